@@ -41,6 +41,9 @@ type ConnectionArgs struct {
 	// Authentication interactor
 	AuthInteractor []httpbakery.Interactor
 
+	// OIDC tokens (updated in place when refreshed or obtained through a new login)
+	OIDCTokens *OIDCTokens
+
 	// Custom proxy
 	Proxy func(*http.Request) (*url.URL, error)
 
@@ -276,6 +279,10 @@ func httpsLXD(url string, args *ConnectionArgs) (InstanceServer, error) {
 		server.setupBakeryClient()
 	}
 
+	if args.AuthType == "oidc" {
+		server.oidcClient = newOIDCClient(args.OIDCTokens, args.Proxy)
+	}
+
 	// Test the connection and seed the server information
 	if !args.SkipGetServer {
 		_, _, err := server.GetServer()
@@ -39,6 +39,8 @@ type ProtocolLXD struct {
 	bakeryInteractor     []httpbakery.Interactor
 	requireAuthenticated bool
 
+	oidcClient *oidcClient
+
 	clusterTarget string
 	project       string
 }
@@ -93,7 +95,7 @@ func (r *ProtocolLXD) GetHTTPClient() (*http.Client, error) {
 	return r.http, nil
 }
 
-// Do performs a Request, using macaroon authentication if set.
+// Do performs a Request, using macaroon or OIDC authentication if set.
 func (r *ProtocolLXD) do(req *http.Request) (*http.Response, error) {
 	// Set the user agent
 	if r.httpUserAgent != "" {
@@ -106,6 +108,10 @@ func (r *ProtocolLXD) do(req *http.Request) (*http.Response, error) {
 		return r.bakeryClient.Do(req)
 	}
 
+	if r.oidcClient != nil {
+		return r.oidcClient.do(r.http, req)
+	}
+
 	return r.http.Do(req)
 }
 
@@ -375,6 +381,11 @@ func (r *ProtocolLXD) rawWebsocket(url string) (*websocket.Conn, error) {
 		r.addMacaroonHeaders(req)
 	}
 
+	// Set the OIDC token if needed
+	if r.oidcClient != nil {
+		r.oidcClient.setHeader(&http.Request{Header: headers})
+	}
+
 	// Establish the connection
 	conn, _, err := dialer.Dial(url, headers)
 	if err != nil {
@@ -336,7 +336,7 @@ func (r *ProtocolLXD) CopyContainer(source InstanceServer, container api.Contain
 	}
 
 	if req.Source.Live {
-		req.Source.Live = container.StatusCode == api.Running
+		req.Source.Live = container.StatusCode == api.Running || container.StatusCode == api.Ready
 	}
 
 	sourceInfo, err := source.GetConnectionInfo()
@@ -1741,7 +1741,7 @@ func (r *ProtocolLXD) GetContainerBackupFile(containerName string, name string,
 	}
 
 	// Start the request
-	response, doneCh, err := cancel.CancelableDownload(req.Canceler, r.http, request)
+	response, doneCh, err := cancel.CancelableDownload(req.Canceler, r.downloadClient(), request)
 	if err != nil {
 		return nil, err
 	}
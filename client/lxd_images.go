@@ -134,7 +134,7 @@ func (r *ProtocolLXD) GetPrivateImageFile(fingerprint string, secret string, req
 		}
 	}
 
-	return lxdDownloadImage(fingerprint, uri, r.httpUserAgent, r.http, req)
+	return lxdDownloadImage(fingerprint, uri, r.httpUserAgent, r.downloadClient(), req)
 }
 
 func lxdDownloadImage(fingerprint string, uri string, userAgent string, client *http.Client, req ImageFileRequest) (*ImageFileResponse, error) {
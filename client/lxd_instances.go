@@ -236,7 +236,7 @@ func (r *ProtocolLXD) CreateInstanceFromBackup(args InstanceBackupArgs) (Operati
 		return nil, err
 	}
 
-	if args.PoolName == "" && args.Name == "" {
+	if args.PoolName == "" && args.Name == "" && args.BackupName == "" {
 		// Send the request
 		op, _, err := r.queryOperation("POST", path, args.BackupFile, "")
 		if err != nil {
@@ -254,6 +254,10 @@ func (r *ProtocolLXD) CreateInstanceFromBackup(args InstanceBackupArgs) (Operati
 		return nil, fmt.Errorf(`The server is missing the required "backup_override_name" API extension`)
 	}
 
+	if args.BackupName != "" && !r.HasExtension("backup_target") {
+		return nil, fmt.Errorf(`The server is missing the required "backup_target" API extension`)
+	}
+
 	// Prepare the HTTP request
 	reqURL, err := r.setQueryAttributes(fmt.Sprintf("%s/1.0%s", r.httpHost, path))
 	if err != nil {
@@ -275,6 +279,10 @@ func (r *ProtocolLXD) CreateInstanceFromBackup(args InstanceBackupArgs) (Operati
 		req.Header.Set("X-LXD-name", args.Name)
 	}
 
+	if args.BackupName != "" {
+		req.Header.Set("X-LXD-backup", args.BackupName)
+	}
+
 	// Send the request
 	resp, err := r.do(req)
 	if err != nil {
@@ -512,7 +520,7 @@ func (r *ProtocolLXD) CopyInstance(source InstanceServer, instance api.Instance,
 	}
 
 	if req.Source.Live {
-		req.Source.Live = instance.StatusCode == api.Running
+		req.Source.Live = instance.StatusCode == api.Running || instance.StatusCode == api.Ready
 	}
 
 	sourceInfo, err := source.GetConnectionInfo()
@@ -1754,6 +1762,48 @@ func (r *ProtocolLXD) UpdateInstanceMetadata(name string, metadata api.ImageMeta
 	return nil
 }
 
+// GetInstanceUEFIVars returns the UEFI variables of a virtual machine.
+func (r *ProtocolLXD) GetInstanceUEFIVars(name string) (*api.InstanceUEFIVars, string, error) {
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !r.HasExtension("instance_uefi_vars") {
+		return nil, "", fmt.Errorf("The server is missing the required \"instance_uefi_vars\" API extension")
+	}
+
+	vars := api.InstanceUEFIVars{}
+
+	url := fmt.Sprintf("%s/%s/uefi-vars", path, url.PathEscape(name))
+	etag, err := r.queryStruct("GET", url, nil, "", &vars)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &vars, etag, err
+}
+
+// UpdateInstanceUEFIVars replaces the UEFI variables of a stopped virtual machine.
+func (r *ProtocolLXD) UpdateInstanceUEFIVars(name string, vars api.InstanceUEFIVars, ETag string) error {
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return err
+	}
+
+	if !r.HasExtension("instance_uefi_vars") {
+		return fmt.Errorf("The server is missing the required \"instance_uefi_vars\" API extension")
+	}
+
+	url := fmt.Sprintf("%s/%s/uefi-vars", path, url.PathEscape(name))
+	_, _, err = r.query("PUT", url, vars, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // GetInstanceTemplateFiles returns the list of names of template files for a instance.
 func (r *ProtocolLXD) GetInstanceTemplateFiles(instanceName string) ([]string, error) {
 	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
@@ -2177,6 +2227,10 @@ func (r *ProtocolLXD) CreateInstanceBackup(instanceName string, backup api.Insta
 		return nil, fmt.Errorf("The server is missing the required \"container_backup\" API extension")
 	}
 
+	if backup.Incremental != "" && !r.HasExtension("backup_incremental") {
+		return nil, fmt.Errorf("The server is missing the required \"backup_incremental\" API extension")
+	}
+
 	// Send the request
 	op, _, err := r.queryOperation("POST", fmt.Sprintf("%s/%s/backups", path, url.PathEscape(instanceName)), backup, "")
 	if err != nil {
@@ -2254,7 +2308,7 @@ func (r *ProtocolLXD) GetInstanceBackupFile(instanceName string, name string, re
 	}
 
 	// Start the request
-	response, doneCh, err := cancel.CancelableDownload(req.Canceler, r.http, request)
+	response, doneCh, err := cancel.CancelableDownload(req.Canceler, r.downloadClient(), request)
 	if err != nil {
 		return nil, err
 	}
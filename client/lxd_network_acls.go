@@ -118,3 +118,20 @@ func (r *ProtocolLXD) DeleteNetworkACL(name string) error {
 
 	return nil
 }
+
+// GetNetworkACLLog returns the traffic log entries matched by the logged rules of a network ACL.
+func (r *ProtocolLXD) GetNetworkACLLog(name string) ([]api.NetworkACLLogEntry, error) {
+	if !r.HasExtension("network_acl_log") {
+		return nil, fmt.Errorf(`The server is missing the required "network_acl_log" API extension`)
+	}
+
+	entries := []api.NetworkACLLogEntry{}
+
+	// Fetch the raw value.
+	_, err := r.queryStruct("GET", fmt.Sprintf("/network-acls/%s/log", url.PathEscape(name)), nil, "", &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
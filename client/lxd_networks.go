@@ -76,6 +76,40 @@ func (r *ProtocolLXD) GetNetworkLeases(name string) ([]api.NetworkLease, error)
 	return leases, nil
 }
 
+// GetNetworkAllocations returns a list of the addresses allocated on the networks of the current project.
+func (r *ProtocolLXD) GetNetworkAllocations() ([]api.NetworkAllocation, error) {
+	if !r.HasExtension("network_allocations") {
+		return nil, fmt.Errorf("The server is missing the required \"network_allocations\" API extension")
+	}
+
+	allocations := []api.NetworkAllocation{}
+
+	// Fetch the raw value.
+	_, err := r.queryStruct("GET", "/network-allocations", nil, "", &allocations)
+	if err != nil {
+		return nil, err
+	}
+
+	return allocations, nil
+}
+
+// GetNetworkAllocationsAllProjects returns a list of the addresses allocated on the networks of all projects.
+func (r *ProtocolLXD) GetNetworkAllocationsAllProjects() ([]api.NetworkAllocation, error) {
+	if !r.HasExtension("network_allocations") {
+		return nil, fmt.Errorf("The server is missing the required \"network_allocations\" API extension")
+	}
+
+	allocations := []api.NetworkAllocation{}
+
+	// Fetch the raw value.
+	_, err := r.queryStruct("GET", "/network-allocations?all-projects=true", nil, "", &allocations)
+	if err != nil {
+		return nil, err
+	}
+
+	return allocations, nil
+}
+
 // GetNetworkState returns metrics and information on the running network
 func (r *ProtocolLXD) GetNetworkState(name string) (*api.NetworkState, error) {
 	if !r.HasExtension("network_state") {
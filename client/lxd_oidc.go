@@ -0,0 +1,342 @@
+package lxd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCTokens holds the OpenID Connect tokens used to authenticate with a LXD server.
+type OIDCTokens struct {
+	AccessToken  string    `json:"access_token" yaml:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty" yaml:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty" yaml:"expiry,omitempty"`
+}
+
+// Response headers used by the server to advertise the OIDC issuer.
+const (
+	oidcHeaderIssuer   = "X-LXD-OIDC-issuer"
+	oidcHeaderClientID = "X-LXD-OIDC-clientid"
+	oidcHeaderAudience = "X-LXD-OIDC-audience"
+)
+
+// oidcDeviceCodeGrantType is the grant type of the device authorization flow (RFC 8628).
+const oidcDeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+type oidcClient struct {
+	// Client used to talk to the issuer (not the LXD server).
+	httpClient *http.Client
+
+	tokens *OIDCTokens
+	lock   sync.Mutex
+}
+
+type oidcProviderConfig struct {
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+}
+
+type oidcTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Error        string `json:"error"`
+	Description  string `json:"error_description"`
+}
+
+type oidcDeviceAuthResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+func newOIDCClient(tokens *OIDCTokens, proxy func(*http.Request) (*url.URL, error)) *oidcClient {
+	if tokens == nil {
+		tokens = &OIDCTokens{}
+	}
+
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+
+	return &oidcClient{
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{Proxy: proxy},
+		},
+		tokens: tokens,
+	}
+}
+
+// setHeader adds the current access token to the request.
+func (o *oidcClient) setHeader(req *http.Request) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	req.Header.Set("Authorization", "Bearer "+o.tokens.AccessToken)
+}
+
+// do sends the request, refreshing the tokens or logging in again when the server rejects them.
+func (o *oidcClient) do(client *http.Client, req *http.Request) (*http.Response, error) {
+	o.setHeader(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := resp.Header.Get(oidcHeaderIssuer)
+	clientID := resp.Header.Get(oidcHeaderClientID)
+	audience := resp.Header.Get(oidcHeaderAudience)
+	if resp.StatusCode != http.StatusUnauthorized || issuer == "" || clientID == "" {
+		return resp, nil
+	}
+
+	// The request can only be replayed if its body can be rewound.
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	_ = resp.Body.Close()
+
+	err = o.refresh(issuer, clientID)
+	if err != nil {
+		err = o.authenticate(issuer, clientID, audience)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.GetBody != nil {
+		req.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	o.setHeader(req)
+
+	return client.Do(req)
+}
+
+// refresh gets a new access token using the refresh token.
+func (o *oidcClient) refresh(issuer string, clientID string) error {
+	o.lock.Lock()
+	refreshToken := o.tokens.RefreshToken
+	o.lock.Unlock()
+
+	if refreshToken == "" {
+		return fmt.Errorf("No refresh token available")
+	}
+
+	provider, err := o.providerConfig(issuer)
+	if err != nil {
+		return err
+	}
+
+	values := url.Values{}
+	values.Set("grant_type", "refresh_token")
+	values.Set("refresh_token", refreshToken)
+	values.Set("client_id", clientID)
+
+	token, err := o.tokenRequest(provider.TokenEndpoint, values)
+	if err != nil {
+		return err
+	}
+
+	if token.Error != "" {
+		return fmt.Errorf("Failed refreshing OIDC token: %s", token.Error)
+	}
+
+	o.setTokens(token)
+
+	return nil
+}
+
+// authenticate performs the device authorization flow, asking the user to log in through their browser.
+func (o *oidcClient) authenticate(issuer string, clientID string, audience string) error {
+	provider, err := o.providerConfig(issuer)
+	if err != nil {
+		return err
+	}
+
+	if provider.DeviceAuthorizationEndpoint == "" {
+		return fmt.Errorf("The OIDC issuer doesn't support the device authorization flow")
+	}
+
+	values := url.Values{}
+	values.Set("client_id", clientID)
+	values.Set("scope", "openid email profile offline_access")
+	if audience != "" {
+		values.Set("audience", audience)
+	}
+
+	resp, err := o.httpClient.PostForm(provider.DeviceAuthorizationEndpoint, values)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed starting OIDC device authorization: %s", resp.Status)
+	}
+
+	deviceAuth := oidcDeviceAuthResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&deviceAuth)
+	if err != nil {
+		return err
+	}
+
+	if deviceAuth.VerificationURIComplete != "" {
+		fmt.Fprintf(os.Stderr, "URL: %s\n", deviceAuth.VerificationURIComplete)
+	} else {
+		fmt.Fprintf(os.Stderr, "URL: %s\n", deviceAuth.VerificationURI)
+	}
+
+	fmt.Fprintf(os.Stderr, "Code: %s\n\n", deviceAuth.UserCode)
+
+	interval := time.Duration(deviceAuth.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	expiresIn := time.Duration(deviceAuth.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = 5 * time.Minute
+	}
+
+	deadline := time.Now().Add(expiresIn)
+
+	values = url.Values{}
+	values.Set("grant_type", oidcDeviceCodeGrantType)
+	values.Set("device_code", deviceAuth.DeviceCode)
+	values.Set("client_id", clientID)
+
+	for time.Now().Before(deadline) {
+		token, err := o.tokenRequest(provider.TokenEndpoint, values)
+		if err != nil {
+			return err
+		}
+
+		switch token.Error {
+		case "":
+			o.setTokens(token)
+			return nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			if token.Description != "" {
+				return fmt.Errorf("Failed OIDC authentication: %s (%s)", token.Error, token.Description)
+			}
+
+			return fmt.Errorf("Failed OIDC authentication: %s", token.Error)
+		}
+
+		time.Sleep(interval)
+	}
+
+	return fmt.Errorf("Timed out waiting for OIDC authentication")
+}
+
+// providerConfig fetches the issuer OpenID configuration.
+func (o *oidcClient) providerConfig(issuer string) (*oidcProviderConfig, error) {
+	resp, err := o.httpClient.Get(strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed OIDC discovery: %s", resp.Status)
+	}
+
+	provider := oidcProviderConfig{}
+	err = json.NewDecoder(resp.Body).Decode(&provider)
+	if err != nil {
+		return nil, err
+	}
+
+	if provider.TokenEndpoint == "" {
+		return nil, fmt.Errorf("The OIDC issuer didn't provide a token endpoint")
+	}
+
+	return &provider, nil
+}
+
+// tokenRequest posts to the token endpoint. OAuth errors are returned in the response Error field.
+func (o *oidcClient) tokenRequest(endpoint string, values url.Values) (*oidcTokenResponse, error) {
+	resp, err := o.httpClient.PostForm(endpoint, values)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	token := oidcTokenResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return nil, fmt.Errorf("Failed decoding OIDC token response (%s): %w", resp.Status, err)
+	}
+
+	if resp.StatusCode != http.StatusOK && token.Error == "" {
+		return nil, fmt.Errorf("Failed OIDC token request: %s", resp.Status)
+	}
+
+	return &token, nil
+}
+
+// setTokens records the tokens returned by the issuer.
+func (o *oidcClient) setTokens(token *oidcTokenResponse) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.tokens.AccessToken = token.AccessToken
+
+	// Issuers aren't required to rotate the refresh token.
+	if token.RefreshToken != "" {
+		o.tokens.RefreshToken = token.RefreshToken
+	}
+
+	o.tokens.Expiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		o.tokens.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+}
+
+// oidcTransport adds the current OIDC access token to requests.
+type oidcTransport struct {
+	base http.RoundTripper
+	oidc *oidcClient
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *oidcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	t.oidc.setHeader(req)
+
+	return t.base.RoundTrip(req)
+}
+
+// downloadClient returns the HTTP client to use for direct downloads from the server.
+// Unlike regular queries, those don't go through do() so the OIDC token has to be added by the transport.
+func (r *ProtocolLXD) downloadClient() *http.Client {
+	if r.oidcClient == nil {
+		return r.http
+	}
+
+	client := *r.http
+	client.Transport = &oidcTransport{base: r.http.Transport, oidc: r.oidcClient}
+
+	return &client
+}
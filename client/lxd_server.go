@@ -101,6 +101,7 @@ func (r *ProtocolLXD) UseProject(name string) InstanceServer {
 		bakeryClient:         r.bakeryClient,
 		bakeryInteractor:     r.bakeryInteractor,
 		requireAuthenticated: r.requireAuthenticated,
+		oidcClient:           r.oidcClient,
 		clusterTarget:        r.clusterTarget,
 		project:              name,
 	}
@@ -120,6 +121,7 @@ func (r *ProtocolLXD) UseTarget(name string) InstanceServer {
 		bakeryClient:         r.bakeryClient,
 		bakeryInteractor:     r.bakeryInteractor,
 		requireAuthenticated: r.requireAuthenticated,
+		oidcClient:           r.oidcClient,
 		project:              r.project,
 		clusterTarget:        name,
 	}
@@ -0,0 +1,205 @@
+package lxd
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// GetStoragePoolBucketNames returns a list of storage bucket names.
+func (r *ProtocolLXD) GetStoragePoolBucketNames(poolName string) ([]string, error) {
+	if !r.HasExtension("storage_buckets") {
+		return nil, fmt.Errorf(`The server is missing the required "storage_buckets" API extension`)
+	}
+
+	// Fetch the raw URL values.
+	urls := []string{}
+	baseURL := fmt.Sprintf("/storage-pools/%s/buckets", url.PathEscape(poolName))
+	_, err := r.queryStruct("GET", baseURL, nil, "", &urls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse it.
+	return urlsToResourceNames(baseURL, urls...)
+}
+
+// GetStoragePoolBuckets returns a list of storage buckets for the provided pool.
+func (r *ProtocolLXD) GetStoragePoolBuckets(poolName string) ([]api.StorageBucket, error) {
+	if !r.HasExtension("storage_buckets") {
+		return nil, fmt.Errorf(`The server is missing the required "storage_buckets" API extension`)
+	}
+
+	buckets := []api.StorageBucket{}
+
+	// Fetch the raw value.
+	_, err := r.queryStruct("GET", fmt.Sprintf("/storage-pools/%s/buckets?recursion=1", url.PathEscape(poolName)), nil, "", &buckets)
+	if err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+// GetStoragePoolBucket returns a storage bucket entry for the provided pool and bucket name.
+func (r *ProtocolLXD) GetStoragePoolBucket(poolName string, bucketName string) (*api.StorageBucket, string, error) {
+	if !r.HasExtension("storage_buckets") {
+		return nil, "", fmt.Errorf(`The server is missing the required "storage_buckets" API extension`)
+	}
+
+	bucket := api.StorageBucket{}
+
+	// Fetch the raw value.
+	etag, err := r.queryStruct("GET", fmt.Sprintf("/storage-pools/%s/buckets/%s", url.PathEscape(poolName), url.PathEscape(bucketName)), nil, "", &bucket)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &bucket, etag, nil
+}
+
+// CreateStoragePoolBucket defines a new storage bucket using the provided struct.
+func (r *ProtocolLXD) CreateStoragePoolBucket(poolName string, bucket api.StorageBucketsPost) error {
+	if !r.HasExtension("storage_buckets") {
+		return fmt.Errorf(`The server is missing the required "storage_buckets" API extension`)
+	}
+
+	// Send the request.
+	_, _, err := r.query("POST", fmt.Sprintf("/storage-pools/%s/buckets", url.PathEscape(poolName)), bucket, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateStoragePoolBucket updates the storage bucket to match the provided struct.
+func (r *ProtocolLXD) UpdateStoragePoolBucket(poolName string, bucketName string, bucket api.StorageBucketPut, ETag string) error {
+	if !r.HasExtension("storage_buckets") {
+		return fmt.Errorf(`The server is missing the required "storage_buckets" API extension`)
+	}
+
+	// Send the request.
+	_, _, err := r.query("PUT", fmt.Sprintf("/storage-pools/%s/buckets/%s", url.PathEscape(poolName), url.PathEscape(bucketName)), bucket, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteStoragePoolBucket deletes an existing storage bucket.
+func (r *ProtocolLXD) DeleteStoragePoolBucket(poolName string, bucketName string) error {
+	if !r.HasExtension("storage_buckets") {
+		return fmt.Errorf(`The server is missing the required "storage_buckets" API extension`)
+	}
+
+	// Send the request.
+	_, _, err := r.query("DELETE", fmt.Sprintf("/storage-pools/%s/buckets/%s", url.PathEscape(poolName), url.PathEscape(bucketName)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetStoragePoolBucketKeyNames returns a list of storage bucket key names.
+func (r *ProtocolLXD) GetStoragePoolBucketKeyNames(poolName string, bucketName string) ([]string, error) {
+	if !r.HasExtension("storage_buckets") {
+		return nil, fmt.Errorf(`The server is missing the required "storage_buckets" API extension`)
+	}
+
+	// Fetch the raw URL values.
+	urls := []string{}
+	baseURL := fmt.Sprintf("/storage-pools/%s/buckets/%s/keys", url.PathEscape(poolName), url.PathEscape(bucketName))
+	_, err := r.queryStruct("GET", baseURL, nil, "", &urls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse it.
+	return urlsToResourceNames(baseURL, urls...)
+}
+
+// GetStoragePoolBucketKeys returns a list of storage bucket keys for the provided pool and bucket.
+func (r *ProtocolLXD) GetStoragePoolBucketKeys(poolName string, bucketName string) ([]api.StorageBucketKey, error) {
+	if !r.HasExtension("storage_buckets") {
+		return nil, fmt.Errorf(`The server is missing the required "storage_buckets" API extension`)
+	}
+
+	keys := []api.StorageBucketKey{}
+
+	// Fetch the raw value.
+	_, err := r.queryStruct("GET", fmt.Sprintf("/storage-pools/%s/buckets/%s/keys?recursion=1", url.PathEscape(poolName), url.PathEscape(bucketName)), nil, "", &keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// GetStoragePoolBucketKey returns a storage bucket key entry for the provided pool, bucket and key name.
+func (r *ProtocolLXD) GetStoragePoolBucketKey(poolName string, bucketName string, keyName string) (*api.StorageBucketKey, string, error) {
+	if !r.HasExtension("storage_buckets") {
+		return nil, "", fmt.Errorf(`The server is missing the required "storage_buckets" API extension`)
+	}
+
+	key := api.StorageBucketKey{}
+
+	// Fetch the raw value.
+	etag, err := r.queryStruct("GET", fmt.Sprintf("/storage-pools/%s/buckets/%s/keys/%s", url.PathEscape(poolName), url.PathEscape(bucketName), url.PathEscape(keyName)), nil, "", &key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &key, etag, nil
+}
+
+// CreateStoragePoolBucketKey adds a key to a storage bucket.
+// The returned key includes any credentials generated by the server.
+func (r *ProtocolLXD) CreateStoragePoolBucketKey(poolName string, bucketName string, key api.StorageBucketKeysPost) (*api.StorageBucketKey, error) {
+	if !r.HasExtension("storage_buckets") {
+		return nil, fmt.Errorf(`The server is missing the required "storage_buckets" API extension`)
+	}
+
+	newKey := api.StorageBucketKey{}
+
+	// Send the request.
+	_, err := r.queryStruct("POST", fmt.Sprintf("/storage-pools/%s/buckets/%s/keys", url.PathEscape(poolName), url.PathEscape(bucketName)), key, "", &newKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &newKey, nil
+}
+
+// UpdateStoragePoolBucketKey updates an existing storage bucket key.
+func (r *ProtocolLXD) UpdateStoragePoolBucketKey(poolName string, bucketName string, keyName string, key api.StorageBucketKeyPut, ETag string) error {
+	if !r.HasExtension("storage_buckets") {
+		return fmt.Errorf(`The server is missing the required "storage_buckets" API extension`)
+	}
+
+	// Send the request.
+	_, _, err := r.query("PUT", fmt.Sprintf("/storage-pools/%s/buckets/%s/keys/%s", url.PathEscape(poolName), url.PathEscape(bucketName), url.PathEscape(keyName)), key, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteStoragePoolBucketKey removes a key from a storage bucket.
+func (r *ProtocolLXD) DeleteStoragePoolBucketKey(poolName string, bucketName string, keyName string) error {
+	if !r.HasExtension("storage_buckets") {
+		return fmt.Errorf(`The server is missing the required "storage_buckets" API extension`)
+	}
+
+	// Send the request.
+	_, _, err := r.query("DELETE", fmt.Sprintf("/storage-pools/%s/buckets/%s/keys/%s", url.PathEscape(poolName), url.PathEscape(bucketName), url.PathEscape(keyName)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
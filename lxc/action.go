@@ -326,7 +326,7 @@ func (c *cmdAction) Run(cmd *cobra.Command, args []string) error {
 			for _, ct := range ctslist {
 				switch cmd.Name() {
 				case "start":
-					if ct.StatusCode == api.Running {
+					if ct.StatusCode == api.Running || ct.StatusCode == api.Ready {
 						continue
 					}
 				case "stop":
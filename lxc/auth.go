@@ -0,0 +1,839 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/lxc/utils"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	cli "github.com/lxc/lxd/shared/cmd"
+	"github.com/lxc/lxd/shared/i18n"
+	"github.com/lxc/lxd/shared/termios"
+)
+
+type cmdAuth struct {
+	global *cmdGlobal
+}
+
+func (c *cmdAuth) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("auth")
+	cmd.Short = i18n.G("Manage authorization")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Manage authorization"))
+
+	// Group.
+	authGroupCmd := cmdAuthGroup{global: c.global}
+	cmd.AddCommand(authGroupCmd.Command())
+
+	// Workaround for subcommand usage errors. See: https://github.com/spf13/cobra/issues/706
+	cmd.Args = cobra.NoArgs
+	cmd.Run = func(cmd *cobra.Command, args []string) { cmd.Usage() }
+	return cmd
+}
+
+type cmdAuthGroup struct {
+	global *cmdGlobal
+}
+
+func (c *cmdAuthGroup) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("group")
+	cmd.Short = i18n.G("Manage authorization groups")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Manage authorization groups"))
+
+	// List.
+	authGroupListCmd := cmdAuthGroupList{global: c.global, authGroup: c}
+	cmd.AddCommand(authGroupListCmd.Command())
+
+	// Show.
+	authGroupShowCmd := cmdAuthGroupShow{global: c.global, authGroup: c}
+	cmd.AddCommand(authGroupShowCmd.Command())
+
+	// Create.
+	authGroupCreateCmd := cmdAuthGroupCreate{global: c.global, authGroup: c}
+	cmd.AddCommand(authGroupCreateCmd.Command())
+
+	// Edit.
+	authGroupEditCmd := cmdAuthGroupEdit{global: c.global, authGroup: c}
+	cmd.AddCommand(authGroupEditCmd.Command())
+
+	// Rename.
+	authGroupRenameCmd := cmdAuthGroupRename{global: c.global, authGroup: c}
+	cmd.AddCommand(authGroupRenameCmd.Command())
+
+	// Delete.
+	authGroupDeleteCmd := cmdAuthGroupDelete{global: c.global, authGroup: c}
+	cmd.AddCommand(authGroupDeleteCmd.Command())
+
+	// Permission.
+	authGroupPermissionCmd := cmdAuthGroupPermission{global: c.global, authGroup: c}
+	cmd.AddCommand(authGroupPermissionCmd.Command())
+
+	// Identity.
+	authGroupIdentityCmd := cmdAuthGroupIdentity{global: c.global, authGroup: c}
+	cmd.AddCommand(authGroupIdentityCmd.Command())
+
+	// Workaround for subcommand usage errors. See: https://github.com/spf13/cobra/issues/706
+	cmd.Args = cobra.NoArgs
+	cmd.Run = func(cmd *cobra.Command, args []string) { cmd.Usage() }
+	return cmd
+}
+
+// List.
+type cmdAuthGroupList struct {
+	global    *cmdGlobal
+	authGroup *cmdAuthGroup
+
+	flagFormat string
+}
+
+func (c *cmdAuthGroupList) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("list", i18n.G("[<remote>:]"))
+	cmd.Aliases = []string{"ls"}
+	cmd.Short = i18n.G("List authorization groups")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("List authorization groups"))
+
+	cmd.RunE = c.Run
+	cmd.Flags().StringVarP(&c.flagFormat, "format", "f", "table", i18n.G("Format (csv|json|table|yaml)")+"``")
+
+	return cmd
+}
+
+func (c *cmdAuthGroupList) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 0, 1)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	remote := ""
+	if len(args) > 0 {
+		remote = args[0]
+	}
+
+	resources, err := c.global.ParseServers(remote)
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name != "" {
+		return fmt.Errorf(i18n.G("Filtering isn't supported yet"))
+	}
+
+	groups, err := resource.server.GetAuthGroups()
+	if err != nil {
+		return err
+	}
+
+	data := [][]string{}
+	for _, group := range groups {
+		details := []string{
+			group.Name,
+			group.Description,
+			fmt.Sprintf("%d", len(group.Permissions)),
+			fmt.Sprintf("%d", len(group.Identities)),
+		}
+
+		data = append(data, details)
+	}
+
+	sort.Sort(byName(data))
+
+	header := []string{
+		i18n.G("NAME"),
+		i18n.G("DESCRIPTION"),
+		i18n.G("PERMISSIONS"),
+		i18n.G("IDENTITIES"),
+	}
+
+	return utils.RenderTable(c.flagFormat, header, data, groups)
+}
+
+// Show.
+type cmdAuthGroupShow struct {
+	global    *cmdGlobal
+	authGroup *cmdAuthGroup
+}
+
+func (c *cmdAuthGroupShow) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("show", i18n.G("[<remote>:]<group>"))
+	cmd.Short = i18n.G("Show authorization group configurations")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Show authorization group configurations"))
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdAuthGroupShow) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 1, 1)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing authorization group name"))
+	}
+
+	group, _, err := resource.server.GetAuthGroup(resource.name)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&group)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", data)
+
+	return nil
+}
+
+// Create.
+type cmdAuthGroupCreate struct {
+	global    *cmdGlobal
+	authGroup *cmdAuthGroup
+
+	flagDescription string
+}
+
+func (c *cmdAuthGroupCreate) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("create", i18n.G("[<remote>:]<group>"))
+	cmd.Short = i18n.G("Create authorization groups")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Create authorization groups"))
+	cmd.Flags().StringVar(&c.flagDescription, "description", "", i18n.G("Group description")+"``")
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdAuthGroupCreate) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 1, 1)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing authorization group name"))
+	}
+
+	// If stdin isn't a terminal, read yaml from it.
+	var groupPut api.AuthGroupPut
+	if !termios.IsTerminal(getStdinFd()) {
+		contents, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		err = yaml.UnmarshalStrict(contents, &groupPut)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.flagDescription != "" {
+		groupPut.Description = c.flagDescription
+	}
+
+	// Create the group.
+	group := api.AuthGroupsPost{
+		Name:         resource.name,
+		AuthGroupPut: groupPut,
+	}
+
+	err = resource.server.CreateAuthGroup(group)
+	if err != nil {
+		return err
+	}
+
+	if !c.global.flagQuiet {
+		fmt.Printf(i18n.G("Authorization group %s created")+"\n", resource.name)
+	}
+
+	return nil
+}
+
+// Edit.
+type cmdAuthGroupEdit struct {
+	global    *cmdGlobal
+	authGroup *cmdAuthGroup
+}
+
+func (c *cmdAuthGroupEdit) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("edit", i18n.G("[<remote>:]<group>"))
+	cmd.Short = i18n.G("Edit authorization groups as YAML")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Edit authorization groups as YAML"))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdAuthGroupEdit) helpTemplate() string {
+	return i18n.G(
+		`### This is a YAML representation of the authorization group.
+### Any line starting with a '# will be ignored.
+###
+### An authorization group grants permissions to a set of identities.
+###
+### An example would look like:
+### name: operators
+### description: Instance operators
+### permissions:
+### - entitlement: can_exec
+###   entity_type: instance
+###   project: default
+###   entity_name: c1
+### identities:
+### - fd200419b271f1dc2a5591b693cc5774b7f234e1ff8c6b78ad703b6888fe2b69
+###
+### Note that the name is shown but cannot be changed`)
+}
+
+func (c *cmdAuthGroupEdit) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 1, 1)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing authorization group name"))
+	}
+
+	// If stdin isn't a terminal, read text from it
+	if !termios.IsTerminal(getStdinFd()) {
+		contents, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		newdata := api.AuthGroup{}
+		err = yaml.UnmarshalStrict(contents, &newdata)
+		if err != nil {
+			return err
+		}
+
+		return resource.server.UpdateAuthGroup(resource.name, newdata.Writable(), "")
+	}
+
+	// Get the current config.
+	group, etag, err := resource.server.GetAuthGroup(resource.name)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&group)
+	if err != nil {
+		return err
+	}
+
+	// Spawn the editor.
+	content, err := shared.TextEditor("", []byte(c.helpTemplate()+"\n\n"+string(data)))
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Parse the text received from the editor.
+		newdata := api.AuthGroup{}
+		err = yaml.UnmarshalStrict(content, &newdata)
+		if err == nil {
+			err = resource.server.UpdateAuthGroup(resource.name, newdata.Writable(), etag)
+		}
+
+		// Respawn the editor.
+		if err != nil {
+			fmt.Fprintf(os.Stderr, i18n.G("Config parsing error: %s")+"\n", err)
+			fmt.Println(i18n.G("Press enter to open the editor again or ctrl+c to abort change"))
+
+			_, err := os.Stdin.Read(make([]byte, 1))
+			if err != nil {
+				return err
+			}
+
+			content, err = shared.TextEditor("", content)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		break
+	}
+
+	return nil
+}
+
+// Rename.
+type cmdAuthGroupRename struct {
+	global    *cmdGlobal
+	authGroup *cmdAuthGroup
+}
+
+func (c *cmdAuthGroupRename) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("rename", i18n.G("[<remote>:]<group> <new-name>"))
+	cmd.Aliases = []string{"mv"}
+	cmd.Short = i18n.G("Rename authorization groups")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Rename authorization groups"))
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdAuthGroupRename) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing authorization group name"))
+	}
+
+	// Rename the group.
+	err = resource.server.RenameAuthGroup(resource.name, api.AuthGroupPost{Name: args[1]})
+	if err != nil {
+		return err
+	}
+
+	if !c.global.flagQuiet {
+		fmt.Printf(i18n.G("Authorization group %s renamed to %s")+"\n", resource.name, args[1])
+	}
+
+	return nil
+}
+
+// Delete.
+type cmdAuthGroupDelete struct {
+	global    *cmdGlobal
+	authGroup *cmdAuthGroup
+}
+
+func (c *cmdAuthGroupDelete) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("delete", i18n.G("[<remote>:]<group>"))
+	cmd.Aliases = []string{"rm"}
+	cmd.Short = i18n.G("Delete authorization groups")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Delete authorization groups"))
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdAuthGroupDelete) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 1, 1)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing authorization group name"))
+	}
+
+	// Delete the group.
+	err = resource.server.DeleteAuthGroup(resource.name)
+	if err != nil {
+		return err
+	}
+
+	if !c.global.flagQuiet {
+		fmt.Printf(i18n.G("Authorization group %s deleted")+"\n", resource.name)
+	}
+
+	return nil
+}
+
+// Permission.
+type cmdAuthGroupPermission struct {
+	global    *cmdGlobal
+	authGroup *cmdAuthGroup
+}
+
+func (c *cmdAuthGroupPermission) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("permission")
+	cmd.Short = i18n.G("Manage authorization group permissions")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Manage authorization group permissions"))
+
+	// Add.
+	authGroupPermissionAddCmd := cmdAuthGroupPermissionAdd{global: c.global, authGroupPermission: c}
+	cmd.AddCommand(authGroupPermissionAddCmd.Command())
+
+	// Remove.
+	authGroupPermissionRemoveCmd := cmdAuthGroupPermissionRemove{global: c.global, authGroupPermission: c}
+	cmd.AddCommand(authGroupPermissionRemoveCmd.Command())
+
+	// Workaround for subcommand usage errors. See: https://github.com/spf13/cobra/issues/706
+	cmd.Args = cobra.NoArgs
+	cmd.Run = func(cmd *cobra.Command, args []string) { cmd.Usage() }
+	return cmd
+}
+
+// parsePermission builds a permission from the entity type, optional entity name and entitlement arguments.
+// Entities other than the server and projects are looked up in the current project.
+func (c *cmdAuthGroupPermission) parsePermission(remote string, args []string) (*api.AuthPermission, error) {
+	permission := api.AuthPermission{
+		EntityType:  args[0],
+		Entitlement: args[len(args)-1],
+	}
+
+	if len(args) == 2 {
+		return &permission, nil
+	}
+
+	if permission.EntityType == "project" {
+		permission.Project = args[1]
+		return &permission, nil
+	}
+
+	permission.EntityName = args[1]
+
+	permission.Project = c.global.conf.ProjectOverride
+	if permission.Project == "" {
+		permission.Project = c.global.conf.Remotes[remote].Project
+	}
+
+	if permission.Project == "" {
+		permission.Project = "default"
+	}
+
+	return &permission, nil
+}
+
+// Add.
+type cmdAuthGroupPermissionAdd struct {
+	global              *cmdGlobal
+	authGroupPermission *cmdAuthGroupPermission
+}
+
+func (c *cmdAuthGroupPermissionAdd) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("add", i18n.G("[<remote>:]<group> <entity_type> [<entity_name>] <entitlement>"))
+	cmd.Short = i18n.G("Add permissions to authorization groups")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Add permissions to authorization groups
+
+Instances, networks and storage volumes are looked up in the current project.
+Storage volumes are named <pool>/<type>/<volume>.`))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc auth group permission add operators server admin
+
+lxc auth group permission add operators project default can_view
+
+lxc auth group permission add operators instance c1 can_exec`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdAuthGroupPermissionAdd) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 3, 4)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing authorization group name"))
+	}
+
+	permission, err := c.authGroupPermission.parsePermission(resource.remote, args[1:])
+	if err != nil {
+		return err
+	}
+
+	// Add the permission.
+	group, etag, err := resource.server.GetAuthGroup(resource.name)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range group.Permissions {
+		if p == *permission {
+			return fmt.Errorf(i18n.G("The group already has this permission"))
+		}
+	}
+
+	group.Permissions = append(group.Permissions, *permission)
+
+	return resource.server.UpdateAuthGroup(resource.name, group.Writable(), etag)
+}
+
+// Remove.
+type cmdAuthGroupPermissionRemove struct {
+	global              *cmdGlobal
+	authGroupPermission *cmdAuthGroupPermission
+}
+
+func (c *cmdAuthGroupPermissionRemove) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("remove", i18n.G("[<remote>:]<group> <entity_type> [<entity_name>] <entitlement>"))
+	cmd.Short = i18n.G("Remove permissions from authorization groups")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Remove permissions from authorization groups"))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdAuthGroupPermissionRemove) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 3, 4)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing authorization group name"))
+	}
+
+	permission, err := c.authGroupPermission.parsePermission(resource.remote, args[1:])
+	if err != nil {
+		return err
+	}
+
+	// Remove the permission.
+	group, etag, err := resource.server.GetAuthGroup(resource.name)
+	if err != nil {
+		return err
+	}
+
+	permissions := []api.AuthPermission{}
+	for _, p := range group.Permissions {
+		if p == *permission {
+			continue
+		}
+
+		permissions = append(permissions, p)
+	}
+
+	if len(permissions) == len(group.Permissions) {
+		return fmt.Errorf(i18n.G("The group doesn't have this permission"))
+	}
+
+	group.Permissions = permissions
+
+	return resource.server.UpdateAuthGroup(resource.name, group.Writable(), etag)
+}
+
+// Identity.
+type cmdAuthGroupIdentity struct {
+	global    *cmdGlobal
+	authGroup *cmdAuthGroup
+}
+
+func (c *cmdAuthGroupIdentity) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("identity")
+	cmd.Short = i18n.G("Manage authorization group identities")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Manage authorization group identities"))
+
+	// Add.
+	authGroupIdentityAddCmd := cmdAuthGroupIdentityAdd{global: c.global, authGroupIdentity: c}
+	cmd.AddCommand(authGroupIdentityAddCmd.Command())
+
+	// Remove.
+	authGroupIdentityRemoveCmd := cmdAuthGroupIdentityRemove{global: c.global, authGroupIdentity: c}
+	cmd.AddCommand(authGroupIdentityRemoveCmd.Command())
+
+	// Workaround for subcommand usage errors. See: https://github.com/spf13/cobra/issues/706
+	cmd.Args = cobra.NoArgs
+	cmd.Run = func(cmd *cobra.Command, args []string) { cmd.Usage() }
+	return cmd
+}
+
+// Add.
+type cmdAuthGroupIdentityAdd struct {
+	global            *cmdGlobal
+	authGroupIdentity *cmdAuthGroupIdentity
+}
+
+func (c *cmdAuthGroupIdentityAdd) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("add", i18n.G("[<remote>:]<group> <fingerprint>"))
+	cmd.Short = i18n.G("Add identities to authorization groups")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Add identities to authorization groups"))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdAuthGroupIdentityAdd) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing authorization group name"))
+	}
+
+	// Resolve partial fingerprints against the trust store.
+	certificate, _, err := resource.server.GetCertificate(args[1])
+	if err != nil {
+		return err
+	}
+
+	// Add the identity.
+	group, etag, err := resource.server.GetAuthGroup(resource.name)
+	if err != nil {
+		return err
+	}
+
+	if shared.StringInSlice(certificate.Fingerprint, group.Identities) {
+		return fmt.Errorf(i18n.G("The identity is already in the group"))
+	}
+
+	group.Identities = append(group.Identities, certificate.Fingerprint)
+
+	return resource.server.UpdateAuthGroup(resource.name, group.Writable(), etag)
+}
+
+// Remove.
+type cmdAuthGroupIdentityRemove struct {
+	global            *cmdGlobal
+	authGroupIdentity *cmdAuthGroupIdentity
+}
+
+func (c *cmdAuthGroupIdentityRemove) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("remove", i18n.G("[<remote>:]<group> <fingerprint>"))
+	cmd.Short = i18n.G("Remove identities from authorization groups")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Remove identities from authorization groups"))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdAuthGroupIdentityRemove) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing authorization group name"))
+	}
+
+	// Remove the identity.
+	group, etag, err := resource.server.GetAuthGroup(resource.name)
+	if err != nil {
+		return err
+	}
+
+	identities := []string{}
+	for _, fingerprint := range group.Identities {
+		if strings.HasPrefix(fingerprint, args[1]) {
+			continue
+		}
+
+		identities = append(identities, fingerprint)
+	}
+
+	if len(identities) == len(group.Identities) {
+		return fmt.Errorf(i18n.G("The identity isn't in the group"))
+	}
+
+	group.Identities = identities
+
+	return resource.server.UpdateAuthGroup(resource.name, group.Writable(), etag)
+}
@@ -1,11 +1,15 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 
 	"github.com/juju/persistent-cookiejar"
+
+	"github.com/lxc/lxd/client"
 )
 
 // Config holds settings to be used by a client or daemon
@@ -35,6 +39,9 @@ type Config struct {
 
 	// Cookie jars
 	cookieJars map[string]*cookiejar.Jar
+
+	// OIDC tokens
+	oidcTokens map[string]*lxd.OIDCTokens
 }
 
 // GlobalConfigPath returns a joined path of the global configuration directory and passed arguments
@@ -62,6 +69,11 @@ func (c *Config) CookiesPath(remote string) string {
 	return c.ConfigPath("jars", remote)
 }
 
+// OIDCTokenPath returns the path for the remote's OIDC tokens
+func (c *Config) OIDCTokenPath(remote string) string {
+	return c.ConfigPath("oidctokens", fmt.Sprintf("%s.json", remote))
+}
+
 // ServerCertPath returns the path for the remote's server certificate
 func (c *Config) ServerCertPath(remote string) string {
 	if c.Remotes[remote].Global == true {
@@ -77,6 +89,27 @@ func (c *Config) SaveCookies() {
 	}
 }
 
+// SaveOIDCTokens saves OIDC tokens to disk
+func (c *Config) SaveOIDCTokens() {
+	for remote, tokens := range c.oidcTokens {
+		if tokens.AccessToken == "" {
+			continue
+		}
+
+		data, err := json.Marshal(tokens)
+		if err != nil {
+			continue
+		}
+
+		err = os.MkdirAll(c.ConfigPath("oidctokens"), 0700)
+		if err != nil {
+			continue
+		}
+
+		_ = ioutil.WriteFile(c.OIDCTokenPath(remote), data, 0600)
+	}
+}
+
 // NewConfig returns a Config, optionally using default remotes.
 func NewConfig(configDir string, defaults bool) *Config {
 	config := &Config{ConfigDir: configDir}
@@ -324,6 +324,12 @@ func (c *cmdConfigTrustList) Run(cmd *cobra.Command, args []string) error {
 	for _, cert := range trust {
 		fp := cert.Fingerprint[0:12]
 
+		// OIDC identities don't have a certificate.
+		if shared.StringInSlice(cert.Type, []string{api.CertificateTypeOIDC, api.CertificateTypeOIDCRevoked}) {
+			data = append(data, []string{cert.Type, cert.Name, "", fp, "", ""})
+			continue
+		}
+
 		certBlock, _ := pem.Decode([]byte(cert.Certificate))
 		if certBlock == nil {
 			return fmt.Errorf(i18n.G("Invalid certificate"))
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	cli "github.com/lxc/lxd/shared/cmd"
+	"github.com/lxc/lxd/shared/i18n"
+	"github.com/lxc/lxd/shared/termios"
+)
+
+// uefiVarDefaultAttr is the attribute set used for new UEFI variables (NON_VOLATILE, BOOTSERVICE_ACCESS and
+// RUNTIME_ACCESS).
+const uefiVarDefaultAttr = 0x7
+
+type cmdConfigUEFI struct {
+	global *cmdGlobal
+	config *cmdConfig
+}
+
+func (c *cmdConfigUEFI) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("uefi")
+	cmd.Short = i18n.G("Manage instance UEFI variables")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Manage instance UEFI variables`))
+
+	// Edit
+	configUEFIEditCmd := cmdConfigUEFIEdit{global: c.global, config: c.config, configUEFI: c}
+	cmd.AddCommand(configUEFIEditCmd.Command())
+
+	// Get
+	configUEFIGetCmd := cmdConfigUEFIGet{global: c.global, config: c.config, configUEFI: c}
+	cmd.AddCommand(configUEFIGetCmd.Command())
+
+	// Set
+	configUEFISetCmd := cmdConfigUEFISet{global: c.global, config: c.config, configUEFI: c}
+	cmd.AddCommand(configUEFISetCmd.Command())
+
+	// Show
+	configUEFIShowCmd := cmdConfigUEFIShow{global: c.global, config: c.config, configUEFI: c}
+	cmd.AddCommand(configUEFIShowCmd.Command())
+
+	// Unset
+	configUEFIUnsetCmd := cmdConfigUEFIUnset{global: c.global, config: c.config, configUEFI: c}
+	cmd.AddCommand(configUEFIUnsetCmd.Command())
+
+	// Workaround for subcommand usage errors. See: https://github.com/spf13/cobra/issues/706
+	cmd.Args = cobra.NoArgs
+	cmd.Run = func(cmd *cobra.Command, args []string) { cmd.Usage() }
+	return cmd
+}
+
+// Edit
+type cmdConfigUEFIEdit struct {
+	global     *cmdGlobal
+	config     *cmdConfig
+	configUEFI *cmdConfigUEFI
+}
+
+func (c *cmdConfigUEFIEdit) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("edit", i18n.G("[<remote>:]<instance>"))
+	cmd.Short = i18n.G("Edit instance UEFI variables")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Edit instance UEFI variables`))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc config uefi edit <instance> < instance_uefi_vars.yaml
+    Set the instance UEFI variables from instance_uefi_vars.yaml.`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdConfigUEFIEdit) helpTemplate() string {
+	return i18n.G(
+		`### This is a YAML representation of the UEFI variables of a virtual machine.
+### Any line starting with a '# will be ignored.
+###
+### Variables are keyed by their name followed by their GUID.
+### Data, timestamps and digests are hex-encoded.
+###
+### A sample configuration looks like:
+###
+### variables:
+###   SecureBootEnable-f0a30bc7-af08-4556-99c4-001009c93a44:
+###     data: "01"
+###     attr: 3`)
+}
+
+func (c *cmdConfigUEFIEdit) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 1, 1)
+	if exit {
+		return err
+	}
+
+	// Parse remote
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing instance name"))
+	}
+
+	// If stdin isn't a terminal, read text from it
+	if !termios.IsTerminal(getStdinFd()) {
+		contents, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		vars := api.InstanceUEFIVars{}
+		err = yaml.Unmarshal(contents, &vars)
+		if err != nil {
+			return err
+		}
+
+		return resource.server.UpdateInstanceUEFIVars(resource.name, vars, "")
+	}
+
+	// Extract the current value
+	vars, etag, err := resource.server.GetInstanceUEFIVars(resource.name)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&vars)
+	if err != nil {
+		return err
+	}
+
+	// Spawn the editor
+	content, err := shared.TextEditor("", []byte(c.helpTemplate()+"\n\n"+string(data)))
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Parse the text received from the editor
+		newVars := api.InstanceUEFIVars{}
+		err = yaml.Unmarshal(content, &newVars)
+		if err == nil {
+			err = resource.server.UpdateInstanceUEFIVars(resource.name, newVars, etag)
+		}
+
+		// Respawn the editor
+		if err != nil {
+			fmt.Fprintf(os.Stderr, i18n.G("Config parsing error: %s")+"\n", err)
+			fmt.Println(i18n.G("Press enter to open the editor again or ctrl+c to abort change"))
+
+			_, err := os.Stdin.Read(make([]byte, 1))
+			if err != nil {
+				return err
+			}
+
+			content, err = shared.TextEditor("", content)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		break
+	}
+
+	return nil
+}
+
+// Get
+type cmdConfigUEFIGet struct {
+	global     *cmdGlobal
+	config     *cmdConfig
+	configUEFI *cmdConfigUEFI
+}
+
+func (c *cmdConfigUEFIGet) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("get", i18n.G("[<remote>:]<instance> <key>"))
+	cmd.Short = i18n.G("Get UEFI variables for instance")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Get UEFI variables for instance`))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc config uefi get v1 BootOrder-8be4df61-93ca-11d2-aa0d-00e098032b8c
+    Show the hex-encoded boot order of the v1 virtual machine.`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdConfigUEFIGet) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	if exit {
+		return err
+	}
+
+	// Parse remote
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing instance name"))
+	}
+
+	vars, _, err := resource.server.GetInstanceUEFIVars(resource.name)
+	if err != nil {
+		return err
+	}
+
+	v, ok := vars.Variables[args[1]]
+	if !ok {
+		return fmt.Errorf(i18n.G("UEFI variable %q not found"), args[1])
+	}
+
+	fmt.Println(v.Data)
+	return nil
+}
+
+// Set
+type cmdConfigUEFISet struct {
+	global     *cmdGlobal
+	config     *cmdConfig
+	configUEFI *cmdConfigUEFI
+}
+
+func (c *cmdConfigUEFISet) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("set", i18n.G("[<remote>:]<instance> <key>=<value>..."))
+	cmd.Short = i18n.G("Set UEFI variables for instance")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Set UEFI variables for instance
+
+Values are hex-encoded. The instance must be stopped.`))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc config uefi set v1 Timeout-8be4df61-93ca-11d2-aa0d-00e098032b8c=0500
+    Set the boot menu timeout of the v1 virtual machine to 5 seconds.`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdConfigUEFISet) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 2, -1)
+	if exit {
+		return err
+	}
+
+	// Parse remote
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing instance name"))
+	}
+
+	// Get the new variables.
+	keys, err := getConfig(args[1:]...)
+	if err != nil {
+		return err
+	}
+
+	vars, etag, err := resource.server.GetInstanceUEFIVars(resource.name)
+	if err != nil {
+		return err
+	}
+
+	uefiVarsSet(vars, keys)
+
+	return resource.server.UpdateInstanceUEFIVars(resource.name, *vars, etag)
+}
+
+// uefiVarsSet sets the data of the given variables, creating the missing ones with the default attributes.
+func uefiVarsSet(vars *api.InstanceUEFIVars, keys map[string]string) {
+	if vars.Variables == nil {
+		vars.Variables = map[string]api.InstanceUEFIVariable{}
+	}
+
+	for k, value := range keys {
+		v, ok := vars.Variables[k]
+		if !ok {
+			v.Attr = uefiVarDefaultAttr
+		}
+
+		v.Data = value
+		vars.Variables[k] = v
+	}
+}
+
+// Show
+type cmdConfigUEFIShow struct {
+	global     *cmdGlobal
+	config     *cmdConfig
+	configUEFI *cmdConfigUEFI
+}
+
+func (c *cmdConfigUEFIShow) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("show", i18n.G("[<remote>:]<instance>"))
+	cmd.Short = i18n.G("Show instance UEFI variables")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Show instance UEFI variables`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdConfigUEFIShow) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 1, 1)
+	if exit {
+		return err
+	}
+
+	// Parse remote
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing instance name"))
+	}
+
+	vars, _, err := resource.server.GetInstanceUEFIVars(resource.name)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&vars)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", data)
+
+	return nil
+}
+
+// Unset
+type cmdConfigUEFIUnset struct {
+	global     *cmdGlobal
+	config     *cmdConfig
+	configUEFI *cmdConfigUEFI
+}
+
+func (c *cmdConfigUEFIUnset) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("unset", i18n.G("[<remote>:]<instance> <key>..."))
+	cmd.Short = i18n.G("Unset UEFI variables for instance")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Unset UEFI variables for instance
+
+The instance must be stopped.`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdConfigUEFIUnset) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 2, -1)
+	if exit {
+		return err
+	}
+
+	// Parse remote
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing instance name"))
+	}
+
+	vars, etag, err := resource.server.GetInstanceUEFIVars(resource.name)
+	if err != nil {
+		return err
+	}
+
+	err = uefiVarsUnset(vars, args[1:])
+	if err != nil {
+		return err
+	}
+
+	return resource.server.UpdateInstanceUEFIVars(resource.name, *vars, etag)
+}
+
+// uefiVarsUnset removes the given variables, failing without removing any if some of them don't exist.
+func uefiVarsUnset(vars *api.InstanceUEFIVars, keys []string) error {
+	missing := []string{}
+	for _, k := range keys {
+		_, ok := vars.Variables[k]
+		if !ok {
+			missing = append(missing, k)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf(i18n.G("UEFI variables not found: %s"), strings.Join(missing, ", "))
+	}
+
+	for _, k := range keys {
+		delete(vars.Variables, k)
+	}
+
+	return nil
+}
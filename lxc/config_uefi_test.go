@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+const testUEFIVarKey = "Timeout-8be4df61-93ca-11d2-aa0d-00e098032b8c"
+
+func TestUEFIVarsSet(t *testing.T) {
+	vars := &api.InstanceUEFIVars{}
+
+	// Missing variables are created with the default attributes.
+	uefiVarsSet(vars, map[string]string{testUEFIVarKey: "0500"})
+	assert.Equal(t, api.InstanceUEFIVariable{Data: "0500", Attr: uefiVarDefaultAttr}, vars.Variables[testUEFIVarKey])
+
+	// Existing variables only have their data changed.
+	vars.Variables[testUEFIVarKey] = api.InstanceUEFIVariable{Data: "0500", Attr: 3, Digest: "00"}
+	uefiVarsSet(vars, map[string]string{testUEFIVarKey: "0a00"})
+	assert.Equal(t, api.InstanceUEFIVariable{Data: "0a00", Attr: 3, Digest: "00"}, vars.Variables[testUEFIVarKey])
+}
+
+func TestUEFIVarsUnset(t *testing.T) {
+	vars := &api.InstanceUEFIVars{Variables: map[string]api.InstanceUEFIVariable{
+		testUEFIVarKey: {Data: "0500", Attr: uefiVarDefaultAttr},
+	}}
+
+	// Nothing is removed if any of the variables is missing.
+	err := uefiVarsUnset(vars, []string{testUEFIVarKey, "b", "a"})
+	assert.EqualError(t, err, "UEFI variables not found: a, b")
+	assert.Contains(t, vars.Variables, testUEFIVarKey)
+
+	err = uefiVarsUnset(vars, []string{testUEFIVarKey})
+	assert.NoError(t, err)
+	assert.Empty(t, vars.Variables)
+}
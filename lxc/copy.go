@@ -260,7 +260,7 @@ func (c *cmdCopy) copyInstance(conf *config.Config, sourceResource string, destR
 
 		// Only start the instance back up if doing a stateless migration.
 		// Its LXD's job to start things back up when receiving a stateful migration.
-		if entry.StatusCode == api.Running && move && !stateful {
+		if (entry.StatusCode == api.Running || entry.StatusCode == api.Ready) && move && !stateful {
 			start = true
 		}
 
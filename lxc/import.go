@@ -19,21 +19,26 @@ import (
 type cmdImport struct {
 	global *cmdGlobal
 
-	flagStorage string
+	flagStorage    string
+	flagFromTarget bool
 }
 
 func (c *cmdImport) Command() *cobra.Command {
 	cmd := &cobra.Command{}
-	cmd.Use = usage("import", i18n.G("[<remote>:] <backup file> [<instance name>]"))
+	cmd.Use = usage("import", i18n.G("[<remote>:] <backup file>|<instance>/<backup> [<instance name>]"))
 	cmd.Short = i18n.G("Import instance backups")
 	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
 		`Import backups of instances including their snapshots.`))
 	cmd.Example = cli.FormatSection("", i18n.G(
 		`lxc import backup0.tar.gz
-    Create a new instance using backup0.tar.gz as the source.`))
+    Create a new instance using backup0.tar.gz as the source.
+
+lxc import c1/backup0 c2 --from-target
+    Create a new instance c2 from backup "backup0" of instance c1 held on the server's backups target.`))
 
 	cmd.RunE = c.Run
 	cmd.Flags().StringVarP(&c.flagStorage, "storage", "s", "", i18n.G("Storage pool name")+"``")
+	cmd.Flags().BoolVar(&c.flagFromTarget, "from-target", false, i18n.G("Restore a backup held on the server's backups target (<instance>/<backup>)"))
 
 	return cmd
 }
@@ -73,6 +78,33 @@ func (c *cmdImport) Run(cmd *cobra.Command, args []string) error {
 
 	resource := resources[0]
 
+	// Restore directly from the server's backups target without transferring any data from the client.
+	if c.flagFromTarget {
+		progress := utils.ProgressRenderer{
+			Format: i18n.G("Importing instance: %s"),
+			Quiet:  c.global.flagQuiet,
+		}
+
+		op, err := resource.server.CreateInstanceFromBackup(lxd.InstanceBackupArgs{
+			BackupName: srcFile,
+			PoolName:   c.flagStorage,
+			Name:       instanceName,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = utils.CancelableWait(op, &progress)
+		if err != nil {
+			progress.Done("")
+			return err
+		}
+
+		progress.Done("")
+
+		return nil
+	}
+
 	var file *os.File
 	if srcFile == "-" {
 		file = os.Stdin
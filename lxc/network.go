@@ -3,8 +3,10 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -73,6 +75,10 @@ func (c *cmdNetwork) Command() *cobra.Command {
 	networkListCmd := cmdNetworkList{global: c.global, network: c}
 	cmd.AddCommand(networkListCmd.Command())
 
+	// List allocations
+	networkListAllocationsCmd := cmdNetworkListAllocations{global: c.global, network: c}
+	cmd.AddCommand(networkListAllocationsCmd.Command())
+
 	// List leases
 	networkListLeasesCmd := cmdNetworkListLeases{global: c.global, network: c}
 	cmd.AddCommand(networkListLeasesCmd.Command())
@@ -101,6 +107,10 @@ func (c *cmdNetwork) Command() *cobra.Command {
 	networkForwardCmd := cmdNetworkForward{global: c.global}
 	cmd.AddCommand(networkForwardCmd.Command())
 
+	// Load Balancer
+	networkLoadBalancerCmd := cmdNetworkLoadBalancer{global: c.global}
+	cmd.AddCommand(networkLoadBalancerCmd.Command())
+
 	// Peer
 	networkPeerCmd := cmdNetworkPeer{global: c.global}
 	cmd.AddCommand(networkPeerCmd.Command())
@@ -921,6 +931,129 @@ func (c *cmdNetworkList) Run(cmd *cobra.Command, args []string) error {
 	return utils.RenderTable(c.flagFormat, header, data, networks)
 }
 
+// List allocations
+type cmdNetworkListAllocations struct {
+	global  *cmdGlobal
+	network *cmdNetwork
+
+	flagFormat      string
+	flagAllProjects bool
+	flagAddress     string
+}
+
+func (c *cmdNetworkListAllocations) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("list-allocations", i18n.G("[<remote>:]"))
+	cmd.Short = i18n.G("List network allocations in use")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`List network allocations in use
+
+The --address flag only shows the allocations overlapping with the given IP address or subnet (CIDR).`))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc network list-allocations --address 10.0.0.0/24
+    Show the allocations conflicting with the 10.0.0.0/24 subnet.`))
+	cmd.Flags().StringVarP(&c.flagFormat, "format", "f", "table", i18n.G("Format (csv|json|table|yaml)")+"``")
+	cmd.Flags().BoolVar(&c.flagAllProjects, "all-projects", false, i18n.G("Display allocations from all projects"))
+	cmd.Flags().StringVar(&c.flagAddress, "address", "", i18n.G("Only show allocations overlapping with an IP address or subnet")+"``")
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdNetworkListAllocations) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 0, 1)
+	if exit {
+		return err
+	}
+
+	if c.global.flagProject != "" && c.flagAllProjects {
+		return fmt.Errorf(i18n.G("Can't specify --project with --all-projects"))
+	}
+
+	// Parse the address filter.
+	var filter *net.IPNet
+	if c.flagAddress != "" {
+		filter, err = parseAllocationAddress(c.flagAddress)
+		if err != nil {
+			return fmt.Errorf(i18n.G("Invalid address %q"), c.flagAddress)
+		}
+	}
+
+	// Parse remote
+	remote := ""
+	if len(args) > 0 {
+		remote = args[0]
+	}
+
+	resources, err := c.global.ParseServers(remote)
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	// List allocations.
+	var allocations []api.NetworkAllocation
+	if c.flagAllProjects {
+		allocations, err = resource.server.GetNetworkAllocationsAllProjects()
+	} else {
+		allocations, err = resource.server.GetNetworkAllocations()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	filtered := []api.NetworkAllocation{}
+	data := [][]string{}
+	for _, allocation := range allocations {
+		if filter != nil {
+			subnet, err := parseAllocationAddress(allocation.Address)
+			if err != nil || !(filter.Contains(subnet.IP) || subnet.Contains(filter.IP)) {
+				continue
+			}
+		}
+
+		filtered = append(filtered, allocation)
+		data = append(data, []string{allocation.UsedBy, allocation.Address, allocation.Type, allocation.Network, strconv.FormatBool(allocation.NAT), allocation.Hwaddr})
+	}
+
+	sort.Sort(byName(data))
+
+	header := []string{
+		i18n.G("USED BY"),
+		i18n.G("ADDRESS"),
+		i18n.G("TYPE"),
+		i18n.G("NETWORK"),
+		i18n.G("NAT"),
+		i18n.G("MAC ADDRESS"),
+	}
+
+	return utils.RenderTable(c.flagFormat, header, data, filtered)
+}
+
+// parseAllocationAddress parses an IP address or subnet, returning a single host subnet for IP addresses.
+func parseAllocationAddress(address string) (*net.IPNet, error) {
+	ip := net.ParseIP(address)
+	if ip != nil {
+		bits := 128
+		if ip.To4() != nil {
+			bits = 32
+		}
+
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, subnet, err := net.ParseCIDR(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return subnet, nil
+}
+
 // List leases
 type cmdNetworkListLeases struct {
 	global  *cmdGlobal
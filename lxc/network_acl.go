@@ -37,6 +37,10 @@ func (c *cmdNetworkACL) Command() *cobra.Command {
 	networkACLShowCmd := cmdNetworkACLShow{global: c.global, networkACL: c}
 	cmd.AddCommand(networkACLShowCmd.Command())
 
+	// Show log.
+	networkACLShowLogCmd := cmdNetworkACLShowLog{global: c.global, networkACL: c}
+	cmd.AddCommand(networkACLShowLogCmd.Command())
+
 	// Get.
 	networkACLGetCmd := cmdNetworkACLGet{global: c.global, networkACL: c}
 	cmd.AddCommand(networkACLGetCmd.Command())
@@ -201,6 +205,92 @@ func (c *cmdNetworkACLShow) Run(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// Show log.
+type cmdNetworkACLShowLog struct {
+	global     *cmdGlobal
+	networkACL *cmdNetworkACL
+
+	flagFormat string
+}
+
+func (c *cmdNetworkACLShowLog) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("show-log", i18n.G("[<remote>:]<ACL>"))
+	cmd.Short = i18n.G("Show network ACL log")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Show the traffic matched by the logged rules of a network ACL"))
+	cmd.Flags().StringVarP(&c.flagFormat, "format", "f", "table", i18n.G("Format (csv|json|table|yaml)")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdNetworkACLShowLog) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 1, 1)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing network ACL name"))
+	}
+
+	// Get the network ACL log.
+	entries, err := resource.server.GetNetworkACLLog(resource.name)
+	if err != nil {
+		return err
+	}
+
+	const layout = "2006/01/02 15:04:05 MST"
+
+	data := [][]string{}
+	for _, entry := range entries {
+		source := entry.Source
+		if entry.SourcePort != "" {
+			source = fmt.Sprintf("%s:%s", source, entry.SourcePort)
+		}
+
+		destination := entry.Destination
+		if entry.DestinationPort != "" {
+			destination = fmt.Sprintf("%s:%s", destination, entry.DestinationPort)
+		}
+
+		details := []string{
+			entry.Timestamp.Local().Format(layout),
+			entry.Location,
+			entry.Direction,
+			fmt.Sprintf("%d", entry.Rule),
+			entry.Action,
+			entry.Protocol,
+			source,
+			destination,
+		}
+
+		data = append(data, details)
+	}
+
+	header := []string{
+		i18n.G("TIMESTAMP"),
+		i18n.G("LOCATION"),
+		i18n.G("DIRECTION"),
+		i18n.G("RULE"),
+		i18n.G("ACTION"),
+		i18n.G("PROTOCOL"),
+		i18n.G("SOURCE"),
+		i18n.G("DESTINATION"),
+	}
+
+	return utils.RenderTable(c.flagFormat, header, data, entries)
+}
+
 // Get.
 type cmdNetworkACLGet struct {
 	global     *cmdGlobal
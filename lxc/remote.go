@@ -98,7 +98,7 @@ Basic authentication can be used when combined with the "simplestreams" protocol
 	cmd.Flags().BoolVar(&c.flagAcceptCert, "accept-certificate", false, i18n.G("Accept certificate"))
 	cmd.Flags().StringVar(&c.flagPassword, "password", "", i18n.G("Remote admin password")+"``")
 	cmd.Flags().StringVar(&c.flagProtocol, "protocol", "", i18n.G("Server protocol (lxd or simplestreams)")+"``")
-	cmd.Flags().StringVar(&c.flagAuthType, "auth-type", "", i18n.G("Server authentication type (tls or candid)")+"``")
+	cmd.Flags().StringVar(&c.flagAuthType, "auth-type", "", i18n.G("Server authentication type (tls, candid or oidc)")+"``")
 	cmd.Flags().BoolVar(&c.flagPublic, "public", false, i18n.G("Public image server"))
 	cmd.Flags().StringVar(&c.flagDomain, "domain", "", i18n.G("Candid domain to use")+"``")
 	cmd.Flags().StringVar(&c.flagProject, "project", "", i18n.G("Project to use for the remote")+"``")
@@ -659,6 +659,15 @@ func (c *cmdRemoteRename) Run(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Rename the OIDC tokens file
+	oldPath = conf.OIDCTokenPath(args[0])
+	if shared.PathExists(oldPath) {
+		err := os.Rename(oldPath, conf.OIDCTokenPath(args[1]))
+		if err != nil {
+			return err
+		}
+	}
+
 	conf.Remotes[args[1]] = rc
 	delete(conf.Remotes, args[0])
 
@@ -719,6 +728,7 @@ func (c *cmdRemoteRemove) Run(cmd *cobra.Command, args []string) error {
 
 	os.Remove(conf.ServerCertPath(args[0]))
 	os.Remove(conf.CookiesPath(args[0]))
+	os.Remove(conf.OIDCTokenPath(args[0]))
 
 	return conf.SaveConfig(c.global.confPath)
 }
@@ -0,0 +1,1112 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/lxc/utils"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	cli "github.com/lxc/lxd/shared/cmd"
+	"github.com/lxc/lxd/shared/i18n"
+	"github.com/lxc/lxd/shared/termios"
+)
+
+type cmdStorageBucket struct {
+	global  *cmdGlobal
+	storage *cmdStorage
+}
+
+func (c *cmdStorageBucket) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("bucket")
+	cmd.Short = i18n.G("Manage storage buckets")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Manage storage buckets"))
+
+	// Create.
+	storageBucketCreateCmd := cmdStorageBucketCreate{global: c.global, storageBucket: c}
+	cmd.AddCommand(storageBucketCreateCmd.Command())
+
+	// Delete.
+	storageBucketDeleteCmd := cmdStorageBucketDelete{global: c.global, storageBucket: c}
+	cmd.AddCommand(storageBucketDeleteCmd.Command())
+
+	// Edit.
+	storageBucketEditCmd := cmdStorageBucketEdit{global: c.global, storageBucket: c}
+	cmd.AddCommand(storageBucketEditCmd.Command())
+
+	// Get.
+	storageBucketGetCmd := cmdStorageBucketGet{global: c.global, storageBucket: c}
+	cmd.AddCommand(storageBucketGetCmd.Command())
+
+	// List.
+	storageBucketListCmd := cmdStorageBucketList{global: c.global, storageBucket: c}
+	cmd.AddCommand(storageBucketListCmd.Command())
+
+	// Set.
+	storageBucketSetCmd := cmdStorageBucketSet{global: c.global, storageBucket: c}
+	cmd.AddCommand(storageBucketSetCmd.Command())
+
+	// Show.
+	storageBucketShowCmd := cmdStorageBucketShow{global: c.global, storageBucket: c}
+	cmd.AddCommand(storageBucketShowCmd.Command())
+
+	// Unset.
+	storageBucketUnsetCmd := cmdStorageBucketUnset{global: c.global, storageBucket: c, storageBucketSet: &storageBucketSetCmd}
+	cmd.AddCommand(storageBucketUnsetCmd.Command())
+
+	// Key.
+	storageBucketKeyCmd := cmdStorageBucketKey{global: c.global, storageBucket: c}
+	cmd.AddCommand(storageBucketKeyCmd.Command())
+
+	// Workaround for subcommand usage errors. See: https://github.com/spf13/cobra/issues/706
+	cmd.Args = cobra.NoArgs
+	cmd.Run = func(cmd *cobra.Command, args []string) { cmd.Usage() }
+	return cmd
+}
+
+// Create.
+type cmdStorageBucketCreate struct {
+	global        *cmdGlobal
+	storageBucket *cmdStorageBucket
+}
+
+func (c *cmdStorageBucketCreate) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("create", i18n.G("[<remote>:]<pool> <bucket> [key=value...]"))
+	cmd.Short = i18n.G("Create new custom storage buckets")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Create new custom storage buckets"))
+	cmd.Example = cli.FormatSection("", i18n.G(`lxc storage bucket create p1 b01
+	Create a new storage bucket name b01 in storage pool p1
+
+lxc storage bucket create p1 b01 size=10GiB
+	Create a new storage bucket name b01 in storage pool p1 with a 10GiB quota`))
+
+	cmd.Flags().StringVar(&c.storageBucket.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdStorageBucketCreate) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 2, -1)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing pool name"))
+	}
+
+	// If stdin isn't a terminal, read yaml from it.
+	var bucketPut api.StorageBucketPut
+	if !termios.IsTerminal(getStdinFd()) {
+		contents, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		err = yaml.UnmarshalStrict(contents, &bucketPut)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create the storage bucket.
+	bucket := api.StorageBucketsPost{
+		Name:             args[1],
+		StorageBucketPut: bucketPut,
+	}
+
+	if bucket.Config == nil {
+		bucket.Config = map[string]string{}
+	}
+
+	for i := 2; i < len(args); i++ {
+		entry := strings.SplitN(args[i], "=", 2)
+		if len(entry) < 2 {
+			return fmt.Errorf(i18n.G("Bad key/value pair: %s"), args[i])
+		}
+
+		bucket.Config[entry[0]] = entry[1]
+	}
+
+	client := resource.server
+
+	// If a target was specified, create the bucket on the given member.
+	if c.storageBucket.storage.flagTarget != "" {
+		client = client.UseTarget(c.storageBucket.storage.flagTarget)
+	}
+
+	err = client.CreateStoragePoolBucket(resource.name, bucket)
+	if err != nil {
+		return err
+	}
+
+	if !c.global.flagQuiet {
+		fmt.Printf(i18n.G("Storage bucket %s created")+"\n", args[1])
+	}
+
+	return nil
+}
+
+// Delete.
+type cmdStorageBucketDelete struct {
+	global        *cmdGlobal
+	storageBucket *cmdStorageBucket
+}
+
+func (c *cmdStorageBucketDelete) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("delete", i18n.G("[<remote>:]<pool> <bucket>"))
+	cmd.Aliases = []string{"rm"}
+	cmd.Short = i18n.G("Delete storage buckets")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Delete storage buckets"))
+
+	cmd.Flags().StringVar(&c.storageBucket.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdStorageBucketDelete) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing pool name"))
+	}
+
+	client := resource.server
+
+	// If a target was specified, delete the bucket on the given member.
+	if c.storageBucket.storage.flagTarget != "" {
+		client = client.UseTarget(c.storageBucket.storage.flagTarget)
+	}
+
+	// Delete the bucket.
+	err = client.DeleteStoragePoolBucket(resource.name, args[1])
+	if err != nil {
+		return err
+	}
+
+	if !c.global.flagQuiet {
+		fmt.Printf(i18n.G("Storage bucket %s deleted")+"\n", args[1])
+	}
+
+	return nil
+}
+
+// Edit.
+type cmdStorageBucketEdit struct {
+	global        *cmdGlobal
+	storageBucket *cmdStorageBucket
+}
+
+func (c *cmdStorageBucketEdit) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("edit", i18n.G("[<remote>:]<pool> <bucket>"))
+	cmd.Short = i18n.G("Edit storage bucket configurations as YAML")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Edit storage bucket configurations as YAML"))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc storage bucket edit [<remote>:]<pool> <bucket> < bucket.yaml
+    Update a storage bucket using the content of bucket.yaml.`))
+
+	cmd.Flags().StringVar(&c.storageBucket.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdStorageBucketEdit) helpTemplate() string {
+	return i18n.G(
+		`### This is a YAML representation of the storage bucket.
+### Any line starting with a '# will be ignored.
+###
+### A storage bucket consists of a set of configuration items.
+###
+### name: bucket1
+### description: My custom bucket
+### config:
+###   size: 5GiB
+### s3_url: https://127.0.0.1:9000/default_bucket1`)
+}
+
+func (c *cmdStorageBucketEdit) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing pool name"))
+	}
+
+	bucketName := args[1]
+	client := resource.server
+
+	// If a target was specified, edit the bucket on the given member.
+	if c.storageBucket.storage.flagTarget != "" {
+		client = client.UseTarget(c.storageBucket.storage.flagTarget)
+	}
+
+	// If stdin isn't a terminal, read text from it
+	if !termios.IsTerminal(getStdinFd()) {
+		contents, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		// Allow output of `lxc storage bucket show` command to passed in here, but only take the contents
+		// of the StorageBucketPut fields when updating. The other fields are silently discarded.
+		newdata := api.StorageBucket{}
+		err = yaml.UnmarshalStrict(contents, &newdata)
+		if err != nil {
+			return err
+		}
+
+		return client.UpdateStoragePoolBucket(resource.name, bucketName, newdata.StorageBucketPut, "")
+	}
+
+	// Get the current config.
+	bucket, etag, err := client.GetStoragePoolBucket(resource.name, bucketName)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&bucket)
+	if err != nil {
+		return err
+	}
+
+	// Spawn the editor.
+	content, err := shared.TextEditor("", []byte(c.helpTemplate()+"\n\n"+string(data)))
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Parse the text received from the editor.
+		newdata := api.StorageBucket{} // We show the full info, but only send the writable fields.
+		err = yaml.UnmarshalStrict(content, &newdata)
+		if err == nil {
+			err = client.UpdateStoragePoolBucket(resource.name, bucketName, newdata.Writable(), etag)
+		}
+
+		// Respawn the editor.
+		if err != nil {
+			fmt.Fprintf(os.Stderr, i18n.G("Config parsing error: %s")+"\n", err)
+			fmt.Println(i18n.G("Press enter to open the editor again or ctrl+c to abort change"))
+
+			_, err := os.Stdin.Read(make([]byte, 1))
+			if err != nil {
+				return err
+			}
+
+			content, err = shared.TextEditor("", content)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		break
+	}
+
+	return nil
+}
+
+// Get.
+type cmdStorageBucketGet struct {
+	global        *cmdGlobal
+	storageBucket *cmdStorageBucket
+}
+
+func (c *cmdStorageBucketGet) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("get", i18n.G("[<remote>:]<pool> <bucket> <key>"))
+	cmd.Short = i18n.G("Get values for storage bucket configuration keys")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Get values for storage bucket configuration keys"))
+
+	cmd.Flags().StringVar(&c.storageBucket.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdStorageBucketGet) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 3, 3)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing pool name"))
+	}
+
+	client := resource.server
+
+	// If a target was specified, use the bucket on the given member.
+	if c.storageBucket.storage.flagTarget != "" {
+		client = client.UseTarget(c.storageBucket.storage.flagTarget)
+	}
+
+	bucket, _, err := client.GetStoragePoolBucket(resource.name, args[1])
+	if err != nil {
+		return err
+	}
+
+	for k, v := range bucket.Config {
+		if k == args[2] {
+			fmt.Printf("%s\n", v)
+		}
+	}
+
+	return nil
+}
+
+// List.
+type cmdStorageBucketList struct {
+	global        *cmdGlobal
+	storageBucket *cmdStorageBucket
+
+	flagFormat string
+}
+
+func (c *cmdStorageBucketList) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("list", i18n.G("[<remote>:]<pool>"))
+	cmd.Aliases = []string{"ls"}
+	cmd.Short = i18n.G("List storage buckets")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("List storage buckets"))
+
+	cmd.Flags().StringVarP(&c.flagFormat, "format", "f", "table", i18n.G("Format (csv|json|table|yaml)")+"``")
+	cmd.Flags().StringVar(&c.storageBucket.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdStorageBucketList) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 1, 1)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing pool name"))
+	}
+
+	client := resource.server
+
+	// If a target was specified, only list the buckets on the given member.
+	if c.storageBucket.storage.flagTarget != "" {
+		client = client.UseTarget(c.storageBucket.storage.flagTarget)
+	}
+
+	buckets, err := client.GetStoragePoolBuckets(resource.name)
+	if err != nil {
+		return err
+	}
+
+	clustered := resource.server.IsClustered()
+
+	data := [][]string{}
+	for _, bucket := range buckets {
+		details := []string{
+			bucket.Name,
+			bucket.Description,
+		}
+
+		if clustered {
+			details = append(details, bucket.Location)
+		}
+
+		data = append(data, details)
+	}
+	sort.Sort(byName(data))
+
+	header := []string{
+		i18n.G("NAME"),
+		i18n.G("DESCRIPTION"),
+	}
+
+	if clustered {
+		header = append(header, i18n.G("LOCATION"))
+	}
+
+	return utils.RenderTable(c.flagFormat, header, data, buckets)
+}
+
+// Set.
+type cmdStorageBucketSet struct {
+	global        *cmdGlobal
+	storageBucket *cmdStorageBucket
+}
+
+func (c *cmdStorageBucketSet) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("set", i18n.G("[<remote>:]<pool> <bucket> <key>=<value>..."))
+	cmd.Short = i18n.G("Set storage bucket configuration keys")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Set storage bucket configuration keys"))
+
+	cmd.Flags().StringVar(&c.storageBucket.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdStorageBucketSet) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 3, -1)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing pool name"))
+	}
+
+	bucketName := args[1]
+	client := resource.server
+
+	// If a target was specified, use the bucket on the given member.
+	if c.storageBucket.storage.flagTarget != "" {
+		client = client.UseTarget(c.storageBucket.storage.flagTarget)
+	}
+
+	// Get the current config.
+	bucket, etag, err := client.GetStoragePoolBucket(resource.name, bucketName)
+	if err != nil {
+		return err
+	}
+
+	// Set the keys.
+	keys, err := getConfig(args[2:]...)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range keys {
+		bucket.Config[k] = v
+	}
+
+	return client.UpdateStoragePoolBucket(resource.name, bucketName, bucket.Writable(), etag)
+}
+
+// Show.
+type cmdStorageBucketShow struct {
+	global        *cmdGlobal
+	storageBucket *cmdStorageBucket
+}
+
+func (c *cmdStorageBucketShow) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("show", i18n.G("[<remote>:]<pool> <bucket>"))
+	cmd.Short = i18n.G("Show storage bucket configurations")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Show storage bucket configurations"))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc storage bucket show default data
+    Will show the properties of a bucket called "data" in the "default" pool.`))
+
+	cmd.Flags().StringVar(&c.storageBucket.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdStorageBucketShow) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing pool name"))
+	}
+
+	client := resource.server
+
+	// If a target was specified, use the bucket on the given member.
+	if c.storageBucket.storage.flagTarget != "" {
+		client = client.UseTarget(c.storageBucket.storage.flagTarget)
+	}
+
+	bucket, _, err := client.GetStoragePoolBucket(resource.name, args[1])
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&bucket)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", data)
+
+	return nil
+}
+
+// Unset.
+type cmdStorageBucketUnset struct {
+	global           *cmdGlobal
+	storageBucket    *cmdStorageBucket
+	storageBucketSet *cmdStorageBucketSet
+}
+
+func (c *cmdStorageBucketUnset) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("unset", i18n.G("[<remote>:]<pool> <bucket> <key>"))
+	cmd.Short = i18n.G("Unset storage bucket configuration keys")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Unset storage bucket configuration keys"))
+
+	cmd.Flags().StringVar(&c.storageBucket.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdStorageBucketUnset) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 3, 3)
+	if exit {
+		return err
+	}
+
+	args = append(args, "")
+	return c.storageBucketSet.Run(cmd, args)
+}
+
+// Key commands.
+type cmdStorageBucketKey struct {
+	global        *cmdGlobal
+	storageBucket *cmdStorageBucket
+}
+
+func (c *cmdStorageBucketKey) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("key")
+	cmd.Short = i18n.G("Manage storage bucket keys")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Manage storage bucket keys"))
+
+	// Create.
+	storageBucketKeyCreateCmd := cmdStorageBucketKeyCreate{global: c.global, storageBucketKey: c}
+	cmd.AddCommand(storageBucketKeyCreateCmd.Command())
+
+	// Delete.
+	storageBucketKeyDeleteCmd := cmdStorageBucketKeyDelete{global: c.global, storageBucketKey: c}
+	cmd.AddCommand(storageBucketKeyDeleteCmd.Command())
+
+	// Edit.
+	storageBucketKeyEditCmd := cmdStorageBucketKeyEdit{global: c.global, storageBucketKey: c}
+	cmd.AddCommand(storageBucketKeyEditCmd.Command())
+
+	// List.
+	storageBucketKeyListCmd := cmdStorageBucketKeyList{global: c.global, storageBucketKey: c}
+	cmd.AddCommand(storageBucketKeyListCmd.Command())
+
+	// Show.
+	storageBucketKeyShowCmd := cmdStorageBucketKeyShow{global: c.global, storageBucketKey: c}
+	cmd.AddCommand(storageBucketKeyShowCmd.Command())
+
+	// Workaround for subcommand usage errors. See: https://github.com/spf13/cobra/issues/706
+	cmd.Args = cobra.NoArgs
+	cmd.Run = func(cmd *cobra.Command, args []string) { cmd.Usage() }
+	return cmd
+}
+
+// List Keys.
+type cmdStorageBucketKeyList struct {
+	global           *cmdGlobal
+	storageBucketKey *cmdStorageBucketKey
+
+	flagFormat string
+}
+
+func (c *cmdStorageBucketKeyList) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("list", i18n.G("[<remote>:]<pool> <bucket>"))
+	cmd.Aliases = []string{"ls"}
+	cmd.Short = i18n.G("List storage bucket keys")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("List storage bucket keys"))
+
+	cmd.Flags().StringVarP(&c.flagFormat, "format", "f", "table", i18n.G("Format (csv|json|table|yaml)")+"``")
+	cmd.Flags().StringVar(&c.storageBucketKey.storageBucket.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdStorageBucketKeyList) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing pool name"))
+	}
+
+	client := resource.server
+
+	// If a target was specified, use the bucket on the given member.
+	if c.storageBucketKey.storageBucket.storage.flagTarget != "" {
+		client = client.UseTarget(c.storageBucketKey.storageBucket.storage.flagTarget)
+	}
+
+	bucketKeys, err := client.GetStoragePoolBucketKeys(resource.name, args[1])
+	if err != nil {
+		return err
+	}
+
+	data := [][]string{}
+	for _, bucketKey := range bucketKeys {
+		details := []string{
+			bucketKey.Name,
+			bucketKey.Description,
+			bucketKey.Role,
+		}
+
+		data = append(data, details)
+	}
+	sort.Sort(byName(data))
+
+	header := []string{
+		i18n.G("NAME"),
+		i18n.G("DESCRIPTION"),
+		i18n.G("ROLE"),
+	}
+
+	return utils.RenderTable(c.flagFormat, header, data, bucketKeys)
+}
+
+// Create Key.
+type cmdStorageBucketKeyCreate struct {
+	global           *cmdGlobal
+	storageBucketKey *cmdStorageBucketKey
+
+	flagRole      string
+	flagAccessKey string
+	flagSecretKey string
+}
+
+func (c *cmdStorageBucketKeyCreate) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("create", i18n.G("[<remote>:]<pool> <bucket> <key>"))
+	cmd.Short = i18n.G("Create key for a storage bucket")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Create key for a storage bucket"))
+	cmd.Example = cli.FormatSection("", i18n.G(`lxc storage bucket key create p1 b01 k1
+	Create a key called k1 for the bucket b01 in the pool p1.
+
+lxc storage bucket key create p1 b01 k1 --role=admin
+	Create an admin key called k1 for the bucket b01 in the pool p1.`))
+
+	cmd.Flags().StringVar(&c.storageBucketKey.storageBucket.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.Flags().StringVar(&c.flagRole, "role", "read-only", i18n.G("Role (admin or read-only)")+"``")
+	cmd.Flags().StringVar(&c.flagAccessKey, "access-key", "", i18n.G("Access key (auto-generated if empty)")+"``")
+	cmd.Flags().StringVar(&c.flagSecretKey, "secret-key", "", i18n.G("Secret key (auto-generated if empty)")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdStorageBucketKeyCreate) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 3, 3)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing pool name"))
+	}
+
+	client := resource.server
+
+	// If a target was specified, use the bucket on the given member.
+	if c.storageBucketKey.storageBucket.storage.flagTarget != "" {
+		client = client.UseTarget(c.storageBucketKey.storageBucket.storage.flagTarget)
+	}
+
+	// If stdin isn't a terminal, read yaml from it.
+	var bucketKeyPut api.StorageBucketKeyPut
+	if !termios.IsTerminal(getStdinFd()) {
+		contents, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		err = yaml.UnmarshalStrict(contents, &bucketKeyPut)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.flagRole != "" {
+		bucketKeyPut.Role = c.flagRole
+	}
+
+	if c.flagAccessKey != "" {
+		bucketKeyPut.AccessKey = c.flagAccessKey
+	}
+
+	if c.flagSecretKey != "" {
+		bucketKeyPut.SecretKey = c.flagSecretKey
+	}
+
+	// Create the storage bucket key.
+	req := api.StorageBucketKeysPost{
+		Name:                args[2],
+		StorageBucketKeyPut: bucketKeyPut,
+	}
+
+	key, err := client.CreateStoragePoolBucketKey(resource.name, args[1], req)
+	if err != nil {
+		return err
+	}
+
+	if !c.global.flagQuiet {
+		fmt.Printf(i18n.G("Storage bucket key %s added")+"\n", key.Name)
+		fmt.Printf(i18n.G("Access key: %s")+"\n", key.AccessKey)
+		fmt.Printf(i18n.G("Secret key: %s")+"\n", key.SecretKey)
+	}
+
+	return nil
+}
+
+// Delete Key.
+type cmdStorageBucketKeyDelete struct {
+	global           *cmdGlobal
+	storageBucketKey *cmdStorageBucketKey
+}
+
+func (c *cmdStorageBucketKeyDelete) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("delete", i18n.G("[<remote>:]<pool> <bucket> <key>"))
+	cmd.Aliases = []string{"rm"}
+	cmd.Short = i18n.G("Delete key from a storage bucket")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Delete key from a storage bucket"))
+
+	cmd.Flags().StringVar(&c.storageBucketKey.storageBucket.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdStorageBucketKeyDelete) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 3, 3)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing pool name"))
+	}
+
+	client := resource.server
+
+	// If a target was specified, use the bucket on the given member.
+	if c.storageBucketKey.storageBucket.storage.flagTarget != "" {
+		client = client.UseTarget(c.storageBucketKey.storageBucket.storage.flagTarget)
+	}
+
+	err = client.DeleteStoragePoolBucketKey(resource.name, args[1], args[2])
+	if err != nil {
+		return err
+	}
+
+	if !c.global.flagQuiet {
+		fmt.Printf(i18n.G("Storage bucket key %s removed")+"\n", args[2])
+	}
+
+	return nil
+}
+
+// Edit Key.
+type cmdStorageBucketKeyEdit struct {
+	global           *cmdGlobal
+	storageBucketKey *cmdStorageBucketKey
+}
+
+func (c *cmdStorageBucketKeyEdit) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("edit", i18n.G("[<remote>:]<pool> <bucket> <key>"))
+	cmd.Short = i18n.G("Edit storage bucket key as YAML")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Edit storage bucket key as YAML"))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc storage bucket key edit [<remote>:]<pool> <bucket> <key> < key.yaml
+    Update a storage bucket key using the content of key.yaml.`))
+
+	cmd.Flags().StringVar(&c.storageBucketKey.storageBucket.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdStorageBucketKeyEdit) helpTemplate() string {
+	return i18n.G(
+		`### This is a YAML representation of the storage bucket key.
+### Any line starting with a '# will be ignored.
+###
+### A storage bucket key consists of a role, an access key and a secret key.
+###
+### name: key1
+### description: My custom key
+### role: admin
+### access-key: 5eb2aa5f2052b1f62ba9e5a3b3a65de9
+### secret-key: 0ba61ff49c4ff2d9f6aa53b9b07e74c3`)
+}
+
+func (c *cmdStorageBucketKeyEdit) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 3, 3)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing pool name"))
+	}
+
+	bucketName := args[1]
+	keyName := args[2]
+	client := resource.server
+
+	// If a target was specified, use the bucket on the given member.
+	if c.storageBucketKey.storageBucket.storage.flagTarget != "" {
+		client = client.UseTarget(c.storageBucketKey.storageBucket.storage.flagTarget)
+	}
+
+	// If stdin isn't a terminal, read text from it
+	if !termios.IsTerminal(getStdinFd()) {
+		contents, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		// Allow output of `lxc storage bucket key show` command to passed in here, but only take the
+		// contents of the StorageBucketKeyPut fields when updating. The other fields are silently discarded.
+		newdata := api.StorageBucketKey{}
+		err = yaml.UnmarshalStrict(contents, &newdata)
+		if err != nil {
+			return err
+		}
+
+		return client.UpdateStoragePoolBucketKey(resource.name, bucketName, keyName, newdata.StorageBucketKeyPut, "")
+	}
+
+	// Get the current config.
+	bucketKey, etag, err := client.GetStoragePoolBucketKey(resource.name, bucketName, keyName)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&bucketKey)
+	if err != nil {
+		return err
+	}
+
+	// Spawn the editor.
+	content, err := shared.TextEditor("", []byte(c.helpTemplate()+"\n\n"+string(data)))
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Parse the text received from the editor.
+		newdata := api.StorageBucketKey{} // We show the full info, but only send the writable fields.
+		err = yaml.UnmarshalStrict(content, &newdata)
+		if err == nil {
+			err = client.UpdateStoragePoolBucketKey(resource.name, bucketName, keyName, newdata.Writable(), etag)
+		}
+
+		// Respawn the editor.
+		if err != nil {
+			fmt.Fprintf(os.Stderr, i18n.G("Config parsing error: %s")+"\n", err)
+			fmt.Println(i18n.G("Press enter to open the editor again or ctrl+c to abort change"))
+
+			_, err := os.Stdin.Read(make([]byte, 1))
+			if err != nil {
+				return err
+			}
+
+			content, err = shared.TextEditor("", content)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		break
+	}
+
+	return nil
+}
+
+// Show Key.
+type cmdStorageBucketKeyShow struct {
+	global           *cmdGlobal
+	storageBucketKey *cmdStorageBucketKey
+}
+
+func (c *cmdStorageBucketKeyShow) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("show", i18n.G("[<remote>:]<pool> <bucket> <key>"))
+	cmd.Short = i18n.G("Show storage bucket key configurations")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G("Show storage bucket key configurations"))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc storage bucket key show default data foo
+    Will show the properties of a bucket key called "foo" for a bucket called "data" in the "default" pool.`))
+
+	cmd.Flags().StringVar(&c.storageBucketKey.storageBucket.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdStorageBucketKeyShow) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 3, 3)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing pool name"))
+	}
+
+	client := resource.server
+
+	// If a target was specified, use the bucket on the given member.
+	if c.storageBucketKey.storageBucket.storage.flagTarget != "" {
+		client = client.UseTarget(c.storageBucketKey.storageBucket.storage.flagTarget)
+	}
+
+	bucketKey, _, err := client.GetStoragePoolBucketKey(resource.name, args[1], args[2])
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&bucketKey)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", data)
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -2050,6 +2051,8 @@ type cmdStorageVolumeImport struct {
 	global        *cmdGlobal
 	storage       *cmdStorage
 	storageVolume *cmdStorageVolume
+
+	flagType string
 }
 
 func (c *cmdStorageVolumeImport) Command() *cobra.Command {
@@ -2057,11 +2060,15 @@ func (c *cmdStorageVolumeImport) Command() *cobra.Command {
 	cmd.Use = usage("import", i18n.G("[<remote>:]<pool> <backup file> [<volume name>]"))
 	cmd.Short = i18n.G("Import custom storage volumes")
 	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
-		`Import backups of custom volumes including their snapshots.`))
+		`Import backups of custom volumes including their snapshots or ISO images as custom ISO volumes.`))
 	cmd.Example = cli.FormatSection("", i18n.G(
 		`lxc storage volume import default backup0.tar.gz
-		Create a new custom volume using backup0.tar.gz as the source.`))
+		Create a new custom volume using backup0.tar.gz as the source.
+
+lxc storage volume import default some-installer.iso installer --type=iso
+		Create a new custom ISO volume using some-installer.iso as the source.`))
 	cmd.Flags().StringVar(&c.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.Flags().StringVar(&c.flagType, "type", "", i18n.G("Import type, backup or iso (default \"backup\")")+"``")
 	cmd.RunE = c.Run
 
 	return cmd
@@ -2108,6 +2115,20 @@ func (c *cmdStorageVolumeImport) Run(cmd *cobra.Command, args []string) error {
 		volName = args[2]
 	}
 
+	// Use the file extension to detect ISO images if no type was specified.
+	if c.flagType == "" && strings.HasSuffix(strings.ToLower(args[1]), ".iso") {
+		c.flagType = "iso"
+	}
+
+	if c.flagType != "" && !shared.StringInSlice(c.flagType, []string{"backup", "iso"}) {
+		return fmt.Errorf(i18n.G("Invalid import type %q"), c.flagType)
+	}
+
+	// ISO volumes default to being named after the file.
+	if c.flagType == "iso" && volName == "" {
+		volName = strings.TrimSuffix(filepath.Base(args[1]), filepath.Ext(args[1]))
+	}
+
 	progress := utils.ProgressRenderer{
 		Format: i18n.G("Importing custom volume: %s"),
 		Quiet:  c.global.flagQuiet,
@@ -2126,7 +2147,13 @@ func (c *cmdStorageVolumeImport) Run(cmd *cobra.Command, args []string) error {
 		Name: volName,
 	}
 
-	op, err := d.CreateStoragePoolVolumeFromBackup(pool, createArgs)
+	var op lxd.Operation
+	if c.flagType == "iso" {
+		op, err = d.CreateStoragePoolVolumeFromISO(pool, createArgs)
+	} else {
+		op, err = d.CreateStoragePoolVolumeFromBackup(pool, createArgs)
+	}
+
 	if err != nil {
 		return err
 	}
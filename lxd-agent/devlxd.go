@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,7 +15,9 @@ import (
 	"github.com/lxc/lxd/lxd/daemon"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/lxd/vsock"
 	"github.com/lxc/lxd/shared"
+	log "github.com/lxc/lxd/shared/log15"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/version"
 )
@@ -27,6 +30,9 @@ func devLxdServer(d *Daemon) *http.Server {
 	}
 }
 
+// devlxdHostContextID is the vsock context ID of the host.
+const devlxdHostContextID = 2
+
 type devLxdResponse struct {
 	content interface{}
 	code    int
@@ -78,7 +84,19 @@ var devlxdConfigGet = devLxdHandler{"/1.0/config", func(d *Daemon, w http.Respon
 	return okResponse(filtered, "json")
 }}
 
-var devlxdConfigKeyGet = devLxdHandler{"/1.0/config/{key}", func(d *Daemon, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+var devlxdConfigKeyHandler = devLxdHandler{"/1.0/config/{key}", func(d *Daemon, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+	switch r.Method {
+	case "GET":
+		return devlxdConfigKeyGetHandler(d, w, r)
+	case "PUT", "DELETE":
+		// Config changes have to be made by LXD on the host.
+		return devlxdHostRelay(r)
+	}
+
+	return &devLxdResponse{fmt.Sprintf("method %q not allowed", r.Method), http.StatusBadRequest, "raw"}
+}}
+
+func devlxdConfigKeyGetHandler(d *Daemon, w http.ResponseWriter, r *http.Request) *devLxdResponse {
 	key := mux.Vars(r)["key"]
 	if !strings.HasPrefix(key, "user.") && !strings.HasPrefix(key, "cloud-init.") {
 		return &devLxdResponse{"not authorized", http.StatusForbidden, "raw"}
@@ -102,7 +120,7 @@ var devlxdConfigKeyGet = devLxdHandler{"/1.0/config/{key}", func(d *Daemon, w ht
 	}
 
 	return okResponse(value, "raw")
-}}
+}
 
 var devlxdMetadataGet = devLxdHandler{"/1.0/meta-data", func(d *Daemon, w http.ResponseWriter, r *http.Request) *devLxdResponse {
 	data, err := ioutil.ReadFile("instance-data")
@@ -130,7 +148,24 @@ var devLxdEventsGet = devLxdHandler{"/1.0/events", func(d *Daemon, w http.Respon
 	return okResponse("", "raw")
 }}
 
-var devlxdAPIGet = devLxdHandler{"/1.0", func(d *Daemon, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+var devlxdAPIHandler = devLxdHandler{"/1.0", func(d *Daemon, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+	switch r.Method {
+	case "GET":
+		// Prefer the host's view which includes the instance state, older hosts can't be reached though.
+		resp := devlxdHostRelay(r)
+		if resp.code == http.StatusOK {
+			return resp
+		}
+
+		return devlxdAPIGetHandler(d, w, r)
+	case "PATCH":
+		return devlxdHostRelay(r)
+	}
+
+	return &devLxdResponse{fmt.Sprintf("method %q not allowed", r.Method), http.StatusBadRequest, "raw"}
+}}
+
+func devlxdAPIGetHandler(d *Daemon, w http.ResponseWriter, r *http.Request) *devLxdResponse {
 	data, err := ioutil.ReadFile("instance-data")
 	if err != nil {
 		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
@@ -143,8 +178,69 @@ var devlxdAPIGet = devLxdHandler{"/1.0", func(d *Daemon, w http.ResponseWriter,
 		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
 	}
 	return okResponse(shared.Jmap{"api_version": version.APIVersion, "location": instance.Location}, "json")
+}
+
+var devlxdSnapshotsPost = devLxdHandler{"/1.0/snapshots", func(d *Daemon, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+	if r.Method != "POST" {
+		return &devLxdResponse{fmt.Sprintf("method %q not allowed", r.Method), http.StatusBadRequest, "raw"}
+	}
+
+	return devlxdHostRelay(r)
 }}
 
+// devlxdHostRelay forwards a request to the /dev/lxd API served by LXD on the host over vsock.
+func devlxdHostRelay(r *http.Request) *devLxdResponse {
+	agentCert, err := ioutil.ReadFile("agent.crt")
+	if err != nil {
+		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+	}
+
+	agentKey, err := ioutil.ReadFile("agent.key")
+	if err != nil {
+		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+	}
+
+	hostCert, err := ioutil.ReadFile("host.crt")
+	if err != nil {
+		return &devLxdResponse{"not supported by host", http.StatusNotImplemented, "raw"}
+	}
+
+	client, err := vsock.HTTPClient(devlxdHostContextID, string(agentCert), string(agentKey), string(hostCert))
+	if err != nil {
+		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+	}
+
+	u := url.URL{Scheme: "https", Host: "lxd", Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+	req, err := http.NewRequest(r.Method, u.String(), r.Body)
+	if err != nil {
+		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+	}
+
+	req.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		logger.Error("Failed relaying devlxd request to host", log.Ctx{"method": r.Method, "path": r.URL.Path, "err": err})
+		return &devLxdResponse{"host unreachable", http.StatusBadGateway, "raw"}
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &devLxdResponse{strings.TrimSpace(string(body)), resp.StatusCode, "raw"}
+	}
+
+	if resp.Header.Get("Content-Type") == "application/json" {
+		return okResponse(json.RawMessage(body), "json")
+	}
+
+	return okResponse(string(body), "raw")
+}
+
 var devlxdDevicesGet = devLxdHandler{"/1.0/devices", func(d *Daemon, w http.ResponseWriter, r *http.Request) *devLxdResponse {
 	data, err := ioutil.ReadFile("instance-data")
 	if err != nil {
@@ -164,12 +260,13 @@ var handlers = []devLxdHandler{
 	{"/", func(d *Daemon, w http.ResponseWriter, r *http.Request) *devLxdResponse {
 		return okResponse([]string{"/1.0"}, "json")
 	}},
-	devlxdAPIGet,
+	devlxdAPIHandler,
 	devlxdConfigGet,
-	devlxdConfigKeyGet,
+	devlxdConfigKeyHandler,
 	devlxdMetadataGet,
 	devLxdEventsGet,
 	devlxdDevicesGet,
+	devlxdSnapshotsPost,
 }
 
 func hoistReq(f func(*Daemon, http.ResponseWriter, *http.Request) *devLxdResponse, d *Daemon) func(http.ResponseWriter, *http.Request) {
@@ -2,9 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -77,5 +81,99 @@ func eventsPost(d *Daemon, r *http.Request) response.Response {
 		return response.InternalError(err)
 	}
 
+	// Handle the event before returning, so that LXD knows shares are unmounted before removing them.
+	eventsProcess(event)
+
 	return response.SyncResponse(true, nil)
 }
+
+// eventsProcess performs the actions requested by LXD through events.
+func eventsProcess(event api.Event) {
+	switch event.Type {
+	case "config":
+		eventsProcessConfig(event)
+	case "device":
+		eventsProcessDevice(event)
+	}
+}
+
+// eventsProcessConfig onlines the vCPUs and memory hotplugged by LXD when the limits change.
+func eventsProcessConfig(event api.Event) {
+	e := struct {
+		Key string `json:"key"`
+	}{}
+
+	err := json.Unmarshal(event.Metadata, &e)
+	if err != nil {
+		logger.Errorf("Failed to parse config event: %v", err)
+		return
+	}
+
+	if e.Key != "limits.cpu" && e.Key != "limits.memory" {
+		return
+	}
+
+	// The guest kernel may take a little while to notice the new hardware, so keep trying for some time.
+	go func() {
+		for i := 0; i < 10; i++ {
+			onlineHotplugged("/sys/devices/system/cpu/cpu*/online", "0", "1")
+			onlineHotplugged("/sys/devices/system/memory/memory*/state", "offline", "online")
+			time.Sleep(time.Second)
+		}
+	}()
+}
+
+// onlineHotplugged writes the online value to the state files matching pattern which hold the offline value.
+func onlineHotplugged(pattern string, offline string, online string) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return
+	}
+
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil || strings.TrimSpace(string(content)) != offline {
+			continue
+		}
+
+		err = ioutil.WriteFile(path, []byte(online), 0644)
+		if err != nil {
+			logger.Errorf("Failed to online %q: %v", filepath.Dir(path), err)
+			continue
+		}
+
+		logger.Infof("Onlined %q", filepath.Dir(path))
+	}
+}
+
+// eventsProcessDevice mounts and unmounts the disk shares added and removed by LXD.
+func eventsProcessDevice(event api.Event) {
+	e := struct {
+		Action string                    `json:"action"`
+		Config map[string]string         `json:"config"`
+		Name   string                    `json:"name"`
+		Mount  instancetype.VMAgentMount `json:"mount"`
+	}{}
+
+	err := json.Unmarshal(event.Metadata, &e)
+	if err != nil {
+		logger.Errorf("Failed to parse device event: %v", err)
+		return
+	}
+
+	// Only disk shares need handling.
+	if e.Config["type"] != "disk" || e.Mount.Source == "" {
+		return
+	}
+
+	switch e.Action {
+	case "added":
+		err = mountHostShare(e.Mount)
+	case "removed":
+		err = unmountHostShare(e.Mount)
+	}
+
+	if err != nil {
+		logger.Error(err.Error())
+	}
+}
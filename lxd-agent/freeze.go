@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Filesystem freeze ioctls (linux/fs.h).
+const fiFreeze = 0xC0045877
+const fiThaw = 0xC0045878
+
+// freezeHooksDir contains executables run with a "freeze" argument before the filesystems are frozen
+// and with a "thaw" argument after they have been thawed.
+const freezeHooksDir = "/etc/lxd-agent/freeze-hook.d"
+
+// freezeSkipFSTypes lists the virtual filesystems that don't need (or don't support) freezing.
+var freezeSkipFSTypes = []string{"autofs", "bpf", "binfmt_misc", "cgroup", "cgroup2", "configfs", "debugfs", "devpts", "devtmpfs", "efivarfs", "fusectl", "hugetlbfs", "mqueue", "nsfs", "proc", "pstore", "rpc_pipefs", "securityfs", "sysfs", "tmpfs", "tracefs", "virtiofs", "9p"}
+
+var freezeCmd = APIEndpoint{
+	Name: "freeze",
+	Path: "freeze",
+
+	Put: APIEndpointAction{Handler: freezePut},
+}
+
+// freezeState tracks the filesystems currently frozen by the agent.
+var freezeState struct {
+	mu     sync.Mutex
+	frozen []string
+	timer  *time.Timer
+}
+
+func freezePut(d *Daemon, r *http.Request) response.Response {
+	req := api.InstanceFilesystemsFreezePut{}
+
+	buf, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = json.Unmarshal(buf, &req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if req.Timeout < 0 {
+		return response.BadRequest(fmt.Errorf("Invalid timeout %d", req.Timeout))
+	}
+
+	if req.Frozen {
+		err = freezeFilesystems(time.Duration(req.Timeout) * time.Second)
+	} else {
+		err = thawFilesystems()
+	}
+
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+// freezeFilesystems runs the freeze hooks and then freezes all mounted filesystems.
+// If timeout is non-zero, the filesystems are automatically thawed once it expires.
+func freezeFilesystems(timeout time.Duration) error {
+	freezeState.mu.Lock()
+	defer freezeState.mu.Unlock()
+
+	if freezeState.frozen != nil {
+		return api.StatusErrorf(http.StatusConflict, "Filesystems are already frozen")
+	}
+
+	err := runFreezeHooks("freeze")
+	if err != nil {
+		runFreezeHooks("thaw")
+		return err
+	}
+
+	mounts, err := freezeMountPoints()
+	if err != nil {
+		runFreezeHooks("thaw")
+		return err
+	}
+
+	// Freeze the most recently mounted filesystems first so that nested mounts are frozen before their parents.
+	frozen := []string{}
+	for i := len(mounts) - 1; i >= 0; i-- {
+		err = fsIoctl(mounts[i], fiFreeze)
+		if err == unix.EOPNOTSUPP || err == unix.EBUSY {
+			// Filesystem doesn't support freezing or is already frozen by someone else.
+			continue
+		} else if err != nil {
+			for j := len(frozen) - 1; j >= 0; j-- {
+				fsIoctl(frozen[j], fiThaw)
+			}
+
+			runFreezeHooks("thaw")
+
+			return fmt.Errorf("Failed freezing %q: %w", mounts[i], err)
+		}
+
+		frozen = append(frozen, mounts[i])
+	}
+
+	// Avoid logging until thawed as the log target may well be on one of the frozen filesystems.
+	freezeState.frozen = frozen
+
+	if timeout > 0 {
+		freezeState.timer = time.AfterFunc(timeout, func() {
+			err := thawFilesystems()
+			if err != nil {
+				logger.Error("Failed thawing filesystems after freeze timeout", log.Ctx{"timeout": timeout, "err": err})
+				return
+			}
+
+			logger.Warn("Thawed filesystems after freeze timeout", log.Ctx{"timeout": timeout})
+		})
+	}
+
+	return nil
+}
+
+// thawFilesystems thaws the filesystems frozen by freezeFilesystems and then runs the thaw hooks.
+func thawFilesystems() error {
+	freezeState.mu.Lock()
+	defer freezeState.mu.Unlock()
+
+	if freezeState.frozen == nil {
+		return api.StatusErrorf(http.StatusConflict, "Filesystems aren't frozen")
+	}
+
+	if freezeState.timer != nil {
+		freezeState.timer.Stop()
+		freezeState.timer = nil
+	}
+
+	var errs []string
+	for i := len(freezeState.frozen) - 1; i >= 0; i-- {
+		// EINVAL means the filesystem isn't frozen anymore.
+		err := fsIoctl(freezeState.frozen[i], fiThaw)
+		if err != nil && err != unix.EINVAL {
+			errs = append(errs, fmt.Sprintf("%q: %v", freezeState.frozen[i], err))
+		}
+	}
+
+	logger.Info("Thawed filesystems", log.Ctx{"mounts": freezeState.frozen})
+	freezeState.frozen = nil
+
+	err := runFreezeHooks("thaw")
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("Failed thawing filesystems: %s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// freezeMountPoints returns the mount points of the filesystems that can be frozen, in mount order.
+func freezeMountPoints() ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseFreezeMountPoints(f)
+}
+
+// parseFreezeMountPoints returns the mount points of the filesystems that can be frozen from mountinfo content.
+func parseFreezeMountPoints(r io.Reader) ([]string, error) {
+	mounts := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// Format: ID parentID major:minor root mountpoint options [optional fields...] - fstype source superoptions
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i, field := range fields {
+			if field == "-" {
+				sep = i
+				break
+			}
+		}
+
+		if len(fields) < 6 || sep < 0 || sep+1 >= len(fields) {
+			continue
+		}
+
+		fsType := fields[sep+1]
+		if shared.StringInSlice(fsType, freezeSkipFSTypes) || strings.HasPrefix(fsType, "fuse") {
+			continue
+		}
+
+		// Read-only mounts can't have pending writes.
+		if shared.StringInSlice("ro", strings.Split(fields[5], ",")) {
+			continue
+		}
+
+		mountPoint := unescapeMountPath(fields[4])
+
+		// Over-mounted paths show up several times, only keep the most recent entry.
+		for i, existing := range mounts {
+			if existing == mountPoint {
+				mounts = append(mounts[:i], mounts[i+1:]...)
+				break
+			}
+		}
+
+		mounts = append(mounts, mountPoint)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return mounts, nil
+}
+
+// unescapeMountPath decodes the octal escapes used by the kernel for spaces and similar in mountinfo.
+func unescapeMountPath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			var c byte
+			_, err := fmt.Sscanf(path[i+1:i+4], "%o", &c)
+			if err == nil {
+				b.WriteByte(c)
+				i += 3
+				continue
+			}
+		}
+
+		b.WriteByte(path[i])
+	}
+
+	return b.String()
+}
+
+// fsIoctl runs a freeze or thaw ioctl against the filesystem mounted at path.
+func fsIoctl(path string, request uint) error {
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(request), 0)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// runFreezeHooks runs the executables in freezeHooksDir with the given action as argument.
+func runFreezeHooks(action string) error {
+	hooks, err := freezeHooks(freezeHooksDir, action)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		out, err := exec.Command(hook, action).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("Failed running %s hook %q: %w (%s)", action, hook, err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	return nil
+}
+
+// freezeHooks returns the executables in dir to run for the given action.
+// Hooks run in lexical order on freeze and in reverse order on thaw.
+func freezeHooks(dir string, action string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	hooks := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Mode()&0111 == 0 {
+			continue
+		}
+
+		hooks = append(hooks, filepath.Join(dir, entry.Name()))
+	}
+
+	if action == "thaw" {
+		sort.Sort(sort.Reverse(sort.StringSlice(hooks)))
+	}
+
+	return hooks, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFreezeMountPoints(t *testing.T) {
+	mountinfo := `22 28 0:21 / /sys rw,nosuid,nodev,noexec,relatime shared:7 - sysfs sysfs rw
+23 28 0:22 / /proc rw,nosuid,nodev,noexec,relatime shared:13 - proc proc rw
+28 1 252:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+29 28 252:15 / /boot/efi rw,relatime shared:2 - vfat /dev/sda15 rw,fmask=0077
+30 28 0:25 / /run rw,nosuid,nodev,noexec,relatime shared:5 - tmpfs tmpfs rw,size=99360k
+31 28 252:2 / /srv/my\040data rw,relatime shared:3 - xfs /dev/sdb rw
+32 28 252:3 / /mnt/ro ro,relatime shared:4 - ext4 /dev/sdc ro
+33 28 0:30 / /home/user/.cache/doc rw,nosuid,nodev,relatime shared:6 - fuse.portal portal rw
+34 28 0:31 / /run/lxd_agent rw,relatime shared:8 - virtiofs config rw
+35 31 252:4 / /srv/my\040data/nested rw,relatime shared:9 - btrfs /dev/sdd rw
+36 28 252:5 / /boot/efi rw,relatime shared:10 - vfat /dev/sde rw
+malformed line
+`
+
+	mounts, err := parseFreezeMountPoints(strings.NewReader(mountinfo))
+	require.NoError(t, err)
+
+	// Virtual, FUSE and read-only filesystems are skipped and over-mounted paths are only kept once,
+	// at the position of their most recent mount.
+	assert.Equal(t, []string{"/", "/srv/my data", "/srv/my data/nested", "/boot/efi"}, mounts)
+}
+
+func TestUnescapeMountPath(t *testing.T) {
+	assert.Equal(t, "/srv/my data", unescapeMountPath(`/srv/my\040data`))
+	assert.Equal(t, "/tab\there", unescapeMountPath(`/tab\011here`))
+	assert.Equal(t, `/back\slash`, unescapeMountPath(`/back\134slash`))
+	assert.Equal(t, `/not\escaped`, unescapeMountPath(`/not\escaped`))
+	assert.Equal(t, "/trailing ", unescapeMountPath(`/trailing\040`))
+}
+
+func TestFreezeHooks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-agent-freeze-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, mode := range map[string]os.FileMode{"20-db": 0755, "10-app": 0700, "30-disabled": 0644} {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), mode)
+		require.NoError(t, err)
+	}
+
+	err = os.Mkdir(filepath.Join(dir, "15-dir"), 0755)
+	require.NoError(t, err)
+
+	// Hooks run in lexical order on freeze and in reverse order on thaw, skipping non-executables.
+	hooks, err := freezeHooks(dir, "freeze")
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "10-app"), filepath.Join(dir, "20-db")}, hooks)
+
+	hooks, err = freezeHooks(dir, "thaw")
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "20-db"), filepath.Join(dir, "10-app")}, hooks)
+
+	// A missing hooks directory means there are no hooks.
+	hooks, err = freezeHooks(filepath.Join(dir, "missing"), "freeze")
+	assert.NoError(t, err)
+	assert.Empty(t, hooks)
+}
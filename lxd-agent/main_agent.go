@@ -260,49 +260,72 @@ func (c *cmdAgent) mountHostShares() {
 	}
 
 	for _, mount := range agentMounts {
-		// Convert relative mounts to absolute from / otherwise dir creation fails or mount fails.
-		if !strings.HasPrefix(mount.Target, "/") {
-			mount.Target = fmt.Sprintf("/%s", mount.Target)
+		err = mountHostShare(mount)
+		if err != nil {
+			logger.Error(err.Error())
 		}
+	}
+}
 
-		if !shared.PathExists(mount.Target) {
-			err := os.MkdirAll(mount.Target, 0755)
-			if err != nil {
-				logger.Errorf("Failed to create mount target %q", mount.Target)
-				continue // Don't try to mount if mount point can't be created.
-			}
+// mountHostShare mounts a share from the host.
+func mountHostShare(mount instancetype.VMAgentMount) error {
+	// Convert relative mounts to absolute from / otherwise dir creation fails or mount fails.
+	if !strings.HasPrefix(mount.Target, "/") {
+		mount.Target = fmt.Sprintf("/%s", mount.Target)
+	}
+
+	if !shared.PathExists(mount.Target) {
+		err := os.MkdirAll(mount.Target, 0755)
+		if err != nil {
+			return fmt.Errorf("Failed to create mount target %q", mount.Target) // Don't try to mount if mount point can't be created.
 		}
+	}
 
-		if mount.FSType == "9p" {
-			// Before mounting with 9p, try virtio-fs and use 9p as the fallback.
-			args := []string{"-t", "virtiofs", mount.Source, mount.Target}
+	if mount.FSType == "9p" {
+		// Before mounting with 9p, try virtio-fs and use 9p as the fallback.
+		args := []string{"-t", "virtiofs", mount.Source, mount.Target}
 
-			for _, opt := range mount.Options {
-				// Ignore the 'trans-virtio' mount option as that's specific to 9p.
-				if opt != "trans=virtio" {
-					args = append(args, "-o", opt)
-				}
+		for _, opt := range mount.Options {
+			// Ignore the 'trans-virtio' mount option as that's specific to 9p.
+			if opt != "trans=virtio" {
+				args = append(args, "-o", opt)
 			}
+		}
 
-			_, err = shared.RunCommand("mount", args...)
-			if err == nil {
-				logger.Infof("Mounted %q (Type: %q, Options: %v) to %q", mount.Source, "virtiofs", mount.Options, mount.Target)
-				continue
-			}
+		_, err := shared.RunCommand("mount", args...)
+		if err == nil {
+			logger.Infof("Mounted %q (Type: %q, Options: %v) to %q", mount.Source, "virtiofs", mount.Options, mount.Target)
+			return nil
 		}
+	}
 
-		args := []string{"-t", mount.FSType, mount.Source, mount.Target}
+	args := []string{"-t", mount.FSType, mount.Source, mount.Target}
 
-		for _, opt := range mount.Options {
-			args = append(args, "-o", opt)
-		}
+	for _, opt := range mount.Options {
+		args = append(args, "-o", opt)
+	}
 
-		_, err = shared.RunCommand("mount", args...)
-		if err != nil {
-			logger.Errorf("Failed mount %q (Type: %q, Options: %v) to %q: %v", mount.Source, mount.FSType, mount.Options, mount.Target, err)
-			continue
-		}
+	_, err := shared.RunCommand("mount", args...)
+	if err != nil {
+		return fmt.Errorf("Failed mount %q (Type: %q, Options: %v) to %q: %v", mount.Source, mount.FSType, mount.Options, mount.Target, err)
+	}
+
+	logger.Infof("Mounted %q (Type: %q, Options: %v) to %q", mount.Source, mount.FSType, mount.Options, mount.Target)
+	return nil
+}
 
-		logger.Infof("Mounted %q (Type: %q, Options: %v) to %q", mount.Source, mount.FSType, mount.Options, mount.Target)
+// unmountHostShare unmounts a share from the host.
+func unmountHostShare(mount instancetype.VMAgentMount) error {
+	if !strings.HasPrefix(mount.Target, "/") {
+		mount.Target = fmt.Sprintf("/%s", mount.Target)
 	}
+
+	// Lazily unmount so that the share can be removed even if still in use.
+	_, err := shared.RunCommand("umount", "-l", mount.Target)
+	if err != nil {
+		return fmt.Errorf("Failed unmounting %q from %q: %v", mount.Source, mount.Target, err)
+	}
+
+	logger.Infof("Unmounted %q from %q", mount.Source, mount.Target)
+	return nil
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
@@ -13,7 +14,11 @@ import (
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/response"
+	storagePools "github.com/lxc/lxd/lxd/storage"
+	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
+	"github.com/lxc/lxd/lxd/storage/s3"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/pkg/errors"
 )
 
 // swagger:operation GET / server api_get
@@ -122,6 +127,60 @@ func metricsServer(d *Daemon) *http.Server {
 	return &http.Server{Handler: &lxdHttpServer{r: mux, d: d}}
 }
 
+func storageBucketsServer(d *Daemon) *http.Server {
+	access := func(accessKey string) (*s3.Access, error) {
+		// The cluster database may not be available yet during startup.
+		if d.cluster == nil {
+			return nil, fmt.Errorf("Cluster database not available")
+		}
+
+		var bucket *db.StorageBucket
+		var key *db.StorageBucketKey
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			bucket, key, err = tx.GetStoragePoolLocalBucketByAccessKey(accessKey)
+			return err
+		})
+		if err != nil {
+			if errors.Is(err, db.ErrNoSuchObject) {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+
+		mount := func() (string, func(), error) {
+			pool, err := storagePools.GetPoolByName(d.State(), bucket.PoolName)
+			if err != nil {
+				return "", nil, err
+			}
+
+			mountPath, err := pool.MountBucket(bucket.Project, bucket.Name, nil)
+			if err != nil {
+				return "", nil, err
+			}
+
+			release := func() {
+				_, err := pool.UnmountBucket(bucket.Project, bucket.Name, nil)
+				if err != nil && !errors.Is(err, storageDrivers.ErrInUse) {
+					logger.Warn("Failed unmounting storage bucket", log.Ctx{"pool": bucket.PoolName, "project": bucket.Project, "bucket": bucket.Name, "err": err})
+				}
+			}
+
+			return mountPath, release, nil
+		}
+
+		return &s3.Access{
+			SecretKey: key.SecretKey,
+			ReadOnly:  key.Role == "read-only",
+			Bucket:    project.StorageVolume(bucket.Project, bucket.Name),
+			Mount:     mount,
+		}, nil
+	}
+
+	return &http.Server{Handler: s3.NewServer(access)}
+}
+
 type lxdHttpServer struct {
 	r *mux.Router
 	d *Daemon
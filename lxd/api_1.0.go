@@ -16,7 +16,6 @@ import (
 	"github.com/lxc/lxd/lxd/lifecycle"
 	"github.com/lxc/lxd/lxd/node"
 	"github.com/lxc/lxd/lxd/project"
-	"github.com/lxc/lxd/lxd/rbac"
 	"github.com/lxc/lxd/lxd/request"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/util"
@@ -37,6 +36,8 @@ var api10Cmd = APIEndpoint{
 var api10 = []APIEndpoint{
 	api10Cmd,
 	api10ResourcesCmd,
+	authGroupCmd,
+	authGroupsCmd,
 	certificateCmd,
 	certificatesCmd,
 	clusterCmd,
@@ -61,6 +62,7 @@ var api10 = []APIEndpoint{
 	instanceSnapshotCmd,
 	instanceSnapshotsCmd,
 	instanceStateCmd,
+	instanceUEFIVarsCmd,
 	eventsCmd,
 	imageAliasCmd,
 	imageAliasesCmd,
@@ -75,12 +77,18 @@ var api10 = []APIEndpoint{
 	networkStateCmd,
 	networkACLCmd,
 	networkACLsCmd,
+	networkACLLogCmd,
+	networkAllocationsCmd,
 	networkForwardCmd,
 	networkForwardsCmd,
+	networkLoadBalancerCmd,
+	networkLoadBalancersCmd,
 	networkPeerCmd,
 	networkPeersCmd,
 	networkZoneCmd,
 	networkZonesCmd,
+	networkZoneRecordCmd,
+	networkZoneRecordsCmd,
 	operationCmd,
 	operationsCmd,
 	operationWait,
@@ -93,6 +101,10 @@ var api10 = []APIEndpoint{
 	storagePoolCmd,
 	storagePoolResourcesCmd,
 	storagePoolsCmd,
+	storagePoolBucketsCmd,
+	storagePoolBucketCmd,
+	storagePoolBucketKeysCmd,
+	storagePoolBucketKeyCmd,
 	storagePoolVolumesCmd,
 	storagePoolVolumeSnapshotsTypeCmd,
 	storagePoolVolumeSnapshotTypeCmd,
@@ -201,6 +213,11 @@ func api10Get(d *Daemon, r *http.Request) response.Response {
 			authMethods = append(authMethods, "candid")
 		}
 
+		oidcIssuer, oidcClientID, _ := config.OIDCServer()
+		if oidcIssuer != "" && oidcClientID != "" {
+			authMethods = append(authMethods, "oidc")
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -366,7 +383,7 @@ func api10Get(d *Daemon, r *http.Request) response.Response {
 	fullSrv := api.Server{ServerUntrusted: srv}
 	fullSrv.Environment = env
 
-	if rbac.UserIsAdmin(r) {
+	if d.authorizer.UserIsAdmin(r) {
 		fullSrv.Config, err = daemonConfigRender(d.State())
 		if err != nil {
 			return response.InternalError(err)
@@ -686,6 +703,7 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 
 	maasChanged := false
 	candidChanged := false
+	oidcChanged := false
 	rbacChanged := false
 	bgpChanged := false
 	dnsChanged := false
@@ -712,6 +730,12 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 			fallthrough
 		case "candid.api.url":
 			candidChanged = true
+		case "oidc.audience":
+			fallthrough
+		case "oidc.client.id":
+			fallthrough
+		case "oidc.issuer":
+			oidcChanged = true
 		case "cluster.images_minimal_replica":
 			autoSyncImages(d.shutdownCtx, d)
 		case "cluster.offline_threshold":
@@ -794,6 +818,14 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 		}
 	}
 
+	value, ok = nodeChanged["core.storage_buckets_address"]
+	if ok {
+		err := d.endpoints.StorageBucketsUpdateAddress(value, d.endpoints.NetworkCert())
+		if err != nil {
+			return err
+		}
+	}
+
 	value, ok = nodeChanged["storage.backups_volume"]
 	if ok {
 		err := daemonStorageMove(s, "backups", value)
@@ -827,6 +859,14 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 		}
 	}
 
+	if oidcChanged {
+		issuer, clientID, audience := clusterConfig.OIDCServer()
+		err := d.setupOIDC(issuer, clientID, audience)
+		if err != nil {
+			return err
+		}
+	}
+
 	if rbacChanged {
 		apiURL, apiKey, apiExpiry, agentURL, agentUsername, agentPrivateKey, agentPublicKey := clusterConfig.RBACServer()
 
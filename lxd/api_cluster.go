@@ -21,8 +21,10 @@ import (
 	"github.com/lxc/lxd/lxd/cluster"
 	clusterRequest "github.com/lxc/lxd/lxd/cluster/request"
 	"github.com/lxc/lxd/lxd/db"
+	dbCluster "github.com/lxc/lxd/lxd/db/cluster"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/drivers"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/lifecycle"
 	"github.com/lxc/lxd/lxd/node"
 	"github.com/lxc/lxd/lxd/operations"
@@ -30,6 +32,7 @@ import (
 	"github.com/lxc/lxd/lxd/request"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/revert"
+	storagePools "github.com/lxc/lxd/lxd/storage"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -2638,9 +2641,10 @@ func evacuateClusterMember(d *Daemon, r *http.Request) response.Response {
 		metadata := make(map[string]interface{})
 
 		for _, inst := range instances {
-			// Stop the instance if needed.
+			// Stop the instance if needed (unless it can be live migrated).
 			isRunning := inst.IsRunning()
-			if isRunning {
+			liveMigrate := isRunning && evacuateLiveMigrate(inst)
+			if isRunning && !liveMigrate {
 				metadata["evacuation_progress"] = fmt.Sprintf("Stopping %q in project %q", inst.Name(), inst.Project())
 				op.UpdateMetadata(metadata)
 
@@ -2696,6 +2700,7 @@ func evacuateClusterMember(d *Daemon, r *http.Request) response.Response {
 			// Migrate the instance.
 			req := api.InstancePost{
 				Name: inst.Name(),
+				Live: liveMigrate,
 			}
 
 			err = migrateInstance(d, r, inst, targetNodeName, false, req, op)
@@ -2703,7 +2708,7 @@ func evacuateClusterMember(d *Daemon, r *http.Request) response.Response {
 				return errors.Wrap(err, "Failed to migrate instance")
 			}
 
-			if !isRunning {
+			if !isRunning || liveMigrate {
 				continue
 			}
 
@@ -2740,6 +2745,214 @@ func evacuateClusterMember(d *Daemon, r *http.Request) response.Response {
 	return operations.OperationResponse(op)
 }
 
+// evacuateLiveMigrate returns whether a running instance should be live migrated during evacuation.
+func evacuateLiveMigrate(inst instance.Instance) bool {
+	config := inst.ExpandedConfig()
+
+	return inst.Type() == instancetype.VM && config["cluster.evacuate"] == "live-migrate" && shared.IsTrue(config["migration.stateful"])
+}
+
+// healClusterMembers evacuates the cluster members which have been offline for longer than the healing
+// threshold, moving their ceph-backed instances to healthy members. It's run by the leader on heartbeat.
+func healClusterMembers(d *Daemon) {
+	var members []db.NodeInfo
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		config, err := cluster.ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+
+		healingThreshold := config.HealingThreshold()
+		if healingThreshold == 0 {
+			return nil
+		}
+
+		// Members can't be considered for healing before being considered offline.
+		offlineThreshold := config.OfflineThreshold()
+		if healingThreshold < offlineThreshold {
+			healingThreshold = offlineThreshold
+		}
+
+		nodes, err := tx.GetNodes()
+		if err != nil {
+			return err
+		}
+
+		for _, node := range nodes {
+			if node.State != db.ClusterMemberStateCreated {
+				continue
+			}
+
+			if node.IsOffline(healingThreshold) {
+				members = append(members, node)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.Error("Failed loading cluster members to heal", log.Ctx{"err": err})
+		return
+	}
+
+	for _, member := range members {
+		err := healClusterMember(d, member)
+		if err != nil {
+			logger.Error("Failed healing cluster member", log.Ctx{"member": member.Name, "err": err})
+		}
+	}
+}
+
+// healClusterMember marks an offline cluster member as evacuated and starts an operation moving its ceph-backed
+// instances which can be migrated to the least loaded members, starting those which were running.
+func healClusterMember(d *Daemon, member db.NodeInfo) error {
+	logger.Warn("Evacuating offline cluster member", log.Ctx{"member": member.Name, "lastHeartbeat": member.Heartbeat})
+
+	var dbInstances []db.Instance
+
+	// Prevent instances from being placed on the member and, as it is then evacuated, healing it again.
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		err := tx.UpdateNodeStatus(member.ID, db.ClusterMemberStateEvacuated)
+		if err != nil {
+			return errors.Wrap(err, "Failed to update cluster member status")
+		}
+
+		dbInstances, err = tx.GetInstances(db.InstanceFilter{Node: &member.Name})
+		if err != nil {
+			return errors.Wrap(err, "Failed to get instances")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = d.cluster.UpsertWarningLocalNode("", dbCluster.TypeNode, int(member.ID), db.WarningClusterMemberHealed, fmt.Sprintf("Cluster member %q has been offline since %s", member.Name, member.Heartbeat.Format(time.RFC3339)))
+	if err != nil {
+		logger.Warn("Failed to create warning", log.Ctx{"err": err})
+	}
+
+	d.State().Events.SendLifecycle(project.Default, lifecycle.ClusterMemberHealed.Event(member.Name, nil, log.Ctx{"last_heartbeat": member.Heartbeat}))
+
+	instanceWarning := func(inst instance.Instance, err error) {
+		logger.Error("Failed moving instance off offline cluster member", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "member": member.Name, "err": err})
+
+		warnErr := d.cluster.UpsertWarningLocalNode(inst.Project(), dbCluster.TypeInstance, inst.ID(), db.WarningInstanceHealingFailure, err.Error())
+		if warnErr != nil {
+			logger.Warn("Failed to create warning", log.Ctx{"err": warnErr})
+		}
+	}
+
+	run := func(op *operations.Operation) error {
+		metadata := make(map[string]interface{})
+
+		for _, dbInst := range dbInstances {
+			inst, err := instance.LoadByProjectAndName(d.State(), dbInst.Project, dbInst.Name)
+			if err != nil {
+				logger.Error("Failed loading instance", log.Ctx{"project": dbInst.Project, "instance": dbInst.Name, "err": err})
+				continue
+			}
+
+			// Only instances on ceph can be moved without their cluster member.
+			pool, err := storagePools.GetPoolByInstance(d.State(), inst)
+			if err != nil {
+				instanceWarning(inst, fmt.Errorf("Failed loading instance storage pool: %w", err))
+				continue
+			}
+
+			if pool.Driver().Info().Name != "ceph" {
+				logger.Info("Skipping instance not backed by ceph", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "member": member.Name})
+				continue
+			}
+
+			// Apply the same policy as a manual evacuation, leaving the instances that can't be migrated (and
+			// their power state) for when the member is restored.
+			if !inst.CanMigrate() {
+				logger.Info("Skipping instance which can't be migrated", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "member": member.Name})
+				continue
+			}
+
+			// Find the least loaded cluster member which supports the architecture.
+			var targetNodeName string
+			var targetNode db.NodeInfo
+			err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+				targetNodeName, err = tx.GetNodeWithLeastInstances([]int{member.Architecture}, -1, "")
+				if err != nil {
+					return err
+				}
+
+				if targetNodeName == "" {
+					return fmt.Errorf("No suitable cluster member found")
+				}
+
+				targetNode, err = tx.GetNodeByName(targetNodeName)
+				if err != nil {
+					return err
+				}
+
+				return nil
+			})
+			if err != nil {
+				instanceWarning(inst, err)
+				continue
+			}
+
+			// Move the instance.
+			metadata["evacuation_progress"] = fmt.Sprintf("Migrating %q in project %q to %q", inst.Name(), inst.Project(), targetNodeName)
+			op.UpdateMetadata(metadata)
+
+			wasRunning := inst.LocalConfig()["volatile.last_state.power"] == "RUNNING"
+			inst.VolatileSet(map[string]string{"volatile.evacuate.origin": member.Name})
+
+			err = migrateInstance(d, nil, inst, targetNodeName, true, api.InstancePost{Name: inst.Name()}, op)
+			if err != nil {
+				instanceWarning(inst, fmt.Errorf("Failed moving instance to %q: %w", targetNodeName, err))
+				continue
+			}
+
+			d.State().Events.SendLifecycle(inst.Project(), lifecycle.InstanceHealed.Event(inst, log.Ctx{"origin": member.Name, "target": targetNodeName}))
+
+			if !wasRunning {
+				continue
+			}
+
+			// Start it back up on target.
+			metadata["evacuation_progress"] = fmt.Sprintf("Starting %q in project %q", inst.Name(), inst.Project())
+			op.UpdateMetadata(metadata)
+
+			dest, err := cluster.Connect(targetNode.Address, d.endpoints.NetworkCert(), d.serverCert(), nil, true)
+			if err != nil {
+				instanceWarning(inst, fmt.Errorf("Failed to connect to %q: %w", targetNodeName, err))
+				continue
+			}
+
+			dest = dest.UseProject(inst.Project())
+
+			startOp, err := dest.UpdateInstanceState(inst.Name(), api.InstanceStatePut{Action: "start"}, "")
+			if err == nil {
+				err = startOp.Wait()
+			}
+
+			if err != nil {
+				instanceWarning(inst, fmt.Errorf("Failed starting instance on %q: %w", targetNodeName, err))
+				continue
+			}
+		}
+
+		return nil
+	}
+
+	op, err := operations.OperationCreate(d.State(), "", operations.OperationClassTask, db.OperationClusterHeal, nil, nil, run, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = op.Run()
+	return err
+}
+
 func restoreClusterMember(d *Daemon, r *http.Request) response.Response {
 	originName := mux.Vars(r)["name"]
 
@@ -2866,9 +3079,10 @@ func restoreClusterMember(d *Daemon, r *http.Request) response.Response {
 				return errors.Wrapf(err, "Failed to get instance %q", inst.Name())
 			}
 
-			isRunning := apiInst.StatusCode == api.Running
+			isRunning := apiInst.StatusCode == api.Running || apiInst.StatusCode == api.Ready
+			liveMigrate := isRunning && evacuateLiveMigrate(inst)
 
-			if isRunning {
+			if isRunning && !liveMigrate {
 				metadata["evacuation_progress"] = fmt.Sprintf("Stopping %q in project %q", inst.Name(), inst.Project())
 				op.UpdateMetadata(metadata)
 
@@ -2905,6 +3119,7 @@ func restoreClusterMember(d *Daemon, r *http.Request) response.Response {
 			req := api.InstancePost{
 				Name:      inst.Name(),
 				Migration: true,
+				Live:      liveMigrate,
 			}
 
 			source = source.UseTarget(originName)
@@ -2944,7 +3159,7 @@ func restoreClusterMember(d *Daemon, r *http.Request) response.Response {
 				return errors.Wrapf(err, "Failed to update instance %q", inst.Name())
 			}
 
-			if !isRunning {
+			if !isRunning || liveMigrate {
 				continue
 			}
 
@@ -12,13 +12,14 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 
+	"github.com/lxc/lxd/lxd/auth"
+	backupTarget "github.com/lxc/lxd/lxd/backup/target"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/lifecycle"
 	"github.com/lxc/lxd/lxd/network"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
 	projecthelpers "github.com/lxc/lxd/lxd/project"
-	"github.com/lxc/lxd/lxd/rbac"
 	"github.com/lxc/lxd/lxd/request"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/state"
@@ -155,11 +156,11 @@ func projectsGet(d *Daemon, r *http.Request) response.Response {
 
 			filtered := []api.Project{}
 			for _, project := range projects {
-				if !rbac.UserHasPermission(r, project.Name, "view") {
+				if !d.authorizer.UserHasPermission(r, auth.ObjectProject(project.Name), auth.EntitlementCanView) {
 					continue
 				}
 
-				filtered = append(filtered, project.ToAPI())
+				filtered = append(filtered, projectToAPI(&project))
 			}
 
 			result = filtered
@@ -173,7 +174,7 @@ func projectsGet(d *Daemon, r *http.Request) response.Response {
 			for _, uri := range uris {
 				name := strings.Split(uri, "/1.0/projects/")[1]
 
-				if !rbac.UserHasPermission(r, name, "view") {
+				if !d.authorizer.UserHasPermission(r, auth.ObjectProject(name), auth.EntitlementCanView) {
 					continue
 				}
 
@@ -344,7 +345,7 @@ func projectGet(d *Daemon, r *http.Request) response.Response {
 	name := mux.Vars(r)["name"]
 
 	// Check user permissions
-	if !rbac.UserHasPermission(r, name, "view") {
+	if !d.authorizer.UserHasPermission(r, auth.ObjectProject(name), auth.EntitlementCanView) {
 		return response.Forbidden(nil)
 	}
 
@@ -356,10 +357,10 @@ func projectGet(d *Daemon, r *http.Request) response.Response {
 
 	etag := []interface{}{
 		project.Description,
-		project.Config,
+		projectHideConfig(project.Config),
 	}
 
-	return response.SyncResponseETag(true, project.ToAPI(), etag)
+	return response.SyncResponseETag(true, projectToAPI(project), etag)
 }
 
 // swagger:operation PUT /1.0/projects/{name} projects project_put
@@ -395,7 +396,7 @@ func projectPut(d *Daemon, r *http.Request) response.Response {
 	name := mux.Vars(r)["name"]
 
 	// Check user permissions
-	if !rbac.UserHasPermission(r, name, "manage-projects") {
+	if !d.authorizer.UserHasPermission(r, auth.ObjectProject(name), auth.EntitlementCanEdit) {
 		return response.Forbidden(nil)
 	}
 
@@ -408,7 +409,7 @@ func projectPut(d *Daemon, r *http.Request) response.Response {
 	// Validate ETag
 	etag := []interface{}{
 		project.Description,
-		project.Config,
+		projectHideConfig(project.Config),
 	}
 	err = util.EtagCheck(r, etag)
 	if err != nil {
@@ -462,7 +463,7 @@ func projectPatch(d *Daemon, r *http.Request) response.Response {
 	name := mux.Vars(r)["name"]
 
 	// Check user permissions
-	if !rbac.UserHasPermission(r, name, "manage-projects") {
+	if !d.authorizer.UserHasPermission(r, auth.ObjectProject(name), auth.EntitlementCanEdit) {
 		return response.Forbidden(nil)
 	}
 
@@ -475,7 +476,7 @@ func projectPatch(d *Daemon, r *http.Request) response.Response {
 	// Validate ETag
 	etag := []interface{}{
 		project.Description,
-		project.Config,
+		projectHideConfig(project.Config),
 	}
 	err = util.EtagCheck(r, etag)
 	if err != nil {
@@ -526,6 +527,13 @@ func projectPatch(d *Daemon, r *http.Request) response.Response {
 
 // Common logic between PUT and PATCH.
 func projectChange(d *Daemon, project *db.Project, req api.ProjectPut) response.Response {
+	// Hidden keys set to "true" keep their current value.
+	for _, key := range projectHiddenConfigKeys {
+		if req.Config[key] == "true" && project.Config[key] != "" {
+			req.Config[key] = project.Config[key]
+		}
+	}
+
 	// Make a list of config keys that have changed.
 	configChanged := []string{}
 	for key := range project.Config {
@@ -803,7 +811,7 @@ func projectStateGet(d *Daemon, r *http.Request) response.Response {
 	name := mux.Vars(r)["name"]
 
 	// Check user permissions.
-	if !rbac.UserHasPermission(r, name, "view") {
+	if !d.authorizer.UserHasPermission(r, auth.ObjectProject(name), auth.EntitlementCanView) {
 		return response.Forbidden(nil)
 	}
 
@@ -839,6 +847,34 @@ func projectIsEmpty(project *db.Project) bool {
 	return true
 }
 
+// projectHiddenConfigKeys are the project config keys whose value isn't returned by the API.
+var projectHiddenConfigKeys = []string{
+	"backups.target.access_key",
+	"backups.target.secret_key",
+}
+
+// projectHideConfig returns a copy of the project config with the value of hidden keys replaced with "true".
+func projectHideConfig(config map[string]string) map[string]string {
+	hidden := make(map[string]string, len(config))
+	for k, v := range config {
+		if v != "" && shared.StringInSlice(k, projectHiddenConfigKeys) {
+			v = "true"
+		}
+
+		hidden[k] = v
+	}
+
+	return hidden
+}
+
+// projectToAPI returns the API representation of the project with the value of hidden keys hidden.
+func projectToAPI(project *db.Project) api.Project {
+	apiProject := project.ToAPI()
+	apiProject.Config = projectHideConfig(project.Config)
+
+	return apiProject
+}
+
 func isEitherAllowOrBlock(value string) error {
 	return validate.Optional(validate.IsOneOf("block", "allow"))(value)
 }
@@ -851,6 +887,9 @@ func projectValidateConfig(s *state.State, config map[string]string) error {
 	// Validate the project configuration.
 	projectConfigKeys := map[string]func(value string) error{
 		"backups.compression_algorithm":        validate.IsCompressionAlgorithm,
+		"backups.target":                       validate.Optional(backupTarget.ValidateURL),
+		"backups.target.access_key":            validate.IsAny,
+		"backups.target.secret_key":            validate.IsAny,
 		"features.profiles":                    validate.Optional(validate.IsBool),
 		"features.images":                      validate.Optional(validate.IsBool),
 		"features.storage.volumes":             validate.Optional(validate.IsBool),
@@ -0,0 +1,355 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/request"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// ObjectType is the type of an object permissions can be granted on.
+type ObjectType string
+
+// All supported object types.
+const (
+	ObjectTypeServer        = ObjectType("server")
+	ObjectTypeProject       = ObjectType("project")
+	ObjectTypeInstance      = ObjectType("instance")
+	ObjectTypeNetwork       = ObjectType("network")
+	ObjectTypeStorageVolume = ObjectType("storage_volume")
+)
+
+// Entitlement is a permission which can be granted on an object.
+type Entitlement string
+
+// Entitlements on the server.
+const (
+	EntitlementAdmin = Entitlement("admin")
+)
+
+// Entitlements on all objects other than the server.
+const (
+	EntitlementCanView = Entitlement("can_view")
+	EntitlementCanEdit = Entitlement("can_edit")
+)
+
+// Entitlements on projects which apply to all entities of a kind within the project.
+const (
+	EntitlementCanManageInstances      = Entitlement("can_manage_instances")
+	EntitlementCanOperateInstances     = Entitlement("can_operate_instances")
+	EntitlementCanManageImages         = Entitlement("can_manage_images")
+	EntitlementCanManageNetworks       = Entitlement("can_manage_networks")
+	EntitlementCanManageProfiles       = Entitlement("can_manage_profiles")
+	EntitlementCanManageStorageVolumes = Entitlement("can_manage_storage_volumes")
+)
+
+// Entitlements on instances and storage volumes.
+const (
+	EntitlementCanUpdateState     = Entitlement("can_update_state")
+	EntitlementCanExec            = Entitlement("can_exec")
+	EntitlementCanAccessConsole   = Entitlement("can_access_console")
+	EntitlementCanAccessFiles     = Entitlement("can_access_files")
+	EntitlementCanManageSnapshots = Entitlement("can_manage_snapshots")
+	EntitlementCanManageBackups   = Entitlement("can_manage_backups")
+)
+
+// Entitlements lists the entitlements which can be granted on each object type.
+var Entitlements = map[ObjectType][]Entitlement{
+	ObjectTypeServer: {EntitlementAdmin},
+	ObjectTypeProject: {
+		EntitlementCanView,
+		EntitlementCanEdit,
+		EntitlementCanManageInstances,
+		EntitlementCanOperateInstances,
+		EntitlementCanManageImages,
+		EntitlementCanManageNetworks,
+		EntitlementCanManageProfiles,
+		EntitlementCanManageStorageVolumes,
+	},
+	ObjectTypeInstance: {
+		EntitlementCanView,
+		EntitlementCanEdit,
+		EntitlementCanUpdateState,
+		EntitlementCanExec,
+		EntitlementCanAccessConsole,
+		EntitlementCanAccessFiles,
+		EntitlementCanManageSnapshots,
+		EntitlementCanManageBackups,
+	},
+	ObjectTypeNetwork: {
+		EntitlementCanView,
+		EntitlementCanEdit,
+	},
+	ObjectTypeStorageVolume: {
+		EntitlementCanView,
+		EntitlementCanEdit,
+		EntitlementCanManageSnapshots,
+		EntitlementCanManageBackups,
+	},
+}
+
+// legacyPermissions maps the project permissions of restricted certificates and RBAC to project entitlements.
+var legacyPermissions = map[string]Entitlement{
+	"view":                   EntitlementCanView,
+	"manage-projects":        EntitlementCanEdit,
+	"manage-containers":      EntitlementCanManageInstances,
+	"operate-containers":     EntitlementCanOperateInstances,
+	"manage-images":          EntitlementCanManageImages,
+	"manage-networks":        EntitlementCanManageNetworks,
+	"manage-profiles":        EntitlementCanManageProfiles,
+	"manage-storage-volumes": EntitlementCanManageStorageVolumes,
+}
+
+// ProjectEntitlement returns the project entitlement equivalent to a legacy project permission.
+func ProjectEntitlement(permission string) Entitlement {
+	entitlement, ok := legacyPermissions[permission]
+	if !ok {
+		// Unknown permissions can't be granted.
+		return Entitlement(permission)
+	}
+
+	return entitlement
+}
+
+// Object identifies something permissions can be granted on.
+type Object struct {
+	Type ObjectType
+
+	// Project is the project of the object or the project itself for project objects.
+	Project string
+
+	// Name is the name of the object within its project.
+	// For storage volumes, this is "<pool>/<type>/<volume>".
+	Name string
+}
+
+// ObjectServer returns the server object.
+func ObjectServer() Object {
+	return Object{Type: ObjectTypeServer}
+}
+
+// ObjectProject returns the object for a project.
+func ObjectProject(projectName string) Object {
+	return Object{Type: ObjectTypeProject, Project: projectName}
+}
+
+// ObjectInstance returns the object for an instance.
+func ObjectInstance(projectName string, instanceName string) Object {
+	return Object{Type: ObjectTypeInstance, Project: projectName, Name: instanceName}
+}
+
+// ObjectNetwork returns the object for a network.
+func ObjectNetwork(projectName string, networkName string) Object {
+	return Object{Type: ObjectTypeNetwork, Project: projectName, Name: networkName}
+}
+
+// ObjectStorageVolume returns the object for a storage volume.
+func ObjectStorageVolume(projectName string, poolName string, volumeType string, volumeName string) Object {
+	return Object{Type: ObjectTypeStorageVolume, Project: projectName, Name: strings.Join([]string{poolName, volumeType, volumeName}, "/")}
+}
+
+// Permission is an entitlement granted on an object.
+type Permission struct {
+	Entitlement Entitlement
+	Object      Object
+}
+
+// PermissionFromAPI validates an API permission and converts it.
+func PermissionFromAPI(p api.AuthPermission) (*Permission, error) {
+	objectType := ObjectType(p.EntityType)
+
+	entitlements, ok := Entitlements[objectType]
+	if !ok {
+		return nil, fmt.Errorf("Unknown entity type %q", p.EntityType)
+	}
+
+	entitlement := Entitlement(p.Entitlement)
+	if !shared.StringInSlice(p.Entitlement, entitlementStrings(entitlements)) {
+		return nil, fmt.Errorf("Entitlement %q isn't valid for entity type %q", p.Entitlement, p.EntityType)
+	}
+
+	switch objectType {
+	case ObjectTypeServer:
+		if p.Project != "" || p.EntityName != "" {
+			return nil, fmt.Errorf("Server permissions can't have a project or entity name")
+		}
+
+	case ObjectTypeProject:
+		if p.Project == "" {
+			return nil, fmt.Errorf("Project permissions require a project")
+		}
+
+		if p.EntityName != "" {
+			return nil, fmt.Errorf("Project permissions can't have an entity name")
+		}
+
+	case ObjectTypeStorageVolume:
+		if p.Project == "" {
+			return nil, fmt.Errorf("Storage volume permissions require a project")
+		}
+
+		fields := strings.Split(p.EntityName, "/")
+		if len(fields) != 3 || fields[0] == "" || fields[1] == "" || fields[2] == "" {
+			return nil, fmt.Errorf("Storage volume names must be in the form <pool>/<type>/<volume>")
+		}
+
+	default:
+		if p.Project == "" || p.EntityName == "" {
+			return nil, fmt.Errorf("Permissions on %q require a project and an entity name", p.EntityType)
+		}
+	}
+
+	return &Permission{
+		Entitlement: entitlement,
+		Object:      Object{Type: objectType, Project: p.Project, Name: p.EntityName},
+	}, nil
+}
+
+func entitlementStrings(entitlements []Entitlement) []string {
+	result := make([]string, 0, len(entitlements))
+	for _, entitlement := range entitlements {
+		result = append(result, string(entitlement))
+	}
+
+	return result
+}
+
+// UserAccess represents the access granted to the requestor.
+type UserAccess struct {
+	Admin bool
+
+	// Projects maps project names to the legacy permissions granted on them by restricted
+	// certificates or RBAC.
+	Projects map[string][]string
+
+	// Permissions are the permissions granted through authorization groups.
+	Permissions []Permission
+}
+
+// Authorizer checks whether the requestor is allowed to access an object.
+type Authorizer interface {
+	// UserIsAdmin returns whether the requestor has full access to the server.
+	UserIsAdmin(r *http.Request) bool
+
+	// UserHasPermission returns whether the requestor has been granted the entitlement on the object.
+	UserHasPermission(r *http.Request, object Object, entitlement Entitlement) bool
+}
+
+// NewAuthorizer returns the built-in authorizer.
+// It relies on the UserAccess record stored in the request context when the request was authenticated.
+func NewAuthorizer() Authorizer {
+	return &authorizer{}
+}
+
+type authorizer struct{}
+
+// userAccess returns the access record of the request.
+func (a *authorizer) userAccess(r *http.Request) *UserAccess {
+	val := r.Context().Value(request.CtxAccess)
+	if val == nil {
+		return nil
+	}
+
+	ua, ok := val.(*UserAccess)
+	if !ok {
+		return nil
+	}
+
+	return ua
+}
+
+// UserIsAdmin returns whether the requestor has full access to the server.
+func (a *authorizer) UserIsAdmin(r *http.Request) bool {
+	ua := a.userAccess(r)
+	if ua == nil {
+		return false
+	}
+
+	return ua.Admin || ua.hasGrant(ObjectServer(), EntitlementAdmin)
+}
+
+// UserHasPermission returns whether the requestor has been granted the entitlement on the object.
+func (a *authorizer) UserHasPermission(r *http.Request, object Object, entitlement Entitlement) bool {
+	ua := a.userAccess(r)
+	if ua == nil {
+		return false
+	}
+
+	return ua.HasPermission(object, entitlement)
+}
+
+// HasPermission returns whether the entitlement on the object has been granted.
+func (ua *UserAccess) HasPermission(object Object, entitlement Entitlement) bool {
+	if ua.Admin || ua.hasGrant(ObjectServer(), EntitlementAdmin) {
+		return true
+	}
+
+	// Entitlements granted directly on the object.
+	if ua.hasGrant(object, entitlement) {
+		return true
+	}
+
+	if object.Type == ObjectTypeServer || object.Type == ObjectTypeProject {
+		return false
+	}
+
+	// Any entitlement on an entity allows viewing it and being allowed to edit it allows everything else.
+	for _, p := range ua.Permissions {
+		if p.Object != object {
+			continue
+		}
+
+		if entitlement == EntitlementCanView || p.Entitlement == EntitlementCanEdit {
+			return true
+		}
+	}
+
+	// Entitlements inherited from the project.
+	project := ObjectProject(object.Project)
+	if entitlement == EntitlementCanView && ua.hasGrant(project, EntitlementCanView) {
+		return true
+	}
+
+	switch object.Type {
+	case ObjectTypeInstance:
+		if entitlement == EntitlementCanView || entitlement == EntitlementCanEdit {
+			if ua.hasGrant(project, EntitlementCanManageInstances) {
+				return true
+			}
+		}
+
+		if entitlement != EntitlementCanEdit && ua.hasGrant(project, EntitlementCanOperateInstances) {
+			return true
+		}
+
+	case ObjectTypeNetwork:
+		return ua.hasGrant(project, EntitlementCanManageNetworks)
+
+	case ObjectTypeStorageVolume:
+		return ua.hasGrant(project, EntitlementCanManageStorageVolumes)
+	}
+
+	return false
+}
+
+// hasGrant returns whether the entitlement has been granted on the object itself.
+func (ua *UserAccess) hasGrant(object Object, entitlement Entitlement) bool {
+	if object.Type == ObjectTypeProject {
+		for _, permission := range ua.Projects[object.Project] {
+			if ProjectEntitlement(permission) == entitlement {
+				return true
+			}
+		}
+	}
+
+	for _, p := range ua.Permissions {
+		if p.Object == object && p.Entitlement == entitlement {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,77 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/auth"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Permissions are validated against the entity type they are granted on.
+func TestPermissionFromAPI(t *testing.T) {
+	cases := []struct {
+		permission api.AuthPermission
+		valid      bool
+	}{
+		{api.AuthPermission{EntityType: "server", Entitlement: "admin"}, true},
+		{api.AuthPermission{EntityType: "server", Entitlement: "admin", Project: "default"}, false},
+		{api.AuthPermission{EntityType: "project", Entitlement: "can_manage_instances", Project: "default"}, true},
+		{api.AuthPermission{EntityType: "project", Entitlement: "can_exec", Project: "default"}, false},
+		{api.AuthPermission{EntityType: "project", Entitlement: "can_view"}, false},
+		{api.AuthPermission{EntityType: "instance", Entitlement: "can_exec", Project: "default", EntityName: "c1"}, true},
+		{api.AuthPermission{EntityType: "instance", Entitlement: "can_exec", Project: "default"}, false},
+		{api.AuthPermission{EntityType: "network", Entitlement: "can_exec", Project: "default", EntityName: "lxdbr0"}, false},
+		{api.AuthPermission{EntityType: "storage_volume", Entitlement: "can_view", Project: "default", EntityName: "pool/custom/vol"}, true},
+		{api.AuthPermission{EntityType: "storage_volume", Entitlement: "can_view", Project: "default", EntityName: "vol"}, false},
+		{api.AuthPermission{EntityType: "image", Entitlement: "can_view", Project: "default", EntityName: "foo"}, false},
+	}
+
+	for _, c := range cases {
+		_, err := auth.PermissionFromAPI(c.permission)
+		if c.valid {
+			assert.NoError(t, err, "%+v", c.permission)
+		} else {
+			assert.Error(t, err, "%+v", c.permission)
+		}
+	}
+}
+
+// Entitlements are implied by broader grants on the same entity or its project.
+func TestUserAccess_HasPermission(t *testing.T) {
+	instance := auth.ObjectInstance("default", "c1")
+	other := auth.ObjectInstance("default", "c2")
+	volume := auth.ObjectStorageVolume("default", "pool", "custom", "vol")
+
+	grant := func(entityType string, entitlement string, projectName string, entityName string) auth.Permission {
+		p, err := auth.PermissionFromAPI(api.AuthPermission{EntityType: entityType, Entitlement: entitlement, Project: projectName, EntityName: entityName})
+		require.NoError(t, err)
+
+		return *p
+	}
+
+	ua := auth.UserAccess{Permissions: []auth.Permission{grant("instance", "can_exec", "default", "c1")}}
+	assert.True(t, ua.HasPermission(instance, auth.EntitlementCanExec))
+	assert.True(t, ua.HasPermission(instance, auth.EntitlementCanView))
+	assert.False(t, ua.HasPermission(instance, auth.EntitlementCanEdit))
+	assert.False(t, ua.HasPermission(other, auth.EntitlementCanView))
+	assert.False(t, ua.HasPermission(auth.ObjectProject("default"), auth.EntitlementCanView))
+
+	ua = auth.UserAccess{Permissions: []auth.Permission{grant("instance", "can_edit", "default", "c1")}}
+	assert.True(t, ua.HasPermission(instance, auth.EntitlementCanAccessConsole))
+
+	ua = auth.UserAccess{Permissions: []auth.Permission{grant("project", "can_operate_instances", "default", "")}}
+	assert.True(t, ua.HasPermission(other, auth.EntitlementCanExec))
+	assert.False(t, ua.HasPermission(other, auth.EntitlementCanEdit))
+	assert.False(t, ua.HasPermission(auth.ObjectInstance("p1", "c1"), auth.EntitlementCanExec))
+
+	ua = auth.UserAccess{Projects: map[string][]string{"default": {"manage-storage-volumes"}}}
+	assert.True(t, ua.HasPermission(volume, auth.EntitlementCanManageSnapshots))
+	assert.True(t, ua.HasPermission(auth.ObjectProject("default"), auth.EntitlementCanManageStorageVolumes))
+	assert.False(t, ua.HasPermission(instance, auth.EntitlementCanView))
+
+	ua = auth.UserAccess{Permissions: []auth.Permission{grant("server", "admin", "", "")}}
+	assert.True(t, ua.HasPermission(auth.ObjectProject("p1"), auth.EntitlementCanEdit))
+}
@@ -0,0 +1,526 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512" // Register SHA-384 and SHA-512 for the RS384/RS512/ES384/ES512 algorithms.
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeaderIssuer is the response header used to advertise the OIDC issuer to clients.
+const HeaderIssuer = "X-LXD-OIDC-issuer"
+
+// HeaderClientID is the response header used to advertise the OIDC client ID to clients.
+const HeaderClientID = "X-LXD-OIDC-clientid"
+
+// HeaderAudience is the response header used to advertise the OIDC audience to clients.
+const HeaderAudience = "X-LXD-OIDC-audience"
+
+// clockSkew is the tolerance applied when validating the token time claims.
+const clockSkew = time.Minute
+
+// keysRefreshInterval is the minimum time between two fetches of the issuer keys.
+const keysRefreshInterval = time.Minute
+
+// AuthError represents an authentication failure of an OIDC request.
+type AuthError struct {
+	Err error
+}
+
+// Error returns the error message.
+func (e AuthError) Error() string {
+	return fmt.Sprintf("Failed to authenticate: %v", e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e AuthError) Unwrap() error {
+	return e.Err
+}
+
+// Identity represents an authenticated OIDC identity.
+type Identity struct {
+	// Subject is the issuer specific identifier of the user.
+	Subject string
+
+	// Name is a human readable name for the user (email, username or subject).
+	Name string
+
+	// Fingerprint uniquely identifies the user across issuers.
+	Fingerprint string
+}
+
+// Verifier validates the bearer tokens issued by an OpenID Connect provider.
+type Verifier struct {
+	issuer   string
+	clientID string
+	audience string
+
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	jwksURI     string
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+type discovery struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+type jwk struct {
+	KeyID string `json:"kid"`
+	Type  string `json:"kty"`
+	Use   string `json:"use"`
+	N     string `json:"n"`
+	E     string `json:"e"`
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Issuer            string          `json:"iss"`
+	Subject           string          `json:"sub"`
+	Audience          json.RawMessage `json:"aud"`
+	Expiry            *float64        `json:"exp"`
+	NotBefore         *float64        `json:"nbf"`
+	Email             string          `json:"email"`
+	PreferredUsername string          `json:"preferred_username"`
+}
+
+// NewVerifier returns a Verifier for the given issuer.
+// The issuer is only contacted once the first token needs verifying.
+func NewVerifier(issuer string, clientID string, audience string, httpClient *http.Client) (*Verifier, error) {
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid OIDC issuer %q: %w", issuer, err)
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("Invalid OIDC issuer %q: Unsupported scheme", issuer)
+	}
+
+	if clientID == "" {
+		return nil, fmt.Errorf("Missing OIDC client ID")
+	}
+
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Verifier{
+		issuer:     strings.TrimSuffix(issuer, "/"),
+		clientID:   clientID,
+		audience:   audience,
+		httpClient: httpClient,
+	}, nil
+}
+
+// IsRequest returns whether the request carries an OIDC bearer token.
+// An empty token (trailing whitespace gets stripped from headers) is used by clients to discover the issuer.
+func (v *Verifier) IsRequest(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+
+	return auth == "Bearer" || strings.HasPrefix(auth, "Bearer ")
+}
+
+// WriteHeaders adds the headers the client needs to log in with the issuer.
+func (v *Verifier) WriteHeaders(w http.ResponseWriter) {
+	w.Header().Set(HeaderIssuer, v.issuer)
+	w.Header().Set(HeaderClientID, v.clientID)
+
+	if v.audience != "" {
+		w.Header().Set(HeaderAudience, v.audience)
+	}
+}
+
+// Fingerprint returns the identifier of the given subject for this issuer.
+func (v *Verifier) Fingerprint(subject string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(v.issuer+"\n"+subject)))
+}
+
+// Auth validates the bearer token of the request and returns the identity it was issued to.
+// Any validation failure is returned as an AuthError.
+func (v *Verifier) Auth(ctx context.Context, r *http.Request) (*Identity, error) {
+	token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer"))
+	if token == "" {
+		return nil, AuthError{Err: fmt.Errorf("Missing bearer token")}
+	}
+
+	claims, err := v.verify(ctx, token)
+	if err != nil {
+		return nil, AuthError{Err: err}
+	}
+
+	name := claims.Email
+	if name == "" {
+		name = claims.PreferredUsername
+	}
+
+	if name == "" {
+		name = claims.Subject
+	}
+
+	return &Identity{
+		Subject:     claims.Subject,
+		Name:        name,
+		Fingerprint: v.Fingerprint(claims.Subject),
+	}, nil
+}
+
+// verify checks the signature and the claims of a compact serialized JWT.
+func (v *Verifier) verify(ctx context.Context, token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("Malformed token")
+	}
+
+	header := jwtHeader{}
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid token header: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid token signature: %w", err)
+	}
+
+	key, err := v.key(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifySignature(header.Algorithm, key, []byte(parts[0]+"."+parts[1]), signature)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwtClaims{}
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid token claims: %w", err)
+	}
+
+	if strings.TrimSuffix(claims.Issuer, "/") != v.issuer {
+		return nil, fmt.Errorf("Token issued by unexpected issuer %q", claims.Issuer)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("Token is missing a subject")
+	}
+
+	now := time.Now()
+	if claims.Expiry == nil || now.After(time.Unix(int64(*claims.Expiry), 0).Add(clockSkew)) {
+		return nil, fmt.Errorf("Token has expired")
+	}
+
+	if claims.NotBefore != nil && now.Add(clockSkew).Before(time.Unix(int64(*claims.NotBefore), 0)) {
+		return nil, fmt.Errorf("Token isn't valid yet")
+	}
+
+	// The token must be intended for the configured audience (the API), or for the LXD client ID if none.
+	required := v.audience
+	if required == "" {
+		required = v.clientID
+	}
+
+	audiences, err := parseAudience(claims.Audience)
+	if err != nil {
+		return nil, err
+	}
+
+	found := false
+	for _, audience := range audiences {
+		if audience == required {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("Token isn't valid for audience %q", required)
+	}
+
+	return &claims, nil
+}
+
+// key returns the issuer public key with the given ID, refreshing the key set if needed.
+func (v *Verifier) key(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	lookup := func() crypto.PublicKey {
+		if keyID == "" && len(v.keys) == 1 {
+			for _, key := range v.keys {
+				return key
+			}
+		}
+
+		return v.keys[keyID]
+	}
+
+	key := lookup()
+	if key != nil {
+		return key, nil
+	}
+
+	// Unknown key, the issuer may have rotated its keys.
+	if time.Since(v.keysFetched) < keysRefreshInterval {
+		return nil, fmt.Errorf("Unknown signing key %q", keyID)
+	}
+
+	err := v.refreshKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key = lookup()
+	if key == nil {
+		return nil, fmt.Errorf("Unknown signing key %q", keyID)
+	}
+
+	return key, nil
+}
+
+// refreshKeys fetches the issuer key set. Must be called with the lock held.
+func (v *Verifier) refreshKeys(ctx context.Context) error {
+	v.keysFetched = time.Now()
+
+	if v.jwksURI == "" {
+		config := discovery{}
+		err := v.getJSON(ctx, v.issuer+"/.well-known/openid-configuration", &config)
+		if err != nil {
+			return fmt.Errorf("Failed OIDC discovery: %w", err)
+		}
+
+		if strings.TrimSuffix(config.Issuer, "/") != v.issuer {
+			return fmt.Errorf("OIDC discovery returned mismatching issuer %q", config.Issuer)
+		}
+
+		if config.JWKSURI == "" {
+			return fmt.Errorf("OIDC discovery didn't return a key set URL")
+		}
+
+		v.jwksURI = config.JWKSURI
+	}
+
+	keySet := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+
+	err := v.getJSON(ctx, v.jwksURI, &keySet)
+	if err != nil {
+		return fmt.Errorf("Failed fetching OIDC key set: %w", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range keySet.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			// Skip unsupported keys.
+			continue
+		}
+
+		keys[k.KeyID] = key
+	}
+
+	v.keys = keys
+
+	return nil
+}
+
+func (v *Verifier) getJSON(ctx context.Context, u string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unexpected response from %q: %s", u, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// publicKey converts the JSON web key into a crypto.PublicKey.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Type {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("Invalid RSA exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("Unsupported curve %q", k.Curve)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("Invalid EC key")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported key type %q", k.Type)
+}
+
+// verifySignature checks the JWS signature of the payload for the given algorithm.
+func verifySignature(algorithm string, key crypto.PublicKey, payload []byte, signature []byte) error {
+	var hash crypto.Hash
+	switch algorithm {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("Unsupported signing algorithm %q", algorithm)
+	}
+
+	h := hash.New()
+	h.Write(payload)
+	digest := h.Sum(nil)
+
+	switch algorithm[0] {
+	case 'R', 'P':
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("Signing key doesn't match algorithm %q", algorithm)
+		}
+
+		var err error
+		if algorithm[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+		} else {
+			err = rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+
+		if err != nil {
+			return fmt.Errorf("Invalid token signature")
+		}
+	case 'E':
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("Signing key doesn't match algorithm %q", algorithm)
+		}
+
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("Invalid token signature")
+		}
+
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return fmt.Errorf("Invalid token signature")
+		}
+	}
+
+	return nil
+}
+
+// parseAudience handles the "aud" claim being either a string or a list of strings.
+func parseAudience(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var single string
+	err := json.Unmarshal(raw, &single)
+	if err == nil {
+		return []string{single}, nil
+	}
+
+	var list []string
+	err = json.Unmarshal(raw, &list)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid token audience")
+	}
+
+	return list, nil
+}
+
+func decodeSegment(segment string, target interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, target)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("Empty value")
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
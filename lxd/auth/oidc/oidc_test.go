@@ -0,0 +1,185 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/auth/oidc"
+)
+
+type fakeIssuer struct {
+	*httptest.Server
+
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	i := &fakeIssuer{rsaKey: rsaKey, ecKey: ecKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"issuer": i.URL, "jwks_uri": i.URL + "/jwks"})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		encode := base64.RawURLEncoding.EncodeToString
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{
+				{"kid": "rsa", "kty": "RSA", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+				{"kid": "ec", "kty": "EC", "crv": "P-256", "x": encode(ecKey.X.Bytes()), "y": encode(ecKey.Y.Bytes())},
+			},
+		})
+	})
+
+	i.Server = httptest.NewServer(mux)
+
+	return i
+}
+
+func (i *fakeIssuer) token(t *testing.T, alg string, claims map[string]interface{}) string {
+	kid := "rsa"
+	if alg == "ES256" {
+		kid = "ec"
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid})
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	if alg == "ES256" {
+		r, s, err := ecdsa.Sign(rand.Reader, i.ecKey, digest[:])
+		require.NoError(t, err)
+
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	} else {
+		signature, err = rsa.SignPKCS1v15(rand.Reader, i.rsaKey, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (i *fakeIssuer) claims(subject string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":   i.URL,
+		"sub":   subject,
+		"aud":   []string{"lxd"},
+		"email": subject + "@example.com",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func authRequest(token string) *http.Request {
+	r := httptest.NewRequest("GET", "/1.0", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	return r
+}
+
+func TestVerifier_Auth(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.Close()
+
+	verifier, err := oidc.NewVerifier(issuer.URL, "device", "lxd", issuer.Client())
+	require.NoError(t, err)
+
+	for _, alg := range []string{"RS256", "ES256"} {
+		r := authRequest(issuer.token(t, alg, issuer.claims("user1")))
+		assert.True(t, verifier.IsRequest(r))
+
+		identity, err := verifier.Auth(context.Background(), r)
+		require.NoError(t, err, alg)
+		assert.Equal(t, "user1", identity.Subject)
+		assert.Equal(t, "user1@example.com", identity.Name)
+		assert.Equal(t, verifier.Fingerprint("user1"), identity.Fingerprint)
+	}
+
+	assert.NotEqual(t, verifier.Fingerprint("user1"), verifier.Fingerprint("user2"))
+}
+
+func TestVerifier_AuthInvalid(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.Close()
+
+	verifier, err := oidc.NewVerifier(issuer.URL, "device", "lxd", issuer.Client())
+	require.NoError(t, err)
+
+	expired := issuer.claims("user1")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	wrongIssuer := issuer.claims("user1")
+	wrongIssuer["iss"] = "https://example.com"
+
+	wrongAudience := issuer.claims("user1")
+	wrongAudience["aud"] = "other"
+
+	clientIDOnly := issuer.claims("user1")
+	clientIDOnly["aud"] = []string{"device"}
+
+	noSubject := issuer.claims("")
+
+	valid := issuer.token(t, "RS256", issuer.claims("user1"))
+
+	tokens := map[string]string{
+		"empty":          "",
+		"malformed":      "foo.bar",
+		"expired":        issuer.token(t, "RS256", expired),
+		"wrong issuer":   issuer.token(t, "RS256", wrongIssuer),
+		"wrong audience": issuer.token(t, "RS256", wrongAudience),
+		"client ID only": issuer.token(t, "RS256", clientIDOnly),
+		"no subject":     issuer.token(t, "ES256", noSubject),
+		"bad signature":  valid[:len(valid)-4] + "AAAA",
+	}
+
+	for name, token := range tokens {
+		_, err := verifier.Auth(context.Background(), authRequest(token))
+		assert.ErrorAs(t, err, &oidc.AuthError{}, name)
+	}
+
+	// Access tokens only carrying the configured audience (and not the client ID) are valid.
+	_, err = verifier.Auth(context.Background(), authRequest(valid))
+	assert.NoError(t, err)
+
+	// Without a configured audience, the client ID is required instead.
+	verifier, err = oidc.NewVerifier(issuer.URL, "device", "", issuer.Client())
+	require.NoError(t, err)
+
+	_, err = verifier.Auth(context.Background(), authRequest(issuer.token(t, "RS256", clientIDOnly)))
+	assert.NoError(t, err)
+
+	_, err = verifier.Auth(context.Background(), authRequest(valid))
+	assert.ErrorAs(t, err, &oidc.AuthError{})
+
+	// Requests without a bearer token aren't OIDC requests.
+	assert.False(t, verifier.IsRequest(httptest.NewRequest("GET", "/1.0", nil)))
+}
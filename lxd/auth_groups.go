@@ -0,0 +1,549 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/auth"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/lifecycle"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/request"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/version"
+)
+
+var authGroupsCmd = APIEndpoint{
+	Path: "auth/groups",
+
+	Get:  APIEndpointAction{Handler: authGroupsGet},
+	Post: APIEndpointAction{Handler: authGroupsPost},
+}
+
+var authGroupCmd = APIEndpoint{
+	Path: "auth/groups/{groupName}",
+
+	Delete: APIEndpointAction{Handler: authGroupDelete},
+	Get:    APIEndpointAction{Handler: authGroupGet},
+	Patch:  APIEndpointAction{Handler: authGroupPut},
+	Post:   APIEndpointAction{Handler: authGroupPost},
+	Put:    APIEndpointAction{Handler: authGroupPut},
+}
+
+// swagger:operation GET /1.0/auth/groups auth auth_groups_get
+//
+// Get the authorization groups
+//
+// Returns a list of authorization groups (URLs).
+//
+// ---
+// produces:
+//   - application/json
+// responses:
+//   "200":
+//     description: API endpoints
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           type: array
+//           description: List of endpoints
+//           items:
+//             type: string
+//           example: |-
+//             [
+//               "/1.0/auth/groups/operators",
+//               "/1.0/auth/groups/viewers"
+//             ]
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+
+// swagger:operation GET /1.0/auth/groups?recursion=1 auth auth_groups_get_recursion1
+//
+// Get the authorization groups
+//
+// Returns a list of authorization groups (structs).
+//
+// ---
+// produces:
+//   - application/json
+// responses:
+//   "200":
+//     description: API endpoints
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           type: array
+//           description: List of authorization groups
+//           items:
+//             $ref: "#/definitions/AuthGroup"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func authGroupsGet(d *Daemon, r *http.Request) response.Response {
+	recursion := util.IsRecursionRequest(r)
+
+	groupNames, err := d.cluster.GetAuthGroups()
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	resultString := []string{}
+	resultMap := []api.AuthGroup{}
+	for _, groupName := range groupNames {
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/auth/groups/%s", version.APIVersion, url.PathEscape(groupName)))
+		} else {
+			_, group, err := d.cluster.GetAuthGroup(groupName)
+			if err != nil {
+				return response.SmartError(err)
+			}
+
+			resultMap = append(resultMap, *group)
+		}
+	}
+
+	if !recursion {
+		return response.SyncResponse(true, resultString)
+	}
+
+	return response.SyncResponse(true, resultMap)
+}
+
+// swagger:operation POST /1.0/auth/groups auth auth_groups_post
+//
+// Add an authorization group
+//
+// Creates a new authorization group.
+//
+// ---
+// consumes:
+//   - application/json
+// produces:
+//   - application/json
+// parameters:
+//   - in: body
+//     name: group
+//     description: Group
+//     required: true
+//     schema:
+//       $ref: "#/definitions/AuthGroupsPost"
+// responses:
+//   "200":
+//     $ref: "#/responses/EmptySyncResponse"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func authGroupsPost(d *Daemon, r *http.Request) response.Response {
+	req := api.AuthGroupsPost{}
+
+	// Parse the request.
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	// Quick checks.
+	err = authGroupValidateName(req.Name)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = authGroupValidate(&req.AuthGroupPut)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	_, _, err = d.cluster.GetAuthGroup(req.Name)
+	if err == nil {
+		return response.BadRequest(fmt.Errorf("The authorization group already exists"))
+	} else if !errors.Is(err, db.ErrNoSuchObject) {
+		return response.SmartError(err)
+	}
+
+	_, err = d.cluster.CreateAuthGroup(&req)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	d.State().Events.SendLifecycle(project.Default, lifecycle.AuthGroupCreated.Event(req.Name, request.CreateRequestor(r), nil))
+
+	return response.SyncResponseLocation(true, nil, fmt.Sprintf("/%s/auth/groups/%s", version.APIVersion, url.PathEscape(req.Name)))
+}
+
+// swagger:operation GET /1.0/auth/groups/{groupName} auth auth_group_get
+//
+// Get the authorization group
+//
+// Gets a specific authorization group.
+//
+// ---
+// produces:
+//   - application/json
+// responses:
+//   "200":
+//     description: Group
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           $ref: "#/definitions/AuthGroup"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "404":
+//     $ref: "#/responses/NotFound"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func authGroupGet(d *Daemon, r *http.Request) response.Response {
+	groupName, err := url.PathUnescape(mux.Vars(r)["groupName"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	_, group, err := d.cluster.GetAuthGroup(groupName)
+	if err != nil {
+		if errors.Is(err, db.ErrNoSuchObject) {
+			return response.NotFound(fmt.Errorf("Authorization group %q not found", groupName))
+		}
+
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponseETag(true, group, group.AuthGroupPut)
+}
+
+// swagger:operation POST /1.0/auth/groups/{groupName} auth auth_group_post
+//
+// Rename the authorization group
+//
+// Renames an existing authorization group.
+//
+// ---
+// consumes:
+//   - application/json
+// produces:
+//   - application/json
+// parameters:
+//   - in: body
+//     name: group
+//     description: Group rename request
+//     required: true
+//     schema:
+//       $ref: "#/definitions/AuthGroupPost"
+// responses:
+//   "200":
+//     $ref: "#/responses/EmptySyncResponse"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "404":
+//     $ref: "#/responses/NotFound"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func authGroupPost(d *Daemon, r *http.Request) response.Response {
+	groupName, err := url.PathUnescape(mux.Vars(r)["groupName"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	req := api.AuthGroupPost{}
+
+	// Parse the request.
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	// Quick checks.
+	err = authGroupValidateName(req.Name)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	id, _, err := d.cluster.GetAuthGroup(groupName)
+	if err != nil {
+		if errors.Is(err, db.ErrNoSuchObject) {
+			return response.NotFound(fmt.Errorf("Authorization group %q not found", groupName))
+		}
+
+		return response.SmartError(err)
+	}
+
+	// Check that the name isn't already in use.
+	_, _, err = d.cluster.GetAuthGroup(req.Name)
+	if err == nil {
+		return response.BadRequest(fmt.Errorf("Name %q already in use", req.Name))
+	}
+
+	err = d.cluster.RenameAuthGroup(id, req.Name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	d.State().Events.SendLifecycle(project.Default, lifecycle.AuthGroupRenamed.Event(req.Name, request.CreateRequestor(r), log.Ctx{"old_name": groupName}))
+
+	return response.SyncResponseLocation(true, nil, fmt.Sprintf("/%s/auth/groups/%s", version.APIVersion, url.PathEscape(req.Name)))
+}
+
+// swagger:operation PATCH /1.0/auth/groups/{groupName} auth auth_group_patch
+//
+// Partially update the authorization group
+//
+// Updates a subset of the authorization group configuration.
+//
+// ---
+// consumes:
+//   - application/json
+// produces:
+//   - application/json
+// parameters:
+//   - in: body
+//     name: group
+//     description: Group configuration
+//     required: true
+//     schema:
+//       $ref: "#/definitions/AuthGroupPut"
+// responses:
+//   "200":
+//     $ref: "#/responses/EmptySyncResponse"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "404":
+//     $ref: "#/responses/NotFound"
+//   "412":
+//     $ref: "#/responses/PreconditionFailed"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+
+// swagger:operation PUT /1.0/auth/groups/{groupName} auth auth_group_put
+//
+// Update the authorization group
+//
+// Updates the entire authorization group configuration.
+//
+// ---
+// consumes:
+//   - application/json
+// produces:
+//   - application/json
+// parameters:
+//   - in: body
+//     name: group
+//     description: Group configuration
+//     required: true
+//     schema:
+//       $ref: "#/definitions/AuthGroupPut"
+// responses:
+//   "200":
+//     $ref: "#/responses/EmptySyncResponse"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "404":
+//     $ref: "#/responses/NotFound"
+//   "412":
+//     $ref: "#/responses/PreconditionFailed"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func authGroupPut(d *Daemon, r *http.Request) response.Response {
+	groupName, err := url.PathUnescape(mux.Vars(r)["groupName"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Get the existing group.
+	id, group, err := d.cluster.GetAuthGroup(groupName)
+	if err != nil {
+		if errors.Is(err, db.ErrNoSuchObject) {
+			return response.NotFound(fmt.Errorf("Authorization group %q not found", groupName))
+		}
+
+		return response.SmartError(err)
+	}
+
+	// Validate the ETag.
+	err = util.EtagCheck(r, group.AuthGroupPut)
+	if err != nil {
+		return response.PreconditionFailed(err)
+	}
+
+	// For PATCH, fields missing from the request keep their current value.
+	req := api.AuthGroupPut{}
+	if r.Method == http.MethodPatch {
+		req = group.Writable()
+	}
+
+	// Parse the request.
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = authGroupValidate(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = d.cluster.UpdateAuthGroup(id, &req)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	d.State().Events.SendLifecycle(project.Default, lifecycle.AuthGroupUpdated.Event(groupName, request.CreateRequestor(r), nil))
+
+	return response.EmptySyncResponse
+}
+
+// swagger:operation DELETE /1.0/auth/groups/{groupName} auth auth_group_delete
+//
+// Delete the authorization group
+//
+// Removes the authorization group.
+//
+// ---
+// produces:
+//   - application/json
+// responses:
+//   "200":
+//     $ref: "#/responses/EmptySyncResponse"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "404":
+//     $ref: "#/responses/NotFound"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func authGroupDelete(d *Daemon, r *http.Request) response.Response {
+	groupName, err := url.PathUnescape(mux.Vars(r)["groupName"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	id, _, err := d.cluster.GetAuthGroup(groupName)
+	if err != nil {
+		if errors.Is(err, db.ErrNoSuchObject) {
+			return response.NotFound(fmt.Errorf("Authorization group %q not found", groupName))
+		}
+
+		return response.SmartError(err)
+	}
+
+	err = d.cluster.DeleteAuthGroup(id)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	d.State().Events.SendLifecycle(project.Default, lifecycle.AuthGroupDeleted.Event(groupName, request.CreateRequestor(r), nil))
+
+	return response.EmptySyncResponse
+}
+
+// authGroupValidateName checks the name of an authorization group.
+func authGroupValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("No name provided")
+	}
+
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("Authorization group names may not contain slashes")
+	}
+
+	if shared.StringInSlice(name, []string{".", ".."}) {
+		return fmt.Errorf("Invalid authorization group name %q", name)
+	}
+
+	return nil
+}
+
+// authGroupValidate checks the permissions and identities of an authorization group.
+func authGroupValidate(req *api.AuthGroupPut) error {
+	if req.Permissions == nil {
+		req.Permissions = []api.AuthPermission{}
+	}
+
+	if req.Identities == nil {
+		req.Identities = []string{}
+	}
+
+	for i, p := range req.Permissions {
+		_, err := auth.PermissionFromAPI(p)
+		if err != nil {
+			return err
+		}
+
+		for _, other := range req.Permissions[:i] {
+			if other == p {
+				return fmt.Errorf("Duplicate permission %q on %s %q", p.Entitlement, p.EntityType, strings.Trim(p.Project+"/"+p.EntityName, "/"))
+			}
+		}
+	}
+
+	for i, fingerprint := range req.Identities {
+		if shared.StringInSlice(fingerprint, req.Identities[:i]) {
+			return fmt.Errorf("Duplicate identity %q", fingerprint)
+		}
+	}
+
+	return nil
+}
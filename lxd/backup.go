@@ -4,15 +4,24 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"context"
 
+	"github.com/flosch/pongo2"
+	"github.com/pborman/uuid"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 
 	"github.com/lxc/lxd/lxd/backup"
+	backupTarget "github.com/lxc/lxd/lxd/backup/target"
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/instance"
@@ -20,6 +29,7 @@ import (
 	"github.com/lxc/lxd/lxd/lifecycle"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/revert"
 	"github.com/lxc/lxd/lxd/state"
 	storagePools "github.com/lxc/lxd/lxd/storage"
@@ -35,8 +45,9 @@ import (
 )
 
 // Create a new backup.
-func backupCreate(s *state.State, args db.InstanceBackup, sourceInst instance.Instance) error {
-	logger := logging.AddContext(logger.Log, log.Ctx{"project": sourceInst.Project(), "instance": sourceInst.Name(), "name": args.Name})
+// If parentName is set, an incremental backup containing only the changes since that backup is created.
+func backupCreate(ctx context.Context, s *state.State, args db.InstanceBackup, sourceInst instance.Instance, parentName string) error {
+	logger := logging.AddContext(logger.Log, log.Ctx{"project": sourceInst.Project(), "instance": sourceInst.Name(), "name": args.Name, "parent": parentName})
 	logger.Debug("Instance backup started")
 	defer logger.Debug("Instance backup finished")
 
@@ -49,6 +60,37 @@ func backupCreate(s *state.State, args db.InstanceBackup, sourceInst instance.In
 		return errors.Wrap(err, "Failed loading instance storage pool")
 	}
 
+	// Load the parent backup of incremental backups.
+	var parent *backup.Parent
+	if parentName != "" {
+		parentBackup, err := instance.BackupLoadByName(s, sourceInst.Project(), sourceInst.Name()+shared.SnapshotDelimiter+parentName)
+		if err != nil {
+			return errors.Wrapf(err, "Failed loading parent backup %q", parentName)
+		}
+
+		// Incremental backups use the same format as their parent.
+		args.InstanceOnly = parentBackup.InstanceOnly()
+		args.OptimizedStorage = parentBackup.OptimizedStorage()
+
+		parentPath := shared.VarPath("backups", "instances", project.Instance(sourceInst.Project(), parentBackup.Name()))
+		parentFile, err := os.Open(parentPath)
+		if err != nil {
+			return errors.Wrapf(err, "Failed opening parent backup %q", parentName)
+		}
+		defer parentFile.Close()
+
+		parentInfo, err := backup.GetInfo(parentFile)
+		if err != nil {
+			return errors.Wrapf(err, "Failed reading parent backup %q", parentName)
+		}
+
+		if parentInfo.Pool != pool.Name() {
+			return fmt.Errorf("Parent backup %q was taken on a different storage pool", parentName)
+		}
+
+		parent = &backup.Parent{Name: parentName, Info: parentInfo, Data: parentFile}
+	}
+
 	// Ignore requests for optimized backups when pool driver doesn't support it.
 	if args.OptimizedStorage && !pool.Driver().Info().OptimizedBackups {
 		args.OptimizedStorage = false
@@ -129,6 +171,7 @@ func backupCreate(s *state.State, args db.InstanceBackup, sourceInst instance.In
 	// Create the tarball.
 	tarPipeReader, tarPipeWriter := io.Pipe()
 	defer tarPipeWriter.Close() // Ensure that go routine below always ends.
+	defer backupAbortOnCancel(ctx, tarPipeReader)()
 	tarWriter := instancewriter.NewInstanceTarWriter(tarPipeWriter, idmap)
 
 	// Setup tar writer go routine, with optional compression.
@@ -151,9 +194,43 @@ func backupCreate(s *state.State, args db.InstanceBackup, sourceInst instance.In
 		resCh <- err
 	}(tarWriterRes)
 
+	// Running VMs which have their guest filesystems frozen for snapshots are exported from a temporary snapshot,
+	// so that the filesystems are only frozen while the snapshot is taken rather than for the whole export.
+	var fromSnapshot, consistency string
+	deleteSnapshot := func() {}
+	defer func() { deleteSnapshot() }()
+
+	if sourceInst.Type() == instancetype.VM && sourceInst.IsRunning() && sourceInst.ExpandedConfig()["snapshots.consistency"] == "filesystem" {
+		fromSnapshot = fmt.Sprintf("backup-%s", uuid.New())
+		logger.Debug("Creating temporary snapshot for backup", log.Ctx{"snapshot": fromSnapshot})
+		err = sourceInst.Snapshot(fromSnapshot, time.Time{}, false)
+		if err != nil {
+			return errors.Wrapf(err, "Failed creating temporary snapshot for backup")
+		}
+
+		snap, err := instance.LoadByProjectAndName(s, sourceInst.Project(), sourceInst.Name()+shared.SnapshotDelimiter+fromSnapshot)
+		if err != nil {
+			return errors.Wrapf(err, "Failed loading temporary snapshot for backup")
+		}
+
+		deleteSnapshot = func() {
+			err := snap.Delete(true)
+			if err != nil {
+				logger.Error("Failed deleting temporary snapshot for backup", log.Ctx{"snapshot": fromSnapshot, "err": err})
+			}
+		}
+
+		consistency = snap.LocalConfig()["volatile.snapshot.consistency"]
+	}
+
 	// Write index file.
 	logger.Debug("Adding backup index file")
-	err = backupWriteIndex(sourceInst, pool, b.OptimizedStorage(), !b.InstanceOnly(), tarWriter)
+	var parents []string
+	if parent != nil {
+		parents = append(append(parents, parent.Info.Parents...), parent.Name)
+	}
+
+	err = backupWriteIndex(sourceInst, pool, b.OptimizedStorage(), !b.InstanceOnly(), fromSnapshot, consistency, parents, tarWriter)
 
 	// Check compression errors.
 	if compressErr != nil {
@@ -165,7 +242,9 @@ func backupCreate(s *state.State, args db.InstanceBackup, sourceInst instance.In
 		return errors.Wrapf(err, "Error writing backup index file")
 	}
 
-	err = pool.BackupInstance(sourceInst, tarWriter, b.OptimizedStorage(), !b.InstanceOnly(), nil)
+	err = pool.BackupInstance(sourceInst, tarWriter, b.OptimizedStorage(), !b.InstanceOnly(), fromSnapshot, parent, nil)
+	deleteSnapshot()
+	deleteSnapshot = func() {}
 	if err != nil {
 		return errors.Wrap(err, "Backup create")
 	}
@@ -187,14 +266,76 @@ func backupCreate(s *state.State, args db.InstanceBackup, sourceInst instance.In
 		return errors.Wrap(err, "Error writing tarball")
 	}
 
+	// Upload the finished tarball to the backups target (if any).
+	err = backup.UploadToTarget(s, sourceInst.Project(), backupTarget.InstanceBackupName(sourceInst.Project(), b.Name()), target)
+	if err != nil {
+		return err
+	}
+
 	revert.Success()
 	s.Events.SendLifecycle(sourceInst.Project(), lifecycle.InstanceBackupCreated.Event(args.Name, b.Instance(), nil))
 
 	return nil
 }
 
+// backupOpenParents opens the tarballs of the backups an incremental backup of the named instance is based on
+// and sets them in the backup info. Tarballs which aren't found locally are retrieved from the backups target.
+// Returns a function that closes and cleans up the opened tarballs.
+func backupOpenParents(s *state.State, projectName string, instanceName string, info *backup.Info) (func(), error) {
+	var files []*os.File
+	var tmpFiles []string
+	cleanup := func() {
+		for _, f := range files {
+			f.Close()
+		}
+
+		for _, path := range tmpFiles {
+			os.Remove(path)
+		}
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+	revert.Add(cleanup)
+
+	info.ParentsData = make([]io.ReadSeeker, 0, len(info.Parents))
+	for _, parentName := range info.Parents {
+		fullName := instanceName + shared.SnapshotDelimiter + parentName
+
+		f, err := os.Open(shared.VarPath("backups", "instances", project.Instance(projectName, fullName)))
+		if os.IsNotExist(err) {
+			f, err = ioutil.TempFile(shared.VarPath("backups"), fmt.Sprintf("%s_parent_", backup.WorkingDirPrefix))
+			if err != nil {
+				return nil, err
+			}
+
+			tmpFiles = append(tmpFiles, f.Name())
+			files = append(files, f)
+
+			err = backup.DownloadFromTarget(s, projectName, backupTarget.InstanceBackupName(projectName, fullName), f)
+			if err == backupTarget.ErrNotFound {
+				return nil, fmt.Errorf("Parent backup %q of incremental backup not found", parentName)
+			}
+		} else if err == nil {
+			files = append(files, f)
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed opening parent backup %q", parentName)
+		}
+
+		info.ParentsData = append(info.ParentsData, f)
+	}
+
+	revert.Success()
+	return cleanup, nil
+}
+
 // backupWriteIndex generates an index.yaml file and then writes it to the root of the backup tarball.
-func backupWriteIndex(sourceInst instance.Instance, pool storagePools.Pool, optimized bool, snapshots bool, tarWriter *instancewriter.InstanceTarWriter) error {
+// For backups exported from a temporary snapshot, fromSnapshot is the snapshot name (which isn't listed in the
+// index) and consistency the consistency achieved when taking it.
+// For incremental backups, parents lists the backups (oldest first) the backup is based on.
+func backupWriteIndex(sourceInst instance.Instance, pool storagePools.Pool, optimized bool, snapshots bool, fromSnapshot string, consistency string, parents []string, tarWriter *instancewriter.InstanceTarWriter) error {
 	// Indicate whether the driver will include a driver-specific optimized header.
 	poolDriverOptimizedHeader := false
 	if optimized {
@@ -214,6 +355,8 @@ func backupWriteIndex(sourceInst instance.Instance, pool storagePools.Pool, opti
 		Type:             backupType,
 		OptimizedStorage: &optimized,
 		OptimizedHeader:  &poolDriverOptimizedHeader,
+		Parents:          parents,
+		Consistency:      consistency,
 	}
 
 	if snapshots {
@@ -224,6 +367,10 @@ func backupWriteIndex(sourceInst instance.Instance, pool storagePools.Pool, opti
 
 		for _, snap := range snaps {
 			_, snapName, _ := shared.InstanceGetParentAndSnapshotName(snap.Name())
+			if snapName == fromSnapshot {
+				continue
+			}
+
 			indexInfo.Snapshots = append(indexInfo.Snapshots, snapName)
 		}
 	}
@@ -295,23 +442,144 @@ func pruneExpiredContainerBackups(ctx context.Context, d *Daemon) error {
 		return errors.Wrap(err, "Unable to retrieve the list of expired instance backups")
 	}
 
-	for _, b := range backups {
-		inst, err := instance.LoadByID(d.State(), b.InstanceID)
+	// Delete the backups over several passes so that incremental backups go before the backups they're based on.
+	// The expired backups that unexpired incremental backups are still based on are kept.
+	for len(backups) > 0 {
+		kept := []db.InstanceBackup{}
+
+		for _, b := range backups {
+			inst, err := instance.LoadByID(d.State(), b.InstanceID)
+			if err != nil {
+				return errors.Wrapf(err, "Error loading instance for deleting backup %q", b.Name)
+			}
+
+			instBackup := backup.NewInstanceBackup(d.State(), inst, b.ID, b.Name, b.CreationDate, b.ExpiryDate, b.InstanceOnly, b.OptimizedStorage)
+
+			children, err := instBackup.Children()
+			if err != nil {
+				return errors.Wrapf(err, "Error checking incremental backups of %q", b.Name)
+			}
+
+			if len(children) > 0 {
+				kept = append(kept, b)
+				continue
+			}
+
+			err = instBackup.Delete()
+			if err != nil {
+				return errors.Wrapf(err, "Error deleting instance backup %q", b.Name)
+			}
+		}
+
+		// Stop once no more backups can be deleted.
+		if len(kept) == len(backups) {
+			break
+		}
+
+		backups = kept
+	}
+
+	return nil
+}
+
+func pruneExpiredCustomVolumeBackupsTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		opRun := func(op *operations.Operation) error {
+			return pruneExpiredCustomVolumeBackups(ctx, d)
+		}
+
+		op, err := operations.OperationCreate(d.State(), "", operations.OperationClassTask, db.OperationCustomVolumeBackupsExpire, nil, nil, opRun, nil, nil, nil)
+		if err != nil {
+			logger.Error("Failed to start expired volume backups operation", log.Ctx{"err": err})
+			return
+		}
+
+		logger.Info("Pruning expired volume backups")
+		_, err = op.Run()
+		if err != nil {
+			logger.Error("Failed to expire volume backups", log.Ctx{"err": err})
+		}
+		logger.Info("Done pruning expired volume backups")
+	}
+
+	f(context.Background())
+
+	first := true
+	schedule := func() (time.Duration, error) {
+		interval := time.Hour
+
+		if first {
+			first = false
+			return interval, task.ErrSkip
+		}
+
+		return interval, nil
+	}
+
+	return f, schedule
+}
+
+func pruneExpiredCustomVolumeBackups(ctx context.Context, d *Daemon) error {
+	// Get the list of expired backups.
+	backups, err := d.cluster.GetExpiredStorageVolumeBackups()
+	if err != nil {
+		return errors.Wrap(err, "Unable to retrieve the list of expired volume backups")
+	}
+
+	if len(backups) == 0 {
+		return nil
+	}
+
+	allVolumes, err := d.cluster.GetStoragePoolVolumesWithType(db.StoragePoolVolumeTypeCustom)
+	if err != nil {
+		return errors.Wrap(err, "Failed getting custom volumes")
+	}
+
+	// Only consider volumes on this member, and remote volumes for which this member is responsible.
+	localNodeID := d.cluster.GetNodeID()
+
+	var volumes, remoteVolumes []db.StorageVolumeArgs
+	for _, v := range allVolumes {
+		if v.NodeID == localNodeID {
+			volumes = append(volumes, v)
+		} else if v.NodeID < 0 {
+			remoteVolumes = append(remoteVolumes, v)
+		}
+	}
+
+	if len(remoteVolumes) > 0 {
+		remoteVolumes, err = customVolumesForLocalMember(d, remoteVolumes)
 		if err != nil {
-			return errors.Wrapf(err, "Error loading instance for deleting backup %q", b.Name)
+			logger.Error("Skipping remote volumes for expired volume backups", log.Ctx{"err": err})
+		}
+
+		volumes = append(volumes, remoteVolumes...)
+	}
+
+	volumesByID := make(map[int64]db.StorageVolumeArgs, len(volumes))
+	for _, v := range volumes {
+		volumesByID[v.ID] = v
+	}
+
+	for _, b := range backups {
+		v, found := volumesByID[b.VolumeID]
+		if !found {
+			continue
 		}
 
-		instBackup := backup.NewInstanceBackup(d.State(), inst, b.ID, b.Name, b.CreationDate, b.ExpiryDate, b.InstanceOnly, b.OptimizedStorage)
-		err = instBackup.Delete()
+		volBackup := backup.NewVolumeBackup(d.State(), v.ProjectName, v.PoolName, v.Name, b.ID, b.Name, b.CreationDate, b.ExpiryDate, b.VolumeOnly, b.OptimizedStorage)
+		err = volBackup.Delete()
 		if err != nil {
-			return errors.Wrapf(err, "Error deleting instance backup %q", b.Name)
+			return errors.Wrapf(err, "Error deleting volume backup %q", b.Name)
 		}
+
+		d.State().Events.SendLifecycle(v.ProjectName, lifecycle.StorageVolumeBackupDeleted.Event(v.PoolName, db.StoragePoolVolumeTypeNameCustom, b.Name, v.ProjectName, nil, nil))
 	}
 
 	return nil
 }
 
-func volumeBackupCreate(s *state.State, args db.StoragePoolVolumeBackup, projectName string, poolName string, volumeName string) error {
+func volumeBackupCreate(ctx context.Context, s *state.State, args db.StoragePoolVolumeBackup, projectName string, poolName string, volumeName string) error {
 	logger := logging.AddContext(logger.Log, log.Ctx{"project": projectName, "storage_volume": volumeName, "name": args.Name})
 	logger.Debug("Volume backup started")
 	defer logger.Debug("Volume backup finished")
@@ -391,6 +659,7 @@ func volumeBackupCreate(s *state.State, args db.StoragePoolVolumeBackup, project
 	// Create the tarball.
 	tarPipeReader, tarPipeWriter := io.Pipe()
 	defer tarPipeWriter.Close() // Ensure that go routine below always ends.
+	defer backupAbortOnCancel(ctx, tarPipeReader)()
 	tarWriter := instancewriter.NewInstanceTarWriter(tarPipeWriter, nil)
 
 	// Setup tar writer go routine, with optional compression.
@@ -449,10 +718,32 @@ func volumeBackupCreate(s *state.State, args db.StoragePoolVolumeBackup, project
 		return errors.Wrap(err, "Error writing tarball")
 	}
 
+	// Upload the finished tarball to the backups target (if any).
+	err = backup.UploadToTarget(s, projectName, backupTarget.VolumeBackupName(projectName, pool.Name(), backupRow.Name), target)
+	if err != nil {
+		return err
+	}
+
 	revert.Success()
 	return nil
 }
 
+// backupAbortOnCancel closes the tarball pipe reader when the context is cancelled, making the export fail.
+// The returned function must be called once the export is finished.
+func backupAbortOnCancel(ctx context.Context, tarPipeReader *io.PipeReader) func() {
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			tarPipeReader.CloseWithError(ctx.Err())
+		case <-done:
+		}
+	}()
+
+	return func() { close(done) }
+}
+
 // volumeBackupWriteIndex generates an index.yaml file and then writes it to the root of the backup tarball.
 func volumeBackupWriteIndex(s *state.State, projectName string, vol *api.StorageVolume, pool storagePools.Pool, optimized bool, snapshots bool, tarWriter *instancewriter.InstanceTarWriter) error {
 	if vol.Type != db.StoragePoolVolumeTypeNameCustom {
@@ -536,3 +827,517 @@ func volumeBackupWriteIndex(s *state.State, projectName string, vol *api.Storage
 
 	return nil
 }
+
+// backupNextName renders the backup name pattern and returns the next backup name that doesn't conflict with any
+// of the existing backup names supplied (in the form "<parent>/<backup>").
+func backupNextName(pattern string, backupNames []string) (string, error) {
+	pattern, err := shared.RenderTemplate(pattern, pongo2.Context{
+		"creation_date": time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	count := strings.Count(pattern, "%d")
+	if count > 1 {
+		return "", fmt.Errorf("Backup pattern may contain '%%d' only once")
+	}
+
+	existing := make(map[string]struct{}, len(backupNames))
+	for _, backupName := range backupNames {
+		_, backupOnlyName, _ := shared.InstanceGetParentAndSnapshotName(backupName)
+		existing[backupOnlyName] = struct{}{}
+	}
+
+	if count == 0 {
+		_, found := existing[pattern]
+		if !found {
+			return pattern, nil
+		}
+
+		// Append '-0', '-1', etc. if the actual pattern/backup name already exists.
+		pattern = fmt.Sprintf("%s-%%d", pattern)
+	}
+
+	fields := strings.SplitN(pattern, "%d", 2)
+	prefix, suffix := fields[0], fields[1]
+
+	next := 0
+	for backupOnlyName := range existing {
+		if len(backupOnlyName) <= len(prefix)+len(suffix) || !strings.HasPrefix(backupOnlyName, prefix) || !strings.HasSuffix(backupOnlyName, suffix) {
+			continue
+		}
+
+		num, err := strconv.Atoi(backupOnlyName[len(prefix) : len(backupOnlyName)-len(suffix)])
+		if err != nil {
+			continue
+		}
+
+		if num >= next {
+			next = num + 1
+		}
+	}
+
+	return strings.Replace(pattern, "%d", strconv.Itoa(next), 1), nil
+}
+
+// backupKeep returns the number of backups to keep as set in the backups.keep key (0 means no limit).
+func backupKeep(config map[string]string) (int, error) {
+	if config["backups.keep"] == "" {
+		return 0, nil
+	}
+
+	keep, err := strconv.ParseUint(config["backups.keep"], 10, 32)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Invalid backups.keep value")
+	}
+
+	return int(keep), nil
+}
+
+func autoCreateInstanceBackupsTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		// Get projects that allow backups.
+		var projectNames []string
+		err := d.State().Cluster.Transaction(func(tx *db.ClusterTx) error {
+			projects, err := tx.GetProjects(db.ProjectFilter{})
+			if err != nil {
+				return fmt.Errorf("Failed loading projects: %w", err)
+			}
+
+			for _, p := range projects {
+				err = project.AllowBackupCreation(tx, p.Name)
+				if err != nil {
+					continue
+				}
+
+				projectNames = append(projectNames, p.Name)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return
+		}
+
+		// Load local instances by project.
+		allInstances := []instance.Instance{}
+		for _, projectName := range projectNames {
+			projectInstances, err := instanceLoadNodeProjectAll(d.State(), projectName, instancetype.Any)
+			if err != nil {
+				continue
+			}
+
+			allInstances = append(allInstances, projectInstances...)
+		}
+
+		// Figure out which need backing up (if any).
+		instances := []instance.Instance{}
+		for _, inst := range allInstances {
+			schedule, ok := inst.ExpandedConfig()["backups.schedule"]
+			if !ok || schedule == "" {
+				continue
+			}
+
+			// Check if backup is scheduled.
+			if !snapshotIsScheduledNow(schedule, int64(inst.ID())) {
+				continue
+			}
+
+			instances = append(instances, inst)
+		}
+
+		if len(instances) == 0 {
+			return
+		}
+
+		opRun := func(op *operations.Operation) error {
+			return autoCreateInstanceBackups(ctx, d, instances)
+		}
+
+		op, err := operations.OperationCreate(d.State(), "", operations.OperationClassTask, db.OperationBackupCreate, nil, nil, opRun, nil, nil, nil)
+		if err != nil {
+			logger.Error("Failed to start create instance backups operation", log.Ctx{"err": err})
+			return
+		}
+
+		logger.Info("Creating scheduled instance backups")
+
+		_, err = op.Run()
+		if err != nil {
+			logger.Error("Failed to create scheduled instance backups", log.Ctx{"err": err})
+		}
+
+		logger.Info("Done creating scheduled instance backups")
+	}
+
+	first := true
+	schedule := func() (time.Duration, error) {
+		interval := time.Minute
+
+		if first {
+			first = false
+			return interval, task.ErrSkip
+		}
+
+		return interval, nil
+	}
+
+	return f, schedule
+}
+
+func autoCreateInstanceBackups(ctx context.Context, d *Daemon, instances []instance.Instance) error {
+	// Make the backups sequentially. The backups are aborted if the task context is cancelled (on shutdown).
+	for _, inst := range instances {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// Reload the instance to skip it if its backup schedule was unset since the task started.
+		inst, err := instance.LoadByProjectAndName(d.State(), inst.Project(), inst.Name())
+		if err != nil || inst.ExpandedConfig()["backups.schedule"] == "" {
+			continue
+		}
+
+		err = autoCreateInstanceBackup(ctx, d.State(), inst)
+		if err != nil {
+			logger.Error("Error creating scheduled instance backup", log.Ctx{"err": err, "project": inst.Project(), "instance": inst.Name()})
+		}
+	}
+
+	return nil
+}
+
+// autoCreateInstanceBackup creates a scheduled backup of the instance and then removes the oldest backups that
+// exceed the backups.keep limit.
+func autoCreateInstanceBackup(ctx context.Context, s *state.State, inst instance.Instance) error {
+	config := inst.ExpandedConfig()
+
+	keep, err := backupKeep(config)
+	if err != nil {
+		return err
+	}
+
+	backups, err := inst.Backups()
+	if err != nil {
+		return err
+	}
+
+	backupNames := make([]string, 0, len(backups))
+	for _, b := range backups {
+		backupNames = append(backupNames, b.Name())
+	}
+
+	pattern := config["backups.pattern"]
+	if pattern == "" {
+		pattern = "backup%d"
+	}
+
+	backupName, err := backupNextName(pattern, backupNames)
+	if err != nil {
+		return errors.Wrapf(err, "Failed generating backup name")
+	}
+
+	expiry, err := shared.GetSnapshotExpiry(time.Now(), config["backups.expiry"])
+	if err != nil {
+		return errors.Wrapf(err, "Failed getting backup expiry date")
+	}
+
+	args := db.InstanceBackup{
+		Name:         inst.Name() + shared.SnapshotDelimiter + backupName,
+		InstanceID:   inst.ID(),
+		CreationDate: time.Now(),
+		ExpiryDate:   expiry,
+	}
+
+	err = backupCreate(ctx, s, args, inst, "")
+	if err != nil {
+		return errors.Wrapf(err, "Failed creating backup %q", backupName)
+	}
+
+	if keep <= 0 {
+		return nil
+	}
+
+	backups, err = inst.Backups()
+	if err != nil {
+		return err
+	}
+
+	if len(backups) <= keep {
+		return nil
+	}
+
+	// Remove the oldest backups, newest first so that incremental backups go before the backups they're based on.
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].CreationDate().Before(backups[j].CreationDate())
+	})
+
+	for i := len(backups) - keep - 1; i >= 0; i-- {
+		b := backups[i]
+
+		// Keep the backups that the remaining incremental backups are still based on.
+		children, err := b.Children()
+		if err != nil {
+			return errors.Wrapf(err, "Failed checking incremental backups of %q", b.Name())
+		}
+
+		if len(children) > 0 {
+			continue
+		}
+
+		err = b.Delete()
+		if err != nil {
+			return errors.Wrapf(err, "Failed deleting backup %q", b.Name())
+		}
+	}
+
+	return nil
+}
+
+func autoCreateCustomVolumeBackupsTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		// Get projects that allow backups.
+		allowedProjects := map[string]bool{}
+		err := d.State().Cluster.Transaction(func(tx *db.ClusterTx) error {
+			projects, err := tx.GetProjects(db.ProjectFilter{})
+			if err != nil {
+				return fmt.Errorf("Failed loading projects: %w", err)
+			}
+
+			for _, p := range projects {
+				allowedProjects[p.Name] = project.AllowBackupCreation(tx, p.Name) == nil
+			}
+
+			return nil
+		})
+		if err != nil {
+			return
+		}
+
+		allVolumes, err := d.cluster.GetStoragePoolVolumesWithType(db.StoragePoolVolumeTypeCustom)
+		if err != nil {
+			logger.Error("Failed getting volumes for auto custom volume backup task", log.Ctx{"err": err})
+			return
+		}
+
+		localNodeID := d.cluster.GetNodeID()
+
+		var volumes, remoteVolumes []db.StorageVolumeArgs
+		for _, v := range allVolumes {
+			schedule, ok := v.Config["backups.schedule"]
+			if !ok || schedule == "" {
+				continue
+			}
+
+			// Check if backup is scheduled.
+			if !snapshotIsScheduledNow(schedule, v.ID) {
+				continue
+			}
+
+			if !allowedProjects[v.ProjectName] {
+				continue
+			}
+
+			if v.NodeID == localNodeID {
+				// Always include local volumes.
+				volumes = append(volumes, v)
+				logger.Debug("Scheduling local auto custom volume backup", log.Ctx{"vol": v.Name, "project": v.ProjectName, "pool": v.PoolName})
+			} else if v.NodeID < 0 {
+				// Keep a separate list of remote volumes in order to select a member to perform
+				// the backup later.
+				remoteVolumes = append(remoteVolumes, v)
+			}
+		}
+
+		if len(remoteVolumes) > 0 {
+			remoteVolumes, err = customVolumesForLocalMember(d, remoteVolumes)
+			if err != nil {
+				logger.Error("Skipping remote volumes for auto custom volume backup task", log.Ctx{"err": err})
+			}
+
+			for _, v := range remoteVolumes {
+				logger.Debug("Scheduling remote auto custom volume backup", log.Ctx{"vol": v.Name, "project": v.ProjectName, "pool": v.PoolName})
+				volumes = append(volumes, v)
+			}
+		}
+
+		if len(volumes) == 0 {
+			return
+		}
+
+		opRun := func(op *operations.Operation) error {
+			autoCreateCustomVolumeBackups(ctx, d, volumes)
+			return nil
+		}
+
+		op, err := operations.OperationCreate(d.State(), "", operations.OperationClassTask, db.OperationCustomVolumeBackupCreate, nil, nil, opRun, nil, nil, nil)
+		if err != nil {
+			logger.Error("Failed to start create volume backup operation", log.Ctx{"err": err})
+			return
+		}
+
+		logger.Info("Creating scheduled volume backups")
+
+		_, err = op.Run()
+		if err != nil {
+			logger.Error("Failed to create scheduled volume backups", log.Ctx{"err": err})
+		}
+
+		logger.Info("Done creating scheduled volume backups")
+	}
+
+	first := true
+	schedule := func() (time.Duration, error) {
+		interval := time.Minute
+
+		if first {
+			first = false
+			return interval, task.ErrSkip
+		}
+
+		return interval, nil
+	}
+
+	return f, schedule
+}
+
+func autoCreateCustomVolumeBackups(ctx context.Context, d *Daemon, volumes []db.StorageVolumeArgs) {
+	// Make the backups sequentially. The backups are aborted if the task context is cancelled (on shutdown).
+	for _, v := range volumes {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Reload the volume to skip it if its backup schedule was unset since the task started.
+		poolID, err := d.cluster.GetStoragePoolID(v.PoolName)
+		if err != nil {
+			continue
+		}
+
+		_, vol, err := d.cluster.GetLocalStoragePoolVolume(v.ProjectName, v.Name, db.StoragePoolVolumeTypeCustom, poolID)
+		if err != nil || vol.Config["backups.schedule"] == "" {
+			continue
+		}
+
+		v.Config = vol.Config
+
+		err = autoCreateCustomVolumeBackup(ctx, d.State(), v)
+		if err != nil {
+			logger.Error("Error creating scheduled volume backup", log.Ctx{"err": err, "project": v.ProjectName, "pool": v.PoolName, "volume": v.Name})
+		}
+	}
+}
+
+// autoCreateCustomVolumeBackup creates a scheduled backup of the custom volume and then removes the oldest
+// backups that exceed the backups.keep limit.
+func autoCreateCustomVolumeBackup(ctx context.Context, s *state.State, v db.StorageVolumeArgs) error {
+	keep, err := backupKeep(v.Config)
+	if err != nil {
+		return err
+	}
+
+	poolID, err := s.Cluster.GetStoragePoolID(v.PoolName)
+	if err != nil {
+		return err
+	}
+
+	backupNames, err := s.Cluster.GetStoragePoolVolumeBackupsNames(v.ProjectName, v.Name, poolID)
+	if err != nil {
+		return err
+	}
+
+	pattern := v.Config["backups.pattern"]
+	if pattern == "" {
+		pattern = "backup%d"
+	}
+
+	backupName, err := backupNextName(pattern, backupNames)
+	if err != nil {
+		return errors.Wrapf(err, "Failed generating backup name")
+	}
+
+	expiry, err := shared.GetSnapshotExpiry(time.Now(), v.Config["backups.expiry"])
+	if err != nil {
+		return errors.Wrapf(err, "Failed getting backup expiry date")
+	}
+
+	args := db.StoragePoolVolumeBackup{
+		Name:         v.Name + shared.SnapshotDelimiter + backupName,
+		VolumeID:     v.ID,
+		CreationDate: time.Now(),
+		ExpiryDate:   expiry,
+	}
+
+	err = volumeBackupCreate(ctx, s, args, v.ProjectName, v.PoolName, v.Name)
+	if err != nil {
+		return errors.Wrapf(err, "Failed creating backup %q", backupName)
+	}
+
+	s.Events.SendLifecycle(v.ProjectName, lifecycle.StorageVolumeBackupCreated.Event(v.PoolName, db.StoragePoolVolumeTypeNameCustom, args.Name, v.ProjectName, nil, nil))
+
+	if keep <= 0 {
+		return nil
+	}
+
+	backups, err := s.Cluster.GetStoragePoolVolumeBackups(v.ProjectName, v.Name, poolID)
+	if err != nil {
+		return err
+	}
+
+	if len(backups) <= keep {
+		return nil
+	}
+
+	// Remove the oldest backups.
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].CreationDate.Before(backups[j].CreationDate)
+	})
+
+	for _, b := range backups[:len(backups)-keep] {
+		volBackup := backup.NewVolumeBackup(s, v.ProjectName, v.PoolName, v.Name, b.ID, b.Name, b.CreationDate, b.ExpiryDate, b.VolumeOnly, b.OptimizedStorage)
+		err = volBackup.Delete()
+		if err != nil {
+			return errors.Wrapf(err, "Failed deleting backup %q", b.Name)
+		}
+
+		s.Events.SendLifecycle(v.ProjectName, lifecycle.StorageVolumeBackupDeleted.Event(v.PoolName, db.StoragePoolVolumeTypeNameCustom, b.Name, v.ProjectName, nil, nil))
+	}
+
+	return nil
+}
+
+// backupTargetExportResponse returns a response streaming the named backup tarball from the project's backups target.
+func backupTargetExportResponse(s *state.State, projectName string, name string) response.Response {
+	t, err := backup.LoadTarget(s, projectName)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if t == nil {
+		return response.NotFound(nil)
+	}
+
+	reader, size, err := t.Get(name)
+	if err == backupTarget.ErrNotFound {
+		return response.NotFound(err)
+	}
+	if err != nil {
+		return response.SmartError(errors.Wrapf(err, "Failed retrieving backup %q from backups target", name))
+	}
+
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		defer reader.Close()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if size >= 0 {
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+		}
+
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline;filename=%s", path.Base(name)))
+		w.WriteHeader(http.StatusOK)
+
+		_, err := io.Copy(w, reader)
+		return err
+	})
+}
@@ -48,6 +48,44 @@ type Info struct {
 	OptimizedHeader  *bool    `json:"optimized_header,omitempty" yaml:"optimized_header,omitempty"` // Optional field to handle older optimized backups that don't have this field.
 	Type             Type     `json:"type,omitempty" yaml:"type,omitempty"`                         // Type of backup.
 	Config           *Config  `json:"config,omitempty" yaml:"config,omitempty"`                     // Equivalent of backup.yaml but embedded in index for quick retrieval.
+	Parents          []string `json:"parents,omitempty" yaml:"parents,omitempty"`                   // Backups (oldest first) an incremental backup is based on.
+	Consistency      string   `json:"consistency,omitempty" yaml:"consistency,omitempty"`           // Consistency achieved when exporting a running instance.
+
+	ParentsData []io.ReadSeeker `json:"-" yaml:"-"` // ParentsData is set during import with the data of the backups listed in Parents.
+}
+
+// Parent represents the backup an incremental backup is created against.
+type Parent struct {
+	Name string        // Name of the parent backup.
+	Info *Info         // Index of the parent backup.
+	Data io.ReadSeeker // Tarball of the parent backup.
+}
+
+// Chain returns the index and data of each backup needed to restore the backup, oldest first.
+// The last element is the backup itself.
+func (i *Info) Chain(data io.ReadSeeker) ([]*Info, []io.ReadSeeker, error) {
+	if len(i.ParentsData) != len(i.Parents) {
+		return nil, nil, fmt.Errorf("Missing parent backups data for incremental backup")
+	}
+
+	infos := make([]*Info, 0, len(i.Parents)+1)
+	for k, parentData := range i.ParentsData {
+		parentInfo, err := GetInfo(parentData)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "Failed reading parent backup %q index", i.Parents[k])
+		}
+
+		if parentInfo.OptimizedStorage != nil && i.OptimizedStorage != nil && *parentInfo.OptimizedStorage != *i.OptimizedStorage {
+			return nil, nil, fmt.Errorf("Parent backup %q doesn't use the same storage format", i.Parents[k])
+		}
+
+		infos = append(infos, parentInfo)
+	}
+
+	infos = append(infos, i)
+	datas := append(append([]io.ReadSeeker{}, i.ParentsData...), data)
+
+	return infos, datas, nil
 }
 
 // GetInfo extracts backup information from a given ReadSeeker.
@@ -1,10 +1,14 @@
 package backup
 
 import (
+	"io/ioutil"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/lxc/lxd/lxd/backup/target"
 	"github.com/lxc/lxd/lxd/lifecycle"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
@@ -55,8 +59,72 @@ func (b *InstanceBackup) Instance() Instance {
 	return b.instance
 }
 
+// Children returns the names of the incremental backups of the instance that are based on this backup.
+// Only the backups stored on the server are checked.
+func (b *InstanceBackup) Children() ([]string, error) {
+	instanceName, backupName, _ := shared.InstanceGetParentAndSnapshotName(b.name)
+	backupsPath := shared.VarPath("backups", "instances", project.Instance(b.instance.Project(), instanceName))
+
+	entries, err := ioutil.ReadDir(backupsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	children := []string{}
+	for _, entry := range entries {
+		if entry.Name() == backupName {
+			continue
+		}
+
+		f, err := os.Open(filepath.Join(backupsPath, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := GetInfo(f)
+		f.Close()
+		if err != nil {
+			continue // Ignore unreadable backups.
+		}
+
+		if shared.StringInSlice(backupName, info.Parents) {
+			children = append(children, entry.Name())
+		}
+	}
+
+	return children, nil
+}
+
+// checkNoChildren returns an error if incremental backups are based on this backup.
+func (b *InstanceBackup) checkNoChildren() error {
+	children, err := b.Children()
+	if err != nil {
+		return err
+	}
+
+	if len(children) > 0 {
+		return api.StatusErrorf(http.StatusBadRequest, "Backup is the parent of incremental backups %s", strings.Join(children, ", "))
+	}
+
+	return nil
+}
+
 // Rename renames an instance backup.
+// Backups that incremental backups are based on can only be renamed along with their instance.
 func (b *InstanceBackup) Rename(newName string) error {
+	_, oldBackupName, _ := shared.InstanceGetParentAndSnapshotName(b.name)
+	_, newBackupName, _ := shared.InstanceGetParentAndSnapshotName(newName)
+	if oldBackupName != newBackupName {
+		err := b.checkNoChildren()
+		if err != nil {
+			return err
+		}
+	}
+
 	oldBackupPath := shared.VarPath("backups", "instances", project.Instance(b.instance.Project(), b.name))
 	newBackupPath := shared.VarPath("backups", "instances", project.Instance(b.instance.Project(), newName))
 
@@ -91,6 +159,12 @@ func (b *InstanceBackup) Rename(newName string) error {
 		}
 	}
 
+	// Rename the copy on the backups target.
+	err = renameOnTarget(b.state, b.instance.Project(), target.InstanceBackupName(b.instance.Project(), b.name), target.InstanceBackupName(b.instance.Project(), newName))
+	if err != nil {
+		return err
+	}
+
 	// Rename the database record.
 	err = b.state.Cluster.RenameInstanceBackup(b.name, newName)
 	if err != nil {
@@ -104,7 +178,23 @@ func (b *InstanceBackup) Rename(newName string) error {
 }
 
 // Delete removes an instance backup.
+// Backups that incremental backups are based on can't be removed until those are.
 func (b *InstanceBackup) Delete() error {
+	err := b.checkNoChildren()
+	if err != nil {
+		return err
+	}
+
+	return b.delete(true)
+}
+
+// DeleteLocal removes an instance backup while keeping its copy on the backups target.
+// This is used when deleting the instance itself so that it can still be restored from the backups target.
+func (b *InstanceBackup) DeleteLocal() error {
+	return b.delete(false)
+}
+
+func (b *InstanceBackup) delete(fromTarget bool) error {
 	backupPath := shared.VarPath("backups", "instances", project.Instance(b.instance.Project(), b.name))
 
 	// Delete the on-disk data.
@@ -125,6 +215,14 @@ func (b *InstanceBackup) Delete() error {
 		}
 	}
 
+	// Delete the copy on the backups target.
+	if fromTarget {
+		err := deleteFromTarget(b.state, b.instance.Project(), target.InstanceBackupName(b.instance.Project(), b.name))
+		if err != nil {
+			return err
+		}
+	}
+
 	// Remove the database record.
 	err := b.state.Cluster.DeleteInstanceBackup(b.name)
 	if err != nil {
@@ -0,0 +1,137 @@
+package backup
+
+import (
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/backup/target"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/util"
+)
+
+// LoadTarget returns the backups target configured for the project, falling back to the server wide one.
+// Returns nil if no backups target is configured.
+func LoadTarget(s *state.State, projectName string) (target.Target, error) {
+	var config map[string]string
+
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		p, err := tx.GetProject(projectName)
+		if err != nil {
+			return err
+		}
+
+		if p.Config["backups.target"] != "" {
+			config = p.Config
+			return nil
+		}
+
+		config, err = tx.Config()
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed loading backups target config for project %q", projectName)
+	}
+
+	if config["backups.target"] == "" {
+		return nil, nil
+	}
+
+	client, err := util.HTTPClient("", s.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := target.Load(config["backups.target"], config["backups.target.access_key"], config["backups.target.secret_key"], client)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed loading backups target")
+	}
+
+	return t, nil
+}
+
+// UploadToTarget uploads the backup tarball at path to the backups target (if any) under the given name.
+func UploadToTarget(s *state.State, projectName string, name string, path string) error {
+	t, err := LoadTarget(s, projectName)
+	if err != nil || t == nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = t.Put(name, f, fi.Size())
+	if err != nil {
+		return errors.Wrapf(err, "Failed uploading backup %q to backups target", name)
+	}
+
+	return nil
+}
+
+// DownloadFromTarget writes the named backup from the backups target to w.
+// Returns target.ErrNotFound if no backups target is configured or the backup doesn't exist on it.
+func DownloadFromTarget(s *state.State, projectName string, name string, w io.Writer) error {
+	t, err := LoadTarget(s, projectName)
+	if err != nil {
+		return err
+	}
+
+	if t == nil {
+		return target.ErrNotFound
+	}
+
+	r, _, err := t.Get(name)
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	_, err = io.Copy(w, r)
+	if err != nil {
+		return errors.Wrapf(err, "Failed downloading backup %q from backups target", name)
+	}
+
+	return nil
+}
+
+// deleteFromTarget removes the named backup from the backups target (if any).
+func deleteFromTarget(s *state.State, projectName string, name string) error {
+	t, err := LoadTarget(s, projectName)
+	if err != nil || t == nil {
+		return err
+	}
+
+	err = t.Delete(name)
+	if err != nil && err != target.ErrNotFound {
+		return errors.Wrapf(err, "Failed deleting backup %q from backups target", name)
+	}
+
+	return nil
+}
+
+// renameOnTarget renames the named backup on the backups target (if any).
+func renameOnTarget(s *state.State, projectName string, oldName string, newName string) error {
+	t, err := LoadTarget(s, projectName)
+	if err != nil || t == nil {
+		return err
+	}
+
+	err = t.Rename(oldName, newName)
+	if err != nil && err != target.ErrNotFound {
+		return errors.Wrapf(err, "Failed renaming backup %q on backups target", oldName)
+	}
+
+	return nil
+}
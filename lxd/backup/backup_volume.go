@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lxc/lxd/lxd/backup/target"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/revert"
 	"github.com/lxc/lxd/lxd/state"
@@ -90,6 +91,12 @@ func (b *VolumeBackup) Rename(newName string) error {
 		}
 	}
 
+	// Rename the copy on the backups target.
+	err = renameOnTarget(b.state, b.projectName, target.VolumeBackupName(b.projectName, b.poolName, b.name), target.VolumeBackupName(b.projectName, b.poolName, newName))
+	if err != nil {
+		return err
+	}
+
 	// Rename the database record.
 	err = b.state.Cluster.RenameVolumeBackup(b.name, newName)
 	if err != nil {
@@ -121,8 +128,14 @@ func (b *VolumeBackup) Delete() error {
 		}
 	}
 
+	// Delete the copy on the backups target.
+	err := deleteFromTarget(b.state, b.projectName, target.VolumeBackupName(b.projectName, b.poolName, b.name))
+	if err != nil {
+		return err
+	}
+
 	// Remove the database record.
-	err := b.state.Cluster.DeleteStoragePoolVolumeBackup(b.name)
+	err = b.state.Cluster.DeleteStoragePoolVolumeBackup(b.name)
 	if err != nil {
 		return err
 	}
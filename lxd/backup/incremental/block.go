@@ -0,0 +1,153 @@
+package incremental
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// DefaultBlockSize is the size of the blocks block volumes are compared in.
+const DefaultBlockSize = 4 * 1024 * 1024
+
+// blockDeltaMagic identifies block delta files.
+var blockDeltaMagic = []byte("LXDBLKD1")
+
+// HashBlocks returns the SHA256 hashes of each block of size bytes read from r.
+func HashBlocks(r io.Reader, size int64, blockSize int64) ([]string, error) {
+	hashes := []string{}
+	buf := make([]byte, blockSize)
+
+	for offset := int64(0); offset < size; offset += blockSize {
+		n := blockLength(size, blockSize, offset)
+		_, err := io.ReadFull(r, buf[:n])
+		if err != nil {
+			return nil, fmt.Errorf("Failed reading block at offset %d: %w", offset, err)
+		}
+
+		hash := sha256.Sum256(buf[:n])
+		hashes = append(hashes, hex.EncodeToString(hash[:]))
+	}
+
+	return hashes, nil
+}
+
+// WriteBlockDelta writes the blocks of the size bytes read from r that differ from parentBlocks to w.
+// It returns the hashes of all the blocks read from r.
+func WriteBlockDelta(w io.Writer, r io.Reader, size int64, blockSize int64, parentBlocks []string) ([]string, error) {
+	header := &bytes.Buffer{}
+	header.Write(blockDeltaMagic)
+	_ = binary.Write(header, binary.BigEndian, size)
+	_ = binary.Write(header, binary.BigEndian, blockSize)
+
+	_, err := w.Write(header.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := []string{}
+	buf := make([]byte, blockSize)
+
+	for offset := int64(0); offset < size; offset += blockSize {
+		n := blockLength(size, blockSize, offset)
+		_, err := io.ReadFull(r, buf[:n])
+		if err != nil {
+			return nil, fmt.Errorf("Failed reading block at offset %d: %w", offset, err)
+		}
+
+		hash := sha256.Sum256(buf[:n])
+		hashStr := hex.EncodeToString(hash[:])
+		hashes = append(hashes, hashStr)
+
+		index := offset / blockSize
+		if index < int64(len(parentBlocks)) && parentBlocks[index] == hashStr {
+			continue
+		}
+
+		err = binary.Write(w, binary.BigEndian, index)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = w.Write(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return hashes, nil
+}
+
+// ReadBlockDeltaHeader reads the header of a block delta from r and returns the disk and block sizes.
+func ReadBlockDeltaHeader(r io.Reader) (int64, int64, error) {
+	magic := make([]byte, len(blockDeltaMagic))
+	_, err := io.ReadFull(r, magic)
+	if err != nil {
+		return -1, -1, fmt.Errorf("Failed reading block delta header: %w", err)
+	}
+
+	if !bytes.Equal(magic, blockDeltaMagic) {
+		return -1, -1, fmt.Errorf("Invalid block delta header")
+	}
+
+	var size, blockSize int64
+	err = binary.Read(r, binary.BigEndian, &size)
+	if err != nil {
+		return -1, -1, fmt.Errorf("Failed reading block delta header: %w", err)
+	}
+
+	err = binary.Read(r, binary.BigEndian, &blockSize)
+	if err != nil {
+		return -1, -1, fmt.Errorf("Failed reading block delta header: %w", err)
+	}
+
+	if size < 0 || blockSize <= 0 {
+		return -1, -1, fmt.Errorf("Invalid block delta header")
+	}
+
+	return size, blockSize, nil
+}
+
+// ApplyBlockDelta writes the blocks of the delta read from r (after its header) to w.
+func ApplyBlockDelta(w io.WriterAt, r io.Reader, size int64, blockSize int64) error {
+	buf := make([]byte, blockSize)
+
+	for {
+		var index int64
+		err := binary.Read(r, binary.BigEndian, &index)
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("Failed reading block delta: %w", err)
+		}
+
+		offset := index * blockSize
+		if index < 0 || offset >= size {
+			return fmt.Errorf("Invalid block index %d in block delta", index)
+		}
+
+		n := blockLength(size, blockSize, offset)
+		_, err = io.ReadFull(r, buf[:n])
+		if err != nil {
+			return fmt.Errorf("Failed reading block %d of block delta: %w", index, err)
+		}
+
+		_, err = w.WriteAt(buf[:n], offset)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// blockLength returns the length of the block at offset.
+func blockLength(size int64, blockSize int64, offset int64) int64 {
+	if size-offset < blockSize {
+		return size - offset
+	}
+
+	return blockSize
+}
@@ -0,0 +1,127 @@
+package incremental_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/backup/incremental"
+)
+
+// writerAt is an in-memory io.WriterAt.
+type writerAt []byte
+
+func (w writerAt) WriteAt(p []byte, off int64) (int, error) {
+	return copy(w[off:], p), nil
+}
+
+func TestBlockDelta(t *testing.T) {
+	blockSize := int64(4)
+	parent := []byte("aaaabbbbccccdd")
+	current := []byte("aaaaBBBBccccddeeee")
+
+	parentBlocks, err := incremental.HashBlocks(bytes.NewReader(parent), int64(len(parent)), blockSize)
+	require.NoError(t, err)
+	assert.Len(t, parentBlocks, 4)
+
+	delta := &bytes.Buffer{}
+	blocks, err := incremental.WriteBlockDelta(delta, bytes.NewReader(current), int64(len(current)), blockSize, parentBlocks)
+	require.NoError(t, err)
+	assert.Len(t, blocks, 5)
+	assert.Equal(t, parentBlocks[0], blocks[0])
+	assert.NotEqual(t, parentBlocks[1], blocks[1])
+
+	size, deltaBlockSize, err := incremental.ReadBlockDeltaHeader(delta)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(current)), size)
+	assert.Equal(t, blockSize, deltaBlockSize)
+
+	// Only the changed and new blocks are part of the delta (index + data).
+	assert.Equal(t, (8+4)+(8+4)+(8+2), delta.Len())
+
+	disk := make(writerAt, size)
+	copy(disk, parent)
+	err = incremental.ApplyBlockDelta(disk, delta, size, deltaBlockSize)
+	require.NoError(t, err)
+	assert.Equal(t, current, []byte(disk))
+}
+
+func TestReadManifest(t *testing.T) {
+	modTime := time.Unix(1600000000, 0)
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+
+	write := func(hdr *tar.Header, data string) {
+		hdr.ModTime = modTime
+		hdr.Size = int64(len(data))
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(data))
+		require.NoError(t, err)
+	}
+
+	write(&tar.Header{Name: "backup/index.yaml", Typeflag: tar.TypeReg, Mode: 0644}, "name: c1\n")
+	write(&tar.Header{Name: "backup/container", Typeflag: tar.TypeDir, Mode: 0755}, "")
+	write(&tar.Header{Name: "backup/container/rootfs/a", Typeflag: tar.TypeReg, Mode: 0644}, "hello")
+	write(&tar.Header{Name: "backup/container/rootfs/b", Typeflag: tar.TypeLink, Linkname: "backup/container/rootfs/a"}, "")
+	write(&tar.Header{Name: "backup/container.img", Typeflag: tar.TypeReg, Mode: 0600}, "block")
+	require.NoError(t, tw.Close())
+
+	m, err := incremental.ReadManifest(bytes.NewReader(buf.Bytes()), "backup/container")
+	require.NoError(t, err)
+	assert.Len(t, m.Files, 3)
+	assert.Equal(t, m.Files["/rootfs/a"], m.Files["/rootfs/b"])
+	assert.Equal(t, int64(5), m.Files["/rootfs/a"].Size)
+	assert.Equal(t, int64(5), m.DiskSize)
+	assert.Len(t, m.Blocks, 1)
+
+	// Incremental backups carry their manifest.
+	buf = &bytes.Buffer{}
+	tw = tar.NewWriter(buf)
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	write(&tar.Header{Name: "backup/container.manifest", Typeflag: tar.TypeReg, Mode: 0644}, string(data))
+	require.NoError(t, tw.Close())
+
+	m2, err := incremental.ReadManifest(bytes.NewReader(buf.Bytes()), "backup/container")
+	require.NoError(t, err)
+	assert.Equal(t, m, m2)
+}
+
+func TestManifestChangedAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "rootfs", "sub"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "rootfs", "keep"), []byte("keep"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "rootfs", "sub", "gone"), []byte("gone"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "root.img"), []byte("disk"), 0644))
+
+	m := incremental.NewManifest()
+	for _, name := range []string{"/rootfs", "/rootfs/keep"} {
+		fi, err := os.Lstat(filepath.Join(dir, name))
+		require.NoError(t, err)
+
+		f, err := incremental.NewFile(filepath.Join(dir, name), fi)
+		require.NoError(t, err)
+		m.Files[name] = f
+	}
+
+	fi, err := os.Lstat(filepath.Join(dir, "rootfs", "keep"))
+	require.NoError(t, err)
+	f, err := incremental.NewFile(filepath.Join(dir, "rootfs", "keep"), fi)
+	require.NoError(t, err)
+	assert.False(t, m.Changed("/rootfs/keep", f))
+	assert.True(t, m.Changed("/rootfs/other", f))
+
+	err = m.Prune(dir, filepath.Join(dir, "root.img"))
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "rootfs", "keep"))
+	assert.FileExists(t, filepath.Join(dir, "root.img"))
+	assert.NoDirExists(t, filepath.Join(dir, "rootfs", "sub"))
+}
@@ -0,0 +1,183 @@
+package incremental
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// ManifestExtension is the extension of the manifest file added next to each volume in incremental backups.
+const ManifestExtension = "manifest"
+
+// Manifest describes the full state of a volume at the time it was backed up.
+// It is used to find what changed since a parent backup and to remove deleted files on restore.
+type Manifest struct {
+	Files     map[string]File `json:"files"`
+	DiskSize  int64           `json:"disk_size,omitempty"`
+	BlockSize int64           `json:"block_size,omitempty"`
+	Blocks    []string        `json:"blocks,omitempty"`
+}
+
+// File describes a file in a volume. Two files with the same File are considered identical.
+type File struct {
+	Type     byte   `json:"type"`
+	Mode     int64  `json:"mode"`
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"mtime"`
+	Linkname string `json:"linkname,omitempty"`
+}
+
+// NewManifest returns an empty Manifest.
+func NewManifest() *Manifest {
+	return &Manifest{Files: map[string]File{}}
+}
+
+// NewFile returns the File for the file at srcPath.
+func NewFile(srcPath string, fi os.FileInfo) (File, error) {
+	link := ""
+	if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
+		var err error
+		link, err = os.Readlink(srcPath)
+		if err != nil {
+			return File{}, fmt.Errorf("Failed to resolve symlink for %q: %w", srcPath, err)
+		}
+	}
+
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return File{}, fmt.Errorf("Failed to create tar info header for %q: %w", srcPath, err)
+	}
+
+	if fi.IsDir() || link != "" {
+		hdr.Size = 0
+	}
+
+	return fileFromHeader(hdr), nil
+}
+
+// fileFromHeader returns the File for a tarball entry.
+// Modification times are compared with a second precision as this is all tarballs guarantee.
+func fileFromHeader(hdr *tar.Header) File {
+	return File{
+		Type:     hdr.Typeflag,
+		Mode:     hdr.Mode,
+		Size:     hdr.Size,
+		ModTime:  hdr.ModTime.Unix(),
+		Linkname: hdr.Linkname,
+	}
+}
+
+// Changed returns whether the file at name differs from the one recorded in the manifest.
+func (m *Manifest) Changed(name string, f File) bool {
+	old, found := m.Files[name]
+
+	return !found || old != f
+}
+
+// Prune removes anything below path that isn't part of the manifest (other than the excluded paths).
+// This is used after applying an incremental backup on top of its parent to remove the deleted files.
+func (m *Manifest) Prune(path string, exclude ...string) error {
+	return filepath.Walk(path, func(srcPath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		name := strings.TrimPrefix(srcPath, path)
+		if name == "" || shared.StringHasPrefix(srcPath, exclude...) {
+			return nil
+		}
+
+		_, found := m.Files[name]
+		if found {
+			return nil
+		}
+
+		err = os.RemoveAll(srcPath)
+		if err != nil {
+			return fmt.Errorf("Failed removing %q: %w", srcPath, err)
+		}
+
+		if fi.IsDir() {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+}
+
+// ReadManifest returns the manifest of the volume stored under prefix in the backup tarball r.
+// Incremental backups include the manifest, for full backups it is built from the tarball's content.
+func ReadManifest(r io.ReadSeeker, prefix string) (*Manifest, error) {
+	_, err := r.Seek(0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, unpacker, err := shared.DetectCompressionFile(r)
+	if err != nil {
+		return nil, err
+	}
+
+	tr, cancelFunc, err := shared.CompressedTarReader(context.Background(), r, unpacker)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cancelFunc()
+
+	m := NewManifest()
+	names := map[string]File{} // Full tarball names, used to resolve hardlinks.
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed reading backup tarball: %w", err)
+		}
+
+		switch {
+		case hdr.Name == fmt.Sprintf("%s.%s", prefix, ManifestExtension):
+			m = NewManifest()
+			err = json.NewDecoder(tr).Decode(m)
+			if err != nil {
+				return nil, fmt.Errorf("Failed decoding backup manifest %q: %w", hdr.Name, err)
+			}
+
+			return m, nil
+		case hdr.Name == fmt.Sprintf("%s.img", prefix):
+			m.DiskSize = hdr.Size
+			m.BlockSize = DefaultBlockSize
+			m.Blocks, err = HashBlocks(tr, m.DiskSize, m.BlockSize)
+			if err != nil {
+				return nil, err
+			}
+		case hdr.Name == prefix || strings.HasPrefix(hdr.Name, prefix+"/"):
+			f := fileFromHeader(hdr)
+			if hdr.Typeflag == tar.TypeLink {
+				// Record hardlinks with the details of the file they point to.
+				target, found := names[hdr.Linkname]
+				if found {
+					f = target
+				}
+			}
+
+			names[hdr.Name] = f
+			m.Files[strings.TrimPrefix(hdr.Name, prefix)] = f
+		}
+	}
+
+	return m, nil
+}
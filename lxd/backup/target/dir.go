@@ -0,0 +1,188 @@
+package target
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dir is a Target storing backups in a local directory.
+type dir struct {
+	path string
+}
+
+// objectPath returns the full path of the named object, ensuring it doesn't escape the target directory.
+func (t *dir) objectPath(name string) (string, error) {
+	p := filepath.Join(t.path, filepath.FromSlash(name))
+	if p == t.path || !strings.HasPrefix(p, t.path+string(filepath.Separator)) {
+		return "", fmt.Errorf("Invalid backup object name %q", name)
+	}
+
+	return p, nil
+}
+
+// Put stores the object in the target directory.
+func (t *dir) Put(name string, r io.Reader, size int64) error {
+	p, err := t.objectPath(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(p), 0700)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a partial upload never replaces a complete one.
+	f, err := ioutil.TempFile(filepath.Dir(p), ".lxd_backup_")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), p)
+}
+
+// Get opens the object in the target directory.
+func (t *dir) Get(name string) (io.ReadCloser, int64, error) {
+	p, err := t.objectPath(name)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, -1, ErrNotFound
+		}
+
+		return nil, -1, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, -1, err
+	}
+
+	return f, fi.Size(), nil
+}
+
+// Delete removes the object and any parent directories left empty.
+func (t *dir) Delete(name string) error {
+	p, err := t.objectPath(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+
+		return err
+	}
+
+	t.removeEmptyParents(p)
+
+	return nil
+}
+
+// Rename moves the object within the target directory.
+func (t *dir) Rename(oldName string, newName string) error {
+	oldPath, err := t.objectPath(oldName)
+	if err != nil {
+		return err
+	}
+
+	newPath, err := t.objectPath(newName)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stat(oldPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(newPath), 0700)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(oldPath, newPath)
+	if err != nil {
+		return err
+	}
+
+	t.removeEmptyParents(oldPath)
+
+	return nil
+}
+
+// List walks the target directory and returns the objects matching the prefix.
+func (t *dir) List(prefix string) ([]Object, error) {
+	objects := []Object{}
+
+	err := filepath.Walk(t.path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".lxd_backup_") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(t.path, p)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(rel)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+
+		objects = append(objects, Object{Name: name, Size: fi.Size(), ModTime: fi.ModTime()})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+// removeEmptyParents removes the now empty parent directories of p up to the target directory.
+func (t *dir) removeEmptyParents(p string) {
+	for d := filepath.Dir(p); d != t.path && strings.HasPrefix(d, t.path); d = filepath.Dir(d) {
+		if os.Remove(d) != nil {
+			break
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package target
+
+import (
+	"fmt"
+)
+
+// ErrNotFound is the "Not found" error returned when an object doesn't exist on the target.
+var ErrNotFound = fmt.Errorf("Backup not found on backups target")
@@ -0,0 +1,9 @@
+package target
+
+// SetS3PartSize changes the size of the parts S3 objects are uploaded in and returns a function restoring it.
+func SetS3PartSize(size int64) func() {
+	oldSize := s3PartSize
+	s3PartSize = size
+
+	return func() { s3PartSize = oldSize }
+}
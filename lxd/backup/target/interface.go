@@ -0,0 +1,31 @@
+package target
+
+import (
+	"io"
+	"time"
+)
+
+// Target represents a location that finished backup tarballs are stored in.
+type Target interface {
+	// Put stores size bytes read from r under the given name, replacing any existing object.
+	Put(name string, r io.Reader, size int64) error
+
+	// Get returns a reader for the object with the given name along with its size (-1 if unknown).
+	Get(name string) (io.ReadCloser, int64, error)
+
+	// Delete removes the object with the given name.
+	Delete(name string) error
+
+	// Rename moves the object with the given name to a new name.
+	Rename(oldName string, newName string) error
+
+	// List returns the objects whose name starts with the given prefix.
+	List(prefix string) ([]Object, error)
+}
+
+// Object represents a backup tarball held by a Target.
+type Object struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
@@ -0,0 +1,98 @@
+package target
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Load returns the Target for the given backups.target value.
+// An absolute path selects a local directory target, while a http(s) URL selects an S3-compatible target
+// (path-style, in the form http(s)://<host>[:<port>]/<bucket>[/<prefix>]).
+func Load(value string, accessKey string, secretKey string, client *http.Client) (Target, error) {
+	err := Validate(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if filepath.IsAbs(value) {
+		return &dir{path: filepath.Clean(value)}, nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.SplitN(strings.Trim(u.Path, "/"), "/", 2)
+
+	t := &s3{
+		endpoint:  fmt.Sprintf("%s://%s", u.Scheme, u.Host),
+		bucket:    fields[0],
+		accessKey: accessKey,
+		secretKey: secretKey,
+		region:    "us-east-1",
+		client:    client,
+	}
+
+	if len(fields) > 1 {
+		t.prefix = fields[1]
+	}
+
+	if t.client == nil {
+		t.client = http.DefaultClient
+	}
+
+	return t, nil
+}
+
+// Validate checks that the value is a valid backups.target value.
+func Validate(value string) error {
+	if filepath.IsAbs(value) {
+		return nil
+	}
+
+	return ValidateURL(value)
+}
+
+// ValidateURL checks that the value is a valid S3-compatible backups.target URL.
+func ValidateURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("Invalid backups target %q: %w", value, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("Invalid backups target %q, must be an absolute path or a http(s) URL", value)
+	}
+
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return fmt.Errorf("Invalid backups target %q, URL must include a host and a bucket", value)
+	}
+
+	if u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("Invalid backups target %q, URL must not include credentials, query or fragment", value)
+	}
+
+	return nil
+}
+
+// InstanceBackupName returns the object name used for an instance backup on the target.
+// The backup name is expected in the form "<instance>/<backup>".
+func InstanceBackupName(projectName string, backupName string) string {
+	return path.Join(projectName, "instances", backupName)
+}
+
+// InstanceBackupsPrefix returns the object name prefix used for all backups of an instance.
+func InstanceBackupsPrefix(projectName string, instanceName string) string {
+	return path.Join(projectName, "instances", instanceName) + "/"
+}
+
+// VolumeBackupName returns the object name used for a custom volume backup on the target.
+// The backup name is expected in the form "<volume>/<backup>".
+func VolumeBackupName(projectName string, poolName string, backupName string) string {
+	return path.Join(projectName, "custom", poolName, backupName)
+}
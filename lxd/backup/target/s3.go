@@ -0,0 +1,400 @@
+package target
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3PartSize is the size of the parts objects larger than it are uploaded in, as S3 limits single uploads to 5GiB.
+var s3PartSize int64 = 128 * 1024 * 1024
+
+// s3MaxParts is the maximum number of parts of a multipart upload.
+const s3MaxParts = 10000
+
+// s3 is a Target storing backups in a bucket of an S3-compatible object store (path-style addressing).
+type s3 struct {
+	endpoint  string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	region    string
+	client    *http.Client
+}
+
+// s3Error represents the XML error document returned by S3.
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// s3ListResult represents the XML document returned by ListObjectsV2.
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// s3InitiateMultipartUploadResult represents the XML document returned by CreateMultipartUpload.
+type s3InitiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+// s3CompletedPart represents an uploaded part in the XML document sent to CompleteMultipartUpload.
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// s3CompleteMultipartUpload represents the XML document sent to CompleteMultipartUpload.
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletedPart `xml:"Part"`
+}
+
+// key returns the object key for the given backup object name.
+func (t *s3) key(name string) string {
+	if t.prefix == "" {
+		return name
+	}
+
+	return path.Join(t.prefix, name)
+}
+
+// Put uploads the object. Objects larger than s3PartSize are uploaded in parts.
+func (t *s3) Put(name string, r io.Reader, size int64) error {
+	if size > s3PartSize {
+		return t.putMultipart(name, r, size)
+	}
+
+	resp, err := t.do("PUT", t.key(name), nil, nil, r, size)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	return nil
+}
+
+// putMultipart uploads the object using a multipart upload, which is aborted should any part fail.
+func (t *s3) putMultipart(name string, r io.Reader, size int64) error {
+	key := t.key(name)
+
+	resp, err := t.do("POST", key, url.Values{"uploads": []string{""}}, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+
+	upload := s3InitiateMultipartUploadResult{}
+	err = xml.NewDecoder(resp.Body).Decode(&upload)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("Failed parsing multipart upload of backup object %q: %w", name, err)
+	}
+
+	success := false
+	defer func() {
+		if success {
+			return
+		}
+
+		resp, err := t.do("DELETE", key, url.Values{"uploadId": []string{upload.UploadID}}, nil, nil, 0)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	// Grow the parts if needed to stay within the maximum number of parts.
+	partSize := s3PartSize
+	if size > partSize*s3MaxParts {
+		partSize = (size + s3MaxParts - 1) / s3MaxParts
+	}
+
+	complete := s3CompleteMultipartUpload{}
+	for partNumber := 1; size > 0; partNumber++ {
+		if partSize > size {
+			partSize = size
+		}
+
+		query := url.Values{}
+		query.Set("partNumber", fmt.Sprintf("%d", partNumber))
+		query.Set("uploadId", upload.UploadID)
+
+		resp, err := t.do("PUT", key, query, nil, io.LimitReader(r, partSize), partSize)
+		if err != nil {
+			return fmt.Errorf("Failed uploading part %d of backup object %q: %w", partNumber, name, err)
+		}
+
+		resp.Body.Close()
+
+		complete.Parts = append(complete.Parts, s3CompletedPart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+
+		size -= partSize
+	}
+
+	body, err := xml.Marshal(&complete)
+	if err != nil {
+		return err
+	}
+
+	resp, err = t.do("POST", key, url.Values{"uploadId": []string{upload.UploadID}}, nil, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+
+	// Completing can fail after a 200 response has been sent, in which case the body holds an error document.
+	body, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	s3Err := s3Error{}
+	if xml.Unmarshal(body, &s3Err) == nil && s3Err.Code != "" {
+		return fmt.Errorf("Failed completing multipart upload of backup object %q: %s (%s)", name, s3Err.Message, s3Err.Code)
+	}
+
+	success = true
+	return nil
+}
+
+// Get downloads the object.
+func (t *s3) Get(name string) (io.ReadCloser, int64, error) {
+	resp, err := t.do("GET", t.key(name), nil, nil, nil, 0)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
+
+// Delete removes the object.
+func (t *s3) Delete(name string) error {
+	// S3 doesn't report missing objects on DELETE, so check first to be consistent with other targets.
+	resp, err := t.do("HEAD", t.key(name), nil, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	resp, err = t.do("DELETE", t.key(name), nil, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	return nil
+}
+
+// Rename copies the object to its new name and then removes the old one.
+func (t *s3) Rename(oldName string, newName string) error {
+	source := (&url.URL{Path: "/" + t.bucket + "/" + t.key(oldName)}).EscapedPath()
+	headers := map[string]string{"x-amz-copy-source": source}
+
+	resp, err := t.do("PUT", t.key(newName), nil, headers, nil, 0)
+	if err != nil {
+		return err
+	}
+
+	// A copy can fail after a 200 response has been sent, in which case the body holds an error document.
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	s3Err := s3Error{}
+	if xml.Unmarshal(body, &s3Err) == nil && s3Err.Code != "" {
+		return fmt.Errorf("Failed copying backup object %q: %s (%s)", oldName, s3Err.Message, s3Err.Code)
+	}
+
+	return t.Delete(oldName)
+}
+
+// List returns the objects whose name starts with prefix.
+func (t *s3) List(prefix string) ([]Object, error) {
+	objects := []Object{}
+	keyPrefix := t.key(prefix)
+	if strings.HasSuffix(prefix, "/") && !strings.HasSuffix(keyPrefix, "/") {
+		keyPrefix += "/"
+	}
+
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", keyPrefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := t.do("GET", "", query, nil, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		result := s3ListResult{}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Failed parsing backup object list: %w", err)
+		}
+
+		for _, entry := range result.Contents {
+			name := entry.Key
+			if t.prefix != "" {
+				name = strings.TrimPrefix(name, strings.TrimSuffix(t.prefix, "/")+"/")
+			}
+
+			objects = append(objects, Object{Name: name, Size: entry.Size, ModTime: entry.LastModified})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+
+		token = result.NextContinuationToken
+	}
+
+	return objects, nil
+}
+
+// do performs a signed request against the bucket and returns the response on success.
+func (t *s3) do(method string, key string, query url.Values, headers map[string]string, body io.Reader, size int64) (*http.Response, error) {
+	u := &url.URL{Path: "/" + t.bucket}
+	if key != "" {
+		u.Path += "/" + key
+	}
+
+	reqURL := t.endpoint + u.EscapedPath()
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, reqURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	t.sign(req, time.Now().UTC())
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
+	s3Err := s3Error{}
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if xml.Unmarshal(data, &s3Err) == nil && s3Err.Code != "" {
+		if s3Err.Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+
+		return nil, fmt.Errorf("Backups target request failed: %s (%s)", s3Err.Message, s3Err.Code)
+	}
+
+	return nil, fmt.Errorf("Backups target request failed: %s", resp.Status)
+}
+
+// sign adds an AWS signature version 4 to the request. Requests are left anonymous if no keys are set.
+func (t *s3) sign(req *http.Request, now time.Time) {
+	if t.accessKey == "" && t.secretKey == "" {
+		return
+	}
+
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", "UNSIGNED-PAYLOAD")
+
+	// Canonical headers.
+	headerNames := []string{"host"}
+	for k := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "x-amz-date" || lk == "x-amz-content-sha256" || lk == "x-amz-copy-source" {
+			headerNames = append(headerNames, lk)
+		}
+	}
+
+	sort.Strings(headerNames)
+
+	canonicalHeaders := &strings.Builder{}
+	for _, name := range headerNames {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+
+		fmt.Fprintf(canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(value))
+	}
+
+	signedHeaders := strings.Join(headerNames, ";")
+
+	// Canonical query string (url.Values.Encode sorts by key).
+	canonicalQuery := strings.Replace(req.URL.Query().Encode(), "+", "%20", -1)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, t.region)
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(hash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+t.secretKey), date)
+	key = hmacSHA256(key, t.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", t.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -14,12 +14,12 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/auth/oidc"
 	"github.com/lxc/lxd/lxd/cluster"
 	clusterRequest "github.com/lxc/lxd/lxd/cluster/request"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/lifecycle"
 	"github.com/lxc/lxd/lxd/project"
-	"github.com/lxc/lxd/lxd/rbac"
 	"github.com/lxc/lxd/lxd/request"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/util"
@@ -32,6 +32,8 @@ import (
 
 type certificateCache struct {
 	Certificates map[db.CertificateType]map[string]x509.Certificate
+	Identities   map[string]string // OIDC identity names keyed on fingerprint.
+	Revoked      map[string]bool   // Revoked OIDC identities keyed on fingerprint.
 	Projects     map[string][]string
 	Lock         sync.Mutex
 }
@@ -164,6 +166,12 @@ func certificatesGet(d *Daemon, r *http.Request) response.Response {
 		}
 	}
 
+	d.clientCerts.Lock.Lock()
+	for fingerprint := range d.clientCerts.Identities {
+		body = append(body, fmt.Sprintf("/%s/certificates/%s", version.APIVersion, fingerprint))
+	}
+	d.clientCerts.Lock.Unlock()
+
 	return response.SyncResponse(true, body)
 }
 
@@ -171,6 +179,8 @@ func updateCertificateCache(d *Daemon) {
 	logger.Debug("Refreshing trusted certificate cache")
 
 	newCerts := map[db.CertificateType]map[string]x509.Certificate{}
+	newIdentities := map[string]string{}
+	newRevoked := map[string]bool{}
 	newProjects := map[string][]string{}
 
 	var dbCerts []db.Certificate
@@ -186,6 +196,19 @@ func updateCertificateCache(d *Daemon) {
 	}
 
 	for _, dbCert := range dbCerts {
+		// OIDC identities don't come with a certificate.
+		if isOIDCCertificateType(dbCert.Type) {
+			newIdentities[dbCert.Fingerprint] = dbCert.Name
+
+			if dbCert.Type == db.CertificateTypeOIDCRevoked {
+				newRevoked[dbCert.Fingerprint] = true
+			} else if dbCert.Restricted {
+				newProjects[dbCert.Fingerprint] = dbCert.Projects
+			}
+
+			continue
+		}
+
 		if _, found := newCerts[dbCert.Type]; !found {
 			newCerts[dbCert.Type] = make(map[string]x509.Certificate)
 		}
@@ -226,10 +249,80 @@ func updateCertificateCache(d *Daemon) {
 
 	d.clientCerts.Lock.Lock()
 	d.clientCerts.Certificates = newCerts
+	d.clientCerts.Identities = newIdentities
+	d.clientCerts.Revoked = newRevoked
 	d.clientCerts.Projects = newProjects
 	d.clientCerts.Lock.Unlock()
 }
 
+// isOIDCCertificateType returns whether the certificate type is used for OIDC identities.
+func isOIDCCertificateType(certType db.CertificateType) bool {
+	return certType == db.CertificateTypeOIDC || certType == db.CertificateTypeOIDCRevoked
+}
+
+// ensureOIDCIdentity adds an OIDC identity to the trust store the first time it authenticates and returns
+// whether it is allowed to authenticate.
+// New identities are restricted to no projects until an administrator grants them access, and revoked ones
+// are kept so that they aren't added again on their next login.
+func ensureOIDCIdentity(d *Daemon, identity *oidc.Identity) (bool, error) {
+	d.clientCerts.Lock.Lock()
+	_, found := d.clientCerts.Identities[identity.Fingerprint]
+	revoked := d.clientCerts.Revoked[identity.Fingerprint]
+	d.clientCerts.Lock.Unlock()
+
+	if found {
+		return !revoked, nil
+	}
+
+	dbCert, err := d.cluster.GetCertificate(identity.Fingerprint)
+	if err == nil {
+		// Already added through another cluster member.
+		updateCertificateCache(d)
+		return dbCert.Type != db.CertificateTypeOIDCRevoked, nil
+	} else if err != db.ErrNoSuchObject {
+		return false, errors.Wrapf(err, "Failed loading OIDC identity")
+	}
+
+	dbCert = &db.Certificate{
+		Fingerprint: identity.Fingerprint,
+		Type:        db.CertificateTypeOIDC,
+		Name:        identity.Name,
+		Restricted:  true,
+	}
+
+	_, err = d.cluster.CreateCertificate(*dbCert)
+	if err != nil {
+		return false, errors.Wrapf(err, "Failed adding OIDC identity")
+	}
+
+	// Notify other nodes about the new identity so they refresh their cache.
+	notifier, err := cluster.NewNotifier(d.State(), d.endpoints.NetworkCert(), d.serverCert(), cluster.NotifyAlive)
+	if err != nil {
+		return false, err
+	}
+
+	req := api.CertificatesPost{
+		CertificatePut: api.CertificatePut{
+			Name: identity.Name,
+			Type: api.CertificateTypeOIDC,
+		},
+	}
+
+	err = notifier(func(client lxd.InstanceServer) error {
+		return client.CreateCertificate(req)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	updateCertificateCache(d)
+
+	requestor := &api.EventLifecycleRequestor{Username: identity.Fingerprint, Protocol: "oidc"}
+	d.State().Events.SendLifecycle(project.Default, lifecycle.CertificateCreated.Event(identity.Fingerprint, requestor, nil))
+
+	return true, nil
+}
+
 // updateCertificateCacheFromLocal loads trusted server certificates from local database into memory.
 func updateCertificateCacheFromLocal(d *Daemon) error {
 	logger.Debug("Refreshing local trusted certificate cache")
@@ -433,7 +526,7 @@ func certificatesPost(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
-	if !trusted || (protocol == "candid" && !rbac.UserIsAdmin(r)) {
+	if !trusted || (shared.StringInSlice(protocol, []string{"candid", "oidc"}) && !d.authorizer.UserIsAdmin(r)) {
 		if req.Password != "" {
 			// Check if cluster member join token supplied as password.
 			joinToken, err := clusterMemberJoinTokenDecode(req.Password)
@@ -464,6 +557,17 @@ func certificatesPost(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	// OIDC identities are added on their first login, other members only need to refresh their cache.
+	if isOIDCCertificateType(dbReqType) {
+		if !isClusterNotification(r) {
+			return response.BadRequest(fmt.Errorf("OIDC identities can't be added manually"))
+		}
+
+		updateCertificateCache(d)
+
+		return response.EmptySyncResponse
+	}
+
 	// Extract the certificate.
 	var cert *x509.Certificate
 	var name string
@@ -727,12 +831,22 @@ func doCertificateUpdate(d *Daemon, dbInfo db.Certificate, req api.CertificatePu
 			Type:        reqDBType,
 		}
 
+		// OIDC identities don't have a certificate and can't be converted to or from other types.
+		// Revoked identities can be allowed to authenticate again by changing their type back to oidc.
+		if isOIDCCertificateType(dbInfo.Type) != isOIDCCertificateType(reqDBType) {
+			return response.BadRequest(fmt.Errorf("The type of OIDC identities can't be changed"))
+		}
+
+		if isOIDCCertificateType(dbInfo.Type) && req.Certificate != "" {
+			return response.BadRequest(fmt.Errorf("OIDC identities can't have a certificate"))
+		}
+
 		// Non-admins are able to change their own certificate but no other fields.
 		// In order to prevent possible future security issues, the certificate information is
 		// reset in case a non-admin user is performing the update.
-		if !rbac.UserIsAdmin(r) {
-			if r.TLS == nil {
-				response.Forbidden(fmt.Errorf("Cannot update certificate information"))
+		if !d.authorizer.UserIsAdmin(r) {
+			if r.TLS == nil || isOIDCCertificateType(dbInfo.Type) {
+				return response.Forbidden(fmt.Errorf("Cannot update certificate information"))
 			}
 
 			// Ensure the user in not trying to change fields other than the certificate.
@@ -853,8 +967,18 @@ func certificateDelete(d *Daemon, r *http.Request) response.Response {
 			return response.NotFound(err)
 		}
 
-		// Perform the delete with the expanded fingerprint.
-		err = d.cluster.DeleteCertificate(certInfo.Fingerprint)
+		if certInfo.Type == db.CertificateTypeOIDC {
+			// Keep removed OIDC identities around so they aren't added again on their next login.
+			certInfo.Type = db.CertificateTypeOIDCRevoked
+			certInfo.Restricted = true
+			certInfo.Projects = nil
+
+			err = d.cluster.UpdateCertificate(certInfo.Fingerprint, *certInfo)
+		} else {
+			// Perform the delete with the expanded fingerprint.
+			err = d.cluster.DeleteCertificate(certInfo.Fingerprint)
+		}
+
 		if err != nil {
 			return response.SmartError(err)
 		}
@@ -11,8 +11,10 @@ import (
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/scrypt"
 
+	backupTarget "github.com/lxc/lxd/lxd/backup/target"
 	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/scriptlet"
 	"github.com/lxc/lxd/shared/validate"
 )
 
@@ -83,6 +85,13 @@ func (c *Config) CandidServer() (string, string, int64, string) {
 		c.m.GetString("candid.domains")
 }
 
+// OIDCServer returns all the OpenID Connect settings needed to connect to a server.
+func (c *Config) OIDCServer() (string, string, string) {
+	return c.m.GetString("oidc.issuer"),
+		c.m.GetString("oidc.client.id"),
+		c.m.GetString("oidc.audience")
+}
+
 // RBACServer returns all the Candid settings needed to connect to a server.
 func (c *Config) RBACServer() (string, string, int64, string, string, string, string) {
 	return c.m.GetString("rbac.api.url"),
@@ -129,11 +138,24 @@ func (c *Config) OfflineThreshold() time.Duration {
 	return time.Duration(n) * time.Second
 }
 
+// HealingThreshold returns the configured healing threshold, i.e. the
+// number of seconds after which an offline member gets evacuated.
+// A zero value means healing is disabled.
+func (c *Config) HealingThreshold() time.Duration {
+	n := c.m.GetInt64("cluster.healing_threshold")
+	return time.Duration(n) * time.Second
+}
+
 // ImagesMinimalReplica returns the numbers of nodes for cluster images replication
 func (c *Config) ImagesMinimalReplica() int64 {
 	return c.m.GetInt64("cluster.images_minimal_replica")
 }
 
+// InstancesPlacementScriptlet returns the instances placement scriptlet source code.
+func (c *Config) InstancesPlacementScriptlet() string {
+	return c.m.GetString("instances.placement.scriptlet")
+}
+
 // MaxVoters returns the maximum number of members in a cluster that will be
 // assigned the voter role.
 func (c *Config) MaxVoters() int64 {
@@ -243,7 +265,11 @@ func configGet(cluster *db.Cluster) (*Config, error) {
 // ConfigSchema defines available server configuration keys.
 var ConfigSchema = config.Schema{
 	"backups.compression_algorithm":  {Default: "gzip", Validator: validate.IsCompressionAlgorithm},
+	"backups.target":                 {Validator: validate.Optional(backupTarget.Validate)},
+	"backups.target.access_key":      {},
+	"backups.target.secret_key":      {Hidden: true},
 	"cluster.offline_threshold":      {Type: config.Int64, Default: offlineThresholdDefault(), Validator: offlineThresholdValidator},
+	"cluster.healing_threshold":      {Type: config.Int64, Default: "0", Validator: validate.IsUint32},
 	"cluster.images_minimal_replica": {Type: config.Int64, Default: "3", Validator: imageMinimalReplicaValidator},
 	"cluster.max_voters":             {Type: config.Int64, Default: "3", Validator: maxVotersValidator},
 	"cluster.max_standby":            {Type: config.Int64, Default: "2", Validator: maxStandByValidator},
@@ -268,8 +294,12 @@ var ConfigSchema = config.Schema{
 	"images.compression_algorithm":   {Default: "gzip", Validator: validate.IsCompressionAlgorithm},
 	"images.default_architecture":    {Validator: validate.Optional(validate.IsArchitecture)},
 	"images.remote_cache_expiry":     {Type: config.Int64, Default: "10"},
+	"instances.placement.scriptlet":  {Validator: validate.Optional(scriptlet.InstancePlacementValidate)},
 	"maas.api.key":                   {},
 	"maas.api.url":                   {},
+	"oidc.audience":                  {},
+	"oidc.client.id":                 {},
+	"oidc.issuer":                    {},
 	"rbac.agent.url":                 {},
 	"rbac.agent.username":            {},
 	"rbac.agent.private_key":         {},
@@ -30,6 +30,8 @@ import (
 	"gopkg.in/macaroon-bakery.v2/bakery/identchecker"
 	"gopkg.in/macaroon-bakery.v2/httpbakery"
 
+	"github.com/lxc/lxd/lxd/auth"
+	"github.com/lxc/lxd/lxd/auth/oidc"
 	"github.com/lxc/lxd/lxd/bgp"
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/daemon"
@@ -107,6 +109,8 @@ type Daemon struct {
 	proxy func(req *http.Request) (*url.URL, error)
 
 	externalAuth *externalAuth
+	oidcVerifier *oidc.Verifier
+	authorizer   auth.Authorizer
 
 	// Stores last heartbeat node information to detect node changes.
 	lastNodeList *cluster.APIHeartbeat
@@ -188,6 +192,7 @@ func newDaemon(config *DaemonConfig, os *sys.OS) *Daemon {
 	shutdownCtx, shutdownCancel := context.WithCancel(context.Background())
 
 	d := &Daemon{
+		authorizer:     auth.NewAuthorizer(),
 		clientCerts:    &certificateCache{},
 		config:         config,
 		devlxdEvents:   devlxdEvents,
@@ -253,19 +258,51 @@ func allowAuthenticated(d *Daemon, r *http.Request) response.Response {
 	return response.EmptySyncResponse
 }
 
-// allowProjectPermission is a wrapper to check access against the project, its features and RBAC permission
-func allowProjectPermission(feature string, permission string) func(d *Daemon, r *http.Request) response.Response {
+// allowProjectPermission is an AccessHandler which checks that the requestor has the entitlement on the project
+// of the request.
+func allowProjectPermission(entitlement auth.Entitlement) func(d *Daemon, r *http.Request) response.Response {
 	return func(d *Daemon, r *http.Request) response.Response {
 		// Shortcut for speed
-		if rbac.UserIsAdmin(r) {
+		if d.authorizer.UserIsAdmin(r) {
 			return response.EmptySyncResponse
 		}
 
-		// Get the project
-		projectName := projectParam(r)
+		// Validate whether the user has the needed permission
+		if !d.authorizer.UserHasPermission(r, auth.ObjectProject(projectParam(r)), entitlement) {
+			return response.Forbidden(nil)
+		}
+
+		return response.EmptySyncResponse
+	}
+}
+
+// allowPermission is an AccessHandler which checks that the requestor has the entitlement on the entity targeted
+// by the request. The entity is looked up in the project of the request using the given mux variables as its name.
+func allowPermission(objectType auth.ObjectType, entitlement auth.Entitlement, muxVars ...string) func(d *Daemon, r *http.Request) response.Response {
+	return func(d *Daemon, r *http.Request) response.Response {
+		// Shortcut for speed
+		if d.authorizer.UserIsAdmin(r) {
+			return response.EmptySyncResponse
+		}
+
+		nameParts := make([]string, 0, len(muxVars))
+		for _, muxVar := range muxVars {
+			value, err := url.PathUnescape(mux.Vars(r)[muxVar])
+			if err != nil {
+				return response.SmartError(err)
+			}
+
+			nameParts = append(nameParts, value)
+		}
+
+		object := auth.Object{
+			Type:    objectType,
+			Project: projectParam(r),
+			Name:    strings.Join(nameParts, "/"),
+		}
 
 		// Validate whether the user has the needed permission
-		if !rbac.UserHasPermission(r, projectName, permission) {
+		if !d.authorizer.UserHasPermission(r, object, entitlement) {
 			return response.Forbidden(nil)
 		}
 
@@ -347,6 +384,26 @@ func (d *Daemon) Authenticate(w http.ResponseWriter, r *http.Request) (bool, str
 		return false, "", "", fmt.Errorf("Bad/missing TLS on network query")
 	}
 
+	// OpenID Connect bearer token.
+	oidcVerifier := d.oidcVerifier
+	if oidcVerifier != nil && oidcVerifier.IsRequest(r) {
+		identity, err := oidcVerifier.Auth(r.Context(), r)
+		if err != nil {
+			return false, "", "oidc", err
+		}
+
+		allowed, err := ensureOIDCIdentity(d, identity)
+		if err != nil {
+			return false, "", "", err
+		}
+
+		if !allowed {
+			return false, "", "oidc", nil
+		}
+
+		return true, identity.Fingerprint, "oidc", nil
+	}
+
 	if d.externalAuth != nil && r.Header.Get(httpbakery.BakeryProtocolHeader) != "" {
 		// Validate external authentication.
 		ctx := httpbakery.ContextWithRequest(context.TODO(), r)
@@ -494,6 +551,19 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c APIEndpoint) {
 		// Authentication
 		trusted, username, protocol, err := d.Authenticate(w, r)
 		if err != nil {
+			// Tell OIDC clients where to get a new token from.
+			var authErr oidc.AuthError
+			if errors.As(err, &authErr) {
+				oidcVerifier := d.oidcVerifier
+				if oidcVerifier != nil {
+					oidcVerifier.WriteHeaders(w)
+				}
+
+				logger.Debug("Rejecting OIDC request", log.Ctx{"ip": r.RemoteAddr, "err": err})
+				response.ErrorResponse(http.StatusUnauthorized, err.Error()).Render(w)
+				return
+			}
+
 			// If not a macaroon discharge request, return the error
 			_, ok := err.(*bakery.DischargeRequiredError)
 			if !ok {
@@ -518,8 +588,8 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c APIEndpoint) {
 			logger.Debug("Handling API request", logCtx)
 
 			// Get user access data.
-			userAccess, err := func() (*rbac.UserAccess, error) {
-				ua := &rbac.UserAccess{}
+			userAccess, err := func() (*auth.UserAccess, error) {
+				ua := &auth.UserAccess{}
 				ua.Admin = true
 
 				// Internal cluster communications.
@@ -527,8 +597,8 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c APIEndpoint) {
 					return ua, nil
 				}
 
-				// Regular TLS clients.
-				if protocol == "tls" {
+				// Regular TLS clients and OIDC identities.
+				if protocol == "tls" || protocol == "oidc" {
 					d.clientCerts.Lock.Lock()
 					certProjects := d.clientCerts.Projects
 					d.clientCerts.Lock.Unlock()
@@ -569,6 +639,11 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c APIEndpoint) {
 
 				return ua, nil
 			}()
+			if err == nil && !userAccess.Admin {
+				// Add the permissions granted through authorization groups, whatever the type of identity.
+				err = d.addGroupPermissions(userAccess, username)
+			}
+
 			if err != nil {
 				logCtx["err"] = err
 				logger.Warn("Rejecting remote API request", logCtx)
@@ -660,7 +735,7 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c APIEndpoint) {
 				}
 			} else if !action.AllowUntrusted {
 				// Require admin privileges
-				if !rbac.UserIsAdmin(r) {
+				if !d.authorizer.UserIsAdmin(r) {
 					return response.Forbidden(nil)
 				}
 			}
@@ -1061,23 +1136,32 @@ func (d *Daemon) init() error {
 		return errors.Wrap(err, "Failed to fetch metrics address")
 	}
 
+	storageBucketsAddress, err := node.StorageBucketsAddress(d.db)
+	if err != nil {
+		return errors.Wrap(err, "Failed to fetch storage buckets address")
+	}
+
 	if os.Getenv("LISTEN_PID") != "" {
 		d.systemdSocketActivated = true
 	}
 
 	/* Setup the web server */
 	config := &endpoints.Config{
-		Dir:                  d.os.VarDir,
-		UnixSocket:           d.UnixSocket(),
-		Cert:                 networkCert,
-		RestServer:           restServer(d),
-		DevLxdServer:         devLxdServer(d),
-		LocalUnixSocketGroup: d.config.Group,
-		NetworkAddress:       address,
-		ClusterAddress:       clusterAddress,
-		DebugAddress:         debugAddress,
-		MetricsAddress:       metricsAddress,
-		MetricsServer:        metricsServer(d),
+		Dir:                   d.os.VarDir,
+		UnixSocket:            d.UnixSocket(),
+		Cert:                  networkCert,
+		RestServer:            restServer(d),
+		DevLxdServer:          devLxdServer(d),
+		VsockServer:           devLxdVsockServer(d),
+		ServerCert:            d.serverCert(),
+		LocalUnixSocketGroup:  d.config.Group,
+		NetworkAddress:        address,
+		ClusterAddress:        clusterAddress,
+		DebugAddress:          debugAddress,
+		MetricsAddress:        metricsAddress,
+		MetricsServer:         metricsServer(d),
+		StorageBucketsAddress: storageBucketsAddress,
+		StorageBucketsServer:  storageBucketsServer(d),
 	}
 	d.endpoints, err = endpoints.Up(config)
 	if err != nil {
@@ -1224,6 +1308,10 @@ func (d *Daemon) init() error {
 	candidDomains := ""
 	candidExpiry := int64(0)
 
+	oidcIssuer := ""
+	oidcClientID := ""
+	oidcAudience := ""
+
 	dnsAddress := ""
 
 	rbacAPIURL := ""
@@ -1268,6 +1356,7 @@ func (d *Daemon) init() error {
 		)
 
 		candidAPIURL, candidAPIKey, candidExpiry, candidDomains = config.CandidServer()
+		oidcIssuer, oidcClientID, oidcAudience = config.OIDCServer()
 		maasAPIURL, maasAPIKey = config.MAASController()
 		rbacAPIURL, rbacAPIKey, rbacExpiry, rbacAgentURL, rbacAgentUsername, rbacAgentPrivateKey, rbacAgentPublicKey = config.RBACServer()
 		d.gateway.HeartbeatOfflineThreshold = config.OfflineThreshold()
@@ -1296,6 +1385,14 @@ func (d *Daemon) init() error {
 		}
 	}
 
+	// Setup OIDC authentication.
+	if oidcIssuer != "" && oidcClientID != "" {
+		err = d.setupOIDC(oidcIssuer, oidcClientID, oidcAudience)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Setup BGP listener.
 	d.bgp = bgp.NewServer()
 	if bgpAddress != "" && bgpASN != 0 && bgpRouterID != "" {
@@ -1491,6 +1588,15 @@ func (d *Daemon) Ready() error {
 		// Remove expired container backups (hourly)
 		d.tasks.Add(pruneExpiredContainerBackupsTask(d))
 
+		// Remove expired custom volume backups (hourly)
+		d.tasks.Add(pruneExpiredCustomVolumeBackupsTask(d))
+
+		// Take backup of instances (minutely check of configurable cron expression)
+		d.tasks.Add(autoCreateInstanceBackupsTask(d))
+
+		// Take backup of custom volumes (minutely check of configurable cron expression)
+		d.tasks.Add(autoCreateCustomVolumeBackupsTask(d))
+
 		// Take snapshot of containers (minutely check of configurable cron expression)
 		d.tasks.Add(autoCreateContainerSnapshotsTask(d))
 
@@ -1803,6 +1909,51 @@ func (d *Daemon) setupExternalAuthentication(authEndpoint string, authPubkey str
 	return nil
 }
 
+// addGroupPermissions adds the permissions granted to an identity through its authorization groups.
+func (d *Daemon) addGroupPermissions(ua *auth.UserAccess, username string) error {
+	permissions, err := d.cluster.GetIdentityPermissions(username)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range permissions {
+		permission, err := auth.PermissionFromAPI(p)
+		if err != nil {
+			logger.Warn("Ignoring invalid permission", log.Ctx{"username": username, "err": err})
+			continue
+		}
+
+		ua.Permissions = append(ua.Permissions, *permission)
+	}
+
+	return nil
+}
+
+// Setup OpenID Connect authentication.
+func (d *Daemon) setupOIDC(issuer string, clientID string, audience string) error {
+	// Allow disabling OIDC authentication (both the issuer and client ID are required).
+	if issuer == "" || clientID == "" {
+		d.oidcVerifier = nil
+		return nil
+	}
+
+	httpClient, err := util.HTTPClient("", d.proxy)
+	if err != nil {
+		return err
+	}
+
+	httpClient.Timeout = 10 * time.Second
+
+	verifier, err := oidc.NewVerifier(issuer, clientID, audience, httpClient)
+	if err != nil {
+		return err
+	}
+
+	d.oidcVerifier = verifier
+
+	return nil
+}
+
 // Setup RBAC
 func (d *Daemon) setupRBACServer(rbacURL string, rbacKey string, rbacExpiry int64, rbacAgentURL string, rbacAgentUsername string, rbacAgentPrivateKey string, rbacAgentPublicKey string) error {
 	if d.rbac != nil || rbacURL == "" || rbacAgentURL == "" || rbacAgentUsername == "" || rbacAgentPrivateKey == "" || rbacAgentPublicKey == "" {
@@ -2016,6 +2167,11 @@ func (d *Daemon) NodeRefreshTask(heartbeatData *cluster.APIHeartbeat, isLeader b
 	// If it fails then it will get to run again next heartbeat.
 	d.lastNodeList = heartbeatData
 
+	// Evacuate the members which have been offline for too long.
+	if isLeader {
+		healClusterMembers(d)
+	}
+
 	// If there are offline members that have voter or stand-by database
 	// roles, let's see if we can replace them with spare ones. Also, if we
 	// don't have enough voters or standbys, let's see if we can upgrade
@@ -0,0 +1,278 @@
+//go:build linux && cgo && !agent
+// +build linux,cgo,!agent
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// GetAuthGroups returns the names of existing authorization groups.
+func (c *Cluster) GetAuthGroups() ([]string, error) {
+	q := `SELECT name FROM auth_groups ORDER BY name`
+
+	var groupNames []string
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		return tx.QueryScan(q, func(scan func(dest ...interface{}) error) error {
+			var groupName string
+
+			err := scan(&groupName)
+			if err != nil {
+				return err
+			}
+
+			groupNames = append(groupNames, groupName)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return groupNames, nil
+}
+
+// GetAuthGroup returns the authorization group with the given name.
+func (c *Cluster) GetAuthGroup(name string) (int64, *api.AuthGroup, error) {
+	var id int64 = int64(-1)
+
+	group := api.AuthGroup{
+		Name: name,
+	}
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		err := tx.tx.QueryRow("SELECT id, description FROM auth_groups WHERE name=? LIMIT 1", name).Scan(&id, &group.Description)
+		if err != nil {
+			return err
+		}
+
+		group.Permissions, err = authGroupPermissions(tx, id)
+		if err != nil {
+			return errors.Wrapf(err, "Failed loading permissions")
+		}
+
+		group.Identities, err = authGroupIdentities(tx, id)
+		if err != nil {
+			return errors.Wrapf(err, "Failed loading identities")
+		}
+
+		return nil
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return -1, nil, ErrNoSuchObject
+		}
+
+		return -1, nil, err
+	}
+
+	return id, &group, nil
+}
+
+// authGroupPermissions returns the permissions of the authorization group with the given ID.
+func authGroupPermissions(tx *ClusterTx, id int64) ([]api.AuthPermission, error) {
+	q := `
+		SELECT auth_groups_permissions.entitlement, auth_groups_permissions.entity_type, IFNULL(projects.name, ''), auth_groups_permissions.entity_name
+		FROM auth_groups_permissions
+		LEFT JOIN projects ON projects.id=auth_groups_permissions.project_id
+		WHERE auth_groups_permissions.auth_group_id=?
+		ORDER BY auth_groups_permissions.id
+	`
+
+	permissions := []api.AuthPermission{}
+	err := tx.QueryScan(q, func(scan func(dest ...interface{}) error) error {
+		p := api.AuthPermission{}
+
+		err := scan(&p.Entitlement, &p.EntityType, &p.Project, &p.EntityName)
+		if err != nil {
+			return err
+		}
+
+		permissions = append(permissions, p)
+
+		return nil
+	}, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// authGroupIdentities returns the fingerprints of the identities in the authorization group with the given ID.
+func authGroupIdentities(tx *ClusterTx, id int64) ([]string, error) {
+	q := `
+		SELECT certificates.fingerprint
+		FROM auth_groups_identities
+		JOIN certificates ON certificates.id=auth_groups_identities.certificate_id
+		WHERE auth_groups_identities.auth_group_id=?
+		ORDER BY certificates.fingerprint
+	`
+
+	fingerprints := []string{}
+	err := tx.QueryScan(q, func(scan func(dest ...interface{}) error) error {
+		var fingerprint string
+
+		err := scan(&fingerprint)
+		if err != nil {
+			return err
+		}
+
+		fingerprints = append(fingerprints, fingerprint)
+
+		return nil
+	}, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return fingerprints, nil
+}
+
+// GetIdentityPermissions returns the permissions granted to an identity through its authorization groups.
+func (c *Cluster) GetIdentityPermissions(fingerprint string) ([]api.AuthPermission, error) {
+	q := `
+		SELECT auth_groups_permissions.entitlement, auth_groups_permissions.entity_type, IFNULL(projects.name, ''), auth_groups_permissions.entity_name
+		FROM auth_groups_permissions
+		JOIN auth_groups_identities ON auth_groups_identities.auth_group_id=auth_groups_permissions.auth_group_id
+		JOIN certificates ON certificates.id=auth_groups_identities.certificate_id
+		LEFT JOIN projects ON projects.id=auth_groups_permissions.project_id
+		WHERE certificates.fingerprint=?
+	`
+
+	permissions := []api.AuthPermission{}
+	err := c.Transaction(func(tx *ClusterTx) error {
+		return tx.QueryScan(q, func(scan func(dest ...interface{}) error) error {
+			p := api.AuthPermission{}
+
+			err := scan(&p.Entitlement, &p.EntityType, &p.Project, &p.EntityName)
+			if err != nil {
+				return err
+			}
+
+			permissions = append(permissions, p)
+
+			return nil
+		}, fingerprint)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// CreateAuthGroup creates a new authorization group.
+func (c *Cluster) CreateAuthGroup(info *api.AuthGroupsPost) (int64, error) {
+	var id int64
+	var err error
+
+	err = c.Transaction(func(tx *ClusterTx) error {
+		// Insert a new authorization group record.
+		result, err := tx.tx.Exec("INSERT INTO auth_groups (name, description) VALUES (?, ?)", info.Name, info.Description)
+		if err != nil {
+			return err
+		}
+
+		id, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		return authGroupMembersAdd(tx.tx, id, &info.AuthGroupPut)
+	})
+	if err != nil {
+		id = -1
+	}
+
+	return id, err
+}
+
+// authGroupMembersAdd inserts the permissions and identities of an authorization group.
+func authGroupMembersAdd(tx *sql.Tx, id int64, info *api.AuthGroupPut) error {
+	for _, p := range info.Permissions {
+		var projectID interface{}
+		if p.Project != "" {
+			var value int64
+			err := tx.QueryRow("SELECT id FROM projects WHERE name=? LIMIT 1", p.Project).Scan(&value)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					return fmt.Errorf("Project %q not found", p.Project)
+				}
+
+				return err
+			}
+
+			projectID = value
+		}
+
+		_, err := tx.Exec("INSERT INTO auth_groups_permissions (auth_group_id, entitlement, entity_type, project_id, entity_name) VALUES (?, ?, ?, ?, ?)", id, p.Entitlement, p.EntityType, projectID, p.EntityName)
+		if err != nil {
+			return errors.Wrapf(err, "Failed inserting permission")
+		}
+	}
+
+	for _, fingerprint := range info.Identities {
+		var certificateID int64
+		err := tx.QueryRow("SELECT id FROM certificates WHERE fingerprint=? LIMIT 1", fingerprint).Scan(&certificateID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("Identity %q not found", fingerprint)
+			}
+
+			return err
+		}
+
+		_, err = tx.Exec("INSERT INTO auth_groups_identities (auth_group_id, certificate_id) VALUES (?, ?)", id, certificateID)
+		if err != nil {
+			return errors.Wrapf(err, "Failed inserting identity")
+		}
+	}
+
+	return nil
+}
+
+// UpdateAuthGroup updates the authorization group with the given ID.
+func (c *Cluster) UpdateAuthGroup(id int64, info *api.AuthGroupPut) error {
+	return c.Transaction(func(tx *ClusterTx) error {
+		_, err := tx.tx.Exec("UPDATE auth_groups SET description=? WHERE id=?", info.Description, id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.tx.Exec("DELETE FROM auth_groups_permissions WHERE auth_group_id=?", id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.tx.Exec("DELETE FROM auth_groups_identities WHERE auth_group_id=?", id)
+		if err != nil {
+			return err
+		}
+
+		return authGroupMembersAdd(tx.tx, id, info)
+	})
+}
+
+// RenameAuthGroup renames the authorization group with the given ID.
+func (c *Cluster) RenameAuthGroup(id int64, newName string) error {
+	return c.Transaction(func(tx *ClusterTx) error {
+		_, err := tx.tx.Exec("UPDATE auth_groups SET name=? WHERE id=?", newName, id)
+		return err
+	})
+}
+
+// DeleteAuthGroup deletes the authorization group with the given ID.
+func (c *Cluster) DeleteAuthGroup(id int64) error {
+	return c.Transaction(func(tx *ClusterTx) error {
+		_, err := tx.tx.Exec("DELETE FROM auth_groups WHERE id=?", id)
+		return err
+	})
+}
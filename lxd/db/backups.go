@@ -279,6 +279,55 @@ func (c *Cluster) GetExpiredInstanceBackups() ([]InstanceBackup, error) {
 	return result, nil
 }
 
+// GetExpiredStorageVolumeBackups returns a list of expired storage volume backups.
+func (c *Cluster) GetExpiredStorageVolumeBackups() ([]StoragePoolVolumeBackup, error) {
+	var result []StoragePoolVolumeBackup
+	var backupID int
+	var name string
+	var expiryDate string
+	var volumeID int64
+	var volumeOnly bool
+	var optimizedStorage bool
+
+	q := `SELECT storage_volumes_backups.id, storage_volumes_backups.name, storage_volumes_backups.expiry_date, storage_volumes_backups.storage_volume_id, storage_volumes_backups.volume_only, storage_volumes_backups.optimized_storage FROM storage_volumes_backups`
+	outfmt := []interface{}{backupID, name, expiryDate, volumeID, volumeOnly, optimizedStorage}
+	dbResults, err := queryScan(c, q, nil, outfmt)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range dbResults {
+		timestamp := r[2]
+
+		var backupExpiry time.Time
+		err = backupExpiry.UnmarshalText([]byte(timestamp.(string)))
+		if err != nil {
+			return []StoragePoolVolumeBackup{}, err
+		}
+
+		// Since zero time causes some issues due to timezones, we check the
+		// unix timestamp instead of IsZero().
+		if backupExpiry.Unix() <= 0 {
+			// Backup doesn't expire
+			continue
+		}
+
+		// Backup has expired
+		if time.Now().Unix()-backupExpiry.Unix() >= 0 {
+			result = append(result, StoragePoolVolumeBackup{
+				ID:               r[0].(int),
+				Name:             r[1].(string),
+				ExpiryDate:       backupExpiry,
+				VolumeID:         r[3].(int64),
+				VolumeOnly:       r[4].(bool),
+				OptimizedStorage: r[5].(bool),
+			})
+		}
+	}
+
+	return result, nil
+}
+
 // GetStoragePoolVolumeBackups returns a list of volume backups.
 func (c *Cluster) GetStoragePoolVolumeBackups(projectName string, volumeName string, poolID int64) ([]StoragePoolVolumeBackup, error) {
 	var backupID int
@@ -45,6 +45,12 @@ const CertificateTypeServer = CertificateType(2)
 // CertificateTypeMetrics indicates a metrics certificate type.
 const CertificateTypeMetrics = CertificateType(3)
 
+// CertificateTypeOIDC indicates an OpenID Connect identity (no certificate).
+const CertificateTypeOIDC = CertificateType(4)
+
+// CertificateTypeOIDCRevoked indicates a removed OpenID Connect identity which isn't allowed to authenticate.
+const CertificateTypeOIDCRevoked = CertificateType(5)
+
 // CertificateAPITypeToDBType converts an API type to the equivalent DB type.
 func CertificateAPITypeToDBType(apiType string) (CertificateType, error) {
 	switch apiType {
@@ -54,6 +60,10 @@ func CertificateAPITypeToDBType(apiType string) (CertificateType, error) {
 		return CertificateTypeServer, nil
 	case api.CertificateTypeMetrics:
 		return CertificateTypeMetrics, nil
+	case api.CertificateTypeOIDC:
+		return CertificateTypeOIDC, nil
+	case api.CertificateTypeOIDCRevoked:
+		return CertificateTypeOIDCRevoked, nil
 	}
 
 	return -1, fmt.Errorf("Invalid certificate type")
@@ -79,6 +89,10 @@ func (cert *Certificate) ToAPIType() string {
 		return api.CertificateTypeServer
 	case CertificateTypeMetrics:
 		return api.CertificateTypeMetrics
+	case CertificateTypeOIDC:
+		return api.CertificateTypeOIDC
+	case CertificateTypeOIDCRevoked:
+		return api.CertificateTypeOIDCRevoked
 	}
 
 	return api.CertificateTypeUnknown
@@ -6,6 +6,30 @@ package cluster
 // modify the database schema, please add a new schema update to update.go
 // and the run 'make update-schema'.
 const freshSchema = `
+CREATE TABLE "auth_groups" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	name TEXT NOT NULL,
+	description TEXT NOT NULL,
+	UNIQUE (name)
+);
+CREATE TABLE "auth_groups_identities" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	auth_group_id INTEGER NOT NULL,
+	certificate_id INTEGER NOT NULL,
+	UNIQUE (auth_group_id, certificate_id),
+	FOREIGN KEY (auth_group_id) REFERENCES "auth_groups" (id) ON DELETE CASCADE,
+	FOREIGN KEY (certificate_id) REFERENCES "certificates" (id) ON DELETE CASCADE
+);
+CREATE TABLE "auth_groups_permissions" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	auth_group_id INTEGER NOT NULL,
+	entitlement TEXT NOT NULL,
+	entity_type TEXT NOT NULL,
+	project_id INTEGER,
+	entity_name TEXT NOT NULL,
+	FOREIGN KEY (auth_group_id) REFERENCES "auth_groups" (id) ON DELETE CASCADE,
+	FOREIGN KEY (project_id) REFERENCES "projects" (id) ON DELETE CASCADE
+);
 CREATE TABLE certificates (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     fingerprint TEXT NOT NULL,
@@ -120,6 +144,18 @@ CREATE TABLE "instances" (
     FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE,
     FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE
 );
+CREATE TRIGGER instances_auth_groups_permissions_delete
+  AFTER DELETE ON instances
+  BEGIN
+    DELETE FROM auth_groups_permissions
+      WHERE entity_type = 'instance' AND project_id = OLD.project_id AND entity_name = OLD.name;
+  END;
+CREATE TRIGGER instances_auth_groups_permissions_rename
+  AFTER UPDATE OF name ON instances
+  BEGIN
+    UPDATE auth_groups_permissions SET entity_name = NEW.name
+      WHERE entity_type = 'instance' AND project_id = OLD.project_id AND entity_name = OLD.name;
+  END;
 CREATE TABLE "instances_backups" (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     instance_id INTEGER NOT NULL,
@@ -325,6 +361,18 @@ CREATE TABLE networks_acls_config (
     UNIQUE (network_acl_id, key),
     FOREIGN KEY (network_acl_id) REFERENCES networks_acls (id) ON DELETE CASCADE
 );
+CREATE TRIGGER networks_auth_groups_permissions_delete
+  AFTER DELETE ON networks
+  BEGIN
+    DELETE FROM auth_groups_permissions
+      WHERE entity_type = 'network' AND project_id = OLD.project_id AND entity_name = OLD.name;
+  END;
+CREATE TRIGGER networks_auth_groups_permissions_rename
+  AFTER UPDATE OF name ON networks
+  BEGIN
+    UPDATE auth_groups_permissions SET entity_name = NEW.name
+      WHERE entity_type = 'network' AND project_id = OLD.project_id AND entity_name = OLD.name;
+  END;
 CREATE TABLE "networks_config" (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     network_id INTEGER NOT NULL,
@@ -354,6 +402,26 @@ CREATE TABLE "networks_forwards_config" (
 	UNIQUE (network_forward_id, key),
 	FOREIGN KEY (network_forward_id) REFERENCES "networks_forwards" (id) ON DELETE CASCADE
 );
+CREATE TABLE "networks_load_balancers" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	network_id INTEGER NOT NULL,
+	node_id INTEGER,
+	listen_address TEXT NOT NULL,
+	description TEXT NOT NULL,
+	backends TEXT NOT NULL,
+	ports TEXT NOT NULL,
+	UNIQUE (network_id, node_id, listen_address),
+	FOREIGN KEY (network_id) REFERENCES "networks" (id) ON DELETE CASCADE,
+	FOREIGN KEY (node_id) REFERENCES "nodes" (id) ON DELETE CASCADE
+);
+CREATE TABLE "networks_load_balancers_config" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	network_load_balancer_id INTEGER NOT NULL,
+	key VARCHAR(255) NOT NULL,
+	value TEXT,
+	UNIQUE (network_load_balancer_id, key),
+	FOREIGN KEY (network_load_balancer_id) REFERENCES "networks_load_balancers" (id) ON DELETE CASCADE
+);
 CREATE TABLE "networks_nodes" (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     network_id INTEGER NOT NULL,
@@ -401,6 +469,29 @@ CREATE TABLE "networks_zones_config" (
 	UNIQUE (network_zone_id, key),
 	FOREIGN KEY (network_zone_id) REFERENCES "networks_zones" (id) ON DELETE CASCADE
 );
+CREATE TABLE "networks_zones_records" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	network_zone_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	description TEXT NOT NULL,
+	entries TEXT NOT NULL,
+	UNIQUE (name, network_zone_id),
+	FOREIGN KEY (network_zone_id) REFERENCES "networks_zones" (id) ON DELETE CASCADE
+);
+CREATE TABLE "networks_zones_records_config" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	network_zone_record_id INTEGER NOT NULL,
+	key VARCHAR(255) NOT NULL,
+	value TEXT,
+	UNIQUE (network_zone_record_id, key),
+	FOREIGN KEY (network_zone_record_id) REFERENCES "networks_zones_records" (id) ON DELETE CASCADE
+);
+CREATE TABLE "networks_zones_serials" (
+	network_zone_id INTEGER PRIMARY KEY NOT NULL,
+	serial INTEGER NOT NULL,
+	hash TEXT NOT NULL,
+	FOREIGN KEY (network_zone_id) REFERENCES "networks_zones" (id) ON DELETE CASCADE
+);
 CREATE TABLE nodes (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     name TEXT NOT NULL,
@@ -590,7 +681,46 @@ CREATE VIEW projects_used_by_ref (name,
     printf('/1.0/network-acls/%s?project=%s',
     networks_acls.name,
     projects.name)
-    FROM networks_acls JOIN projects ON project_id=projects.id;
+    FROM networks_acls JOIN projects ON project_id=projects.id UNION
+  SELECT projects.name,
+    printf('/1.0/storage-pools/%s/buckets/%s?project=%s&target=%s',
+    storage_pools.name,
+    storage_buckets.name,
+    projects.name,
+    nodes.name)
+    FROM storage_buckets JOIN storage_pools ON storage_pool_id=storage_pools.id JOIN nodes ON node_id=nodes.id JOIN projects ON project_id=projects.id;
+CREATE TABLE "storage_buckets" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	name TEXT NOT NULL,
+	storage_pool_id INTEGER NOT NULL,
+	node_id INTEGER,
+	description TEXT NOT NULL,
+	project_id INTEGER NOT NULL,
+	UNIQUE (node_id, project_id, name),
+	FOREIGN KEY (storage_pool_id) REFERENCES "storage_pools" (id) ON DELETE CASCADE,
+	FOREIGN KEY (node_id) REFERENCES "nodes" (id) ON DELETE CASCADE,
+	FOREIGN KEY (project_id) REFERENCES "projects" (id) ON DELETE CASCADE
+);
+CREATE TABLE "storage_buckets_config" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	storage_bucket_id INTEGER NOT NULL,
+	key TEXT NOT NULL,
+	value TEXT NOT NULL,
+	UNIQUE (storage_bucket_id, key),
+	FOREIGN KEY (storage_bucket_id) REFERENCES "storage_buckets" (id) ON DELETE CASCADE
+);
+CREATE TABLE "storage_buckets_keys" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	storage_bucket_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	description TEXT NOT NULL,
+	access_key TEXT NOT NULL,
+	secret_key TEXT NOT NULL,
+	role TEXT NOT NULL,
+	UNIQUE (storage_bucket_id, name),
+	UNIQUE (access_key),
+	FOREIGN KEY (storage_bucket_id) REFERENCES "storage_buckets" (id) ON DELETE CASCADE
+);
 CREATE TABLE storage_pools (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     name TEXT NOT NULL,
@@ -663,6 +793,29 @@ CREATE VIEW storage_volumes_all (
          storage_volumes.content_type
     FROM storage_volumes
     JOIN storage_volumes_snapshots ON storage_volumes.id = storage_volumes_snapshots.storage_volume_id;
+CREATE TRIGGER storage_volumes_auth_groups_permissions_delete
+  AFTER DELETE ON storage_volumes
+  WHEN NOT EXISTS (SELECT 1 FROM storage_volumes WHERE storage_pool_id = OLD.storage_pool_id AND project_id = OLD.project_id AND type = OLD.type AND name = OLD.name)
+  BEGIN
+    DELETE FROM auth_groups_permissions
+      WHERE entity_type = 'storage_volume' AND project_id = OLD.project_id AND entity_name =
+        (SELECT name FROM storage_pools WHERE id = OLD.storage_pool_id) || '/' ||
+        CASE OLD.type WHEN 0 THEN 'container' WHEN 1 THEN 'image' WHEN 2 THEN 'custom' WHEN 3 THEN 'virtual-machine' END || '/' ||
+        OLD.name;
+  END;
+CREATE TRIGGER storage_volumes_auth_groups_permissions_rename
+  AFTER UPDATE OF name ON storage_volumes
+  WHEN NOT EXISTS (SELECT 1 FROM storage_volumes WHERE storage_pool_id = OLD.storage_pool_id AND project_id = OLD.project_id AND type = OLD.type AND name = OLD.name)
+  BEGIN
+    UPDATE auth_groups_permissions SET entity_name =
+        (SELECT name FROM storage_pools WHERE id = OLD.storage_pool_id) || '/' ||
+        CASE OLD.type WHEN 0 THEN 'container' WHEN 1 THEN 'image' WHEN 2 THEN 'custom' WHEN 3 THEN 'virtual-machine' END || '/' ||
+        NEW.name
+      WHERE entity_type = 'storage_volume' AND project_id = OLD.project_id AND entity_name =
+        (SELECT name FROM storage_pools WHERE id = OLD.storage_pool_id) || '/' ||
+        CASE OLD.type WHEN 0 THEN 'container' WHEN 1 THEN 'image' WHEN 2 THEN 'custom' WHEN 3 THEN 'virtual-machine' END || '/' ||
+        OLD.name;
+  END;
 CREATE TABLE storage_volumes_backups (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     storage_volume_id INTEGER NOT NULL,
@@ -734,5 +887,5 @@ CREATE TABLE warnings (
 );
 CREATE UNIQUE INDEX warnings_unique_node_id_project_id_entity_type_code_entity_id_type_code ON warnings(IFNULL(node_id, -1), IFNULL(project_id, -1), entity_type_code, entity_id, type_code);
 
-INSERT INTO schema (version, updated_at) VALUES (54, strftime("%s"))
+INSERT INTO schema (version, updated_at) VALUES (60, strftime("%s"))
 `
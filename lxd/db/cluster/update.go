@@ -93,6 +93,278 @@ var updates = map[int]schema.Update{
 	52: updateFromV51,
 	53: updateFromV52,
 	54: updateFromV53,
+	55: updateFromV54,
+	56: updateFromV55,
+	57: updateFromV56,
+	58: updateFromV57,
+	59: updateFromV58,
+	60: updateFromV59,
+}
+
+// updateFromV59 creates the networks_zones_serials table tracking the SOA serial of each network zone content
+// so that all cluster members serve the same serial.
+func updateFromV59(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE "networks_zones_serials" (
+	network_zone_id INTEGER PRIMARY KEY NOT NULL,
+	serial INTEGER NOT NULL,
+	hash TEXT NOT NULL,
+	FOREIGN KEY (network_zone_id) REFERENCES "networks_zones" (id) ON DELETE CASCADE
+);
+`)
+	if err != nil {
+		return fmt.Errorf("Failed creating network zone serials table: %w", err)
+	}
+
+	return nil
+}
+
+// updateFromV58 adds triggers keeping the permissions of authorization groups in sync with the instances,
+// networks and storage volumes they are granted on when those are renamed or deleted.
+func updateFromV58(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TRIGGER instances_auth_groups_permissions_delete
+  AFTER DELETE ON instances
+  BEGIN
+    DELETE FROM auth_groups_permissions
+      WHERE entity_type = 'instance' AND project_id = OLD.project_id AND entity_name = OLD.name;
+  END;
+CREATE TRIGGER instances_auth_groups_permissions_rename
+  AFTER UPDATE OF name ON instances
+  BEGIN
+    UPDATE auth_groups_permissions SET entity_name = NEW.name
+      WHERE entity_type = 'instance' AND project_id = OLD.project_id AND entity_name = OLD.name;
+  END;
+CREATE TRIGGER networks_auth_groups_permissions_delete
+  AFTER DELETE ON networks
+  BEGIN
+    DELETE FROM auth_groups_permissions
+      WHERE entity_type = 'network' AND project_id = OLD.project_id AND entity_name = OLD.name;
+  END;
+CREATE TRIGGER networks_auth_groups_permissions_rename
+  AFTER UPDATE OF name ON networks
+  BEGIN
+    UPDATE auth_groups_permissions SET entity_name = NEW.name
+      WHERE entity_type = 'network' AND project_id = OLD.project_id AND entity_name = OLD.name;
+  END;
+CREATE TRIGGER storage_volumes_auth_groups_permissions_delete
+  AFTER DELETE ON storage_volumes
+  WHEN NOT EXISTS (SELECT 1 FROM storage_volumes WHERE storage_pool_id = OLD.storage_pool_id AND project_id = OLD.project_id AND type = OLD.type AND name = OLD.name)
+  BEGIN
+    DELETE FROM auth_groups_permissions
+      WHERE entity_type = 'storage_volume' AND project_id = OLD.project_id AND entity_name =
+        (SELECT name FROM storage_pools WHERE id = OLD.storage_pool_id) || '/' ||
+        CASE OLD.type WHEN 0 THEN 'container' WHEN 1 THEN 'image' WHEN 2 THEN 'custom' WHEN 3 THEN 'virtual-machine' END || '/' ||
+        OLD.name;
+  END;
+CREATE TRIGGER storage_volumes_auth_groups_permissions_rename
+  AFTER UPDATE OF name ON storage_volumes
+  WHEN NOT EXISTS (SELECT 1 FROM storage_volumes WHERE storage_pool_id = OLD.storage_pool_id AND project_id = OLD.project_id AND type = OLD.type AND name = OLD.name)
+  BEGIN
+    UPDATE auth_groups_permissions SET entity_name =
+        (SELECT name FROM storage_pools WHERE id = OLD.storage_pool_id) || '/' ||
+        CASE OLD.type WHEN 0 THEN 'container' WHEN 1 THEN 'image' WHEN 2 THEN 'custom' WHEN 3 THEN 'virtual-machine' END || '/' ||
+        NEW.name
+      WHERE entity_type = 'storage_volume' AND project_id = OLD.project_id AND entity_name =
+        (SELECT name FROM storage_pools WHERE id = OLD.storage_pool_id) || '/' ||
+        CASE OLD.type WHEN 0 THEN 'container' WHEN 1 THEN 'image' WHEN 2 THEN 'custom' WHEN 3 THEN 'virtual-machine' END || '/' ||
+        OLD.name;
+  END;
+`)
+	if err != nil {
+		return fmt.Errorf("Failed creating authorization group permission triggers: %w", err)
+	}
+
+	return nil
+}
+
+// updateFromV57 creates the networks_zones_records and networks_zones_records_config tables.
+func updateFromV57(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE "networks_zones_records" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	network_zone_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	description TEXT NOT NULL,
+	entries TEXT NOT NULL,
+	UNIQUE (name, network_zone_id),
+	FOREIGN KEY (network_zone_id) REFERENCES "networks_zones" (id) ON DELETE CASCADE
+);
+CREATE TABLE "networks_zones_records_config" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	network_zone_record_id INTEGER NOT NULL,
+	key VARCHAR(255) NOT NULL,
+	value TEXT,
+	UNIQUE (network_zone_record_id, key),
+	FOREIGN KEY (network_zone_record_id) REFERENCES "networks_zones_records" (id) ON DELETE CASCADE
+);
+`)
+	if err != nil {
+		return fmt.Errorf("Failed creating network zone record tables: %w", err)
+	}
+
+	return nil
+}
+
+// updateFromV56 creates the auth_groups, auth_groups_identities and auth_groups_permissions tables.
+func updateFromV56(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE "auth_groups" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	name TEXT NOT NULL,
+	description TEXT NOT NULL,
+	UNIQUE (name)
+);
+CREATE TABLE "auth_groups_identities" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	auth_group_id INTEGER NOT NULL,
+	certificate_id INTEGER NOT NULL,
+	UNIQUE (auth_group_id, certificate_id),
+	FOREIGN KEY (auth_group_id) REFERENCES "auth_groups" (id) ON DELETE CASCADE,
+	FOREIGN KEY (certificate_id) REFERENCES "certificates" (id) ON DELETE CASCADE
+);
+CREATE TABLE "auth_groups_permissions" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	auth_group_id INTEGER NOT NULL,
+	entitlement TEXT NOT NULL,
+	entity_type TEXT NOT NULL,
+	project_id INTEGER,
+	entity_name TEXT NOT NULL,
+	FOREIGN KEY (auth_group_id) REFERENCES "auth_groups" (id) ON DELETE CASCADE,
+	FOREIGN KEY (project_id) REFERENCES "projects" (id) ON DELETE CASCADE
+);
+`)
+	if err != nil {
+		return fmt.Errorf("Failed creating authorization group tables: %w", err)
+	}
+
+	return nil
+}
+
+// updateFromV55 creates the networks_load_balancers and networks_load_balancers_config tables.
+func updateFromV55(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE "networks_load_balancers" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	network_id INTEGER NOT NULL,
+	node_id INTEGER,
+	listen_address TEXT NOT NULL,
+	description TEXT NOT NULL,
+	backends TEXT NOT NULL,
+	ports TEXT NOT NULL,
+	UNIQUE (network_id, node_id, listen_address),
+	FOREIGN KEY (network_id) REFERENCES "networks" (id) ON DELETE CASCADE,
+	FOREIGN KEY (node_id) REFERENCES "nodes" (id) ON DELETE CASCADE
+);
+CREATE TABLE "networks_load_balancers_config" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	network_load_balancer_id INTEGER NOT NULL,
+	key VARCHAR(255) NOT NULL,
+	value TEXT,
+	UNIQUE (network_load_balancer_id, key),
+	FOREIGN KEY (network_load_balancer_id) REFERENCES "networks_load_balancers" (id) ON DELETE CASCADE
+);
+`)
+	if err != nil {
+		return fmt.Errorf("Failed creating network load balancer tables: %w", err)
+	}
+
+	return nil
+}
+
+// updateFromV54 creates the storage_buckets, storage_buckets_config and storage_buckets_keys tables
+// and updates projects_used_by_ref to include storage buckets.
+func updateFromV54(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE "storage_buckets" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	name TEXT NOT NULL,
+	storage_pool_id INTEGER NOT NULL,
+	node_id INTEGER,
+	description TEXT NOT NULL,
+	project_id INTEGER NOT NULL,
+	UNIQUE (node_id, project_id, name),
+	FOREIGN KEY (storage_pool_id) REFERENCES "storage_pools" (id) ON DELETE CASCADE,
+	FOREIGN KEY (node_id) REFERENCES "nodes" (id) ON DELETE CASCADE,
+	FOREIGN KEY (project_id) REFERENCES "projects" (id) ON DELETE CASCADE
+);
+
+CREATE TABLE "storage_buckets_config" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	storage_bucket_id INTEGER NOT NULL,
+	key TEXT NOT NULL,
+	value TEXT NOT NULL,
+	UNIQUE (storage_bucket_id, key),
+	FOREIGN KEY (storage_bucket_id) REFERENCES "storage_buckets" (id) ON DELETE CASCADE
+);
+
+CREATE TABLE "storage_buckets_keys" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	storage_bucket_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	description TEXT NOT NULL,
+	access_key TEXT NOT NULL,
+	secret_key TEXT NOT NULL,
+	role TEXT NOT NULL,
+	UNIQUE (storage_bucket_id, name),
+	UNIQUE (access_key),
+	FOREIGN KEY (storage_bucket_id) REFERENCES "storage_buckets" (id) ON DELETE CASCADE
+);
+
+DROP VIEW projects_used_by_ref;
+CREATE VIEW projects_used_by_ref (name,
+    value) AS
+  SELECT projects.name,
+    printf('/1.0/instances/%s?project=%s',
+    "instances".name,
+    projects.name)
+    FROM "instances" JOIN projects ON project_id=projects.id UNION
+  SELECT projects.name,
+    printf('/1.0/images/%s?project=%s',
+    images.fingerprint,
+    projects.name)
+    FROM images JOIN projects ON project_id=projects.id UNION
+  SELECT projects.name,
+    printf('/1.0/storage-pools/%s/volumes/custom/%s?project=%s&target=%s',
+    storage_pools.name,
+    storage_volumes.name,
+    projects.name,
+    nodes.name)
+    FROM storage_volumes JOIN storage_pools ON storage_pool_id=storage_pools.id JOIN nodes ON node_id=nodes.id JOIN projects ON project_id=projects.id WHERE storage_volumes.type=2 UNION
+  SELECT projects.name,
+    printf('/1.0/storage-pools/%s/volumes/custom/%s?project=%s',
+    storage_pools.name,
+    storage_volumes.name,
+    projects.name)
+    FROM storage_volumes JOIN storage_pools ON storage_pool_id=storage_pools.id JOIN projects ON project_id=projects.id WHERE storage_volumes.type=2 AND storage_volumes.node_id IS NULL UNION
+  SELECT projects.name,
+    printf('/1.0/profiles/%s?project=%s',
+    profiles.name,
+    projects.name)
+    FROM profiles JOIN projects ON project_id=projects.id UNION
+  SELECT projects.name,
+    printf('/1.0/networks/%s?project=%s',
+    networks.name,
+    projects.name)
+    FROM networks JOIN projects ON project_id=projects.id UNION
+  SELECT projects.name,
+    printf('/1.0/network-acls/%s?project=%s',
+    networks_acls.name,
+    projects.name)
+    FROM networks_acls JOIN projects ON project_id=projects.id UNION
+  SELECT projects.name,
+    printf('/1.0/storage-pools/%s/buckets/%s?project=%s&target=%s',
+    storage_pools.name,
+    storage_buckets.name,
+    projects.name,
+    nodes.name)
+    FROM storage_buckets JOIN storage_pools ON storage_pool_id=storage_pools.id JOIN nodes ON node_id=nodes.id JOIN projects ON project_id=projects.id;
+`)
+	if err != nil {
+		return fmt.Errorf("Failed creating storage bucket tables: %w", err)
+	}
+
+	return nil
 }
 
 // updateFromV53 creates the cluster_groups and nodes_cluster_groups tables.
@@ -447,10 +447,11 @@ SELECT instances.name, nodes.name
 }
 
 // UpdateInstanceNode changes the name of an instance and the cluster member hosting it.
-// It's meant to be used when moving a non-running instance backed by ceph from one cluster node to another.
+// It's meant to be used when moving a non-running instance backed by ceph from one cluster node to another, or when
+// live migrating an instance (without renaming it) whose volumes have already been moved to the new node.
 func (c *ClusterTx) UpdateInstanceNode(project, oldName string, newName string, newNode string, volumeType int) error {
 	// First check that the container to be moved is backed by a ceph
-	// volume.
+	// volume (unless only the node is changing).
 	poolName, err := c.GetInstancePool(project, oldName)
 	if err != nil {
 		return errors.Wrap(err, "Failed to get instance's storage pool name")
@@ -466,7 +467,7 @@ func (c *ClusterTx) UpdateInstanceNode(project, oldName string, newName string,
 		return errors.Wrap(err, "Failed to get instance's storage pool driver")
 	}
 
-	if poolDriver != "ceph" {
+	if poolDriver != "ceph" && newName != oldName {
 		return fmt.Errorf("Instance's storage pool is not of type ceph")
 	}
 
@@ -0,0 +1,218 @@
+//go:build linux && cgo && !agent
+// +build linux,cgo,!agent
+
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// GetNetworkZoneRecordNames returns the names of the records in the Network zone with the given ID.
+func (c *Cluster) GetNetworkZoneRecordNames(zoneID int64) ([]string, error) {
+	q := `SELECT name FROM networks_zones_records
+		WHERE network_zone_id = ?
+		ORDER BY name
+	`
+
+	var recordNames []string
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		return tx.QueryScan(q, func(scan func(dest ...interface{}) error) error {
+			var recordName string
+
+			err := scan(&recordName)
+			if err != nil {
+				return err
+			}
+
+			recordNames = append(recordNames, recordName)
+
+			return nil
+		}, zoneID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recordNames, nil
+}
+
+// GetNetworkZoneRecord returns the record with the given name in the Network zone with the given ID.
+func (c *Cluster) GetNetworkZoneRecord(zoneID int64, name string) (int64, *api.NetworkZoneRecord, error) {
+	var id int64 = int64(-1)
+	var entries string
+
+	record := api.NetworkZoneRecord{
+		Name: name,
+	}
+
+	q := `
+		SELECT id, description, entries
+		FROM networks_zones_records
+		WHERE network_zone_id=? AND name=?
+		LIMIT 1
+	`
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		err := tx.tx.QueryRow(q, zoneID, name).Scan(&id, &record.Description, &entries)
+		if err != nil {
+			return err
+		}
+
+		err = json.Unmarshal([]byte(entries), &record.Entries)
+		if err != nil {
+			return errors.Wrapf(err, "Failed unmarshalling entries")
+		}
+
+		err = networkZoneRecordConfig(tx, id, &record)
+		if err != nil {
+			return errors.Wrapf(err, "Failed loading config")
+		}
+
+		return nil
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return -1, nil, ErrNoSuchObject
+		}
+
+		return -1, nil, err
+	}
+
+	return id, &record, nil
+}
+
+// networkZoneRecordConfig populates the config map of the Network zone record with the given ID.
+func networkZoneRecordConfig(tx *ClusterTx, id int64, record *api.NetworkZoneRecord) error {
+	q := `
+		SELECT key, value
+		FROM networks_zones_records_config
+		WHERE network_zone_record_id=?
+	`
+
+	record.Config = make(map[string]string)
+	return tx.QueryScan(q, func(scan func(dest ...interface{}) error) error {
+		var key, value string
+
+		err := scan(&key, &value)
+		if err != nil {
+			return err
+		}
+
+		_, found := record.Config[key]
+		if found {
+			return fmt.Errorf("Duplicate config row found for key %q for network zone record ID %d", key, id)
+		}
+
+		record.Config[key] = value
+
+		return nil
+	}, id)
+}
+
+// CreateNetworkZoneRecord creates a new record in the Network zone with the given ID.
+func (c *Cluster) CreateNetworkZoneRecord(zoneID int64, info api.NetworkZoneRecordsPost) (int64, error) {
+	var id int64
+	var err error
+
+	entries, err := json.Marshal(info.Entries)
+	if err != nil {
+		return -1, err
+	}
+
+	err = c.Transaction(func(tx *ClusterTx) error {
+		// Insert a new Network zone record.
+		result, err := tx.tx.Exec(`
+			INSERT INTO networks_zones_records (network_zone_id, name, description, entries)
+			VALUES (?, ?, ?, ?)
+		`, zoneID, info.Name, info.Description, string(entries))
+		if err != nil {
+			return err
+		}
+
+		id, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		err = networkZoneRecordConfigAdd(tx.tx, id, info.Config)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		id = -1
+	}
+
+	return id, err
+}
+
+// networkZoneRecordConfigAdd inserts Network zone record config keys.
+func networkZoneRecordConfigAdd(tx *sql.Tx, id int64, config map[string]string) error {
+	sql := "INSERT INTO networks_zones_records_config (network_zone_record_id, key, value) VALUES(?, ?, ?)"
+	stmt, err := tx.Prepare(sql)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for k, v := range config {
+		if v == "" {
+			continue
+		}
+
+		_, err = stmt.Exec(id, k, v)
+		if err != nil {
+			return errors.Wrapf(err, "Failed inserting config")
+		}
+	}
+
+	return nil
+}
+
+// UpdateNetworkZoneRecord updates the Network zone record with the given ID.
+func (c *Cluster) UpdateNetworkZoneRecord(id int64, config api.NetworkZoneRecordPut) error {
+	entries, err := json.Marshal(config.Entries)
+	if err != nil {
+		return err
+	}
+
+	return c.Transaction(func(tx *ClusterTx) error {
+		_, err := tx.tx.Exec(`
+			UPDATE networks_zones_records
+			SET description=?, entries=?
+			WHERE id=?
+		`, config.Description, string(entries), id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.tx.Exec("DELETE FROM networks_zones_records_config WHERE network_zone_record_id=?", id)
+		if err != nil {
+			return err
+		}
+
+		err = networkZoneRecordConfigAdd(tx.tx, id, config.Config)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// DeleteNetworkZoneRecord deletes the Network zone record.
+func (c *Cluster) DeleteNetworkZoneRecord(id int64) error {
+	return c.Transaction(func(tx *ClusterTx) error {
+		_, err := tx.tx.Exec("DELETE FROM networks_zones_records WHERE id=?", id)
+		return err
+	})
+}
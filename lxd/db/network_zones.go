@@ -157,6 +157,47 @@ func (c *Cluster) GetNetworkZone(name string) (int64, string, *api.NetworkZone,
 	return id, projectName, &zone, nil
 }
 
+// GetNetworkZonesAllProjects returns all the Network zones, keyed by name.
+func (c *Cluster) GetNetworkZonesAllProjects() (map[string]*api.NetworkZone, error) {
+	q := `SELECT id, name, description FROM networks_zones`
+
+	zones := map[string]*api.NetworkZone{}
+	err := c.Transaction(func(tx *ClusterTx) error {
+		ids := map[string]int64{}
+		err := tx.QueryScan(q, func(scan func(dest ...interface{}) error) error {
+			var id int64
+			zone := api.NetworkZone{}
+
+			err := scan(&id, &zone.Name, &zone.Description)
+			if err != nil {
+				return err
+			}
+
+			ids[zone.Name] = id
+			zones[zone.Name] = &zone
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for name, id := range ids {
+			err = networkZoneConfig(tx, id, zones[name])
+			if err != nil {
+				return errors.Wrapf(err, "Failed loading config")
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return zones, nil
+}
+
 // GetNetworkZoneByProject returns the Network zone with the given name in the given project.
 func (c *Cluster) GetNetworkZoneByProject(projectName string, name string) (int64, *api.NetworkZone, error) {
 	var id int64 = int64(-1)
@@ -314,3 +355,51 @@ func (c *Cluster) DeleteNetworkZone(id int64) error {
 		return err
 	})
 }
+
+// UpdateNetworkZoneSerial returns the SOA serial of the Network zone content with the given hash and whether it
+// was newly allocated. When the content changed, a new serial is allocated which is greater than the previous one
+// and at least minSerial.
+func (c *Cluster) UpdateNetworkZoneSerial(name string, hash string, minSerial uint32) (uint32, bool, error) {
+	var serial uint32
+	var allocated bool
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		var id int64
+		err := tx.tx.QueryRow("SELECT id FROM networks_zones WHERE name=?", name).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		var oldHash string
+		var oldSerial uint32
+		err = tx.tx.QueryRow("SELECT serial, hash FROM networks_zones_serials WHERE network_zone_id=?", id).Scan(&oldSerial, &oldHash)
+		if err == nil && oldHash == hash {
+			serial = oldSerial
+			return nil
+		} else if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		serial = minSerial
+		if err == nil && oldSerial >= serial {
+			serial = oldSerial + 1
+		}
+
+		_, err = tx.tx.Exec("INSERT OR REPLACE INTO networks_zones_serials (network_zone_id, serial, hash) VALUES (?, ?, ?)", id, serial, hash)
+		if err != nil {
+			return err
+		}
+
+		allocated = true
+		return nil
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, ErrNoSuchObject
+		}
+
+		return 0, false, err
+	}
+
+	return serial, allocated, nil
+}
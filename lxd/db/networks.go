@@ -552,6 +552,7 @@ const (
 	NetworkTypeSriov                       // Network type sriov.
 	NetworkTypeOVN                         // Network type ovn.
 	NetworkTypePhysical                    // Network type physical.
+	NetworkTypeOverlay                     // Network type overlay.
 )
 
 // NetworkNode represents a network node.
@@ -686,6 +687,8 @@ func networkFillType(network *api.Network, netType NetworkType) {
 		network.Type = "ovn"
 	case NetworkTypePhysical:
 		network.Type = "physical"
+	case NetworkTypeOverlay:
+		network.Type = "overlay"
 	default:
 		network.Type = "" // Unknown
 	}
@@ -943,5 +946,6 @@ var NodeSpecificNetworkConfig = []string{
 	"bgp.ipv4.nexthop",
 	"bgp.ipv6.nexthop",
 	"bridge.external_interfaces",
+	"overlay.interface",
 	"parent",
 }
@@ -1103,23 +1103,23 @@ func (c *ClusterTx) GetNodeOfflineThreshold() (time.Duration, error) {
 	return threshold, nil
 }
 
-// GetNodeWithLeastInstances returns the name of the non-offline node with with
-// the least number of containers (either already created or being created with
-// an operation). If archs is not empty, then return only nodes with an
-// architecture in that list.
-func (c *ClusterTx) GetNodeWithLeastInstances(archs []int, defaultArch int, group string) (string, error) {
+// GetCandidateMembers returns the non-offline and non-evacuated members an
+// instance can be placed on, honoring their scheduler.instance setting and the
+// cluster group (if not empty). If archs is not empty, then return only members
+// with an architecture in that list. If some of the members support the
+// default architecture, only those are returned.
+func (c *ClusterTx) GetCandidateMembers(archs []int, defaultArch int, group string) ([]NodeInfo, error) {
 	threshold, err := c.GetNodeOfflineThreshold()
 	if err != nil {
-		return "", errors.Wrap(err, "failed to get offline threshold")
+		return nil, errors.Wrap(err, "failed to get offline threshold")
 	}
 
 	nodes, err := c.GetNodes()
 	if err != nil {
-		return "", errors.Wrap(err, "failed to get current nodes")
+		return nil, errors.Wrap(err, "failed to get current nodes")
 	}
 
-	name := ""
-	containers := -1
+	candidates := []NodeInfo{}
 	isDefaultArchChosen := false
 	for _, node := range nodes {
 		if node.Config["scheduler.instance"] == "manual" {
@@ -1146,7 +1146,7 @@ func (c *ClusterTx) GetNodeWithLeastInstances(archs []int, defaultArch int, grou
 		// Get personalities too.
 		personalities, err := osarch.ArchitecturePersonalities(node.Architecture)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		supported := []int{node.Architecture}
@@ -1169,6 +1169,31 @@ func (c *ClusterTx) GetNodeWithLeastInstances(archs []int, defaultArch int, grou
 			continue
 		}
 
+		// Drop the members found so far if this is the first one supporting the default architecture.
+		if isDefaultArch && !isDefaultArchChosen {
+			candidates = []NodeInfo{}
+			isDefaultArchChosen = true
+		}
+
+		candidates = append(candidates, node)
+	}
+
+	return candidates, nil
+}
+
+// GetNodeWithLeastInstances returns the name of the non-offline node with with
+// the least number of containers (either already created or being created with
+// an operation). If archs is not empty, then return only nodes with an
+// architecture in that list.
+func (c *ClusterTx) GetNodeWithLeastInstances(archs []int, defaultArch int, group string) (string, error) {
+	nodes, err := c.GetCandidateMembers(archs, defaultArch, group)
+	if err != nil {
+		return "", err
+	}
+
+	name := ""
+	containers := -1
+	for _, node := range nodes {
 		// Fetch the number of containers already created on this node.
 		created, err := query.Count(c.tx, "instances", "node_id=?", node.ID)
 		if err != nil {
@@ -1183,12 +1208,9 @@ func (c *ClusterTx) GetNodeWithLeastInstances(archs []int, defaultArch int, grou
 		}
 
 		count := created + pending
-		if containers == -1 || count < containers || (isDefaultArch == true && isDefaultArchChosen == false) {
+		if containers == -1 || count < containers {
 			containers = count
 			name = node.Name
-			if isDefaultArch {
-				isDefaultArchChosen = true
-			}
 		}
 	}
 	return name, nil
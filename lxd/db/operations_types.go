@@ -65,6 +65,8 @@ const (
 	OperationVolumeSnapshotRename
 	OperationClusterMemberEvacuate
 	OperationClusterMemberRestore
+	OperationCustomVolumeBackupsExpire
+	OperationClusterHeal
 )
 
 // Description return a human-readable description of the operation type.
@@ -178,6 +180,10 @@ func (t OperationType) Description() string {
 		return "Evacuating cluster member"
 	case OperationClusterMemberRestore:
 		return "Restoring cluster member"
+	case OperationCustomVolumeBackupsExpire:
+		return "Cleaning up expired volume backups"
+	case OperationClusterHeal:
+		return "Healing cluster"
 	default:
 		return "Executing operation"
 	}
@@ -249,6 +255,8 @@ func (t OperationType) Permission() string {
 
 	case OperationCustomVolumeSnapshotsExpire:
 		return "operate-volumes"
+	case OperationCustomVolumeBackupsExpire:
+		return "manage-storage-volumes"
 	case OperationCustomVolumeBackupCreate:
 		return "manage-storage-volumes"
 	case OperationCustomVolumeBackupRemove:
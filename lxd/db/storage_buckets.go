@@ -0,0 +1,468 @@
+//go:build linux && cgo && !agent
+// +build linux,cgo,!agent
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// StorageBucket represents a database storage bucket record.
+type StorageBucket struct {
+	api.StorageBucket
+
+	ID       int64
+	PoolID   int64
+	PoolName string
+	Project  string
+}
+
+// Etag returns the values used for etag generation.
+func (b *StorageBucket) Etag() []interface{} {
+	return []interface{}{b.Name, b.Description, b.Config}
+}
+
+// StorageBucketKey represents a database storage bucket key record.
+type StorageBucketKey struct {
+	api.StorageBucketKey
+
+	ID       int64
+	BucketID int64
+}
+
+// Etag returns the values used for etag generation.
+func (k *StorageBucketKey) Etag() []interface{} {
+	return []interface{}{k.Name, k.Description, k.Role, k.AccessKey, k.SecretKey}
+}
+
+// StorageBucketFilter used for filtering storage buckets with GetStorageBuckets().
+type StorageBucketFilter struct {
+	PoolID  *int64
+	Project *string
+	Name    *string
+}
+
+// GetStoragePoolBuckets returns the storage buckets for the given filter.
+// If memberSpecific is true, then the search is restricted to buckets that belong to this member or belong
+// to all members.
+func (c *ClusterTx) GetStoragePoolBuckets(memberSpecific bool, filter StorageBucketFilter) ([]*StorageBucket, error) {
+	var q *strings.Builder = &strings.Builder{}
+	args := []interface{}{}
+
+	q.WriteString(`
+	SELECT
+		projects.name as project,
+		storage_pools.name,
+		IFNULL(nodes.name, "") as location,
+		storage_buckets.id,
+		storage_buckets.storage_pool_id,
+		storage_buckets.name,
+		storage_buckets.description
+	FROM storage_buckets
+	JOIN projects ON projects.id = storage_buckets.project_id
+	JOIN storage_pools ON storage_pools.id = storage_buckets.storage_pool_id
+	LEFT JOIN nodes ON nodes.id = storage_buckets.node_id
+	WHERE 1=1
+	`)
+
+	if memberSpecific {
+		q.WriteString("AND (storage_buckets.node_id = ? OR storage_buckets.node_id IS NULL) ")
+		args = append(args, c.nodeID)
+	}
+
+	if filter.PoolID != nil {
+		q.WriteString("AND storage_buckets.storage_pool_id = ? ")
+		args = append(args, *filter.PoolID)
+	}
+
+	if filter.Project != nil {
+		q.WriteString("AND projects.name = ? ")
+		args = append(args, *filter.Project)
+	}
+
+	if filter.Name != nil {
+		q.WriteString("AND storage_buckets.name = ? ")
+		args = append(args, *filter.Name)
+	}
+
+	q.WriteString("ORDER BY storage_buckets.id")
+
+	var buckets []*StorageBucket
+
+	err := c.QueryScan(q.String(), func(scan func(dest ...interface{}) error) error {
+		var bucket StorageBucket
+
+		err := scan(&bucket.Project, &bucket.PoolName, &bucket.Location, &bucket.ID, &bucket.PoolID, &bucket.Name, &bucket.Description)
+		if err != nil {
+			return err
+		}
+
+		buckets = append(buckets, &bucket)
+
+		return nil
+	}, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Populate config.
+	for _, bucket := range buckets {
+		err = storageBucketConfig(c, bucket.ID, bucket)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed loading config")
+		}
+	}
+
+	return buckets, nil
+}
+
+// storageBucketConfig populates the config map of the Storage Bucket with the given ID.
+func storageBucketConfig(tx *ClusterTx, bucketID int64, bucket *StorageBucket) error {
+	q := `
+	SELECT
+		key,
+		value
+	FROM storage_buckets_config
+	WHERE storage_bucket_id=?
+	`
+
+	bucket.Config = make(map[string]string)
+	return tx.QueryScan(q, func(scan func(dest ...interface{}) error) error {
+		var key, value string
+
+		err := scan(&key, &value)
+		if err != nil {
+			return err
+		}
+
+		_, found := bucket.Config[key]
+		if found {
+			return fmt.Errorf("Duplicate config row found for key %q for storage bucket ID %d", key, bucketID)
+		}
+
+		bucket.Config[key] = value
+
+		return nil
+	}, bucketID)
+}
+
+// GetStoragePoolBucket returns the Storage Bucket for the given Storage Pool ID, Project Name and Bucket Name.
+// If memberSpecific is true, then the search is restricted to buckets that belong to this member or belong to
+// all members.
+func (c *ClusterTx) GetStoragePoolBucket(poolID int64, projectName string, memberSpecific bool, bucketName string) (*StorageBucket, error) {
+	filter := StorageBucketFilter{
+		PoolID:  &poolID,
+		Project: &projectName,
+		Name:    &bucketName,
+	}
+
+	buckets, err := c.GetStoragePoolBuckets(memberSpecific, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketsLen := len(buckets)
+	if bucketsLen == 1 {
+		return buckets[0], nil
+	} else if bucketsLen > 1 {
+		return nil, api.StatusErrorf(400, "More than one bucket found")
+	}
+
+	return nil, ErrNoSuchObject
+}
+
+// GetStoragePoolLocalBucketByAccessKey returns the local Storage Bucket and bucket key matching the given
+// access key.
+func (c *ClusterTx) GetStoragePoolLocalBucketByAccessKey(accessKey string) (*StorageBucket, *StorageBucketKey, error) {
+	q := `
+	SELECT
+		storage_buckets.id,
+		storage_buckets_keys.id
+	FROM storage_buckets_keys
+	JOIN storage_buckets ON storage_buckets.id = storage_buckets_keys.storage_bucket_id
+	WHERE storage_buckets_keys.access_key = ? AND (storage_buckets.node_id = ? OR storage_buckets.node_id IS NULL)
+	LIMIT 1
+	`
+
+	var bucketID, keyID int64
+	err := c.tx.QueryRow(q, accessKey, c.nodeID).Scan(&bucketID, &keyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, ErrNoSuchObject
+		}
+
+		return nil, nil, err
+	}
+
+	var bucket *StorageBucket
+	buckets, err := c.GetStoragePoolBuckets(true, StorageBucketFilter{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, b := range buckets {
+		if b.ID == bucketID {
+			bucket = b
+			break
+		}
+	}
+
+	if bucket == nil {
+		return nil, nil, ErrNoSuchObject
+	}
+
+	keys, err := c.GetStoragePoolBucketKeys(bucketID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, key := range keys {
+		if key.ID == keyID {
+			return bucket, key, nil
+		}
+	}
+
+	return nil, nil, ErrNoSuchObject
+}
+
+// CreateStoragePoolBucket creates a new Storage Bucket.
+// If memberSpecific is true, then the storage bucket is associated to the current member, rather than being
+// associated to all members.
+func (c *ClusterTx) CreateStoragePoolBucket(poolID int64, projectName string, memberSpecific bool, info api.StorageBucketsPost) (int64, error) {
+	var nodeID interface{}
+
+	if memberSpecific {
+		nodeID = c.nodeID
+	}
+
+	// Insert a new Storage Bucket record.
+	result, err := c.tx.Exec(`
+	INSERT INTO storage_buckets
+	(storage_pool_id, node_id, name, description, project_id)
+	VALUES (?, ?, ?, ?, (SELECT id FROM projects WHERE name = ?))
+	`, poolID, nodeID, info.Name, info.Description, projectName)
+	if err != nil {
+		return -1, err
+	}
+
+	bucketID, err := result.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+
+	// Save config.
+	err = storageBucketConfigAdd(c.tx, bucketID, info.Config)
+	if err != nil {
+		return -1, err
+	}
+
+	return bucketID, nil
+}
+
+// storageBucketConfigAdd inserts Storage Bucket config keys.
+func storageBucketConfigAdd(tx *sql.Tx, bucketID int64, config map[string]string) error {
+	sql := "INSERT INTO storage_buckets_config (storage_bucket_id, key, value) VALUES(?, ?, ?)"
+	stmt, err := tx.Prepare(sql)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for k, v := range config {
+		if v == "" {
+			continue
+		}
+
+		_, err = stmt.Exec(bucketID, k, v)
+		if err != nil {
+			return errors.Wrapf(err, "Failed inserting config")
+		}
+	}
+
+	return nil
+}
+
+// UpdateStoragePoolBucket updates an existing Storage Bucket.
+func (c *ClusterTx) UpdateStoragePoolBucket(poolID int64, bucketID int64, info *api.StorageBucketPut) error {
+	// Update existing Storage Bucket record.
+	res, err := c.tx.Exec(`
+	UPDATE storage_buckets
+	SET description = ?
+	WHERE storage_pool_id = ? and id = ?
+	`, info.Description, poolID, bucketID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected <= 0 {
+		return ErrNoSuchObject
+	}
+
+	// Save config.
+	_, err = c.tx.Exec("DELETE FROM storage_buckets_config WHERE storage_bucket_id=?", bucketID)
+	if err != nil {
+		return err
+	}
+
+	err = storageBucketConfigAdd(c.tx, bucketID, info.Config)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteStoragePoolBucket deletes an existing Storage Bucket.
+func (c *ClusterTx) DeleteStoragePoolBucket(poolID int64, bucketID int64) error {
+	// Delete existing Storage Bucket record.
+	res, err := c.tx.Exec(`
+	DELETE FROM storage_buckets
+	WHERE storage_pool_id = ? and id = ?
+	`, poolID, bucketID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected <= 0 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+// GetStoragePoolBucketKeys returns the Storage Bucket Keys for the given Bucket ID.
+func (c *ClusterTx) GetStoragePoolBucketKeys(bucketID int64) ([]*StorageBucketKey, error) {
+	q := `
+	SELECT
+		storage_buckets_keys.id,
+		storage_buckets_keys.name,
+		storage_buckets_keys.description,
+		storage_buckets_keys.role,
+		storage_buckets_keys.access_key,
+		storage_buckets_keys.secret_key
+	FROM storage_buckets_keys
+	WHERE storage_buckets_keys.storage_bucket_id = ?
+	ORDER BY storage_buckets_keys.id
+	`
+
+	var bucketKeys []*StorageBucketKey
+
+	err := c.QueryScan(q, func(scan func(dest ...interface{}) error) error {
+		bucketKey := StorageBucketKey{
+			BucketID: bucketID,
+		}
+
+		err := scan(&bucketKey.ID, &bucketKey.Name, &bucketKey.Description, &bucketKey.Role, &bucketKey.AccessKey, &bucketKey.SecretKey)
+		if err != nil {
+			return err
+		}
+
+		bucketKeys = append(bucketKeys, &bucketKey)
+
+		return nil
+	}, bucketID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bucketKeys, nil
+}
+
+// GetStoragePoolBucketKey returns the Storage Bucket Key for the given Bucket ID and Key Name.
+func (c *ClusterTx) GetStoragePoolBucketKey(bucketID int64, keyName string) (*StorageBucketKey, error) {
+	keys, err := c.GetStoragePoolBucketKeys(bucketID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		if key.Name == keyName {
+			return key, nil
+		}
+	}
+
+	return nil, ErrNoSuchObject
+}
+
+// CreateStoragePoolBucketKey creates a new Storage Bucket Key.
+func (c *ClusterTx) CreateStoragePoolBucketKey(bucketID int64, info api.StorageBucketKeysPost) (int64, error) {
+	// Insert a new Storage Bucket Key record.
+	result, err := c.tx.Exec(`
+	INSERT INTO storage_buckets_keys
+	(storage_bucket_id, name, description, role, access_key, secret_key)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`, bucketID, info.Name, info.Description, info.Role, info.AccessKey, info.SecretKey)
+	if err != nil {
+		return -1, err
+	}
+
+	bucketKeyID, err := result.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+
+	return bucketKeyID, nil
+}
+
+// UpdateStoragePoolBucketKey updates an existing Storage Bucket Key.
+func (c *ClusterTx) UpdateStoragePoolBucketKey(bucketID int64, bucketKeyID int64, info *api.StorageBucketKeyPut) error {
+	// Update existing Storage Bucket Key record.
+	res, err := c.tx.Exec(`
+	UPDATE storage_buckets_keys
+	SET description = ?, role = ?, access_key = ?, secret_key = ?
+	WHERE storage_bucket_id = ? and id = ?
+	`, info.Description, info.Role, info.AccessKey, info.SecretKey, bucketID, bucketKeyID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected <= 0 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+// DeleteStoragePoolBucketKey deletes an existing Storage Bucket Key.
+func (c *ClusterTx) DeleteStoragePoolBucketKey(bucketID int64, keyID int64) error {
+	// Delete existing Storage Bucket Key record.
+	res, err := c.tx.Exec(`
+	DELETE FROM storage_buckets_keys
+	WHERE storage_bucket_id = ? and id = ?
+	`, bucketID, keyID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected <= 0 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
@@ -55,6 +55,10 @@ const (
 	WarningInstanceAutostartFailure
 	//WarningInstanceTypeNotOperational represents the lack of support for an instance driver
 	WarningInstanceTypeNotOperational
+	// WarningClusterMemberHealed represents the automatic evacuation of an offline cluster member
+	WarningClusterMemberHealed
+	// WarningInstanceHealingFailure represents the failure to move an instance off an offline cluster member
+	WarningInstanceHealingFailure
 )
 
 // WarningTypeNames associates a warning code to its name.
@@ -83,6 +87,8 @@ var WarningTypeNames = map[WarningType]string{
 	WarningOfflineClusterMember:                   "Offline cluster member",
 	WarningInstanceAutostartFailure:               "Failed to autostart instance",
 	WarningInstanceTypeNotOperational:             "Instance type not operational",
+	WarningClusterMemberHealed:                    "Offline cluster member evacuated",
+	WarningInstanceHealingFailure:                 "Failed to move instance off offline cluster member",
 }
 
 // WarningTypes associates a warning type to its type code.
@@ -145,6 +151,10 @@ func (t WarningType) Severity() WarningSeverity {
 		return WarningSeverityLow
 	case WarningInstanceTypeNotOperational:
 		return WarningSeverityLow
+	case WarningClusterMemberHealed:
+		return WarningSeverityModerate
+	case WarningInstanceHealingFailure:
+		return WarningSeverityModerate
 	}
 
 	return WarningSeverityLow
@@ -41,6 +41,15 @@ const diskSourceCloudInit = "cloud-init:config"
 // the QEMU driver.
 const DiskVirtiofsdSockMountOpt = "virtiofsdSock"
 
+// DiskBusMountOpt indicates the mount option prefix used to provide the disk bus to the QEMU driver.
+const DiskBusMountOpt = "bus"
+
+// DiskCacheMountOpt indicates the mount option prefix used to provide the disk cache mode to the QEMU driver.
+const DiskCacheMountOpt = "cache"
+
+// DiskIOThreadMountOpt indicates the mount option used to request a dedicated I/O thread from the QEMU driver.
+const DiskIOThreadMountOpt = "iothread"
+
 // DiskFileDescriptorMountPrefix indicates the mount dev path is using a file descriptor rather than a normal path.
 // The Mount.DevPath field will be expected to be in the format: "fd:<fdNum>:<devPath>".
 // It still includes the original dev path so that the instance driver can perform additional probing of the path
@@ -75,6 +84,21 @@ type disk struct {
 	restrictedParentSourcePath string
 }
 
+// CanHotPlug returns whether the device can be managed whilst the instance is running.
+func (d *disk) CanHotPlug() bool {
+	if d.inst.Type() == instancetype.Container {
+		return true
+	}
+
+	// The root disk can't be changed whilst a VM is running and Ceph RBD disks can only be setup by QEMU
+	// when it starts.
+	if shared.IsRootDiskDevice(d.config) || strings.HasPrefix(d.config["source"], "ceph:") {
+		return false
+	}
+
+	return true
+}
+
 // CanMigrate returns whether the device can be migrated to any other cluster member.
 func (d *disk) CanMigrate() bool {
 	// Root disk is always migratable.
@@ -162,6 +186,9 @@ func (d *disk) validateConfig(instConf instance.ConfigReader) error {
 		"ceph.user_name":    validate.IsAny,
 		"boot.priority":     validate.Optional(validate.IsUint32),
 		"path":              validate.IsAny,
+		"io.bus":            validate.Optional(validate.IsOneOf("nvme", "virtio-blk", "virtio-scsi")),
+		"io.cache":          validate.Optional(validate.IsOneOf("none", "writeback", "unsafe")),
+		"io.threads":        validate.Optional(validate.IsBool),
 	}
 
 	err := d.config.Validate(rules)
@@ -201,6 +228,14 @@ func (d *disk) validateConfig(instConf instance.ConfigReader) error {
 		return fmt.Errorf("Recursive read-only bind-mounts aren't currently supported by the kernel")
 	}
 
+	if instConf.Type() == instancetype.Container && (d.config["io.bus"] != "" || d.config["io.cache"] != "" || d.config["io.threads"] != "") {
+		return fmt.Errorf("The io.bus, io.cache and io.threads options are only supported for virtual machines")
+	}
+
+	if d.config["io.bus"] == "nvme" && shared.IsTrue(d.config["io.threads"]) {
+		return fmt.Errorf("Dedicated I/O threads aren't supported for disks using the nvme bus")
+	}
+
 	// Check ceph options are only used when ceph or cephfs type source is specified.
 	if !shared.StringHasPrefix(d.config["source"], "ceph:", "cephfs:") && (d.config["ceph.cluster_name"] != "" || d.config["ceph.user_name"] != "") {
 		return fmt.Errorf("Invalid options ceph.cluster_name/ceph.user_name for source %q", d.config["source"])
@@ -294,7 +329,7 @@ func (d *disk) validateConfig(instConf instance.ConfigReader) error {
 				return err
 			}
 
-			if contentType == db.StoragePoolVolumeContentTypeBlock {
+			if contentType == db.StoragePoolVolumeContentTypeBlock || contentType == db.StoragePoolVolumeContentTypeISO {
 				if instConf.Type() == instancetype.Container {
 					return fmt.Errorf("Custom block volumes cannot be used on containers")
 				}
@@ -558,7 +593,7 @@ func (d *disk) startContainer() (*deviceConfig.RunConfig, error) {
 			var err error
 			var revertFunc func()
 
-			revertFunc, srcPath, err = d.mountPoolVolume()
+			revertFunc, srcPath, _, err = d.mountPoolVolume()
 			if err != nil {
 				return nil, diskSourceNotFoundError{msg: "Failed mounting volume", err: err}
 			}
@@ -611,6 +646,25 @@ func (d *disk) vmVirtiofsdPaths() (string, string) {
 	return sockPath, pidPath
 }
 
+// vmDriveOpts returns the mount options used to pass the disk's I/O settings to the QEMU driver.
+func (d *disk) vmDriveOpts() []string {
+	opts := []string{}
+
+	if d.config["io.bus"] != "" {
+		opts = append(opts, fmt.Sprintf("%s=%s", DiskBusMountOpt, d.config["io.bus"]))
+	}
+
+	if d.config["io.cache"] != "" {
+		opts = append(opts, fmt.Sprintf("%s=%s", DiskCacheMountOpt, d.config["io.cache"]))
+	}
+
+	if shared.IsTrue(d.config["io.threads"]) {
+		opts = append(opts, DiskIOThreadMountOpt)
+	}
+
+	return opts
+}
+
 // startVM starts the disk device for a virtual machine instance.
 func (d *disk) startVM() (*deviceConfig.RunConfig, error) {
 	runConf := deviceConfig.RunConfig{
@@ -628,6 +682,7 @@ func (d *disk) startVM() (*deviceConfig.RunConfig, error) {
 			{
 				TargetPath: d.config["path"], // Indicator used that this is the root device.
 				DevName:    d.name,
+				Opts:       d.vmDriveOpts(),
 			},
 		}
 
@@ -644,6 +699,7 @@ func (d *disk) startVM() (*deviceConfig.RunConfig, error) {
 				DevPath: isoPath,
 				DevName: d.name,
 				FSType:  "iso9660",
+				Opts:    d.vmDriveOpts(),
 			},
 		}
 
@@ -672,6 +728,7 @@ func (d *disk) startVM() (*deviceConfig.RunConfig, error) {
 				{
 					DevPath: fmt.Sprintf("rbd:%s/%s:%s", optEscaper.Replace(poolName), optEscaper.Replace(volumeName), strings.Join(opts, ":")),
 					DevName: d.name,
+					Opts:    d.vmDriveOpts(),
 				},
 			}
 		} else {
@@ -681,14 +738,18 @@ func (d *disk) startVM() (*deviceConfig.RunConfig, error) {
 			// Mount the pool volume and update srcPath to mount path so it can be recognised as dir
 			// if the volume is a filesystem volume type (if it is a block volume the srcPath will
 			// be returned as the path to the block device).
+			isISO := false
 			if d.config["pool"] != "" {
 				var revertFunc func()
+				var vol *api.StorageVolume
 
-				revertFunc, srcPath, err = d.mountPoolVolume()
+				revertFunc, srcPath, vol, err = d.mountPoolVolume()
 				if err != nil {
 					return nil, diskSourceNotFoundError{msg: "Failed mounting volume", err: err}
 				}
 				revert.Add(revertFunc)
+
+				isISO = vol.ContentType == db.StoragePoolVolumeContentTypeNameISO
 			}
 
 			// Default to block device or image file passthrough first.
@@ -697,14 +758,25 @@ func (d *disk) startVM() (*deviceConfig.RunConfig, error) {
 				DevName: d.name,
 			}
 
-			if shared.IsTrue(d.config["readonly"]) {
+			// ISO volumes are always attached read-only as a CD-ROM.
+			if isISO {
+				mount.FSType = "iso9660"
+			}
+
+			if shared.IsTrue(d.config["readonly"]) || isISO {
 				mount.Opts = append(mount.Opts, "ro")
 			}
 
+			mount.Opts = append(mount.Opts, d.vmDriveOpts()...)
+
 			// If the source being added is a directory or cephfs share, then we will use the lxd-agent
 			// directory sharing feature to mount the directory inside the VM, and as such we need to
 			// indicate to the VM the target path to mount to.
 			if shared.IsDir(srcPath) || strings.HasPrefix(d.config["source"], "cephfs:") {
+				if d.config["io.bus"] != "" || d.config["io.cache"] != "" || d.config["io.threads"] != "" {
+					return nil, fmt.Errorf("The io.bus, io.cache and io.threads options can't be used with directory shares")
+				}
+
 				// Mount the source in the instance devices directory.
 				// This will ensure that if the exported directory configured as readonly that this
 				// takes effect event if using virtio-fs (which doesn't support read only mode) by
@@ -1056,8 +1128,8 @@ func (w *cgroupWriter) Set(version cgroup.Backend, controller string, key string
 }
 
 // mountPoolVolume mounts the pool volume specified in d.config["source"] from pool specified in d.config["pool"]
-// and return the mount path. If the instance type is container volume will be shifted if needed.
-func (d *disk) mountPoolVolume() (func(), string, error) {
+// and return the mount path and the volume. If the instance type is container volume will be shifted if needed.
+func (d *disk) mountPoolVolume() (func(), string, *api.StorageVolume, error) {
 	revert := revert.New()
 	defer revert.Fail()
 
@@ -1069,7 +1141,7 @@ func (d *disk) mountPoolVolume() (func(), string, error) {
 	// Currently, <type> must either be empty or "custom".
 	// We do not yet support instance mounts.
 	if filepath.IsAbs(d.config["source"]) {
-		return nil, "", fmt.Errorf(`When the "pool" property is set "source" must specify the name of a volume, not a path`)
+		return nil, "", nil, fmt.Errorf(`When the "pool" property is set "source" must specify the name of a volume, not a path`)
 	}
 
 	volumeTypeName := ""
@@ -1087,7 +1159,7 @@ func (d *disk) mountPoolVolume() (func(), string, error) {
 	// Check volume type name is custom.
 	switch volumeTypeName {
 	case db.StoragePoolVolumeTypeNameContainer:
-		return nil, "", fmt.Errorf("Using instance storage volumes is not supported")
+		return nil, "", nil, fmt.Errorf("Using instance storage volumes is not supported")
 	case "":
 		// We simply received the name of a storage volume.
 		volumeTypeName = db.StoragePoolVolumeTypeNameCustom
@@ -1095,15 +1167,15 @@ func (d *disk) mountPoolVolume() (func(), string, error) {
 	case db.StoragePoolVolumeTypeNameCustom:
 		break
 	case db.StoragePoolVolumeTypeNameImage:
-		return nil, "", fmt.Errorf("Using image storage volumes is not supported")
+		return nil, "", nil, fmt.Errorf("Using image storage volumes is not supported")
 	default:
-		return nil, "", fmt.Errorf("Unknown storage type prefix %q found", volumeTypeName)
+		return nil, "", nil, fmt.Errorf("Unknown storage type prefix %q found", volumeTypeName)
 	}
 
 	// Only custom volumes can be attached currently.
 	storageProjectName, err := project.StorageVolumeProject(d.state.Cluster, d.inst.Project(), db.StoragePoolVolumeTypeCustom)
 	if err != nil {
-		return nil, "", err
+		return nil, "", nil, err
 	}
 
 	volStorageName := project.StorageVolume(storageProjectName, volumeName)
@@ -1111,41 +1183,41 @@ func (d *disk) mountPoolVolume() (func(), string, error) {
 
 	pool, err := storagePools.GetPoolByName(d.state, d.config["pool"])
 	if err != nil {
-		return nil, "", err
+		return nil, "", nil, err
 	}
 
 	err = pool.MountCustomVolume(storageProjectName, volumeName, nil)
 	if err != nil {
-		return nil, "", errors.Wrapf(err, "Failed mounting storage volume %q of type %q on storage pool %q", volumeName, volumeTypeName, pool.Name())
+		return nil, "", nil, errors.Wrapf(err, "Failed mounting storage volume %q of type %q on storage pool %q", volumeName, volumeTypeName, pool.Name())
 	}
 	revert.Add(func() { pool.UnmountCustomVolume(storageProjectName, volumeName, nil) })
 
 	_, vol, err := d.state.Cluster.GetLocalStoragePoolVolume(storageProjectName, volumeName, db.StoragePoolVolumeTypeCustom, pool.ID())
 	if err != nil {
-		return nil, "", errors.Wrapf(err, "Failed to fetch local storage volume record")
+		return nil, "", nil, errors.Wrapf(err, "Failed to fetch local storage volume record")
 	}
 
 	if d.inst.Type() == instancetype.Container {
 		if vol.ContentType == db.StoragePoolVolumeContentTypeNameFS {
 			err = d.storagePoolVolumeAttachShift(storageProjectName, pool.Name(), volumeName, db.StoragePoolVolumeTypeCustom, srcPath)
 			if err != nil {
-				return nil, "", errors.Wrapf(err, "Failed shifting storage volume %q of type %q on storage pool %q", volumeName, volumeTypeName, pool.Name())
+				return nil, "", nil, errors.Wrapf(err, "Failed shifting storage volume %q of type %q on storage pool %q", volumeName, volumeTypeName, pool.Name())
 			}
 		} else {
-			return nil, "", fmt.Errorf("Only filesystem volumes are supported for containers")
+			return nil, "", nil, fmt.Errorf("Only filesystem volumes are supported for containers")
 		}
 	}
 
-	if vol.ContentType == db.StoragePoolVolumeContentTypeNameBlock {
+	if vol.ContentType == db.StoragePoolVolumeContentTypeNameBlock || vol.ContentType == db.StoragePoolVolumeContentTypeNameISO {
 		srcPath, err = pool.GetCustomVolumeDisk(storageProjectName, volumeName)
 		if err != nil {
-			return nil, "", errors.Wrapf(err, "Failed to get disk path")
+			return nil, "", nil, errors.Wrapf(err, "Failed to get disk path")
 		}
 	}
 
 	revertExternal := revert.Clone() // Clone before calling revert.Success() so we can return the Fail func.
 	revert.Success()
-	return revertExternal.Fail, srcPath, err
+	return revertExternal.Fail, srcPath, vol, err
 }
 
 // createDevice creates a disk device mount on host.
@@ -93,8 +93,8 @@ func (d *nicBridged) validateConfig(instConf instance.ConfigReader) error {
 			return fmt.Errorf("Specified network is not fully created")
 		}
 
-		if n.Type() != "bridge" {
-			return fmt.Errorf("Specified network must be of type bridge")
+		if !shared.StringInSlice(n.Type(), []string{"bridge", "overlay"}) {
+			return fmt.Errorf("Specified network must be of type bridge or overlay")
 		}
 
 		netConfig := n.Config()
@@ -566,11 +566,16 @@ func (d *nicBridged) Start() (*deviceConfig.RunConfig, error) {
 				return nil, fmt.Errorf("Failed loading network forwards: %w", err)
 			}
 
-			// If br_netfilter is enabled and bridge has forwards, we enable hairpin mode on NIC's
-			// bridge port in case any of the forwards target this NIC and the instance attempts to
-			// connect to the forward's listener. Without hairpin mode on the target of the forward
-			// will not be able to connect to the listener.
-			if len(listenAddresses) > 0 {
+			lbListenAddresses, err := d.state.Cluster.GetNetworkLoadBalancerListenAddresses(d.network.ID(), true)
+			if err != nil {
+				return nil, fmt.Errorf("Failed loading network load balancers: %w", err)
+			}
+
+			// If br_netfilter is enabled and bridge has forwards or load balancers, we enable hairpin
+			// mode on NIC's bridge port in case any of them target this NIC and the instance attempts
+			// to connect to the listener. Without hairpin mode on the target will not be able to
+			// connect to the listener.
+			if len(listenAddresses) > 0 || len(lbListenAddresses) > 0 {
 				link := &ip.Link{Name: saveData["host_name"]}
 				err = link.BridgeLinkSetHairpin(true)
 				if err != nil {
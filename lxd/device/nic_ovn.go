@@ -14,6 +14,7 @@ import (
 	"github.com/lxc/lxd/lxd/dnsmasq/dhcpalloc"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/instance/operationlock"
 	"github.com/lxc/lxd/lxd/ip"
 	"github.com/lxc/lxd/lxd/network"
 	"github.com/lxc/lxd/lxd/network/acl"
@@ -155,6 +156,11 @@ func (d *nicOVN) validateConfig(instConf instance.ConfigReader) error {
 		if ip.Equal(net.ParseIP(d.config["ipv4.address"])) {
 			return fmt.Errorf("IP address %q is assigned to parent managed network device %q", d.config["ipv4.address"], d.config["parent"])
 		}
+
+		// IP should not be the address reserved for the network's load balancer health checks.
+		if network.OVNLoadBalancerHealthCheckIPv4(subnet).Equal(net.ParseIP(d.config["ipv4.address"])) {
+			return fmt.Errorf("IP address %q is reserved for load balancer health checks on network %q", d.config["ipv4.address"], d.config["network"])
+		}
 	}
 
 	if d.config["ipv6.address"] != "" {
@@ -507,15 +513,20 @@ func (d *nicOVN) Stop() (*deviceConfig.RunConfig, error) {
 		}
 	}
 
-	instanceUUID := d.inst.LocalConfig()["volatile.uuid"]
-	err = d.network.InstanceDevicePortDelete(ovsExternalOVNPort, &network.OVNInstanceNICStopOpts{
-		InstanceUUID: instanceUUID,
-		DeviceName:   d.name,
-		DeviceConfig: d.config,
-	})
-	if err != nil {
-		// Don't fail here as we still want the postStop hook to run to clean up the local veth pair.
-		d.logger.Error("Failed to remove OVN device port", log.Ctx{"err": err})
+	// If the instance has been live migrated then the logical switch port is now in use by the instance on
+	// the target member, so leave it in place.
+	op := operationlock.Get(d.inst.Project(), d.inst.Name())
+	if op == nil || op.Action() != operationlock.ActionMigrate {
+		instanceUUID := d.inst.LocalConfig()["volatile.uuid"]
+		err = d.network.InstanceDevicePortDelete(ovsExternalOVNPort, &network.OVNInstanceNICStopOpts{
+			InstanceUUID: instanceUUID,
+			DeviceName:   d.name,
+			DeviceConfig: d.config,
+		})
+		if err != nil {
+			// Don't fail here as we still want the postStop hook to run to clean up the local veth pair.
+			d.logger.Error("Failed to remove OVN device port", log.Ctx{"err": err})
+		}
 	}
 
 	// Remove BGP announcements.
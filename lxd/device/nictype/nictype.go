@@ -33,7 +33,7 @@ func NICType(s *state.State, deviceProjectName string, d deviceConfig.Device) (s
 
 			var nicType string
 			switch netInfo.Type {
-			case "bridge":
+			case "bridge", "overlay":
 				nicType = "bridged"
 			case "macvlan":
 				nicType = "macvlan"
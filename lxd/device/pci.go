@@ -19,6 +19,11 @@ type pci struct {
 	deviceCommon
 }
 
+// CanHotPlug returns whether the device can be managed whilst the instance is running.
+func (d *pci) CanHotPlug() bool {
+	return true
+}
+
 // validateConfig checks the supplied config for correctness.
 func (d *pci) validateConfig(instConf instance.ConfigReader) error {
 	if !instanceSupported(instConf.Type(), instancetype.VM) {
@@ -30,10 +30,22 @@ func usbIsOurDevice(config deviceConfig.Device, usb *USBEvent) bool {
 	return true
 }
 
+// usbVMDeviceName returns the name of the QEMU device used to pass a USB device through to a VM.
+// The name is based on the host bus and device numbers so that the same QEMU device can be found again
+// when the USB device is removed from the host.
+func usbVMDeviceName(deviceName string, usb *USBEvent) string {
+	return fmt.Sprintf("%s-%03d-%03d", deviceName, usb.BusNum, usb.DevNum)
+}
+
 type usb struct {
 	deviceCommon
 }
 
+// CanHotPlug returns whether the device can be managed whilst the instance is running.
+func (d *usb) CanHotPlug() bool {
+	return true
+}
+
 // isRequired indicates whether the device config requires this device to start OK.
 func (d *usb) isRequired() bool {
 	// Defaults to not required.
@@ -79,6 +91,7 @@ func (d *usb) Register() error {
 	devConfig := d.config
 	deviceName := d.name
 	state := d.state
+	instType := d.inst.Type()
 
 	// Handler for when a USB event occurs.
 	f := func(e USBEvent) (*deviceConfig.RunConfig, error) {
@@ -88,7 +101,13 @@ func (d *usb) Register() error {
 
 		runConf := deviceConfig.RunConfig{}
 
-		if e.Action == "add" {
+		if instType == instancetype.VM {
+			// The instance attaches or detaches the USB device based on the uevent action.
+			runConf.USBDevice = append(runConf.USBDevice, deviceConfig.USBDeviceItem{
+				DeviceName:     usbVMDeviceName(deviceName, &e),
+				HostDevicePath: fmt.Sprintf("/dev/bus/usb/%03d/%03d", e.BusNum, e.DevNum),
+			})
+		} else if e.Action == "add" {
 			err := unixDeviceSetupCharNum(state, devicesPath, "unix", deviceName, devConfig, e.Major, e.Minor, e.Path, false, &runConf)
 			if err != nil {
 				return nil, err
@@ -173,7 +192,7 @@ func (d *usb) startVM() (*deviceConfig.RunConfig, error) {
 	for _, usb := range usbs {
 		if usbIsOurDevice(d.config, &usb) {
 			runConf.USBDevice = append(runConf.USBDevice, deviceConfig.USBDeviceItem{
-				DeviceName:     fmt.Sprintf("%s-%d", d.name, len(runConf.USBDevice)),
+				DeviceName:     usbVMDeviceName(d.name, &usb),
 				HostDevicePath: fmt.Sprintf("/dev/bus/usb/%03d/%03d", usb.BusNum, usb.DevNum),
 			})
 		}
@@ -192,14 +211,30 @@ func (d *usb) Stop() (*deviceConfig.RunConfig, error) {
 		PostHooks: []func() error{d.postStop},
 	}
 
-	if d.inst.Type() == instancetype.Container {
-		// Unregister any USB event handlers for this device.
-		usbUnregisterHandler(d.inst, d.name)
+	// Unregister any USB event handlers for this device.
+	usbUnregisterHandler(d.inst, d.name)
 
+	if d.inst.Type() == instancetype.Container {
 		err := unixDeviceRemove(d.inst.DevicesPath(), "unix", d.name, "", &runConf)
 		if err != nil {
 			return nil, err
 		}
+	} else if d.inst.Type() == instancetype.VM {
+		// Provide the USB devices currently passed through so they can be detached from a running VM.
+		// Any that have been removed from the host have already been detached.
+		usbs, err := d.loadUsb()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, usb := range usbs {
+			if usbIsOurDevice(d.config, &usb) {
+				runConf.USBDevice = append(runConf.USBDevice, deviceConfig.USBDeviceItem{
+					DeviceName:     usbVMDeviceName(d.name, &usb),
+					HostDevicePath: fmt.Sprintf("/dev/bus/usb/%03d/%03d", usb.BusNum, usb.DevNum),
+				})
+			}
+		}
 	}
 
 	return &runConf, nil
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -10,20 +13,27 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	"golang.org/x/sys/unix"
 
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/daemon"
+	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/lifecycle"
+	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/ucred"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/validate"
 	"github.com/lxc/lxd/shared/version"
 )
 
@@ -31,11 +41,19 @@ import (
 // /dev/lxd Unix socket endpoint created inside containers.
 func devLxdServer(d *Daemon) *http.Server {
 	return &http.Server{
-		Handler:   devLxdAPI(d),
+		Handler:   devLxdAPI(d, hoistReq),
 		ConnState: pidMapper.ConnStateHandler,
 	}
 }
 
+// devLxdVsockServer creates an http.Server capable of handling requests against the
+// /dev/lxd API relayed by the lxd-agent from inside VMs over vsock.
+func devLxdVsockServer(d *Daemon) *http.Server {
+	return &http.Server{
+		Handler: devLxdAPI(d, hoistReqVM),
+	}
+}
+
 type devLxdResponse struct {
 	content interface{}
 	code    int
@@ -58,6 +76,12 @@ type devLxdHandler struct {
 	f func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse
 }
 
+// devlxdMaxConfigKeys is the number of user.* keys past which no new key can be written over devlxd.
+const devlxdMaxConfigKeys = 128
+
+// devlxdMaxConfigSize is the total size of the user.* keys and values past which they can't be grown over devlxd.
+const devlxdMaxConfigSize = 1024 * 1024
+
 var devlxdConfigGet = devLxdHandler{"/1.0/config", func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
 	filtered := []string{}
 	for k := range c.ExpandedConfig() {
@@ -68,7 +92,18 @@ var devlxdConfigGet = devLxdHandler{"/1.0/config", func(d *Daemon, c instance.In
 	return okResponse(filtered, "json")
 }}
 
-var devlxdConfigKeyGet = devLxdHandler{"/1.0/config/{key}", func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+var devlxdConfigKeyHandler = devLxdHandler{"/1.0/config/{key}", func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+	switch r.Method {
+	case "GET":
+		return devlxdConfigKeyGetHandler(d, c, w, r)
+	case "PUT", "DELETE":
+		return devlxdConfigKeyPutHandler(d, c, w, r)
+	}
+
+	return &devLxdResponse{fmt.Sprintf("method %q not allowed", r.Method), http.StatusBadRequest, "raw"}
+}}
+
+func devlxdConfigKeyGetHandler(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
 	key := mux.Vars(r)["key"]
 	if !strings.HasPrefix(key, "user.") && !strings.HasPrefix(key, "cloud-init.") {
 		return &devLxdResponse{"not authorized", http.StatusForbidden, "raw"}
@@ -80,7 +115,95 @@ var devlxdConfigKeyGet = devLxdHandler{"/1.0/config/{key}", func(d *Daemon, c in
 	}
 
 	return okResponse(value, "raw")
-}}
+}
+
+// devlxdConfigKeyPutHandler sets (PUT) or removes (DELETE) a user.* key in the instance's local config.
+// Requires security.devlxd.config, and limits the number and total size of the instance's user.* keys.
+func devlxdConfigKeyPutHandler(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+	if !shared.IsTrue(c.ExpandedConfig()["security.devlxd.config"]) {
+		return &devLxdResponse{"not authorized", http.StatusForbidden, "raw"}
+	}
+
+	key := mux.Vars(r)["key"]
+	if !strings.HasPrefix(key, "user.") {
+		return &devLxdResponse{"not authorized", http.StatusForbidden, "raw"}
+	}
+
+	value := ""
+	if r.Method == "PUT" {
+		buf, err := ioutil.ReadAll(io.LimitReader(r.Body, devlxdMaxConfigSize+1))
+		if err != nil {
+			return &devLxdResponse{"bad request", http.StatusBadRequest, "raw"}
+		}
+
+		if len(buf) > devlxdMaxConfigSize {
+			return &devLxdResponse{"value too large", http.StatusRequestEntityTooLarge, "raw"}
+		}
+
+		value = string(buf)
+	}
+
+	config := make(map[string]string, len(c.LocalConfig()))
+	for k, v := range c.LocalConfig() {
+		config[k] = v
+	}
+
+	if value == "" {
+		_, ok := config[key]
+		if !ok {
+			return okResponse("", "raw")
+		}
+
+		delete(config, key)
+	} else {
+		oldValue, ok := config[key]
+		config[key] = value
+
+		// Check the limits, but only when growing so that the keys can always be shrunk or removed.
+		keys, size := devlxdUserConfigUsage(config)
+		if !ok && keys > devlxdMaxConfigKeys {
+			return &devLxdResponse{"too many keys", http.StatusRequestEntityTooLarge, "raw"}
+		}
+
+		if len(value) > len(oldValue) && size > devlxdMaxConfigSize {
+			return &devLxdResponse{"config too large", http.StatusRequestEntityTooLarge, "raw"}
+		}
+	}
+
+	args := db.InstanceArgs{
+		Architecture: c.Architecture(),
+		Config:       config,
+		Description:  c.Description(),
+		Devices:      c.LocalDevices(),
+		Ephemeral:    c.IsEphemeral(),
+		Profiles:     c.Profiles(),
+		Project:      c.Project(),
+		Type:         c.Type(),
+		Snapshot:     c.IsSnapshot(),
+	}
+
+	err := c.Update(args, true)
+	if err != nil {
+		logger.Error("Failed updating instance config over devlxd", log.Ctx{"project": c.Project(), "instance": c.Name(), "key": key, "err": err})
+		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+	}
+
+	return okResponse("", "raw")
+}
+
+// devlxdUserConfigUsage returns the number of user.* keys in the config and their total size (keys and values).
+func devlxdUserConfigUsage(config map[string]string) (int, int) {
+	keys := 0
+	size := 0
+	for k, v := range config {
+		if strings.HasPrefix(k, "user.") {
+			keys++
+			size += len(k) + len(v)
+		}
+	}
+
+	return keys, size
+}
 
 var devlxdImageExport = devLxdHandler{"/1.0/images/{fingerprint}/export", func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
 	if !shared.IsTrue(c.ExpandedConfig()["security.devlxd.images"]) {
@@ -127,7 +250,18 @@ var devlxdEventsGet = devLxdHandler{"/1.0/events", func(d *Daemon, c instance.In
 	return &devLxdResponse{"websocket", http.StatusOK, "websocket"}
 }}
 
-var devlxdAPIGet = devLxdHandler{"/1.0", func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+var devlxdAPIHandler = devLxdHandler{"/1.0", func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+	switch r.Method {
+	case "GET":
+		return devlxdAPIGetHandler(d, c, w, r)
+	case "PATCH":
+		return devlxdAPIPatchHandler(d, c, w, r)
+	}
+
+	return &devLxdResponse{fmt.Sprintf("method %q not allowed", r.Method), http.StatusBadRequest, "raw"}
+}}
+
+func devlxdAPIGetHandler(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
 	location := "none"
 	clustered, err := cluster.Enabled(d.db)
 	if err != nil {
@@ -137,7 +271,127 @@ var devlxdAPIGet = devLxdHandler{"/1.0", func(d *Daemon, c instance.Instance, w
 	if clustered {
 		location = c.Location()
 	}
-	return okResponse(shared.Jmap{"api_version": version.APIVersion, "location": location, "instance_type": c.Type().String()}, "json")
+
+	state := api.Started
+	if shared.IsTrue(c.LocalConfig()["volatile.last_state.ready"]) {
+		state = api.Ready
+	}
+
+	return okResponse(api.DevLXDGet{
+		DevLXDPut:    api.DevLXDPut{State: state.String()},
+		APIVersion:   version.APIVersion,
+		Location:     location,
+		InstanceType: c.Type().String(),
+	}, "json")
+}
+
+// devlxdAPIPatchHandler records the state reported by the workload (Ready or Started).
+func devlxdAPIPatchHandler(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+	req := api.DevLXDPut{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return &devLxdResponse{"bad request", http.StatusBadRequest, "raw"}
+	}
+
+	switch req.State {
+	case api.Ready.String():
+		if shared.IsTrue(c.LocalConfig()["volatile.last_state.ready"]) {
+			return okResponse("", "raw")
+		}
+
+		err = c.VolatileSet(map[string]string{"volatile.last_state.ready": "true"})
+		if err != nil {
+			return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+		}
+
+		d.State().Events.SendLifecycle(c.Project(), lifecycle.InstanceReady.Event(c, nil))
+	case api.Started.String():
+		err = c.VolatileSet(map[string]string{"volatile.last_state.ready": ""})
+		if err != nil {
+			return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+		}
+	default:
+		return &devLxdResponse{fmt.Sprintf("invalid state %q", req.State), http.StatusBadRequest, "raw"}
+	}
+
+	return okResponse("", "raw")
+}
+
+var devlxdSnapshotsPost = devLxdHandler{"/1.0/snapshots", func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+	if r.Method != "POST" {
+		return &devLxdResponse{fmt.Sprintf("method %q not allowed", r.Method), http.StatusBadRequest, "raw"}
+	}
+
+	if !shared.IsTrue(c.ExpandedConfig()["security.devlxd.snapshots"]) {
+		return &devLxdResponse{"not authorized", http.StatusForbidden, "raw"}
+	}
+
+	req := api.DevLXDSnapshotsPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		return &devLxdResponse{"bad request", http.StatusBadRequest, "raw"}
+	}
+
+	var proj *db.Project
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		proj, err = tx.GetProject(c.Project())
+		return err
+	})
+	if err != nil {
+		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+	}
+
+	err = project.AllowSnapshotCreation(proj)
+	if err != nil {
+		return &devLxdResponse{err.Error(), http.StatusForbidden, "raw"}
+	}
+
+	if req.Name == "" {
+		req.Name, err = instance.NextSnapshotName(d.State(), c, "snap%d")
+		if err != nil {
+			return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+		}
+	}
+
+	err = validate.IsURLSegmentSafe(req.Name)
+	if err != nil || strings.Contains(req.Name, shared.SnapshotDelimiter) {
+		return &devLxdResponse{fmt.Sprintf("invalid snapshot name %q", req.Name), http.StatusBadRequest, "raw"}
+	}
+
+	expiry, err := shared.GetSnapshotExpiry(time.Now(), c.ExpandedConfig()["snapshots.expiry"])
+	if err != nil {
+		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+	}
+
+	snapshot := func(op *operations.Operation) error {
+		c.SetOperation(op)
+		return c.Snapshot(req.Name, expiry, false)
+	}
+
+	resources := map[string][]string{}
+	resources["instances"] = []string{c.Name()}
+
+	if c.Type() == instancetype.Container {
+		resources["containers"] = resources["instances"]
+	}
+
+	op, err := operations.OperationCreate(d.State(), c.Project(), operations.OperationClassTask, db.OperationSnapshotCreate, resources, nil, snapshot, nil, nil, nil)
+	if err != nil {
+		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+	}
+
+	// Wait for the snapshot to be taken so the workload knows when it's safe to proceed.
+	opRun, err := op.Run()
+	if err == nil {
+		err = <-opRun
+	}
+
+	if err != nil {
+		logger.Error("Failed creating snapshot over devlxd", log.Ctx{"project": c.Project(), "instance": c.Name(), "snapshot": req.Name, "err": err})
+		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+	}
+
+	return okResponse(api.DevLXDSnapshotsPost{Name: req.Name}, "json")
 }}
 
 var devlxdDevicesGet = devLxdHandler{"/1.0/devices", func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
@@ -148,13 +402,14 @@ var handlers = []devLxdHandler{
 	{"/", func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
 		return okResponse([]string{"/1.0"}, "json")
 	}},
-	devlxdAPIGet,
+	devlxdAPIHandler,
 	devlxdConfigGet,
-	devlxdConfigKeyGet,
+	devlxdConfigKeyHandler,
 	devlxdMetadataGet,
 	devlxdEventsGet,
 	devlxdImageExport,
 	devlxdDevicesGet,
+	devlxdSnapshotsPost,
 }
 
 func hoistReq(f func(*Daemon, instance.Instance, http.ResponseWriter, *http.Request) *devLxdResponse, d *Daemon) func(http.ResponseWriter, *http.Request) {
@@ -186,30 +441,68 @@ func hoistReq(f func(*Daemon, instance.Instance, http.ResponseWriter, *http.Requ
 			return
 		}
 
-		resp := f(d, c, w, r)
-		if resp.code != http.StatusOK {
-			http.Error(w, fmt.Sprintf("%s", resp.content), resp.code)
-		} else if resp.ctype == "json" {
-			w.Header().Set("Content-Type", "application/json")
+		devLxdRender(w, f(d, c, w, r))
+	}
+}
+
+// hoistReqVM identifies the VM a request relayed by the lxd-agent comes from using the vsock
+// context ID of the connection and checks that it was made with that VM's agent certificate.
+func hoistReqVM(f func(*Daemon, instance.Instance, http.ResponseWriter, *http.Request) *devLxdResponse, d *Daemon) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var contextID, port uint32
+		_, err := fmt.Sscanf(r.RemoteAddr, "vm(%d):%d", &contextID, &port)
+		if err != nil {
+			http.Error(w, "Request not from a VM", http.StatusBadRequest)
+			return
+		}
+
+		vm, err := findVMForContextID(contextID, d.State())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-			var debugLogger logger.Logger
-			if daemon.Debug {
-				debugLogger = logger.Logger(logger.Log)
-			}
+		// Access control
+		agentCert := vm.AgentCertificate()
+		if agentCert == nil || r.TLS == nil || len(r.TLS.PeerCertificates) < 1 || !bytes.Equal(r.TLS.PeerCertificates[0].Raw, agentCert.Raw) {
+			http.Error(w, "Access denied for unknown agent certificate", http.StatusUnauthorized)
+			return
+		}
+
+		devlxdEnabled := vm.ExpandedConfig()["security.devlxd"]
+		if devlxdEnabled != "" && !shared.IsTrue(devlxdEnabled) {
+			http.Error(w, "Access denied as security.devlxd is disabled", http.StatusUnauthorized)
+			return
+		}
+
+		devLxdRender(w, f(d, vm, w, r))
+	}
+}
+
+// devLxdRender writes a devlxd handler response.
+func devLxdRender(w http.ResponseWriter, resp *devLxdResponse) {
+	if resp.code != http.StatusOK {
+		http.Error(w, fmt.Sprintf("%s", resp.content), resp.code)
+	} else if resp.ctype == "json" {
+		w.Header().Set("Content-Type", "application/json")
 
-			util.WriteJSON(w, resp.content, debugLogger)
-		} else if resp.ctype != "websocket" {
-			w.Header().Set("Content-Type", "application/octet-stream")
-			fmt.Fprintf(w, resp.content.(string))
+		var debugLogger logger.Logger
+		if daemon.Debug {
+			debugLogger = logger.Logger(logger.Log)
 		}
+
+		util.WriteJSON(w, resp.content, debugLogger)
+	} else if resp.ctype != "websocket" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		fmt.Fprintf(w, resp.content.(string))
 	}
 }
 
-func devLxdAPI(d *Daemon) http.Handler {
+func devLxdAPI(d *Daemon, hoist func(f func(*Daemon, instance.Instance, http.ResponseWriter, *http.Request) *devLxdResponse, d *Daemon) func(http.ResponseWriter, *http.Request)) http.Handler {
 	m := mux.NewRouter()
 
 	for _, handler := range handlers {
-		m.HandleFunc(handler.path, hoistReq(handler.f, d))
+		m.HandleFunc(handler.path, hoist(handler.f, d))
 	}
 
 	return m
@@ -286,6 +579,28 @@ func (m *ConnPidMapper) ConnStateHandler(conn net.Conn, state http.ConnState) {
 
 var pidNotInContainerErr = fmt.Errorf("pid not in container?")
 
+// findVMForContextID returns the running VM on this server using the given vsock context ID.
+func findVMForContextID(contextID uint32, s *state.State) (instance.VM, error) {
+	instances, err := instance.LoadNodeAll(s, instancetype.VM)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, inst := range instances {
+		if inst.LocalConfig()["volatile.vsock_id"] != strconv.FormatUint(uint64(contextID), 10) {
+			continue
+		}
+
+		if !inst.IsRunning() {
+			continue
+		}
+
+		return inst.(instance.VM), nil
+	}
+
+	return nil, fmt.Errorf("No VM found for vsock context ID %d", contextID)
+}
+
 func findContainerForPid(pid int32, s *state.State) (instance.Container, error) {
 	/*
 	 * Try and figure out which container a pid is in. There is probably a
@@ -32,8 +32,8 @@ func (d dnsHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 		return
 	}
 
-	// Check that it's AXFR.
-	if r.Question[0].Qtype != dns.TypeAXFR {
+	// Only handle standard queries.
+	if r.Opcode != dns.OpcodeQuery {
 		m := new(dns.Msg)
 		m.SetRcode(r, dns.RcodeNotImplemented)
 		w.WriteMsg(m)
@@ -41,7 +41,6 @@ func (d dnsHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	}
 
 	// Extract the request information.
-	name := strings.TrimSuffix(r.Question[0].Name, ".")
 	ip, _, err := net.SplitHostPort(w.RemoteAddr().String())
 	if err != nil {
 		m := new(dns.Msg)
@@ -50,38 +49,174 @@ func (d dnsHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 		return
 	}
 
+	switch r.Question[0].Qtype {
+	case dns.TypeAXFR, dns.TypeIXFR:
+		d.serveTransfer(w, r, ip)
+	default:
+		d.serveQuery(w, r, ip)
+	}
+}
+
+// serveTransfer handles zone transfer (AXFR and IXFR) requests.
+func (d dnsHandler) serveTransfer(w dns.ResponseWriter, r *dns.Msg, ip string) {
+	name := strings.TrimSuffix(r.Question[0].Name, ".")
+
 	// Prepare the response.
 	m := new(dns.Msg)
 	m.SetReply(r)
 	m.Authoritative = true
 
-	// Load the zone.
-	zone, err := d.server.zoneRetriever(name)
-	if err != nil {
-		// On failure, return NXDOMAIN.
+	// Check access before rendering the zone.
+	info, err := d.server.zoneInfo(name)
+	if err != nil || info == nil || !d.isAllowed(*info, ip, r.IsTsig(), w.TsigStatus() == nil) {
+		// On failure, return NXDOMAIN to avoid information leaks.
 		m := new(dns.Msg)
 		m.SetRcode(r, dns.RcodeNameError)
 		w.WriteMsg(m)
 		return
 	}
 
-	// Check access.
-	if !d.isAllowed(zone.Info, ip, r.IsTsig(), w.TsigStatus() == nil) {
-		// On auth failure, return NXDOMAIN to avoid information leaks.
+	records, err := d.server.loadZone(info.Name)
+	if err != nil {
 		m := new(dns.Msg)
-		m.SetRcode(r, dns.RcodeNameError)
+		m.SetRcode(r, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+		return
+	}
+
+	m.Answer = d.transferRecords(r, records)
+
+	tsig := r.IsTsig()
+	if tsig != nil && w.TsigStatus() == nil {
+		m.SetTsig(tsig.Hdr.Name, tsig.Algorithm, 300, time.Now().Unix())
+	}
+
+	w.WriteMsg(m)
+}
+
+// transferRecords returns the records to send in reply to a zone transfer request.
+func (d dnsHandler) transferRecords(r *dns.Msg, records *zoneRecords) []dns.RR {
+	// Full transfer.
+	full := append(records.all(), records.soa)
+
+	if r.Question[0].Qtype != dns.TypeIXFR || len(r.Ns) == 0 {
+		return full
+	}
+
+	// Incremental transfer, the authority section holds the SOA known to the client.
+	clientSOA, ok := r.Ns[0].(*dns.SOA)
+	if !ok {
+		return full
+	}
+
+	// The client is up to date, only send the current SOA.
+	if clientSOA.Serial == records.soa.Serial {
+		return []dns.RR{records.soa}
+	}
+
+	// Fallback to a full transfer if the version known to the client isn't available anymore.
+	removed, added, ok := d.server.zoneDiff(records.info.Name, clientSOA.Serial)
+	if !ok {
+		return full
+	}
+
+	oldSOA := dns.Copy(records.soa).(*dns.SOA)
+	oldSOA.Serial = clientSOA.Serial
+
+	answer := []dns.RR{records.soa, oldSOA}
+	answer = append(answer, removed...)
+	answer = append(answer, records.soa)
+	answer = append(answer, added...)
+	answer = append(answer, records.soa)
+
+	return answer
+}
+
+// serveQuery answers standard queries from the zone data.
+func (d dnsHandler) serveQuery(w dns.ResponseWriter, r *dns.Msg, ip string) {
+	qname := strings.ToLower(dns.Fqdn(r.Question[0].Name))
+	qtype := r.Question[0].Qtype
+
+	// Find the zone holding the name and check access before rendering it.
+	info := d.findZone(qname)
+	if info == nil || !d.isQueryAllowed(*info, ip, r.IsTsig(), w.TsigStatus() == nil) {
+		// Refuse queries for names outside of the zones we are allowed to serve.
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeRefused)
+		w.WriteMsg(m)
+		return
+	}
+
+	records, err := d.server.loadZone(info.Name)
+	if err != nil {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeServerFailure)
 		w.WriteMsg(m)
 		return
 	}
 
-	zoneRR := dns.NewZoneParser(strings.NewReader(zone.Content), "", "")
-	for {
-		rr, ok := zoneRR.Next()
-		if !ok {
+	// Prepare the response.
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	// Get all the records for the name.
+	exists := false
+	matches := []dns.RR{}
+	for _, rr := range records.all() {
+		owner := strings.ToLower(rr.Header().Name)
+		if owner == qname {
+			matches = append(matches, rr)
+		} else if strings.HasSuffix(owner, "."+qname) {
+			// Names with no records but with records below them exist too.
+			exists = true
+		}
+	}
+
+	for _, rr := range matches {
+		if qtype == dns.TypeANY || rr.Header().Rrtype == qtype {
+			m.Answer = append(m.Answer, rr)
+		}
+	}
+
+	// Follow aliases within the zone.
+	if len(m.Answer) == 0 && qtype != dns.TypeCNAME {
+		for _, rr := range matches {
+			cname, ok := rr.(*dns.CNAME)
+			if !ok {
+				continue
+			}
+
+			m.Answer = append(m.Answer, cname)
+			target := strings.ToLower(cname.Target)
+			for _, rr := range records.records {
+				if strings.ToLower(rr.Header().Name) == target && rr.Header().Rrtype == qtype {
+					m.Answer = append(m.Answer, rr)
+				}
+			}
+
 			break
 		}
+	}
+
+	// No data or no such name, include the SOA for negative caching.
+	if len(m.Answer) == 0 {
+		if len(matches) == 0 && !exists {
+			m.Rcode = dns.RcodeNameError
+		}
 
-		m.Answer = append(m.Answer, rr)
+		m.Ns = []dns.RR{records.soa}
+	}
+
+	// Keep UDP responses within the size supported by the client.
+	if w.LocalAddr().Network() == "udp" {
+		size := dns.MinMsgSize
+		opt := r.IsEdns0()
+		if opt != nil && int(opt.UDPSize()) > size {
+			size = int(opt.UDPSize())
+		}
+
+		m.Truncate(size)
 	}
 
 	tsig := r.IsTsig()
@@ -90,8 +225,54 @@ func (d dnsHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	}
 
 	w.WriteMsg(m)
+}
 
-	return
+// findZone returns the configuration of the most specific zone holding the given name.
+func (d dnsHandler) findZone(name string) *api.NetworkZone {
+	labels := dns.SplitDomainName(name)
+	for i := range labels {
+		info, err := d.server.zoneInfo(strings.Join(labels[i:], "."))
+		if err != nil {
+			return nil
+		}
+
+		if info != nil {
+			return info
+		}
+	}
+
+	return nil
+}
+
+// isQueryAllowed checks whether the client may query records from the zone.
+// Transfer peers are always allowed, other clients must be in one of the subnets of dns.query.allow.
+func (d *dnsHandler) isQueryAllowed(zone api.NetworkZone, ip string, tsig *dns.TSIG, tsigStatus bool) bool {
+	if d.isAllowed(zone, ip, tsig, tsigStatus) {
+		return true
+	}
+
+	clientIP := net.ParseIP(ip)
+	if clientIP == nil {
+		return false
+	}
+
+	for _, entry := range strings.Split(zone.Config["dns.query.allow"], ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		_, subnet, err := net.ParseCIDR(entry)
+		if err != nil {
+			continue
+		}
+
+		if subnet.Contains(clientIP) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (d *dnsHandler) isAllowed(zone api.NetworkZone, ip string, tsig *dns.TSIG, tsigStatus bool) bool {
@@ -0,0 +1,70 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// zoneRefreshInterval is how often the served zones are checked for changes.
+const zoneRefreshInterval = time.Minute
+
+// notifyPeers sends a NOTIFY message for the zone to all peers with a configured address.
+func (s *Server) notifyPeers(records *zoneRecords) {
+	zoneName := records.info.Name
+
+	// Build the list of peers to notify.
+	addresses := map[string]string{}
+	keys := map[string]string{}
+	for k, v := range records.info.Config {
+		if !strings.HasPrefix(k, "peers.") {
+			continue
+		}
+
+		fields := strings.SplitN(k, ".", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		switch fields[2] {
+		case "address":
+			addresses[fields[1]] = v
+		case "key":
+			keys[fields[1]] = v
+		}
+	}
+
+	for peerName, address := range addresses {
+		if address == "" {
+			continue
+		}
+
+		m := new(dns.Msg)
+		m.SetNotify(dns.Fqdn(zoneName))
+		m.Answer = []dns.RR{records.soa}
+
+		client := dns.Client{Net: "udp", Timeout: 5 * time.Second}
+
+		key := keys[peerName]
+		if key != "" {
+			keyName := fmt.Sprintf("%s_%s.", zoneName, peerName)
+			client.TsigSecret = map[string]string{keyName: key}
+			m.SetTsig(keyName, dns.HmacSHA256, 300, time.Now().Unix())
+		}
+
+		resp, _, err := client.Exchange(m, net.JoinHostPort(address, "53"))
+		if err != nil {
+			logger.Warnf("Failed to send DNS NOTIFY for zone %q to %q: %v", zoneName, address, err)
+			continue
+		}
+
+		if resp.Rcode != dns.RcodeSuccess {
+			logger.Warnf("DNS NOTIFY for zone %q refused by %q: %s", zoneName, address, dns.RcodeToString[resp.Rcode])
+		}
+	}
+}
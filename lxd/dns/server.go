@@ -2,12 +2,14 @@ package dns
 
 import (
 	"sync"
+	"time"
 
 	"github.com/miekg/dns"
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/revert"
 	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -25,14 +27,22 @@ type Server struct {
 
 	// Internal state (to handle reconfiguration).
 	address string
+	stopCh  chan struct{}
 
 	mu sync.Mutex
+
+	// Zone caching and serial tracking (for IXFR and NOTIFY).
+	zones           map[string][]zoneVersion
+	zoneCache       map[string]*zoneRecords
+	zoneInfos       map[string]*api.NetworkZone
+	zoneInfosExpiry time.Time
+	zonesMu         sync.Mutex
 }
 
 // NewServer returns a new server instance.
 func NewServer(db *db.Cluster, retriever ZoneRetriever) *Server {
 	// Setup new struct.
-	s := &Server{db: db, zoneRetriever: retriever}
+	s := &Server{db: db, zoneRetriever: retriever, zones: map[string][]zoneVersion{}, zoneCache: map[string]*zoneRecords{}}
 	return s
 }
 
@@ -79,6 +89,10 @@ func (s *Server) start(address string) error {
 	// Record the address.
 	s.address = address
 
+	// Periodically look for zone changes to notify the peers.
+	s.stopCh = make(chan struct{})
+	go s.refreshLoop(s.stopCh)
+
 	return nil
 }
 
@@ -101,6 +115,11 @@ func (s *Server) stop() error {
 	s.tcpDNS.Shutdown()
 	s.udpDNS.Shutdown()
 
+	if s.stopCh != nil {
+		close(s.stopCh)
+		s.stopCh = nil
+	}
+
 	// Unset the address.
 	s.address = ""
 	return nil
@@ -173,3 +192,70 @@ func (s *Server) updateTSIG() error {
 
 	return nil
 }
+
+// RefreshZone drops the cached content of the zone, checks whether it changed and notifies its peers if so.
+// It must be called whenever the zone or its records change.
+func (s *Server) RefreshZone(name string) error {
+	s.invalidateZone(name)
+
+	// Locking.
+	s.mu.Lock()
+	running := s.tcpDNS != nil && s.udpDNS != nil && s.address != ""
+	s.mu.Unlock()
+
+	// Skip if not running.
+	if !running || s.zoneRetriever == nil {
+		return nil
+	}
+
+	info, err := s.zoneInfo(name)
+	if err != nil {
+		return err
+	}
+
+	if info == nil {
+		s.forgetZone(name)
+		return nil
+	}
+
+	_, err = s.loadZone(name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// loadZone returns the parsed content of the zone, notifying the peers when it changed.
+func (s *Server) loadZone(name string) (*zoneRecords, error) {
+	records, changed, err := s.zoneRecords(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if changed {
+		go s.notifyPeers(records)
+	}
+
+	return records, nil
+}
+
+// refreshLoop periodically refreshes the zones that have been served so far so that peers get notified of changes.
+func (s *Server) refreshLoop(stopCh chan struct{}) {
+	ticker := time.NewTicker(zoneRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			for _, name := range s.trackedZones() {
+				err := s.RefreshZone(name)
+				if err != nil {
+					logger.Warnf("Failed to refresh DNS zone %q: %v", name, err)
+				}
+			}
+		}
+	}
+}
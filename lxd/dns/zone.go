@@ -1,11 +1,263 @@
 package dns
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
 	"github.com/lxc/lxd/shared/api"
 )
 
+// zoneHistorySize is the number of zone versions kept around to serve incremental transfers.
+// Clients asking for a version which isn't available anymore get a full transfer instead.
+const zoneHistorySize = 10
+
+// zoneCacheExpiry is how long the zone list and the parsed zone content are cached for. Changes made through
+// this member invalidate the cache straight away, this bounds how long other changes (such as new leases or
+// changes made through other cluster members) take to be served.
+const zoneCacheExpiry = 30 * time.Second
+
 // Zone represents a DNS zone configuration and its content.
 type Zone struct {
 	Info    api.NetworkZone
 	Content string
 }
+
+// zoneVersion represents the records of a zone at a given serial.
+type zoneVersion struct {
+	serial  uint32
+	records map[string]dns.RR
+}
+
+// zoneRecords represents the parsed content of a zone with its tracked serial.
+type zoneRecords struct {
+	info    api.NetworkZone
+	soa     *dns.SOA
+	records []dns.RR
+	expiry  time.Time
+}
+
+// all returns the SOA followed by the zone records.
+func (z *zoneRecords) all() []dns.RR {
+	return append([]dns.RR{z.soa}, z.records...)
+}
+
+// parseZone splits the zone content into its SOA and other records.
+func parseZone(content string) (*dns.SOA, []dns.RR, error) {
+	var soa *dns.SOA
+	records := []dns.RR{}
+
+	zoneRR := dns.NewZoneParser(strings.NewReader(content), "", "")
+	for {
+		rr, ok := zoneRR.Next()
+		if !ok {
+			break
+		}
+
+		// The content is formatted for AXFR and so ends with a second copy of the SOA.
+		rrSOA, isSOA := rr.(*dns.SOA)
+		if isSOA {
+			if soa == nil {
+				soa = rrSOA
+			}
+
+			continue
+		}
+
+		records = append(records, rr)
+	}
+
+	err := zoneRR.Err()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if soa == nil {
+		return nil, nil, fmt.Errorf("Zone is missing its SOA record")
+	}
+
+	return soa, records, nil
+}
+
+// zoneInfo returns the configuration of the zone with the given name, or nil if there is no such zone.
+// The configuration of all zones is cached so that access to a zone can be checked without rendering it.
+func (s *Server) zoneInfo(name string) (*api.NetworkZone, error) {
+	s.zonesMu.Lock()
+	defer s.zonesMu.Unlock()
+
+	if s.zoneInfos == nil || time.Now().After(s.zoneInfosExpiry) {
+		if s.db == nil {
+			return nil, fmt.Errorf("No database available")
+		}
+
+		zoneInfos, err := s.db.GetNetworkZonesAllProjects()
+		if err != nil {
+			return nil, err
+		}
+
+		s.zoneInfos = zoneInfos
+		s.zoneInfosExpiry = time.Now().Add(zoneCacheExpiry)
+	}
+
+	return s.zoneInfos[strings.TrimSuffix(name, ".")], nil
+}
+
+// zoneRecords returns the parsed content of the zone, rendering it if it isn't cached.
+// It also returns whether the zone content changed since it was last seen.
+func (s *Server) zoneRecords(name string) (*zoneRecords, bool, error) {
+	s.zonesMu.Lock()
+	records := s.zoneCache[name]
+	s.zonesMu.Unlock()
+
+	if records != nil && time.Now().Before(records.expiry) {
+		return records, false, nil
+	}
+
+	zone, err := s.zoneRetriever(name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	records, changed, err := s.trackZone(zone)
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.zonesMu.Lock()
+	s.zoneCache[name] = records
+	s.zonesMu.Unlock()
+
+	return records, changed, nil
+}
+
+// invalidateZone drops the cached configuration and content of the zone so that changes are served right away.
+func (s *Server) invalidateZone(name string) {
+	s.zonesMu.Lock()
+	defer s.zonesMu.Unlock()
+
+	s.zoneInfos = nil
+	delete(s.zoneCache, name)
+}
+
+// zoneSerial returns the serial of the zone content, allocating a new one in the database when the content
+// changed so that all cluster members serve the same serial for the same content.
+func (s *Server) zoneSerial(name string, soa *dns.SOA, records []dns.RR) (uint32, bool, error) {
+	lines := make([]string, 0, len(records))
+	for _, rr := range records {
+		lines = append(lines, rr.String())
+	}
+
+	sort.Strings(lines)
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(lines, "\n"))))
+
+	return s.db.UpdateNetworkZoneSerial(name, hash, soa.Serial)
+}
+
+// trackZone parses the zone content and sets the SOA serial so that it only changes along with the records.
+// It returns whether the zone content changed since it was last seen by any cluster member.
+func (s *Server) trackZone(zone *Zone) (*zoneRecords, bool, error) {
+	soa, records, err := parseZone(zone.Content)
+	if err != nil {
+		return nil, false, err
+	}
+
+	name := zone.Info.Name
+	serial, changed, err := s.zoneSerial(name, soa, records)
+	if err != nil {
+		return nil, false, err
+	}
+
+	soa.Serial = serial
+
+	current := map[string]dns.RR{}
+	for _, rr := range records {
+		current[rr.String()] = rr
+	}
+
+	s.zonesMu.Lock()
+	defer s.zonesMu.Unlock()
+
+	// Keep this version around to serve incremental transfers from it later on. The versions other members
+	// have seen are missing, which only means clients knowing them get a full transfer.
+	versions := s.zones[name]
+	if len(versions) == 0 || versions[len(versions)-1].serial != serial {
+		versions = append(versions, zoneVersion{serial: serial, records: current})
+		if len(versions) > zoneHistorySize {
+			versions = versions[len(versions)-zoneHistorySize:]
+		}
+
+		s.zones[name] = versions
+	}
+
+	// Return the records in a stable order.
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Header().Name < records[j].Header().Name
+	})
+
+	return &zoneRecords{info: zone.Info, soa: soa, records: records, expiry: time.Now().Add(zoneCacheExpiry)}, changed, nil
+}
+
+// forgetZone removes the tracking data of a zone.
+func (s *Server) forgetZone(name string) {
+	s.zonesMu.Lock()
+	defer s.zonesMu.Unlock()
+
+	delete(s.zones, name)
+	delete(s.zoneCache, name)
+}
+
+// trackedZones returns the names of the zones currently tracked.
+func (s *Server) trackedZones() []string {
+	s.zonesMu.Lock()
+	defer s.zonesMu.Unlock()
+
+	names := make([]string, 0, len(s.zones))
+	for name := range s.zones {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// zoneDiff returns the records removed and added between the given serial and the latest version of the zone.
+// The last return value is false if that serial isn't known anymore.
+func (s *Server) zoneDiff(name string, serial uint32) ([]dns.RR, []dns.RR, bool) {
+	s.zonesMu.Lock()
+	defer s.zonesMu.Unlock()
+
+	versions := s.zones[name]
+	if len(versions) == 0 {
+		return nil, nil, false
+	}
+
+	latest := versions[len(versions)-1]
+	for _, version := range versions {
+		if version.serial != serial {
+			continue
+		}
+
+		removed := []dns.RR{}
+		for k, rr := range version.records {
+			_, ok := latest.records[k]
+			if !ok {
+				removed = append(removed, rr)
+			}
+		}
+
+		added := []dns.RR{}
+		for k, rr := range latest.records {
+			_, ok := version.records[k]
+			if !ok {
+				added = append(added, rr)
+			}
+		}
+
+		return removed, added, true
+	}
+
+	return nil, nil, false
+}
@@ -29,6 +29,13 @@ type Config struct {
 	// HTTP server for the internal /dev/lxd API exposed to containers.
 	DevLxdServer *http.Server
 
+	// HTTP server for the /dev/lxd API relayed by the lxd-agent of VMs over vsock.
+	VsockServer *http.Server
+
+	// The TLS keypair of this server, used for the vsock endpoint. If not set,
+	// the vsock endpoint won't be started.
+	ServerCert *shared.CertInfo
+
 	// The TLS keypair and optional CA to use for the network endpoint. It
 	// must be always provided, since the pubblic key will be included in
 	// the response of the /1.0 REST API as part of the server info.
@@ -66,6 +73,14 @@ type Config struct {
 
 	// HTTP server handling requests for the LXD metrics API.
 	MetricsServer *http.Server
+
+	// StorageBucketsAddress sets the address for the storage buckets endpoint.
+	//
+	// It can be updated after the endpoints are up using StorageBucketsUpdateAddress().
+	StorageBucketsAddress string
+
+	// HTTP server handling requests for the S3 storage buckets API.
+	StorageBucketsServer *http.Server
 }
 
 // Up brings up all applicable LXD endpoints and starts accepting HTTP
@@ -94,6 +109,14 @@ type Config struct {
 // authorization will be performed by the HTTP server using the socket ucred
 // struct).
 //
+// vsock endpoint (vsock socket with TLS)
+// --------------------------------------
+//
+// If a vsock server and certificate were set, listen on the vsock port used by
+// the lxd-agent to relay /dev/lxd requests from VMs (actual authorization will
+// be performed by the HTTP server using the peer context ID and certificate).
+// Failing to listen isn't fatal as VM support is optional.
+//
 // remote endpoint (TCP socket with TLS)
 // -------------------------------------
 //
@@ -164,12 +187,14 @@ func (e *Endpoints) up(config *Config) error {
 	defer e.mu.Unlock()
 
 	e.servers = map[kind]*http.Server{
-		devlxd:  config.DevLxdServer,
-		local:   config.RestServer,
-		network: config.RestServer,
-		cluster: config.RestServer,
-		pprof:   pprofCreateServer(),
-		metrics: config.MetricsServer,
+		devlxd:         config.DevLxdServer,
+		devlxdVsock:    config.VsockServer,
+		local:          config.RestServer,
+		network:        config.RestServer,
+		cluster:        config.RestServer,
+		pprof:          pprofCreateServer(),
+		metrics:        config.MetricsServer,
+		storageBuckets: config.StorageBucketsServer,
 	}
 	e.cert = config.Cert
 	e.inherited = map[kind]bool{}
@@ -198,6 +223,15 @@ func (e *Endpoints) up(config *Config) error {
 		return err
 	}
 
+	if config.VsockServer != nil && config.ServerCert != nil {
+		listener, err := vsockCreateListener(config.ServerCert)
+		if err != nil {
+			logger.Info("Unable to listen on vsock, VM agents won't be able to reach /dev/lxd", log.Ctx{"err": err})
+		} else {
+			e.listeners[devlxdVsock] = listener
+		}
+	}
+
 	if config.NetworkAddress != "" {
 		listener, ok := e.listeners[network]
 		if ok {
@@ -293,8 +327,19 @@ func (e *Endpoints) up(config *Config) error {
 		e.serve(metrics)
 	}
 
+	if config.StorageBucketsAddress != "" {
+		e.listeners[storageBuckets], err = storageBucketsCreateListener(config.StorageBucketsAddress, e.cert)
+		if err != nil {
+			return err
+		}
+
+		logger.Infof("Starting storage buckets handler:")
+		e.serve(storageBuckets)
+	}
+
 	logger.Infof("Starting /dev/lxd handler:")
 	e.serve(devlxd)
+	e.serve(devlxdVsock)
 
 	logger.Infof("REST API daemon:")
 	e.serve(local)
@@ -329,12 +374,17 @@ func (e *Endpoints) Down() error {
 		}
 	}
 
-	if e.listeners[devlxd] != nil {
+	if e.listeners[devlxd] != nil || e.listeners[devlxdVsock] != nil {
 		logger.Infof("Stopping /dev/lxd handler:")
 		err := e.closeListener(devlxd)
 		if err != nil {
 			return err
 		}
+
+		err = e.closeListener(devlxdVsock)
+		if err != nil {
+			return err
+		}
 	}
 
 	if e.listeners[pprof] != nil {
@@ -353,6 +403,14 @@ func (e *Endpoints) Down() error {
 		}
 	}
 
+	if e.listeners[storageBuckets] != nil {
+		logger.Infof("Stopping storage buckets handler:")
+		err := e.closeListener(storageBuckets)
+		if err != nil {
+			return err
+		}
+	}
+
 	if e.tomb != nil {
 		e.tomb.Kill(nil)
 		e.tomb.Wait()
@@ -436,14 +494,18 @@ const (
 	pprof
 	cluster
 	metrics
+	storageBuckets
+	devlxdVsock
 )
 
 // Human-readable descriptions of the various kinds of endpoints.
 var descriptions = map[kind]string{
-	local:   "Unix socket",
-	devlxd:  "devlxd socket",
-	network: "TCP socket",
-	pprof:   "pprof socket",
-	cluster: "cluster socket",
-	metrics: "metrics socket",
+	local:          "Unix socket",
+	devlxd:         "devlxd socket",
+	network:        "TCP socket",
+	pprof:          "pprof socket",
+	cluster:        "cluster socket",
+	metrics:        "metrics socket",
+	storageBuckets: "storage buckets socket",
+	devlxdVsock:    "devlxd vsock socket",
 }
@@ -6,6 +6,8 @@ package endpoints
 import (
 	"fmt"
 	"net"
+
+	"github.com/lxc/lxd/shared"
 )
 
 func localCreateListener(path string, group string) (net.Listener, error) {
@@ -15,3 +17,7 @@ func localCreateListener(path string, group string) (net.Listener, error) {
 func createDevLxdlListener(path string) (net.Listener, error) {
 	return nil, fmt.Errorf("Platform isn't supported")
 }
+
+func vsockCreateListener(cert *shared.CertInfo) (net.Listener, error) {
+	return nil, fmt.Errorf("Platform isn't supported")
+}
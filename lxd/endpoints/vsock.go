@@ -0,0 +1,37 @@
+//go:build linux && cgo
+// +build linux,cgo
+
+package endpoints
+
+import (
+	"crypto/tls"
+	"net"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/lxd/vsock"
+	"github.com/lxc/lxd/shared"
+)
+
+// Create a new net.Listener bound to the vsock port used by the lxd-agent to reach the host.
+// Any client certificate is accepted at the TLS level, the HTTP handlers then match it against
+// the certificate of the VM the connection comes from.
+func vsockCreateListener(cert *shared.CertInfo) (net.Listener, error) {
+	// The host side of vsock is provided by the vhost_vsock module.
+	err := util.LoadModule("vhost_vsock")
+	if err != nil {
+		return nil, errors.Wrap(err, "Load vhost_vsock module")
+	}
+
+	listener, err := vsock.Listen(shared.HTTPSDefaultPort)
+	if err != nil {
+		return nil, errors.Wrap(err, "Bind vsock port")
+	}
+
+	config := shared.InitTLSConfig()
+	config.ClientAuth = tls.RequireAnyClientCert
+	config.Certificates = []tls.Certificate{cert.KeyPair()}
+
+	return tls.NewListener(listener, config), nil
+}
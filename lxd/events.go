@@ -6,7 +6,6 @@ import (
 	"strings"
 
 	"github.com/lxc/lxd/lxd/db"
-	"github.com/lxc/lxd/lxd/rbac"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
@@ -40,7 +39,7 @@ func eventsSocket(d *Daemon, r *http.Request, w http.ResponseWriter) error {
 	if len(types) == 1 && types[0] == "" {
 		types = []string{}
 		for _, entry := range eventTypes {
-			if !rbac.UserIsAdmin(r) && shared.StringInSlice(entry, privilegedEventTypes) {
+			if !d.authorizer.UserIsAdmin(r) && shared.StringInSlice(entry, privilegedEventTypes) {
 				continue
 			}
 
@@ -56,7 +55,7 @@ func eventsSocket(d *Daemon, r *http.Request, w http.ResponseWriter) error {
 		}
 	}
 
-	if shared.StringInSlice("logging", types) && !rbac.UserIsAdmin(r) {
+	if shared.StringInSlice("logging", types) && !d.authorizer.UserIsAdmin(r) {
 		response.Forbidden(nil).Render(w)
 		return nil
 	}
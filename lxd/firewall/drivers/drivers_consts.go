@@ -53,3 +53,17 @@ type AddressForward struct {
 	ListenPorts   []uint64
 	TargetPorts   []uint64
 }
+
+// LoadBalancerTarget represents a network load balancer backend target.
+type LoadBalancerTarget struct {
+	Address net.IP
+	Port    uint64
+}
+
+// LoadBalancer represents a network load balancer listen port and the targets it balances across.
+type LoadBalancer struct {
+	ListenAddress net.IP
+	Protocol      string
+	ListenPort    uint64
+	Targets       []LoadBalancerTarget
+}
@@ -368,6 +368,7 @@ func (d Nftables) NetworkClear(networkName string, _ bool, _ []uint) error {
 		"fwd", "pstrt", "in", "out", // Chains used for network operation rules.
 		"aclin", "aclout", "aclfwd", "acl", // Chains used by ACL rules.
 		"fwdprert", "fwdout", "fwdpstrt", // Chains used by Address Forward rules.
+		"lbprert", "lbout", "lbpstrt", // Chains used by Load Balancer rules.
 	}
 
 	// Remove chains created by network rules.
@@ -380,6 +381,37 @@ func (d Nftables) NetworkClear(networkName string, _ bool, _ []uint) error {
 	return nil
 }
 
+// NetworkSetupTunnelFilter prevents the host from exchanging traffic with the network's tunnel interfaces (those
+// starting with tunnelPrefix), so that the gateway, DHCP and DNS services of each host only serve its local
+// instances while instances can still reach each other through the tunnels.
+func (d Nftables) NetworkSetupTunnelFilter(networkName string, tunnelPrefix string) error {
+	tplFields := map[string]interface{}{
+		"namespace":      nftablesNamespace,
+		"chainSeparator": nftablesChainSeparator,
+		"networkName":    networkName,
+		"family":         "bridge",
+		"tunnelMatch":    fmt.Sprintf("%s*", tunnelPrefix),
+	}
+
+	err := d.applyNftConfig(nftablesNetTunnelFilter, tplFields)
+	if err != nil {
+		return errors.Wrapf(err, "Failed adding tunnel filter rules for network %q (%s)", networkName, tplFields["family"])
+	}
+
+	return nil
+}
+
+// NetworkClearTunnelFilter removes the tunnel filter rules of a network.
+// The tunnelPrefix argument has no effect for nftables driver.
+func (d Nftables) NetworkClearTunnelFilter(networkName string, _ string) error {
+	err := d.removeChains([]string{"bridge"}, networkName, "tunin", "tunout")
+	if err != nil {
+		return errors.Wrapf(err, "Failed clearing tunnel filter rules for network %q", networkName)
+	}
+
+	return nil
+}
+
 //instanceDeviceLabel returns the unique label used for instance device chains.
 func (d Nftables) instanceDeviceLabel(projectName, instanceName, deviceName string) string {
 	return fmt.Sprintf("%s%s%s", project.Instance(projectName, instanceName), nftablesChainSeparator, deviceName)
@@ -1040,3 +1072,92 @@ func (d Nftables) NetworkApplyForwards(networkName string, rules []AddressForwar
 
 	return nil
 }
+
+// NetworkApplyLoadBalancers apply network load balancer rules to firewall.
+// Connections are spread randomly across the targets of each listen port.
+func (d Nftables) NetworkApplyLoadBalancers(networkName string, rules []LoadBalancer) error {
+	var dnatRules []map[string]interface{}
+	var snatRules []map[string]interface{}
+
+	// Used to avoid adding duplicate hairpin NAT rules for targets shared between listen ports.
+	snatTargets := make(map[string]struct{})
+
+	for ruleIndex, rule := range rules {
+		if rule.ListenAddress == nil {
+			return fmt.Errorf("Invalid rule %d, listen address is required", ruleIndex)
+		}
+
+		if rule.Protocol == "" || rule.ListenPort <= 0 {
+			return fmt.Errorf("Invalid rule %d, protocol and listen port are required", ruleIndex)
+		}
+
+		if len(rule.Targets) <= 0 {
+			return fmt.Errorf("Invalid rule %d, at least one target is required", ruleIndex)
+		}
+
+		ipFamily := "ip"
+		if rule.ListenAddress.To4() == nil {
+			ipFamily = "ip6"
+		}
+
+		targetsMap := make([]string, 0, len(rule.Targets))
+		for i, target := range rule.Targets {
+			if target.Address == nil {
+				return fmt.Errorf("Invalid rule %d, target address is required", ruleIndex)
+			}
+
+			targetAddressStr := target.Address.String()
+			targetsMap = append(targetsMap, fmt.Sprintf("%d : %s . %d", i, targetAddressStr, target.Port))
+
+			snatKey := fmt.Sprintf("%s/%s/%d", rule.Protocol, targetAddressStr, target.Port)
+			if _, found := snatTargets[snatKey]; !found {
+				snatTargets[snatKey] = struct{}{}
+				snatRules = append(snatRules, map[string]interface{}{
+					"ipFamily":   ipFamily,
+					"protocol":   rule.Protocol,
+					"targetHost": targetAddressStr,
+					"targetPort": target.Port,
+				})
+			}
+		}
+
+		dnatRules = append(dnatRules, map[string]interface{}{
+			"ipFamily":      ipFamily,
+			"protocol":      rule.Protocol,
+			"listenAddress": rule.ListenAddress.String(),
+			"listenPort":    rule.ListenPort,
+			"targetsLen":    len(rule.Targets),
+			"targetsMap":    strings.Join(targetsMap, ", "),
+		})
+	}
+
+	tplFields := map[string]interface{}{
+		"namespace":      nftablesNamespace,
+		"chainSeparator": nftablesChainSeparator,
+		"family":         "inet",
+		"label":          networkName,
+		"dnatRules":      dnatRules,
+		"snatRules":      snatRules,
+	}
+
+	// Apply rules or remove chains if no rules generated.
+	if len(dnatRules) > 0 {
+		config := &strings.Builder{}
+		err := nftablesNetLoadBalancer.Execute(config, tplFields)
+		if err != nil {
+			return fmt.Errorf("Failed running %q template: %w", nftablesNetLoadBalancer.Name(), err)
+		}
+
+		_, err = shared.RunCommand("nft", config.String())
+		if err != nil {
+			return err
+		}
+	} else {
+		err := d.removeChains([]string{"inet"}, networkName, "lbprert", "lbout", "lbpstrt")
+		if err != nil {
+			return fmt.Errorf("Failed clearing nftables load balancer rules for network %q: %w", networkName, err)
+		}
+	}
+
+	return nil
+}
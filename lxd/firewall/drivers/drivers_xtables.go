@@ -166,6 +166,12 @@ func (d Xtables) networkForwardIPTablesComment(networkName string) string {
 	return fmt.Sprintf("LXD network-forward %s", networkName)
 }
 
+// networkLoadBalancerIPTablesComment returns the iptables comment that is added to each network load balancer
+// related rule.
+func (d Xtables) networkLoadBalancerIPTablesComment(networkName string) string {
+	return fmt.Sprintf("LXD network-load-balancer %s", networkName)
+}
+
 // networkSetupNICFilteringChain creates the NIC filtering chain if it doesn't exist, and adds the jump rules to
 // the INPUT and FORWARD filter chains. Must be called after networkSetupForwardingPolicy so that the rules are
 // prepended before the default fowarding policy rules.
@@ -755,10 +761,11 @@ func (d Xtables) NetworkClear(networkName string, delete bool, ipVersions []uint
 	comments := []string{
 		d.networkIPTablesComment(networkName),
 		d.networkForwardIPTablesComment(networkName),
+		d.networkLoadBalancerIPTablesComment(networkName),
 	}
 
 	for _, ipVersion := range ipVersions {
-		// Clear any rules associated to the network and network address forwards.
+		// Clear any rules associated to the network, network address forwards and load balancers.
 		err := d.iptablesClear(ipVersion, comments, "filter", "mangle", "nat")
 		if err != nil {
 			return err
@@ -850,13 +857,34 @@ func (d Xtables) InstanceClearBridgeFilter(projectName string, instanceName stri
 	// Get a list of rules that we would have applied on instance start.
 	rules := d.generateFilterEbtablesRules(hostName, hwAddr, IPv4, IPv6)
 
+	errs, err := d.ebtablesDelete(rules)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to get a list of network filters to for %q", deviceName)
+	}
+
+	// Remove any ip6tables rules added as part of bridge filtering.
+	err = d.iptablesClear(6, []string{comment}, "filter")
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("Failed to remove network filters rule for %q: %v", deviceName, errs)
+	}
+
+	return nil
+}
+
+// ebtablesDelete removes the active ebtables rules matching any of the supplied rules.
+// Returns the errors encountered removing the individual rules.
+func (d Xtables) ebtablesDelete(rules [][]string) ([]error, error) {
 	ebtablesMu.Lock()
+	defer ebtablesMu.Unlock()
 
 	// Get a current list of rules active on the host.
 	out, err := shared.RunCommand("ebtables", "-L", "--Lmac2", "--Lx")
 	if err != nil {
-		ebtablesMu.Unlock()
-		return errors.Wrapf(err, "Failed to get a list of network filters to for %q", deviceName)
+		return nil, err
 	}
 
 	errs := []error{}
@@ -886,16 +914,45 @@ func (d Xtables) InstanceClearBridgeFilter(projectName string, instanceName stri
 		}
 	}
 
-	ebtablesMu.Unlock()
+	return errs, nil
+}
 
-	// Remove any ip6tables rules added as part of bridge filtering.
-	err = d.iptablesClear(6, []string{comment}, "filter")
+// generateTunnelEbtablesRules returns the ebtables rules isolating the host from the tunnel interfaces.
+func (d Xtables) generateTunnelEbtablesRules(tunnelPrefix string) [][]string {
+	tunnelMatch := fmt.Sprintf("%s+", tunnelPrefix)
+
+	return [][]string{
+		{"ebtables", "-t", "filter", "-A", "INPUT", "-i", tunnelMatch, "-j", "DROP"},
+		{"ebtables", "-t", "filter", "-A", "OUTPUT", "-o", tunnelMatch, "-j", "DROP"},
+	}
+}
+
+// NetworkSetupTunnelFilter prevents the host from exchanging traffic with the network's tunnel interfaces (those
+// starting with tunnelPrefix), so that the gateway, DHCP and DNS services of each host only serve its local
+// instances while instances can still reach each other through the tunnels.
+func (d Xtables) NetworkSetupTunnelFilter(networkName string, tunnelPrefix string) error {
+	ebtablesMu.Lock()
+	defer ebtablesMu.Unlock()
+
+	for _, rule := range d.generateTunnelEbtablesRules(tunnelPrefix) {
+		_, err := shared.RunCommand(rule[0], rule[1:]...)
+		if err != nil {
+			return errors.Wrapf(err, "Failed adding tunnel filter rules for network %q", networkName)
+		}
+	}
+
+	return nil
+}
+
+// NetworkClearTunnelFilter removes the tunnel filter rules of a network.
+func (d Xtables) NetworkClearTunnelFilter(networkName string, tunnelPrefix string) error {
+	errs, err := d.ebtablesDelete(d.generateTunnelEbtablesRules(tunnelPrefix))
 	if err != nil {
-		errs = append(errs, err)
+		return errors.Wrapf(err, "Failed to get a list of network filters for %q", networkName)
 	}
 
 	if len(errs) > 0 {
-		return fmt.Errorf("Failed to remove network filters rule for %q: %v", deviceName, errs)
+		return fmt.Errorf("Failed to remove tunnel filter rules for %q: %v", networkName, errs)
 	}
 
 	return nil
@@ -1518,3 +1575,87 @@ func (d Xtables) NetworkApplyForwards(networkName string, rules []AddressForward
 
 	return nil
 }
+
+// NetworkApplyLoadBalancers apply network load balancer rules to firewall.
+// Connections are spread across the targets using the statistic module in nth mode.
+func (d Xtables) NetworkApplyLoadBalancers(networkName string, rules []LoadBalancer) error {
+	comment := d.networkLoadBalancerIPTablesComment(networkName)
+
+	// Clear any load balancer rules associated to the network.
+	for _, ipVersion := range []uint{4, 6} {
+		err := d.iptablesClear(ipVersion, []string{comment}, "nat")
+		if err != nil {
+			return err
+		}
+	}
+
+	for ruleIndex, rule := range rules {
+		if rule.ListenAddress == nil {
+			return fmt.Errorf("Invalid rule %d, listen address is required", ruleIndex)
+		}
+
+		if rule.Protocol == "" || rule.ListenPort <= 0 {
+			return fmt.Errorf("Invalid rule %d, protocol and listen port are required", ruleIndex)
+		}
+
+		targetsLen := len(rule.Targets)
+		if targetsLen <= 0 {
+			return fmt.Errorf("Invalid rule %d, at least one target is required", ruleIndex)
+		}
+
+		ipVersion := uint(4)
+		if rule.ListenAddress.To4() == nil {
+			ipVersion = 6
+		}
+
+		listenAddressStr := rule.ListenAddress.String()
+		listenPortStr := fmt.Sprintf("%d", rule.ListenPort)
+
+		// Rules are prepended, so add them in reverse order so that the first target's rule is evaluated
+		// first. Each target's rule matches every nth new connection of those not matched by the rules
+		// before it, with the last target's rule matching all remaining connections.
+		for i := targetsLen - 1; i >= 0; i-- {
+			target := rule.Targets[i]
+			if target.Address == nil {
+				return fmt.Errorf("Invalid rule %d, target address is required", ruleIndex)
+			}
+
+			targetAddressStr := target.Address.String()
+			targetPortStr := fmt.Sprintf("%d", target.Port)
+
+			// Format the destination host/port as appropriate.
+			targetDest := fmt.Sprintf("%s:%d", targetAddressStr, target.Port)
+			if ipVersion == 6 {
+				targetDest = fmt.Sprintf("[%s]:%d", targetAddressStr, target.Port)
+			}
+
+			matchArgs := []string{"-p", rule.Protocol, "--destination", listenAddressStr, "--dport", listenPortStr}
+			if i < targetsLen-1 {
+				matchArgs = append(matchArgs, "-m", "statistic", "--mode", "nth", "--every", fmt.Sprintf("%d", targetsLen-i), "--packet", "0")
+			}
+
+			dnatArgs := append(matchArgs, "-j", "DNAT", "--to-destination", targetDest)
+
+			// outbound <-> instance.
+			err := d.iptablesPrepend(ipVersion, comment, "nat", "PREROUTING", dnatArgs...)
+			if err != nil {
+				return err
+			}
+
+			// host <-> instance.
+			err = d.iptablesPrepend(ipVersion, comment, "nat", "OUTPUT", dnatArgs...)
+			if err != nil {
+				return err
+			}
+
+			// instance <-> instance.
+			// Requires instance's bridge port has hairpin mode enabled when br_netfilter is loaded.
+			err = d.iptablesPrepend(ipVersion, comment, "nat", "POSTROUTING", "-p", rule.Protocol, "--source", targetAddressStr, "--destination", targetAddressStr, "--dport", targetPortStr, "-j", "MASQUERADE")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
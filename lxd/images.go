@@ -29,6 +29,7 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/auth"
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/filter"
@@ -64,46 +65,46 @@ var imagesCmd = APIEndpoint{
 var imageCmd = APIEndpoint{
 	Path: "images/{fingerprint}",
 
-	Delete: APIEndpointAction{Handler: imageDelete, AccessHandler: allowProjectPermission("images", "manage-images")},
+	Delete: APIEndpointAction{Handler: imageDelete, AccessHandler: allowProjectPermission(auth.EntitlementCanManageImages)},
 	Get:    APIEndpointAction{Handler: imageGet, AllowUntrusted: true},
-	Patch:  APIEndpointAction{Handler: imagePatch, AccessHandler: allowProjectPermission("images", "manage-images")},
-	Put:    APIEndpointAction{Handler: imagePut, AccessHandler: allowProjectPermission("images", "manage-images")},
+	Patch:  APIEndpointAction{Handler: imagePatch, AccessHandler: allowProjectPermission(auth.EntitlementCanManageImages)},
+	Put:    APIEndpointAction{Handler: imagePut, AccessHandler: allowProjectPermission(auth.EntitlementCanManageImages)},
 }
 
 var imageExportCmd = APIEndpoint{
 	Path: "images/{fingerprint}/export",
 
 	Get:  APIEndpointAction{Handler: imageExport, AllowUntrusted: true},
-	Post: APIEndpointAction{Handler: imageExportPost, AccessHandler: allowProjectPermission("images", "manage-images")},
+	Post: APIEndpointAction{Handler: imageExportPost, AccessHandler: allowProjectPermission(auth.EntitlementCanManageImages)},
 }
 
 var imageSecretCmd = APIEndpoint{
 	Path: "images/{fingerprint}/secret",
 
-	Post: APIEndpointAction{Handler: imageSecret, AccessHandler: allowProjectPermission("images", "view")},
+	Post: APIEndpointAction{Handler: imageSecret, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
 }
 
 var imageRefreshCmd = APIEndpoint{
 	Path: "images/{fingerprint}/refresh",
 
-	Post: APIEndpointAction{Handler: imageRefresh, AccessHandler: allowProjectPermission("images", "manage-images")},
+	Post: APIEndpointAction{Handler: imageRefresh, AccessHandler: allowProjectPermission(auth.EntitlementCanManageImages)},
 }
 
 var imageAliasesCmd = APIEndpoint{
 	Path: "images/aliases",
 
-	Get:  APIEndpointAction{Handler: imageAliasesGet, AccessHandler: allowProjectPermission("images", "view")},
-	Post: APIEndpointAction{Handler: imageAliasesPost, AccessHandler: allowProjectPermission("images", "manage-images")},
+	Get:  APIEndpointAction{Handler: imageAliasesGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
+	Post: APIEndpointAction{Handler: imageAliasesPost, AccessHandler: allowProjectPermission(auth.EntitlementCanManageImages)},
 }
 
 var imageAliasCmd = APIEndpoint{
 	Path: "images/aliases/{name:.*}",
 
-	Delete: APIEndpointAction{Handler: imageAliasDelete, AccessHandler: allowProjectPermission("images", "manage-images")},
+	Delete: APIEndpointAction{Handler: imageAliasDelete, AccessHandler: allowProjectPermission(auth.EntitlementCanManageImages)},
 	Get:    APIEndpointAction{Handler: imageAliasGet, AllowUntrusted: true},
-	Patch:  APIEndpointAction{Handler: imageAliasPatch, AccessHandler: allowProjectPermission("images", "manage-images")},
-	Post:   APIEndpointAction{Handler: imageAliasPost, AccessHandler: allowProjectPermission("images", "manage-images")},
-	Put:    APIEndpointAction{Handler: imageAliasPut, AccessHandler: allowProjectPermission("images", "manage-images")},
+	Patch:  APIEndpointAction{Handler: imageAliasPatch, AccessHandler: allowProjectPermission(auth.EntitlementCanManageImages)},
+	Post:   APIEndpointAction{Handler: imageAliasPost, AccessHandler: allowProjectPermission(auth.EntitlementCanManageImages)},
+	Put:    APIEndpointAction{Handler: imageAliasPut, AccessHandler: allowProjectPermission(auth.EntitlementCanManageImages)},
 }
 
 /* We only want a single publish running at any one time.
@@ -815,7 +816,7 @@ func imageCreateInPool(d *Daemon, info *api.Image, storagePool string) error {
 //   "500":
 //     $ref: "#/responses/InternalServerError"
 func imagesPost(d *Daemon, r *http.Request) response.Response {
-	trusted := d.checkTrustedClient(r) == nil && allowProjectPermission("images", "manage-images")(d, r) == response.EmptySyncResponse
+	trusted := d.checkTrustedClient(r) == nil && allowProjectPermission(auth.EntitlementCanManageImages)(d, r) == response.EmptySyncResponse
 
 	secret := r.Header.Get("X-LXD-secret")
 	fingerprint := r.Header.Get("X-LXD-fingerprint")
@@ -1398,7 +1399,7 @@ func doImagesGet(d *Daemon, recursion bool, project string, public bool, clauses
 func imagesGet(d *Daemon, r *http.Request) response.Response {
 	projectName := projectParam(r)
 	filterStr := r.FormValue("filter")
-	public := d.checkTrustedClient(r) != nil || allowProjectPermission("images", "view")(d, r) != response.EmptySyncResponse
+	public := d.checkTrustedClient(r) != nil || allowProjectPermission(auth.EntitlementCanView)(d, r) != response.EmptySyncResponse
 
 	var clauses []filter.Clause
 	if filterStr != "" {
@@ -2461,7 +2462,7 @@ func imageValidSecret(d *Daemon, r *http.Request, projectName string, fingerprin
 func imageGet(d *Daemon, r *http.Request) response.Response {
 	projectName := projectParam(r)
 	fingerprint := mux.Vars(r)["fingerprint"]
-	public := d.checkTrustedClient(r) != nil || allowProjectPermission("images", "view")(d, r) != response.EmptySyncResponse
+	public := d.checkTrustedClient(r) != nil || allowProjectPermission(auth.EntitlementCanView)(d, r) != response.EmptySyncResponse
 	secret := r.FormValue("secret")
 
 	info, resp := doImageGet(d.cluster, projectName, fingerprint, false)
@@ -2949,7 +2950,7 @@ func imageAliasesGet(d *Daemon, r *http.Request) response.Response {
 func imageAliasGet(d *Daemon, r *http.Request) response.Response {
 	projectName := projectParam(r)
 	name := mux.Vars(r)["name"]
-	public := d.checkTrustedClient(r) != nil || allowProjectPermission("images", "view")(d, r) != response.EmptySyncResponse
+	public := d.checkTrustedClient(r) != nil || allowProjectPermission(auth.EntitlementCanView)(d, r) != response.EmptySyncResponse
 
 	_, alias, err := d.cluster.GetImageAlias(projectName, name, !public)
 	if err != nil {
@@ -3287,7 +3288,7 @@ func imageExport(d *Daemon, r *http.Request) response.Response {
 	projectName := projectParam(r)
 	fingerprint := mux.Vars(r)["fingerprint"]
 
-	public := d.checkTrustedClient(r) != nil || allowProjectPermission("images", "view")(d, r) != response.EmptySyncResponse
+	public := d.checkTrustedClient(r) != nil || allowProjectPermission(auth.EntitlementCanView)(d, r) != response.EmptySyncResponse
 	secret := r.FormValue("secret")
 
 	var imgInfo *api.Image
@@ -861,11 +861,12 @@ func (d *common) maasDelete(inst instance.Instance) error {
 func (d *common) onStopOperationSetup(target string) (*operationlock.InstanceOperation, bool, error) {
 	var err error
 
-	// Pick up the existing stop operation lock created in Stop() function.
+	// Pick up the existing stop operation lock created in Stop() function (or the migrate operation lock when the
+	// instance is stopped after being live migrated).
 	// If there is another ongoing operation (such as start), wait until that has finished before proceeding
 	// to run the hook (this should be quick as it will fail showing instance is already running).
 	op := operationlock.Get(d.Project(), d.Name())
-	if op != nil && !op.ActionMatch(operationlock.ActionStop, operationlock.ActionRestart, operationlock.ActionRestore) {
+	if op != nil && !op.ActionMatch(operationlock.ActionStop, operationlock.ActionRestart, operationlock.ActionRestore, operationlock.ActionMigrate) {
 		d.logger.Debug("Waiting for existing operation lock to finish before running hook", log.Ctx{"action": op.Action()})
 		op.Wait()
 		op = nil
@@ -912,7 +913,7 @@ func (d *common) canMigrate(inst instance.Instance) bool {
 		val = "auto"
 	}
 
-	if val == "migrate" {
+	if shared.StringInSlice(val, []string{"migrate", "live-migrate"}) {
 		return true
 	}
 
@@ -2964,7 +2964,7 @@ func (d *lxc) onStop(args map[string]string) error {
 	d.fromHook = true
 
 	// Record power state.
-	err = d.VolatileSet(map[string]string{"volatile.last_state.power": "STOPPED"})
+	err = d.VolatileSet(map[string]string{"volatile.last_state.power": "STOPPED", "volatile.last_state.ready": ""})
 	if err != nil {
 		// Don't return an error here as we still want to cleanup the instance even if DB not available.
 		d.logger.Error("Failed recording last power state", log.Ctx{"err": err})
@@ -3683,14 +3683,14 @@ func (d *lxc) Delete(force bool) error {
 
 	// Perform other cleanup steps if not snapshot.
 	if !d.IsSnapshot() {
-		// Remove all backups.
+		// Remove all backups (any copies held on the backups target are kept).
 		backups, err := d.Backups()
 		if err != nil {
 			return err
 		}
 
 		for _, backup := range backups {
-			err = backup.Delete()
+			err = backup.DeleteLocal()
 			if err != nil {
 				return err
 			}
@@ -6778,7 +6778,14 @@ func (d *lxc) statusCode() api.StatusCode {
 		return api.Error
 	}
 
-	return lxcStatusCode(state)
+	statusCode := lxcStatusCode(state)
+
+	// Report the workload as ready if it said so through devlxd.
+	if statusCode == api.Running && shared.IsTrue(d.LocalConfig()["volatile.last_state.ready"]) {
+		return api.Ready
+	}
+
+	return statusCode
 }
 
 // State returns instance state.
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/x509"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -85,6 +86,21 @@ const qemuDeviceIDPrefix = "dev-lxd_"
 // qemuNetDevIDPrefix used as part of the name given QEMU netdevs generated from user added devices.
 const qemuNetDevIDPrefix = "lxd_"
 
+// qemuBlockDevIDPrefix used as part of the name given QEMU block devices generated from user added devices.
+const qemuBlockDevIDPrefix = "lxd_"
+
+// qemuMaxCPUs is the maximum number of vCPUs which can be hotplugged into a VM.
+const qemuMaxCPUs = 288
+
+// qemuMemorySlots is the number of memory slots available for hotplugging memory into a VM.
+const qemuMemorySlots = 8
+
+// qemuMaxMemorySizeMB is the maximum memory size in MiB a VM can have with the memory hotplugged into it.
+const qemuMaxMemorySizeMB = 256 * 1024
+
+// qemuMemoryBlockSizeMB is the size of the memory blocks the guest onlines, hotplugged memory is aligned to it.
+const qemuMemoryBlockSizeMB = 128
+
 var errQemuAgentOffline = fmt.Errorf("LXD VM agent isn't currently running")
 
 var vmConsole = map[int]bool{}
@@ -506,6 +522,21 @@ func (d *qemu) unmount() (bool, error) {
 	return unmounted, nil
 }
 
+// AgentCertificate returns the certificate the lxd-agent uses, or nil if it can't be read.
+func (d *qemu) AgentCertificate() *x509.Certificate {
+	agentCert := filepath.Join(d.Path(), "agent.crt")
+	if !shared.PathExists(agentCert) {
+		return nil
+	}
+
+	cert, err := shared.ReadCert(agentCert)
+	if err != nil {
+		return nil
+	}
+
+	return cert
+}
+
 // generateAgentCert creates the necessary server key and certificate if needed.
 func (d *qemu) generateAgentCert() (string, string, string, string, error) {
 	// Mount the instance's config volume if needed.
@@ -645,11 +676,17 @@ func (d *qemu) onStop(target string) error {
 	// Reset timeout to 30s.
 	op.Reset()
 
+	// When the instance has been live migrated it is still running on the target member, so don't record
+	// that it has stopped.
+	migrated := op.Action() == operationlock.ActionMigrate
+
 	// Record power state.
-	err = d.VolatileSet(map[string]string{"volatile.last_state.power": "STOPPED"})
-	if err != nil {
-		// Don't return an error here as we still want to cleanup the instance even if DB not available.
-		d.logger.Error("Failed recording last power state", log.Ctx{"err": err})
+	if !migrated {
+		err = d.VolatileSet(map[string]string{"volatile.last_state.power": "STOPPED", "volatile.last_state.ready": ""})
+		if err != nil {
+			// Don't return an error here as we still want to cleanup the instance even if DB not available.
+			d.logger.Error("Failed recording last power state", log.Ctx{"err": err})
+		}
 	}
 
 	// Cleanup.
@@ -690,7 +727,7 @@ func (d *qemu) onStop(target string) error {
 		}
 
 		d.state.Events.SendLifecycle(d.project, lifecycle.InstanceRestarted.Event(d, nil))
-	} else if d.ephemeral {
+	} else if d.ephemeral && !migrated {
 		// Reset timeout to 30s.
 		op.Reset()
 
@@ -921,54 +958,79 @@ func (d *qemu) restoreState(monitor *qmp.Monitor) error {
 func (d *qemu) saveState(monitor *qmp.Monitor) error {
 	os.Remove(d.StatePath())
 
+	revert := revert.New()
+	defer revert.Fail()
+
 	// Prepare the state file.
 	stateFile, err := os.Create(d.StatePath())
 	if err != nil {
 		return err
 	}
+	defer stateFile.Close()
+	revert.Add(func() { os.Remove(d.StatePath()) })
 
 	compressedState, err := gzip.NewWriterLevel(stateFile, gzip.BestSpeed)
 	if err != nil {
-		stateFile.Close()
 		return err
 	}
+	defer compressedState.Close()
 
 	pipeRead, pipeWrite, err := os.Pipe()
 	if err != nil {
-		compressedState.Close()
-		stateFile.Close()
 		return err
 	}
 	defer pipeRead.Close()
 	defer pipeWrite.Close()
 
-	go io.Copy(compressedState, pipeRead)
+	// Compress the state into the file as it is being received.
+	chCopyErr := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(compressedState, pipeRead)
+		chCopyErr <- err
+	}()
 
 	// Send the target file to qemu.
 	err = monitor.SendFile("migration", pipeWrite)
 	if err != nil {
-		compressedState.Close()
-		stateFile.Close()
 		return err
 	}
 
 	// Issue the migration command.
 	err = monitor.Migrate("fd:migration")
 	if err != nil {
-		compressedState.Close()
-		stateFile.Close()
 		return err
 	}
 
+	// Close our end of the pipe and wait for all of the state to have been written.
+	pipeWrite.Close()
+	err = <-chCopyErr
+	if err != nil {
+		return errors.Wrapf(err, "Failed writing state file")
+	}
+
+	err = compressedState.Close()
+	if err != nil {
+		return errors.Wrapf(err, "Failed writing state file")
+	}
+
 	// Close the file to avoid unmount delays.
-	compressedState.Close()
-	stateFile.Close()
+	err = stateFile.Close()
+	if err != nil {
+		return errors.Wrapf(err, "Failed closing state file")
+	}
 
+	revert.Success()
 	return nil
 }
 
 // Start starts the instance.
 func (d *qemu) Start(stateful bool) error {
+	return d.start(stateful, nil)
+}
+
+// start starts the instance. If migrateArgs is supplied then the instance's running state is received from a
+// live migration source rather than being restored from the state file.
+func (d *qemu) start(stateful bool, migrateArgs *instance.VMLiveMigrateArgs) error {
 	d.logger.Debug("Start started", log.Ctx{"stateful": stateful})
 	defer d.logger.Debug("Start finished", log.Ctx{"stateful": stateful})
 
@@ -1054,6 +1116,15 @@ func (d *qemu) Start(stateful bool) error {
 		volatileSet["volatile.uuid"] = instUUID
 	}
 
+	// Forget about memory hotplugged into a previous run unless its state is being restored.
+	if !stateful && migrateArgs == nil {
+		for _, key := range []string{"volatile.memory.base", "volatile.memory.hotplug"} {
+			if d.localConfig[key] != "" {
+				volatileSet[key] = ""
+			}
+		}
+	}
+
 	// Apply any volatile changes that need to be made.
 	err = d.VolatileSet(volatileSet)
 	if err != nil {
@@ -1247,6 +1318,9 @@ func (d *qemu) Start(stateful bool) error {
 			return err
 		}
 
+		qemuCmd = append(qemuCmd, "-incoming", "defer")
+	} else if migrateArgs != nil {
+		// The running state will be received from the live migration source.
 		qemuCmd = append(qemuCmd, "-incoming", "defer")
 	} else if d.stateful {
 		// Stateless start requested but state is present, delete it.
@@ -1270,7 +1344,7 @@ func (d *qemu) Start(stateful bool) error {
 	}
 
 	// Attempt to drop privileges (doesn't work when restoring state).
-	if !stateful && d.state.OS.UnprivUser != "" {
+	if !stateful && migrateArgs == nil && d.state.OS.UnprivUser != "" {
 		qemuCmd = append(qemuCmd, "-runas", d.state.OS.UnprivUser)
 
 		// Change ownership of config directory files so they are accessible to the
@@ -1395,6 +1469,20 @@ func (d *qemu) Start(stateful bool) error {
 		return err
 	}
 
+	// Hotplug the vCPUs beyond the first one so that they can be removed while running.
+	if d.cpuHotplugSupported(d.expandedConfig) {
+		cpuCount := 1
+		if d.expandedConfig["limits.cpu"] != "" {
+			cpuCount, _ = strconv.Atoi(d.expandedConfig["limits.cpu"])
+		}
+
+		err = d.setCPUs(monitor, cpuCount)
+		if err != nil {
+			op.Done(err)
+			return err
+		}
+	}
+
 	// Get the list of PIDs from the VM.
 	pids, err := monitor.GetCPUs()
 	if err != nil {
@@ -1461,8 +1549,23 @@ func (d *qemu) Start(stateful bool) error {
 	// Reset timeout to 30s.
 	op.Reset()
 
+	// Recreate the memory hotplugged into the VM being restored so that its layout matches the saved state.
+	if migrateArgs != nil || stateful {
+		err := d.restoreMemoryDIMMs(monitor)
+		if err != nil {
+			op.Done(err)
+			return err
+		}
+	}
+
 	// Restore the state.
-	if stateful {
+	if migrateArgs != nil {
+		err := d.migrateReceiveLive(monitor, *migrateArgs)
+		if err != nil {
+			op.Done(err)
+			return err
+		}
+	} else if stateful {
 		err := d.restoreState(monitor)
 		if err != nil {
 			op.Done(err)
@@ -1529,6 +1632,22 @@ func (d *qemu) setupNvram() error {
 	}
 	defer d.unmount()
 
+	srcOvmfFile, err := d.nvramTemplatePath()
+	if err != nil {
+		return err
+	}
+
+	os.Remove(d.nvramPath())
+	err = shared.FileCopy(srcOvmfFile, d.nvramPath())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// nvramTemplatePath returns the path to the firmware settings file the instance's NVRAM file is created from.
+func (d *qemu) nvramTemplatePath() (string, error) {
 	srcOvmfFile := filepath.Join(d.ovmfPath(), "OVMF_VARS.fd")
 	if d.expandedConfig["security.secureboot"] == "" || shared.IsTrue(d.expandedConfig["security.secureboot"]) {
 		srcOvmfFile = filepath.Join(d.ovmfPath(), "OVMF_VARS.ms.fd")
@@ -1537,24 +1656,190 @@ func (d *qemu) setupNvram() error {
 	missingEFIFirmwareErr := fmt.Errorf("Required EFI firmware settings file missing %q", srcOvmfFile)
 
 	if !shared.PathExists(srcOvmfFile) {
-		return missingEFIFirmwareErr
+		return "", missingEFIFirmwareErr
 	}
 
-	srcOvmfFile, err = filepath.EvalSymlinks(srcOvmfFile)
+	srcOvmfFile, err := filepath.EvalSymlinks(srcOvmfFile)
 	if err != nil {
-		return errors.Wrapf(err, "Failed resolving EFI firmware symlink %q", srcOvmfFile)
+		return "", errors.Wrapf(err, "Failed resolving EFI firmware symlink %q", srcOvmfFile)
 	}
 
 	if !shared.PathExists(srcOvmfFile) {
-		return missingEFIFirmwareErr
+		return "", missingEFIFirmwareErr
 	}
 
-	os.Remove(d.nvramPath())
-	err = shared.FileCopy(srcOvmfFile, d.nvramPath())
+	return srcOvmfFile, nil
+}
+
+// uefiVarsFile is the JSON representation of an UEFI variable store used by the uefivars tool.
+type uefiVarsFile struct {
+	Version   int                `json:"version"`
+	Variables []uefiVarsFileItem `json:"variables"`
+}
+
+// uefiVarsFileItem is a single UEFI variable in an uefiVarsFile.
+type uefiVarsFileItem struct {
+	Name      string `json:"name"`
+	GUID      string `json:"guid"`
+	Attr      uint32 `json:"attr"`
+	Data      string `json:"data"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Digest    string `json:"digest,omitempty"`
+}
+
+// UEFIVars reads the UEFI variables from the instance's NVRAM file.
+func (d *qemu) UEFIVars() (*api.InstanceUEFIVars, error) {
+	// UEFI only on x86_64 and aarch64.
+	if !shared.IntInSlice(d.architecture, []int{osarch.ARCH_64BIT_INTEL_X86, osarch.ARCH_64BIT_ARMV8_LITTLE_ENDIAN}) {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "UEFI is not supported on this architecture")
+	}
+
+	// Prevent the NVRAM file from being changed while reading it. Concurrent reads share the lock.
+	op, err := operationlock.Create(d.Project(), d.Name(), operationlock.ActionUEFIVars, true, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "Create instance UEFI variables operation")
+	}
+	defer op.Done(nil)
+
+	// Mount the instance's config volume.
+	_, err = d.mount()
+	if err != nil {
+		return nil, err
+	}
+	defer d.unmount()
+
+	// Read the firmware defaults if the instance hasn't been started yet.
+	nvramPath := d.nvramPath()
+	if !shared.PathExists(nvramPath) {
+		nvramPath, err = d.nvramTemplatePath()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tmpDir, err := ioutil.TempDir("", "lxd_uefi_vars_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Convert the NVRAM file to JSON.
+	jsonPath := filepath.Join(tmpDir, "vars.json")
+	_, err = shared.RunCommand("uefivars", "-i", "edk2", "-I", nvramPath, "-o", "json", "-O", jsonPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed reading UEFI variables")
+	}
+
+	data, err := ioutil.ReadFile(jsonPath)
+	if err != nil {
+		return nil, err
+	}
+
+	varsFile := uefiVarsFile{}
+	err = json.Unmarshal(data, &varsFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed parsing UEFI variables")
+	}
+
+	vars := api.InstanceUEFIVars{
+		Variables: make(map[string]api.InstanceUEFIVariable, len(varsFile.Variables)),
+	}
+
+	for _, v := range varsFile.Variables {
+		vars.Variables[fmt.Sprintf("%s-%s", v.Name, v.GUID)] = api.InstanceUEFIVariable{
+			Data:      v.Data,
+			Attr:      v.Attr,
+			Timestamp: v.Timestamp,
+			Digest:    v.Digest,
+		}
+	}
+
+	return &vars, nil
+}
+
+// UEFIVarsUpdate replaces the UEFI variables in the instance's NVRAM file.
+// The variable keys and values are expected to have been validated by the caller.
+func (d *qemu) UEFIVarsUpdate(newUEFIVarsSet api.InstanceUEFIVars) error {
+	// UEFI only on x86_64 and aarch64.
+	if !shared.IntInSlice(d.architecture, []int{osarch.ARCH_64BIT_INTEL_X86, osarch.ARCH_64BIT_ARMV8_LITTLE_ENDIAN}) {
+		return api.StatusErrorf(http.StatusBadRequest, "UEFI is not supported on this architecture")
+	}
+
+	// Prevent the instance from being started or its NVRAM file being read while it is being replaced.
+	op, err := operationlock.Create(d.Project(), d.Name(), operationlock.ActionUEFIVars, false, false)
+	if err != nil {
+		return errors.Wrap(err, "Create instance UEFI variables operation")
+	}
+	defer op.Done(nil)
+
+	// The NVRAM file is held open by QEMU while the instance is running.
+	if d.IsRunning() {
+		return api.StatusErrorf(http.StatusBadRequest, "UEFI variables can only be changed while the instance is stopped")
+	}
+
+	varsFile := uefiVarsFile{Version: 2}
+	for key, v := range newUEFIVarsSet.Variables {
+		// Keys have already been validated to be the variable name followed by its (36 characters long) GUID.
+		varsFile.Variables = append(varsFile.Variables, uefiVarsFileItem{
+			Name:      key[:len(key)-37],
+			GUID:      key[len(key)-36:],
+			Attr:      v.Attr,
+			Data:      v.Data,
+			Timestamp: v.Timestamp,
+			Digest:    v.Digest,
+		})
+	}
+
+	// Mount the instance's config volume.
+	_, err = d.mount()
+	if err != nil {
+		return err
+	}
+	defer d.unmount()
+
+	// Generate the NVRAM file if the instance hasn't been started yet, so the firmware volume is preserved.
+	if !shared.PathExists(d.nvramPath()) {
+		err = d.setupNvram()
+		if err != nil {
+			return err
+		}
+	}
+
+	tmpDir, err := ioutil.TempDir("", "lxd_uefi_vars_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	data, err := json.Marshal(varsFile)
+	if err != nil {
+		return err
+	}
+
+	jsonPath := filepath.Join(tmpDir, "vars.json")
+	err = ioutil.WriteFile(jsonPath, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	// Write the new variables to a copy of the NVRAM file, then replace the original.
+	nvramPath := filepath.Join(tmpDir, "qemu.nvram")
+	err = shared.FileCopy(d.nvramPath(), nvramPath)
 	if err != nil {
 		return err
 	}
 
+	_, err = shared.RunCommand("uefivars", "-i", "json", "-I", jsonPath, "-o", "edk2", "-O", nvramPath)
+	if err != nil {
+		return errors.Wrapf(err, "Failed writing UEFI variables")
+	}
+
+	err = shared.FileCopy(nvramPath, d.nvramPath())
+	if err != nil {
+		return err
+	}
+
+	d.state.Events.SendLifecycle(d.project, lifecycle.InstanceUEFIVarsUpdated.Event(d, nil))
 	return nil
 }
 
@@ -1681,6 +1966,10 @@ func (d *qemu) deviceStart(deviceName string, rawConfig deviceConfig.Device, ins
 	if runConf != nil {
 		// If instance is running and then live attach device.
 		if instanceRunning {
+			if runConf.Revert != nil {
+				revert.Add(runConf.Revert.Fail)
+			}
+
 			// Attach network interface if requested.
 			if len(runConf.NetworkInterface) > 0 {
 				err = d.deviceAttachNIC(deviceName, configCopy, runConf.NetworkInterface)
@@ -1689,6 +1978,33 @@ func (d *qemu) deviceStart(deviceName string, rawConfig deviceConfig.Device, ins
 				}
 			}
 
+			// Attach disk if requested.
+			if len(runConf.Mounts) > 0 {
+				err = d.deviceAttachDisk(deviceName, configCopy, runConf.Mounts)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			// Attach USB devices if requested.
+			for _, usbDev := range runConf.USBDevice {
+				err = d.deviceAttachUSB(usbDev)
+				if err != nil {
+					return nil, err
+				}
+
+				usbDev := usbDev // Local var for revert.
+				revert.Add(func() { d.deviceDetachUSB(usbDev) })
+			}
+
+			// Attach PCI device if requested.
+			if len(runConf.PCIDevice) > 0 {
+				err = d.deviceAttachPCI(deviceName, runConf.PCIDevice)
+				if err != nil {
+					return nil, err
+				}
+			}
+
 			// If running, run post start hooks now (if not running LXD will run them
 			// once the instance is started).
 			err = d.runHooks(runConf.PostHooks)
@@ -1768,52 +2084,20 @@ func (d *qemu) deviceAttachNIC(deviceName string, configCopy map[string]string,
 	return nil
 }
 
-// deviceStop loads a new device and calls its Stop() function.
-func (d *qemu) deviceStop(deviceName string, rawConfig deviceConfig.Device, instanceRunning bool) error {
-	logger := logging.AddContext(d.logger, log.Ctx{"device": deviceName, "type": rawConfig["type"]})
-	logger.Debug("Stopping device")
-
-	dev, _, err := d.deviceLoad(deviceName, rawConfig)
-
-	// If deviceLoad fails with unsupported device type then return.
-	if err == device.ErrUnsupportedDevType {
-		return err
-	}
-
-	// If deviceLoad fails for any other reason then just log the error and proceed, as in the
-	// scenario that a new version of LXD has additional validation restrictions than older
-	// versions we still need to allow previously valid devices to be stopped.
-	if err != nil {
-		// If there is no device returned, then we cannot proceed, so return as error.
-		if dev == nil {
-			return fmt.Errorf("Device stop validation failed for %q: %v", deviceName, err)
-		}
-
-		logger.Error("Device stop validation failed", log.Ctx{"err": err})
-	}
-
-	if instanceRunning && !dev.CanHotPlug() {
-		return fmt.Errorf("Device cannot be stopped when instance is running")
-	}
-
-	runConf, err := dev.Stop()
+// deviceAttachDisk live attaches the mounts of a disk device to the instance.
+func (d *qemu) deviceAttachDisk(deviceName string, configCopy map[string]string, mounts []deviceConfig.MountEntryItem) error {
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
 	if err != nil {
 		return err
 	}
 
-	if runConf != nil {
-		if runConf != nil {
-			// Detach NIC from running instance.
-			if rawConfig["type"] == "nic" && instanceRunning {
-				err = d.deviceDetachNIC(deviceName)
-				if err != nil {
-					return err
-				}
-			}
+	for _, mount := range mounts {
+		if mount.FSType == "9p" {
+			err = d.deviceAttachPath(monitor, deviceName, configCopy, mount)
+		} else {
+			err = d.deviceAttachBlockDevice(monitor, mount)
 		}
 
-		// Run post stop hooks irrespective of run state of instance.
-		err = d.runHooks(runConf.PostHooks)
 		if err != nil {
 			return err
 		}
@@ -1822,458 +2106,488 @@ func (d *qemu) deviceStop(deviceName string, rawConfig deviceConfig.Device, inst
 	return nil
 }
 
-// deviceDetachNIC detaches a NIC device from a running instance.
-func (d *qemu) deviceDetachNIC(deviceName string) error {
-	// Check if the agent is running.
-	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
-	if err != nil {
-		return err
+// deviceAttachBlockDevice live attaches a drive to the instance's SCSI controller.
+func (d *qemu) deviceAttachBlockDevice(monitor *qmp.Monitor, mount deviceConfig.MountEntryItem) error {
+	if strings.HasPrefix(mount.DevPath, "rbd:") {
+		return fmt.Errorf("Ceph RBD drives cannot be attached to a running instance")
 	}
 
-	// pciDeviceExists checks if the deviceID exists as a bridged PCI device.
-	pciDeviceExists := func(deviceID string) (bool, error) {
-		pciDevs, err := monitor.QueryPCI()
-		if err != nil {
-			return false, err
+	revert := revert.New()
+	defer revert.Fail()
+
+	nodeName := fmt.Sprintf("%s%s", qemuBlockDevIDPrefix, mount.DevName)
+	readonly := shared.StringInSlice("ro", mount.Opts)
+
+	// Work out the path to open, reopening the file descriptor supplied by the device if any.
+	srcDevPath := mount.DevPath
+	openPath := mount.DevPath
+	if strings.HasPrefix(mount.DevPath, fmt.Sprintf("%s:", device.DiskFileDescriptorMountPrefix)) {
+		// Expect devPath in format "fd:<fdNum>:<devPath>".
+		devPathParts := strings.SplitN(mount.DevPath, ":", 3)
+		if len(devPathParts) != 3 {
+			return fmt.Errorf("Unexpected devPath file descriptor format %q for drive %q", mount.DevPath, mount.DevName)
 		}
 
-		for _, pciDev := range pciDevs {
-			for _, bridgeDev := range pciDev.Bridge.Devices {
-				if bridgeDev.DevID == deviceID {
-					return true, nil
-				}
-			}
+		fd, err := strconv.Atoi(devPathParts[1])
+		if err != nil {
+			return fmt.Errorf("Invalid file descriptor %q for drive %q: %w", devPathParts[1], mount.DevName, err)
 		}
 
-		return false, nil
+		srcDevPath = devPathParts[2]
+		openPath = fmt.Sprintf("/proc/self/fd/%d", fd)
 	}
 
-	deviceID := fmt.Sprintf("%s%s", qemuDeviceIDPrefix, deviceName)
-	netDevID := fmt.Sprintf("%s%s", qemuNetDevIDPrefix, deviceName)
-
-	// Request removal of device.
-	err = monitor.RemoveNIC(netDevID, deviceID)
+	aioMode, cacheMode, media, err := d.driveIOConfig(srcDevPath, mount)
 	if err != nil {
 		return err
 	}
 
-	_, qemuBus, err := d.qemuArchConfig(d.architecture)
-	if err != nil {
-		return err
+	// Only SCSI drives can be presented as a CD-ROM, so present others as read-only disks.
+	ioBus, ioThread := qemuDriveBus(mount)
+	if media == "cdrom" && ioBus != "virtio-scsi" {
+		readonly = true
 	}
 
-	if shared.StringInSlice(qemuBus, []string{"pcie", "pci"}) {
-		// Wait until the device is actually removed (or we timeout waiting).
-		waitDuration := time.Duration(time.Second * time.Duration(10))
-		waitUntil := time.Now().Add(waitDuration)
-		for {
-			devExists, err := pciDeviceExists(deviceID)
-			if err != nil {
-				return errors.Wrapf(err, "Failed getting PCI devices to check for NIC detach")
-			}
-
-			if !devExists {
-				break
-
-			}
-
-			if time.Now().After(waitUntil) {
-				return errors.Wrapf(err, "Failed to detach NIC after %v", waitDuration)
-			}
-
-			d.logger.Debug("Waiting for NIC device to be detached", log.Ctx{"device": deviceName})
-			time.Sleep(time.Second * time.Duration(2))
-		}
+	// QEMU only uses a file descriptor from a file descriptor set if its access mode matches the one QEMU
+	// needs, so open the drive with the right mode. The QEMU process can't open the drive itself as the path
+	// isn't part of the AppArmor profile generated when it started.
+	flags := os.O_RDWR
+	if readonly {
+		flags = os.O_RDONLY
 	}
 
-	return nil
-}
+	f, err := os.OpenFile(openPath, flags, 0)
+	if err != nil {
+		return fmt.Errorf("Failed opening drive %q: %w", mount.DevName, err)
+	}
 
-func (d *qemu) monitorPath() string {
-	return filepath.Join(d.LogPath(), "qemu.monitor")
-}
+	defer f.Close() // QEMU has its own copy once sent.
 
-func (d *qemu) nvramPath() string {
-	return filepath.Join(d.Path(), "qemu.nvram")
-}
+	fdSet, err := monitor.AddFDToFDSet(nodeName, f)
+	if err != nil {
+		return err
+	}
 
-func (d *qemu) spicePath() string {
-	return filepath.Join(d.LogPath(), "qemu.spice")
-}
+	revert.Add(func() { monitor.RemoveFDFromFDSet(nodeName) })
 
-func (d *qemu) spiceCmdlineConfig() string {
-	return fmt.Sprintf("unix=on,disable-ticketing=on,addr=%s", d.spicePath())
-}
+	fileDriver := "file"
+	if shared.IsBlockdevPath(srcDevPath) {
+		fileDriver = "host_device"
+	}
 
-// generateConfigShare generates the config share directory that will be exported to the VM via
-// a 9P share. Due to the unknown size of templates inside the images this directory is created
-// inside the VM's config volume so that it can be restricted by quota.
-// Requires the instance be mounted before calling this function.
-func (d *qemu) generateConfigShare() error {
-	configDrivePath := filepath.Join(d.Path(), "config")
+	blockDev := map[string]interface{}{
+		"driver":    "raw",
+		"node-name": nodeName,
+		"read-only": readonly,
+		"discard":   "unmap",
+		"cache": map[string]interface{}{
+			"direct":   cacheMode == "none",
+			"no-flush": cacheMode == "unsafe",
+		},
+		"file": map[string]interface{}{
+			"driver":   fileDriver,
+			"filename": fmt.Sprintf("/dev/fdset/%d", fdSet.ID),
+			"aio":      aioMode,
+			"locking":  "off",
+		},
+	}
 
-	// Create config drive dir if doesn't exist, if it does exist, leave it around so we don't regenerate all
-	// files causing unnecessary config drive snapshot usage.
-	err := os.MkdirAll(configDrivePath, 0500)
+	err = monitor.AddBlockDevice(blockDev)
 	if err != nil {
 		return err
 	}
 
-	// Add the VM agent.
-	lxdAgentSrcPath, err := exec.LookPath("lxd-agent")
-	if err != nil {
-		d.logger.Warn("lxd-agent not found, skipping its inclusion in the VM config drive", log.Ctx{"err": err})
-	} else {
-		// Install agent into config drive dir if found.
-		lxdAgentSrcPath, err = filepath.EvalSymlinks(lxdAgentSrcPath)
+	revert.Add(func() { monitor.RemoveBlockDevice(nodeName) })
+
+	ioThreadID := fmt.Sprintf("iothread_%s", mount.DevName)
+	scsiBus := "qemu_scsi.0"
+
+	if ioThread {
+		err = monitor.AddObject(map[string]interface{}{
+			"qom-type": "iothread",
+			"id":       ioThreadID,
+		})
 		if err != nil {
 			return err
 		}
 
-		lxdAgentSrcInfo, err := os.Stat(lxdAgentSrcPath)
+		revert.Add(func() { monitor.RemoveObject(ioThreadID) })
+	}
+
+	// Drives not on the shared SCSI controller need a device of their own on the instance's bus.
+	addBusDevice := func(qemuDev map[string]string) error {
+		_, qemuBus, err := d.qemuArchConfig(d.architecture)
 		if err != nil {
-			return errors.Wrapf(err, "Failed getting info for lxd-agent source %q", lxdAgentSrcPath)
+			return err
 		}
 
-		lxdAgentInstallPath := filepath.Join(configDrivePath, "lxd-agent")
-		lxdAgentNeedsInstall := true
-
-		if shared.PathExists(lxdAgentInstallPath) {
-			lxdAgentInstallInfo, err := os.Stat(lxdAgentInstallPath)
+		switch qemuBus {
+		case "pcie":
+			portName, err := d.freePCIePort(monitor)
 			if err != nil {
-				return errors.Wrapf(err, "Failed getting info for existing lxd-agent install %q", lxdAgentInstallPath)
+				return err
 			}
 
-			if lxdAgentInstallInfo.ModTime() == lxdAgentSrcInfo.ModTime() && lxdAgentInstallInfo.Size() == lxdAgentSrcInfo.Size() {
-				lxdAgentNeedsInstall = false
+			qemuDev["bus"] = portName
+			qemuDev["addr"] = "00.0"
+			qemuDev["driver"] += "-pci"
+		case "pci":
+			qemuDev["driver"] += "-pci"
+		case "ccw":
+			if ioBus == "nvme" {
+				return fmt.Errorf("The nvme bus isn't supported on this architecture for drive %q", mount.DevName)
 			}
+
+			qemuDev["driver"] += "-ccw"
 		}
 
-		// Only install the lxd-agent into config drive if the existing one is different to the source one.
-		// Otherwise we would end up copying it again and this can cause unnecessary snapshot usage.
-		if lxdAgentNeedsInstall {
-			d.logger.Debug("Installing lxd-agent", log.Ctx{"srcPath": lxdAgentSrcPath, "installPath": lxdAgentInstallPath})
-			err = shared.FileCopy(lxdAgentSrcPath, lxdAgentInstallPath)
-			if err != nil {
-				return err
-			}
+		// The nvme device has no bus specific variants.
+		if ioBus == "nvme" {
+			qemuDev["driver"] = "nvme"
+		}
 
-			err = os.Chmod(lxdAgentInstallPath, 0500)
-			if err != nil {
-				return err
-			}
+		err = monitor.AddDevice(qemuDev)
+		if err != nil {
+			return err
+		}
 
-			err = os.Chown(lxdAgentInstallPath, 0, 0)
+		revert.Add(func() { monitor.RemoveDevice(qemuDev["id"]) })
+
+		return nil
+	}
+
+	qemuDev := map[string]string{
+		"id":    fmt.Sprintf("%s%s", qemuDeviceIDPrefix, mount.DevName),
+		"drive": nodeName,
+	}
+
+	switch ioBus {
+	case "virtio-blk":
+		qemuDev["driver"] = "virtio-blk"
+		if ioThread {
+			qemuDev["iothread"] = ioThreadID
+		}
+
+		err = addBusDevice(qemuDev)
+	case "nvme":
+		qemuDev["driver"] = "nvme"
+		qemuDev["serial"] = nodeName
+
+		err = addBusDevice(qemuDev)
+	default:
+		// Use a SCSI controller of its own when the drive has a dedicated I/O thread.
+		if ioThread {
+			err = addBusDevice(map[string]string{
+				"id":       fmt.Sprintf("qemu_scsi_%s", mount.DevName),
+				"driver":   "virtio-scsi",
+				"iothread": ioThreadID,
+			})
 			if err != nil {
 				return err
 			}
 
-			// Ensure we copy the source file's timestamps so they can be used for comparison later.
-			err = os.Chtimes(lxdAgentInstallPath, lxdAgentSrcInfo.ModTime(), lxdAgentSrcInfo.ModTime())
-			if err != nil {
-				return errors.Wrapf(err, "Failed setting lxd-agent timestamps")
-			}
-		} else {
-			d.logger.Debug("Skipping lxd-agent install as unchanged", log.Ctx{"srcPath": lxdAgentSrcPath, "installPath": lxdAgentInstallPath})
+			scsiBus = fmt.Sprintf("qemu_scsi_%s.0", mount.DevName)
+		}
+
+		// Let QEMU pick a free SCSI ID, the boot index based one may be used by another drive until next
+		// boot.
+		qemuDev["driver"] = "scsi-hd"
+		qemuDev["bus"] = scsiBus
+		qemuDev["channel"] = "0"
+		qemuDev["lun"] = "1"
+
+		if media == "cdrom" {
+			qemuDev["driver"] = "scsi-cd"
 		}
+
+		err = monitor.AddDevice(qemuDev)
 	}
 
-	agentCert, agentKey, clientCert, _, err := d.generateAgentCert()
 	if err != nil {
 		return err
 	}
 
-	err = ioutil.WriteFile(filepath.Join(configDrivePath, "server.crt"), []byte(clientCert), 0400)
-	if err != nil {
-		return err
+	revert.Success()
+	return nil
+}
+
+// deviceAttachPath live attaches a directory share to the instance using virtio-fs and requests the agent
+// mounts it. Shares can't be live attached using 9p, as QEMU can't add its backend once started.
+func (d *qemu) deviceAttachPath(monitor *qmp.Monitor, deviceName string, configCopy map[string]string, mount deviceConfig.MountEntryItem) error {
+	virtiofsdSockPath := qemuVirtiofsdSockPath(mount)
+	if virtiofsdSockPath == "" {
+		return fmt.Errorf("Directory shares can only be attached to a running instance when virtiofsd is available")
 	}
 
-	err = ioutil.WriteFile(filepath.Join(configDrivePath, "agent.crt"), []byte(agentCert), 0400)
+	_, qemuBus, err := d.qemuArchConfig(d.architecture)
 	if err != nil {
 		return err
 	}
 
-	err = ioutil.WriteFile(filepath.Join(configDrivePath, "agent.key"), []byte(agentKey), 0400)
-	if err != nil {
-		return err
+	revert := revert.New()
+	defer revert.Fail()
+
+	agentMount := qemuDriveDirAgentMount(mount)
+	deviceID := fmt.Sprintf("%s%s-virtio-fs", qemuDeviceIDPrefix, mount.DevName)
+
+	charDev := map[string]interface{}{
+		"id": agentMount.Source,
+		"backend": map[string]interface{}{
+			"type": "socket",
+			"data": map[string]interface{}{
+				"addr": map[string]interface{}{
+					"type": "unix",
+					"data": map[string]interface{}{
+						"path": virtiofsdSockPath,
+					},
+				},
+				"server": false,
+			},
+		},
 	}
 
-	// Systemd units.
-	err = os.MkdirAll(filepath.Join(configDrivePath, "systemd"), 0500)
+	err = monitor.AddCharDevice(charDev)
 	if err != nil {
 		return err
 	}
 
-	lxdAgentServiceUnit := `[Unit]
-Description=LXD - agent
-Documentation=https://linuxcontainers.org/lxd
-ConditionPathExists=/dev/virtio-ports/org.linuxcontainers.lxd
-Before=cloud-init.target cloud-init.service cloud-init-local.service
-DefaultDependencies=no
+	revert.Add(func() { monitor.RemoveCharDevice(agentMount.Source) })
 
-[Service]
-Type=notify
-WorkingDirectory=-/run/lxd_agent
-ExecStartPre=/lib/systemd/lxd-agent-setup
-ExecStart=/run/lxd_agent/lxd-agent
-Restart=on-failure
-RestartSec=5s
-StartLimitInterval=60
-StartLimitBurst=10
+	qemuDev := map[string]string{
+		"id":      deviceID,
+		"driver":  "vhost-user-fs-pci",
+		"chardev": agentMount.Source,
+		"tag":     agentMount.Source,
+	}
 
-[Install]
-WantedBy=multi-user.target
-`
+	switch qemuBus {
+	case "pcie":
+		// PCIe requires a free port device to hotplug the share into.
+		portName, err := d.freePCIePort(monitor)
+		if err != nil {
+			return err
+		}
 
-	err = ioutil.WriteFile(filepath.Join(configDrivePath, "systemd", "lxd-agent.service"), []byte(lxdAgentServiceUnit), 0400)
+		d.logger.Debug("Using PCI bus device to hotplug share into", log.Ctx{"device": deviceName, "port": portName})
+		qemuDev["bus"] = portName
+		qemuDev["addr"] = "00.0"
+	case "ccw":
+		qemuDev["driver"] = "vhost-user-fs-ccw"
+	}
+
+	err = monitor.AddDevice(qemuDev)
 	if err != nil {
 		return err
 	}
 
-	lxdAgentSetupScript := `#!/bin/sh
-set -eu
-PREFIX="/run/lxd_agent"
+	revert.Add(func() { monitor.RemoveDevice(deviceID) })
 
-# Functions.
-mount_virtiofs() {
-    mount -t virtiofs config "${PREFIX}/.mnt" >/dev/null 2>&1
-}
+	// Request the agent mounts the share. The agent may not be running yet, in which case it will mount the
+	// share on next boot.
+	msg := map[string]interface{}{
+		"action": "added",
+		"name":   deviceName,
+		"config": configCopy,
+		"mount":  agentMount,
+	}
 
-mount_9p() {
-    /sbin/modprobe 9pnet_virtio >/dev/null 2>&1 || true
-    /bin/mount -t 9p config "${PREFIX}/.mnt" -o access=0,trans=virtio >/dev/null 2>&1
-}
+	err = d.devlxdEventSend("device", msg)
+	if err != nil {
+		d.logger.Warn("Failed requesting the agent mounts the share", log.Ctx{"device": deviceName, "err": err})
+	}
 
-fail() {
-    umount -l "${PREFIX}" >/dev/null 2>&1 || true
-    rmdir "${PREFIX}" >/dev/null 2>&1 || true
-    echo "${1}"
-    exit 1
+	revert.Success()
+	return nil
 }
 
-# Setup the mount target.
-umount -l "${PREFIX}" >/dev/null 2>&1 || true
-mkdir -p "${PREFIX}"
-mount -t tmpfs tmpfs "${PREFIX}" -o mode=0700,size=50M
-mkdir -p "${PREFIX}/.mnt"
-
-# Try virtiofs first.
-mount_virtiofs || mount_9p || fail "Couldn't mount virtiofs or 9p, failing."
-
-# Copy the data.
-cp -Ra "${PREFIX}/.mnt/"* "${PREFIX}"
+// freePCIePort returns the name of an empty PCIe port that a device can be hotplugged into.
+func (d *qemu) freePCIePort(monitor *qmp.Monitor) (string, error) {
+	pciDevs, err := monitor.QueryPCI()
+	if err != nil {
+		return "", err
+	}
 
-# Unmount the temporary mount.
-umount "${PREFIX}/.mnt"
-rmdir "${PREFIX}/.mnt"
+	// Start from the last port, as NIC hotplugging expects the port matching its position in the devices
+	// list to be available.
+	for i := len(pciDevs) - 1; i >= 0; i-- {
+		if strings.HasPrefix(pciDevs[i].DevID, busDevicePortPrefix) && len(pciDevs[i].Bridge.Devices) == 0 {
+			return pciDevs[i].DevID, nil
+		}
+	}
 
-# Fix up permissions.
-chown -R root:root "${PREFIX}"
-`
+	return "", fmt.Errorf("No free PCIe port available to hotplug into")
+}
 
-	err = ioutil.WriteFile(filepath.Join(configDrivePath, "systemd", "lxd-agent-setup"), []byte(lxdAgentSetupScript), 0500)
+// deviceAttachUSB live attaches a host USB device to the instance's USB controller.
+func (d *qemu) deviceAttachUSB(usbDev deviceConfig.USBDeviceItem) error {
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
 	if err != nil {
 		return err
 	}
 
-	// Udev rules
-	err = os.MkdirAll(filepath.Join(configDrivePath, "udev"), 0500)
+	deviceID := fmt.Sprintf("%s%s", qemuDeviceIDPrefix, usbDev.DeviceName)
+
+	// The USB device may already have been attached, for example if it was present when the instance started.
+	exists, err := monitor.DeviceExists(deviceID)
 	if err != nil {
 		return err
 	}
 
-	lxdAgentRules := `ACTION=="add", SYMLINK=="virtio-ports/org.linuxcontainers.lxd", TAG+="systemd", ACTION=="add", RUN+="/bin/systemctl start lxd-agent.service"`
-	err = ioutil.WriteFile(filepath.Join(configDrivePath, "udev", "99-lxd-agent.rules"), []byte(lxdAgentRules), 0400)
-	if err != nil {
-		return err
+	if exists {
+		return nil
 	}
 
-	// Install script for manual installs.
-	lxdConfigShareInstall := `#!/bin/sh
-if [ ! -e "systemd" ] || [ ! -e "lxd-agent" ]; then
-    echo "This script must be run from within the 9p mount"
-    exit 1
-fi
+	revert := revert.New()
+	defer revert.Fail()
 
-if [ ! -e "/lib/systemd/system" ]; then
-    echo "This script only works on systemd systems"
-    exit 1
-fi
+	// The QEMU process can't open the USB device itself as its path isn't part of the AppArmor profile
+	// generated when it started, so pass it an open file descriptor instead.
+	f, err := os.OpenFile(usbDev.HostDevicePath, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("Failed opening USB device %q: %w", usbDev.HostDevicePath, err)
+	}
 
-# Cleanup former units.
-rm -f /lib/systemd/system/lxd-agent-9p.service \
-    /lib/systemd/system/lxd-agent-virtiofs.service \
-    /etc/systemd/system/multi-user.target.wants/lxd-agent-9p.service \
-    /etc/systemd/system/multi-user.target.wants/lxd-agent-virtiofs.service
+	defer f.Close() // QEMU has its own copy once sent.
 
-# Install the units.
-cp udev/99-lxd-agent.rules /lib/udev/rules.d/
-cp systemd/lxd-agent.service /lib/systemd/system/
-cp systemd/lxd-agent-setup /lib/systemd/
-systemctl daemon-reload
-systemctl enable lxd-agent.service
+	fdSet, err := monitor.AddFDToFDSet(deviceID, f)
+	if err != nil {
+		return err
+	}
 
-echo ""
-echo "LXD agent has been installed, reboot to confirm setup."
-echo "To start it now, unmount this filesystem and run: systemctl start lxd-agent"
-`
+	revert.Add(func() { monitor.RemoveFDFromFDSet(deviceID) })
 
-	err = ioutil.WriteFile(filepath.Join(configDrivePath, "install.sh"), []byte(lxdConfigShareInstall), 0700)
+	qemuDev := map[string]string{
+		"id":         deviceID,
+		"driver":     "usb-host",
+		"bus":        "qemu_usb.0",
+		"hostdevice": fmt.Sprintf("/dev/fdset/%d", fdSet.ID),
+	}
+
+	err = monitor.AddDevice(qemuDev)
 	if err != nil {
 		return err
 	}
 
-	// Instance data for devlxd.
-	err = d.writeInstanceData()
+	revert.Success()
+	return nil
+}
+
+// deviceAttachPCI live attaches a PCI device to the instance using VFIO.
+func (d *qemu) deviceAttachPCI(deviceName string, pciConfig []deviceConfig.RunConfigItem) error {
+	_, qemuBus, err := d.qemuArchConfig(d.architecture)
 	if err != nil {
 		return err
 	}
 
-	// Templated files.
-	templateFilesPath := filepath.Join(configDrivePath, "files")
-
-	// Clear path and recreate.
-	os.RemoveAll(templateFilesPath)
-	err = os.MkdirAll(templateFilesPath, 0500)
-	if err != nil {
-		return err
+	if qemuBus != "pcie" {
+		return fmt.Errorf("PCI devices can only be attached to a running instance on a PCIe bus")
 	}
 
-	// Template anything that needs templating.
-	key := "volatile.apply_template"
-	if d.localConfig[key] != "" {
-		// Run any template that needs running.
-		err = d.templateApplyNow(instance.TemplateTrigger(d.localConfig[key]), templateFilesPath)
-		if err != nil {
-			return err
+	var devName, pciSlotName string
+	for _, pciItem := range pciConfig {
+		if pciItem.Key == "devName" {
+			devName = pciItem.Value
+		} else if pciItem.Key == "pciSlotName" {
+			pciSlotName = pciItem.Value
 		}
+	}
 
-		// Remove the volatile key from the DB.
-		err := d.state.Cluster.DeleteInstanceConfigKey(d.id, key)
-		if err != nil {
-			return err
-		}
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
+	if err != nil {
+		return err
 	}
 
-	err = d.templateApplyNow("start", templateFilesPath)
+	portName, err := d.freePCIePort(monitor)
 	if err != nil {
 		return err
 	}
 
-	// Copy the template metadata itself too.
-	metaPath := filepath.Join(d.Path(), "metadata.yaml")
-	if shared.PathExists(metaPath) {
-		err = shared.FileCopy(metaPath, filepath.Join(templateFilesPath, "metadata.yaml"))
-		if err != nil {
-			return err
-		}
+	d.logger.Debug("Using PCI bus device to hotplug PCI device into", log.Ctx{"device": deviceName, "port": portName})
+
+	qemuDev := map[string]string{
+		"id":     fmt.Sprintf("%s%s", qemuDeviceIDPrefix, devName),
+		"driver": "vfio-pci",
+		"host":   pciSlotName,
+		"bus":    portName,
+		"addr":   "00.0",
 	}
 
-	return nil
+	return monitor.AddDevice(qemuDev)
 }
 
-func (d *qemu) templateApplyNow(trigger instance.TemplateTrigger, path string) error {
-	// If there's no metadata, just return.
-	fname := filepath.Join(d.Path(), "metadata.yaml")
-	if !shared.PathExists(fname) {
-		return nil
+// deviceStop loads a new device and calls its Stop() function.
+func (d *qemu) deviceStop(deviceName string, rawConfig deviceConfig.Device, instanceRunning bool) error {
+	logger := logging.AddContext(d.logger, log.Ctx{"device": deviceName, "type": rawConfig["type"]})
+	logger.Debug("Stopping device")
+
+	dev, _, err := d.deviceLoad(deviceName, rawConfig)
+
+	// If deviceLoad fails with unsupported device type then return.
+	if err == device.ErrUnsupportedDevType {
+		return err
 	}
 
-	// Parse the metadata.
-	content, err := ioutil.ReadFile(fname)
+	// If deviceLoad fails for any other reason then just log the error and proceed, as in the
+	// scenario that a new version of LXD has additional validation restrictions than older
+	// versions we still need to allow previously valid devices to be stopped.
 	if err != nil {
-		return errors.Wrap(err, "Failed to read metadata")
+		// If there is no device returned, then we cannot proceed, so return as error.
+		if dev == nil {
+			return fmt.Errorf("Device stop validation failed for %q: %v", deviceName, err)
+		}
+
+		logger.Error("Device stop validation failed", log.Ctx{"err": err})
 	}
 
-	metadata := new(api.ImageMetadata)
-	err = yaml.Unmarshal(content, &metadata)
-	if err != nil {
-		return errors.Wrapf(err, "Could not parse %s", fname)
+	if instanceRunning && !dev.CanHotPlug() {
+		return fmt.Errorf("Device cannot be stopped when instance is running")
 	}
 
-	// Figure out the instance architecture.
-	arch, err := osarch.ArchitectureName(d.architecture)
-	if err != nil {
-		arch, err = osarch.ArchitectureName(d.state.OS.Architectures[0])
+	// Detach disk from running instance before stopping the device, so that the guest has released it
+	// before its source goes away.
+	if rawConfig["type"] == "disk" && instanceRunning {
+		err = d.deviceDetachDisk(deviceName, rawConfig)
 		if err != nil {
-			return errors.Wrap(err, "Failed to detect system architecture")
+			return err
 		}
 	}
 
-	// Generate the container metadata.
-	instanceMeta := make(map[string]string)
-	instanceMeta["name"] = d.name
-	instanceMeta["type"] = "virtual-machine"
-	instanceMeta["architecture"] = arch
-
-	if d.ephemeral {
-		instanceMeta["ephemeral"] = "true"
-	} else {
-		instanceMeta["ephemeral"] = "false"
+	runConf, err := dev.Stop()
+	if err != nil {
+		return err
 	}
 
-	// Go through the templates.
-	for tplPath, tpl := range metadata.Templates {
-		err = func(tplPath string, tpl *api.ImageMetadataTemplate) error {
-			var w *os.File
-
-			// Check if the template should be applied now.
-			found := false
-			for _, tplTrigger := range tpl.When {
-				if tplTrigger == string(trigger) {
-					found = true
-					break
+	if runConf != nil {
+		if runConf != nil {
+			// Detach NIC from running instance.
+			if rawConfig["type"] == "nic" && instanceRunning {
+				err = d.deviceDetachNIC(deviceName)
+				if err != nil {
+					return err
 				}
 			}
 
-			if !found {
-				return nil
-			}
-
-			// Create the file itself.
-			w, err = os.Create(filepath.Join(path, fmt.Sprintf("%s.out", tpl.Template)))
-			if err != nil {
-				return err
-			}
-
-			// Fix ownership and mode.
-			w.Chmod(0644)
-			defer w.Close()
-
-			// Read the template.
-			tplString, err := ioutil.ReadFile(filepath.Join(d.TemplatesPath(), tpl.Template))
-			if err != nil {
-				return errors.Wrap(err, "Failed to read template file")
-			}
-
-			// Restrict filesystem access to within the container's rootfs.
-			tplSet := pongo2.NewSet(fmt.Sprintf("%s-%s", d.name, tpl.Template), pongoTemplate.ChrootLoader{Path: d.TemplatesPath()})
-			tplRender, err := tplSet.FromString("{% autoescape off %}" + string(tplString) + "{% endautoescape %}")
-			if err != nil {
-				return errors.Wrap(err, "Failed to render template")
+			// Detach USB devices from running instance.
+			if instanceRunning {
+				for _, usbDev := range runConf.USBDevice {
+					err = d.deviceDetachUSB(usbDev)
+					if err != nil {
+						return err
+					}
+				}
 			}
 
-			configGet := func(confKey, confDefault *pongo2.Value) *pongo2.Value {
-				val, ok := d.expandedConfig[confKey.String()]
-				if !ok {
-					return confDefault
+			// Detach PCI device from running instance before it is bound back to its host driver.
+			if rawConfig["type"] == "pci" && instanceRunning {
+				err = d.deviceDetachPCI(deviceName)
+				if err != nil {
+					return err
 				}
-
-				return pongo2.AsValue(strings.TrimRight(val, "\r\n"))
 			}
+		}
 
-			// Render the template.
-			tplRender.ExecuteWriter(pongo2.Context{"trigger": trigger,
-				"path":       tplPath,
-				"instance":   instanceMeta,
-				"container":  instanceMeta, // FIXME: remove once most images have moved away.
-				"config":     d.expandedConfig,
-				"devices":    d.expandedDevices,
-				"properties": tpl.Properties,
-				"config_get": configGet}, w)
-
-			return nil
-		}(tplPath, tpl)
+		// Run post stop hooks irrespective of run state of instance.
+		err = d.runHooks(runConf.PostHooks)
 		if err != nil {
 			return err
 		}
@@ -2282,2529 +2596,4071 @@ func (d *qemu) templateApplyNow(trigger instance.TemplateTrigger, path string) e
 	return nil
 }
 
-// deviceBootPriorities returns a map keyed on device name containing the boot index to use.
-// Qemu tries to boot devices in order of boot index (lowest first).
-func (d *qemu) deviceBootPriorities() (map[string]int, error) {
-	type devicePrios struct {
-		Name     string
-		BootPrio uint32
+// deviceDetachNIC detaches a NIC device from a running instance.
+func (d *qemu) deviceDetachNIC(deviceName string) error {
+	// Check if the agent is running.
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
+	if err != nil {
+		return err
 	}
 
-	devices := []devicePrios{}
-
-	for _, dev := range d.expandedDevices.Sorted() {
-		if dev.Config["type"] != "disk" && dev.Config["type"] != "nic" {
-			continue
+	// pciDeviceExists checks if the deviceID exists as a bridged PCI device.
+	pciDeviceExists := func(deviceID string) (bool, error) {
+		pciDevs, err := monitor.QueryPCI()
+		if err != nil {
+			return false, err
 		}
 
-		bootPrio := uint32(0) // Default to lowest priority.
-		if dev.Config["boot.priority"] != "" {
-			prio, err := strconv.ParseInt(dev.Config["boot.priority"], 10, 32)
-			if err != nil {
-				return nil, errors.Wrapf(err, "Invalid boot.priority for device %q", dev.Name)
+		for _, pciDev := range pciDevs {
+			for _, bridgeDev := range pciDev.Bridge.Devices {
+				if bridgeDev.DevID == deviceID {
+					return true, nil
+				}
 			}
-			bootPrio = uint32(prio)
-		} else if dev.Config["path"] == "/" {
-			bootPrio = 1 // Set boot priority of root disk higher than any device without a boot prio.
 		}
 
-		devices = append(devices, devicePrios{Name: dev.Name, BootPrio: bootPrio})
-	}
-
-	// Sort devices by priority (use SliceStable so that devices with the same boot priority stay in the same
-	// order each boot based on the device order provided by the d.expandedDevices.Sorted() function).
-	// This is important because as well as providing a predicable boot index order, the boot index number can
-	// also be used for other properties (such as disk SCSI ID) which can result in it being given different
-	// device names inside the guest based on the device order.
-	sort.SliceStable(devices, func(i, j int) bool { return devices[i].BootPrio > devices[j].BootPrio })
-
-	sortedDevs := make(map[string]int, len(devices))
-	for bootIndex, dev := range devices {
-		sortedDevs[dev.Name] = bootIndex
+		return false, nil
 	}
 
-	return sortedDevs, nil
-}
-
-// generateQemuConfigFile writes the qemu config file and returns its location.
-// It writes the config file inside the VM's log path.
-func (d *qemu) generateQemuConfigFile(mountInfo *storagePools.MountInfo, busName string, devConfs []*deviceConfig.RunConfig, fdFiles *[]*os.File) (string, []monitorHook, error) {
-	var sb *strings.Builder = &strings.Builder{}
-	var monHooks []monitorHook
+	deviceID := fmt.Sprintf("%s%s", qemuDeviceIDPrefix, deviceName)
+	netDevID := fmt.Sprintf("%s%s", qemuNetDevIDPrefix, deviceName)
 
-	err := qemuBase.Execute(sb, map[string]interface{}{
-		"architecture": d.architectureName,
-	})
+	// Request removal of device.
+	err = monitor.RemoveNIC(netDevID, deviceID)
 	if err != nil {
-		return "", nil, err
+		return err
 	}
 
-	cpuCount, err := d.addCPUMemoryConfig(sb)
+	_, qemuBus, err := d.qemuArchConfig(d.architecture)
 	if err != nil {
-		return "", nil, err
+		return err
 	}
 
-	err = qemuDriveFirmware.Execute(sb, map[string]interface{}{
-		"architecture": d.architectureName,
-		"roPath":       filepath.Join(d.ovmfPath(), "OVMF_CODE.fd"),
-		"nvramPath":    d.nvramPath(),
-	})
-	if err != nil {
-		return "", nil, err
-	}
+	if shared.StringInSlice(qemuBus, []string{"pcie", "pci"}) {
+		// Wait until the device is actually removed (or we timeout waiting).
+		waitDuration := time.Duration(time.Second * time.Duration(10))
+		waitUntil := time.Now().Add(waitDuration)
+		for {
+			devExists, err := pciDeviceExists(deviceID)
+			if err != nil {
+				return errors.Wrapf(err, "Failed getting PCI devices to check for NIC detach")
+			}
 
-	err = qemuControlSocket.Execute(sb, map[string]interface{}{
-		"path": d.monitorPath(),
-	})
-	if err != nil {
-		return "", nil, err
-	}
-
-	// Setup the bus allocator.
-	bus := qemuNewBus(busName, sb)
-
-	// Now add the fixed set of devices. The multi-function groups used for these fixed internal devices are
-	// specifically chosen to ensure that we consume exactly 4 PCI bus ports (on PCIe bus). This ensures that
-	// the first user device NIC added will use the 5th PCI bus port and will be consistently named enp5s0
-	// on PCIe (which we need to maintain compatibility with network configuration in our existing VM images).
-	// It's also meant to group all low-bandwidth internal devices onto a single address. PCIe bus allows a
-	// total of 256 devices, but this assumes 32 chassis * 8 function. By using VFs for the internal fixed
-	// devices we avoid consuming a chassis for each one. See also the qemuPCIDeviceIDStart constant.
-	devBus, devAddr, multi := bus.allocate(busFunctionGroupGeneric)
-	err = qemuBalloon.Execute(sb, map[string]interface{}{
-		"bus":           bus.name,
-		"devBus":        devBus,
-		"devAddr":       devAddr,
-		"multifunction": multi,
-	})
-	if err != nil {
-		return "", nil, err
-	}
+			if !devExists {
+				break
 
-	devBus, devAddr, multi = bus.allocate(busFunctionGroupGeneric)
-	err = qemuRNG.Execute(sb, map[string]interface{}{
-		"bus":           bus.name,
-		"devBus":        devBus,
-		"devAddr":       devAddr,
-		"multifunction": multi,
-	})
-	if err != nil {
-		return "", nil, err
-	}
+			}
 
-	devBus, devAddr, multi = bus.allocate(busFunctionGroupGeneric)
-	err = qemuKeyboard.Execute(sb, map[string]interface{}{
-		"bus":           bus.name,
-		"devBus":        devBus,
-		"devAddr":       devAddr,
-		"multifunction": multi,
-	})
-	if err != nil {
-		return "", nil, err
-	}
+			if time.Now().After(waitUntil) {
+				return errors.Wrapf(err, "Failed to detach NIC after %v", waitDuration)
+			}
 
-	devBus, devAddr, multi = bus.allocate(busFunctionGroupGeneric)
-	err = qemuTablet.Execute(sb, map[string]interface{}{
-		"bus":           bus.name,
-		"devBus":        devBus,
-		"devAddr":       devAddr,
-		"multifunction": multi,
-	})
-	if err != nil {
-		return "", nil, err
+			d.logger.Debug("Waiting for NIC device to be detached", log.Ctx{"device": deviceName})
+			time.Sleep(time.Second * time.Duration(2))
+		}
 	}
 
-	devBus, devAddr, multi = bus.allocate(busFunctionGroupGeneric)
-	err = qemuVsock.Execute(sb, map[string]interface{}{
-		"bus":           bus.name,
-		"devBus":        devBus,
-		"devAddr":       devAddr,
-		"multifunction": multi,
+	return nil
+}
 
-		"vsockID": d.vsockID(),
-	})
+// deviceDetachDisk detaches a disk device from a running instance.
+func (d *qemu) deviceDetachDisk(deviceName string, rawConfig deviceConfig.Device) error {
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
 	if err != nil {
-		return "", nil, err
+		return err
 	}
 
-	devBus, devAddr, multi = bus.allocate(busFunctionGroupGeneric)
-	err = qemuSerial.Execute(sb, map[string]interface{}{
-		"bus":           bus.name,
-		"devBus":        devBus,
-		"devAddr":       devAddr,
-		"multifunction": multi,
-
-		"chardevName":      qemuSerialChardevName,
-		"ringbufSizeBytes": qmp.RingbufSize,
-	})
-	if err != nil {
-		return "", nil, err
+	nodeName := fmt.Sprintf("%s%s", qemuBlockDevIDPrefix, deviceName)
+	mountTag := fmt.Sprintf("lxd_%s", deviceName)
+	driveDeviceID := fmt.Sprintf("%s%s", qemuDeviceIDPrefix, deviceName)
+	scsiControllerID := fmt.Sprintf("qemu_scsi_%s", deviceName)
+	shareDeviceIDs := []string{
+		fmt.Sprintf("%s%s-virtio-fs", qemuDeviceIDPrefix, deviceName),
+		fmt.Sprintf("%s%s-9p", qemuDeviceIDPrefix, deviceName),
 	}
 
-	// s390x doesn't really have USB.
-	if d.architecture != osarch.ARCH_64BIT_S390_BIG_ENDIAN {
-		devBus, devAddr, multi = bus.allocate(busFunctionGroupGeneric)
-		err = qemuUSB.Execute(sb, map[string]interface{}{
-			"bus":           bus.name,
-			"devBus":        devBus,
-			"devAddr":       devAddr,
-			"multifunction": multi,
-		})
+	// Find which QEMU devices are in use by the disk. Drives with a dedicated I/O thread may have their own
+	// SCSI controller, which is removed after the drive.
+	deviceIDs := []string{}
+	for _, deviceID := range append([]string{driveDeviceID, scsiControllerID}, shareDeviceIDs...) {
+		exists, err := monitor.DeviceExists(deviceID)
 		if err != nil {
-			return "", nil, err
+			return err
 		}
-	}
 
-	devBus, devAddr, multi = bus.allocate(busFunctionGroupNone)
-	err = qemuSCSI.Execute(sb, map[string]interface{}{
-		"bus":           bus.name,
-		"devBus":        devBus,
-		"devAddr":       devAddr,
-		"multifunction": multi,
-	})
-	if err != nil {
-		return "", nil, err
+		if exists {
+			deviceIDs = append(deviceIDs, deviceID)
+		}
 	}
 
-	// Always export the config directory as a 9p config drive, in case the host or VM guest doesn't support
-	// virtio-fs.
-	devBus, devAddr, multi = bus.allocate(busFunctionGroup9p)
-	err = qemuDriveConfig.Execute(sb, map[string]interface{}{
-		"bus":           bus.name,
-		"devBus":        devBus,
-		"devAddr":       devAddr,
-		"multifunction": multi,
-		"protocol":      "9p",
-
-		"path": d.configDriveMountPath(),
-	})
+	// Shares added at boot time may be sharing a PCI slot with other shares. As the whole slot is removed
+	// when unplugging a device from it, this would take the other shares with it.
+	pciDevs, err := monitor.QueryPCI()
 	if err != nil {
-		return "", nil, err
+		return err
 	}
 
-	// If virtiofsd is running for the config directory then export the config drive via virtio-fs.
-	// This is used by the lxd-agent in preference to 9p (due to its improved performance) and in scenarios
-	// where 9p isn't available in the VM guest OS.
-	configSockPath, _ := d.configVirtiofsdPaths()
-	if shared.PathExists(configSockPath) {
-		devBus, devAddr, multi = bus.allocate(busFunctionGroup9p)
-		err = qemuDriveConfig.Execute(sb, map[string]interface{}{
-			"bus":           bus.name,
-			"devBus":        devBus,
-			"devAddr":       devAddr,
-			"multifunction": multi,
-			"protocol":      "virtio-fs",
-
-			"path": configSockPath,
-		})
-		if err != nil {
-			return "", nil, err
+	slots := map[string][]string{}
+	for _, pciDev := range pciDevs {
+		// PCIe devices are behind a port, PCI devices share a slot on the root bus.
+		if len(pciDev.Bridge.Devices) > 0 {
+			for _, bridgeDev := range pciDev.Bridge.Devices {
+				slots[pciDev.DevID] = append(slots[pciDev.DevID], bridgeDev.DevID)
+			}
+		} else {
+			slot := fmt.Sprintf("%d", pciDev.Slot)
+			slots[slot] = append(slots[slot], pciDev.DevID)
 		}
 	}
 
-	devBus, devAddr, multi = bus.allocate(busFunctionGroupNone)
-	err = qemuGPU.Execute(sb, map[string]interface{}{
-		"bus":           bus.name,
-		"devBus":        devBus,
-		"devAddr":       devAddr,
-		"multifunction": multi,
-
-		"architecture": d.architectureName,
-	})
-	if err != nil {
-		return "", nil, err
-	}
+	for _, slotDevIDs := range slots {
+		ours := 0
+		for _, slotDevID := range slotDevIDs {
+			if shared.StringInSlice(slotDevID, deviceIDs) {
+				ours++
+			}
+		}
 
-	// Dynamic devices.
-	bootIndexes, err := d.deviceBootPriorities()
-	if err != nil {
-		return "", nil, errors.Wrap(err, "Error calculating boot indexes")
+		if ours > 0 && ours < len(slotDevIDs) {
+			return fmt.Errorf("Device shares its PCI slot with other devices and cannot be removed while the instance is running")
+		}
 	}
 
-	// Record the mounts we are going to do inside the VM using the agent.
-	agentMounts := []instancetype.VMAgentMount{}
-
-	// These devices are sorted so that NICs are added first to ensure that the first NIC can use the 5th
-	// PCIe bus port and will be consistently named enp5s0 for compatibility with network configuration in our
-	// existing VM images. Even on non-PCIe busses having NICs first means that their names won't change when
-	// other devices are added.
-	for _, runConf := range devConfs {
-		// Add drive devices.
-		if len(runConf.Mounts) > 0 {
-			for _, drive := range runConf.Mounts {
-				if drive.TargetPath == "/" {
-					err = d.addRootDriveConfig(sb, mountInfo, bootIndexes, drive)
-				} else if drive.FSType == "9p" {
-					err = d.addDriveDirConfig(sb, bus, fdFiles, &agentMounts, drive)
-				} else {
-					err = d.addDriveConfig(sb, fdFiles, bootIndexes, drive)
-				}
-				if err != nil {
-					return "", nil, err
-				}
-			}
+	// Request the agent unmounts shares before they go away.
+	for _, deviceID := range shareDeviceIDs {
+		if !shared.StringInSlice(deviceID, deviceIDs) {
+			continue
 		}
 
-		// Add network device.
-		if len(runConf.NetworkInterface) > 0 {
-			qemuDev := make(map[string]string)
-			if shared.StringInSlice(bus.name, []string{"pcie", "pci"}) {
-				// Allocate a PCI(e) port and write it to the config file so QMP can "hotplug" the
-				// NIC into it later.
-				devBus, devAddr, multi := bus.allocate(busFunctionGroupNone)
-
-				// Populate the qemu device with port info.
-				qemuDev["bus"] = devBus
-				qemuDev["addr"] = devAddr
+		msg := map[string]interface{}{
+			"action": "removed",
+			"name":   deviceName,
+			"config": rawConfig,
+			"mount": instancetype.VMAgentMount{
+				Source: mountTag,
+				Target: rawConfig["path"],
+			},
+		}
 
-				if multi {
-					qemuDev["multifunction"] = "on"
-				}
-			}
+		err = d.devlxdEventSend("device", msg)
+		if err != nil {
+			d.logger.Warn("Failed requesting the agent unmounts the share", log.Ctx{"device": deviceName, "err": err})
+		}
 
-			monHook, err := d.addNetDevConfig(cpuCount, bus.name, qemuDev, bootIndexes, runConf.NetworkInterface)
-			if err != nil {
-				return "", nil, err
-			}
+		break
+	}
 
-			monHooks = append(monHooks, monHook)
+	// Request removal of the devices.
+	for _, deviceID := range deviceIDs {
+		err = monitor.RemoveDevice(deviceID)
+		if err != nil {
+			return err
 		}
+	}
 
-		// Add GPU device.
-		if len(runConf.GPUDevice) > 0 {
-			err = d.addGPUDevConfig(sb, bus, runConf.GPUDevice)
+	// Wait until the devices are actually removed (or we timeout waiting), as PCI devices need to be
+	// released by the guest first.
+	waitDuration := time.Duration(time.Second * time.Duration(10))
+	waitUntil := time.Now().Add(waitDuration)
+	for _, deviceID := range deviceIDs {
+		for {
+			devExists, err := monitor.DeviceExists(deviceID)
 			if err != nil {
-				return "", nil, err
+				return errors.Wrapf(err, "Failed getting devices to check for disk detach")
 			}
-		}
 
-		// Add PCI device.
-		if len(runConf.PCIDevice) > 0 {
-			err = d.addPCIDevConfig(sb, bus, runConf.PCIDevice)
-			if err != nil {
-				return "", nil, err
+			if !devExists {
+				break
 			}
-		}
 
-		// Add USB devices.
-		for _, usbDev := range runConf.USBDevice {
-			err = d.addUSBDeviceConfig(sb, bus, usbDev)
-			if err != nil {
-				return "", nil, err
+			if time.Now().After(waitUntil) {
+				return fmt.Errorf("Failed to detach disk after %v", waitDuration)
 			}
-		}
 
-		// Add TPM device.
-		if len(runConf.TPMDevice) > 0 {
-			err = d.addTPMDeviceConfig(sb, runConf.TPMDevice)
-			if err != nil {
-				return "", nil, err
-			}
+			d.logger.Debug("Waiting for disk device to be detached", log.Ctx{"device": deviceName})
+			time.Sleep(time.Second * time.Duration(2))
 		}
+	}
 
+	// Remove the backends. Drives added at boot time are removed along with their device.
+	err = monitor.RemoveBlockDevice(nodeName)
+	if err != nil {
+		return err
 	}
 
-	// Allocate 4 PCI slots for hotplug devices.
-	for i := 0; i < 4; i++ {
-		bus.allocate(busFunctionGroupNone)
+	if shared.IsTrue(rawConfig["io.threads"]) {
+		err = monitor.RemoveObject(fmt.Sprintf("iothread_%s", deviceName))
+		if err != nil {
+			return err
+		}
 	}
 
-	// Write the agent mount config.
-	agentMountJSON, err := json.Marshal(agentMounts)
+	err = monitor.RemoveFDFromFDSet(nodeName)
 	if err != nil {
-		return "", nil, errors.Wrapf(err, "Failed marshalling agent mounts to JSON")
+		return err
 	}
 
-	agentMountFile := filepath.Join(d.Path(), "config", "agent-mounts.json")
-	err = ioutil.WriteFile(agentMountFile, agentMountJSON, 0400)
+	err = monitor.RemoveCharDevice(mountTag)
 	if err != nil {
-		return "", nil, errors.Wrapf(err, "Failed writing agent mounts file")
+		return err
 	}
 
-	// Write the config file to disk.
-	configPath := filepath.Join(d.LogPath(), "qemu.conf")
-	return configPath, monHooks, ioutil.WriteFile(configPath, []byte(sb.String()), 0640)
+	return nil
 }
 
-// addCPUMemoryConfig adds the qemu config required for setting the number of virtualised CPUs and memory.
-// If sb is nil then no config is written and instead just the CPU count is returned.
-func (d *qemu) addCPUMemoryConfig(sb *strings.Builder) (int, error) {
-	instanceTypes, _ := SupportedInstanceTypes()
-	driverInfo := instanceTypes[instancetype.VM]
-	if driverInfo.Name == "" {
-		return -1, fmt.Errorf("Unable to ascertain QEMU version")
+// deviceDetachUSB detaches a host USB device from a running instance.
+func (d *qemu) deviceDetachUSB(usbDev deviceConfig.USBDeviceItem) error {
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
+	if err != nil {
+		return err
 	}
 
-	// Figure out what memory object layout we're going to use.
-	// Before v6.0 or if version unknown, we use the "repeated" format, otherwise we use "indexed" format.
-	qemuMemObjectFormat := "repeated"
-	qemuVer6, _ := version.NewDottedVersion("6.0")
-	qemuVer, _ := version.NewDottedVersion(driverInfo.Version)
-	if qemuVer != nil && qemuVer.Compare(qemuVer6) >= 0 {
-		qemuMemObjectFormat = "indexed"
+	deviceID := fmt.Sprintf("%s%s", qemuDeviceIDPrefix, usbDev.DeviceName)
+
+	err = monitor.RemoveDevice(deviceID)
+	if err != nil {
+		return err
 	}
 
-	// Default to a single core.
-	cpus := d.expandedConfig["limits.cpu"]
-	if cpus == "" {
-		cpus = "1"
+	// USB devices attached at boot time don't use a file descriptor set.
+	err = monitor.RemoveFDFromFDSet(deviceID)
+	if err != nil {
+		return err
 	}
 
-	ctx := map[string]interface{}{
-		"architecture":        d.architectureName,
-		"qemuMemObjectFormat": qemuMemObjectFormat,
+	return nil
+}
+
+// deviceDetachPCI detaches a PCI device from a running instance.
+func (d *qemu) deviceDetachPCI(deviceName string) error {
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
+	if err != nil {
+		return err
 	}
 
-	cpuCount, err := strconv.Atoi(cpus)
-	hostNodes := []uint64{}
-	if err == nil {
-		// If not pinning, default to exposing cores.
-		ctx["cpuCount"] = cpuCount
-		ctx["cpuSockets"] = 1
-		ctx["cpuCores"] = cpuCount
-		ctx["cpuThreads"] = 1
-		hostNodes = []uint64{0}
-	} else {
-		// Expand to a set of CPU identifiers and get the pinning map.
-		nrSockets, nrCores, nrThreads, vcpus, numaNodes, err := d.cpuTopology(cpus)
+	deviceID := fmt.Sprintf("%s%s", qemuDeviceIDPrefix, deviceName)
+
+	err = monitor.RemoveDevice(deviceID)
+	if err != nil {
+		return err
+	}
+
+	// Wait until the device is actually removed (or we timeout waiting), as the guest needs to release it
+	// before it can be bound back to its host driver.
+	waitDuration := time.Duration(time.Second * time.Duration(10))
+	waitUntil := time.Now().Add(waitDuration)
+	for {
+		devExists, err := monitor.DeviceExists(deviceID)
 		if err != nil {
-			return -1, err
+			return errors.Wrapf(err, "Failed getting devices to check for PCI device detach")
 		}
 
-		// Figure out socket-id/core-id/thread-id for all vcpus.
-		vcpuSocket := map[uint64]uint64{}
-		vcpuCore := map[uint64]uint64{}
-		vcpuThread := map[uint64]uint64{}
-		vcpu := uint64(0)
-		for i := 0; i < nrSockets; i++ {
-			for j := 0; j < nrCores; j++ {
-				for k := 0; k < nrThreads; k++ {
-					vcpuSocket[vcpu] = uint64(i)
-					vcpuCore[vcpu] = uint64(j)
-					vcpuThread[vcpu] = uint64(k)
-					vcpu++
-				}
-			}
+		if !devExists {
+			break
 		}
 
-		// Prepare the NUMA map.
-		numa := []map[string]uint64{}
-		numaIDs := []uint64{}
-		numaNode := uint64(0)
-		for hostNode, entry := range numaNodes {
-			hostNodes = append(hostNodes, hostNode)
-
-			numaIDs = append(numaIDs, numaNode)
-			for _, vcpu := range entry {
-				numa = append(numa, map[string]uint64{
-					"node":   numaNode,
-					"socket": vcpuSocket[vcpu],
-					"core":   vcpuCore[vcpu],
-					"thread": vcpuThread[vcpu],
-				})
-			}
-
-			numaNode++
+		if time.Now().After(waitUntil) {
+			return fmt.Errorf("Failed to detach PCI device after %v", waitDuration)
 		}
 
-		// Prepare context.
-		ctx["cpuCount"] = len(vcpus)
-		ctx["cpuSockets"] = nrSockets
-		ctx["cpuCores"] = nrCores
-		ctx["cpuThreads"] = nrThreads
-		ctx["cpuNumaNodes"] = numaIDs
-		ctx["cpuNumaMapping"] = numa
-		ctx["cpuNumaHostNodes"] = hostNodes
+		d.logger.Debug("Waiting for PCI device to be detached", log.Ctx{"device": deviceName})
+		time.Sleep(time.Second * time.Duration(2))
 	}
 
-	// Configure memory limit.
-	memSize := d.expandedConfig["limits.memory"]
-	if memSize == "" {
-		memSize = qemuDefaultMemSize // Default if no memory limit specified.
-	}
+	return nil
+}
 
-	memSizeBytes, err := units.ParseByteSizeString(memSize)
+func (d *qemu) monitorPath() string {
+	return filepath.Join(d.LogPath(), "qemu.monitor")
+}
+
+func (d *qemu) nvramPath() string {
+	return filepath.Join(d.Path(), "qemu.nvram")
+}
+
+func (d *qemu) spicePath() string {
+	return filepath.Join(d.LogPath(), "qemu.spice")
+}
+
+func (d *qemu) spiceCmdlineConfig() string {
+	return fmt.Sprintf("unix=on,disable-ticketing=on,addr=%s", d.spicePath())
+}
+
+// generateConfigShare generates the config share directory that will be exported to the VM via
+// a 9P share. Due to the unknown size of templates inside the images this directory is created
+// inside the VM's config volume so that it can be restricted by quota.
+// Requires the instance be mounted before calling this function.
+func (d *qemu) generateConfigShare() error {
+	configDrivePath := filepath.Join(d.Path(), "config")
+
+	// Create config drive dir if doesn't exist, if it does exist, leave it around so we don't regenerate all
+	// files causing unnecessary config drive snapshot usage.
+	err := os.MkdirAll(configDrivePath, 0500)
 	if err != nil {
-		return -1, fmt.Errorf("limits.memory invalid: %v", err)
+		return err
 	}
 
-	ctx["hugepages"] = ""
-	if shared.IsTrue(d.expandedConfig["limits.memory.hugepages"]) {
-		hugetlb, err := util.HugepagesPath()
+	// Add the VM agent.
+	lxdAgentSrcPath, err := exec.LookPath("lxd-agent")
+	if err != nil {
+		d.logger.Warn("lxd-agent not found, skipping its inclusion in the VM config drive", log.Ctx{"err": err})
+	} else {
+		// Install agent into config drive dir if found.
+		lxdAgentSrcPath, err = filepath.EvalSymlinks(lxdAgentSrcPath)
 		if err != nil {
-			return -1, err
+			return err
 		}
 
-		ctx["hugepages"] = hugetlb
-	}
+		lxdAgentSrcInfo, err := os.Stat(lxdAgentSrcPath)
+		if err != nil {
+			return errors.Wrapf(err, "Failed getting info for lxd-agent source %q", lxdAgentSrcPath)
+		}
 
-	// Determine per-node memory limit.
-	memSizeBytes = memSizeBytes / 1024 / 1024
-	nodeMemory := int64(memSizeBytes / int64(len(hostNodes)))
-	memSizeBytes = nodeMemory * int64(len(hostNodes))
-	ctx["memory"] = nodeMemory
+		lxdAgentInstallPath := filepath.Join(configDrivePath, "lxd-agent")
+		lxdAgentNeedsInstall := true
 
-	if sb != nil {
-		err = qemuMemory.Execute(sb, map[string]interface{}{
-			"architecture": d.architectureName,
-			"memSizeBytes": memSizeBytes,
-		})
+		if shared.PathExists(lxdAgentInstallPath) {
+			lxdAgentInstallInfo, err := os.Stat(lxdAgentInstallPath)
+			if err != nil {
+				return errors.Wrapf(err, "Failed getting info for existing lxd-agent install %q", lxdAgentInstallPath)
+			}
 
-		if err != nil {
-			return -1, err
+			if lxdAgentInstallInfo.ModTime() == lxdAgentSrcInfo.ModTime() && lxdAgentInstallInfo.Size() == lxdAgentSrcInfo.Size() {
+				lxdAgentNeedsInstall = false
+			}
 		}
 
-		err = qemuCPU.Execute(sb, ctx)
-		if err != nil {
-			return -1, err
-		}
-	}
+		// Only install the lxd-agent into config drive if the existing one is different to the source one.
+		// Otherwise we would end up copying it again and this can cause unnecessary snapshot usage.
+		if lxdAgentNeedsInstall {
+			d.logger.Debug("Installing lxd-agent", log.Ctx{"srcPath": lxdAgentSrcPath, "installPath": lxdAgentInstallPath})
+			err = shared.FileCopy(lxdAgentSrcPath, lxdAgentInstallPath)
+			if err != nil {
+				return err
+			}
 
-	// Configure the CPU limit.
-	return ctx["cpuCount"].(int), nil
-}
+			err = os.Chmod(lxdAgentInstallPath, 0500)
+			if err != nil {
+				return err
+			}
 
-// addFileDescriptor adds a file path to the list of files to open and pass file descriptor to qemu.
-// Returns the file descriptor number that qemu will receive.
-func (d *qemu) addFileDescriptor(fdFiles *[]*os.File, file *os.File) int {
-	// Append the tap device file path to the list of files to be opened and passed to qemu.
-	*fdFiles = append(*fdFiles, file)
-	return 2 + len(*fdFiles) // Use 2+fdFiles count, as first user file descriptor is 3.
-}
+			err = os.Chown(lxdAgentInstallPath, 0, 0)
+			if err != nil {
+				return err
+			}
 
-// addRootDriveConfig adds the qemu config required for adding the root drive.
-func (d *qemu) addRootDriveConfig(sb *strings.Builder, mountInfo *storagePools.MountInfo, bootIndexes map[string]int, rootDriveConf deviceConfig.MountEntryItem) error {
-	if rootDriveConf.TargetPath != "/" {
-		return fmt.Errorf("Non-root drive config supplied")
+			// Ensure we copy the source file's timestamps so they can be used for comparison later.
+			err = os.Chtimes(lxdAgentInstallPath, lxdAgentSrcInfo.ModTime(), lxdAgentSrcInfo.ModTime())
+			if err != nil {
+				return errors.Wrapf(err, "Failed setting lxd-agent timestamps")
+			}
+		} else {
+			d.logger.Debug("Skipping lxd-agent install as unchanged", log.Ctx{"srcPath": lxdAgentSrcPath, "installPath": lxdAgentInstallPath})
+		}
 	}
 
-	pool, err := d.getStoragePool()
+	agentCert, agentKey, clientCert, _, err := d.generateAgentCert()
 	if err != nil {
 		return err
 	}
 
-	if mountInfo.DiskPath == "" {
-		return fmt.Errorf("No disk path available from mount")
+	err = ioutil.WriteFile(filepath.Join(configDrivePath, "server.crt"), []byte(clientCert), 0400)
+	if err != nil {
+		return err
 	}
 
-	// Generate a new device config with the root device path expanded.
-	driveConf := deviceConfig.MountEntryItem{
-		DevName: rootDriveConf.DevName,
-		DevPath: mountInfo.DiskPath,
+	err = ioutil.WriteFile(filepath.Join(configDrivePath, "agent.crt"), []byte(agentCert), 0400)
+	if err != nil {
+		return err
 	}
 
-	// If the storage pool is on ZFS and backed by a loop file and we can't use DirectIO, then resort to
-	// unsafe async I/O to avoid kernel lock up when running ZFS storage pools in an image file on another FS.
-	driverInfo := pool.Driver().Info()
-	driverConf := pool.Driver().Config()
-	if driverInfo.Name == "zfs" && !driverInfo.DirectIO && shared.PathExists(driverConf["source"]) && !shared.IsBlockdevPath(driverConf["source"]) {
-		driveConf.Opts = append(driveConf.Opts, qemuUnsafeIO)
+	// Certificate of the LXD server for the agent to relay /dev/lxd requests over vsock.
+	err = ioutil.WriteFile(filepath.Join(configDrivePath, "host.crt"), d.state.ServerCert().PublicKey(), 0400)
+	if err != nil {
+		return err
 	}
 
-	return d.addDriveConfig(sb, nil, bootIndexes, driveConf)
-}
-
-// addDriveDirConfig adds the qemu config required for adding a supplementary drive directory share.
-func (d *qemu) addDriveDirConfig(sb *strings.Builder, bus *qemuBus, fdFiles *[]*os.File, agentMounts *[]instancetype.VMAgentMount, driveConf deviceConfig.MountEntryItem) error {
-	mountTag := fmt.Sprintf("lxd_%s", driveConf.DevName)
+	err = ioutil.WriteFile(filepath.Join(configDrivePath, "agent.key"), []byte(agentKey), 0400)
+	if err != nil {
+		return err
+	}
 
-	agentMount := instancetype.VMAgentMount{
-		Source: mountTag,
-		Target: driveConf.TargetPath,
-		FSType: driveConf.FSType,
+	// Systemd units.
+	err = os.MkdirAll(filepath.Join(configDrivePath, "systemd"), 0500)
+	if err != nil {
+		return err
 	}
 
-	// If mount type is 9p, we need to specify to use the virtio transport to support more VM guest OSes.
+	lxdAgentServiceUnit := `[Unit]
+Description=LXD - agent
+Documentation=https://linuxcontainers.org/lxd
+ConditionPathExists=/dev/virtio-ports/org.linuxcontainers.lxd
+Before=cloud-init.target cloud-init.service cloud-init-local.service
+DefaultDependencies=no
+
+[Service]
+Type=notify
+WorkingDirectory=-/run/lxd_agent
+ExecStartPre=/lib/systemd/lxd-agent-setup
+ExecStart=/run/lxd_agent/lxd-agent
+Restart=on-failure
+RestartSec=5s
+StartLimitInterval=60
+StartLimitBurst=10
+
+[Install]
+WantedBy=multi-user.target
+`
+
+	err = ioutil.WriteFile(filepath.Join(configDrivePath, "systemd", "lxd-agent.service"), []byte(lxdAgentServiceUnit), 0400)
+	if err != nil {
+		return err
+	}
+
+	lxdAgentSetupScript := `#!/bin/sh
+set -eu
+PREFIX="/run/lxd_agent"
+
+# Functions.
+mount_virtiofs() {
+    mount -t virtiofs config "${PREFIX}/.mnt" >/dev/null 2>&1
+}
+
+mount_9p() {
+    /sbin/modprobe 9pnet_virtio >/dev/null 2>&1 || true
+    /bin/mount -t 9p config "${PREFIX}/.mnt" -o access=0,trans=virtio >/dev/null 2>&1
+}
+
+fail() {
+    umount -l "${PREFIX}" >/dev/null 2>&1 || true
+    rmdir "${PREFIX}" >/dev/null 2>&1 || true
+    echo "${1}"
+    exit 1
+}
+
+# Setup the mount target.
+umount -l "${PREFIX}" >/dev/null 2>&1 || true
+mkdir -p "${PREFIX}"
+mount -t tmpfs tmpfs "${PREFIX}" -o mode=0700,size=50M
+mkdir -p "${PREFIX}/.mnt"
+
+# Try virtiofs first.
+mount_virtiofs || mount_9p || fail "Couldn't mount virtiofs or 9p, failing."
+
+# Copy the data.
+cp -Ra "${PREFIX}/.mnt/"* "${PREFIX}"
+
+# Unmount the temporary mount.
+umount "${PREFIX}/.mnt"
+rmdir "${PREFIX}/.mnt"
+
+# Fix up permissions.
+chown -R root:root "${PREFIX}"
+`
+
+	err = ioutil.WriteFile(filepath.Join(configDrivePath, "systemd", "lxd-agent-setup"), []byte(lxdAgentSetupScript), 0500)
+	if err != nil {
+		return err
+	}
+
+	// Udev rules
+	err = os.MkdirAll(filepath.Join(configDrivePath, "udev"), 0500)
+	if err != nil {
+		return err
+	}
+
+	lxdAgentRules := `ACTION=="add", SYMLINK=="virtio-ports/org.linuxcontainers.lxd", TAG+="systemd", ACTION=="add", RUN+="/bin/systemctl start lxd-agent.service"`
+	err = ioutil.WriteFile(filepath.Join(configDrivePath, "udev", "99-lxd-agent.rules"), []byte(lxdAgentRules), 0400)
+	if err != nil {
+		return err
+	}
+
+	// Install script for manual installs.
+	lxdConfigShareInstall := `#!/bin/sh
+if [ ! -e "systemd" ] || [ ! -e "lxd-agent" ]; then
+    echo "This script must be run from within the 9p mount"
+    exit 1
+fi
+
+if [ ! -e "/lib/systemd/system" ]; then
+    echo "This script only works on systemd systems"
+    exit 1
+fi
+
+# Cleanup former units.
+rm -f /lib/systemd/system/lxd-agent-9p.service \
+    /lib/systemd/system/lxd-agent-virtiofs.service \
+    /etc/systemd/system/multi-user.target.wants/lxd-agent-9p.service \
+    /etc/systemd/system/multi-user.target.wants/lxd-agent-virtiofs.service
+
+# Install the units.
+cp udev/99-lxd-agent.rules /lib/udev/rules.d/
+cp systemd/lxd-agent.service /lib/systemd/system/
+cp systemd/lxd-agent-setup /lib/systemd/
+systemctl daemon-reload
+systemctl enable lxd-agent.service
+
+echo ""
+echo "LXD agent has been installed, reboot to confirm setup."
+echo "To start it now, unmount this filesystem and run: systemctl start lxd-agent"
+`
+
+	err = ioutil.WriteFile(filepath.Join(configDrivePath, "install.sh"), []byte(lxdConfigShareInstall), 0700)
+	if err != nil {
+		return err
+	}
+
+	// Instance data for devlxd.
+	err = d.writeInstanceData()
+	if err != nil {
+		return err
+	}
+
+	// Templated files.
+	templateFilesPath := filepath.Join(configDrivePath, "files")
+
+	// Clear path and recreate.
+	os.RemoveAll(templateFilesPath)
+	err = os.MkdirAll(templateFilesPath, 0500)
+	if err != nil {
+		return err
+	}
+
+	// Template anything that needs templating.
+	key := "volatile.apply_template"
+	if d.localConfig[key] != "" {
+		// Run any template that needs running.
+		err = d.templateApplyNow(instance.TemplateTrigger(d.localConfig[key]), templateFilesPath)
+		if err != nil {
+			return err
+		}
+
+		// Remove the volatile key from the DB.
+		err := d.state.Cluster.DeleteInstanceConfigKey(d.id, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = d.templateApplyNow("start", templateFilesPath)
+	if err != nil {
+		return err
+	}
+
+	// Copy the template metadata itself too.
+	metaPath := filepath.Join(d.Path(), "metadata.yaml")
+	if shared.PathExists(metaPath) {
+		err = shared.FileCopy(metaPath, filepath.Join(templateFilesPath, "metadata.yaml"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *qemu) templateApplyNow(trigger instance.TemplateTrigger, path string) error {
+	// If there's no metadata, just return.
+	fname := filepath.Join(d.Path(), "metadata.yaml")
+	if !shared.PathExists(fname) {
+		return nil
+	}
+
+	// Parse the metadata.
+	content, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return errors.Wrap(err, "Failed to read metadata")
+	}
+
+	metadata := new(api.ImageMetadata)
+	err = yaml.Unmarshal(content, &metadata)
+	if err != nil {
+		return errors.Wrapf(err, "Could not parse %s", fname)
+	}
+
+	// Figure out the instance architecture.
+	arch, err := osarch.ArchitectureName(d.architecture)
+	if err != nil {
+		arch, err = osarch.ArchitectureName(d.state.OS.Architectures[0])
+		if err != nil {
+			return errors.Wrap(err, "Failed to detect system architecture")
+		}
+	}
+
+	// Generate the container metadata.
+	instanceMeta := make(map[string]string)
+	instanceMeta["name"] = d.name
+	instanceMeta["type"] = "virtual-machine"
+	instanceMeta["architecture"] = arch
+
+	if d.ephemeral {
+		instanceMeta["ephemeral"] = "true"
+	} else {
+		instanceMeta["ephemeral"] = "false"
+	}
+
+	// Go through the templates.
+	for tplPath, tpl := range metadata.Templates {
+		err = func(tplPath string, tpl *api.ImageMetadataTemplate) error {
+			var w *os.File
+
+			// Check if the template should be applied now.
+			found := false
+			for _, tplTrigger := range tpl.When {
+				if tplTrigger == string(trigger) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return nil
+			}
+
+			// Create the file itself.
+			w, err = os.Create(filepath.Join(path, fmt.Sprintf("%s.out", tpl.Template)))
+			if err != nil {
+				return err
+			}
+
+			// Fix ownership and mode.
+			w.Chmod(0644)
+			defer w.Close()
+
+			// Read the template.
+			tplString, err := ioutil.ReadFile(filepath.Join(d.TemplatesPath(), tpl.Template))
+			if err != nil {
+				return errors.Wrap(err, "Failed to read template file")
+			}
+
+			// Restrict filesystem access to within the container's rootfs.
+			tplSet := pongo2.NewSet(fmt.Sprintf("%s-%s", d.name, tpl.Template), pongoTemplate.ChrootLoader{Path: d.TemplatesPath()})
+			tplRender, err := tplSet.FromString("{% autoescape off %}" + string(tplString) + "{% endautoescape %}")
+			if err != nil {
+				return errors.Wrap(err, "Failed to render template")
+			}
+
+			configGet := func(confKey, confDefault *pongo2.Value) *pongo2.Value {
+				val, ok := d.expandedConfig[confKey.String()]
+				if !ok {
+					return confDefault
+				}
+
+				return pongo2.AsValue(strings.TrimRight(val, "\r\n"))
+			}
+
+			// Render the template.
+			tplRender.ExecuteWriter(pongo2.Context{"trigger": trigger,
+				"path":       tplPath,
+				"instance":   instanceMeta,
+				"container":  instanceMeta, // FIXME: remove once most images have moved away.
+				"config":     d.expandedConfig,
+				"devices":    d.expandedDevices,
+				"properties": tpl.Properties,
+				"config_get": configGet}, w)
+
+			return nil
+		}(tplPath, tpl)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deviceBootPriorities returns a map keyed on device name containing the boot index to use.
+// Qemu tries to boot devices in order of boot index (lowest first).
+func (d *qemu) deviceBootPriorities() (map[string]int, error) {
+	type devicePrios struct {
+		Name     string
+		BootPrio uint32
+	}
+
+	devices := []devicePrios{}
+
+	for _, dev := range d.expandedDevices.Sorted() {
+		if dev.Config["type"] != "disk" && dev.Config["type"] != "nic" {
+			continue
+		}
+
+		bootPrio := uint32(0) // Default to lowest priority.
+		if dev.Config["boot.priority"] != "" {
+			prio, err := strconv.ParseInt(dev.Config["boot.priority"], 10, 32)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Invalid boot.priority for device %q", dev.Name)
+			}
+			bootPrio = uint32(prio)
+		} else if dev.Config["path"] == "/" {
+			bootPrio = 1 // Set boot priority of root disk higher than any device without a boot prio.
+		}
+
+		devices = append(devices, devicePrios{Name: dev.Name, BootPrio: bootPrio})
+	}
+
+	// Sort devices by priority (use SliceStable so that devices with the same boot priority stay in the same
+	// order each boot based on the device order provided by the d.expandedDevices.Sorted() function).
+	// This is important because as well as providing a predicable boot index order, the boot index number can
+	// also be used for other properties (such as disk SCSI ID) which can result in it being given different
+	// device names inside the guest based on the device order.
+	sort.SliceStable(devices, func(i, j int) bool { return devices[i].BootPrio > devices[j].BootPrio })
+
+	sortedDevs := make(map[string]int, len(devices))
+	for bootIndex, dev := range devices {
+		sortedDevs[dev.Name] = bootIndex
+	}
+
+	return sortedDevs, nil
+}
+
+// generateQemuConfigFile writes the qemu config file and returns its location.
+// It writes the config file inside the VM's log path.
+func (d *qemu) generateQemuConfigFile(mountInfo *storagePools.MountInfo, busName string, devConfs []*deviceConfig.RunConfig, fdFiles *[]*os.File) (string, []monitorHook, error) {
+	var sb *strings.Builder = &strings.Builder{}
+	var monHooks []monitorHook
+
+	err := qemuBase.Execute(sb, map[string]interface{}{
+		"architecture": d.architectureName,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	cpuCount, err := d.addCPUMemoryConfig(sb)
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = qemuDriveFirmware.Execute(sb, map[string]interface{}{
+		"architecture": d.architectureName,
+		"roPath":       filepath.Join(d.ovmfPath(), "OVMF_CODE.fd"),
+		"nvramPath":    d.nvramPath(),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = qemuControlSocket.Execute(sb, map[string]interface{}{
+		"path": d.monitorPath(),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Setup the bus allocator.
+	bus := qemuNewBus(busName, sb)
+
+	// Now add the fixed set of devices. The multi-function groups used for these fixed internal devices are
+	// specifically chosen to ensure that we consume exactly 4 PCI bus ports (on PCIe bus). This ensures that
+	// the first user device NIC added will use the 5th PCI bus port and will be consistently named enp5s0
+	// on PCIe (which we need to maintain compatibility with network configuration in our existing VM images).
+	// It's also meant to group all low-bandwidth internal devices onto a single address. PCIe bus allows a
+	// total of 256 devices, but this assumes 32 chassis * 8 function. By using VFs for the internal fixed
+	// devices we avoid consuming a chassis for each one. See also the qemuPCIDeviceIDStart constant.
+	devBus, devAddr, multi := bus.allocate(busFunctionGroupGeneric)
+	err = qemuBalloon.Execute(sb, map[string]interface{}{
+		"bus":           bus.name,
+		"devBus":        devBus,
+		"devAddr":       devAddr,
+		"multifunction": multi,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	devBus, devAddr, multi = bus.allocate(busFunctionGroupGeneric)
+	err = qemuRNG.Execute(sb, map[string]interface{}{
+		"bus":           bus.name,
+		"devBus":        devBus,
+		"devAddr":       devAddr,
+		"multifunction": multi,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	devBus, devAddr, multi = bus.allocate(busFunctionGroupGeneric)
+	err = qemuKeyboard.Execute(sb, map[string]interface{}{
+		"bus":           bus.name,
+		"devBus":        devBus,
+		"devAddr":       devAddr,
+		"multifunction": multi,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	devBus, devAddr, multi = bus.allocate(busFunctionGroupGeneric)
+	err = qemuTablet.Execute(sb, map[string]interface{}{
+		"bus":           bus.name,
+		"devBus":        devBus,
+		"devAddr":       devAddr,
+		"multifunction": multi,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	devBus, devAddr, multi = bus.allocate(busFunctionGroupGeneric)
+	err = qemuVsock.Execute(sb, map[string]interface{}{
+		"bus":           bus.name,
+		"devBus":        devBus,
+		"devAddr":       devAddr,
+		"multifunction": multi,
+
+		"vsockID": d.vsockID(),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	devBus, devAddr, multi = bus.allocate(busFunctionGroupGeneric)
+	err = qemuSerial.Execute(sb, map[string]interface{}{
+		"bus":           bus.name,
+		"devBus":        devBus,
+		"devAddr":       devAddr,
+		"multifunction": multi,
+
+		"chardevName":      qemuSerialChardevName,
+		"ringbufSizeBytes": qmp.RingbufSize,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	// s390x doesn't really have USB.
+	if d.architecture != osarch.ARCH_64BIT_S390_BIG_ENDIAN {
+		devBus, devAddr, multi = bus.allocate(busFunctionGroupGeneric)
+		err = qemuUSB.Execute(sb, map[string]interface{}{
+			"bus":           bus.name,
+			"devBus":        devBus,
+			"devAddr":       devAddr,
+			"multifunction": multi,
+		})
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	devBus, devAddr, multi = bus.allocate(busFunctionGroupNone)
+	err = qemuSCSI.Execute(sb, map[string]interface{}{
+		"bus":           bus.name,
+		"devBus":        devBus,
+		"devAddr":       devAddr,
+		"multifunction": multi,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Always export the config directory as a 9p config drive, in case the host or VM guest doesn't support
+	// virtio-fs.
+	devBus, devAddr, multi = bus.allocate(busFunctionGroup9p)
+	err = qemuDriveConfig.Execute(sb, map[string]interface{}{
+		"bus":           bus.name,
+		"devBus":        devBus,
+		"devAddr":       devAddr,
+		"multifunction": multi,
+		"protocol":      "9p",
+
+		"path": d.configDriveMountPath(),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	// If virtiofsd is running for the config directory then export the config drive via virtio-fs.
+	// This is used by the lxd-agent in preference to 9p (due to its improved performance) and in scenarios
+	// where 9p isn't available in the VM guest OS.
+	configSockPath, _ := d.configVirtiofsdPaths()
+	if shared.PathExists(configSockPath) {
+		devBus, devAddr, multi = bus.allocate(busFunctionGroup9p)
+		err = qemuDriveConfig.Execute(sb, map[string]interface{}{
+			"bus":           bus.name,
+			"devBus":        devBus,
+			"devAddr":       devAddr,
+			"multifunction": multi,
+			"protocol":      "virtio-fs",
+
+			"path": configSockPath,
+		})
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	devBus, devAddr, multi = bus.allocate(busFunctionGroupNone)
+	err = qemuGPU.Execute(sb, map[string]interface{}{
+		"bus":           bus.name,
+		"devBus":        devBus,
+		"devAddr":       devAddr,
+		"multifunction": multi,
+
+		"architecture": d.architectureName,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Dynamic devices.
+	bootIndexes, err := d.deviceBootPriorities()
+	if err != nil {
+		return "", nil, errors.Wrap(err, "Error calculating boot indexes")
+	}
+
+	// Record the mounts we are going to do inside the VM using the agent.
+	agentMounts := []instancetype.VMAgentMount{}
+
+	// These devices are sorted so that NICs are added first to ensure that the first NIC can use the 5th
+	// PCIe bus port and will be consistently named enp5s0 for compatibility with network configuration in our
+	// existing VM images. Even on non-PCIe busses having NICs first means that their names won't change when
+	// other devices are added.
+	for _, runConf := range devConfs {
+		// Add drive devices.
+		if len(runConf.Mounts) > 0 {
+			for _, drive := range runConf.Mounts {
+				if drive.TargetPath == "/" {
+					err = d.addRootDriveConfig(sb, bus, mountInfo, bootIndexes, drive)
+				} else if drive.FSType == "9p" {
+					err = d.addDriveDirConfig(sb, bus, fdFiles, &agentMounts, drive)
+				} else {
+					err = d.addDriveConfig(sb, bus, fdFiles, bootIndexes, drive)
+				}
+				if err != nil {
+					return "", nil, err
+				}
+			}
+		}
+
+		// Add network device.
+		if len(runConf.NetworkInterface) > 0 {
+			qemuDev := make(map[string]string)
+			if shared.StringInSlice(bus.name, []string{"pcie", "pci"}) {
+				// Allocate a PCI(e) port and write it to the config file so QMP can "hotplug" the
+				// NIC into it later.
+				devBus, devAddr, multi := bus.allocate(busFunctionGroupNone)
+
+				// Populate the qemu device with port info.
+				qemuDev["bus"] = devBus
+				qemuDev["addr"] = devAddr
+
+				if multi {
+					qemuDev["multifunction"] = "on"
+				}
+			}
+
+			monHook, err := d.addNetDevConfig(cpuCount, bus.name, qemuDev, bootIndexes, runConf.NetworkInterface)
+			if err != nil {
+				return "", nil, err
+			}
+
+			monHooks = append(monHooks, monHook)
+		}
+
+		// Add GPU device.
+		if len(runConf.GPUDevice) > 0 {
+			err = d.addGPUDevConfig(sb, bus, runConf.GPUDevice)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+
+		// Add PCI device.
+		if len(runConf.PCIDevice) > 0 {
+			err = d.addPCIDevConfig(sb, bus, runConf.PCIDevice)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+
+		// Add USB devices.
+		for _, usbDev := range runConf.USBDevice {
+			err = d.addUSBDeviceConfig(sb, bus, usbDev)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+
+		// Add TPM device.
+		if len(runConf.TPMDevice) > 0 {
+			err = d.addTPMDeviceConfig(sb, runConf.TPMDevice)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+
+	}
+
+	// Allocate 4 PCI slots for hotplug devices.
+	for i := 0; i < 4; i++ {
+		bus.allocate(busFunctionGroupNone)
+	}
+
+	// Write the agent mount config.
+	agentMountJSON, err := json.Marshal(agentMounts)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "Failed marshalling agent mounts to JSON")
+	}
+
+	agentMountFile := filepath.Join(d.Path(), "config", "agent-mounts.json")
+	err = ioutil.WriteFile(agentMountFile, agentMountJSON, 0400)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "Failed writing agent mounts file")
+	}
+
+	// Write the config file to disk.
+	configPath := filepath.Join(d.LogPath(), "qemu.conf")
+	return configPath, monHooks, ioutil.WriteFile(configPath, []byte(sb.String()), 0640)
+}
+
+// addCPUMemoryConfig adds the qemu config required for setting the number of virtualised CPUs and memory.
+// If sb is nil then no config is written and instead just the CPU count is returned.
+func (d *qemu) addCPUMemoryConfig(sb *strings.Builder) (int, error) {
+	instanceTypes, _ := SupportedInstanceTypes()
+	driverInfo := instanceTypes[instancetype.VM]
+	if driverInfo.Name == "" {
+		return -1, fmt.Errorf("Unable to ascertain QEMU version")
+	}
+
+	// Figure out what memory object layout we're going to use.
+	// Before v6.0 or if version unknown, we use the "repeated" format, otherwise we use "indexed" format.
+	qemuMemObjectFormat := "repeated"
+	qemuVer6, _ := version.NewDottedVersion("6.0")
+	qemuVer, _ := version.NewDottedVersion(driverInfo.Version)
+	if qemuVer != nil && qemuVer.Compare(qemuVer6) >= 0 {
+		qemuMemObjectFormat = "indexed"
+	}
+
+	// Default to a single core.
+	cpus := d.expandedConfig["limits.cpu"]
+	if cpus == "" {
+		cpus = "1"
+	}
+
+	ctx := map[string]interface{}{
+		"architecture":        d.architectureName,
+		"qemuMemObjectFormat": qemuMemObjectFormat,
+	}
+
+	cpuCount, err := strconv.Atoi(cpus)
+	hostNodes := []uint64{}
+	if err == nil {
+		// If not pinning, default to exposing cores.
+		ctx["cpuCount"] = cpuCount
+		ctx["cpuSockets"] = 1
+		ctx["cpuCores"] = cpuCount
+		ctx["cpuThreads"] = 1
+		hostNodes = []uint64{0}
+
+		// When vCPUs can be hotplugged, boot with a single vCPU and allow up to a fixed maximum of vCPUs so
+		// the VM layout doesn't depend on the host it runs on. The other vCPUs are hotplugged before the VM
+		// starts so that they can be removed later on.
+		if d.cpuHotplugSupported(d.expandedConfig) {
+			cpuMaxCount := d.cpuMaxCount(cpuCount)
+			ctx["cpuCount"] = 1
+			ctx["cpuCores"] = cpuMaxCount
+			ctx["cpuMaxCount"] = cpuMaxCount
+		}
+	} else {
+		// Expand to a set of CPU identifiers and get the pinning map.
+		nrSockets, nrCores, nrThreads, vcpus, numaNodes, err := d.cpuTopology(cpus)
+		if err != nil {
+			return -1, err
+		}
+
+		// Figure out socket-id/core-id/thread-id for all vcpus.
+		vcpuSocket := map[uint64]uint64{}
+		vcpuCore := map[uint64]uint64{}
+		vcpuThread := map[uint64]uint64{}
+		vcpu := uint64(0)
+		for i := 0; i < nrSockets; i++ {
+			for j := 0; j < nrCores; j++ {
+				for k := 0; k < nrThreads; k++ {
+					vcpuSocket[vcpu] = uint64(i)
+					vcpuCore[vcpu] = uint64(j)
+					vcpuThread[vcpu] = uint64(k)
+					vcpu++
+				}
+			}
+		}
+
+		// Prepare the NUMA map.
+		numa := []map[string]uint64{}
+		numaIDs := []uint64{}
+		numaNode := uint64(0)
+		for hostNode, entry := range numaNodes {
+			hostNodes = append(hostNodes, hostNode)
+
+			numaIDs = append(numaIDs, numaNode)
+			for _, vcpu := range entry {
+				numa = append(numa, map[string]uint64{
+					"node":   numaNode,
+					"socket": vcpuSocket[vcpu],
+					"core":   vcpuCore[vcpu],
+					"thread": vcpuThread[vcpu],
+				})
+			}
+
+			numaNode++
+		}
+
+		// Prepare context.
+		cpuCount = len(vcpus)
+		ctx["cpuCount"] = len(vcpus)
+		ctx["cpuSockets"] = nrSockets
+		ctx["cpuCores"] = nrCores
+		ctx["cpuThreads"] = nrThreads
+		ctx["cpuNumaNodes"] = numaIDs
+		ctx["cpuNumaMapping"] = numa
+		ctx["cpuNumaHostNodes"] = hostNodes
+	}
+
+	// Configure memory limit.
+	memSize := d.expandedConfig["limits.memory"]
+	if memSize == "" {
+		memSize = qemuDefaultMemSize // Default if no memory limit specified.
+	}
+
+	// When restoring a VM that had memory hotplugged, keep the boot time memory size it was started with.
+	if d.localConfig["volatile.memory.base"] != "" {
+		memSize = fmt.Sprintf("%sMiB", d.localConfig["volatile.memory.base"])
+	}
+
+	memSizeBytes, err := units.ParseByteSizeString(memSize)
+	if err != nil {
+		return -1, fmt.Errorf("limits.memory invalid: %v", err)
+	}
+
+	ctx["hugepages"] = ""
+	if shared.IsTrue(d.expandedConfig["limits.memory.hugepages"]) {
+		hugetlb, err := util.HugepagesPath()
+		if err != nil {
+			return -1, err
+		}
+
+		ctx["hugepages"] = hugetlb
+	}
+
+	// Determine per-node memory limit.
+	memSizeBytes = memSizeBytes / 1024 / 1024
+	nodeMemory := int64(memSizeBytes / int64(len(hostNodes)))
+	memSizeBytes = nodeMemory * int64(len(hostNodes))
+	ctx["memory"] = nodeMemory
+
+	if sb != nil {
+		memCtx := map[string]interface{}{
+			"architecture": d.architectureName,
+			"memSizeBytes": memSizeBytes,
+		}
+
+		// Allow hotplugging memory up to a fixed maximum so that the VM layout doesn't depend on the host.
+		if d.architecture == osarch.ARCH_64BIT_INTEL_X86 {
+			memCtx["memSlots"] = qemuMemorySlots
+			memCtx["maxMemSizeBytes"] = d.memMaxSizeMB(memSizeBytes)
+		}
+
+		err = qemuMemory.Execute(sb, memCtx)
+		if err != nil {
+			return -1, err
+		}
+
+		err = qemuCPU.Execute(sb, ctx)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	// Configure the CPU limit.
+	return cpuCount, nil
+}
+
+// cpuHotplugSupported returns whether vCPUs can be added and removed while the VM is running for the given
+// config. This is the case on x86_64 when enabled with limits.cpu.hotplug and when limits.cpu is a number of
+// vCPUs rather than a set of CPUs to pin to.
+func (d *qemu) cpuHotplugSupported(config map[string]string) bool {
+	if d.architecture != osarch.ARCH_64BIT_INTEL_X86 || !shared.IsTrue(config["limits.cpu.hotplug"]) {
+		return false
+	}
+
+	if config["limits.cpu"] == "" {
+		return true
+	}
+
+	_, err := strconv.Atoi(config["limits.cpu"])
+	return err == nil
+}
+
+// cpuMaxCount returns the maximum number of vCPUs the VM can have. This is fixed rather than based on the host
+// CPUs so that a VM can be restored or live migrated onto a host with a different number of CPUs.
+func (d *qemu) cpuMaxCount(cpuCount int) int {
+	if cpuCount > qemuMaxCPUs {
+		return cpuCount
+	}
+
+	return qemuMaxCPUs
+}
+
+// memMaxSizeMB returns the maximum memory size in MiB the VM can have. Like cpuMaxCount, this is fixed rather
+// than based on the host memory so that the VM can be restored or live migrated onto another host.
+func (d *qemu) memMaxSizeMB(memSizeMB int64) int64 {
+	if memSizeMB > qemuMaxMemorySizeMB {
+		return memSizeMB
+	}
+
+	return qemuMaxMemorySizeMB
+}
+
+// setCPUs hotplugs or unplugs vCPUs until the VM has the given number of vCPUs.
+// Only hotplugged vCPUs can be unplugged.
+func (d *qemu) setCPUs(monitor *qmp.Monitor, count int) error {
+	cpus, err := monitor.QueryHotpluggableCPUs()
+	if err != nil {
+		return err
+	}
+
+	// Sort the vCPU slots by topology, as QEMU lists them in reverse order.
+	topology := []string{"socket-id", "die-id", "core-id", "thread-id"}
+	sort.SliceStable(cpus, func(i, j int) bool {
+		for _, key := range topology {
+			if cpus[i].Props[key] != cpus[j].Props[key] {
+				return cpus[i].Props[key] < cpus[j].Props[key]
+			}
+		}
+
+		return false
+	})
+
+	plugged := []qmp.HotpluggableCPU{}
+	available := []int{}
+	for i, cpu := range cpus {
+		if cpu.QOMPath != "" {
+			plugged = append(plugged, cpu)
+		} else {
+			available = append(available, i)
+		}
+	}
+
+	if count > len(plugged) {
+		if count-len(plugged) > len(available) {
+			return fmt.Errorf("Cannot increase the number of vCPUs beyond %d when VM is running", len(cpus))
+		}
+
+		for _, i := range available[:count-len(plugged)] {
+			err = monitor.AddCPU(fmt.Sprintf("cpu%d", i), cpus[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// Remove the last vCPUs first.
+	deviceIDs := []string{}
+	for i := len(plugged) - 1; i >= count; i-- {
+		// vCPUs added at boot time don't have a device ID and can't be removed.
+		if !strings.HasPrefix(plugged[i].QOMPath, "/machine/peripheral/") {
+			return fmt.Errorf("Cannot decrease the number of vCPUs below %d until the VM is restarted", i+1)
+		}
+
+		deviceID := strings.TrimPrefix(plugged[i].QOMPath, "/machine/peripheral/")
+		err = monitor.RemoveDevice(deviceID)
+		if err != nil {
+			return err
+		}
+
+		deviceIDs = append(deviceIDs, deviceID)
+	}
+
+	// Wait until the vCPUs are actually removed (or we timeout waiting), as the guest needs to release them.
+	waitDuration := time.Duration(time.Second * time.Duration(10))
+	waitUntil := time.Now().Add(waitDuration)
+	for _, deviceID := range deviceIDs {
+		for {
+			exists, err := monitor.DeviceExists(deviceID)
+			if err != nil {
+				return err
+			}
+
+			if !exists {
+				break
+			}
+
+			if time.Now().After(waitUntil) {
+				return fmt.Errorf("Failed to remove vCPUs after %v", waitDuration)
+			}
+
+			time.Sleep(500 * time.Millisecond)
+		}
+	}
+
+	return nil
+}
+
+// addFileDescriptor adds a file path to the list of files to open and pass file descriptor to qemu.
+// Returns the file descriptor number that qemu will receive.
+func (d *qemu) addFileDescriptor(fdFiles *[]*os.File, file *os.File) int {
+	// Append the tap device file path to the list of files to be opened and passed to qemu.
+	*fdFiles = append(*fdFiles, file)
+	return 2 + len(*fdFiles) // Use 2+fdFiles count, as first user file descriptor is 3.
+}
+
+// addRootDriveConfig adds the qemu config required for adding the root drive.
+func (d *qemu) addRootDriveConfig(sb *strings.Builder, bus *qemuBus, mountInfo *storagePools.MountInfo, bootIndexes map[string]int, rootDriveConf deviceConfig.MountEntryItem) error {
+	if rootDriveConf.TargetPath != "/" {
+		return fmt.Errorf("Non-root drive config supplied")
+	}
+
+	pool, err := d.getStoragePool()
+	if err != nil {
+		return err
+	}
+
+	if mountInfo.DiskPath == "" {
+		return fmt.Errorf("No disk path available from mount")
+	}
+
+	// Generate a new device config with the root device path expanded.
+	driveConf := deviceConfig.MountEntryItem{
+		DevName: rootDriveConf.DevName,
+		DevPath: mountInfo.DiskPath,
+		Opts:    rootDriveConf.Opts,
+	}
+
+	// If the storage pool is on ZFS and backed by a loop file and we can't use DirectIO, then resort to
+	// unsafe async I/O to avoid kernel lock up when running ZFS storage pools in an image file on another FS.
+	driverInfo := pool.Driver().Info()
+	driverConf := pool.Driver().Config()
+	if driverInfo.Name == "zfs" && !driverInfo.DirectIO && shared.PathExists(driverConf["source"]) && !shared.IsBlockdevPath(driverConf["source"]) {
+		driveConf.Opts = append(driveConf.Opts, qemuUnsafeIO)
+	}
+
+	return d.addDriveConfig(sb, bus, nil, bootIndexes, driveConf)
+}
+
+// qemuDriveDirAgentMount returns the mount the agent needs to perform for a drive directory share.
+func qemuDriveDirAgentMount(driveConf deviceConfig.MountEntryItem) instancetype.VMAgentMount {
+	agentMount := instancetype.VMAgentMount{
+		Source: fmt.Sprintf("lxd_%s", driveConf.DevName),
+		Target: driveConf.TargetPath,
+		FSType: driveConf.FSType,
+	}
+
+	// If mount type is 9p, we need to specify to use the virtio transport to support more VM guest OSes.
 	if agentMount.FSType == "9p" {
 		agentMount.Options = append(agentMount.Options, "trans=virtio")
 	}
 
-	readonly := shared.StringInSlice("ro", driveConf.Opts)
+	// Indicate to agent to mount this readonly. Note: This is purely to indicate to VM guest that this is
+	// readonly, it should *not* be used as a security measure, as the VM guest could remount it R/W.
+	if shared.StringInSlice("ro", driveConf.Opts) {
+		agentMount.Options = append(agentMount.Options, "ro")
+	}
+
+	return agentMount
+}
+
+// qemuDriveOpt returns the value of the drive option with the given name provided by the disk device if any.
+func qemuDriveOpt(driveConf deviceConfig.MountEntryItem, name string) string {
+	var value string
+	for _, opt := range driveConf.Opts {
+		if strings.HasPrefix(opt, fmt.Sprintf("%s=", name)) {
+			parts := strings.SplitN(opt, "=", 2)
+			value = parts[1]
+		}
+	}
+
+	return value
+}
+
+// qemuDriveBus returns the bus a drive should be attached to and whether it uses a dedicated I/O thread.
+func qemuDriveBus(driveConf deviceConfig.MountEntryItem) (string, bool) {
+	ioBus := qemuDriveOpt(driveConf, device.DiskBusMountOpt)
+	if ioBus == "" {
+		ioBus = "virtio-scsi"
+	}
+
+	return ioBus, shared.StringInSlice(device.DiskIOThreadMountOpt, driveConf.Opts)
+}
+
+// qemuVirtiofsdSockPath returns the virtiofsd socket path provided by the disk device if any.
+func qemuVirtiofsdSockPath(driveConf deviceConfig.MountEntryItem) string {
+	return qemuDriveOpt(driveConf, device.DiskVirtiofsdSockMountOpt)
+}
+
+// addDriveDirConfig adds the qemu config required for adding a supplementary drive directory share.
+func (d *qemu) addDriveDirConfig(sb *strings.Builder, bus *qemuBus, fdFiles *[]*os.File, agentMounts *[]instancetype.VMAgentMount, driveConf deviceConfig.MountEntryItem) error {
+	agentMount := qemuDriveDirAgentMount(driveConf)
+	mountTag := agentMount.Source
+	readonly := shared.StringInSlice("ro", driveConf.Opts)
+
+	// Record the 9p mount for the agent.
+	*agentMounts = append(*agentMounts, agentMount)
+
+	// Check if the disk device has provided a virtiofsd socket path.
+	virtiofsdSockPath := qemuVirtiofsdSockPath(driveConf)
+
+	// If there is a virtiofsd socket path setup the virtio-fs share.
+	if virtiofsdSockPath != "" {
+		if !shared.PathExists(virtiofsdSockPath) {
+			return fmt.Errorf("virtiofsd socket path %q doesn't exist", virtiofsdSockPath)
+		}
+
+		devBus, devAddr, multi := bus.allocate(busFunctionGroup9p)
+
+		// Add virtio-fs device as this will be preferred over 9p.
+		err := qemuDriveDir.Execute(sb, map[string]interface{}{
+			"bus":           bus.name,
+			"devBus":        devBus,
+			"devAddr":       devAddr,
+			"multifunction": multi,
+
+			"devName":  driveConf.DevName,
+			"mountTag": mountTag,
+			"path":     virtiofsdSockPath,
+			"protocol": "virtio-fs",
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Add 9p share config.
+	devBus, devAddr, multi := bus.allocate(busFunctionGroup9p)
+
+	fd, err := strconv.Atoi(driveConf.DevPath)
+	if err != nil {
+		return fmt.Errorf("Invalid file descriptor %q for drive %q: %w", driveConf.DevPath, driveConf.DevName, err)
+	}
+
+	proxyFD := d.addFileDescriptor(fdFiles, os.NewFile(uintptr(fd), driveConf.DevName))
+
+	return qemuDriveDir.Execute(sb, map[string]interface{}{
+		"bus":           bus.name,
+		"devBus":        devBus,
+		"devAddr":       devAddr,
+		"multifunction": multi,
+
+		"devName":  driveConf.DevName,
+		"mountTag": mountTag,
+		"proxyFD":  proxyFD, // Pass by file descriptor, so don't add to d.devPaths for apparmor access.
+		"readonly": readonly,
+		"protocol": "9p",
+	})
+}
+
+// addDriveConfig adds the qemu config required for adding a supplementary drive.
+func (d *qemu) addDriveConfig(sb *strings.Builder, bus *qemuBus, fdFiles *[]*os.File, bootIndexes map[string]int, driveConf deviceConfig.MountEntryItem) error {
+	aioMode := "native" // Use native kernel async IO and O_DIRECT by default.
+	cacheMode := "none" // Bypass host cache, use O_DIRECT semantics by default.
+	media := "disk"
+
+	ioBus, ioThread := qemuDriveBus(driveConf)
+	if ioBus == "nvme" && bus.name == "ccw" {
+		return fmt.Errorf("The nvme bus isn't supported on this architecture for drive %q", driveConf.DevName)
+	}
+
+	// Handle local disk devices.
+	if !strings.HasPrefix(driveConf.DevPath, "rbd:") {
+		var err error
+
+		srcDevPath := driveConf.DevPath
+
+		// Detect if existing file descriptor format is being supplied.
+		if strings.HasPrefix(driveConf.DevPath, fmt.Sprintf("%s:", device.DiskFileDescriptorMountPrefix)) {
+			// Expect devPath in format "fd:<fdNum>:<devPath>".
+			devPathParts := strings.SplitN(driveConf.DevPath, ":", 3)
+			if len(devPathParts) != 3 || !strings.HasPrefix(driveConf.DevPath, fmt.Sprintf("%s:", device.DiskFileDescriptorMountPrefix)) {
+				return fmt.Errorf("Unexpected devPath file descriptor format %q for drive %q", driveConf.DevPath, driveConf.DevName)
+			}
+
+			// Map the file descriptor to the file descriptor path it will be in the QEMU process.
+			fd, err := strconv.Atoi(devPathParts[1])
+			if err != nil {
+				return fmt.Errorf("Invalid file descriptor %q for drive %q: %w", devPathParts[1], driveConf.DevName, err)
+			}
+
+			// Extract original dev path for additional probing.
+			srcDevPath = devPathParts[2]
+
+			driveConf.DevPath = fmt.Sprintf("/proc/self/fd/%d", d.addFileDescriptor(fdFiles, os.NewFile(uintptr(fd), srcDevPath)))
+		}
+
+		aioMode, cacheMode, media, err = d.driveIOConfig(srcDevPath, driveConf)
+		if err != nil {
+			return err
+		}
+
+		// Add src path to external devPaths. This way, the path will be included in the apparmor profile.
+		d.devPaths = append(d.devPaths, srcDevPath)
+	} else if qemuDriveOpt(driveConf, device.DiskCacheMountOpt) != "" {
+		cacheMode = qemuDriveOpt(driveConf, device.DiskCacheMountOpt)
+	}
+
+	// Drives not on the shared SCSI controller need their own PCI slot.
+	var devBus, devAddr string
+	var multi bool
+	if ioBus != "virtio-scsi" || ioThread {
+		devBus, devAddr, multi = bus.allocate(busFunctionGroupNone)
+	}
+
+	// Only SCSI drives can be presented as a CD-ROM, so present others as read-only disks.
+	readonly := shared.StringInSlice("ro", driveConf.Opts)
+	if media == "cdrom" && ioBus != "virtio-scsi" {
+		readonly = true
+	}
+
+	return qemuDrive.Execute(sb, map[string]interface{}{
+		"bus":           bus.name,
+		"devBus":        devBus,
+		"devAddr":       devAddr,
+		"multifunction": multi,
+
+		"devName":   driveConf.DevName,
+		"devPath":   driveConf.DevPath,
+		"bootIndex": bootIndexes[driveConf.DevName],
+		"cacheMode": cacheMode,
+		"aioMode":   aioMode,
+		"media":     media,
+		"shared":    driveConf.TargetPath != "/" && !strings.HasPrefix(driveConf.DevPath, "rbd:"),
+		"readonly":  readonly,
+		"ioBus":     ioBus,
+		"ioThread":  ioThread,
+	})
+}
+
+// driveIOConfig returns the AIO mode, cache mode and media type to use for a local drive.
+func (d *qemu) driveIOConfig(srcDevPath string, driveConf deviceConfig.MountEntryItem) (string, string, string, error) {
+	aioMode := "native" // Use native kernel async IO and O_DIRECT by default.
+	cacheMode := "none" // Bypass host cache, use O_DIRECT semantics by default.
+	media := "disk"
+
+	// If drive config indicates we need to use unsafe I/O then use it.
+	if shared.StringInSlice(qemuUnsafeIO, driveConf.Opts) {
+		d.logger.Warn("Using unsafe cache I/O", log.Ctx{"DevPath": srcDevPath})
+		aioMode = "threads"
+		cacheMode = "unsafe" // Use host cache, but ignore all sync requests from guest.
+	} else if shared.PathExists(srcDevPath) && !shared.IsBlockdevPath(srcDevPath) {
+		// Disk dev path is a file, check whether it is located on a ZFS filesystem.
+		fsType, err := filesystem.Detect(srcDevPath)
+		if err != nil {
+			return "", "", "", errors.Wrapf(err, "Failed detecting filesystem type of %q", srcDevPath)
+		}
+
+		// If backing FS is ZFS or BTRFS, avoid using direct I/O and use host page cache only.
+		// We've seen ZFS lock up and BTRFS checksum issues when using direct I/O on image files.
+		if fsType == "zfs" || fsType == "btrfs" {
+			if driveConf.FSType != "iso9660" {
+				// Only warn about using writeback cache if the drive image is writable.
+				d.logger.Warn("Using writeback cache I/O", log.Ctx{"DevPath": srcDevPath, "fsType": fsType})
+			}
+
+			aioMode = "threads"
+			cacheMode = "writeback" // Use host cache, with neither O_DSYNC nor O_DIRECT semantics.
+		}
+
+		// Special case ISO images as cdroms.
+		if strings.HasSuffix(srcDevPath, ".iso") {
+			media = "cdrom"
+		}
+	}
+
+	// ISO storage volumes are always presented as cdroms.
+	if driveConf.FSType == "iso9660" {
+		media = "cdrom"
+	}
+
+	// Use the cache mode requested by the disk device if any. Native async IO requires O_DIRECT semantics.
+	ioCache := qemuDriveOpt(driveConf, device.DiskCacheMountOpt)
+	if ioCache != "" {
+		cacheMode = ioCache
+		aioMode = "threads"
+		if cacheMode == "none" {
+			aioMode = "native"
+		}
+	}
+
+	return aioMode, cacheMode, media, nil
+}
+
+// addNetDevConfig adds the qemu config required for adding a network device.
+// The qemuDev map is expected to be preconfigured with the settings for an existing port to use for the device.
+func (d *qemu) addNetDevConfig(cpuCount int, busName string, qemuDev map[string]string, bootIndexes map[string]int, nicConfig []deviceConfig.RunConfigItem) (monitorHook, error) {
+	revert := revert.New()
+	defer revert.Fail()
+
+	var devName, nicName, devHwaddr, pciSlotName, pciIOMMUGroup string
+	for _, nicItem := range nicConfig {
+		if nicItem.Key == "devName" {
+			devName = nicItem.Value
+		} else if nicItem.Key == "link" {
+			nicName = nicItem.Value
+		} else if nicItem.Key == "hwaddr" {
+			devHwaddr = nicItem.Value
+		} else if nicItem.Key == "pciSlotName" {
+			pciSlotName = nicItem.Value
+		} else if nicItem.Key == "pciIOMMUGroup" {
+			pciIOMMUGroup = nicItem.Value
+		}
+	}
+
+	var qemuNetDev map[string]interface{}
+	qemuDev["id"] = fmt.Sprintf("%s%s", qemuDeviceIDPrefix, devName)
+
+	if len(bootIndexes) > 0 {
+		bootIndex, found := bootIndexes[devName]
+		if found {
+			qemuDev["bootindex"] = strconv.Itoa(bootIndex)
+		}
+	}
+
+	// Detect MACVTAP interface types and figure out which tap device is being used.
+	// This is so we can open a file handle to the tap device and pass it to the qemu process.
+	if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/macvtap", nicName)) {
+		content, err := ioutil.ReadFile(fmt.Sprintf("/sys/class/net/%s/ifindex", nicName))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error getting tap device ifindex")
+		}
+
+		ifindex, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error parsing tap device ifindex")
+		}
+
+		qemuNetDev = map[string]interface{}{
+			"id":    fmt.Sprintf("%s%s", qemuNetDevIDPrefix, devName),
+			"type":  "tap",
+			"vhost": true,
+			"fd":    fmt.Sprintf("/dev/tap%d", ifindex), // Indicates the file to open and the FD name.
+		}
+
+		if shared.StringInSlice(busName, []string{"pcie", "pci"}) {
+			qemuDev["driver"] = "virtio-net-pci"
+		} else if busName == "ccw" {
+			qemuDev["driver"] = "virtio-net-ccw"
+		}
+
+		qemuDev["netdev"] = qemuNetDev["id"].(string)
+		qemuDev["mac"] = devHwaddr
+	} else if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/tun_flags", nicName)) {
+		// Detect TAP (via TUN driver) device.
+		qemuNetDev = map[string]interface{}{
+			"id":         fmt.Sprintf("%s%s", qemuNetDevIDPrefix, devName),
+			"type":       "tap",
+			"vhost":      true,
+			"script":     "no",
+			"downscript": "no",
+			"ifname":     nicName,
+		}
+
+		// Number of queues is the same as number of vCPUs. Run with a minimum of two queues.
+		queueCount := cpuCount
+		if queueCount < 2 {
+			queueCount = 2
+		}
+
+		if queueCount > 0 {
+			qemuNetDev["queues"] = queueCount
+		}
+
+		if shared.StringInSlice(busName, []string{"pcie", "pci"}) {
+			qemuDev["driver"] = "virtio-net-pci"
+		} else if busName == "ccw" {
+			qemuDev["driver"] = "virtio-net-ccw"
+		}
+
+		// Number of vectors is number of vCPUs * 2 (RX/TX) + 2 (config/control MSI-X).
+		vectors := 2*queueCount + 2
+		if vectors > 0 {
+			qemuDev["mq"] = "on"
+			if shared.StringInSlice(busName, []string{"pcie", "pci"}) {
+				qemuDev["vectors"] = strconv.Itoa(vectors)
+			}
+		}
+
+		qemuDev["netdev"] = qemuNetDev["id"].(string)
+		qemuDev["mac"] = devHwaddr
+	} else if pciSlotName != "" {
+		// Detect physical passthrough device.
+		if shared.StringInSlice(busName, []string{"pcie", "pci"}) {
+			qemuDev["driver"] = "vfio-pci"
+		} else if busName == "ccw" {
+			qemuDev["driver"] = "vfio-ccw"
+		}
+
+		qemuDev["host"] = pciSlotName
+
+		if d.state.OS.UnprivUser != "" {
+			if pciIOMMUGroup == "" {
+				return nil, fmt.Errorf("No PCI IOMMU group supplied")
+			}
+
+			vfioGroupFile := fmt.Sprintf("/dev/vfio/%s", pciIOMMUGroup)
+			err := os.Chown(vfioGroupFile, int(d.state.OS.UnprivUID), -1)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to chown vfio group device %q", vfioGroupFile)
+			}
+			revert.Add(func() { os.Chown(vfioGroupFile, 0, -1) })
+		}
+	}
+
+	if qemuDev["driver"] != "" {
+		// Return a monitor hook to add the NIC via QMP before the VM is started.
+		monHook := func(m *qmp.Monitor) error {
+			if fd, found := qemuNetDev["fd"]; found {
+				fileName := fd.(string)
+
+				f, err := os.OpenFile(fileName, os.O_RDWR, 0)
+				if err != nil {
+					return errors.Wrapf(err, "Error opening exta file %q", fileName)
+				}
+				defer f.Close() // Close file after device has been added.
+
+				err = m.SendFile(fileName, f)
+				if err != nil {
+					return errors.Wrapf(err, "Error sending exta file %q", fileName)
+				}
+			}
+
+			err := m.AddNIC(qemuNetDev, qemuDev)
+			if err != nil {
+				return errors.Wrapf(err, "Failed setting up device %v", devName)
+			}
+
+			return nil
+		}
+
+		revert.Success()
+		return monHook, nil
+	}
+
+	return nil, fmt.Errorf("Unrecognised device type")
+}
+
+// addPCIDevConfig adds the qemu config required for adding a raw PCI device.
+func (d *qemu) addPCIDevConfig(sb *strings.Builder, bus *qemuBus, pciConfig []deviceConfig.RunConfigItem) error {
+	var devName, pciSlotName string
+	for _, pciItem := range pciConfig {
+		if pciItem.Key == "devName" {
+			devName = pciItem.Value
+		} else if pciItem.Key == "pciSlotName" {
+			pciSlotName = pciItem.Value
+		}
+	}
+
+	devBus, devAddr, multi := bus.allocate(fmt.Sprintf("lxd_%s", devName))
+	tplFields := map[string]interface{}{
+		"bus":           bus.name,
+		"devBus":        devBus,
+		"devAddr":       devAddr,
+		"multifunction": multi,
+
+		"devName":     devName,
+		"pciSlotName": pciSlotName,
+	}
+
+	return qemuPCIPhysical.Execute(sb, tplFields)
+}
+
+// addGPUDevConfig adds the qemu config required for adding a GPU device.
+func (d *qemu) addGPUDevConfig(sb *strings.Builder, bus *qemuBus, gpuConfig []deviceConfig.RunConfigItem) error {
+	var devName, pciSlotName, vgpu string
+	for _, gpuItem := range gpuConfig {
+		if gpuItem.Key == "devName" {
+			devName = gpuItem.Value
+		} else if gpuItem.Key == "pciSlotName" {
+			pciSlotName = gpuItem.Value
+		} else if gpuItem.Key == "vgpu" {
+			vgpu = gpuItem.Value
+		}
+	}
+
+	vgaMode := func() bool {
+		// No VGA mode on non-x86.
+		if d.architecture != osarch.ARCH_64BIT_INTEL_X86 {
+			return false
+		}
+
+		// Only enable if present on the card.
+		if !shared.PathExists(filepath.Join("/sys/bus/pci/devices", pciSlotName, "boot_vga")) {
+			return false
+		}
+
+		// Skip SRIOV VFs as those are shared with the host card.
+		if shared.PathExists(filepath.Join("/sys/bus/pci/devices", pciSlotName, "physfn")) {
+			return false
+		}
+
+		return true
+	}()
+
+	devBus, devAddr, multi := bus.allocate(fmt.Sprintf("lxd_%s", devName))
+	tplFields := map[string]interface{}{
+		"bus":           bus.name,
+		"devBus":        devBus,
+		"devAddr":       devAddr,
+		"multifunction": multi,
+
+		"devName":     devName,
+		"pciSlotName": pciSlotName,
+		"vga":         vgaMode,
+		"vgpu":        vgpu,
+	}
+
+	// Add main GPU device in VGA mode to qemu config.
+	err := qemuGPUDevPhysical.Execute(sb, tplFields)
+	if err != nil {
+		return err
+	}
+
+	var iommuGroupPath string
+
+	if vgpu != "" {
+		iommuGroupPath = filepath.Join("/sys/bus/mdev/devices", vgpu, "iommu_group", "devices")
+	} else {
+		// Add any other related IOMMU VFs as generic PCI devices.
+		iommuGroupPath = filepath.Join("/sys/bus/pci/devices", pciSlotName, "iommu_group", "devices")
+	}
+
+	if shared.PathExists(iommuGroupPath) {
+		// Extract parent slot name by removing any virtual function ID.
+		parts := strings.SplitN(pciSlotName, ".", 2)
+		prefix := parts[0]
+
+		// Iterate the members of the IOMMU group and override any that match the parent slot name prefix.
+		err := filepath.Walk(iommuGroupPath, func(path string, _ os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			iommuSlotName := filepath.Base(path) // Virtual function's address is dir name.
+
+			// Match any VFs that are related to the GPU device (but not the GPU device itself).
+			if strings.HasPrefix(iommuSlotName, prefix) && iommuSlotName != pciSlotName {
+				// Add VF device without VGA mode to qemu config.
+				devBus, devAddr, multi := bus.allocate(fmt.Sprintf("lxd_%s", devName))
+				tplFields := map[string]interface{}{
+					"bus":           bus.name,
+					"devBus":        devBus,
+					"devAddr":       devAddr,
+					"multifunction": multi,
+
+					// Generate associated device name by combining main device name and VF ID.
+					"devName":     fmt.Sprintf("%s_%s", devName, devAddr),
+					"pciSlotName": iommuSlotName,
+					"vga":         false,
+					"vgpu":        "",
+				}
+
+				err := qemuGPUDevPhysical.Execute(sb, tplFields)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-	// Indicate to agent to mount this readonly. Note: This is purely to indicate to VM guest that this is
-	// readonly, it should *not* be used as a security measure, as the VM guest could remount it R/W.
-	if readonly {
-		agentMount.Options = append(agentMount.Options, "ro")
+func (d *qemu) addUSBDeviceConfig(sb *strings.Builder, bus *qemuBus, usbDev deviceConfig.USBDeviceItem) error {
+	tplFields := map[string]interface{}{
+		"hostDevice": usbDev.HostDevicePath,
+		"devName":    usbDev.DeviceName,
 	}
 
-	// Record the 9p mount for the agent.
-	*agentMounts = append(*agentMounts, agentMount)
+	err := qemuUSBDev.Execute(sb, tplFields)
+	if err != nil {
+		return err
+	}
 
-	// Check if the disk device has provided a virtiofsd socket path.
-	var virtiofsdSockPath string
-	for _, opt := range driveConf.Opts {
-		if strings.HasPrefix(opt, fmt.Sprintf("%s=", device.DiskVirtiofsdSockMountOpt)) {
-			parts := strings.SplitN(opt, "=", 2)
-			virtiofsdSockPath = parts[1]
+	// Add path to external devPaths. This way, the path will be included in the apparmor profile.
+	d.devPaths = append(d.devPaths, usbDev.HostDevicePath)
+
+	return nil
+}
+
+func (d *qemu) addTPMDeviceConfig(sb *strings.Builder, tpmConfig []deviceConfig.RunConfigItem) error {
+	var devName, socketPath string
+
+	for _, tpmItem := range tpmConfig {
+		if tpmItem.Key == "path" {
+			socketPath = tpmItem.Value
+		} else if tpmItem.Key == "devName" {
+			devName = tpmItem.Value
 		}
 	}
 
-	// If there is a virtiofsd socket path setup the virtio-fs share.
-	if virtiofsdSockPath != "" {
-		if !shared.PathExists(virtiofsdSockPath) {
-			return fmt.Errorf("virtiofsd socket path %q doesn't exist", virtiofsdSockPath)
-		}
+	tplFields := map[string]interface{}{
+		"devName": devName,
+		"path":    socketPath,
+	}
 
-		devBus, devAddr, multi := bus.allocate(busFunctionGroup9p)
+	err := qemuTPM.Execute(sb, tplFields)
+	if err != nil {
+		return err
+	}
 
-		// Add virtio-fs device as this will be preferred over 9p.
-		err := qemuDriveDir.Execute(sb, map[string]interface{}{
-			"bus":           bus.name,
-			"devBus":        devBus,
-			"devAddr":       devAddr,
-			"multifunction": multi,
+	return nil
+}
 
-			"devName":  driveConf.DevName,
-			"mountTag": mountTag,
-			"path":     virtiofsdSockPath,
-			"protocol": "virtio-fs",
-		})
+// pidFilePath returns the path where the qemu process should write its PID.
+func (d *qemu) pidFilePath() string {
+	return filepath.Join(d.LogPath(), "qemu.pid")
+}
+
+// pid gets the PID of the running qemu process. Returns 0 if PID file or process not found, and -1 if err non-nil.
+func (d *qemu) pid() (int, error) {
+	pidStr, err := ioutil.ReadFile(d.pidFilePath())
+	if os.IsNotExist(err) {
+		return 0, nil // PID file has gone.
+	}
+
+	if err != nil {
+		return -1, err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(pidStr)))
+	if err != nil {
+		return -1, err
+	}
+
+	cmdLineProcFilePath := fmt.Sprintf("/proc/%d/cmdline", pid)
+	cmdLine, err := ioutil.ReadFile(cmdLineProcFilePath)
+	if err != nil {
+		return 0, nil // Process has gone.
+	}
+
+	qemuSearchString := []byte("qemu-system")
+	instUUID := []byte(d.localConfig["volatile.uuid"])
+	if !bytes.Contains(cmdLine, qemuSearchString) || !bytes.Contains(cmdLine, instUUID) {
+		return -1, fmt.Errorf("PID doesn't match the running process")
+	}
+
+	return pid, nil
+}
+
+// forceStop kills the QEMU prorcess if running, performs normal device & operation cleanup and sends stop
+// lifecycle event.
+func (d *qemu) forceStop() error {
+	pid, _ := d.pid()
+	if pid > 0 {
+		err := d.killQemuProcess(pid)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to stop VM process %d", pid)
+		}
+
+		// Wait for QEMU process to exit and perform device cleanup.
+		err = d.onStop("stop")
 		if err != nil {
 			return err
 		}
+
+		d.state.Events.SendLifecycle(d.project, lifecycle.InstanceStopped.Event(d, nil))
 	}
 
-	// Add 9p share config.
-	devBus, devAddr, multi := bus.allocate(busFunctionGroup9p)
+	return nil
+}
 
-	fd, err := strconv.Atoi(driveConf.DevPath)
+// Stop the VM.
+func (d *qemu) Stop(stateful bool) error {
+	d.logger.Debug("Stop started", log.Ctx{"stateful": stateful})
+	defer d.logger.Debug("Stop finished", log.Ctx{"stateful": stateful})
+
+	// Must be run prior to creating the operation lock.
+	// Allow stop to proceed if statusCode is Error as we may need to forcefully kill the QEMU process.
+	statusCode := d.statusCode()
+	if !d.isRunningStatusCode(statusCode) && statusCode != api.Error {
+		return ErrInstanceIsStopped
+	}
+
+	// Check for stateful.
+	if stateful && !shared.IsTrue(d.expandedConfig["migration.stateful"]) {
+		return fmt.Errorf("Stateful stop requires migration.stateful to be set to true")
+	}
+
+	// Setup a new operation.
+	// Allow inheriting of ongoing restart or restore operation (we are called from restartCommon and Restore).
+	// Don't allow reuse when creating a new stop operation. This prevents other operations from intefering.
+	// Allow reuse of a reusable ongoing stop operation as Shutdown() may be called first, which allows reuse
+	// of its operations. This allow for Stop() to inherit from Shutdown() where instance is stuck.
+	op, err := operationlock.CreateWaitGet(d.Project(), d.Name(), operationlock.ActionStop, []operationlock.Action{operationlock.ActionRestart, operationlock.ActionRestore}, false, true)
 	if err != nil {
-		return fmt.Errorf("Invalid file descriptor %q for drive %q: %w", driveConf.DevPath, driveConf.DevName, err)
+		if errors.Is(err, operationlock.ErrNonReusuableSucceeded) {
+			// An existing matching operation has now succeeded, return.
+			return nil
+		}
+
+		return err
 	}
 
-	proxyFD := d.addFileDescriptor(fdFiles, os.NewFile(uintptr(fd), driveConf.DevName))
+	// Connect to the monitor.
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
+	if err != nil {
+		// If we fail to connect, it's most likely because the VM is already off, but it could also be
+		// because the qemu process is not responding, check if process still exists and kill it if needed.
+		err = d.forceStop()
+		op.Done(err)
+		return err
+	}
 
-	return qemuDriveDir.Execute(sb, map[string]interface{}{
-		"bus":           bus.name,
-		"devBus":        devBus,
-		"devAddr":       devAddr,
-		"multifunction": multi,
+	// Get the wait channel.
+	chDisconnect, err := monitor.Wait()
+	if err != nil {
+		if err == qmp.ErrMonitorDisconnect {
+			// If we fail to wait, it's most likely because the VM is already off, but it could also be
+			// because the qemu process is not responding, check if process still exists and kill it if
+			// needed.
+			err = d.forceStop()
+			op.Done(err)
+			return err
+		}
 
-		"devName":  driveConf.DevName,
-		"mountTag": mountTag,
-		"proxyFD":  proxyFD, // Pass by file descriptor, so don't add to d.devPaths for apparmor access.
-		"readonly": readonly,
-		"protocol": "9p",
-	})
-}
+		op.Done(err)
+		return err
+	}
 
-// addDriveConfig adds the qemu config required for adding a supplementary drive.
-func (d *qemu) addDriveConfig(sb *strings.Builder, fdFiles *[]*os.File, bootIndexes map[string]int, driveConf deviceConfig.MountEntryItem) error {
-	aioMode := "native" // Use native kernel async IO and O_DIRECT by default.
-	cacheMode := "none" // Bypass host cache, use O_DIRECT semantics by default.
-	media := "disk"
+	// Handle stateful stop.
+	if stateful {
+		// Dump the state.
+		err := d.saveState(monitor)
+		if err != nil {
+			op.Done(err)
+			return err
+		}
 
-	// Handle local disk devices.
-	if !strings.HasPrefix(driveConf.DevPath, "rbd:") {
-		srcDevPath := driveConf.DevPath
+		// Reset the timer.
+		op.Reset()
+
+		// Mark the instance as having state.
+		d.stateful = true
+		err = d.state.Cluster.UpdateInstanceStatefulFlag(d.id, true)
+		if err != nil {
+			op.Done(err)
+			return err
+		}
+	}
+
+	// Send the quit command.
+	err = monitor.Quit()
+	if err != nil {
+		if err == qmp.ErrMonitorDisconnect {
+			op.Done(nil)
+			return nil
+		}
 
-		// Detect if existing file descriptor format is being supplied.
-		if strings.HasPrefix(driveConf.DevPath, fmt.Sprintf("%s:", device.DiskFileDescriptorMountPrefix)) {
-			// Expect devPath in format "fd:<fdNum>:<devPath>".
-			devPathParts := strings.SplitN(driveConf.DevPath, ":", 3)
-			if len(devPathParts) != 3 || !strings.HasPrefix(driveConf.DevPath, fmt.Sprintf("%s:", device.DiskFileDescriptorMountPrefix)) {
-				return fmt.Errorf("Unexpected devPath file descriptor format %q for drive %q", driveConf.DevPath, driveConf.DevName)
-			}
+		op.Done(err)
+		return err
+	}
 
-			// Map the file descriptor to the file descriptor path it will be in the QEMU process.
-			fd, err := strconv.Atoi(devPathParts[1])
-			if err != nil {
-				return fmt.Errorf("Invalid file descriptor %q for drive %q: %w", devPathParts[1], driveConf.DevName, err)
-			}
+	// Wait for QEMU to exit (can take a while if pending I/O).
+	<-chDisconnect
 
-			// Extract original dev path for additional probing.
-			srcDevPath = devPathParts[2]
+	// Wait for operation lock to be Done. This is normally completed by onStop which picks up the same
+	// operation lock and then marks it as Done after the instance stops and the devices have been cleaned up.
+	// However if the operation has failed for another reason we will collect the error here.
+	err = op.Wait()
+	status := d.statusCode()
+	if status != api.Stopped {
+		errPrefix := fmt.Errorf("Failed stopping instance, status is %q", status)
 
-			driveConf.DevPath = fmt.Sprintf("/proc/self/fd/%d", d.addFileDescriptor(fdFiles, os.NewFile(uintptr(fd), srcDevPath)))
+		if err != nil {
+			return fmt.Errorf("%s: %w", errPrefix.Error(), err)
 		}
 
-		// If drive config indicates we need to use unsafe I/O then use it.
-		if shared.StringInSlice(qemuUnsafeIO, driveConf.Opts) {
-			d.logger.Warn("Using unsafe cache I/O", log.Ctx{"DevPath": srcDevPath})
-			aioMode = "threads"
-			cacheMode = "unsafe" // Use host cache, but ignore all sync requests from guest.
-		} else if shared.PathExists(srcDevPath) && !shared.IsBlockdevPath(srcDevPath) {
-			// Disk dev path is a file, check whether it is located on a ZFS filesystem.
-			fsType, err := filesystem.Detect(driveConf.DevPath)
-			if err != nil {
-				return errors.Wrapf(err, "Failed detecting filesystem type of %q", srcDevPath)
-			}
+		return errPrefix
+	} else if op.Action() == "stop" {
+		// If instance stopped, send lifecycle event (even if there has been an error cleaning up).
+		d.state.Events.SendLifecycle(d.project, lifecycle.InstanceStopped.Event(d, nil))
+	}
 
-			// If backing FS is ZFS or BTRFS, avoid using direct I/O and use host page cache only.
-			// We've seen ZFS lock up and BTRFS checksum issues when using direct I/O on image files.
-			if fsType == "zfs" || fsType == "btrfs" {
-				if driveConf.FSType != "iso9660" {
-					// Only warn about using writeback cache if the drive image is writable.
-					d.logger.Warn("Using writeback cache I/O", log.Ctx{"DevPath": srcDevPath, "fsType": fsType})
-				}
+	// Now handle errors from stop sequence and return to caller if wasn't completed cleanly.
+	if err != nil {
+		return err
+	}
 
-				aioMode = "threads"
-				cacheMode = "writeback" // Use host cache, with neither O_DSYNC nor O_DIRECT semantics.
-			}
+	return nil
+}
 
-			// Special case ISO images as cdroms.
-			if strings.HasSuffix(srcDevPath, ".iso") {
-				media = "cdrom"
-			}
-		}
+// Unfreeze restores the instance to running.
+func (d *qemu) Unfreeze() error {
+	// Connect to the monitor.
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
+	if err != nil {
+		return err
+	}
 
-		// Add src path to external devPaths. This way, the path will be included in the apparmor profile.
-		d.devPaths = append(d.devPaths, srcDevPath)
+	// Send the cont command.
+	err = monitor.Start()
+	if err != nil {
+		return err
 	}
 
-	return qemuDrive.Execute(sb, map[string]interface{}{
-		"devName":   driveConf.DevName,
-		"devPath":   driveConf.DevPath,
-		"bootIndex": bootIndexes[driveConf.DevName],
-		"cacheMode": cacheMode,
-		"aioMode":   aioMode,
-		"media":     media,
-		"shared":    driveConf.TargetPath != "/" && !strings.HasPrefix(driveConf.DevPath, "rbd:"),
-		"readonly":  shared.StringInSlice("ro", driveConf.Opts),
-	})
+	d.state.Events.SendLifecycle(d.project, lifecycle.InstanceResumed.Event(d, nil))
+	return nil
 }
 
-// addNetDevConfig adds the qemu config required for adding a network device.
-// The qemuDev map is expected to be preconfigured with the settings for an existing port to use for the device.
-func (d *qemu) addNetDevConfig(cpuCount int, busName string, qemuDev map[string]string, bootIndexes map[string]int, nicConfig []deviceConfig.RunConfigItem) (monitorHook, error) {
-	revert := revert.New()
-	defer revert.Fail()
+// IsPrivileged does not apply to virtual machines. Always returns false.
+func (d *qemu) IsPrivileged() bool {
+	return false
+}
 
-	var devName, nicName, devHwaddr, pciSlotName, pciIOMMUGroup string
-	for _, nicItem := range nicConfig {
-		if nicItem.Key == "devName" {
-			devName = nicItem.Value
-		} else if nicItem.Key == "link" {
-			nicName = nicItem.Value
-		} else if nicItem.Key == "hwaddr" {
-			devHwaddr = nicItem.Value
-		} else if nicItem.Key == "pciSlotName" {
-			pciSlotName = nicItem.Value
-		} else if nicItem.Key == "pciIOMMUGroup" {
-			pciIOMMUGroup = nicItem.Value
-		}
+// Snapshot takes a new snapshot.
+func (d *qemu) Snapshot(name string, expiry time.Time, stateful bool) error {
+	// Stateful snapshots include the guest memory so are consistent without freezing the filesystems.
+	consistency := ""
+	if d.IsRunning() && !stateful {
+		var thaw func()
+		consistency, thaw = d.freezeFilesystems(instance.FreezeTimeout)
+		defer thaw()
 	}
 
-	var qemuNetDev map[string]interface{}
-	qemuDev["id"] = fmt.Sprintf("%s%s", qemuDeviceIDPrefix, devName)
+	// Deal with state.
+	if stateful {
+		// Confirm the instance has stateful migration enabled.
+		if !shared.IsTrue(d.expandedConfig["migration.stateful"]) {
+			return fmt.Errorf("Stateful snapshot requires migration.stateful to be set to true")
+		}
 
-	if len(bootIndexes) > 0 {
-		bootIndex, found := bootIndexes[devName]
-		if found {
-			qemuDev["bootindex"] = strconv.Itoa(bootIndex)
+		// Quick checks.
+		if !d.IsRunning() {
+			return fmt.Errorf("Unable to create a stateful snapshot. The instance isn't running")
 		}
-	}
 
-	// Detect MACVTAP interface types and figure out which tap device is being used.
-	// This is so we can open a file handle to the tap device and pass it to the qemu process.
-	if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/macvtap", nicName)) {
-		content, err := ioutil.ReadFile(fmt.Sprintf("/sys/class/net/%s/ifindex", nicName))
+		// Connect to the monitor.
+		monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
 		if err != nil {
-			return nil, errors.Wrapf(err, "Error getting tap device ifindex")
+			return err
 		}
 
-		ifindex, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		// Resume the VM once the state and disks have been saved (or if saving the state fails).
+		defer monitor.Start()
+
+		// Dump the state.
+		err = d.saveState(monitor)
 		if err != nil {
-			return nil, errors.Wrapf(err, "Error parsing tap device ifindex")
+			return errors.Wrapf(err, "Failed saving instance state")
 		}
 
-		qemuNetDev = map[string]interface{}{
-			"id":    fmt.Sprintf("%s%s", qemuNetDevIDPrefix, devName),
-			"type":  "tap",
-			"vhost": true,
-			"fd":    fmt.Sprintf("/dev/tap%d", ifindex), // Indicates the file to open and the FD name.
-		}
+		// Remove the state from the main volume.
+		defer os.Remove(d.StatePath())
+	}
 
-		if shared.StringInSlice(busName, []string{"pcie", "pci"}) {
-			qemuDev["driver"] = "virtio-net-pci"
-		} else if busName == "ccw" {
-			qemuDev["driver"] = "virtio-net-ccw"
-		}
+	err := d.snapshotCommon(d, name, expiry, stateful)
+	if err != nil {
+		return err
+	}
 
-		qemuDev["netdev"] = qemuNetDev["id"].(string)
-		qemuDev["mac"] = devHwaddr
-	} else if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/tun_flags", nicName)) {
-		// Detect TAP (via TUN driver) device.
-		qemuNetDev = map[string]interface{}{
-			"id":         fmt.Sprintf("%s%s", qemuNetDevIDPrefix, devName),
-			"type":       "tap",
-			"vhost":      true,
-			"script":     "no",
-			"downscript": "no",
-			"ifname":     nicName,
+	// Record the consistency achieved in the snapshot config.
+	if consistency != "" {
+		snap, err := instance.LoadByProjectAndName(d.state, d.project, d.name+shared.SnapshotDelimiter+name)
+		if err != nil {
+			return err
 		}
 
-		// Number of queues is the same as number of vCPUs. Run with a minimum of two queues.
-		queueCount := cpuCount
-		if queueCount < 2 {
-			queueCount = 2
+		err = snap.VolatileSet(map[string]string{"volatile.snapshot.consistency": consistency})
+		if err != nil {
+			return err
 		}
+	}
 
-		if queueCount > 0 {
-			qemuNetDev["queues"] = queueCount
-		}
+	return nil
+}
 
-		if shared.StringInSlice(busName, []string{"pcie", "pci"}) {
-			qemuDev["driver"] = "virtio-net-pci"
-		} else if busName == "ccw" {
-			qemuDev["driver"] = "virtio-net-ccw"
-		}
+// freezeFilesystems freezes the guest filesystems through the lxd-agent if snapshots.consistency is set to
+// filesystem. It returns the consistency achieved along with a function which thaws the filesystems again.
+// Failing to freeze isn't fatal, the caller then simply gets a crash consistent copy of the disks.
+func (d *qemu) freezeFilesystems(timeout time.Duration) (string, func()) {
+	if d.expandedConfig["snapshots.consistency"] != "filesystem" {
+		return "crash", func() {}
+	}
 
-		// Number of vectors is number of vCPUs * 2 (RX/TX) + 2 (config/control MSI-X).
-		vectors := 2*queueCount + 2
-		if vectors > 0 {
-			qemuDev["mq"] = "on"
-			if shared.StringInSlice(busName, []string{"pcie", "pci"}) {
-				qemuDev["vectors"] = strconv.Itoa(vectors)
-			}
-		}
+	err := d.agentFreezeFilesystems(true, timeout)
+	if err != nil {
+		d.logger.Warn("Failed freezing guest filesystems, falling back to crash consistency", log.Ctx{"err": err})
+		return "crash", func() {}
+	}
 
-		qemuDev["netdev"] = qemuNetDev["id"].(string)
-		qemuDev["mac"] = devHwaddr
-	} else if pciSlotName != "" {
-		// Detect physical passthrough device.
-		if shared.StringInSlice(busName, []string{"pcie", "pci"}) {
-			qemuDev["driver"] = "vfio-pci"
-		} else if busName == "ccw" {
-			qemuDev["driver"] = "vfio-ccw"
+	return "filesystem", func() {
+		err := d.agentFreezeFilesystems(false, 0)
+		if err != nil {
+			d.logger.Error("Failed thawing guest filesystems", log.Ctx{"err": err})
 		}
+	}
+}
 
-		qemuDev["host"] = pciSlotName
+// agentFreezeFilesystems asks the lxd-agent to freeze or thaw the guest filesystems.
+func (d *qemu) agentFreezeFilesystems(frozen bool, timeout time.Duration) error {
+	// Check if the agent is running.
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
+	if err != nil {
+		return err
+	}
 
-		if d.state.OS.UnprivUser != "" {
-			if pciIOMMUGroup == "" {
-				return nil, fmt.Errorf("No PCI IOMMU group supplied")
-			}
+	if !monitor.AgentReady() {
+		return errQemuAgentOffline
+	}
 
-			vfioGroupFile := fmt.Sprintf("/dev/vfio/%s", pciIOMMUGroup)
-			err := os.Chown(vfioGroupFile, int(d.state.OS.UnprivUID), -1)
-			if err != nil {
-				return nil, errors.Wrapf(err, "Failed to chown vfio group device %q", vfioGroupFile)
-			}
-			revert.Add(func() { os.Chown(vfioGroupFile, 0, -1) })
-		}
+	client, err := d.getAgentClient()
+	if err != nil {
+		return err
 	}
 
-	if qemuDev["driver"] != "" {
-		// Return a monitor hook to add the NIC via QMP before the VM is started.
-		monHook := func(m *qmp.Monitor) error {
-			if fd, found := qemuNetDev["fd"]; found {
-				fileName := fd.(string)
+	agent, err := lxd.ConnectLXDHTTP(nil, client)
+	if err != nil {
+		return errors.Wrapf(err, "Failed connecting to agent")
+	}
+	defer agent.Disconnect()
 
-				f, err := os.OpenFile(fileName, os.O_RDWR, 0)
-				if err != nil {
-					return errors.Wrapf(err, "Error opening exta file %q", fileName)
-				}
-				defer f.Close() // Close file after device has been added.
+	req := api.InstanceFilesystemsFreezePut{
+		Frozen:  frozen,
+		Timeout: int(timeout / time.Second),
+	}
+
+	_, _, err = agent.RawQuery("PUT", "/1.0/freeze", req, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Restore restores an instance snapshot.
+func (d *qemu) Restore(source instance.Instance, stateful bool) error {
+	op, err := operationlock.Create(d.Project(), d.Name(), operationlock.ActionRestore, false, false)
+	if err != nil {
+		return errors.Wrap(err, "Create restore operation")
+	}
+	defer op.Done(nil)
+
+	var ctxMap log.Ctx
+
+	// Check the snapshot has state to restore.
+	if stateful && !source.IsStateful() {
+		err = fmt.Errorf("Stateful snapshot restore requested but snapshot is stateless")
+		op.Done(err)
+		return err
+	}
+
+	// Stop the instance.
+	wasRunning := false
+	if d.IsRunning() {
+		wasRunning = true
 
-				err = m.SendFile(fileName, f)
-				if err != nil {
-					return errors.Wrapf(err, "Error sending exta file %q", fileName)
-				}
+		ephemeral := d.IsEphemeral()
+		if ephemeral {
+			// Unset ephemeral flag.
+			args := db.InstanceArgs{
+				Architecture: d.Architecture(),
+				Config:       d.LocalConfig(),
+				Description:  d.Description(),
+				Devices:      d.LocalDevices(),
+				Ephemeral:    false,
+				Profiles:     d.Profiles(),
+				Project:      d.Project(),
+				Type:         d.Type(),
+				Snapshot:     d.IsSnapshot(),
 			}
 
-			err := m.AddNIC(qemuNetDev, qemuDev)
+			err := d.Update(args, false)
 			if err != nil {
-				return errors.Wrapf(err, "Failed setting up device %v", devName)
+				op.Done(err)
+				return err
 			}
 
-			return nil
+			// On function return, set the flag back on.
+			defer func() {
+				args.Ephemeral = ephemeral
+				d.Update(args, false)
+			}()
 		}
 
-		revert.Success()
-		return monHook, nil
-	}
-
-	return nil, fmt.Errorf("Unrecognised device type")
-}
+		// This will unmount the instance storage.
+		err := d.Stop(false)
+		if err != nil {
+			op.Done(err)
+			return err
+		}
 
-// addPCIDevConfig adds the qemu config required for adding a raw PCI device.
-func (d *qemu) addPCIDevConfig(sb *strings.Builder, bus *qemuBus, pciConfig []deviceConfig.RunConfigItem) error {
-	var devName, pciSlotName string
-	for _, pciItem := range pciConfig {
-		if pciItem.Key == "devName" {
-			devName = pciItem.Value
-		} else if pciItem.Key == "pciSlotName" {
-			pciSlotName = pciItem.Value
+		// Refresh the operation as that one is now complete.
+		op, err = operationlock.Create(d.Project(), d.Name(), operationlock.ActionRestore, false, false)
+		if err != nil {
+			return errors.Wrap(err, "Create restore operation")
 		}
+		defer op.Done(nil)
+
 	}
 
-	devBus, devAddr, multi := bus.allocate(fmt.Sprintf("lxd_%s", devName))
-	tplFields := map[string]interface{}{
-		"bus":           bus.name,
-		"devBus":        devBus,
-		"devAddr":       devAddr,
-		"multifunction": multi,
+	ctxMap = log.Ctx{
+		"created":   d.creationDate,
+		"ephemeral": d.ephemeral,
+		"used":      d.lastUsedDate,
+		"source":    source.Name()}
 
-		"devName":     devName,
-		"pciSlotName": pciSlotName,
+	d.logger.Info("Restoring instance", ctxMap)
+
+	// Load the storage driver.
+	pool, err := storagePools.GetPoolByInstance(d.state, d)
+	if err != nil {
+		op.Done(err)
+		return err
 	}
 
-	return qemuPCIPhysical.Execute(sb, tplFields)
-}
+	// Restore the rootfs.
+	err = pool.RestoreInstanceSnapshot(d, source, nil)
+	if err != nil {
+		op.Done(err)
+		return err
+	}
 
-// addGPUDevConfig adds the qemu config required for adding a GPU device.
-func (d *qemu) addGPUDevConfig(sb *strings.Builder, bus *qemuBus, gpuConfig []deviceConfig.RunConfigItem) error {
-	var devName, pciSlotName, vgpu string
-	for _, gpuItem := range gpuConfig {
-		if gpuItem.Key == "devName" {
-			devName = gpuItem.Value
-		} else if gpuItem.Key == "pciSlotName" {
-			pciSlotName = gpuItem.Value
-		} else if gpuItem.Key == "vgpu" {
-			vgpu = gpuItem.Value
+	// Restore the configuration (the snapshot consistency only applies to the snapshot itself).
+	config := make(map[string]string, len(source.LocalConfig()))
+	for k, v := range source.LocalConfig() {
+		if k == "volatile.snapshot.consistency" {
+			continue
 		}
+
+		config[k] = v
 	}
 
-	vgaMode := func() bool {
-		// No VGA mode on non-x86.
-		if d.architecture != osarch.ARCH_64BIT_INTEL_X86 {
-			return false
-		}
+	args := db.InstanceArgs{
+		Architecture: source.Architecture(),
+		Config:       config,
+		Description:  source.Description(),
+		Devices:      source.LocalDevices(),
+		Ephemeral:    source.IsEphemeral(),
+		Profiles:     source.Profiles(),
+		Project:      source.Project(),
+		Type:         source.Type(),
+		Snapshot:     source.IsSnapshot(),
+	}
 
-		// Only enable if present on the card.
-		if !shared.PathExists(filepath.Join("/sys/bus/pci/devices", pciSlotName, "boot_vga")) {
-			return false
-		}
+	// Don't pass as user-requested as there's no way to fix a bad config.
+	// This will call d.UpdateBackupFile() to ensure snapshot list is up to date.
+	err = d.Update(args, false)
+	if err != nil {
+		op.Done(err)
+		return err
+	}
+	d.stateful = stateful
 
-		// Skip SRIOV VFs as those are shared with the host card.
-		if shared.PathExists(filepath.Join("/sys/bus/pci/devices", pciSlotName, "physfn")) {
-			return false
+	// Restart the instance.
+	if wasRunning || stateful {
+		d.logger.Debug("Starting instance after snapshot restore")
+		err := d.Start(stateful)
+		if err != nil {
+			op.Done(err)
+			return err
 		}
+	}
 
-		return true
-	}()
+	d.state.Events.SendLifecycle(d.project, lifecycle.InstanceRestored.Event(d, map[string]interface{}{"snapshot": source.Name()}))
+	d.logger.Info("Restored instance", ctxMap)
+	return nil
+}
 
-	devBus, devAddr, multi := bus.allocate(fmt.Sprintf("lxd_%s", devName))
-	tplFields := map[string]interface{}{
-		"bus":           bus.name,
-		"devBus":        devBus,
-		"devAddr":       devAddr,
-		"multifunction": multi,
+// Rename the instance. Accepts an argument to enable applying deferred TemplateTriggerRename.
+func (d *qemu) Rename(newName string, applyTemplateTrigger bool) error {
+	oldName := d.Name()
+	ctxMap := log.Ctx{
+		"created":   d.creationDate,
+		"ephemeral": d.ephemeral,
+		"used":      d.lastUsedDate,
+		"newname":   newName}
 
-		"devName":     devName,
-		"pciSlotName": pciSlotName,
-		"vga":         vgaMode,
-		"vgpu":        vgpu,
-	}
+	d.logger.Info("Renaming instance", ctxMap)
 
-	// Add main GPU device in VGA mode to qemu config.
-	err := qemuGPUDevPhysical.Execute(sb, tplFields)
+	// Quick checks.
+	err := instance.ValidName(newName, d.IsSnapshot())
 	if err != nil {
 		return err
 	}
 
-	var iommuGroupPath string
+	if d.IsRunning() {
+		return fmt.Errorf("Renaming of running instance not allowed")
+	}
 
-	if vgpu != "" {
-		iommuGroupPath = filepath.Join("/sys/bus/mdev/devices", vgpu, "iommu_group", "devices")
-	} else {
-		// Add any other related IOMMU VFs as generic PCI devices.
-		iommuGroupPath = filepath.Join("/sys/bus/pci/devices", pciSlotName, "iommu_group", "devices")
+	// Clean things up.
+	d.cleanup()
+
+	pool, err := storagePools.GetPoolByInstance(d.state, d)
+	if err != nil {
+		return errors.Wrap(err, "Failed loading instance storage pool")
 	}
 
-	if shared.PathExists(iommuGroupPath) {
-		// Extract parent slot name by removing any virtual function ID.
-		parts := strings.SplitN(pciSlotName, ".", 2)
-		prefix := parts[0]
+	if d.IsSnapshot() {
+		_, newSnapName, _ := shared.InstanceGetParentAndSnapshotName(newName)
+		err = pool.RenameInstanceSnapshot(d, newSnapName, nil)
+		if err != nil {
+			return errors.Wrap(err, "Rename instance snapshot")
+		}
+	} else {
+		err = pool.RenameInstance(d, newName, nil)
+		if err != nil {
+			return errors.Wrap(err, "Rename instance")
+		}
 
-		// Iterate the members of the IOMMU group and override any that match the parent slot name prefix.
-		err := filepath.Walk(iommuGroupPath, func(path string, _ os.FileInfo, err error) error {
+		if applyTemplateTrigger {
+			err = d.DeferTemplateApply(instance.TemplateTriggerRename)
 			if err != nil {
 				return err
 			}
+		}
+	}
 
-			iommuSlotName := filepath.Base(path) // Virtual function's address is dir name.
+	if !d.IsSnapshot() {
+		// Rename all the instance snapshot database entries.
+		results, err := d.state.Cluster.GetInstanceSnapshotsNames(d.project, oldName)
+		if err != nil {
+			d.logger.Error("Failed to get instance snapshots", ctxMap)
+			return errors.Wrapf(err, "Failed to get instance snapshots")
+		}
 
-			// Match any VFs that are related to the GPU device (but not the GPU device itself).
-			if strings.HasPrefix(iommuSlotName, prefix) && iommuSlotName != pciSlotName {
-				// Add VF device without VGA mode to qemu config.
-				devBus, devAddr, multi := bus.allocate(fmt.Sprintf("lxd_%s", devName))
-				tplFields := map[string]interface{}{
-					"bus":           bus.name,
-					"devBus":        devBus,
-					"devAddr":       devAddr,
-					"multifunction": multi,
+		for _, sname := range results {
+			// Rename the snapshot.
+			oldSnapName := strings.SplitN(sname, shared.SnapshotDelimiter, 2)[1]
+			baseSnapName := filepath.Base(sname)
+			err := d.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+				return tx.RenameInstanceSnapshot(d.project, oldName, oldSnapName, baseSnapName)
+			})
+			if err != nil {
+				d.logger.Error("Failed renaming snapshot", ctxMap)
+				return err
+			}
+		}
+	}
 
-					// Generate associated device name by combining main device name and VF ID.
-					"devName":     fmt.Sprintf("%s_%s", devName, devAddr),
-					"pciSlotName": iommuSlotName,
-					"vga":         false,
-					"vgpu":        "",
-				}
+	// Rename the instance database entry.
+	err = d.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		if d.IsSnapshot() {
+			oldParts := strings.SplitN(oldName, shared.SnapshotDelimiter, 2)
+			newParts := strings.SplitN(newName, shared.SnapshotDelimiter, 2)
+			return tx.RenameInstanceSnapshot(d.project, oldParts[0], oldParts[1], newParts[1])
+		}
 
-				err := qemuGPUDevPhysical.Execute(sb, tplFields)
-				if err != nil {
-					return err
-				}
-			}
+		return tx.RenameInstance(d.project, oldName, newName)
+	})
+	if err != nil {
+		d.logger.Error("Failed renaming instance", ctxMap)
+		return err
+	}
+
+	// Rename the logging path.
+	newFullName := project.Instance(d.Project(), d.Name())
+	os.RemoveAll(shared.LogPath(newFullName))
+	if shared.PathExists(d.LogPath()) {
+		err := os.Rename(d.LogPath(), shared.LogPath(newFullName))
+		if err != nil {
+			d.logger.Error("Failed renaming instance", ctxMap)
+			return err
+		}
+	}
 
-			return nil
-		})
+	// Rename the MAAS entry.
+	if !d.IsSnapshot() {
+		err = d.maasRename(d, newName)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
-}
+	revert := revert.New()
+	defer revert.Fail()
 
-func (d *qemu) addUSBDeviceConfig(sb *strings.Builder, bus *qemuBus, usbDev deviceConfig.USBDeviceItem) error {
-	tplFields := map[string]interface{}{
-		"hostDevice": usbDev.HostDevicePath,
-		"devName":    usbDev.DeviceName,
-	}
+	// Set the new name in the struct.
+	d.name = newName
+	revert.Add(func() { d.name = oldName })
 
-	err := qemuUSBDev.Execute(sb, tplFields)
+	// Rename the backups.
+	backups, err := d.Backups()
 	if err != nil {
 		return err
 	}
 
-	// Add path to external devPaths. This way, the path will be included in the apparmor profile.
-	d.devPaths = append(d.devPaths, usbDev.HostDevicePath)
-
-	return nil
-}
-
-func (d *qemu) addTPMDeviceConfig(sb *strings.Builder, tpmConfig []deviceConfig.RunConfigItem) error {
-	var devName, socketPath string
+	for _, backup := range backups {
+		b := backup
+		oldName := b.Name()
+		backupName := strings.Split(oldName, "/")[1]
+		newName := fmt.Sprintf("%s/%s", newName, backupName)
 
-	for _, tpmItem := range tpmConfig {
-		if tpmItem.Key == "path" {
-			socketPath = tpmItem.Value
-		} else if tpmItem.Key == "devName" {
-			devName = tpmItem.Value
+		err = b.Rename(newName)
+		if err != nil {
+			return err
 		}
-	}
 
-	tplFields := map[string]interface{}{
-		"devName": devName,
-		"path":    socketPath,
+		revert.Add(func() { b.Rename(oldName) })
 	}
 
-	err := qemuTPM.Execute(sb, tplFields)
+	// Update lease files.
+	network.UpdateDNSMasqStatic(d.state, "")
+
+	err = d.UpdateBackupFile()
 	if err != nil {
 		return err
 	}
 
+	d.logger.Info("Renamed instance", ctxMap)
+
+	if d.snapshot {
+		d.state.Events.SendLifecycle(d.project, lifecycle.InstanceSnapshotRenamed.Event(d, map[string]interface{}{"old_name": oldName}))
+	} else {
+		d.state.Events.SendLifecycle(d.project, lifecycle.InstanceRenamed.Event(d, map[string]interface{}{"old_name": oldName}))
+	}
+
+	revert.Success()
 	return nil
 }
 
-// pidFilePath returns the path where the qemu process should write its PID.
-func (d *qemu) pidFilePath() string {
-	return filepath.Join(d.LogPath(), "qemu.pid")
-}
+// Update the instance config.
+func (d *qemu) Update(args db.InstanceArgs, userRequested bool) error {
+	revert := revert.New()
+	defer revert.Fail()
 
-// pid gets the PID of the running qemu process. Returns 0 if PID file or process not found, and -1 if err non-nil.
-func (d *qemu) pid() (int, error) {
-	pidStr, err := ioutil.ReadFile(d.pidFilePath())
-	if os.IsNotExist(err) {
-		return 0, nil // PID file has gone.
+	// Set sane defaults for unset keys.
+	if args.Project == "" {
+		args.Project = project.Default
 	}
 
-	if err != nil {
-		return -1, err
+	if args.Architecture == 0 {
+		args.Architecture = d.architecture
 	}
 
-	pid, err := strconv.Atoi(strings.TrimSpace(string(pidStr)))
-	if err != nil {
-		return -1, err
+	if args.Config == nil {
+		args.Config = map[string]string{}
 	}
 
-	cmdLineProcFilePath := fmt.Sprintf("/proc/%d/cmdline", pid)
-	cmdLine, err := ioutil.ReadFile(cmdLineProcFilePath)
-	if err != nil {
-		return 0, nil // Process has gone.
+	if args.Devices == nil {
+		args.Devices = deviceConfig.Devices{}
 	}
 
-	qemuSearchString := []byte("qemu-system")
-	instUUID := []byte(d.localConfig["volatile.uuid"])
-	if !bytes.Contains(cmdLine, qemuSearchString) || !bytes.Contains(cmdLine, instUUID) {
-		return -1, fmt.Errorf("PID doesn't match the running process")
+	if args.Profiles == nil {
+		args.Profiles = []string{}
 	}
 
-	return pid, nil
-}
-
-// forceStop kills the QEMU prorcess if running, performs normal device & operation cleanup and sends stop
-// lifecycle event.
-func (d *qemu) forceStop() error {
-	pid, _ := d.pid()
-	if pid > 0 {
-		err := d.killQemuProcess(pid)
+	if userRequested {
+		// Validate the new config.
+		err := instance.ValidConfig(d.state.OS, args.Config, false, d.dbType)
 		if err != nil {
-			return errors.Wrapf(err, "Failed to stop VM process %d", pid)
+			return errors.Wrap(err, "Invalid config")
 		}
 
-		// Wait for QEMU process to exit and perform device cleanup.
-		err = d.onStop("stop")
+		// Validate the new devices without using expanded devices validation (expensive checks disabled).
+		err = instance.ValidDevices(d.state, d.state.Cluster, d.Project(), d.Type(), args.Devices, false)
 		if err != nil {
-			return err
+			return errors.Wrap(err, "Invalid devices")
 		}
+	}
 
-		d.state.Events.SendLifecycle(d.project, lifecycle.InstanceStopped.Event(d, nil))
+	// Validate the new profiles.
+	profiles, err := d.state.Cluster.GetProfileNames(args.Project)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get profiles")
 	}
 
-	return nil
-}
+	checkedProfiles := []string{}
+	for _, profile := range args.Profiles {
+		if !shared.StringInSlice(profile, profiles) {
+			return fmt.Errorf("Requested profile '%s' doesn't exist", profile)
+		}
 
-// Stop the VM.
-func (d *qemu) Stop(stateful bool) error {
-	d.logger.Debug("Stop started", log.Ctx{"stateful": stateful})
-	defer d.logger.Debug("Stop finished", log.Ctx{"stateful": stateful})
+		if shared.StringInSlice(profile, checkedProfiles) {
+			return fmt.Errorf("Duplicate profile found in request")
+		}
 
-	// Must be run prior to creating the operation lock.
-	// Allow stop to proceed if statusCode is Error as we may need to forcefully kill the QEMU process.
-	statusCode := d.statusCode()
-	if !d.isRunningStatusCode(statusCode) && statusCode != api.Error {
-		return ErrInstanceIsStopped
+		checkedProfiles = append(checkedProfiles, profile)
 	}
 
-	// Check for stateful.
-	if stateful && !shared.IsTrue(d.expandedConfig["migration.stateful"]) {
-		return fmt.Errorf("Stateful stop requires migration.stateful to be set to true")
+	// Validate the new architecture.
+	if args.Architecture != 0 {
+		_, err = osarch.ArchitectureName(args.Architecture)
+		if err != nil {
+			return fmt.Errorf("Invalid architecture ID: %s", err)
+		}
 	}
 
-	// Setup a new operation.
-	// Allow inheriting of ongoing restart or restore operation (we are called from restartCommon and Restore).
-	// Don't allow reuse when creating a new stop operation. This prevents other operations from intefering.
-	// Allow reuse of a reusable ongoing stop operation as Shutdown() may be called first, which allows reuse
-	// of its operations. This allow for Stop() to inherit from Shutdown() where instance is stuck.
-	op, err := operationlock.CreateWaitGet(d.Project(), d.Name(), operationlock.ActionStop, []operationlock.Action{operationlock.ActionRestart, operationlock.ActionRestore}, false, true)
+	// Get a copy of the old configuration.
+	oldDescription := d.Description()
+	oldArchitecture := 0
+	err = shared.DeepCopy(&d.architecture, &oldArchitecture)
 	if err != nil {
-		if errors.Is(err, operationlock.ErrNonReusuableSucceeded) {
-			// An existing matching operation has now succeeded, return.
-			return nil
-		}
+		return err
+	}
 
+	oldEphemeral := false
+	err = shared.DeepCopy(&d.ephemeral, &oldEphemeral)
+	if err != nil {
 		return err
 	}
 
-	// Connect to the monitor.
-	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
+	oldExpandedDevices := deviceConfig.Devices{}
+	err = shared.DeepCopy(&d.expandedDevices, &oldExpandedDevices)
+	if err != nil {
+		return err
+	}
+
+	oldExpandedConfig := map[string]string{}
+	err = shared.DeepCopy(&d.expandedConfig, &oldExpandedConfig)
+	if err != nil {
+		return err
+	}
+
+	oldLocalDevices := deviceConfig.Devices{}
+	err = shared.DeepCopy(&d.localDevices, &oldLocalDevices)
+	if err != nil {
+		return err
+	}
+
+	oldLocalConfig := map[string]string{}
+	err = shared.DeepCopy(&d.localConfig, &oldLocalConfig)
+	if err != nil {
+		return err
+	}
+
+	oldProfiles := []string{}
+	err = shared.DeepCopy(&d.profiles, &oldProfiles)
 	if err != nil {
-		// If we fail to connect, it's most likely because the VM is already off, but it could also be
-		// because the qemu process is not responding, check if process still exists and kill it if needed.
-		err = d.forceStop()
-		op.Done(err)
 		return err
 	}
 
-	// Get the wait channel.
-	chDisconnect, err := monitor.Wait()
-	if err != nil {
-		if err == qmp.ErrMonitorDisconnect {
-			// If we fail to wait, it's most likely because the VM is already off, but it could also be
-			// because the qemu process is not responding, check if process still exists and kill it if
-			// needed.
-			err = d.forceStop()
-			op.Done(err)
-			return err
+	oldExpiryDate := d.expiryDate
+
+	// Revert local changes if update fails.
+	revert.Add(func() {
+		d.description = oldDescription
+		d.architecture = oldArchitecture
+		d.ephemeral = oldEphemeral
+		d.expandedConfig = oldExpandedConfig
+		d.expandedDevices = oldExpandedDevices
+		d.localConfig = oldLocalConfig
+		d.localDevices = oldLocalDevices
+		d.profiles = oldProfiles
+		d.expiryDate = oldExpiryDate
+	})
+
+	// Apply the various changes to local vars.
+	d.description = args.Description
+	d.architecture = args.Architecture
+	d.ephemeral = args.Ephemeral
+	d.localConfig = args.Config
+	d.localDevices = args.Devices
+	d.profiles = args.Profiles
+	d.expiryDate = args.ExpiryDate
+
+	// Expand the config.
+	err = d.expandConfig(nil)
+	if err != nil {
+		return errors.Wrap(err, "Expand config")
+	}
+
+	err = d.expandDevices(nil)
+	if err != nil {
+		return errors.Wrap(err, "Expand devices")
+	}
+
+	// Diff the configurations.
+	changedConfig := []string{}
+	for key := range oldExpandedConfig {
+		if oldExpandedConfig[key] != d.expandedConfig[key] {
+			if !shared.StringInSlice(key, changedConfig) {
+				changedConfig = append(changedConfig, key)
+			}
+		}
+	}
+
+	for key := range d.expandedConfig {
+		if oldExpandedConfig[key] != d.expandedConfig[key] {
+			if !shared.StringInSlice(key, changedConfig) {
+				changedConfig = append(changedConfig, key)
+			}
 		}
-
-		op.Done(err)
-		return err
 	}
 
-	// Handle stateful stop.
-	if stateful {
-		// Dump the state.
-		err := d.saveState(monitor)
+	// Diff the devices.
+	removeDevices, addDevices, updateDevices, allUpdatedKeys := oldExpandedDevices.Update(d.expandedDevices, func(oldDevice deviceConfig.Device, newDevice deviceConfig.Device) []string {
+		// This function needs to return a list of fields that are excluded from differences
+		// between oldDevice and newDevice. The result of this is that as long as the
+		// devices are otherwise identical except for the fields returned here, then the
+		// device is considered to be being "updated" rather than "added & removed".
+		oldDevType, err := device.LoadByType(d.state, d.Project(), oldDevice)
 		if err != nil {
-			op.Done(err)
-			return err
+			return []string{} // Couldn't create Device, so this cannot be an update.
 		}
 
-		// Reset the timer.
-		op.Reset()
+		newDevType, err := device.LoadByType(d.state, d.Project(), newDevice)
+		if err != nil {
+			return []string{} // Couldn't create Device, so this cannot be an update.
+		}
 
-		// Mark the instance as having state.
-		d.stateful = true
-		err = d.state.Cluster.UpdateInstanceStatefulFlag(d.id, true)
+		return newDevType.UpdatableFields(oldDevType)
+	})
+
+	if userRequested {
+		// Do some validation of the config diff (allows mixed instance types for profiles).
+		err = instance.ValidConfig(d.state.OS, d.expandedConfig, true, instancetype.Any)
 		if err != nil {
-			op.Done(err)
-			return err
+			return errors.Wrap(err, "Invalid expanded config")
+		}
+
+		// Do full expanded validation of the devices diff.
+		err = instance.ValidDevices(d.state, d.state.Cluster, d.Project(), d.Type(), d.expandedDevices, true)
+		if err != nil {
+			return errors.Wrap(err, "Invalid expanded devices")
 		}
 	}
 
-	// Send the quit command.
-	err = monitor.Quit()
-	if err != nil {
-		if err == qmp.ErrMonitorDisconnect {
-			op.Done(nil)
-			return nil
+	// If apparmor changed, re-validate the apparmor profile (even if not running).
+	if shared.StringInSlice("raw.apparmor", changedConfig) {
+		err = apparmor.InstanceValidate(d.state, d)
+		if err != nil {
+			return errors.Wrap(err, "Parse AppArmor profile")
 		}
+	}
 
-		op.Done(err)
+	isRunning := d.IsRunning()
+
+	// Use the device interface to apply update changes.
+	err = d.updateDevices(removeDevices, addDevices, updateDevices, oldExpandedDevices, isRunning, userRequested)
+	if err != nil {
 		return err
 	}
 
-	// Wait for QEMU to exit (can take a while if pending I/O).
-	<-chDisconnect
+	if isRunning {
+		// Only certain keys can be changed on a running VM.
+		liveUpdateKeys := []string{"limits.memory"}
 
-	// Wait for operation lock to be Done. This is normally completed by onStop which picks up the same
-	// operation lock and then marks it as Done after the instance stops and the devices have been cleaned up.
-	// However if the operation has failed for another reason we will collect the error here.
-	err = op.Wait()
-	status := d.statusCode()
-	if status != api.Stopped {
-		errPrefix := fmt.Errorf("Failed stopping instance, status is %q", status)
+		// The number of vCPUs can be changed if it was and still is allowed to be hotplugged.
+		if d.cpuHotplugSupported(oldExpandedConfig) && d.cpuHotplugSupported(d.expandedConfig) {
+			liveUpdateKeys = append(liveUpdateKeys, "limits.cpu")
+		}
 
-		if err != nil {
-			return fmt.Errorf("%s: %w", errPrefix.Error(), err)
+		// Check only keys that support live update have changed.
+		for _, key := range changedConfig {
+			if !strings.HasPrefix(key, "user.") && !shared.StringInSlice(key, liveUpdateKeys) {
+				return fmt.Errorf("Key %q cannot be updated when VM is running", key)
+			}
 		}
 
-		return errPrefix
-	} else if op.Action() == "stop" {
-		// If instance stopped, send lifecycle event (even if there has been an error cleaning up).
-		d.state.Events.SendLifecycle(d.project, lifecycle.InstanceStopped.Event(d, nil))
-	}
+		// Apply live update for each key.
+		for _, key := range changedConfig {
+			value := d.expandedConfig[key]
 
-	// Now handle errors from stop sequence and return to caller if wasn't completed cleanly.
-	if err != nil {
-		return err
-	}
+			if key == "limits.memory" {
+				err = d.updateMemoryLimit(value)
+				if err != nil {
+					if err != nil {
+						return errors.Wrapf(err, "Failed updating memory limit")
+					}
+				}
+			} else if key == "limits.cpu" {
+				err = d.updateCPULimit(value)
+				if err != nil {
+					return errors.Wrapf(err, "Failed updating CPU limit")
+				}
+			}
 
-	return nil
-}
+			// Request the agent onlines hotplugged vCPUs and memory.
+			if shared.StringInSlice(key, []string{"limits.cpu", "limits.memory"}) {
+				msg := map[string]string{
+					"key":       key,
+					"old_value": oldExpandedConfig[key],
+					"value":     value,
+				}
 
-// Unfreeze restores the instance to running.
-func (d *qemu) Unfreeze() error {
-	// Connect to the monitor.
-	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
-	if err != nil {
-		return err
+				err = d.devlxdEventSend("config", msg)
+				if err != nil {
+					d.logger.Warn("Failed notifying the agent of the new limit", log.Ctx{"key": key, "err": err})
+				}
+			}
+		}
 	}
 
-	// Send the cont command.
-	err = monitor.Start()
-	if err != nil {
-		return err
+	// Update MAAS (must run after the MAC addresses have been generated).
+	updateMAAS := false
+	for _, key := range []string{"maas.subnet.ipv4", "maas.subnet.ipv6", "ipv4.address", "ipv6.address"} {
+		if shared.StringInSlice(key, allUpdatedKeys) {
+			updateMAAS = true
+			break
+		}
 	}
 
-	d.state.Events.SendLifecycle(d.project, lifecycle.InstanceResumed.Event(d, nil))
-	return nil
-}
-
-// IsPrivileged does not apply to virtual machines. Always returns false.
-func (d *qemu) IsPrivileged() bool {
-	return false
-}
+	if !d.IsSnapshot() && updateMAAS {
+		err = d.maasUpdate(d, oldExpandedDevices.CloneNative())
+		if err != nil {
+			return err
+		}
+	}
 
-// Snapshot takes a new snapshot.
-func (d *qemu) Snapshot(name string, expiry time.Time, stateful bool) error {
-	// Deal with state.
-	if stateful {
-		// Confirm the instance has stateful migration enabled.
-		if !shared.IsTrue(d.expandedConfig["migration.stateful"]) {
-			return fmt.Errorf("Stateful stop requires migration.stateful to be set to true")
+	if shared.StringInSlice("security.secureboot", changedConfig) {
+		// Re-generate the NVRAM.
+		err = d.setupNvram()
+		if err != nil {
+			return err
 		}
+	}
 
-		// Quick checks.
-		if !d.IsRunning() {
-			return fmt.Errorf("Unable to create a stateful snapshot. The instance isn't running")
+	// Finally, apply the changes to the database.
+	err = d.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		// Snapshots should update only their descriptions and expiry date.
+		if d.IsSnapshot() {
+			return tx.UpdateInstanceSnapshot(d.id, d.description, d.expiryDate)
 		}
 
-		// Connect to the monitor.
-		monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
+		object, err := tx.GetInstance(d.project, d.name)
 		if err != nil {
 			return err
 		}
 
-		// Dump the state.
-		err = d.saveState(monitor)
+		object.Description = d.description
+		object.Architecture = d.architecture
+		object.Ephemeral = d.ephemeral
+		object.ExpiryDate = sql.NullTime{Time: d.expiryDate, Valid: true}
+		object.Config = d.localConfig
+		object.Profiles = d.profiles
+
+		devices, err := db.APIToDevices(d.localDevices.CloneNative())
 		if err != nil {
 			return err
 		}
 
-		// Resume the VM once the disk state has been saved.
-		defer monitor.Start()
+		object.Devices = devices
 
-		// Remove the state from the main volume.
-		defer os.Remove(d.StatePath())
+		return tx.UpdateInstance(d.project, d.name, *object)
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to update database")
 	}
 
-	return d.snapshotCommon(d, name, expiry, stateful)
-}
-
-// Restore restores an instance snapshot.
-func (d *qemu) Restore(source instance.Instance, stateful bool) error {
-	op, err := operationlock.Create(d.Project(), d.Name(), operationlock.ActionRestore, false, false)
-	if err != nil {
-		return errors.Wrap(err, "Create restore operation")
+	err = d.UpdateBackupFile()
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "Failed to write backup file")
 	}
-	defer op.Done(nil)
 
-	var ctxMap log.Ctx
+	// Changes have been applied and recorded, do not revert if an error occurs from here.
+	revert.Success()
 
-	// Stop the instance.
-	wasRunning := false
-	if d.IsRunning() {
-		wasRunning = true
+	if isRunning {
+		err = d.writeInstanceData()
+		if err != nil {
+			return errors.Wrap(err, "Failed to write instance-data file")
+		}
 
-		ephemeral := d.IsEphemeral()
-		if ephemeral {
-			// Unset ephemeral flag.
-			args := db.InstanceArgs{
-				Architecture: d.Architecture(),
-				Config:       d.LocalConfig(),
-				Description:  d.Description(),
-				Devices:      d.LocalDevices(),
-				Ephemeral:    false,
-				Profiles:     d.Profiles(),
-				Project:      d.Project(),
-				Type:         d.Type(),
-				Snapshot:     d.IsSnapshot(),
+		// Send devlxd notifications only for user.* key changes
+		for _, key := range changedConfig {
+			if !strings.HasPrefix(key, "user.") {
+				continue
 			}
 
-			err := d.Update(args, false)
-			if err != nil {
-				op.Done(err)
-				return err
+			msg := map[string]string{
+				"key":       key,
+				"old_value": oldExpandedConfig[key],
+				"value":     d.expandedConfig[key],
 			}
 
-			// On function return, set the flag back on.
-			defer func() {
-				args.Ephemeral = ephemeral
-				d.Update(args, false)
-			}()
-		}
-
-		// This will unmount the instance storage.
-		err := d.Stop(false)
-		if err != nil {
-			op.Done(err)
-			return err
+			err = d.devlxdEventSend("config", msg)
+			if err != nil {
+				return err
+			}
 		}
+	}
 
-		// Refresh the operation as that one is now complete.
-		op, err = operationlock.Create(d.Project(), d.Name(), operationlock.ActionRestore, false, false)
-		if err != nil {
-			return errors.Wrap(err, "Create restore operation")
+	if userRequested {
+		if d.snapshot {
+			d.state.Events.SendLifecycle(d.project, lifecycle.InstanceSnapshotUpdated.Event(d, nil))
+		} else {
+			d.state.Events.SendLifecycle(d.project, lifecycle.InstanceUpdated.Event(d, nil))
 		}
-		defer op.Done(nil)
+	}
+
+	return nil
+}
 
+// updateMemoryLimit live updates the VM's memory limit by reszing the balloon device.
+func (d *qemu) updateMemoryLimit(newLimit string) error {
+	if newLimit == "" {
+		return nil
 	}
 
-	ctxMap = log.Ctx{
-		"created":   d.creationDate,
-		"ephemeral": d.ephemeral,
-		"used":      d.lastUsedDate,
-		"source":    source.Name()}
+	if shared.IsTrue(d.expandedConfig["limits.memory.hugepages"]) {
+		return fmt.Errorf("Cannot live update memory limit when using huge pages")
+	}
 
-	d.logger.Info("Restoring instance", ctxMap)
+	// Check new size string is valid and convert to bytes.
+	newSizeBytes, err := units.ParseByteSizeString(newLimit)
+	if err != nil {
+		return errors.Wrapf(err, "Invalid memory size")
+	}
+	newSizeMB := newSizeBytes / 1024 / 1024
 
-	// Load the storage driver.
-	pool, err := storagePools.GetPoolByInstance(d.state, d)
+	// Connect to the monitor.
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
 	if err != nil {
-		op.Done(err)
-		return err
+		return err // The VM isn't running as no monitor socket available.
 	}
 
-	// Restore the rootfs.
-	err = pool.RestoreInstanceSnapshot(d, source, nil)
+	baseSizeBytes, err := monitor.GetMemorySizeBytes()
 	if err != nil {
-		op.Done(err)
 		return err
 	}
+	baseSizeMB := baseSizeBytes / 1024 / 1024
 
-	// Restore the configuration.
-	args := db.InstanceArgs{
-		Architecture: source.Architecture(),
-		Config:       source.LocalConfig(),
-		Description:  source.Description(),
-		Devices:      source.LocalDevices(),
-		Ephemeral:    source.IsEphemeral(),
-		Profiles:     source.Profiles(),
-		Project:      source.Project(),
-		Type:         source.Type(),
-		Snapshot:     source.IsSnapshot(),
+	pluggedSizeBytes, err := monitor.GetPluggedMemorySizeBytes()
+	if err != nil {
+		return err
 	}
+	totalSizeMB := baseSizeMB + pluggedSizeBytes/1024/1024
 
-	// Don't pass as user-requested as there's no way to fix a bad config.
-	// This will call d.UpdateBackupFile() to ensure snapshot list is up to date.
-	err = d.Update(args, false)
+	curSizeBytes, err := monitor.GetMemoryBalloonSizeBytes()
 	if err != nil {
-		op.Done(err)
 		return err
 	}
-	d.stateful = stateful
+	curSizeMB := curSizeBytes / 1024 / 1024
 
-	// Restart the instance.
-	if wasRunning || stateful {
-		d.logger.Debug("Starting instance after snapshot restore")
-		err := d.Start(stateful)
+	if curSizeMB == newSizeMB {
+		return nil
+	} else if totalSizeMB < newSizeMB {
+		if d.architecture != osarch.ARCH_64BIT_INTEL_X86 {
+			return fmt.Errorf("Cannot increase memory size beyond boot time size when VM is running (Boot time size %dMiB, new size %dMiB)", baseSizeMB, newSizeMB)
+		}
+
+		maxSizeMB := d.memMaxSizeMB(baseSizeMB)
+		if newSizeMB > maxSizeMB {
+			return fmt.Errorf("Cannot increase memory size beyond %dMiB when VM is running (new size %dMiB)", maxSizeMB, newSizeMB)
+		}
+
+		// Hotplug the missing memory, the balloon then takes care of any extra memory.
+		err = d.hotplugMemory(monitor, newSizeMB-totalSizeMB)
 		if err != nil {
-			op.Done(err)
 			return err
 		}
 	}
 
-	d.state.Events.SendLifecycle(d.project, lifecycle.InstanceRestored.Event(d, map[string]interface{}{"snapshot": source.Name()}))
-	d.logger.Info("Restored instance", ctxMap)
-	return nil
-}
-
-// Rename the instance. Accepts an argument to enable applying deferred TemplateTriggerRename.
-func (d *qemu) Rename(newName string, applyTemplateTrigger bool) error {
-	oldName := d.Name()
-	ctxMap := log.Ctx{
-		"created":   d.creationDate,
-		"ephemeral": d.ephemeral,
-		"used":      d.lastUsedDate,
-		"newname":   newName}
-
-	d.logger.Info("Renaming instance", ctxMap)
-
-	// Quick checks.
-	err := instance.ValidName(newName, d.IsSnapshot())
+	// Set effective memory size.
+	err = monitor.SetMemoryBalloonSizeBytes(newSizeBytes)
 	if err != nil {
 		return err
 	}
 
-	if d.IsRunning() {
-		return fmt.Errorf("Renaming of running instance not allowed")
-	}
-
-	// Clean things up.
-	d.cleanup()
-
-	pool, err := storagePools.GetPoolByInstance(d.state, d)
-	if err != nil {
-		return errors.Wrap(err, "Failed loading instance storage pool")
-	}
-
-	if d.IsSnapshot() {
-		_, newSnapName, _ := shared.InstanceGetParentAndSnapshotName(newName)
-		err = pool.RenameInstanceSnapshot(d, newSnapName, nil)
-		if err != nil {
-			return errors.Wrap(err, "Rename instance snapshot")
-		}
-	} else {
-		err = pool.RenameInstance(d, newName, nil)
+	// Changing the memory balloon can take time, so poll the effectice size to check it has shrunk within 1%
+	// of the target size, which we then take as success (it may still continue to shrink closer to target).
+	for i := 0; i < 10; i++ {
+		curSizeBytes, err = monitor.GetMemoryBalloonSizeBytes()
 		if err != nil {
-			return errors.Wrap(err, "Rename instance")
+			return err
 		}
+		curSizeMB = curSizeBytes / 1024 / 1024
 
-		if applyTemplateTrigger {
-			err = d.DeferTemplateApply(instance.TemplateTriggerRename)
-			if err != nil {
-				return err
-			}
+		var diff int64
+		if curSizeMB < newSizeMB {
+			diff = newSizeMB - curSizeMB
+		} else {
+			diff = curSizeMB - newSizeMB
 		}
-	}
 
-	if !d.IsSnapshot() {
-		// Rename all the instance snapshot database entries.
-		results, err := d.state.Cluster.GetInstanceSnapshotsNames(d.project, oldName)
-		if err != nil {
-			d.logger.Error("Failed to get instance snapshots", ctxMap)
-			return errors.Wrapf(err, "Failed to get instance snapshots")
+		if diff <= (newSizeMB / 100) {
+			return nil // We reached to within 1% of our target size.
 		}
 
-		for _, sname := range results {
-			// Rename the snapshot.
-			oldSnapName := strings.SplitN(sname, shared.SnapshotDelimiter, 2)[1]
-			baseSnapName := filepath.Base(sname)
-			err := d.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
-				return tx.RenameInstanceSnapshot(d.project, oldName, oldSnapName, baseSnapName)
-			})
-			if err != nil {
-				d.logger.Error("Failed renaming snapshot", ctxMap)
-				return err
-			}
-		}
+		time.Sleep(500 * time.Millisecond)
 	}
 
-	// Rename the instance database entry.
-	err = d.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
-		if d.IsSnapshot() {
-			oldParts := strings.SplitN(oldName, shared.SnapshotDelimiter, 2)
-			newParts := strings.SplitN(newName, shared.SnapshotDelimiter, 2)
-			return tx.RenameInstanceSnapshot(d.project, oldParts[0], oldParts[1], newParts[1])
-		}
+	return fmt.Errorf("Failed setting memory to %dMiB (currently %dMiB) as it was taking too long", newSizeMB, curSizeMB)
+}
 
-		return tx.RenameInstance(d.project, oldName, newName)
-	})
-	if err != nil {
-		d.logger.Error("Failed renaming instance", ctxMap)
-		return err
-	}
+// hotplugMemory adds a memory DIMM of at least the given size to the VM and records it in volatile config so
+// that it can be recreated when the VM's state is restored.
+func (d *qemu) hotplugMemory(monitor *qmp.Monitor, sizeMB int64) error {
+	// The guest onlines memory by blocks, so make the DIMM a multiple of the block size.
+	sizeMB = ((sizeMB + qemuMemoryBlockSizeMB - 1) / qemuMemoryBlockSizeMB) * qemuMemoryBlockSizeMB
 
-	// Rename the logging path.
-	newFullName := project.Instance(d.Project(), d.Name())
-	os.RemoveAll(shared.LogPath(newFullName))
-	if shared.PathExists(d.LogPath()) {
-		err := os.Rename(d.LogPath(), shared.LogPath(newFullName))
-		if err != nil {
-			d.logger.Error("Failed renaming instance", ctxMap)
-			return err
-		}
+	// DIMMs are never unplugged, so the next free slot follows the ones already recorded.
+	dimms := util.SplitNTrimSpace(d.localConfig["volatile.memory.hotplug"], ",", -1, true)
+	if len(dimms) >= qemuMemorySlots {
+		return fmt.Errorf("No memory slots left to hotplug memory into, the VM needs restarting")
 	}
 
-	// Rename the MAAS entry.
-	if !d.IsSnapshot() {
-		err = d.maasRename(d, newName)
+	volatileSet := map[string]string{}
+	if d.localConfig["volatile.memory.base"] == "" {
+		baseSizeBytes, err := monitor.GetMemorySizeBytes()
 		if err != nil {
 			return err
 		}
-	}
-
-	revert := revert.New()
-	defer revert.Fail()
 
-	// Set the new name in the struct.
-	d.name = newName
-	revert.Add(func() { d.name = oldName })
+		volatileSet["volatile.memory.base"] = strconv.FormatInt(baseSizeBytes/1024/1024, 10)
+	}
 
-	// Rename the backups.
-	backups, err := d.Backups()
+	err := d.addMemoryDIMM(monitor, fmt.Sprintf("dimm%d", len(dimms)), sizeMB)
 	if err != nil {
 		return err
 	}
 
-	for _, backup := range backups {
-		b := backup
-		oldName := b.Name()
-		backupName := strings.Split(oldName, "/")[1]
-		newName := fmt.Sprintf("%s/%s", newName, backupName)
+	volatileSet["volatile.memory.hotplug"] = strings.Join(append(dimms, strconv.FormatInt(sizeMB, 10)), ",")
 
-		err = b.Rename(newName)
+	return d.VolatileSet(volatileSet)
+}
+
+// restoreMemoryDIMMs recreates the memory DIMMs recorded by hotplugMemory. This must be done before restoring
+// the VM's state, as the restored state expects the same memory layout.
+func (d *qemu) restoreMemoryDIMMs(monitor *qmp.Monitor) error {
+	dimms := util.SplitNTrimSpace(d.localConfig["volatile.memory.hotplug"], ",", -1, true)
+	for i, dimm := range dimms {
+		sizeMB, err := strconv.ParseInt(dimm, 10, 64)
 		if err != nil {
-			return err
+			return errors.Wrapf(err, "Invalid hotplugged memory size %q", dimm)
 		}
 
-		revert.Add(func() { b.Rename(oldName) })
+		err = d.addMemoryDIMM(monitor, fmt.Sprintf("dimm%d", i), sizeMB)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Update lease files.
-	network.UpdateDNSMasqStatic(d.state, "")
+	return nil
+}
 
-	err = d.UpdateBackupFile()
+// addMemoryDIMM adds a memory DIMM with the given ID and size to the VM.
+func (d *qemu) addMemoryDIMM(monitor *qmp.Monitor, deviceID string, sizeMB int64) error {
+	revert := revert.New()
+	defer revert.Fail()
+
+	// Back the DIMM the same way as the boot time memory.
+	memDevID := fmt.Sprintf("mem-%s", deviceID)
+	err := monitor.AddObject(map[string]interface{}{
+		"qom-type": "memory-backend-memfd",
+		"id":       memDevID,
+		"size":     sizeMB * 1024 * 1024,
+		"share":    true,
+	})
 	if err != nil {
 		return err
 	}
 
-	d.logger.Info("Renamed instance", ctxMap)
+	revert.Add(func() { monitor.RemoveObject(memDevID) })
 
-	if d.snapshot {
-		d.state.Events.SendLifecycle(d.project, lifecycle.InstanceSnapshotRenamed.Event(d, map[string]interface{}{"old_name": oldName}))
-	} else {
-		d.state.Events.SendLifecycle(d.project, lifecycle.InstanceRenamed.Event(d, map[string]interface{}{"old_name": oldName}))
+	err = monitor.AddDevice(map[string]string{
+		"driver": "pc-dimm",
+		"id":     deviceID,
+		"memdev": memDevID,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Failed hotplugging %dMiB of memory", sizeMB)
 	}
 
 	revert.Success()
 	return nil
 }
 
-// Update the instance config.
-func (d *qemu) Update(args db.InstanceArgs, userRequested bool) error {
-	revert := revert.New()
-	defer revert.Fail()
+// updateCPULimit hotplugs or unplugs vCPUs to match the new CPU limit.
+func (d *qemu) updateCPULimit(newLimit string) error {
+	cpuCount := 1
+	if newLimit != "" {
+		var err error
+		cpuCount, err = strconv.Atoi(newLimit)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid CPU limit")
+		}
+	}
 
-	// Set sane defaults for unset keys.
-	if args.Project == "" {
-		args.Project = project.Default
+	// Connect to the monitor.
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
+	if err != nil {
+		return err // The VM isn't running as no monitor socket available.
 	}
 
-	if args.Architecture == 0 {
-		args.Architecture = d.architecture
+	err = d.setCPUs(monitor, cpuCount)
+	if err != nil {
+		return err
 	}
 
-	if args.Config == nil {
-		args.Config = map[string]string{}
+	// Move all the vCPU threads, including the hotplugged ones, into a new core scheduling domain.
+	pids, err := monitor.GetCPUs()
+	if err != nil {
+		return err
 	}
 
-	if args.Devices == nil {
-		args.Devices = deviceConfig.Devices{}
+	err = d.setCoreSched(pids)
+	if err != nil {
+		return fmt.Errorf("Failed to allocate new core scheduling domain for vCPU threads: %w", err)
 	}
 
-	if args.Profiles == nil {
-		args.Profiles = []string{}
-	}
+	return nil
+}
+
+func (d *qemu) updateDevices(removeDevices deviceConfig.Devices, addDevices deviceConfig.Devices, updateDevices deviceConfig.Devices, oldExpandedDevices deviceConfig.Devices, instanceRunning bool, userRequested bool) error {
+	revert := revert.New()
+	defer revert.Fail()
+
+	// Remove devices in reverse order to how they were added.
+	for _, dev := range removeDevices.Reversed() {
+		if instanceRunning {
+			err := d.deviceStop(dev.Name, dev.Config, instanceRunning)
+			if err == device.ErrUnsupportedDevType {
+				continue // No point in trying to remove device below.
+			} else if err != nil {
+				return errors.Wrapf(err, "Failed to stop device %q", dev.Name)
+			}
+		}
 
-	if userRequested {
-		// Validate the new config.
-		err := instance.ValidConfig(d.state.OS, args.Config, false, d.dbType)
-		if err != nil {
-			return errors.Wrap(err, "Invalid config")
+		err := d.deviceRemove(dev.Name, dev.Config, instanceRunning)
+		if err != nil && err != device.ErrUnsupportedDevType {
+			return errors.Wrapf(err, "Failed to remove device %q", dev.Name)
 		}
 
-		// Validate the new devices without using expanded devices validation (expensive checks disabled).
-		err = instance.ValidDevices(d.state, d.state.Cluster, d.Project(), d.Type(), args.Devices, false)
+		// Check whether we are about to add the same device back with updated config and
+		// if not, or if the device type has changed, then remove all volatile keys for
+		// this device (as its an actual removal or a device type change).
+		err = d.deviceVolatileReset(dev.Name, dev.Config, addDevices[dev.Name])
 		if err != nil {
-			return errors.Wrap(err, "Invalid devices")
+			return errors.Wrapf(err, "Failed to reset volatile data for device %q", dev.Name)
 		}
 	}
 
-	// Validate the new profiles.
-	profiles, err := d.state.Cluster.GetProfileNames(args.Project)
-	if err != nil {
-		return errors.Wrap(err, "Failed to get profiles")
-	}
+	// Add devices in sorted order, this ensures that device mounts are added in path order.
+	for _, dd := range addDevices.Sorted() {
+		dev := dd // Local var for loop revert.
+		err := d.deviceAdd(dev.Name, dev.Config, instanceRunning)
+		if err == device.ErrUnsupportedDevType {
+			continue // No point in trying to start device below.
+		} else if err != nil {
+			if userRequested {
+				return errors.Wrapf(err, "Failed to add device %q", dev.Name)
+			}
 
-	checkedProfiles := []string{}
-	for _, profile := range args.Profiles {
-		if !shared.StringInSlice(profile, profiles) {
-			return fmt.Errorf("Requested profile '%s' doesn't exist", profile)
+			// If update is non-user requested (i.e from a snapshot restore), there's nothing we can
+			// do to fix the config and we don't want to prevent the snapshot restore so log and allow.
+			d.logger.Error("Failed to add device, skipping as non-user requested", log.Ctx{"device": dev.Name, "err": err})
+			continue
 		}
 
-		if shared.StringInSlice(profile, checkedProfiles) {
-			return fmt.Errorf("Duplicate profile found in request")
-		}
+		revert.Add(func() { d.deviceRemove(dev.Name, dev.Config, instanceRunning) })
 
-		checkedProfiles = append(checkedProfiles, profile)
-	}
+		if instanceRunning {
+			_, err := d.deviceStart(dev.Name, dev.Config, instanceRunning)
+			if err != nil && err != device.ErrUnsupportedDevType {
+				return errors.Wrapf(err, "Failed to start device %q", dev.Name)
+			}
 
-	// Validate the new architecture.
-	if args.Architecture != 0 {
-		_, err = osarch.ArchitectureName(args.Architecture)
-		if err != nil {
-			return fmt.Errorf("Invalid architecture ID: %s", err)
+			revert.Add(func() { d.deviceStop(dev.Name, dev.Config, instanceRunning) })
 		}
 	}
 
-	// Get a copy of the old configuration.
-	oldDescription := d.Description()
-	oldArchitecture := 0
-	err = shared.DeepCopy(&d.architecture, &oldArchitecture)
-	if err != nil {
-		return err
+	for _, dev := range updateDevices.Sorted() {
+		err := d.deviceUpdate(dev.Name, dev.Config, oldExpandedDevices, instanceRunning)
+		if err != nil && err != device.ErrUnsupportedDevType {
+			return errors.Wrapf(err, "Failed to update device %q", dev.Name)
+		}
 	}
 
-	oldEphemeral := false
-	err = shared.DeepCopy(&d.ephemeral, &oldEphemeral)
-	if err != nil {
-		return err
-	}
+	revert.Success()
+	return nil
+}
 
-	oldExpandedDevices := deviceConfig.Devices{}
-	err = shared.DeepCopy(&d.expandedDevices, &oldExpandedDevices)
+// deviceUpdate loads a new device and calls its Update() function.
+func (d *qemu) deviceUpdate(deviceName string, rawConfig deviceConfig.Device, oldDevices deviceConfig.Devices, instanceRunning bool) error {
+	dev, _, err := d.deviceLoad(deviceName, rawConfig)
 	if err != nil {
 		return err
 	}
 
-	oldExpandedConfig := map[string]string{}
-	err = shared.DeepCopy(&d.expandedConfig, &oldExpandedConfig)
+	err = dev.Update(oldDevices, instanceRunning)
 	if err != nil {
 		return err
 	}
 
-	oldLocalDevices := deviceConfig.Devices{}
-	err = shared.DeepCopy(&d.localDevices, &oldLocalDevices)
-	if err != nil {
-		return err
-	}
+	return nil
+}
 
-	oldLocalConfig := map[string]string{}
-	err = shared.DeepCopy(&d.localConfig, &oldLocalConfig)
-	if err != nil {
-		return err
+func (d *qemu) removeUnixDevices() error {
+	// Check that we indeed have devices to remove.
+	if !shared.PathExists(d.DevicesPath()) {
+		return nil
 	}
 
-	oldProfiles := []string{}
-	err = shared.DeepCopy(&d.profiles, &oldProfiles)
+	// Load the directory listing.
+	dents, err := ioutil.ReadDir(d.DevicesPath())
 	if err != nil {
 		return err
 	}
 
-	oldExpiryDate := d.expiryDate
+	for _, f := range dents {
+		// Skip non-Unix devices.
+		if !strings.HasPrefix(f.Name(), "forkmknod.unix.") && !strings.HasPrefix(f.Name(), "unix.") && !strings.HasPrefix(f.Name(), "infiniband.unix.") {
+			continue
+		}
 
-	// Revert local changes if update fails.
-	revert.Add(func() {
-		d.description = oldDescription
-		d.architecture = oldArchitecture
-		d.ephemeral = oldEphemeral
-		d.expandedConfig = oldExpandedConfig
-		d.expandedDevices = oldExpandedDevices
-		d.localConfig = oldLocalConfig
-		d.localDevices = oldLocalDevices
-		d.profiles = oldProfiles
-		d.expiryDate = oldExpiryDate
-	})
+		// Remove the entry
+		devicePath := filepath.Join(d.DevicesPath(), f.Name())
+		err := os.Remove(devicePath)
+		if err != nil {
+			d.logger.Error("Failed removing unix device", log.Ctx{"err": err, "path": devicePath})
+		}
+	}
 
-	// Apply the various changes to local vars.
-	d.description = args.Description
-	d.architecture = args.Architecture
-	d.ephemeral = args.Ephemeral
-	d.localConfig = args.Config
-	d.localDevices = args.Devices
-	d.profiles = args.Profiles
-	d.expiryDate = args.ExpiryDate
+	return nil
+}
 
-	// Expand the config.
-	err = d.expandConfig(nil)
-	if err != nil {
-		return errors.Wrap(err, "Expand config")
+func (d *qemu) removeDiskDevices() error {
+	// Check that we indeed have devices to remove.
+	if !shared.PathExists(d.DevicesPath()) {
+		return nil
 	}
 
-	err = d.expandDevices(nil)
+	// Load the directory listing.
+	dents, err := ioutil.ReadDir(d.DevicesPath())
 	if err != nil {
-		return errors.Wrap(err, "Expand devices")
+		return err
 	}
 
-	// Diff the configurations.
-	changedConfig := []string{}
-	for key := range oldExpandedConfig {
-		if oldExpandedConfig[key] != d.expandedConfig[key] {
-			if !shared.StringInSlice(key, changedConfig) {
-				changedConfig = append(changedConfig, key)
-			}
+	for _, f := range dents {
+		// Skip non-disk devices
+		if !strings.HasPrefix(f.Name(), "disk.") {
+			continue
 		}
-	}
 
-	for key := range d.expandedConfig {
-		if oldExpandedConfig[key] != d.expandedConfig[key] {
-			if !shared.StringInSlice(key, changedConfig) {
-				changedConfig = append(changedConfig, key)
-			}
-		}
-	}
+		// Always try to unmount the host side.
+		_ = unix.Unmount(filepath.Join(d.DevicesPath(), f.Name()), unix.MNT_DETACH)
 
-	// Diff the devices.
-	removeDevices, addDevices, updateDevices, allUpdatedKeys := oldExpandedDevices.Update(d.expandedDevices, func(oldDevice deviceConfig.Device, newDevice deviceConfig.Device) []string {
-		// This function needs to return a list of fields that are excluded from differences
-		// between oldDevice and newDevice. The result of this is that as long as the
-		// devices are otherwise identical except for the fields returned here, then the
-		// device is considered to be being "updated" rather than "added & removed".
-		oldDevType, err := device.LoadByType(d.state, d.Project(), oldDevice)
+		// Remove the entry.
+		diskPath := filepath.Join(d.DevicesPath(), f.Name())
+		err := os.Remove(diskPath)
 		if err != nil {
-			return []string{} // Couldn't create Device, so this cannot be an update.
+			d.logger.Error("Failed to remove disk device path", log.Ctx{"err": err, "path": diskPath})
 		}
+	}
 
-		newDevType, err := device.LoadByType(d.state, d.Project(), newDevice)
-		if err != nil {
-			return []string{} // Couldn't create Device, so this cannot be an update.
-		}
+	return nil
+}
 
-		return newDevType.UpdatableFields(oldDevType)
-	})
+func (d *qemu) cleanup() {
+	// Unmount any leftovers
+	d.removeUnixDevices()
+	d.removeDiskDevices()
 
-	if userRequested {
-		// Do some validation of the config diff (allows mixed instance types for profiles).
-		err = instance.ValidConfig(d.state.OS, d.expandedConfig, true, instancetype.Any)
-		if err != nil {
-			return errors.Wrap(err, "Invalid expanded config")
-		}
+	// Remove the security profiles
+	apparmor.InstanceDelete(d.state, d)
 
-		// Do full expanded validation of the devices diff.
-		err = instance.ValidDevices(d.state, d.state.Cluster, d.Project(), d.Type(), d.expandedDevices, true)
-		if err != nil {
-			return errors.Wrap(err, "Invalid expanded devices")
-		}
+	// Remove the devices path
+	os.Remove(d.DevicesPath())
+
+	// Remove the shmounts path
+	os.RemoveAll(d.ShmountsPath())
+}
+
+// cleanupDevices performs any needed device cleanup steps when instance is stopped.
+// Must be called before root volume is unmounted.
+func (d *qemu) cleanupDevices() {
+	// Clear up the config drive virtiofsd process.
+	err := device.DiskVMVirtiofsdStop(d.configVirtiofsdPaths())
+	if err != nil {
+		d.logger.Warn("Failed cleaning up config drive virtiofsd", log.Ctx{"err": err})
 	}
 
-	// If apparmor changed, re-validate the apparmor profile (even if not running).
-	if shared.StringInSlice("raw.apparmor", changedConfig) {
-		err = apparmor.InstanceValidate(d.state, d)
-		if err != nil {
-			return errors.Wrap(err, "Parse AppArmor profile")
+	// Clear up the config drive mount.
+	err = d.configDriveMountPathClear()
+	if err != nil {
+		d.logger.Warn("Failed cleaning up config drive mount", log.Ctx{"err": err})
+	}
+
+	for _, dev := range d.expandedDevices.Reversed() {
+		// Use the device interface if device supports it.
+		err := d.deviceStop(dev.Name, dev.Config, false)
+		if err == device.ErrUnsupportedDevType {
+			continue
+		} else if err != nil {
+			d.logger.Error("Failed to stop device", log.Ctx{"devName": dev.Name, "err": err})
 		}
 	}
+}
 
-	isRunning := d.IsRunning()
+func (d *qemu) init() error {
+	// Compute the expanded config and device list.
+	err := d.expandConfig(nil)
+	if err != nil {
+		return err
+	}
 
-	// Use the device interface to apply update changes.
-	err = d.updateDevices(removeDevices, addDevices, updateDevices, oldExpandedDevices, isRunning, userRequested)
+	err = d.expandDevices(nil)
 	if err != nil {
 		return err
 	}
 
-	if isRunning {
-		// Only certain keys can be changed on a running VM.
-		liveUpdateKeys := []string{"limits.memory"}
+	return nil
+}
 
-		// Check only keys that support live update have changed.
-		for _, key := range changedConfig {
-			if !strings.HasPrefix(key, "user.") && !shared.StringInSlice(key, liveUpdateKeys) {
-				return fmt.Errorf("Key %q cannot be updated when VM is running", key)
-			}
-		}
+// Delete the instance.
+func (d *qemu) Delete(force bool) error {
+	ctxMap := log.Ctx{
+		"created":   d.creationDate,
+		"ephemeral": d.ephemeral,
+		"used":      d.lastUsedDate}
 
-		// Apply live update for each key.
-		for _, key := range changedConfig {
-			value := d.expandedConfig[key]
+	d.logger.Info("Deleting instance", ctxMap)
 
-			if key == "limits.memory" {
-				err = d.updateMemoryLimit(value)
-				if err != nil {
-					if err != nil {
-						return errors.Wrapf(err, "Failed updating memory limit")
-					}
-				}
-			}
-		}
+	// Check if instance is delete protected.
+	if !force && shared.IsTrue(d.expandedConfig["security.protection.delete"]) && !d.IsSnapshot() {
+		return fmt.Errorf("Instance is protected")
 	}
 
-	// Update MAAS (must run after the MAC addresses have been generated).
-	updateMAAS := false
-	for _, key := range []string{"maas.subnet.ipv4", "maas.subnet.ipv6", "ipv4.address", "ipv6.address"} {
-		if shared.StringInSlice(key, allUpdatedKeys) {
-			updateMAAS = true
-			break
-		}
+	// Delete any persistent warnings for instance.
+	err := d.warningsDelete()
+	if err != nil {
+		return err
 	}
 
-	if !d.IsSnapshot() && updateMAAS {
-		err = d.maasUpdate(d, oldExpandedDevices.CloneNative())
-		if err != nil {
-			return err
+	// Attempt to initialize storage interface for the instance.
+	pool, err := d.getStoragePool()
+	if err != nil && errors.Cause(err) != db.ErrNoSuchObject {
+		return err
+	} else if pool != nil {
+		if d.IsSnapshot() {
+
+			// Remove snapshot volume and database record.
+			err = pool.DeleteInstanceSnapshot(d, nil)
+			if err != nil {
+				return err
+			}
+		} else {
+			// Remove all snapshots by initialising each snapshot as an Instance and
+			// calling its Delete function.
+			err := instance.DeleteSnapshots(d.state, d.Project(), d.Name())
+			if err != nil {
+				return err
+			}
+
+			// Remove the storage volume, snapshot volumes and database records.
+			err = pool.DeleteInstance(d, nil)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
-	if shared.StringInSlice("security.secureboot", changedConfig) {
-		// Re-generate the NVRAM.
-		err = d.setupNvram()
+	// Perform other cleanup steps if not snapshot.
+	if !d.IsSnapshot() {
+		// Remove all backups (any copies held on the backups target are kept).
+		backups, err := d.Backups()
 		if err != nil {
 			return err
 		}
-	}
 
-	// Finally, apply the changes to the database.
-	err = d.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
-		// Snapshots should update only their descriptions and expiry date.
-		if d.IsSnapshot() {
-			return tx.UpdateInstanceSnapshot(d.id, d.description, d.expiryDate)
+		for _, backup := range backups {
+			err = backup.DeleteLocal()
+			if err != nil {
+				return err
+			}
 		}
 
-		object, err := tx.GetInstance(d.project, d.name)
+		// Delete the MAAS entry.
+		err = d.maasDelete(d)
 		if err != nil {
+			d.logger.Error("Failed deleting instance MAAS record", log.Ctx{"err": err})
 			return err
 		}
 
-		object.Description = d.description
-		object.Architecture = d.architecture
-		object.Ephemeral = d.ephemeral
-		object.ExpiryDate = sql.NullTime{Time: d.expiryDate, Valid: true}
-		object.Config = d.localConfig
-		object.Profiles = d.profiles
-
-		devices, err := db.APIToDevices(d.localDevices.CloneNative())
-		if err != nil {
-			return err
+		// Run device removal function for each device.
+		for k, m := range d.expandedDevices {
+			err = d.deviceRemove(k, m, false)
+			if err != nil && err != device.ErrUnsupportedDevType {
+				return errors.Wrapf(err, "Failed to remove device %q", k)
+			}
 		}
 
-		object.Devices = devices
-
-		return tx.UpdateInstance(d.project, d.name, *object)
-	})
-	if err != nil {
-		return errors.Wrap(err, "Failed to update database")
+		// Clean things up.
+		d.cleanup()
 	}
 
-	err = d.UpdateBackupFile()
-	if err != nil && !os.IsNotExist(err) {
-		return errors.Wrap(err, "Failed to write backup file")
+	// Remove the database record of the instance or snapshot instance.
+	if err := d.state.Cluster.DeleteInstance(d.Project(), d.Name()); err != nil {
+		d.logger.Error("Failed deleting instance entry", log.Ctx{"project": d.Project()})
+		return err
 	}
 
-	// Changes have been applied and recorded, do not revert if an error occurs from here.
-	revert.Success()
+	// If dealing with a snapshot, refresh the backup file on the parent.
+	if d.IsSnapshot() {
+		parentName, _, _ := shared.InstanceGetParentAndSnapshotName(d.name)
 
-	if isRunning {
-		err = d.writeInstanceData()
+		// Load the parent.
+		parent, err := instance.LoadByProjectAndName(d.state, d.project, parentName)
 		if err != nil {
-			return errors.Wrap(err, "Failed to write instance-data file")
+			return errors.Wrap(err, "Invalid parent")
 		}
 
-		// Send devlxd notifications only for user.* key changes
-		for _, key := range changedConfig {
-			if !strings.HasPrefix(key, "user.") {
-				continue
-			}
-
-			msg := map[string]string{
-				"key":       key,
-				"old_value": oldExpandedConfig[key],
-				"value":     d.expandedConfig[key],
-			}
-
-			err = d.devlxdEventSend("config", msg)
-			if err != nil {
-				return err
-			}
+		// Update the backup file.
+		err = parent.UpdateBackupFile()
+		if err != nil {
+			return err
 		}
 	}
 
-	if userRequested {
-		if d.snapshot {
-			d.state.Events.SendLifecycle(d.project, lifecycle.InstanceSnapshotUpdated.Event(d, nil))
-		} else {
-			d.state.Events.SendLifecycle(d.project, lifecycle.InstanceUpdated.Event(d, nil))
-		}
+	d.logger.Info("Deleted instance", ctxMap)
+
+	if d.snapshot {
+		d.state.Events.SendLifecycle(d.project, lifecycle.InstanceSnapshotDeleted.Event(d, nil))
+	} else {
+		d.state.Events.SendLifecycle(d.project, lifecycle.InstanceDeleted.Event(d, nil))
 	}
 
 	return nil
 }
 
-// updateMemoryLimit live updates the VM's memory limit by reszing the balloon device.
-func (d *qemu) updateMemoryLimit(newLimit string) error {
-	if newLimit == "" {
-		return nil
+func (d *qemu) deviceAdd(deviceName string, rawConfig deviceConfig.Device, instanceRunning bool) error {
+	dev, _, err := d.deviceLoad(deviceName, rawConfig)
+	if err != nil {
+		return err
 	}
 
-	if shared.IsTrue(d.expandedConfig["limits.memory.hugepages"]) {
-		return fmt.Errorf("Cannot live update memory limit when using huge pages")
+	if instanceRunning && !dev.CanHotPlug() {
+		return fmt.Errorf("Device cannot be added when instance is running")
 	}
 
-	// Check new size string is valid and convert to bytes.
-	newSizeBytes, err := units.ParseByteSizeString(newLimit)
-	if err != nil {
-		return errors.Wrapf(err, "Invalid memory size")
-	}
-	newSizeMB := newSizeBytes / 1024 / 1024
+	return dev.Add()
+}
 
-	// Connect to the monitor.
-	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
-	if err != nil {
-		return err // The VM isn't running as no monitor socket available.
-	}
+func (d *qemu) deviceRemove(deviceName string, rawConfig deviceConfig.Device, instanceRunning bool) error {
+	logger := logging.AddContext(d.logger, log.Ctx{"device": deviceName, "type": rawConfig["type"]})
 
-	baseSizeBytes, err := monitor.GetMemorySizeBytes()
-	if err != nil {
+	dev, _, err := d.deviceLoad(deviceName, rawConfig)
+
+	// If deviceLoad fails with unsupported device type then return.
+	if err == device.ErrUnsupportedDevType {
 		return err
 	}
-	baseSizeMB := baseSizeBytes / 1024 / 1024
 
-	curSizeBytes, err := monitor.GetMemoryBalloonSizeBytes()
+	// If deviceLoad fails for any other reason then just log the error and proceed, as in the
+	// scenario that a new version of LXD has additional validation restrictions than older
+	// versions we still need to allow previously valid devices to be stopped.
 	if err != nil {
-		return err
-	}
-	curSizeMB := curSizeBytes / 1024 / 1024
+		// If there is no device returned, then we cannot proceed, so return as error.
+		if dev == nil {
+			return fmt.Errorf("Device remove validation failed for %q: %v", deviceName, err)
+		}
 
-	if curSizeMB == newSizeMB {
-		return nil
-	} else if baseSizeMB < newSizeMB {
-		return fmt.Errorf("Cannot increase memory size beyond boot time size when VM is running (Boot time size %dMiB, new size %dMiB)", baseSizeMB, newSizeMB)
+		logger.Error("Device remove validation failed", log.Ctx{"err": err})
 	}
 
-	// Set effective memory size.
-	err = monitor.SetMemoryBalloonSizeBytes(newSizeBytes)
-	if err != nil {
-		return err
+	if instanceRunning && !dev.CanHotPlug() {
+		return fmt.Errorf("Device cannot be removed when instance is running")
 	}
 
-	// Changing the memory balloon can take time, so poll the effectice size to check it has shrunk within 1%
-	// of the target size, which we then take as success (it may still continue to shrink closer to target).
-	for i := 0; i < 10; i++ {
-		curSizeBytes, err = monitor.GetMemoryBalloonSizeBytes()
-		if err != nil {
-			return err
-		}
-		curSizeMB = curSizeBytes / 1024 / 1024
+	return dev.Remove()
+}
 
-		var diff int64
-		if curSizeMB < newSizeMB {
-			diff = newSizeMB - curSizeMB
-		} else {
-			diff = curSizeMB - newSizeMB
-		}
+// Export publishes the instance.
+func (d *qemu) Export(w io.Writer, properties map[string]string, expiration time.Time) (api.ImageMetadata, error) {
+	ctxMap := log.Ctx{
+		"created":   d.creationDate,
+		"ephemeral": d.ephemeral,
+		"used":      d.lastUsedDate}
+
+	meta := api.ImageMetadata{}
 
-		if diff <= (newSizeMB / 100) {
-			return nil // We reached to within 1% of our target size.
-		}
+	if d.IsRunning() {
+		return meta, fmt.Errorf("Cannot export a running instance as an image")
+	}
 
-		time.Sleep(500 * time.Millisecond)
+	d.logger.Info("Exporting instance", ctxMap)
+
+	// Start the storage.
+	mountInfo, err := d.mount()
+	if err != nil {
+		d.logger.Error("Failed exporting instance", ctxMap)
+		return meta, err
 	}
+	defer d.unmount()
 
-	return fmt.Errorf("Failed setting memory to %dMiB (currently %dMiB) as it was taking too long", newSizeMB, curSizeMB)
-}
+	// Create the tarball.
+	tarWriter := instancewriter.NewInstanceTarWriter(w, nil)
 
-func (d *qemu) updateDevices(removeDevices deviceConfig.Devices, addDevices deviceConfig.Devices, updateDevices deviceConfig.Devices, oldExpandedDevices deviceConfig.Devices, instanceRunning bool, userRequested bool) error {
-	revert := revert.New()
-	defer revert.Fail()
+	// Path inside the tar image is the pathname starting after cDir.
+	cDir := d.Path()
+	offset := len(cDir) + 1
 
-	// Remove devices in reverse order to how they were added.
-	for _, dev := range removeDevices.Reversed() {
-		if instanceRunning {
-			err := d.deviceStop(dev.Name, dev.Config, instanceRunning)
-			if err == device.ErrUnsupportedDevType {
-				continue // No point in trying to remove device below.
-			} else if err != nil {
-				return errors.Wrapf(err, "Failed to stop device %q", dev.Name)
-			}
+	writeToTar := func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
 		}
 
-		err := d.deviceRemove(dev.Name, dev.Config, instanceRunning)
-		if err != nil && err != device.ErrUnsupportedDevType {
-			return errors.Wrapf(err, "Failed to remove device %q", dev.Name)
+		err = tarWriter.WriteFile(path[offset:], path, fi, false)
+		if err != nil {
+			d.logger.Debug("Error tarring up", log.Ctx{"path": path, "err": err})
+			return err
 		}
 
-		// Check whether we are about to add the same device back with updated config and
-		// if not, or if the device type has changed, then remove all volatile keys for
-		// this device (as its an actual removal or a device type change).
-		err = d.deviceVolatileReset(dev.Name, dev.Config, addDevices[dev.Name])
+		return nil
+	}
+
+	// Look for metadata.yaml.
+	fnam := filepath.Join(cDir, "metadata.yaml")
+	if !shared.PathExists(fnam) {
+		// Generate a new metadata.yaml.
+		tempDir, err := ioutil.TempDir("", "lxd_lxd_metadata_")
 		if err != nil {
-			return errors.Wrapf(err, "Failed to reset volatile data for device %q", dev.Name)
+			tarWriter.Close()
+			d.logger.Error("Failed exporting instance", ctxMap)
+			return meta, err
 		}
-	}
+		defer os.RemoveAll(tempDir)
 
-	// Add devices in sorted order, this ensures that device mounts are added in path order.
-	for _, dd := range addDevices.Sorted() {
-		dev := dd // Local var for loop revert.
-		err := d.deviceAdd(dev.Name, dev.Config, instanceRunning)
-		if err == device.ErrUnsupportedDevType {
-			continue // No point in trying to start device below.
-		} else if err != nil {
-			if userRequested {
-				return errors.Wrapf(err, "Failed to add device %q", dev.Name)
+		// Get the instance's architecture.
+		var arch string
+		if d.IsSnapshot() {
+			parentName, _, _ := shared.InstanceGetParentAndSnapshotName(d.name)
+			parent, err := instance.LoadByProjectAndName(d.state, d.project, parentName)
+			if err != nil {
+				tarWriter.Close()
+				d.logger.Error("Failed exporting instance", ctxMap)
+				return meta, err
 			}
 
-			// If update is non-user requested (i.e from a snapshot restore), there's nothing we can
-			// do to fix the config and we don't want to prevent the snapshot restore so log and allow.
-			d.logger.Error("Failed to add device, skipping as non-user requested", log.Ctx{"device": dev.Name, "err": err})
-			continue
+			arch, _ = osarch.ArchitectureName(parent.Architecture())
+		} else {
+			arch, _ = osarch.ArchitectureName(d.architecture)
 		}
 
-		revert.Add(func() { d.deviceRemove(dev.Name, dev.Config, instanceRunning) })
-
-		if instanceRunning {
-			_, err := d.deviceStart(dev.Name, dev.Config, instanceRunning)
-			if err != nil && err != device.ErrUnsupportedDevType {
-				return errors.Wrapf(err, "Failed to start device %q", dev.Name)
+		if arch == "" {
+			arch, err = osarch.ArchitectureName(d.state.OS.Architectures[0])
+			if err != nil {
+				d.logger.Error("Failed exporting instance", ctxMap)
+				return meta, err
 			}
-
-			revert.Add(func() { d.deviceStop(dev.Name, dev.Config, instanceRunning) })
 		}
-	}
 
-	for _, dev := range updateDevices.Sorted() {
-		err := d.deviceUpdate(dev.Name, dev.Config, oldExpandedDevices, instanceRunning)
-		if err != nil && err != device.ErrUnsupportedDevType {
-			return errors.Wrapf(err, "Failed to update device %q", dev.Name)
+		// Fill in the metadata.
+		meta.Architecture = arch
+		meta.CreationDate = time.Now().UTC().Unix()
+		meta.Properties = properties
+		if !expiration.IsZero() {
+			meta.ExpiryDate = expiration.UTC().Unix()
 		}
-	}
-
-	revert.Success()
-	return nil
-}
-
-// deviceUpdate loads a new device and calls its Update() function.
-func (d *qemu) deviceUpdate(deviceName string, rawConfig deviceConfig.Device, oldDevices deviceConfig.Devices, instanceRunning bool) error {
-	dev, _, err := d.deviceLoad(deviceName, rawConfig)
-	if err != nil {
-		return err
-	}
-
-	err = dev.Update(oldDevices, instanceRunning)
-	if err != nil {
-		return err
-	}
 
-	return nil
-}
+		data, err := yaml.Marshal(&meta)
+		if err != nil {
+			tarWriter.Close()
+			d.logger.Error("Failed exporting instance", ctxMap)
+			return meta, err
+		}
 
-func (d *qemu) removeUnixDevices() error {
-	// Check that we indeed have devices to remove.
-	if !shared.PathExists(d.DevicesPath()) {
-		return nil
-	}
+		// Write the actual file.
+		fnam = filepath.Join(tempDir, "metadata.yaml")
+		err = ioutil.WriteFile(fnam, data, 0644)
+		if err != nil {
+			tarWriter.Close()
+			d.logger.Error("Failed exporting instance", ctxMap)
+			return meta, err
+		}
 
-	// Load the directory listing.
-	dents, err := ioutil.ReadDir(d.DevicesPath())
-	if err != nil {
-		return err
-	}
+		fi, err := os.Lstat(fnam)
+		if err != nil {
+			tarWriter.Close()
+			d.logger.Error("Failed exporting instance", ctxMap)
+			return meta, err
+		}
 
-	for _, f := range dents {
-		// Skip non-Unix devices.
-		if !strings.HasPrefix(f.Name(), "forkmknod.unix.") && !strings.HasPrefix(f.Name(), "unix.") && !strings.HasPrefix(f.Name(), "infiniband.unix.") {
-			continue
+		tmpOffset := len(filepath.Dir(fnam)) + 1
+		if err := tarWriter.WriteFile(fnam[tmpOffset:], fnam, fi, false); err != nil {
+			tarWriter.Close()
+			d.logger.Error("Failed exporting instance", ctxMap)
+			return meta, err
+		}
+	} else {
+		// Parse the metadata.
+		content, err := ioutil.ReadFile(fnam)
+		if err != nil {
+			tarWriter.Close()
+			d.logger.Error("Failed exporting instance", ctxMap)
+			return meta, err
 		}
 
-		// Remove the entry
-		devicePath := filepath.Join(d.DevicesPath(), f.Name())
-		err := os.Remove(devicePath)
+		err = yaml.Unmarshal(content, &meta)
 		if err != nil {
-			d.logger.Error("Failed removing unix device", log.Ctx{"err": err, "path": devicePath})
+			tarWriter.Close()
+			d.logger.Error("Failed exporting instance", ctxMap)
+			return meta, err
 		}
-	}
 
-	return nil
-}
+		if !expiration.IsZero() {
+			meta.ExpiryDate = expiration.UTC().Unix()
+		}
 
-func (d *qemu) removeDiskDevices() error {
-	// Check that we indeed have devices to remove.
-	if !shared.PathExists(d.DevicesPath()) {
-		return nil
-	}
+		if properties != nil {
+			meta.Properties = properties
+		}
 
-	// Load the directory listing.
-	dents, err := ioutil.ReadDir(d.DevicesPath())
-	if err != nil {
-		return err
-	}
+		if properties != nil || !expiration.IsZero() {
+			// Generate a new metadata.yaml.
+			tempDir, err := ioutil.TempDir("", "lxd_lxd_metadata_")
+			if err != nil {
+				tarWriter.Close()
+				d.logger.Error("Failed exporting instance", ctxMap)
+				return meta, err
+			}
+			defer os.RemoveAll(tempDir)
 
-	for _, f := range dents {
-		// Skip non-disk devices
-		if !strings.HasPrefix(f.Name(), "disk.") {
-			continue
+			data, err := yaml.Marshal(&meta)
+			if err != nil {
+				tarWriter.Close()
+				d.logger.Error("Failed exporting instance", ctxMap)
+				return meta, err
+			}
+
+			// Write the actual file.
+			fnam = filepath.Join(tempDir, "metadata.yaml")
+			err = ioutil.WriteFile(fnam, data, 0644)
+			if err != nil {
+				tarWriter.Close()
+				d.logger.Error("Failed exporting instance", ctxMap)
+				return meta, err
+			}
 		}
 
-		// Always try to unmount the host side.
-		_ = unix.Unmount(filepath.Join(d.DevicesPath(), f.Name()), unix.MNT_DETACH)
+		// Include metadata.yaml in the tarball.
+		fi, err := os.Lstat(fnam)
+		if err != nil {
+			tarWriter.Close()
+			d.logger.Debug("Error statting during export", log.Ctx{"fileName": fnam})
+			d.logger.Error("Failed exporting instance", ctxMap)
+			return meta, err
+		}
 
-		// Remove the entry.
-		diskPath := filepath.Join(d.DevicesPath(), f.Name())
-		err := os.Remove(diskPath)
+		if properties != nil || !expiration.IsZero() {
+			tmpOffset := len(filepath.Dir(fnam)) + 1
+			err = tarWriter.WriteFile(fnam[tmpOffset:], fnam, fi, false)
+		} else {
+			err = tarWriter.WriteFile(fnam[offset:], fnam, fi, false)
+		}
 		if err != nil {
-			d.logger.Error("Failed to remove disk device path", log.Ctx{"err": err, "path": diskPath})
+			tarWriter.Close()
+			d.logger.Debug("Error writing to tarfile", log.Ctx{"err": err})
+			d.logger.Error("Failed exporting instance", ctxMap)
+			return meta, err
 		}
 	}
 
-	return nil
-}
-
-func (d *qemu) cleanup() {
-	// Unmount any leftovers
-	d.removeUnixDevices()
-	d.removeDiskDevices()
-
-	// Remove the security profiles
-	apparmor.InstanceDelete(d.state, d)
+	// Convert from raw to qcow2 and add to tarball.
+	tmpPath, err := ioutil.TempDir(shared.VarPath("images"), "lxd_export_")
+	if err != nil {
+		return meta, err
+	}
+	defer os.RemoveAll(tmpPath)
 
-	// Remove the devices path
-	os.Remove(d.DevicesPath())
+	if mountInfo.DiskPath == "" {
+		return meta, fmt.Errorf("No disk path available from mount")
+	}
 
-	// Remove the shmounts path
-	os.RemoveAll(d.ShmountsPath())
-}
+	fPath := fmt.Sprintf("%s/rootfs.img", tmpPath)
+	_, err = shared.RunCommand("qemu-img", "convert", "-c", "-O", "qcow2", mountInfo.DiskPath, fPath)
+	if err != nil {
+		return meta, fmt.Errorf("Failed converting image to qcow2: %v", err)
+	}
 
-// cleanupDevices performs any needed device cleanup steps when instance is stopped.
-// Must be called before root volume is unmounted.
-func (d *qemu) cleanupDevices() {
-	// Clear up the config drive virtiofsd process.
-	err := device.DiskVMVirtiofsdStop(d.configVirtiofsdPaths())
+	fi, err := os.Lstat(fPath)
 	if err != nil {
-		d.logger.Warn("Failed cleaning up config drive virtiofsd", log.Ctx{"err": err})
+		return meta, err
 	}
 
-	// Clear up the config drive mount.
-	err = d.configDriveMountPathClear()
+	imgOffset := len(tmpPath) + 1
+	err = tarWriter.WriteFile(fPath[imgOffset:], fPath, fi, false)
 	if err != nil {
-		d.logger.Warn("Failed cleaning up config drive mount", log.Ctx{"err": err})
+		return meta, err
 	}
 
-	for _, dev := range d.expandedDevices.Reversed() {
-		// Use the device interface if device supports it.
-		err := d.deviceStop(dev.Name, dev.Config, false)
-		if err == device.ErrUnsupportedDevType {
-			continue
-		} else if err != nil {
-			d.logger.Error("Failed to stop device", log.Ctx{"devName": dev.Name, "err": err})
+	// Include all the templates.
+	fnam = d.TemplatesPath()
+	if shared.PathExists(fnam) {
+		err = filepath.Walk(fnam, writeToTar)
+		if err != nil {
+			d.logger.Error("Failed exporting instance", ctxMap)
+			return meta, err
 		}
 	}
-}
 
-func (d *qemu) init() error {
-	// Compute the expanded config and device list.
-	err := d.expandConfig(nil)
+	err = tarWriter.Close()
 	if err != nil {
-		return err
+		d.logger.Error("Failed exporting instance", ctxMap)
+		return meta, err
 	}
 
-	err = d.expandDevices(nil)
-	if err != nil {
-		return err
+	d.logger.Info("Exported instance", ctxMap)
+	return meta, nil
+}
+
+// MigrateSendLive live migrates the running instance to a target member.
+// If args.DiskConn is set then the root disk is transferred as well, otherwise the storage is expected to be
+// shared with the target. Once the target has confirmed it is running the instance, the local QEMU process is
+// stopped.
+func (d *qemu) MigrateSendLive(args instance.VMLiveMigrateArgs) error {
+	d.logger.Debug("Live migration send started")
+	defer d.logger.Debug("Live migration send finished")
+
+	if !d.IsRunning() {
+		return fmt.Errorf("Live migration requires the instance to be running")
 	}
 
-	return nil
-}
+	if !shared.IsTrue(d.expandedConfig["migration.stateful"]) {
+		return fmt.Errorf("Live migration requires migration.stateful to be set to true")
+	}
 
-// Delete the instance.
-func (d *qemu) Delete(force bool) error {
-	ctxMap := log.Ctx{
-		"created":   d.creationDate,
-		"ephemeral": d.ephemeral,
-		"used":      d.lastUsedDate}
+	rootDiskName, _, err := shared.GetRootDiskDevice(d.expandedDevices.CloneNative())
+	if err != nil {
+		return errors.Wrapf(err, "Failed getting instance root disk")
+	}
 
-	d.logger.Info("Deleting instance", ctxMap)
+	rootDriveName := fmt.Sprintf("lxd_%s", rootDiskName)
 
-	// Check if instance is delete protected.
-	if !force && shared.IsTrue(d.expandedConfig["security.protection.delete"]) && !d.IsSnapshot() {
-		return fmt.Errorf("Instance is protected")
+	// Setup a new operation. This is picked up by onStop once the migration has succeeded so that the
+	// instance's power state and the resources it shares with the target are left alone.
+	op, err := operationlock.Create(d.Project(), d.Name(), operationlock.ActionMigrate, false, false)
+	if err != nil {
+		return errors.Wrap(err, "Create instance migrate operation")
 	}
+	defer op.Done(nil)
 
-	// Delete any persistent warnings for instance.
-	err := d.warningsDelete()
+	// Keep the operation alive for the duration of the migration.
+	chMigrateDone := make(chan struct{})
+	defer close(chMigrateDone)
+	go func() {
+		for {
+			select {
+			case <-chMigrateDone:
+				return
+			case <-time.After(time.Second * 10):
+				op.Reset()
+			}
+		}
+	}()
+
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
 	if err != nil {
+		op.Done(err)
 		return err
 	}
 
-	// Attempt to initialize storage interface for the instance.
-	pool, err := d.getStoragePool()
-	if err != nil && errors.Cause(err) != db.ErrNoSuchObject {
+	// Throttle the guest's CPUs if needed so that busy guests still converge.
+	err = monitor.MigrateSetCapabilities(map[string]bool{"auto-converge": true})
+	if err != nil {
+		op.Done(err)
 		return err
-	} else if pool != nil {
-		if d.IsSnapshot() {
+	}
 
-			// Remove snapshot volume and database record.
-			err = pool.DeleteInstanceSnapshot(d, nil)
-			if err != nil {
-				return err
-			}
-		} else {
-			// Remove all snapshots by initialising each snapshot as an Instance and
-			// calling its Delete function.
-			err := instance.DeleteSnapshots(d.state, d.Project(), d.Name())
-			if err != nil {
-				return err
-			}
+	revert := revert.New()
+	defer revert.Fail()
 
-			// Remove the storage volume, snapshot volumes and database records.
-			err = pool.DeleteInstance(d, nil)
-			if err != nil {
-				return err
-			}
+	overlayPath := filepath.Join(d.DevicesPath(), "migration.qcow2")
+
+	if args.DiskConn != nil {
+		err = os.MkdirAll(d.DevicesPath(), 0711)
+		if err != nil {
+			op.Done(err)
+			return err
 		}
-	}
 
-	// Perform other cleanup steps if not snapshot.
-	if !d.IsSnapshot() {
-		// Remove all backups.
-		backups, err := d.Backups()
+		// Redirect the guest's writes to an overlay, so that the root disk doesn't change whilst it is
+		// being transferred. The overlay file is created in advance so that an unprivileged QEMU can use it.
+		err = ioutil.WriteFile(overlayPath, nil, 0600)
 		if err != nil {
+			op.Done(err)
 			return err
 		}
 
-		for _, backup := range backups {
-			err = backup.Delete()
+		if d.state.OS.UnprivUser != "" {
+			err = os.Chown(overlayPath, int(d.state.OS.UnprivUID), -1)
 			if err != nil {
+				op.Done(err)
 				return err
 			}
 		}
 
-		// Delete the MAAS entry.
-		err = d.maasDelete(d)
+		err = monitor.BlockDevSnapshot(rootDriveName, overlayPath, "lxd_migration_overlay")
 		if err != nil {
-			d.logger.Error("Failed deleting instance MAAS record", log.Ctx{"err": err})
+			os.Remove(overlayPath)
+			op.Done(err)
 			return err
 		}
 
-		// Run device removal function for each device.
-		for k, m := range d.expandedDevices {
-			err = d.deviceRemove(k, m, false)
-			if err != nil && err != device.ErrUnsupportedDevType {
-				return errors.Wrapf(err, "Failed to remove device %q", k)
+		revert.Add(func() {
+			// Merge the writes made during the migration back into the root disk.
+			err := monitor.BlockCommit(rootDriveName, "lxd_migration_commit")
+			if err == nil {
+				err = monitor.BlockJobWaitReady("lxd_migration_commit", nil)
 			}
-		}
 
-		// Clean things up.
-		d.cleanup()
-	}
+			if err == nil {
+				err = monitor.BlockJobComplete("lxd_migration_commit")
+			}
 
-	// Remove the database record of the instance or snapshot instance.
-	if err := d.state.Cluster.DeleteInstance(d.Project(), d.Name()); err != nil {
-		d.logger.Error("Failed deleting instance entry", log.Ctx{"project": d.Project()})
-		return err
-	}
+			if err != nil {
+				d.logger.Error("Failed merging migration overlay into root disk", log.Ctx{"err": err})
+				return
+			}
 
-	// If dealing with a snapshot, refresh the backup file on the parent.
-	if d.IsSnapshot() {
-		parentName, _, _ := shared.InstanceGetParentAndSnapshotName(d.name)
+			os.Remove(overlayPath)
+		})
 
-		// Load the parent.
-		parent, err := instance.LoadByProjectAndName(d.state, d.project, parentName)
+		// Transfer the root disk as it was when the overlay was created.
+		err = args.DiskTransfer()
 		if err != nil {
-			return errors.Wrap(err, "Invalid parent")
+			op.Done(err)
+			return err
 		}
 
-		// Update the backup file.
-		err = parent.UpdateBackupFile()
+		// Connect QEMU to the target's NBD export of the root disk using a local proxy socket.
+		nbdPath := filepath.Join(d.DevicesPath(), "migration.nbd.sock")
+		os.Remove(nbdPath)
+
+		listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: nbdPath, Net: "unix"})
 		if err != nil {
+			op.Done(err)
 			return err
 		}
-	}
-
-	d.logger.Info("Deleted instance", ctxMap)
-
-	if d.snapshot {
-		d.state.Events.SendLifecycle(d.project, lifecycle.InstanceSnapshotDeleted.Event(d, nil))
-	} else {
-		d.state.Events.SendLifecycle(d.project, lifecycle.InstanceDeleted.Event(d, nil))
-	}
-
-	return nil
-}
-
-func (d *qemu) deviceAdd(deviceName string, rawConfig deviceConfig.Device, instanceRunning bool) error {
-	dev, _, err := d.deviceLoad(deviceName, rawConfig)
-	if err != nil {
-		return err
-	}
 
-	if instanceRunning && !dev.CanHotPlug() {
-		return fmt.Errorf("Device cannot be added when instance is running")
-	}
+		defer os.Remove(nbdPath)
+		defer listener.Close()
 
-	return dev.Add()
-}
+		if d.state.OS.UnprivUser != "" {
+			err = os.Chown(nbdPath, int(d.state.OS.UnprivUID), -1)
+			if err != nil {
+				op.Done(err)
+				return err
+			}
+		}
 
-func (d *qemu) deviceRemove(deviceName string, rawConfig deviceConfig.Device, instanceRunning bool) error {
-	logger := logging.AddContext(d.logger, log.Ctx{"device": deviceName, "type": rawConfig["type"]})
+		go func() {
+			conn, err := listener.AcceptUnix()
+			if err != nil {
+				return
+			}
 
-	dev, _, err := d.deviceLoad(deviceName, rawConfig)
+			qemuMigrationProxy(conn, args.DiskConn)
+		}()
+
+		err = monitor.AddBlockDevice(map[string]interface{}{
+			"driver":    "nbd",
+			"node-name": "lxd_migration_nbd",
+			"export":    rootDriveName,
+			"server": map[string]interface{}{
+				"type": "unix",
+				"path": nbdPath,
+			},
+		})
+		if err != nil {
+			op.Done(err)
+			return err
+		}
 
-	// If deviceLoad fails with unsupported device type then return.
-	if err == device.ErrUnsupportedDevType {
-		return err
-	}
+		revert.Add(func() { monitor.RemoveBlockDevice("lxd_migration_nbd") })
 
-	// If deviceLoad fails for any other reason then just log the error and proceed, as in the
-	// scenario that a new version of LXD has additional validation restrictions than older
-	// versions we still need to allow previously valid devices to be stopped.
-	if err != nil {
-		// If there is no device returned, then we cannot proceed, so return as error.
-		if dev == nil {
-			return fmt.Errorf("Device remove validation failed for %q: %v", deviceName, err)
+		// Mirror the writes held in the overlay (and any new ones) to the target.
+		err = monitor.BlockDevMirror(rootDriveName, "lxd_migration_nbd", "lxd_migration_mirror")
+		if err != nil {
+			op.Done(err)
+			return err
 		}
 
-		logger.Error("Device remove validation failed", log.Ctx{"err": err})
-	}
+		revert.Add(func() { monitor.BlockJobCancel("lxd_migration_mirror") })
 
-	if instanceRunning && !dev.CanHotPlug() {
-		return fmt.Errorf("Device cannot be removed when instance is running")
+		err = monitor.BlockJobWaitReady("lxd_migration_mirror", nil)
+		if err != nil {
+			op.Done(err)
+			return err
+		}
 	}
 
-	return dev.Remove()
-}
-
-// Export publishes the instance.
-func (d *qemu) Export(w io.Writer, properties map[string]string, expiration time.Time) (api.ImageMetadata, error) {
-	ctxMap := log.Ctx{
-		"created":   d.creationDate,
-		"ephemeral": d.ephemeral,
-		"used":      d.lastUsedDate}
-
-	meta := api.ImageMetadata{}
-
-	if d.IsRunning() {
-		return meta, fmt.Errorf("Cannot export a running instance as an image")
+	// Send the memory and device state. Once done, the VM is paused.
+	pipeRead, pipeWrite, err := os.Pipe()
+	if err != nil {
+		op.Done(err)
+		return err
 	}
 
-	d.logger.Info("Exporting instance", ctxMap)
+	chStateSent := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(args.StateConn, pipeRead)
+		pipeRead.Close()
+		args.StateConn.Close()
+		chStateSent <- err
+	}()
 
-	// Start the storage.
-	mountInfo, err := d.mount()
+	err = monitor.SendFile("migration", pipeWrite)
 	if err != nil {
-		d.logger.Error("Failed exporting instance", ctxMap)
-		return meta, err
+		pipeWrite.Close()
+		op.Done(err)
+		return err
 	}
-	defer d.unmount()
 
-	// Create the tarball.
-	tarWriter := instancewriter.NewInstanceTarWriter(w, nil)
+	err = monitor.Migrate("fd:migration")
+	pipeWrite.Close()
+	if err != nil {
+		op.Done(err)
+		return err
+	}
 
-	// Path inside the tar image is the pathname starting after cDir.
-	cDir := d.Path()
-	offset := len(cDir) + 1
+	revert.Add(func() { monitor.Start() })
 
-	writeToTar := func(path string, fi os.FileInfo, err error) error {
+	err = <-chStateSent
+	if err != nil {
+		op.Done(err)
+		return err
+	}
+
+	if args.DiskConn != nil {
+		// Finish mirroring the writes made before the VM was paused and disconnect from the target.
+		err = monitor.BlockJobCancel("lxd_migration_mirror")
 		if err != nil {
+			op.Done(err)
 			return err
 		}
 
-		err = tarWriter.WriteFile(path[offset:], path, fi, false)
+		err = monitor.RemoveBlockDevice("lxd_migration_nbd")
 		if err != nil {
-			d.logger.Debug("Error tarring up", log.Ctx{"path": path, "err": err})
+			op.Done(err)
 			return err
 		}
+	}
 
-		return nil
+	// Wait for the target to confirm it is running the instance.
+	err = args.Confirm()
+	if err != nil {
+		op.Done(err)
+		return err
 	}
 
-	// Look for metadata.yaml.
-	fnam := filepath.Join(cDir, "metadata.yaml")
-	if !shared.PathExists(fnam) {
-		// Generate a new metadata.yaml.
-		tempDir, err := ioutil.TempDir("", "lxd_lxd_metadata_")
-		if err != nil {
-			tarWriter.Close()
-			d.logger.Error("Failed exporting instance", ctxMap)
-			return meta, err
-		}
-		defer os.RemoveAll(tempDir)
+	revert.Success()
 
-		// Get the instance's architecture.
-		var arch string
-		if d.IsSnapshot() {
-			parentName, _, _ := shared.InstanceGetParentAndSnapshotName(d.name)
-			parent, err := instance.LoadByProjectAndName(d.state, d.project, parentName)
-			if err != nil {
-				tarWriter.Close()
-				d.logger.Error("Failed exporting instance", ctxMap)
-				return meta, err
-			}
+	// Stop the local QEMU process.
+	chDisconnect, err := monitor.Wait()
+	if err != nil {
+		op.Done(err)
+		return err
+	}
 
-			arch, _ = osarch.ArchitectureName(parent.Architecture())
-		} else {
-			arch, _ = osarch.ArchitectureName(d.architecture)
-		}
+	err = monitor.Quit()
+	if err != nil && err != qmp.ErrMonitorDisconnect {
+		op.Done(err)
+		return err
+	}
 
-		if arch == "" {
-			arch, err = osarch.ArchitectureName(d.state.OS.Architectures[0])
-			if err != nil {
-				d.logger.Error("Failed exporting instance", ctxMap)
-				return meta, err
-			}
-		}
+	<-chDisconnect
 
-		// Fill in the metadata.
-		meta.Architecture = arch
-		meta.CreationDate = time.Now().UTC().Unix()
-		meta.Properties = properties
-		if !expiration.IsZero() {
-			meta.ExpiryDate = expiration.UTC().Unix()
-		}
+	// Wait for onStop to finish cleaning up.
+	err = op.Wait()
+	if err != nil {
+		return err
+	}
 
-		data, err := yaml.Marshal(&meta)
-		if err != nil {
-			tarWriter.Close()
-			d.logger.Error("Failed exporting instance", ctxMap)
-			return meta, err
-		}
+	os.Remove(overlayPath)
 
-		// Write the actual file.
-		fnam = filepath.Join(tempDir, "metadata.yaml")
-		err = ioutil.WriteFile(fnam, data, 0644)
-		if err != nil {
-			tarWriter.Close()
-			d.logger.Error("Failed exporting instance", ctxMap)
-			return meta, err
-		}
+	return nil
+}
 
-		fi, err := os.Lstat(fnam)
-		if err != nil {
-			tarWriter.Close()
-			d.logger.Error("Failed exporting instance", ctxMap)
-			return meta, err
-		}
+// MigrateReceiveLive starts the instance using the running state received from a live migration source.
+// If args.DiskConn is set then the root disk writes made during the migration are received too.
+func (d *qemu) MigrateReceiveLive(args instance.VMLiveMigrateArgs) error {
+	d.logger.Debug("Live migration receive started")
+	defer d.logger.Debug("Live migration receive finished")
 
-		tmpOffset := len(filepath.Dir(fnam)) + 1
-		if err := tarWriter.WriteFile(fnam[tmpOffset:], fnam, fi, false); err != nil {
-			tarWriter.Close()
-			d.logger.Error("Failed exporting instance", ctxMap)
-			return meta, err
-		}
-	} else {
-		// Parse the metadata.
-		content, err := ioutil.ReadFile(fnam)
-		if err != nil {
-			tarWriter.Close()
-			d.logger.Error("Failed exporting instance", ctxMap)
-			return meta, err
-		}
+	if !shared.IsTrue(d.expandedConfig["migration.stateful"]) {
+		return fmt.Errorf("Live migration requires migration.stateful to be set to true")
+	}
 
-		err = yaml.Unmarshal(content, &meta)
-		if err != nil {
-			tarWriter.Close()
-			d.logger.Error("Failed exporting instance", ctxMap)
-			return meta, err
-		}
+	return d.start(false, &args)
+}
 
-		if !expiration.IsZero() {
-			meta.ExpiryDate = expiration.UTC().Unix()
-		}
+// migrateReceiveLive receives the root disk writes and the running state from a live migration source.
+func (d *qemu) migrateReceiveLive(monitor *qmp.Monitor, args instance.VMLiveMigrateArgs) error {
+	var chDiskDone chan struct{}
 
-		if properties != nil {
-			meta.Properties = properties
+	if args.DiskConn != nil {
+		rootDiskName, _, err := shared.GetRootDiskDevice(d.expandedDevices.CloneNative())
+		if err != nil {
+			return errors.Wrapf(err, "Failed getting instance root disk")
 		}
 
-		if properties != nil || !expiration.IsZero() {
-			// Generate a new metadata.yaml.
-			tempDir, err := ioutil.TempDir("", "lxd_lxd_metadata_")
-			if err != nil {
-				tarWriter.Close()
-				d.logger.Error("Failed exporting instance", ctxMap)
-				return meta, err
-			}
-			defer os.RemoveAll(tempDir)
-
-			data, err := yaml.Marshal(&meta)
-			if err != nil {
-				tarWriter.Close()
-				d.logger.Error("Failed exporting instance", ctxMap)
-				return meta, err
-			}
+		// Export the root disk so the source can mirror its writes to it.
+		nbdPath := filepath.Join(d.DevicesPath(), "migration.nbd.sock")
+		os.Remove(nbdPath)
 
-			// Write the actual file.
-			fnam = filepath.Join(tempDir, "metadata.yaml")
-			err = ioutil.WriteFile(fnam, data, 0644)
-			if err != nil {
-				tarWriter.Close()
-				d.logger.Error("Failed exporting instance", ctxMap)
-				return meta, err
-			}
+		err = monitor.NBDServerStart(nbdPath)
+		if err != nil {
+			return err
 		}
 
-		// Include metadata.yaml in the tarball.
-		fi, err := os.Lstat(fnam)
+		err = monitor.NBDBlockExportAdd(fmt.Sprintf("lxd_%s", rootDiskName))
 		if err != nil {
-			tarWriter.Close()
-			d.logger.Debug("Error statting during export", log.Ctx{"fileName": fnam})
-			d.logger.Error("Failed exporting instance", ctxMap)
-			return meta, err
+			monitor.NBDServerStop()
+			return err
 		}
 
-		if properties != nil || !expiration.IsZero() {
-			tmpOffset := len(filepath.Dir(fnam)) + 1
-			err = tarWriter.WriteFile(fnam[tmpOffset:], fnam, fi, false)
-		} else {
-			err = tarWriter.WriteFile(fnam[offset:], fnam, fi, false)
-		}
+		conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: nbdPath, Net: "unix"})
 		if err != nil {
-			tarWriter.Close()
-			d.logger.Debug("Error writing to tarfile", log.Ctx{"err": err})
-			d.logger.Error("Failed exporting instance", ctxMap)
-			return meta, err
+			monitor.NBDServerStop()
+			return err
 		}
+
+		chDiskDone = make(chan struct{})
+		go func() {
+			qemuMigrationProxy(conn, args.DiskConn)
+			close(chDiskDone)
+		}()
 	}
 
-	// Convert from raw to qcow2 and add to tarball.
-	tmpPath, err := ioutil.TempDir(shared.VarPath("images"), "lxd_export_")
+	pipeRead, pipeWrite, err := os.Pipe()
 	if err != nil {
-		return meta, err
+		return err
 	}
-	defer os.RemoveAll(tmpPath)
 
-	if mountInfo.DiskPath == "" {
-		return meta, fmt.Errorf("No disk path available from mount")
-	}
+	go func() {
+		io.Copy(pipeWrite, args.StateConn)
+		pipeWrite.Close()
+		pipeRead.Close()
+	}()
 
-	fPath := fmt.Sprintf("%s/rootfs.img", tmpPath)
-	_, err = shared.RunCommand("qemu-img", "convert", "-c", "-O", "qcow2", mountInfo.DiskPath, fPath)
+	err = monitor.SendFile("migration", pipeRead)
 	if err != nil {
-		return meta, fmt.Errorf("Failed converting image to qcow2: %v", err)
+		return err
 	}
 
-	fi, err := os.Lstat(fPath)
+	err = monitor.MigrateIncoming("fd:migration")
 	if err != nil {
-		return meta, err
+		return err
 	}
 
-	imgOffset := len(tmpPath) + 1
-	err = tarWriter.WriteFile(fPath[imgOffset:], fPath, fi, false)
-	if err != nil {
-		return meta, err
-	}
+	// Wait for the source to disconnect from the root disk export before resuming the VM.
+	if chDiskDone != nil {
+		<-chDiskDone
 
-	// Include all the templates.
-	fnam = d.TemplatesPath()
-	if shared.PathExists(fnam) {
-		err = filepath.Walk(fnam, writeToTar)
+		err = monitor.NBDServerStop()
 		if err != nil {
-			d.logger.Error("Failed exporting instance", ctxMap)
-			return meta, err
+			return err
 		}
 	}
 
-	err = tarWriter.Close()
-	if err != nil {
-		d.logger.Error("Failed exporting instance", ctxMap)
-		return meta, err
-	}
+	return nil
+}
 
-	d.logger.Info("Exported instance", ctxMap)
-	return meta, nil
+// qemuMigrationProxy copies data in both directions between a local connection and a migration connection
+// until both sides have finished.
+func qemuMigrationProxy(local *net.UnixConn, remote io.ReadWriteCloser) {
+	chDone := make(chan struct{}, 2)
+
+	go func() {
+		io.Copy(remote, local)
+		remote.Close()
+		chDone <- struct{}{}
+	}()
+
+	go func() {
+		io.Copy(local, remote)
+		local.CloseWrite()
+		chDone <- struct{}{}
+	}()
+
+	<-chDone
+	<-chDone
+	local.Close()
 }
 
 // Migrate starts the instance from a migrated state file.
@@ -5374,7 +7230,37 @@ func (d *qemu) CanMigrate() bool {
 
 // DeviceEventHandler handles events occurring on the instance's devices.
 func (d *qemu) DeviceEventHandler(runConf *deviceConfig.RunConfig) error {
-	return fmt.Errorf("DeviceEventHandler Not implemented")
+	// Device events can only be processed when the instance is running.
+	if !d.IsRunning() || runConf == nil {
+		return nil
+	}
+
+	// Attach or detach USB devices depending on the action of the uevent that was received.
+	for _, eventParts := range runConf.Uevents {
+		for _, part := range eventParts {
+			fields := strings.SplitN(part, "=", 2)
+			if len(fields) != 2 || fields[0] != "ACTION" {
+				continue
+			}
+
+			for _, usbDev := range runConf.USBDevice {
+				switch fields[1] {
+				case "add":
+					err := d.deviceAttachUSB(usbDev)
+					if err != nil {
+						return err
+					}
+				case "remove":
+					err := d.deviceDetachUSB(usbDev)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return nil
 }
 
 // vsockID returns the vsock context ID, 3 being the first ID that can be used.
@@ -5432,6 +7318,11 @@ func (d *qemu) statusCode() api.StatusCode {
 	}
 
 	if status == "running" {
+		// Report the workload as ready if it said so through devlxd.
+		if shared.IsTrue(d.LocalConfig()["volatile.last_state.ready"]) {
+			return api.Ready
+		}
+
 		return api.Running
 	} else if status == "paused" {
 		return api.Frozen
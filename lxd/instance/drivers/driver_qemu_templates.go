@@ -50,6 +50,10 @@ var qemuMemory = template.Must(template.New("qemuMemory").Parse(`
 # Memory
 [memory]
 size = "{{.memSizeBytes}}M"
+{{- if .maxMemSizeBytes}}
+slots = "{{.memSlots}}"
+maxmem = "{{.maxMemSizeBytes}}M"
+{{- end}}
 `))
 
 var qemuSerial = template.Must(template.New("qemuSerial").Parse(`
@@ -238,6 +242,9 @@ var qemuCPU = template.Must(template.New("qemuCPU").Parse(`
 # CPU
 [smp-opts]
 cpus = "{{.cpuCount}}"
+{{- if .cpuMaxCount}}
+maxcpus = "{{.cpuMaxCount}}"
+{{- end}}
 sockets = "{{.cpuSockets}}"
 cores = "{{.cpuCores}}"
 threads = "{{.cpuThreads}}"
@@ -443,17 +450,58 @@ readonly = "on"
 {{- else}}
 readonly = "off"
 {{- end}}
+{{- if .ioThread}}
+
+[object "iothread_{{.devName}}"]
+qom-type = "iothread"
+{{- if eq .ioBus "virtio-scsi"}}
+
+[device "qemu_scsi_{{.devName}}"]
+{{- if eq .bus "pci" "pcie"}}
+driver = "virtio-scsi-pci"
+bus = "{{.devBus}}"
+addr = "{{.devAddr}}"
+{{- end}}
+{{- if eq .bus "ccw"}}
+driver = "virtio-scsi-ccw"
+{{- end}}
+iothread = "iothread_{{.devName}}"
+{{- end}}
+{{- end}}
 
 [device "dev-lxd_{{.devName}}"]
+{{- if eq .ioBus "virtio-blk"}}
+{{- if eq .bus "pci" "pcie"}}
+driver = "virtio-blk-pci"
+bus = "{{.devBus}}"
+addr = "{{.devAddr}}"
+{{- end}}
+{{- if eq .bus "ccw"}}
+driver = "virtio-blk-ccw"
+{{- end}}
+{{- if .ioThread}}
+iothread = "iothread_{{.devName}}"
+{{- end}}
+{{- else if eq .ioBus "nvme"}}
+driver = "nvme"
+bus = "{{.devBus}}"
+addr = "{{.devAddr}}"
+serial = "lxd_{{.devName}}"
+{{- else}}
 {{- if eq .media "disk" }}
 driver = "scsi-hd"
 {{- else}}
 driver = "scsi-cd"
 {{- end }}
+{{- if .ioThread}}
+bus = "qemu_scsi_{{.devName}}.0"
+{{- else}}
 bus = "qemu_scsi.0"
+{{- end}}
 channel = "0"
 scsi-id = "{{.bootIndex}}"
 lun = "1"
+{{- end}}
 drive = "lxd_{{.devName}}"
 bootindex = "{{.bootIndex}}"
 {{if .multifunction -}}
@@ -1,6 +1,7 @@
 package qmp
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -90,6 +91,82 @@ func (m *Monitor) SendFile(name string, file *os.File) error {
 	return nil
 }
 
+// FDSet represents a file descriptor set.
+type FDSet struct {
+	ID int `json:"fdset-id"`
+	FD int `json:"fd"`
+}
+
+// AddFDToFDSet passes a file descriptor to QEMU in a new file descriptor set tagged with name.
+// The file descriptor set can then be referenced by QEMU as /dev/fdset/<ID>.
+func (m *Monitor) AddFDToFDSet(name string, file *os.File) (*FDSet, error) {
+	// Check if disconnected
+	if m.disconnected {
+		return nil, ErrMonitorDisconnect
+	}
+
+	out, err := m.qmp.RunWithFile([]byte(fmt.Sprintf("{'execute': 'add-fd', 'arguments': {'opaque': '%s'}}", name)), file)
+	if err != nil {
+		// Confirm the daemon didn't die.
+		errPing := m.ping()
+		if errPing != nil {
+			return nil, errPing
+		}
+
+		return nil, err
+	}
+
+	var resp struct {
+		Return FDSet `json:"return"`
+	}
+
+	err = json.Unmarshal(out, &resp)
+	if err != nil {
+		return nil, ErrMonitorBadReturn
+	}
+
+	return &resp.Return, nil
+}
+
+// RemoveFDFromFDSet removes the file descriptor sets tagged with name.
+// File descriptors still in use by QEMU are only closed once no longer needed.
+func (m *Monitor) RemoveFDFromFDSet(name string) error {
+	var resp struct {
+		Return []struct {
+			ID  int `json:"fdset-id"`
+			FDs []struct {
+				FD     int    `json:"fd"`
+				Opaque string `json:"opaque"`
+			} `json:"fds"`
+		} `json:"return"`
+	}
+
+	err := m.run("query-fdsets", nil, &resp)
+	if err != nil {
+		return errors.Wrapf(err, "Failed querying file descriptor sets")
+	}
+
+	for _, fdSet := range resp.Return {
+		for _, fd := range fdSet.FDs {
+			if fd.Opaque != name {
+				continue
+			}
+
+			args := map[string]int{
+				"fdset-id": fdSet.ID,
+				"fd":       fd.FD,
+			}
+
+			err = m.run("remove-fd", args, nil)
+			if err != nil {
+				return errors.Wrapf(err, "Failed removing file descriptor %d from set %d", fd.FD, fdSet.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
 // Migrate starts a migration stream.
 func (m *Monitor) Migrate(uri string) error {
 	// Query the status.
@@ -226,6 +303,23 @@ func (m *Monitor) GetMemorySizeBytes() (int64, error) {
 	return resp.Return.BaseMemory, nil
 }
 
+// GetPluggedMemorySizeBytes returns the size of the hotplugged memory in bytes.
+func (m *Monitor) GetPluggedMemorySizeBytes() (int64, error) {
+	// Prepare the response.
+	var resp struct {
+		Return struct {
+			PluggedMemory int64 `json:"plugged-memory"`
+		} `json:"return"`
+	}
+
+	err := m.run("query-memory-size-summary", nil, &resp)
+	if err != nil {
+		return -1, err
+	}
+
+	return resp.Return.PluggedMemory, nil
+}
+
 // GetMemoryBalloonSizeBytes returns effective size of the memory in bytes (considering the current balloon size).
 func (m *Monitor) GetMemoryBalloonSizeBytes() (int64, error) {
 	// Prepare the response.
@@ -249,6 +343,70 @@ func (m *Monitor) SetMemoryBalloonSizeBytes(sizeBytes int64) error {
 	return m.run("balloon", args, nil)
 }
 
+// HotpluggableCPU represents a vCPU slot.
+type HotpluggableCPU struct {
+	Type    string         `json:"type"`
+	Props   map[string]int `json:"props"`
+	QOMPath string         `json:"qom-path"`
+}
+
+// QueryHotpluggableCPUs returns the vCPU slots of the VM, the ones in use have a QOM path.
+func (m *Monitor) QueryHotpluggableCPUs() ([]HotpluggableCPU, error) {
+	var resp struct {
+		Return []HotpluggableCPU `json:"return"`
+	}
+
+	err := m.run("query-hotpluggable-cpus", nil, &resp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed querying hotpluggable CPUs")
+	}
+
+	return resp.Return, nil
+}
+
+// AddCPU adds a vCPU into the given slot.
+func (m *Monitor) AddCPU(deviceID string, cpu HotpluggableCPU) error {
+	args := map[string]interface{}{
+		"id":     deviceID,
+		"driver": cpu.Type,
+	}
+
+	for k, v := range cpu.Props {
+		args[k] = v
+	}
+
+	err := m.run("device_add", args, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed adding CPU")
+	}
+
+	return nil
+}
+
+// AddObject adds an object.
+func (m *Monitor) AddObject(object map[string]interface{}) error {
+	err := m.run("object-add", object, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed adding object %q", object["id"])
+	}
+
+	return nil
+}
+
+// RemoveObject removes an object.
+func (m *Monitor) RemoveObject(objectID string) error {
+	args := map[string]string{
+		"id": objectID,
+	}
+
+	err := m.run("object-del", args, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed removing object %q", objectID)
+	}
+
+	return nil
+}
+
 // AddNIC adds a NIC device.
 func (m *Monitor) AddNIC(netDev map[string]interface{}, device map[string]string) error {
 	revert := revert.New()
@@ -315,6 +473,87 @@ func (m *Monitor) RemoveNIC(netDevID string, deviceID string) error {
 	return nil
 }
 
+// AddDevice adds a device.
+func (m *Monitor) AddDevice(device map[string]string) error {
+	err := m.run("device_add", device, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed adding device %q", device["id"])
+	}
+
+	return nil
+}
+
+// RemoveDevice requests the removal of a device.
+// The removal of some devices requires cooperation from the guest, use DeviceExists to check for completion.
+func (m *Monitor) RemoveDevice(deviceID string) error {
+	args := map[string]string{
+		"id": deviceID,
+	}
+
+	err := m.run("device_del", args, nil)
+	if err != nil {
+		// If the device has already been removed then all good.
+		if !strings.Contains(err.Error(), "not found") {
+			return errors.Wrapf(err, "Failed removing device %q", deviceID)
+		}
+	}
+
+	return nil
+}
+
+// DeviceExists checks whether a user created device with the given ID exists.
+func (m *Monitor) DeviceExists(deviceID string) (bool, error) {
+	var resp struct {
+		Return []struct {
+			Name string `json:"name"`
+		} `json:"return"`
+	}
+
+	args := map[string]string{
+		"path": "/machine/peripheral",
+	}
+
+	err := m.run("qom-list", args, &resp)
+	if err != nil {
+		return false, errors.Wrapf(err, "Failed listing devices")
+	}
+
+	for _, dev := range resp.Return {
+		if dev.Name == deviceID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// AddCharDevice adds a character device.
+func (m *Monitor) AddCharDevice(charDev map[string]interface{}) error {
+	err := m.run("chardev-add", charDev, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed adding character device %q", charDev["id"])
+	}
+
+	return nil
+}
+
+// RemoveCharDevice removes a character device.
+func (m *Monitor) RemoveCharDevice(charDevID string) error {
+	args := map[string]string{
+		"id": charDevID,
+	}
+
+	err := m.run("chardev-remove", args, nil)
+	if err != nil {
+		// If the character device has already been removed then all good.
+		if !strings.Contains(err.Error(), "not found") {
+			return errors.Wrapf(err, "Failed removing character device %q", charDevID)
+		}
+	}
+
+	return nil
+}
+
 // Reset VM.
 func (m *Monitor) Reset() error {
 	err := m.run("system_reset", nil, nil)
@@ -367,3 +606,246 @@ func (m *Monitor) QueryPCI() ([]PCIDevice, error) {
 
 	return nil, nil
 }
+
+// BlockDevSnapshot creates a qcow2 overlay on top of a block device and redirects further writes to it.
+func (m *Monitor) BlockDevSnapshot(deviceName string, overlayPath string, overlayNodeName string) error {
+	args := map[string]string{
+		"device":             deviceName,
+		"snapshot-file":      overlayPath,
+		"snapshot-node-name": overlayNodeName,
+		"format":             "qcow2",
+		"mode":               "absolute-paths",
+	}
+
+	err := m.run("blockdev-snapshot-sync", args, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed snapshotting block device %q", deviceName)
+	}
+
+	return nil
+}
+
+// AddBlockDevice adds a block device node.
+func (m *Monitor) AddBlockDevice(blockDev map[string]interface{}) error {
+	err := m.run("blockdev-add", blockDev, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed adding block device")
+	}
+
+	return nil
+}
+
+// RemoveBlockDevice removes a block device node.
+func (m *Monitor) RemoveBlockDevice(nodeName string) error {
+	args := map[string]string{
+		"node-name": nodeName,
+	}
+
+	err := m.run("blockdev-del", args, nil)
+	if err != nil {
+		// If the node has already been removed then all good.
+		if !strings.Contains(err.Error(), "Failed to find node") {
+			return errors.Wrapf(err, "Failed removing block device")
+		}
+	}
+
+	return nil
+}
+
+// BlockDevMirror starts a job that mirrors the writes of the top image of a block device to a target node.
+func (m *Monitor) BlockDevMirror(deviceName string, targetNodeName string, jobID string) error {
+	args := map[string]string{
+		"job-id": jobID,
+		"device": deviceName,
+		"target": targetNodeName,
+		"sync":   "top",
+	}
+
+	err := m.run("blockdev-mirror", args, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed mirroring block device %q", deviceName)
+	}
+
+	return nil
+}
+
+// BlockCommit starts a job that merges the active layer of a block device back into its backing image.
+func (m *Monitor) BlockCommit(deviceName string, jobID string) error {
+	args := map[string]string{
+		"job-id": jobID,
+		"device": deviceName,
+	}
+
+	err := m.run("block-commit", args, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed committing block device %q", deviceName)
+	}
+
+	return nil
+}
+
+// blockJob represents a running block job.
+type blockJob struct {
+	Device string `json:"device"`
+	Ready  bool   `json:"ready"`
+	Len    int64  `json:"len"`
+	Offset int64  `json:"offset"`
+}
+
+// getBlockJob returns the block job matching jobID, or nil if the job isn't running.
+func (m *Monitor) getBlockJob(jobID string) (*blockJob, error) {
+	// Prepare the response.
+	var resp struct {
+		Return []blockJob `json:"return"`
+	}
+
+	err := m.run("query-block-jobs", nil, &resp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed querying block jobs")
+	}
+
+	for _, job := range resp.Return {
+		if job.Device == jobID {
+			return &job, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// BlockJobWaitReady waits until a block job has synchronised its target and is ready to be completed.
+// The progress function (if not nil) is called periodically with the number of bytes processed and the total.
+func (m *Monitor) BlockJobWaitReady(jobID string, progress func(offset int64, length int64)) error {
+	for {
+		job, err := m.getBlockJob(jobID)
+		if err != nil {
+			return err
+		}
+
+		if job == nil {
+			return fmt.Errorf("Block job %q has stopped unexpectedly", jobID)
+		}
+
+		if progress != nil {
+			progress(job.Offset, job.Len)
+		}
+
+		if job.Ready {
+			return nil
+		}
+
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// BlockJobCancel cancels a block job and waits for it to stop.
+// For a mirror job that is ready, this leaves the target in a consistent state.
+func (m *Monitor) BlockJobCancel(jobID string) error {
+	args := map[string]string{
+		"device": jobID,
+	}
+
+	err := m.run("block-job-cancel", args, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed cancelling block job %q", jobID)
+	}
+
+	return m.blockJobWaitStopped(jobID)
+}
+
+// BlockJobComplete completes a block job that is ready and waits for it to stop.
+func (m *Monitor) BlockJobComplete(jobID string) error {
+	args := map[string]string{
+		"device": jobID,
+	}
+
+	err := m.run("block-job-complete", args, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed completing block job %q", jobID)
+	}
+
+	return m.blockJobWaitStopped(jobID)
+}
+
+// blockJobWaitStopped waits until a block job is no longer running.
+func (m *Monitor) blockJobWaitStopped(jobID string) error {
+	for {
+		job, err := m.getBlockJob(jobID)
+		if err != nil {
+			return err
+		}
+
+		if job == nil {
+			return nil
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// NBDServerStart starts the internal NBD server listening on the given Unix socket path.
+func (m *Monitor) NBDServerStart(path string) error {
+	args := map[string]interface{}{
+		"addr": map[string]interface{}{
+			"type": "unix",
+			"data": map[string]string{
+				"path": path,
+			},
+		},
+	}
+
+	err := m.run("nbd-server-start", args, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed starting NBD server")
+	}
+
+	return nil
+}
+
+// NBDBlockExportAdd exports a writable block device via the internal NBD server.
+func (m *Monitor) NBDBlockExportAdd(deviceName string) error {
+	args := map[string]interface{}{
+		"device":   deviceName,
+		"writable": true,
+	}
+
+	err := m.run("nbd-server-add", args, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed adding NBD block export for %q", deviceName)
+	}
+
+	return nil
+}
+
+// NBDServerStop stops the internal NBD server.
+func (m *Monitor) NBDServerStop() error {
+	err := m.run("nbd-server-stop", nil, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed stopping NBD server")
+	}
+
+	return nil
+}
+
+// MigrateSetCapabilities sets the migration capabilities.
+func (m *Monitor) MigrateSetCapabilities(caps map[string]bool) error {
+	type capability struct {
+		Capability string `json:"capability"`
+		State      bool   `json:"state"`
+	}
+
+	args := struct {
+		Capabilities []capability `json:"capabilities"`
+	}{}
+
+	for name, state := range caps {
+		args.Capabilities = append(args.Capabilities, capability{Capability: name, State: state})
+	}
+
+	err := m.run("migrate-set-capabilities", args, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed setting migration capabilities")
+	}
+
+	return nil
+}
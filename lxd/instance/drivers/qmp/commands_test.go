@@ -0,0 +1,319 @@
+package qmp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQMPCommand is a command received by the fake QMP server.
+type fakeQMPCommand struct {
+	Execute   string          `json:"execute"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// fakeQMPServer is a minimal QMP server that replies to commands using a handler function.
+type fakeQMPServer struct {
+	path     string
+	listener *net.UnixListener
+	handler  func(cmd fakeQMPCommand) (interface{}, error)
+
+	mu       sync.Mutex
+	commands []fakeQMPCommand
+}
+
+// newFakeQMPServer starts a fake QMP server listening on a temporary Unix socket.
+func newFakeQMPServer(t *testing.T, handler func(cmd fakeQMPCommand) (interface{}, error)) *fakeQMPServer {
+	dir, err := ioutil.TempDir("", "lxd-qmp-test-")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "qmp.monitor")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	require.NoError(t, err)
+
+	s := &fakeQMPServer{path: path, listener: listener, handler: handler}
+	go s.serve()
+
+	t.Cleanup(func() {
+		monitorsLock.Lock()
+		monitor, ok := monitors[path]
+		monitorsLock.Unlock()
+
+		if ok {
+			monitor.Disconnect()
+		}
+
+		listener.Close()
+	})
+
+	return s
+}
+
+func (s *fakeQMPServer) serve() {
+	conn, err := s.listener.AcceptUnix()
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(singleQuoteReader{conn})
+
+	err = enc.Encode(map[string]interface{}{
+		"QMP": map[string]interface{}{
+			"version":      map[string]interface{}{},
+			"capabilities": []string{"oob"},
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	for {
+		var cmd fakeQMPCommand
+		err := dec.Decode(&cmd)
+		if err != nil {
+			return
+		}
+
+		var ret interface{} = map[string]interface{}{}
+
+		switch cmd.Execute {
+		case "qmp_capabilities", "query-version":
+		case "ringbuf-read":
+			ret = ""
+		default:
+			s.mu.Lock()
+			s.commands = append(s.commands, cmd)
+			s.mu.Unlock()
+
+			ret, err = s.handler(cmd)
+		}
+
+		if err != nil {
+			err = enc.Encode(map[string]interface{}{
+				"error": map[string]interface{}{"class": "GenericError", "desc": err.Error()},
+			})
+		} else {
+			err = enc.Encode(map[string]interface{}{"return": ret})
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// singleQuoteReader converts the single quoted strings QEMU accepts (and ping sends) to valid JSON.
+type singleQuoteReader struct {
+	r io.Reader
+}
+
+func (r singleQuoteReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	for i := range p[:n] {
+		if p[i] == '\'' {
+			p[i] = '"'
+		}
+	}
+
+	return n, err
+}
+
+// executed returns the names of the commands received by the server so far.
+func (s *fakeQMPServer) executed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.commands))
+	for _, cmd := range s.commands {
+		names = append(names, cmd.Execute)
+	}
+
+	return names
+}
+
+func (s *fakeQMPServer) connect(t *testing.T) *Monitor {
+	monitor, err := Connect(s.path, "qemu_serial", nil)
+	require.NoError(t, err)
+
+	return monitor
+}
+
+// migrateStatusHandler returns a handler that reports the given query-migrate statuses in order.
+func migrateStatusHandler(statuses ...string) func(cmd fakeQMPCommand) (interface{}, error) {
+	return func(cmd fakeQMPCommand) (interface{}, error) {
+		if cmd.Execute != "query-migrate" {
+			return map[string]interface{}{}, nil
+		}
+
+		status := statuses[0]
+		if len(statuses) > 1 {
+			statuses = statuses[1:]
+		}
+
+		return map[string]interface{}{"status": status}, nil
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	s := newFakeQMPServer(t, migrateStatusHandler("active", "completed"))
+	monitor := s.connect(t)
+
+	err := monitor.Migrate("fd:migration")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"migrate", "query-migrate", "query-migrate"}, s.executed())
+
+	var args map[string]string
+	require.NoError(t, json.Unmarshal(s.commands[0].Arguments, &args))
+	assert.Equal(t, "fd:migration", args["uri"])
+}
+
+func TestMigrate_Failed(t *testing.T) {
+	s := newFakeQMPServer(t, migrateStatusHandler("failed"))
+	monitor := s.connect(t)
+
+	err := monitor.Migrate("fd:migration")
+	assert.EqualError(t, err, "Migration call failed")
+}
+
+func TestMigrateIncoming(t *testing.T) {
+	s := newFakeQMPServer(t, migrateStatusHandler("completed"))
+	monitor := s.connect(t)
+
+	err := monitor.MigrateIncoming("fd:migration")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"migrate-incoming", "query-migrate"}, s.executed())
+}
+
+func TestMigrate_CommandError(t *testing.T) {
+	s := newFakeQMPServer(t, func(cmd fakeQMPCommand) (interface{}, error) {
+		return nil, fmt.Errorf("Migration is disabled")
+	})
+
+	monitor := s.connect(t)
+
+	err := monitor.Migrate("fd:migration")
+	assert.EqualError(t, err, "Migration is disabled")
+	assert.Equal(t, []string{"migrate"}, s.executed())
+}
+
+func TestMigrateSetCapabilities(t *testing.T) {
+	s := newFakeQMPServer(t, func(cmd fakeQMPCommand) (interface{}, error) {
+		return map[string]interface{}{}, nil
+	})
+
+	monitor := s.connect(t)
+
+	err := monitor.MigrateSetCapabilities(map[string]bool{"auto-converge": true})
+	require.NoError(t, err)
+
+	var args struct {
+		Capabilities []struct {
+			Capability string `json:"capability"`
+			State      bool   `json:"state"`
+		} `json:"capabilities"`
+	}
+
+	require.NoError(t, json.Unmarshal(s.commands[0].Arguments, &args))
+	require.Len(t, args.Capabilities, 1)
+	assert.Equal(t, "auto-converge", args.Capabilities[0].Capability)
+	assert.True(t, args.Capabilities[0].State)
+}
+
+func TestBlockJobWaitReady(t *testing.T) {
+	jobs := [][]blockJob{
+		{{Device: "other", Ready: true}, {Device: "mirror", Len: 100, Offset: 50}},
+		{{Device: "mirror", Ready: true, Len: 100, Offset: 100}},
+	}
+
+	s := newFakeQMPServer(t, func(cmd fakeQMPCommand) (interface{}, error) {
+		ret := jobs[0]
+		if len(jobs) > 1 {
+			jobs = jobs[1:]
+		}
+
+		return ret, nil
+	})
+
+	monitor := s.connect(t)
+
+	var offsets []int64
+	err := monitor.BlockJobWaitReady("mirror", func(offset int64, length int64) {
+		assert.Equal(t, int64(100), length)
+		offsets = append(offsets, offset)
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []int64{50, 100}, offsets)
+}
+
+func TestBlockJobWaitReady_Stopped(t *testing.T) {
+	s := newFakeQMPServer(t, func(cmd fakeQMPCommand) (interface{}, error) {
+		return []blockJob{}, nil
+	})
+
+	monitor := s.connect(t)
+
+	err := monitor.BlockJobWaitReady("mirror", nil)
+	assert.EqualError(t, err, `Block job "mirror" has stopped unexpectedly`)
+}
+
+func TestBlockJobCancel(t *testing.T) {
+	polls := 0
+	s := newFakeQMPServer(t, func(cmd fakeQMPCommand) (interface{}, error) {
+		if cmd.Execute != "query-block-jobs" {
+			return map[string]interface{}{}, nil
+		}
+
+		// Report the job as still running on the first poll.
+		polls++
+		if polls == 1 {
+			return []blockJob{{Device: "mirror", Ready: true}}, nil
+		}
+
+		return []blockJob{}, nil
+	})
+
+	monitor := s.connect(t)
+
+	err := monitor.BlockJobCancel("mirror")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"block-job-cancel", "query-block-jobs", "query-block-jobs"}, s.executed())
+}
+
+func TestRemoveBlockDevice(t *testing.T) {
+	s := newFakeQMPServer(t, func(cmd fakeQMPCommand) (interface{}, error) {
+		var args map[string]string
+		err := json.Unmarshal(cmd.Arguments, &args)
+		if err != nil {
+			return nil, err
+		}
+
+		if args["node-name"] == "missing" {
+			return nil, fmt.Errorf("Failed to find node with node-name='missing'")
+		}
+
+		return nil, fmt.Errorf("Node is in use")
+	})
+
+	monitor := s.connect(t)
+
+	// Removing a node that is already gone succeeds.
+	err := monitor.RemoveBlockDevice("missing")
+	assert.NoError(t, err)
+
+	err = monitor.RemoveBlockDevice("lxd_migration_nbd")
+	assert.EqualError(t, err, "Failed removing block device: Node is in use")
+}
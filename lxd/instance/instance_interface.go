@@ -1,6 +1,7 @@
 package instance
 
 import (
+	"crypto/x509"
 	"io"
 	"os"
 	"time"
@@ -18,6 +19,9 @@ import (
 	"github.com/lxc/lxd/shared/idmap"
 )
 
+// FreezeTimeout is how long guest filesystems may stay frozen before the agent automatically thaws them.
+const FreezeTimeout = 5 * time.Minute
+
 // HookStart hook used when instance has started.
 const HookStart = "onstart"
 
@@ -171,6 +175,36 @@ type Container interface {
 	IdmappedStorage(path string) idmap.IdmapStorageType
 }
 
+// VM interface is for virtual machine specific functions.
+type VM interface {
+	Instance
+
+	MigrateSendLive(args VMLiveMigrateArgs) error
+	MigrateReceiveLive(args VMLiveMigrateArgs) error
+
+	UEFIVars() (*api.InstanceUEFIVars, error)
+	UEFIVarsUpdate(newUEFIVarsSet api.InstanceUEFIVars) error
+
+	AgentCertificate() *x509.Certificate
+}
+
+// VMLiveMigrateArgs arguments for live migrating a running virtual machine.
+type VMLiveMigrateArgs struct {
+	// StateConn carries the VM's memory and device state.
+	StateConn io.ReadWriteCloser
+
+	// DiskConn carries the root disk writes made during the migration (nil when the storage is shared).
+	DiskConn io.ReadWriteCloser
+
+	// DiskTransfer is run by the source once the root disk has been snapshotted, to transfer the snapshotted
+	// disk contents to the target before the remaining writes are mirrored over DiskConn.
+	DiskTransfer func() error
+
+	// Confirm is run by the source once the state has been transferred and waits for the target to report
+	// whether it has successfully resumed the VM.
+	Confirm func() error
+}
+
 // CriuMigrationArgs arguments for CRIU migration.
 type CriuMigrationArgs struct {
 	Cmd          uint
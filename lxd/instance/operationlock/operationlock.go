@@ -28,6 +28,12 @@ const ActionRestart Action = "restart"
 // ActionRestore for restoring an instance.
 const ActionRestore Action = "restore"
 
+// ActionMigrate for live migrating an instance.
+const ActionMigrate Action = "migrate"
+
+// ActionUEFIVars for reading or updating an instance's UEFI variables.
+const ActionUEFIVars Action = "uefi-vars"
+
 // ErrNonReusuableSucceeded is returned when no operation is created due to having to wait for a matching
 // non-reusuable operation that has now completed successfully.
 var ErrNonReusuableSucceeded error = fmt.Errorf("A matching non-reusable operation has now succeeded")
@@ -1,15 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"path"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 
+	"github.com/lxc/lxd/lxd/backup"
+	backupTarget "github.com/lxc/lxd/lxd/backup/target"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
@@ -127,39 +131,83 @@ func instanceBackupsGet(d *Daemon, r *http.Request) response.Response {
 
 	// Handle requests targeted to a container on a different node
 	resp, err := forwardedResponseIfInstanceIsRemote(d, r, projectName, cname, instanceType)
-	if err != nil {
+	if err != nil && err != db.ErrNoSuchObject {
 		return response.SmartError(err)
 	}
 	if resp != nil {
 		return resp
 	}
 
+	// The backups of an instance which no longer exists can still be held on the backups target.
+	instanceExists := err == nil
+
 	recursion := util.IsRecursionRequest(r)
 
-	c, err := instance.LoadByProjectAndName(d.State(), projectName, cname)
-	if err != nil {
-		return response.SmartError(err)
+	resultString := []string{}
+	resultMap := []*api.InstanceBackup{}
+	localBackups := map[string]bool{}
+
+	if instanceExists {
+		c, err := instance.LoadByProjectAndName(d.State(), projectName, cname)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		backups, err := c.Backups()
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		for _, backup := range backups {
+			backupName := strings.Split(backup.Name(), "/")[1]
+			localBackups[backupName] = true
+
+			if !recursion {
+				url := fmt.Sprintf("/%s/instances/%s/backups/%s",
+					version.APIVersion, cname, backupName)
+				resultString = append(resultString, url)
+			} else {
+				render := backup.Render()
+				resultMap = append(resultMap, render)
+			}
+		}
 	}
 
-	backups, err := c.Backups()
+	// Add the backups only held on the backups target.
+	t, err := backup.LoadTarget(d.State(), projectName)
 	if err != nil {
 		return response.SmartError(err)
 	}
 
-	resultString := []string{}
-	resultMap := []*api.InstanceBackup{}
+	if t != nil {
+		objects, err := t.List(backupTarget.InstanceBackupsPrefix(projectName, cname))
+		if err != nil {
+			return response.SmartError(errors.Wrapf(err, "Failed listing backups target"))
+		}
+
+		for _, object := range objects {
+			backupName := path.Base(object.Name)
+			if localBackups[backupName] {
+				continue
+			}
 
-	for _, backup := range backups {
-		if !recursion {
-			url := fmt.Sprintf("/%s/instances/%s/backups/%s",
-				version.APIVersion, cname, strings.Split(backup.Name(), "/")[1])
-			resultString = append(resultString, url)
-		} else {
-			render := backup.Render()
-			resultMap = append(resultMap, render)
+			if !recursion {
+				url := fmt.Sprintf("/%s/instances/%s/backups/%s",
+					version.APIVersion, cname, backupName)
+				resultString = append(resultString, url)
+			} else {
+				resultMap = append(resultMap, &api.InstanceBackup{
+					Name:      backupName,
+					CreatedAt: object.ModTime,
+				})
+			}
 		}
 	}
 
+	if !instanceExists && len(resultString) == 0 && len(resultMap) == 0 {
+		return response.NotFound(fmt.Errorf("Instance not found"))
+	}
+
 	if !recursion {
 		return response.SyncResponse(true, resultString)
 	}
@@ -262,28 +310,15 @@ func instanceBackupsPost(d *Daemon, r *http.Request) response.Response {
 			return response.BadRequest(err)
 		}
 
-		base := name + shared.SnapshotDelimiter + "backup"
-		length := len(base)
-		max := 0
-
+		backupNames := make([]string, 0, len(backups))
 		for _, backup := range backups {
-			// Ignore backups not containing base.
-			if !strings.HasPrefix(backup.Name(), base) {
-				continue
-			}
-
-			substr := backup.Name()[length:]
-			var num int
-			count, err := fmt.Sscanf(substr, "%d", &num)
-			if err != nil || count != 1 {
-				continue
-			}
-			if num >= max {
-				max = num + 1
-			}
+			backupNames = append(backupNames, backup.Name())
 		}
 
-		req.Name = fmt.Sprintf("backup%d", max)
+		req.Name, err = backupNextName("backup%d", backupNames)
+		if err != nil {
+			return response.InternalError(err)
+		}
 	}
 
 	// Validate the name.
@@ -294,6 +329,14 @@ func instanceBackupsPost(d *Daemon, r *http.Request) response.Response {
 	fullName := name + shared.SnapshotDelimiter + req.Name
 	instanceOnly := req.InstanceOnly || req.ContainerOnly
 
+	if strings.Contains(req.Incremental, "/") {
+		return response.BadRequest(fmt.Errorf("Parent backup names may not contain slashes"))
+	}
+
+	if req.Incremental == req.Name {
+		return response.BadRequest(fmt.Errorf("Backups cannot be their own parent"))
+	}
+
 	backup := func(op *operations.Operation) error {
 		args := db.InstanceBackup{
 			Name:                 fullName,
@@ -305,7 +348,7 @@ func instanceBackupsPost(d *Daemon, r *http.Request) response.Response {
 			CompressionAlgorithm: req.CompressionAlgorithm,
 		}
 
-		err := backupCreate(d.State(), args, inst)
+		err := backupCreate(context.Background(), d.State(), args, inst, req.Incremental)
 		if err != nil {
 			return errors.Wrap(err, "Create backup")
 		}
@@ -595,8 +638,14 @@ func instanceBackupExportGet(d *Daemon, r *http.Request) response.Response {
 	name := mux.Vars(r)["name"]
 	backupName := mux.Vars(r)["backupName"]
 
+	fullName := name + shared.SnapshotDelimiter + backupName
+
 	// Handle requests targeted to a container on a different node
 	resp, err := forwardedResponseIfInstanceIsRemote(d, r, projectName, name, instanceType)
+	if err == db.ErrNoSuchObject {
+		// The instance is gone but its backups may still be held on the backups target.
+		return backupTargetExportResponse(d.State(), projectName, backupTarget.InstanceBackupName(projectName, fullName))
+	}
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -604,8 +653,10 @@ func instanceBackupExportGet(d *Daemon, r *http.Request) response.Response {
 		return resp
 	}
 
-	fullName := name + shared.SnapshotDelimiter + backupName
 	backup, err := instance.BackupLoadByName(d.State(), projectName, fullName)
+	if errors.Cause(err) == db.ErrNoSuchObject {
+		return backupTargetExportResponse(d.State(), projectName, backupTarget.InstanceBackupName(projectName, fullName))
+	}
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -614,6 +665,10 @@ func instanceBackupExportGet(d *Daemon, r *http.Request) response.Response {
 		Path: shared.VarPath("backups", "instances", project.Instance(projectName, backup.Name())),
 	}
 
+	if !shared.PathExists(ent.Path) {
+		return backupTargetExportResponse(d.State(), projectName, backupTarget.InstanceBackupName(projectName, fullName))
+	}
+
 	d.State().Events.SendLifecycle(projectName, lifecycle.InstanceBackupRetrieved.Event(name, backup.Instance(), nil))
 
 	return response.FileResponse(r, []response.FileResponseEntry{ent}, nil, false)
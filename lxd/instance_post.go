@@ -11,6 +11,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/auth"
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/instance"
@@ -18,11 +19,11 @@ import (
 	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
-	"github.com/lxc/lxd/lxd/rbac"
 	"github.com/lxc/lxd/lxd/response"
 	storagePools "github.com/lxc/lxd/lxd/storage"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/osarch"
 )
 
 var internalClusterInstanceMovedCmd = APIEndpoint{
@@ -110,7 +111,7 @@ func instancePost(d *Daemon, r *http.Request) response.Response {
 			}
 
 			// Check if user is allowed to use cluster member targeting
-			err = project.CheckClusterTargetRestriction(tx, r, projectName, targetNode)
+			err = project.CheckClusterTargetRestriction(d.authorizer, tx, r, projectName, targetNode)
 			if err != nil {
 				return err
 			}
@@ -233,7 +234,7 @@ func instancePost(d *Daemon, r *http.Request) response.Response {
 		// Server-side project migration.
 		if req.Project != "" {
 			// Check is user has access to target project
-			if !rbac.UserHasPermission(r, req.Project, "manage-containers") {
+			if !d.authorizer.UserHasPermission(r, auth.ObjectProject(req.Project), auth.EntitlementCanManageInstances) {
 				return response.Forbidden(nil)
 			}
 
@@ -283,7 +284,7 @@ func instancePost(d *Daemon, r *http.Request) response.Response {
 		}
 
 		instanceOnly := req.InstanceOnly || req.ContainerOnly
-		ws, err := newMigrationSource(inst, req.Live, instanceOnly)
+		ws, err := newMigrationSource(inst, req.Live, instanceOnly, false)
 		if err != nil {
 			return response.InternalError(err)
 		}
@@ -865,6 +866,157 @@ func instancePostClusteringMigrateWithCeph(d *Daemon, r *http.Request, inst inst
 	return run, nil
 }
 
+// Live migrate a running virtual machine to another cluster member, keeping its name.
+func instancePostClusteringMigrateLive(d *Daemon, r *http.Request, inst instance.Instance, newNode string, op *operations.Operation) error {
+	var sourceAddress string
+	var targetAddress string
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+
+		sourceAddress, err = tx.GetLocalNodeAddress()
+		if err != nil {
+			return errors.Wrap(err, "Failed to get local node address")
+		}
+
+		node, err := tx.GetNodeByName(newNode)
+		if err != nil {
+			return errors.Wrap(err, "Failed to get new node address")
+		}
+		targetAddress = node.Address
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	pool, err := storagePools.GetPoolByInstance(d.State(), inst)
+	if err != nil {
+		return fmt.Errorf("Failed loading instance storage pool: %w", err)
+	}
+
+	volType, err := storagePools.InstanceTypeToVolumeType(inst.Type())
+	if err != nil {
+		return err
+	}
+
+	volDBType, err := storagePools.VolumeTypeToDBType(volType)
+	if err != nil {
+		return err
+	}
+
+	// Setup the migration source on this member.
+	ws, err := newMigrationSource(inst, true, false, true)
+	if err != nil {
+		return err
+	}
+
+	resources := map[string][]string{}
+	resources["instances"] = []string{inst.Name()}
+
+	sourceRun := func(op *operations.Operation) error {
+		return ws.Do(d.State(), op)
+	}
+
+	sourceCancel := func(op *operations.Operation) error {
+		ws.disconnect()
+		return nil
+	}
+
+	sourceOp, err := operations.OperationCreate(d.State(), inst.Project(), operations.OperationClassWebsocket, db.OperationInstanceMigrate, resources, ws.Metadata(), sourceRun, sourceCancel, ws.Connect, r)
+	if err != nil {
+		return err
+	}
+
+	chSource, err := sourceOp.Run()
+	if err != nil {
+		return err
+	}
+
+	// Ask the target member to pull the instance from this member.
+	dest, err := cluster.Connect(targetAddress, d.endpoints.NetworkCert(), d.serverCert(), r, true)
+	if err != nil {
+		sourceOp.Cancel()
+		return fmt.Errorf("Failed to connect to destination server %q: %w", targetAddress, err)
+	}
+	dest = dest.UseTarget(newNode).UseProject(inst.Project())
+
+	secrets := map[string]string{}
+	for k, v := range ws.Metadata().(shared.Jmap) {
+		secrets[k] = v.(string)
+	}
+
+	req := api.InstancesPost{
+		Name: inst.Name(),
+		InstancePut: api.InstancePut{
+			Config:      inst.LocalConfig(),
+			Devices:     inst.LocalDevices().CloneNative(),
+			Ephemeral:   inst.IsEphemeral(),
+			Profiles:    inst.Profiles(),
+			Description: inst.Description(),
+		},
+		Type: api.InstanceType(inst.Type().String()),
+		Source: api.InstanceSource{
+			Type:        "migration",
+			Mode:        "pull",
+			Operation:   fmt.Sprintf("https://%s%s", sourceAddress, sourceOp.URL()),
+			Websockets:  secrets,
+			Certificate: string(d.endpoints.NetworkCert().PublicKey()),
+			Live:        true,
+			Source:      inst.Name(),
+		},
+	}
+
+	req.Architecture, err = osarch.ArchitectureName(inst.Architecture())
+	if err != nil {
+		sourceOp.Cancel()
+		return err
+	}
+
+	destOp, err := dest.CreateInstance(req)
+	if err != nil {
+		sourceOp.Cancel()
+		return errors.Wrap(err, "Failed to issue live migration request to target")
+	}
+
+	handler := func(newOp api.Operation) {
+		op.UpdateMetadata(newOp.Metadata)
+	}
+
+	_, err = destOp.AddHandler(handler)
+	if err != nil {
+		return err
+	}
+
+	err = destOp.Wait()
+	if err != nil {
+		sourceOp.Cancel()
+		return errors.Wrap(err, "Live migration failed on target")
+	}
+
+	err = <-chSource
+	if err != nil {
+		return errors.Wrap(err, "Live migration failed on source")
+	}
+
+	// The instance is now running on the target, so move its record there.
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.UpdateInstanceNode(inst.Project(), inst.Name(), inst.Name(), newNode, volDBType)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed updating cluster member to %q for instance %q: %w", newNode, inst.Name(), err)
+	}
+
+	// Remove the volumes which are no longer needed from this member.
+	err = pool.CleanupInstanceMove(inst, nil)
+	if err != nil {
+		return fmt.Errorf("Failed removing instance volumes from source member: %w", err)
+	}
+
+	return nil
+}
+
 // Notification that an instance was moved.
 //
 // At the moment it's used for ceph-based instances, where the target node needs
@@ -907,6 +1059,11 @@ func migrateInstance(d *Daemon, r *http.Request, inst instance.Instance, targetN
 		return fmt.Errorf("Target must be different than instance's current location")
 	}
 
+	// Live migrate running virtual machines if requested, unless they are being renamed too.
+	if inst.Type() == instancetype.VM && req.Live && inst.IsRunning() && shared.IsTrue(inst.ExpandedConfig()["migration.stateful"]) && (req.Name == "" || req.Name == inst.Name()) {
+		return instancePostClusteringMigrateLive(d, r, inst, targetNode, op)
+	}
+
 	// Check if we are migrating a ceph-based instance.
 	pool, err := storagePools.GetPoolByInstance(d.State(), inst)
 	if err != nil {
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// swagger:operation GET /1.0/instances/{name}/uefi-vars instances instance_uefi_vars_get
+//
+// Get the instance's UEFI variables
+//
+// Gets the UEFI variables stored in the NVRAM of a virtual machine.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+// responses:
+//   "200":
+//     description: UEFI variables
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           $ref: "#/definitions/InstanceUEFIVars"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func instanceUEFIVarsGet(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	projectName := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to an instance on a different node.
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, projectName, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+	if resp != nil {
+		return resp
+	}
+
+	vm, err := instanceUEFIVarsLoad(d, projectName, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	vars, err := vm.UEFIVars()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponseETag(true, vars, vars)
+}
+
+// swagger:operation PUT /1.0/instances/{name}/uefi-vars instances instance_uefi_vars_put
+//
+// Update the instance's UEFI variables
+//
+// Replaces the UEFI variables stored in the NVRAM of a stopped virtual machine.
+//
+// ---
+// consumes:
+//   - application/json
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: body
+//     name: uefi-vars
+//     description: UEFI variables
+//     required: true
+//     schema:
+//       $ref: "#/definitions/InstanceUEFIVars"
+// responses:
+//   "200":
+//     $ref: "#/responses/EmptySyncResponse"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "412":
+//     $ref: "#/responses/PreconditionFailed"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func instanceUEFIVarsPut(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	projectName := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to an instance on a different node.
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, projectName, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+	if resp != nil {
+		return resp
+	}
+
+	vm, err := instanceUEFIVarsLoad(d, projectName, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Validate ETag.
+	vars, err := vm.UEFIVars()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = util.EtagCheck(r, vars)
+	if err != nil {
+		return response.PreconditionFailed(err)
+	}
+
+	// Parse the request.
+	req := api.InstanceUEFIVars{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = instanceUEFIVarsValidate(req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = vm.UEFIVarsUpdate(req)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+// instanceUEFIVarsLoad loads the named instance and checks that it is a virtual machine.
+func instanceUEFIVarsLoad(d *Daemon, projectName string, name string) (instance.VM, error) {
+	inst, err := instance.LoadByProjectAndName(d.State(), projectName, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if inst.Type() != instancetype.VM {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "UEFI variables are only available for virtual machines")
+	}
+
+	vm, ok := inst.(instance.VM)
+	if !ok {
+		return nil, fmt.Errorf("Instance %q is not a virtual machine", name)
+	}
+
+	return vm, nil
+}
+
+// instanceUEFIVarKeyParse splits a UEFI variable key into the variable name and its GUID.
+func instanceUEFIVarKeyParse(key string) (string, string, error) {
+	// Keys are made of the variable name followed by its GUID (which is 36 characters long).
+	if len(key) < 38 || key[len(key)-37] != '-' {
+		return "", "", fmt.Errorf("Invalid UEFI variable name %q, expected <name>-<GUID>", key)
+	}
+
+	guid := key[len(key)-36:]
+	if uuid.Parse(guid) == nil {
+		return "", "", fmt.Errorf("Invalid GUID in UEFI variable name %q", key)
+	}
+
+	return key[:len(key)-37], guid, nil
+}
+
+// instanceUEFIVarsValidate checks the UEFI variable keys and that their values are hex-encoded.
+func instanceUEFIVarsValidate(vars api.InstanceUEFIVars) error {
+	for key, v := range vars.Variables {
+		_, _, err := instanceUEFIVarKeyParse(key)
+		if err != nil {
+			return err
+		}
+
+		for _, value := range []string{v.Data, v.Timestamp, v.Digest} {
+			_, err := hex.DecodeString(value)
+			if err != nil {
+				return fmt.Errorf("Invalid hex value for UEFI variable %q: %w", key, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestInstanceUEFIVarKeyParse(t *testing.T) {
+	name, guid, err := instanceUEFIVarKeyParse("SecureBootEnable-f0a30bc7-af08-4556-99c4-001009c93a44")
+	require.NoError(t, err)
+	assert.Equal(t, "SecureBootEnable", name)
+	assert.Equal(t, "f0a30bc7-af08-4556-99c4-001009c93a44", guid)
+
+	// Variable names can contain dashes.
+	name, guid, err = instanceUEFIVarKeyParse("Boot-Order-8be4df61-93ca-11d2-aa0d-00e098032b8c")
+	require.NoError(t, err)
+	assert.Equal(t, "Boot-Order", name)
+	assert.Equal(t, "8be4df61-93ca-11d2-aa0d-00e098032b8c", guid)
+}
+
+func TestInstanceUEFIVarKeyParse_Invalid(t *testing.T) {
+	keys := []string{
+		"",
+		"BootOrder",
+		"-8be4df61-93ca-11d2-aa0d-00e098032b8c",
+		"BootOrder8be4df61-93ca-11d2-aa0d-00e098032b8c",
+		"BootOrder-8be4df61-93ca-11d2-aa0d-00e098032b8",
+		"BootOrder-8be4df61x93ca-11d2-aa0d-00e098032b8c",
+		"BootOrder-zbe4df61-93ca-11d2-aa0d-00e098032b8c",
+	}
+
+	for _, key := range keys {
+		_, _, err := instanceUEFIVarKeyParse(key)
+		assert.Error(t, err, key)
+	}
+}
+
+func TestInstanceUEFIVarsValidate(t *testing.T) {
+	key := "Timeout-8be4df61-93ca-11d2-aa0d-00e098032b8c"
+
+	vars := api.InstanceUEFIVars{Variables: map[string]api.InstanceUEFIVariable{
+		key: {Data: "0500", Attr: 7},
+	}}
+
+	assert.NoError(t, instanceUEFIVarsValidate(vars))
+	assert.NoError(t, instanceUEFIVarsValidate(api.InstanceUEFIVars{}))
+
+	for _, v := range []api.InstanceUEFIVariable{
+		{Data: "05x0"},
+		{Data: "050"},
+		{Data: "05", Timestamp: "zz"},
+		{Data: "05", Digest: "0"},
+	} {
+		vars.Variables[key] = v
+		assert.Error(t, instanceUEFIVarsValidate(vars), v)
+	}
+
+	vars = api.InstanceUEFIVars{Variables: map[string]api.InstanceUEFIVariable{
+		"Timeout": {Data: "0500"},
+	}}
+
+	assert.EqualError(t, instanceUEFIVarsValidate(vars), `Invalid UEFI variable name "Timeout", expected <name>-<GUID>`)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
@@ -10,12 +11,14 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/dustinkirkland/golang-petname"
 	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
 
 	"github.com/lxc/lxd/lxd/backup"
+	backupTarget "github.com/lxc/lxd/lxd/backup/target"
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
@@ -25,8 +28,10 @@ import (
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/request"
+	"github.com/lxc/lxd/lxd/resources"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/lxd/scriptlet"
 	storagePools "github.com/lxc/lxd/lxd/storage"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -195,6 +200,12 @@ func createFromNone(d *Daemon, r *http.Request, projectName string, req *api.Ins
 	return operations.OperationResponse(op)
 }
 
+// isClusterMigration returns true if the request is from another cluster member that is live migrating one of
+// its instances to this member, in which case the existing instance record is used.
+func isClusterMigration(r *http.Request, req *api.InstancesPost) bool {
+	return r != nil && isClusterNotification(r) && req.Source.Type == "migration" && req.Source.Source != ""
+}
+
 func createFromMigration(d *Daemon, r *http.Request, projectName string, req *api.InstancesPost) response.Response {
 	if d.cluster.LocalNodeIsEvacuated() && r.Context().Value(request.CtxProtocol) != "cluster" {
 		return response.Forbidden(fmt.Errorf("Cluster member is evacuated"))
@@ -306,7 +317,18 @@ func createFromMigration(d *Daemon, r *http.Request, projectName string, req *ap
 
 	instanceOnly := req.Source.InstanceOnly || req.Source.ContainerOnly
 
-	if !req.Source.Refresh {
+	clusterMove := isClusterMigration(r, req)
+	if clusterMove {
+		if req.Source.Source != req.Name {
+			return response.BadRequest(fmt.Errorf("Instance cannot be renamed when moved between cluster members"))
+		}
+
+		// The instance record already exists and is moved to this member once the migration has finished.
+		inst, err = instance.LoadByProjectAndName(d.State(), projectName, req.Name)
+		if err != nil {
+			return response.SmartError(err)
+		}
+	} else if !req.Source.Refresh {
 		_, err := storagePools.GetPoolByName(d.State(), storagePool)
 		if err != nil {
 			return response.InternalError(err)
@@ -357,6 +379,7 @@ func createFromMigration(d *Daemon, r *http.Request, projectName string, req *ap
 		Live:         req.Source.Live,
 		InstanceOnly: instanceOnly,
 		Refresh:      req.Source.Refresh,
+		ClusterMove:  clusterMove,
 	}
 
 	sink, err := newMigrationSink(&migrationArgs)
@@ -376,9 +399,11 @@ func createFromMigration(d *Daemon, r *http.Request, projectName string, req *ap
 			return fmt.Errorf("Error transferring instance data: %w", err)
 		}
 
-		err = inst.DeferTemplateApply(instance.TemplateTriggerCopy)
-		if err != nil {
-			return err
+		if !clusterMove {
+			err = inst.DeferTemplateApply(instance.TemplateTriggerCopy)
+			if err != nil {
+				return err
+			}
 		}
 
 		runRevert.Success()
@@ -649,6 +674,17 @@ func createFromBackup(d *Daemon, r *http.Request, projectName string, data io.Re
 	}
 	bInfo.Project = projectName
 
+	// Incremental backups also need the backups they are based on.
+	cleanupParents := func() {}
+	if len(bInfo.Parents) > 0 {
+		cleanupParents, err = backupOpenParents(d.State(), projectName, bInfo.Name, bInfo)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		revert.Add(cleanupParents)
+	}
+
 	// Override pool.
 	if pool != "" {
 		bInfo.Pool = pool
@@ -701,6 +737,7 @@ func createFromBackup(d *Daemon, r *http.Request, projectName string, data io.Re
 
 	run := func(op *operations.Operation) error {
 		defer backupFile.Close()
+		defer cleanupParents()
 		defer runRevert.Fail()
 
 		pool, err := storagePools.GetPoolByName(d.State(), bInfo.Pool)
@@ -813,6 +850,36 @@ func instancesPost(d *Daemon, r *http.Request) response.Response {
 
 	// If we're getting binary content, process separately
 	if r.Header.Get("Content-Type") == "application/octet-stream" {
+		// Restore from a backup held on the backups target rather than from the uploaded data.
+		backupName := r.Header.Get("X-LXD-backup")
+		if backupName != "" {
+			fields := strings.Split(backupName, "/")
+			if len(fields) != 2 || shared.StringInSlice(fields[0], []string{"", ".", ".."}) || shared.StringInSlice(fields[1], []string{"", ".", ".."}) {
+				return response.BadRequest(fmt.Errorf("Invalid backup name %q, must be in the form <instance>/<backup>", backupName))
+			}
+
+			t, err := backup.LoadTarget(d.State(), targetProject)
+			if err != nil {
+				return response.SmartError(err)
+			}
+
+			if t == nil {
+				return response.BadRequest(fmt.Errorf("No backups target configured"))
+			}
+
+			reader, _, err := t.Get(backupTarget.InstanceBackupName(targetProject, backupName))
+			if err == backupTarget.ErrNotFound {
+				return response.NotFound(err)
+			}
+			if err != nil {
+				return response.SmartError(errors.Wrapf(err, "Failed retrieving backup %q from backups target", backupName))
+			}
+
+			defer reader.Close()
+
+			return createFromBackup(d, r, targetProject, reader, r.Header.Get("X-LXD-pool"), r.Header.Get("X-LXD-name"))
+		}
+
 		return createFromBackup(d, r, targetProject, r.Body, r.Header.Get("X-LXD-pool"), r.Header.Get("X-LXD-name"))
 	}
 
@@ -835,7 +902,7 @@ func instancesPost(d *Daemon, r *http.Request) response.Response {
 
 	targetNode := queryParam(r, "target")
 	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
-		return project.CheckClusterTargetRestriction(tx, r, targetProject, targetNode)
+		return project.CheckClusterTargetRestriction(d.authorizer, tx, r, targetProject, targetNode)
 	})
 	if err != nil {
 		return response.SmartError(err)
@@ -920,14 +987,46 @@ func instancesPost(d *Daemon, r *http.Request) response.Response {
 			}
 		}
 
+		clustered, err := cluster.Enabled(d.db)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		var placementScriptlet string
+		var candidates []db.NodeInfo
+
 		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
-			var err error
+			config, err := cluster.ConfigLoad(tx)
+			if err != nil {
+				return err
+			}
+
+			placementScriptlet = config.InstancesPlacementScriptlet()
+			if clustered && placementScriptlet != "" {
+				candidates, err = tx.GetCandidateMembers(architectures, defaultArchId, group)
+				if err != nil {
+					return err
+				}
+			}
+
 			targetNode, err = tx.GetNodeWithLeastInstances(architectures, defaultArchId, group)
 			return err
 		})
 		if err != nil {
 			return response.SmartError(err)
 		}
+
+		// Let the placement scriptlet pick the member, falling back to the least busy one.
+		if len(candidates) > 0 {
+			target, err := instancePlacementScriptletRun(d, r, placementScriptlet, targetProject, &req, candidates)
+			if err != nil {
+				return response.BadRequest(fmt.Errorf("Instance placement scriptlet failed: %w", err))
+			}
+
+			if target != "" {
+				targetNode = target
+			}
+		}
 	}
 
 	if targetNode != "" {
@@ -1014,9 +1113,12 @@ func instancesPost(d *Daemon, r *http.Request) response.Response {
 			}
 		}
 
-		err := project.AllowInstanceCreation(tx, targetProject, req)
-		if err != nil {
-			return err
+		// Instances being moved between cluster members are already accounted for in the project.
+		if !isClusterMigration(r, &req) {
+			err := project.AllowInstanceCreation(tx, targetProject, req)
+			if err != nil {
+				return err
+			}
 		}
 
 		if req.Name == "" {
@@ -1201,3 +1303,48 @@ func clusterCopyContainerInternal(d *Daemon, r *http.Request, source instance.In
 	// Run the migration
 	return createFromMigration(d, nil, projectName, req)
 }
+
+// instancePlacementScriptletRun runs the instance placement scriptlet against the candidate cluster members and
+// returns the name of the member it chose, or an empty string if it didn't choose any.
+func instancePlacementScriptletRun(d *Daemon, r *http.Request, src string, projectName string, req *api.InstancesPost, candidates []db.NodeInfo) (string, error) {
+	leader, err := d.gateway.LeaderAddress()
+	if err != nil {
+		return "", err
+	}
+
+	members := make([]api.ClusterMember, 0, len(candidates))
+	for _, candidate := range candidates {
+		member, err := candidate.ToAPI(d.cluster, d.db, leader)
+		if err != nil {
+			return "", err
+		}
+
+		members = append(members, *member)
+	}
+
+	memberResources := func(memberName string) (*api.Resources, error) {
+		for _, candidate := range candidates {
+			if candidate.Name != memberName {
+				continue
+			}
+
+			if candidate.ID == d.cluster.GetNodeID() {
+				return resources.GetResources()
+			}
+
+			client, err := cluster.Connect(candidate.Address, d.endpoints.NetworkCert(), d.serverCert(), nil, true)
+			if err != nil {
+				return nil, err
+			}
+
+			return client.GetServerResources()
+		}
+
+		return nil, fmt.Errorf("Cluster member %q not found", memberName)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	return scriptlet.InstancePlacementRun(ctx, src, projectName, req, members, memberResources)
+}
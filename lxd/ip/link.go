@@ -366,6 +366,24 @@ func (l *Link) BridgeLinkSetIsolated(isolated bool) error {
 	return nil
 }
 
+// BridgeFDBAppend appends a forwarding database entry sending the frames for hwAddr to the tunnel destination dst
+func (l *Link) BridgeFDBAppend(hwAddr string, dst string) error {
+	_, err := shared.RunCommand("bridge", "fdb", "append", hwAddr, "dev", l.Name, "dst", dst)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// BridgeFDBDelete deletes the forwarding database entry sending the frames for hwAddr to the tunnel destination dst
+func (l *Link) BridgeFDBDelete(hwAddr string, dst string) error {
+	_, err := shared.RunCommand("bridge", "fdb", "del", hwAddr, "dev", l.Name, "dst", dst)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 // BridgeLinkSetHairpin sets bridge 'hairpin' attribute on a port
 func (l *Link) BridgeLinkSetHairpin(hairpin bool) error {
 	hairpinState := "on"
@@ -27,6 +27,7 @@ const (
 	InstanceShutdown         = InstanceAction("shutdown")
 	InstanceRestarted        = InstanceAction("restarted")
 	InstancePaused           = InstanceAction("paused")
+	InstanceReady            = InstanceAction("ready")
 	InstanceResumed          = InstanceAction("resumed")
 	InstanceRestored         = InstanceAction("restored")
 	InstanceDeleted          = InstanceAction("deleted")
@@ -39,6 +40,8 @@ const (
 	InstanceFileRetrieved    = InstanceAction("file-retrieved")
 	InstanceFilePushed       = InstanceAction("file-pushed")
 	InstanceFileDeleted      = InstanceAction("file-deleted")
+	InstanceHealed           = InstanceAction("healed")
+	InstanceUEFIVarsUpdated  = InstanceAction("uefi-vars-updated")
 )
 
 // Event creates the lifecycle event for an action on an instance.
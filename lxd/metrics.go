@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/lxc/lxd/lxd/auth"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/metrics"
@@ -15,7 +16,7 @@ import (
 var metricsCmd = APIEndpoint{
 	Path: "metrics",
 
-	Get: APIEndpointAction{Handler: metricsGet, AccessHandler: allowProjectPermission("containers", "view")},
+	Get: APIEndpointAction{Handler: metricsGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
 }
 
 var metricsGetBuilding bool
@@ -42,6 +42,7 @@ type migrationFields struct {
 	live         bool
 	instanceOnly bool
 	instance     instance.Instance
+	clusterMove  bool
 
 	// storage specific fields
 	volumeOnly bool
@@ -179,7 +180,7 @@ func (s *migrationSourceWs) Connect(op *operations.Operation, r *http.Request, w
 	*conn = c
 
 	// Check criteria for considering all channels to be connected.
-	if s.instance != nil && s.instance.Type() == instancetype.Container && s.live && s.criuConn == nil {
+	if s.instance != nil && s.live && s.criuSecret != "" && s.criuConn == nil {
 		return nil
 	}
 
@@ -283,6 +284,7 @@ type MigrationSinkArgs struct {
 	Live         bool
 	Refresh      bool
 	Snapshots    []*migration.Snapshot
+	ClusterMove  bool
 
 	// Storage specific fields
 	VolumeOnly bool
@@ -33,9 +33,10 @@ import (
 	"github.com/lxc/lxd/shared/logger"
 )
 
-func newMigrationSource(inst instance.Instance, stateful bool, instanceOnly bool) (*migrationSourceWs, error) {
+func newMigrationSource(inst instance.Instance, stateful bool, instanceOnly bool, clusterMove bool) (*migrationSourceWs, error) {
 	ret := migrationSourceWs{migrationFields{instance: inst}, make(chan bool, 1)}
 	ret.instanceOnly = instanceOnly
+	ret.clusterMove = clusterMove
 
 	var err error
 	ret.controlSecret, err = shared.RandomCryptoString()
@@ -57,6 +58,13 @@ func newMigrationSource(inst instance.Instance, stateful bool, instanceOnly bool
 				return nil, fmt.Errorf("Unable to perform container live migration. CRIU isn't installed on the source server")
 			}
 
+			ret.criuSecret, err = shared.RandomCryptoString()
+			if err != nil {
+				return nil, err
+			}
+		} else if inst.Type() == instancetype.VM && clusterMove {
+			// Running VMs moved within a cluster are live migrated, with the state sent over the
+			// criu connection.
 			ret.criuSecret, err = shared.RandomCryptoString()
 			if err != nil {
 				return nil, err
@@ -438,6 +446,11 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 
 		logger.Debugf("Set migration offer volume size for %q: %d", s.instance.Name(), blockSize)
 		offerHeader.VolumeSize = &blockSize
+
+		// Offer to live migrate the running VM if the target connected the state connection.
+		if s.live && s.criuConn != nil {
+			offerHeader.Criu = migration.CRIUType_VM_QEMU.Enum()
+		}
 	}
 
 	// Send offer to target.
@@ -502,18 +515,53 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 		volSourceArgs.MultiSync = s.live || (respHeader.Criu != nil && *respHeader.Criu == migration.CRIUType_NONE)
 	}
 
+	volSourceArgs.Name = s.instance.Name()
+	volSourceArgs.MigrationType = migrationTypes[0]
+	volSourceArgs.Snapshots = sendSnapshotNames
+	volSourceArgs.TrackProgress = true
+
 	if s.instance.Type() == instancetype.VM && s.live {
+		// Live migrate the VM if the target agreed to it.
+		if respHeader.GetCriu() == migration.CRIUType_VM_QEMU {
+			vmArgs := instance.VMLiveMigrateArgs{
+				StateConn: &shared.WebsocketIO{Conn: s.criuConn},
+				Confirm: func() error {
+					msg := migration.MigrationControl{}
+					err := s.recv(&msg)
+					if err != nil {
+						return err
+					}
+
+					if !msg.GetSuccess() {
+						return fmt.Errorf(msg.GetMessage())
+					}
+
+					return nil
+				},
+			}
+
+			// The root disk doesn't need transferring when moving within a cluster on remote storage.
+			if !s.clusterMove || !pool.Driver().Info().Remote {
+				vmArgs.DiskConn = &shared.WebsocketIO{Conn: s.fsConn}
+				vmArgs.DiskTransfer = func() error {
+					return pool.MigrateInstance(s.instance, &shared.WebsocketIO{Conn: s.fsConn}, volSourceArgs, migrateOp)
+				}
+			}
+
+			err = s.instance.(instance.VM).MigrateSendLive(vmArgs)
+			if err != nil {
+				return abort(err)
+			}
+
+			return nil
+		}
+
 		err = s.instance.Stop(true)
 		if err != nil {
 			return abort(fmt.Errorf("Failed statefully stopping instance: %w", err))
 		}
 	}
 
-	volSourceArgs.Name = s.instance.Name()
-	volSourceArgs.MigrationType = migrationTypes[0]
-	volSourceArgs.Snapshots = sendSnapshotNames
-	volSourceArgs.TrackProgress = true
-
 	err = pool.MigrateInstance(s.instance, &shared.WebsocketIO{Conn: s.fsConn}, volSourceArgs, migrateOp)
 	if err != nil {
 		return abort(err)
@@ -743,6 +791,8 @@ func newMigrationSink(args *MigrationSinkArgs) (*migrationSink, error) {
 		refresh: args.Refresh,
 	}
 
+	sink.src.clusterMove = args.ClusterMove
+
 	if sink.push {
 		sink.allConnected = make(chan bool, 1)
 	}
@@ -827,7 +877,7 @@ func (c *migrationSink) Do(state *state.State, revert *revert.Reverter, migrateO
 			return err
 		}
 
-		if c.src.live && c.src.instance.Type() == instancetype.Container {
+		if c.src.live && (c.src.instance.Type() == instancetype.Container || c.src.criuSecret != "") {
 			c.src.criuConn, err = c.connectWithSecret(c.src.criuSecret)
 			if err != nil {
 				c.src.sendControl(err)
@@ -872,6 +922,13 @@ func (c *migrationSink) Do(state *state.State, revert *revert.Reverter, migrateO
 		}
 	}
 
+	// Accept live migration of a running VM if offered and the state connection is available.
+	liveVM := false
+	if c.src.instance.Type() == instancetype.VM && live && offerHeader.GetCriu() == migration.CRIUType_VM_QEMU && !c.push && c.src.criuConn != nil {
+		liveVM = true
+		criuType = migration.CRIUType_VM_QEMU.Enum()
+	}
+
 	// The function that will be executed to receive the sender's migration data.
 	var myTarget func(conn *websocket.Conn, op *operations.Operation, args MigrationSinkArgs) error
 
@@ -889,6 +946,9 @@ func (c *migrationSink) Do(state *state.State, revert *revert.Reverter, migrateO
 		return err
 	}
 
+	// The root disk isn't transferred when live migrating within a cluster on remote storage.
+	sharedStorage := liveVM && c.src.clusterMove && pool.Driver().Info().Remote
+
 	// The migration header to be sent back to source with our target options.
 	// Convert response type to response header and copy snapshot info into it.
 	respHeader := migration.TypesToHeader(respTypes...)
@@ -906,6 +966,7 @@ func (c *migrationSink) Do(state *state.State, revert *revert.Reverter, migrateO
 			TrackProgress: true,            // Use a progress tracker on receiver to get in-cluster progress information.
 			Live:          args.Live,       // Indicates we will get a final rootfs sync.
 			VolumeSize:    args.VolumeSize, // Block size setting override.
+			ClusterMove:   args.ClusterMove,
 		}
 
 		// At this point we have already figured out the parent container's root
@@ -961,8 +1022,11 @@ func (c *migrationSink) Do(state *state.State, revert *revert.Reverter, migrateO
 		}
 
 		// Only delete entire instance on error if the pool volume creation has succeeded to avoid
-		// deleting an existing conflicting volume.
-		if !volTargetArgs.Refresh {
+		// deleting an existing conflicting volume. When moving within a cluster the instance records
+		// are still in use by the source, so only remove the volumes from this member.
+		if volTargetArgs.ClusterMove {
+			revert.Add(func() { pool.CleanupInstanceMove(args.Instance, nil) })
+		} else if !volTargetArgs.Refresh {
 			revert.Add(func() { args.Instance.Delete(true) })
 		}
 
@@ -1045,6 +1109,12 @@ func (c *migrationSink) Do(state *state.State, revert *revert.Reverter, migrateO
 		// will be minimized even if we're dumb here.
 		fsTransfer := make(chan error)
 		go func() {
+			// The volume already exists on shared storage, so just create its mount point.
+			if sharedStorage {
+				fsTransfer <- pool.ImportInstance(c.src.instance, nil)
+				return
+			}
+
 			snapshots := []*migration.Snapshot{}
 
 			// Legacy: we only sent the snapshot names, so we just copy the container's
@@ -1089,6 +1159,7 @@ func (c *migrationSink) Do(state *state.State, revert *revert.Reverter, migrateO
 				RsyncFeatures: rsyncFeatures,
 				Snapshots:     snapshots,
 				VolumeSize:    offerHeader.GetVolumeSize(), // Block size setting override.
+				ClusterMove:   c.src.clusterMove,
 			}
 
 			err = myTarget(fsConn, migrateOp, args)
@@ -1199,7 +1270,21 @@ func (c *migrationSink) Do(state *state.State, revert *revert.Reverter, migrateO
 				}
 			}
 
-			if c.src.instance.Type() == instancetype.VM {
+			if c.src.instance.Type() == instancetype.VM && liveVM {
+				vmArgs := instance.VMLiveMigrateArgs{
+					StateConn: &shared.WebsocketIO{Conn: c.src.criuConn},
+				}
+
+				if !sharedStorage {
+					vmArgs.DiskConn = &shared.WebsocketIO{Conn: c.src.fsConn}
+				}
+
+				err = c.src.instance.(instance.VM).MigrateReceiveLive(vmArgs)
+				if err != nil {
+					restore <- err
+					return
+				}
+			} else if c.src.instance.Type() == instancetype.VM {
 				err = c.src.instance.Migrate(nil)
 				if err != nil {
 					restore <- err
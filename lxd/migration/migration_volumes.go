@@ -41,6 +41,7 @@ type VolumeTargetArgs struct {
 	Live          bool
 	VolumeSize    int64
 	ContentType   string
+	ClusterMove   bool
 }
 
 // TypesToHeader converts one or more Types to a MigrationHeader. It uses the first type argument
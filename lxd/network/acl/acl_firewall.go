@@ -20,7 +20,7 @@ func FirewallApplyACLRules(s *state.State, logger logger.Logger, aclProjectName
 	var allowRules []firewallDrivers.ACLRule
 
 	// convertACLRules converts the ACL rules to Firewall ACL rules.
-	convertACLRules := func(direction string, logPrefix string, rules ...api.NetworkACLRule) error {
+	convertACLRules := func(direction string, aclID int64, rules ...api.NetworkACLRule) error {
 		for ruleIndex, rule := range rules {
 			if rule.State == "disabled" {
 				continue
@@ -40,8 +40,7 @@ func FirewallApplyACLRules(s *state.State, logger logger.Logger, aclProjectName
 
 			if rule.State == "logged" {
 				firewallACLRule.Log = true
-				// Max 29 chars.
-				firewallACLRule.LogName = fmt.Sprintf("%s-%s-%d", logPrefix, direction, ruleIndex)
+				firewallACLRule.LogName = aclLogName(aclID, direction, ruleIndex)
 			}
 
 			switch {
@@ -63,17 +62,17 @@ func FirewallApplyACLRules(s *state.State, logger logger.Logger, aclProjectName
 
 	// Load ACLs specified by network.
 	for _, aclName := range util.SplitNTrimSpace(aclNet.Config["security.acls"], ",", -1, true) {
-		_, aclInfo, err := s.Cluster.GetNetworkACL(aclProjectName, aclName)
+		aclID, aclInfo, err := s.Cluster.GetNetworkACL(aclProjectName, aclName)
 		if err != nil {
 			return errors.Wrapf(err, "Failed loading ACL %q for network %q", aclName, aclNet.Name)
 		}
 
-		err = convertACLRules("ingress", logPrefix, aclInfo.Ingress...)
+		err = convertACLRules("ingress", aclID, aclInfo.Ingress...)
 		if err != nil {
 			return errors.Wrapf(err, "Failed converting ACL %q ingress rules for network %q", aclInfo.Name, aclNet.Name)
 		}
 
-		err = convertACLRules("egress", logPrefix, aclInfo.Egress...)
+		err = convertACLRules("egress", aclID, aclInfo.Egress...)
 		if err != nil {
 			return errors.Wrapf(err, "Failed converting ACL %q egress rules for network %q", aclInfo.Name, aclNet.Name)
 		}
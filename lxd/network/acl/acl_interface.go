@@ -17,6 +17,7 @@ type NetworkACL interface {
 	Info() *api.NetworkACL
 	Etag() []interface{}
 	UsedBy() ([]string, error)
+	GetLog() ([]api.NetworkACLLogEntry, error)
 
 	// Internal validation.
 	validateName(name string) error
@@ -0,0 +1,304 @@
+package acl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// ovnControllerLogPaths lists the locations of the ovn-controller log used by the various OVN packages.
+var ovnControllerLogPaths = []string{"/var/log/ovn/ovn-controller.log", "/var/log/openvswitch/ovn-controller.log"}
+
+// aclLogName returns the log name used for the rule at the given index of an ACL.
+// The prefix matches the ACL's OVN port group name so that logs can be matched the same way for all drivers.
+func aclLogName(aclID int64, direction string, ruleIndex int) string {
+	// Max 29 chars.
+	return fmt.Sprintf("%s%d-%s-%d", ovnACLPortGroupPrefix, aclID, direction, ruleIndex)
+}
+
+// logProtocol converts the protocol names used in the kernel and OVN logs to the ones used in ACL rules.
+func logProtocol(protocol string) string {
+	switch strings.ToLower(protocol) {
+	case "icmp":
+		return "icmp4"
+	case "icmpv6", "icmp6":
+		return "icmp6"
+	case "tcp", "tcp6":
+		return "tcp"
+	case "udp", "udp6":
+		return "udp"
+	}
+
+	return strings.ToLower(protocol)
+}
+
+// GetLog returns the traffic log entries matching the logged rules of the ACL on the local member.
+func (d *common) GetLog() ([]api.NetworkACLLogEntry, error) {
+	prefix := fmt.Sprintf("%s%d-", ovnACLPortGroupPrefix, d.id)
+
+	entries, err := d.kernelLogEntries(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	ovnEntries, err := d.ovnLogEntries(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(entries, ovnEntries...), nil
+}
+
+// ruleFromLogName extracts the rule direction and index from a log name and looks up the rule's action.
+func (d *common) ruleFromLogName(prefix string, name string) (string, int, string, bool) {
+	fields := strings.Split(strings.TrimPrefix(name, prefix), "-")
+	if len(fields) != 2 {
+		return "", -1, "", false
+	}
+
+	ruleIndex, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return "", -1, "", false
+	}
+
+	var rules []api.NetworkACLRule
+	switch fields[0] {
+	case string(ruleDirectionIngress):
+		rules = d.info.Ingress
+	case string(ruleDirectionEgress):
+		rules = d.info.Egress
+	default:
+		return "", -1, "", false
+	}
+
+	action := ""
+	if ruleIndex >= 0 && ruleIndex < len(rules) {
+		action = rules[ruleIndex].Action
+	}
+
+	return fields[0], ruleIndex, action, true
+}
+
+// kernelLogEntries returns the entries logged by the firewall (bridge networks) in the kernel ring buffer.
+func (d *common) kernelLogEntries(prefix string) ([]api.NetworkACLLogEntry, error) {
+	fd, err := unix.Open("/dev/kmsg", unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		if os.IsNotExist(err) || err == unix.EPERM || err == unix.EACCES {
+			return []api.NetworkACLLogEntry{}, nil
+		}
+
+		return nil, fmt.Errorf("Failed opening kernel log: %w", err)
+	}
+
+	defer unix.Close(fd)
+
+	// The kernel log timestamps are relative to the boot time.
+	var ts unix.Timespec
+	err = unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts)
+	if err != nil {
+		return nil, err
+	}
+
+	bootTime := time.Now().Add(-time.Duration(ts.Nano()))
+
+	entries := []api.NetworkACLLogEntry{}
+	buf := make([]byte, 8192)
+	for {
+		// Each read returns a single record.
+		n, err := unix.Read(fd, buf)
+		if err == unix.EPIPE || err == unix.EINTR {
+			// Some records were overwritten while reading, carry on from the next one.
+			continue
+		} else if err == unix.EAGAIN {
+			// No more records.
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Failed reading kernel log: %w", err)
+		} else if n <= 0 {
+			break
+		}
+
+		entry, ok := d.parseKernelLogRecord(prefix, string(buf[:n]), bootTime)
+		if ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// parseKernelLogRecord parses a kernel log record written by the nftables or xtables log rules.
+// It returns false if the record wasn't logged by a rule of the ACL.
+func (d *common) parseKernelLogRecord(prefix string, record string, bootTime time.Time) (api.NetworkACLLogEntry, bool) {
+	// Format: priority,sequence,timestamp,flags;message
+	parts := strings.SplitN(strings.SplitN(record, "\n", 2)[0], ";", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[1], prefix) {
+		return api.NetworkACLLogEntry{}, false
+	}
+
+	header := strings.Split(parts[0], ",")
+	if len(header) < 3 {
+		return api.NetworkACLLogEntry{}, false
+	}
+
+	usec, err := strconv.ParseInt(header[2], 10, 64)
+	if err != nil {
+		return api.NetworkACLLogEntry{}, false
+	}
+
+	fields := strings.Fields(parts[1])
+	direction, ruleIndex, action, ok := d.ruleFromLogName(prefix, fields[0])
+	if !ok {
+		return api.NetworkACLLogEntry{}, false
+	}
+
+	entry := api.NetworkACLLogEntry{
+		Timestamp: bootTime.Add(time.Duration(usec) * time.Microsecond).UTC(),
+		Direction: direction,
+		Rule:      ruleIndex,
+		Action:    action,
+	}
+
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "SRC":
+			entry.Source = kv[1]
+		case "DST":
+			entry.Destination = kv[1]
+		case "PROTO":
+			entry.Protocol = logProtocol(kv[1])
+		case "SPT":
+			entry.SourcePort = kv[1]
+		case "DPT":
+			entry.DestinationPort = kv[1]
+		}
+	}
+
+	return entry, true
+}
+
+// ovnLogEntries returns the entries logged by OVN (OVN networks) in the ovn-controller log.
+func (d *common) ovnLogEntries(prefix string) ([]api.NetworkACLLogEntry, error) {
+	entries := []api.NetworkACLLogEntry{}
+
+	for _, logPath := range ovnControllerLogPaths {
+		f, err := os.Open(shared.HostPath(logPath))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, fmt.Errorf("Failed opening OVN controller log: %w", err)
+		}
+
+		fileEntries, err := d.parseOVNLog(prefix, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, fileEntries...)
+	}
+
+	return entries, nil
+}
+
+// parseOVNLog returns the entries logged by the rules of the ACL in the ovn-controller log content.
+func (d *common) parseOVNLog(prefix string, r io.Reader) ([]api.NetworkACLLogEntry, error) {
+	entries := []api.NetworkACLLogEntry{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		entry, ok := d.parseOVNLogLine(prefix, scanner.Text())
+		if ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("Failed reading OVN controller log: %w", err)
+	}
+
+	return entries, nil
+}
+
+// parseOVNLogLine parses an ovn-controller log line. It returns false if the line wasn't logged by a rule of the ACL.
+func (d *common) parseOVNLogLine(prefix string, line string) (api.NetworkACLLogEntry, bool) {
+	// Format: timestamp|sequence|acl_log(thread)|level|name="...", verdict=..., severity=..., direction=...: flow
+	if !strings.Contains(line, fmt.Sprintf(`name="%s`, prefix)) {
+		return api.NetworkACLLogEntry{}, false
+	}
+
+	fields := strings.SplitN(line, "|", 5)
+	if len(fields) != 5 || !strings.HasPrefix(fields[2], "acl_log") {
+		return api.NetworkACLLogEntry{}, false
+	}
+
+	timestamp, err := time.Parse("2006-01-02T15:04:05.000Z", fields[0])
+	if err != nil {
+		return api.NetworkACLLogEntry{}, false
+	}
+
+	parts := strings.SplitN(fields[4], ": ", 2)
+	if len(parts) != 2 {
+		return api.NetworkACLLogEntry{}, false
+	}
+
+	entry := api.NetworkACLLogEntry{Timestamp: timestamp}
+
+	valid := false
+	for _, field := range strings.Split(parts[0], ", ") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "name":
+			entry.Direction, entry.Rule, _, valid = d.ruleFromLogName(prefix, strings.Trim(kv[1], `"`))
+		case "verdict":
+			entry.Action = kv[1]
+		}
+	}
+
+	if !valid {
+		return api.NetworkACLLogEntry{}, false
+	}
+
+	flow := strings.Split(parts[1], ",")
+	entry.Protocol = logProtocol(flow[0])
+	for _, field := range flow[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "nw_src", "ipv6_src":
+			entry.Source = kv[1]
+		case "nw_dst", "ipv6_dst":
+			entry.Destination = kv[1]
+		case "tp_src":
+			entry.SourcePort = kv[1]
+		case "tp_dst":
+			entry.DestinationPort = kv[1]
+		}
+	}
+
+	return entry, true
+}
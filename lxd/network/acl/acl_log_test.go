@@ -0,0 +1,120 @@
+package acl
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func newLogTestACL() *common {
+	return &common{
+		id: 1,
+		info: &api.NetworkACL{
+			NetworkACLPut: api.NetworkACLPut{
+				Ingress: []api.NetworkACLRule{{Action: "allow", State: "logged"}},
+				Egress:  []api.NetworkACLRule{{Action: "drop", State: "logged"}, {Action: "reject", State: "logged"}},
+			},
+		},
+	}
+}
+
+func TestParseKernelLogRecord_nftables(t *testing.T) {
+	d := newLogTestACL()
+	bootTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	entry, ok := d.parseKernelLogRecord("lxd_acl1-", "4,1234,5000000,-;lxd_acl1-ingress-0 IN=lxdbr0 OUT=veth1a2b3c4d MAC=00:16:3e:aa:bb:cc:00:16:3e:dd:ee:ff:08:00 SRC=10.0.0.2 DST=10.0.0.3 LEN=60 TOS=0x00 PREC=0x00 TTL=64 ID=3 DF PROTO=TCP SPT=40000 DPT=22 WINDOW=64240 RES=0x00 SYN URGP=0\n SUBSYSTEM=net", bootTime)
+	require.True(t, ok)
+	assert.Equal(t, api.NetworkACLLogEntry{
+		Timestamp:       bootTime.Add(5 * time.Second),
+		Direction:       "ingress",
+		Rule:            0,
+		Action:          "allow",
+		Source:          "10.0.0.2",
+		Destination:     "10.0.0.3",
+		Protocol:        "tcp",
+		SourcePort:      "40000",
+		DestinationPort: "22",
+	}, entry)
+}
+
+func TestParseKernelLogRecord_xtables(t *testing.T) {
+	d := newLogTestACL()
+	bootTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	entry, ok := d.parseKernelLogRecord("lxd_acl1-", "4,99,7000000,-;lxd_acl1-egress-1 IN=lxdbr0 OUT=eth0 PHYSIN=veth1a2b3c4d MAC=00:16:3e:aa:bb:cc:00:16:3e:dd:ee:ff:86:dd SRC=fd42::2 DST=fd42::3 LEN=104 TC=0 HOPLIMIT=64 FLOWLBL=0 PROTO=ICMPv6 TYPE=128 CODE=0 ID=1 SEQ=1", bootTime)
+	require.True(t, ok)
+	assert.Equal(t, api.NetworkACLLogEntry{
+		Timestamp:   bootTime.Add(7 * time.Second),
+		Direction:   "egress",
+		Rule:        1,
+		Action:      "reject",
+		Source:      "fd42::2",
+		Destination: "fd42::3",
+		Protocol:    "icmp6",
+	}, entry)
+}
+
+func TestParseKernelLogRecord_Ignored(t *testing.T) {
+	d := newLogTestACL()
+
+	records := []string{
+		// Other ACL.
+		"4,1,1000,-;lxd_acl12-ingress-0 SRC=10.0.0.2 DST=10.0.0.3 PROTO=UDP",
+		// Unrelated message.
+		"6,2,1000,-;lxdbr0: port 1(veth1a2b3c4d) entered forwarding state",
+		// Bad rule name.
+		"4,3,1000,-;lxd_acl1-sideways-0 SRC=10.0.0.2 DST=10.0.0.3 PROTO=UDP",
+		"4,4,1000,-;lxd_acl1-ingress-x SRC=10.0.0.2 DST=10.0.0.3 PROTO=UDP",
+		// Bad header.
+		"4,5;lxd_acl1-ingress-0 SRC=10.0.0.2 DST=10.0.0.3 PROTO=UDP",
+		"4,6,abc,-;lxd_acl1-ingress-0 SRC=10.0.0.2 DST=10.0.0.3 PROTO=UDP",
+		"lxd_acl1-ingress-0 SRC=10.0.0.2 DST=10.0.0.3 PROTO=UDP",
+	}
+
+	for _, record := range records {
+		_, ok := d.parseKernelLogRecord("lxd_acl1-", record, time.Now())
+		assert.False(t, ok, record)
+	}
+}
+
+func TestParseOVNLog(t *testing.T) {
+	d := newLogTestACL()
+
+	log := `2022-01-01T10:00:00.000Z|00061|binding|INFO|Claiming lport 1a2b3c4d for this chassis.
+2022-01-01T10:00:01.250Z|00062|acl_log(ovn_pinctrl0)|INFO|name="lxd_acl1-ingress-0", verdict=allow, severity=info, direction=to-lport: tcp,vlan_tci=0x0000,dl_src=00:16:3e:aa:bb:cc,dl_dst=00:16:3e:dd:ee:ff,nw_src=10.0.0.2,nw_dst=10.0.0.3,nw_tos=0,nw_ecn=0,nw_ttl=64,tp_src=40000,tp_dst=22,tcp_flags=syn
+2022-01-01T10:00:02.500Z|00063|acl_log(ovn_pinctrl0)|INFO|name="lxd_acl12-ingress-0", verdict=allow, severity=info, direction=to-lport: udp,nw_src=10.0.0.2,nw_dst=10.0.0.3,tp_src=5353,tp_dst=53
+2022-01-01T10:00:03.750Z|00064|acl_log(ovn_pinctrl0)|INFO|name="lxd_acl1-egress-0", verdict=drop, severity=info, direction=from-lport: icmp6,vlan_tci=0x0000,dl_src=00:16:3e:aa:bb:cc,dl_dst=00:16:3e:dd:ee:ff,ipv6_src=fd42::2,ipv6_dst=fd42::3,ipv6_label=0x00000,nw_tos=0,nw_ecn=0,nw_ttl=64,icmp_type=128,icmp_code=0
+not a log line with name="lxd_acl1-ingress-0"
+2022-01-01T10:00:04.000Z|00065|acl_log(ovn_pinctrl0)|INFO|name="lxd_acl1-ingress-0", verdict=allow, severity=info, direction=to-lport
+`
+
+	entries, err := d.parseOVNLog("lxd_acl1-", strings.NewReader(log))
+	require.NoError(t, err)
+	assert.Equal(t, []api.NetworkACLLogEntry{
+		{
+			Timestamp:       time.Date(2022, 1, 1, 10, 0, 1, 250000000, time.UTC),
+			Direction:       "ingress",
+			Rule:            0,
+			Action:          "allow",
+			Source:          "10.0.0.2",
+			Destination:     "10.0.0.3",
+			Protocol:        "tcp",
+			SourcePort:      "40000",
+			DestinationPort: "22",
+		},
+		{
+			Timestamp:   time.Date(2022, 1, 1, 10, 0, 3, 750000000, time.UTC),
+			Direction:   "egress",
+			Rule:        0,
+			Action:      "drop",
+			Source:      "fd42::2",
+			Destination: "fd42::3",
+			Protocol:    "icmp6",
+		},
+	}, entries)
+}
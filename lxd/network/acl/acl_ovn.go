@@ -338,7 +338,7 @@ func ovnApplyToPortGroup(logger logger.Logger, client *openvswitch.OVN, aclInfo
 
 			if rule.State == "logged" {
 				ovnACLRule.Log = true
-				ovnACLRule.LogName = fmt.Sprintf("%s-%s-%d", portGroupName, direction, ruleIndex)
+				ovnACLRule.LogName = aclLogName(aclNameIDs[aclInfo.Name], direction, ruleIndex)
 			}
 
 			if networkSpecific {
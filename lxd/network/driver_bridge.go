@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
-	"net/http"
 	"os"
 	"os/exec"
 	"reflect"
@@ -74,6 +73,7 @@ func (n *bridge) DBType() db.NetworkType {
 func (n *bridge) Info() Info {
 	info := n.common.Info()
 	info.AddressForwards = true
+	info.LoadBalancers = true
 
 	return info
 }
@@ -1673,6 +1673,12 @@ func (n *bridge) setup(oldConfig map[string]string) error {
 		return err
 	}
 
+	// Setup network load balancers.
+	err = n.loadBalancerSetupFirewall()
+	if err != nil {
+		return err
+	}
+
 	// Setup BGP.
 	err = n.bgpSetup(oldConfig)
 	if err != nil {
@@ -2453,7 +2459,7 @@ func (n *bridge) bridgedNICExternalRoutes(bridgeProjectNetworks map[string][]*ap
 func (n *bridge) getExternalSubnetInUse() ([]externalSubnetUsage, error) {
 	var err error
 	var projectNetworks map[string]map[int64]api.Network
-	var projectNetworksForwardsOnUplink map[string]map[int64][]string
+	var projectNetworksForwardsOnUplink, projectNetworksLoadBalancersOnUplink map[string]map[int64][]string
 
 	err = n.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
 		// Get all managed networks across all projects.
@@ -2468,6 +2474,13 @@ func (n *bridge) getExternalSubnetInUse() ([]externalSubnetUsage, error) {
 			return errors.Wrapf(err, "Failed loading network forward listen addresses")
 		}
 
+		// Get all network load balancer listen addresses for load balancers assigned to this specific
+		// cluster member.
+		projectNetworksLoadBalancersOnUplink, err = tx.GetProjectNetworkLoadBalancerListenAddressesOnMember()
+		if err != nil {
+			return errors.Wrapf(err, "Failed loading network load balancer listen addresses")
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -2493,24 +2506,26 @@ func (n *bridge) getExternalSubnetInUse() ([]externalSubnetUsage, error) {
 	externalSubnets = append(externalSubnets, bridgeNetworkExternalSubnets...)
 	externalSubnets = append(externalSubnets, bridgedNICExternalRoutes...)
 
-	// Add forward listen addresses to this list.
-	for projectName, networks := range projectNetworksForwardsOnUplink {
-		for networkID, listenAddresses := range networks {
-			for _, listenAddress := range listenAddresses {
-				// Convert listen address to subnet.
-				listenAddressNet, err := ParseIPToNet(listenAddress)
-				if err != nil {
-					return nil, fmt.Errorf("Invalid existing forward listen address %q", listenAddress)
-				}
+	// Add forward and load balancer listen addresses to this list.
+	for _, projectNetworksListeners := range []map[string]map[int64][]string{projectNetworksForwardsOnUplink, projectNetworksLoadBalancersOnUplink} {
+		for projectName, networks := range projectNetworksListeners {
+			for networkID, listenAddresses := range networks {
+				for _, listenAddress := range listenAddresses {
+					// Convert listen address to subnet.
+					listenAddressNet, err := ParseIPToNet(listenAddress)
+					if err != nil {
+						return nil, fmt.Errorf("Invalid existing listen address %q", listenAddress)
+					}
 
-				// Create an externalSubnetUsage for the listen address by using the network ID
-				// of the listen address to retrieve the already loaded network name from the
-				// projectNetworks map.
-				externalSubnets = append(externalSubnets, externalSubnetUsage{
-					subnet:         *listenAddressNet,
-					networkProject: projectName,
-					networkName:    projectNetworks[projectName][networkID].Name,
-				})
+					// Create an externalSubnetUsage for the listen address by using the network ID
+					// of the listen address to retrieve the already loaded network name from the
+					// projectNetworks map.
+					externalSubnets = append(externalSubnets, externalSubnetUsage{
+						subnet:         *listenAddressNet,
+						networkProject: projectName,
+						networkName:    projectNetworks[projectName][networkID].Name,
+					})
+				}
 			}
 		}
 	}
@@ -2522,10 +2537,10 @@ func (n *bridge) getExternalSubnetInUse() ([]externalSubnetUsage, error) {
 func (n *bridge) ForwardCreate(forward api.NetworkForwardsPost, clientType request.ClientType) error {
 	memberSpecific := true // bridge supports per-member forwards.
 
-	// Check if there is an existing forward using the same listen address.
-	_, _, err := n.state.Cluster.GetNetworkForward(n.ID(), memberSpecific, forward.ListenAddress)
-	if err == nil {
-		return api.StatusErrorf(http.StatusConflict, "A forward for that listen address already exists")
+	// Check if there is an existing forward or load balancer using the same listen address.
+	err := n.listenAddressInUse(memberSpecific, forward.ListenAddress)
+	if err != nil {
+		return err
 	}
 
 	// Convert listen address to subnet so we can check its valid and can be used.
@@ -2584,6 +2599,24 @@ func (n *bridge) ForwardCreate(forward api.NetworkForwardsPost, clientType reque
 	}
 
 	// Check if hairpin mode needs to be enabled on active NIC bridge ports.
+	err = n.listenersHairpinSetup()
+	if err != nil {
+		return err
+	}
+
+	// Refresh exported BGP prefixes on local member.
+	err = n.forwardBGPSetupPrefixes()
+	if err != nil {
+		return fmt.Errorf("Failed applying BGP prefixes for address forwards: %w", err)
+	}
+
+	revert.Success()
+	return nil
+}
+
+// listenersHairpinSetup enables hairpin mode on active NIC bridge ports when the first forward or load balancer
+// is added to the network.
+func (n *bridge) listenersHairpinSetup() error {
 	if n.config["bridge.driver"] != "openvswitch" {
 		brNetfilterEnabled := false
 		for _, ipVersion := range []uint{4, 6} {
@@ -2598,13 +2631,19 @@ func (n *bridge) ForwardCreate(forward api.NetworkForwardsPost, clientType reque
 		// forward's listener. Without hairpin mode on the target of the forward will not be able to
 		// connect to the listener.
 		if brNetfilterEnabled {
-			listenAddresses, err := n.state.Cluster.GetNetworkForwardListenAddresses(n.ID(), true)
+			fwdListenAddresses, err := n.state.Cluster.GetNetworkForwardListenAddresses(n.ID(), true)
 			if err != nil {
 				return fmt.Errorf("Failed loading network forwards: %w", err)
 			}
 
-			// If we are the first forward on this bridge, enable hairpin mode on active NIC ports.
-			if len(listenAddresses) <= 1 {
+			lbListenAddresses, err := n.state.Cluster.GetNetworkLoadBalancerListenAddresses(n.ID(), true)
+			if err != nil {
+				return fmt.Errorf("Failed loading network load balancers: %w", err)
+			}
+
+			// If we are the first forward or load balancer on this bridge, enable hairpin mode on active
+			// NIC ports.
+			if len(fwdListenAddresses)+len(lbListenAddresses) <= 1 {
 				var localNode string
 
 				err = n.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
@@ -2664,13 +2703,6 @@ func (n *bridge) ForwardCreate(forward api.NetworkForwardsPost, clientType reque
 		}
 	}
 
-	// Refresh exported BGP prefixes on local member.
-	err = n.forwardBGPSetupPrefixes()
-	if err != nil {
-		return fmt.Errorf("Failed applying BGP prefixes for address forwards: %w", err)
-	}
-
-	revert.Success()
 	return nil
 }
 
@@ -2841,6 +2873,299 @@ func (n *bridge) forwardSetupFirewall() error {
 	return nil
 }
 
+// loadBalancerConvertToFirewallLoadBalancers converts load balancers into format compatible with the firewall package.
+func (n *bridge) loadBalancerConvertToFirewallLoadBalancers(listenAddress net.IP, portMaps []*loadBalancerPortMap) []firewallDrivers.LoadBalancer {
+	var lbs []firewallDrivers.LoadBalancer
+
+	for _, portMap := range portMaps {
+		for i, lp := range portMap.listenPorts {
+			targets := make([]firewallDrivers.LoadBalancerTarget, 0, len(portMap.targets))
+			for _, target := range portMap.targets {
+				targets = append(targets, firewallDrivers.LoadBalancerTarget{
+					Address: target.address,
+					Port:    target.targetPort(portMap.listenPorts, i),
+				})
+			}
+
+			lbs = append(lbs, firewallDrivers.LoadBalancer{
+				ListenAddress: listenAddress,
+				Protocol:      portMap.protocol,
+				ListenPort:    lp,
+				Targets:       targets,
+			})
+		}
+	}
+
+	return lbs
+}
+
+// LoadBalancerCreate creates a network load balancer.
+func (n *bridge) LoadBalancerCreate(loadBalancer api.NetworkLoadBalancersPost, clientType request.ClientType) error {
+	memberSpecific := true // bridge supports per-member load balancers.
+
+	// Check if there is an existing forward or load balancer using the same listen address.
+	err := n.listenAddressInUse(memberSpecific, loadBalancer.ListenAddress)
+	if err != nil {
+		return err
+	}
+
+	// Convert listen address to subnet so we can check its valid and can be used.
+	listenAddressNet, err := ParseIPToNet(loadBalancer.ListenAddress)
+	if err != nil {
+		return errors.Wrapf(err, "Failed parsing load balancer listen address %q", loadBalancer.ListenAddress)
+	}
+
+	_, err = n.loadBalancerValidate(listenAddressNet.IP, &loadBalancer.NetworkLoadBalancerPut)
+	if err != nil {
+		return err
+	}
+
+	externalSubnetsInUse, err := n.getExternalSubnetInUse()
+	if err != nil {
+		return err
+	}
+
+	// Check the listen address subnet doesn't fall within any existing network external subnets.
+	for _, externalSubnetUser := range externalSubnetsInUse {
+		// Skip our own network's SNAT address (as it can be used for NICs in the network).
+		if externalSubnetUser.networkSNAT && externalSubnetUser.networkProject == n.project && externalSubnetUser.networkName == n.name {
+			continue
+		}
+
+		// Skip our own network (but not NIC devices on our own network).
+		if externalSubnetUser.networkProject == n.project && externalSubnetUser.networkName == n.name && externalSubnetUser.instanceDevice == "" {
+			continue
+		}
+
+		if SubnetContains(&externalSubnetUser.subnet, listenAddressNet) || SubnetContains(listenAddressNet, &externalSubnetUser.subnet) {
+			// This error is purposefully vague so that it doesn't reveal any names of
+			// resources potentially outside of the network.
+			return fmt.Errorf("Load balancer listen address %q overlaps with another network or NIC", listenAddressNet.String())
+		}
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	// Create load balancer DB record.
+	loadBalancerID, err := n.state.Cluster.CreateNetworkLoadBalancer(n.ID(), memberSpecific, &loadBalancer)
+	if err != nil {
+		return err
+	}
+
+	revert.Add(func() {
+		n.state.Cluster.DeleteNetworkLoadBalancer(n.ID(), loadBalancerID)
+		n.loadBalancerSetupFirewall()
+		n.loadBalancerBGPSetupPrefixes()
+	})
+
+	err = n.loadBalancerSetupFirewall()
+	if err != nil {
+		return err
+	}
+
+	// Check if hairpin mode needs to be enabled on active NIC bridge ports.
+	err = n.listenersHairpinSetup()
+	if err != nil {
+		return err
+	}
+
+	// Refresh exported BGP prefixes on local member.
+	err = n.loadBalancerBGPSetupPrefixes()
+	if err != nil {
+		return fmt.Errorf("Failed applying BGP prefixes for load balancers: %w", err)
+	}
+
+	revert.Success()
+	return nil
+}
+
+// LoadBalancerUpdate updates a network load balancer.
+func (n *bridge) LoadBalancerUpdate(listenAddress string, req api.NetworkLoadBalancerPut, clientType request.ClientType) error {
+	memberSpecific := true // bridge supports per-member load balancers.
+	curLoadBalancerID, curLoadBalancer, err := n.state.Cluster.GetNetworkLoadBalancer(n.ID(), memberSpecific, listenAddress)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.loadBalancerValidate(net.ParseIP(curLoadBalancer.ListenAddress), &req)
+	if err != nil {
+		return err
+	}
+
+	curLoadBalancerEtagHash, err := util.EtagHash(curLoadBalancer.Etag())
+	if err != nil {
+		return err
+	}
+
+	newLoadBalancer := api.NetworkLoadBalancer{
+		ListenAddress:          curLoadBalancer.ListenAddress,
+		NetworkLoadBalancerPut: req,
+	}
+
+	newLoadBalancerEtagHash, err := util.EtagHash(newLoadBalancer.Etag())
+	if err != nil {
+		return err
+	}
+
+	if curLoadBalancerEtagHash == newLoadBalancerEtagHash {
+		return nil // Nothing has changed.
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	err = n.state.Cluster.UpdateNetworkLoadBalancer(n.ID(), curLoadBalancerID, &newLoadBalancer.NetworkLoadBalancerPut)
+	if err != nil {
+		return err
+	}
+
+	revert.Add(func() {
+		n.state.Cluster.UpdateNetworkLoadBalancer(n.ID(), curLoadBalancerID, &curLoadBalancer.NetworkLoadBalancerPut)
+		n.loadBalancerSetupFirewall()
+	})
+
+	err = n.loadBalancerSetupFirewall()
+	if err != nil {
+		return err
+	}
+
+	revert.Success()
+	return nil
+}
+
+// LoadBalancerDelete deletes a network load balancer.
+func (n *bridge) LoadBalancerDelete(listenAddress string, clientType request.ClientType) error {
+	memberSpecific := true // bridge supports per-member load balancers.
+	loadBalancerID, loadBalancer, err := n.state.Cluster.GetNetworkLoadBalancer(n.ID(), memberSpecific, listenAddress)
+	if err != nil {
+		return err
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	err = n.state.Cluster.DeleteNetworkLoadBalancer(n.ID(), loadBalancerID)
+	if err != nil {
+		return err
+	}
+
+	revert.Add(func() {
+		newLoadBalancer := api.NetworkLoadBalancersPost{
+			NetworkLoadBalancerPut: loadBalancer.NetworkLoadBalancerPut,
+			ListenAddress:          loadBalancer.ListenAddress,
+		}
+		n.state.Cluster.CreateNetworkLoadBalancer(n.ID(), memberSpecific, &newLoadBalancer)
+		n.loadBalancerSetupFirewall()
+		n.loadBalancerBGPSetupPrefixes()
+	})
+
+	err = n.loadBalancerSetupFirewall()
+	if err != nil {
+		return err
+	}
+
+	// Refresh exported BGP prefixes on local member.
+	err = n.loadBalancerBGPSetupPrefixes()
+	if err != nil {
+		return fmt.Errorf("Failed applying BGP prefixes for load balancers: %w", err)
+	}
+
+	revert.Success()
+	return nil
+}
+
+// LoadBalancerBackendHealth returns the health of each of the load balancer's backends.
+// A backend is online if a TCP connection can be made from the host to its target port for the first TCP listen
+// port that uses it. Backends that aren't used by a TCP listen port are reported as unknown.
+func (n *bridge) LoadBalancerBackendHealth(loadBalancer *api.NetworkLoadBalancer) (map[string]string, error) {
+	portMaps, err := n.loadBalancerValidate(net.ParseIP(loadBalancer.ListenAddress), &loadBalancer.NetworkLoadBalancerPut)
+	if err != nil {
+		return nil, err
+	}
+
+	health := make(map[string]string, len(loadBalancer.Backends))
+	for _, backend := range loadBalancer.Backends {
+		health[backend.Name] = "unknown"
+	}
+
+	// Work out the destination to check for each backend.
+	checkDests := make(map[string]string)
+	for _, portMap := range portMaps {
+		if portMap.protocol != "tcp" {
+			continue
+		}
+
+		for _, target := range portMap.targets {
+			_, found := checkDests[target.backendName]
+			if found {
+				continue
+			}
+
+			checkDests[target.backendName] = net.JoinHostPort(target.address.String(), fmt.Sprintf("%d", target.targetPort(portMap.listenPorts, 0)))
+		}
+	}
+
+	var wg sync.WaitGroup
+	var healthMu sync.Mutex
+
+	for backendName, dest := range checkDests {
+		wg.Add(1)
+		go func(backendName string, dest string) {
+			defer wg.Done()
+
+			state := "online"
+			conn, err := net.DialTimeout("tcp", dest, 2*time.Second)
+			if err != nil {
+				state = "offline"
+			} else {
+				conn.Close()
+			}
+
+			healthMu.Lock()
+			health[backendName] = state
+			healthMu.Unlock()
+		}(backendName, dest)
+	}
+
+	wg.Wait()
+
+	return health, nil
+}
+
+// loadBalancerSetupFirewall applies all network load balancers defined for this network and this member.
+func (n *bridge) loadBalancerSetupFirewall() error {
+	memberSpecific := true // Get all load balancers for this cluster member.
+	loadBalancers, err := n.state.Cluster.GetNetworkLoadBalancers(n.ID(), memberSpecific)
+	if err != nil {
+		return fmt.Errorf("Failed loading network load balancers: %w", err)
+	}
+
+	var fwLoadBalancers []firewallDrivers.LoadBalancer
+
+	for _, loadBalancer := range loadBalancers {
+		// Convert listen address to subnet so we can check its valid and can be used.
+		listenAddressNet, err := ParseIPToNet(loadBalancer.ListenAddress)
+		if err != nil {
+			return errors.Wrapf(err, "Failed parsing load balancer listen address %q", loadBalancer.ListenAddress)
+		}
+
+		portMaps, err := n.loadBalancerValidate(listenAddressNet.IP, &loadBalancer.NetworkLoadBalancerPut)
+		if err != nil {
+			return fmt.Errorf("Failed validating firewall load balancer for listen address %q: %w", loadBalancer.ListenAddress, err)
+		}
+
+		fwLoadBalancers = append(fwLoadBalancers, n.loadBalancerConvertToFirewallLoadBalancers(listenAddressNet.IP, portMaps)...)
+	}
+
+	err = n.state.Firewall.NetworkApplyLoadBalancers(n.name, fwLoadBalancers)
+	if err != nil {
+		return fmt.Errorf("Failed applying firewall load balancers: %w", err)
+	}
+
+	return nil
+}
+
 // Leases returns a list of leases for the bridged network. It will reach out to other cluster members as needed.
 // The projectName passed here refers to the initial project from the API request which may differ from the network's project.
 func (n *bridge) Leases(projectName string, clientType request.ClientType) ([]api.NetworkLease, error) {
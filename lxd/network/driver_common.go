@@ -3,6 +3,7 @@ package network
 import (
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
@@ -31,6 +32,7 @@ type Info struct {
 	Projects           bool // Indicates if driver can be used in network enabled projects.
 	NodeSpecificConfig bool // Whether driver has cluster node specific config as a prerequisite for creation.
 	AddressForwards    bool // Indicates if driver supports address forwards.
+	LoadBalancers      bool // Indicates if driver supports load balancers.
 	Peering            bool // Indicates if the driver supports network peering.
 }
 
@@ -42,6 +44,34 @@ type forwardPortMap struct {
 	protocol      string
 }
 
+// loadBalancerTarget represents a load balancer backend's target address and port(s).
+type loadBalancerTarget struct {
+	backendName string
+	address     net.IP
+	ports       []uint64
+}
+
+// loadBalancerPortMap represents a mapping of listen port(s) to backend targets for a protocol.
+type loadBalancerPortMap struct {
+	listenPorts []uint64
+	protocol    string
+	targets     []loadBalancerTarget
+}
+
+// targetPort returns the target port to use for the listen port at index i of the listen ports.
+// If no target ports are specified then the listen port is used, if a single target port is specified then it is
+// used for all listen ports, otherwise the target port at the same index as the listen port is used.
+func (t *loadBalancerTarget) targetPort(listenPorts []uint64, i int) uint64 {
+	switch len(t.ports) {
+	case 0:
+		return listenPorts[i]
+	case 1:
+		return t.ports[0]
+	default:
+		return t.ports[i]
+	}
+}
+
 // externalSubnetUsage represents usage of a subnet by a network or NIC.
 type externalSubnetUsage struct {
 	subnet          net.IPNet
@@ -204,6 +234,7 @@ func (n *common) Info() Info {
 		Projects:           false,
 		NodeSpecificConfig: true,
 		AddressForwards:    false,
+		LoadBalancers:      false,
 	}
 }
 
@@ -510,6 +541,11 @@ func (n *common) bgpSetup(oldConfig map[string]string) error {
 		return fmt.Errorf("Failed applying BGP prefixes for address forwards: %w", err)
 	}
 
+	err = n.loadBalancerBGPSetupPrefixes()
+	if err != nil {
+		return fmt.Errorf("Failed applying BGP prefixes for load balancers: %w", err)
+	}
+
 	return nil
 }
 
@@ -869,33 +905,246 @@ func (n *common) ForwardDelete(listenAddress string, clientType request.ClientTy
 	return ErrNotImplemented
 }
 
+// loadBalancerValidate validates the load balancer request.
+func (n *common) loadBalancerValidate(listenAddress net.IP, loadBalancer *api.NetworkLoadBalancerPut) ([]*loadBalancerPortMap, error) {
+	if listenAddress == nil {
+		return nil, fmt.Errorf("Invalid listen address")
+	}
+
+	listenIsIP4 := listenAddress.To4() != nil
+
+	// For checking target addresses are within network's subnet.
+	netIPKey := "ipv4.address"
+	if !listenIsIP4 {
+		netIPKey = "ipv6.address"
+	}
+
+	netIPAddress := n.config[netIPKey]
+
+	var err error
+	var netSubnet *net.IPNet
+	if netIPAddress != "" {
+		_, netSubnet, err = net.ParseCIDR(n.config[netIPKey])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Look for any unknown config fields.
+	for k := range loadBalancer.Config {
+		// User keys are not validated.
+		if shared.IsUserConfig(k) {
+			continue
+		}
+
+		return nil, fmt.Errorf("Invalid option %q", k)
+	}
+
+	// Validate backends.
+	backendsMap := make(map[string]*loadBalancerTarget, len(loadBalancer.Backends))
+
+	for backendSpecID, backendSpec := range loadBalancer.Backends {
+		if backendSpec.Name == "" {
+			return nil, fmt.Errorf("Missing backend name in backend specification %d", backendSpecID)
+		}
+
+		err = validate.IsURLSegmentSafe(backendSpec.Name)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid backend name in backend specification %d: %w", backendSpecID, err)
+		}
+
+		if _, found := backendsMap[backendSpec.Name]; found {
+			return nil, fmt.Errorf("Duplicate backend name %q in backend specification %d", backendSpec.Name, backendSpecID)
+		}
+
+		targetAddress := net.ParseIP(backendSpec.TargetAddress)
+		if targetAddress == nil {
+			return nil, fmt.Errorf("Invalid target address in backend specification %d", backendSpecID)
+		}
+
+		targetIsIP4 := targetAddress.To4() != nil
+		if listenIsIP4 != targetIsIP4 {
+			return nil, fmt.Errorf("Cannot mix IP versions in listen address and backend specification %d target address", backendSpecID)
+		}
+
+		// Check target address is within network's subnet.
+		if netSubnet != nil && !SubnetContainsIP(netSubnet, targetAddress) {
+			return nil, fmt.Errorf("Target address is not within the network subnet in backend specification %d", backendSpecID)
+		}
+
+		target := loadBalancerTarget{
+			backendName: backendSpec.Name,
+			address:     targetAddress,
+			ports:       make([]uint64, 0),
+		}
+
+		for _, pr := range util.SplitNTrimSpace(backendSpec.TargetPort, ",", -1, true) {
+			portFirst, portRange, err := ParsePortRange(pr)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid target port in backend specification %d: %w", backendSpecID, err)
+			}
+
+			for i := int64(0); i < portRange; i++ {
+				target.ports = append(target.ports, uint64(portFirst+i))
+			}
+		}
+
+		backendsMap[backendSpec.Name] = &target
+	}
+
+	// Validate port rules.
+	validPortProcols := []string{"tcp", "udp"}
+
+	// Used to ensure that each listen port is only used once.
+	listenPorts := map[string]map[int64]struct{}{
+		"tcp": make(map[int64]struct{}),
+		"udp": make(map[int64]struct{}),
+	}
+
+	portMaps := make([]*loadBalancerPortMap, 0, len(loadBalancer.Ports))
+
+	for portSpecID, portSpec := range loadBalancer.Ports {
+		if !shared.StringInSlice(portSpec.Protocol, validPortProcols) {
+			return nil, fmt.Errorf("Invalid port protocol in port specification %d, protocol must be one of: %s", portSpecID, strings.Join(validPortProcols, ", "))
+		}
+
+		// Check valid listen port(s) supplied.
+		listenPortRanges := util.SplitNTrimSpace(portSpec.ListenPort, ",", -1, true)
+		if len(listenPortRanges) <= 0 {
+			return nil, fmt.Errorf("Missing listen port in port specification %d", portSpecID)
+		}
+
+		portMap := loadBalancerPortMap{
+			listenPorts: make([]uint64, 0),
+			protocol:    portSpec.Protocol,
+		}
+
+		for _, pr := range listenPortRanges {
+			portFirst, portRange, err := ParsePortRange(pr)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid listen port in port specification %d: %w", portSpecID, err)
+			}
+
+			for i := int64(0); i < portRange; i++ {
+				port := portFirst + i
+				if _, found := listenPorts[portSpec.Protocol][port]; found {
+					return nil, fmt.Errorf("Duplicate listen port %d for protocol %q in port specification %d", port, portSpec.Protocol, portSpecID)
+				}
+
+				listenPorts[portSpec.Protocol][port] = struct{}{}
+				portMap.listenPorts = append(portMap.listenPorts, uint64(port))
+			}
+		}
+
+		// Check valid target backend(s) supplied.
+		if len(portSpec.TargetBackend) <= 0 {
+			return nil, fmt.Errorf("Missing target backend in port specification %d", portSpecID)
+		}
+
+		portMap.targets = make([]loadBalancerTarget, 0, len(portSpec.TargetBackend))
+
+		for _, backendName := range portSpec.TargetBackend {
+			target, found := backendsMap[backendName]
+			if !found {
+				return nil, fmt.Errorf("Invalid target backend %q in port specification %d", backendName, portSpecID)
+			}
+
+			// Only check if the target port count matches the listen port count if the target ports
+			// don't equal 1, because we allow many-to-one type mapping.
+			targetPortsLen := len(target.ports)
+			if targetPortsLen > 1 && len(portMap.listenPorts) != targetPortsLen {
+				return nil, fmt.Errorf("Mismatch of listen port(s) and target port(s) count for backend %q in port specification %d", backendName, portSpecID)
+			}
+
+			portMap.targets = append(portMap.targets, *target)
+		}
+
+		portMaps = append(portMaps, &portMap)
+	}
+
+	return portMaps, nil
+}
+
+// LoadBalancerCreate returns ErrNotImplemented for drivers that do not support load balancers.
+func (n *common) LoadBalancerCreate(loadBalancer api.NetworkLoadBalancersPost, clientType request.ClientType) error {
+	return ErrNotImplemented
+}
+
+// LoadBalancerUpdate returns ErrNotImplemented for drivers that do not support load balancers.
+func (n *common) LoadBalancerUpdate(listenAddress string, newLoadBalancer api.NetworkLoadBalancerPut, clientType request.ClientType) error {
+	return ErrNotImplemented
+}
+
+// LoadBalancerDelete returns ErrNotImplemented for drivers that do not support load balancers.
+func (n *common) LoadBalancerDelete(listenAddress string, clientType request.ClientType) error {
+	return ErrNotImplemented
+}
+
+// LoadBalancerBackendHealth returns ErrNotImplemented for drivers that do not support load balancers.
+func (n *common) LoadBalancerBackendHealth(loadBalancer *api.NetworkLoadBalancer) (map[string]string, error) {
+	return nil, ErrNotImplemented
+}
+
+// listenAddressInUse returns an error if the listen address is already used by a forward or load balancer on
+// this network.
+func (n *common) listenAddressInUse(memberSpecific bool, listenAddress string) error {
+	_, _, err := n.state.Cluster.GetNetworkForward(n.ID(), memberSpecific, listenAddress)
+	if err == nil {
+		return api.StatusErrorf(http.StatusConflict, "A forward for that listen address already exists")
+	}
+
+	_, _, err = n.state.Cluster.GetNetworkLoadBalancer(n.ID(), memberSpecific, listenAddress)
+	if err == nil {
+		return api.StatusErrorf(http.StatusConflict, "A load balancer for that listen address already exists")
+	}
+
+	return nil
+}
+
 // forwardBGPSetupPrefixes exports external forward addresses as prefixes.
 func (n *common) forwardBGPSetupPrefixes() error {
-	// Retrieve network forwards before clearing existing prefixes, and separate them by IP family.
+	// Retrieve network forwards before clearing existing prefixes.
 	fwdListenAddresses, err := n.state.Cluster.GetNetworkForwardListenAddresses(n.ID(), true)
 	if err != nil {
 		return fmt.Errorf("Failed loading network forwards: %w", err)
 	}
 
-	fwdListenAddressesByFamily := map[uint][]string{
+	// Use forward specific owner string (different from the network prefixes) so that these can be reapplied
+	// independently of the network's own prefixes.
+	return n.listenAddressesBGPSetupPrefixes(fmt.Sprintf("network_%d_forward", n.id), fwdListenAddresses)
+}
+
+// loadBalancerBGPSetupPrefixes exports external load balancer addresses as prefixes.
+func (n *common) loadBalancerBGPSetupPrefixes() error {
+	// Retrieve network load balancers before clearing existing prefixes.
+	lbListenAddresses, err := n.state.Cluster.GetNetworkLoadBalancerListenAddresses(n.ID(), true)
+	if err != nil {
+		return fmt.Errorf("Failed loading network load balancers: %w", err)
+	}
+
+	// Use load balancer specific owner string (different from the network prefixes) so that these can be
+	// reapplied independently of the network's own prefixes.
+	return n.listenAddressesBGPSetupPrefixes(fmt.Sprintf("network_%d_load_balancer", n.id), lbListenAddresses)
+}
+
+// listenAddressesBGPSetupPrefixes replaces the prefixes exported for bgpOwner with the external listen addresses.
+func (n *common) listenAddressesBGPSetupPrefixes(bgpOwner string, listenAddresses map[int64]string) error {
+	// Separate listen addresses by IP family.
+	listenAddressesByFamily := map[uint][]string{
 		4: make([]string, 0),
 		6: make([]string, 0),
 	}
 
-	for _, fwdListenAddress := range fwdListenAddresses {
-		if strings.Contains(fwdListenAddress, ":") {
-			fwdListenAddressesByFamily[6] = append(fwdListenAddressesByFamily[6], fwdListenAddress)
+	for _, listenAddress := range listenAddresses {
+		if strings.Contains(listenAddress, ":") {
+			listenAddressesByFamily[6] = append(listenAddressesByFamily[6], listenAddress)
 		} else {
-			fwdListenAddressesByFamily[4] = append(fwdListenAddressesByFamily[4], fwdListenAddress)
+			listenAddressesByFamily[4] = append(listenAddressesByFamily[4], listenAddress)
 		}
 	}
 
-	// Use forward specific owner string (different from the network prefixes) so that these can be reapplied
-	// independently of the network's own prefixes.
-	bgpOwner := fmt.Sprintf("network_%d_forward", n.id)
-
-	// Clear existing address forward prefixes for network.
-	err = n.state.BGP.RemovePrefixByOwner(bgpOwner)
+	// Clear existing listen address prefixes for owner.
+	err := n.state.BGP.RemovePrefixByOwner(bgpOwner)
 	if err != nil {
 		return err
 	}
@@ -911,16 +1160,16 @@ func (n *common) forwardBGPSetupPrefixes() error {
 			routeSubnetSize = 32
 		}
 
-		// Export external forward listen addresses.
-		for _, fwdListenAddress := range fwdListenAddressesByFamily[ipVersion] {
-			fwdListenAddr := net.ParseIP(fwdListenAddress)
+		// Export external listen addresses.
+		for _, listenAddress := range listenAddressesByFamily[ipVersion] {
+			listenAddr := net.ParseIP(listenAddress)
 
-			// Don't export internal address forwards (those inside the NAT enabled network's subnet).
-			if natEnabled && netSubnet != nil && netSubnet.Contains(fwdListenAddr) {
+			// Don't export internal listen addresses (those inside the NAT enabled network's subnet).
+			if natEnabled && netSubnet != nil && netSubnet.Contains(listenAddr) {
 				continue
 			}
 
-			_, ipRouteSubnet, err := net.ParseCIDR(fmt.Sprintf("%s/%d", fwdListenAddr.String(), routeSubnetSize))
+			_, ipRouteSubnet, err := net.ParseCIDR(fmt.Sprintf("%s/%d", listenAddr.String(), routeSubnetSize))
 			if err != nil {
 				return err
 			}
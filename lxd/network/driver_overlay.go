@@ -0,0 +1,1143 @@
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/apparmor"
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/cluster/request"
+	"github.com/lxc/lxd/lxd/db"
+	dbCluster "github.com/lxc/lxd/lxd/db/cluster"
+	"github.com/lxc/lxd/lxd/dnsmasq"
+	"github.com/lxc/lxd/lxd/dnsmasq/dhcpalloc"
+	"github.com/lxc/lxd/lxd/ip"
+	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/lxd/warnings"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/validate"
+)
+
+// overlayPeersFile is the file (in the network's directory) that contains the tunnel peers currently configured.
+const overlayPeersFile = "overlay.peers"
+
+// overlayDHCPRangesFile is the file (in the network's directory) that contains the part of the DHCP ranges that
+// dnsmasq is currently configured with.
+const overlayDHCPRangesFile = "overlay.dhcp"
+
+// overlayPeer represents a remote cluster member the overlay network is tunnelled to.
+type overlayPeer struct {
+	ID      int64
+	Address string
+}
+
+// overlay represents a LXD overlay network.
+// It is a bridge on each cluster member, with the bridges connected together by a full mesh of VXLAN or Geneve
+// tunnels to form a single L2 segment. The gateway, DHCP and DNS services run on each member and only serve
+// the instances local to that member.
+type overlay struct {
+	bridge
+}
+
+// Type returns the network type.
+func (n *overlay) Type() string {
+	return "overlay"
+}
+
+// DBType returns the network type DB ID.
+func (n *overlay) DBType() db.NetworkType {
+	return db.NetworkTypeOverlay
+}
+
+// Info returns the network driver info.
+func (n *overlay) Info() Info {
+	return n.common.Info()
+}
+
+// FillConfig fills requested config with any default values.
+func (n *overlay) FillConfig(config map[string]string) error {
+	if config["ipv4.address"] == "" {
+		config["ipv4.address"] = "auto"
+	}
+
+	if config["ipv4.address"] == "auto" && config["ipv4.nat"] == "" {
+		config["ipv4.nat"] = "true"
+	}
+
+	if config["ipv6.address"] == "" {
+		content, err := ioutil.ReadFile("/proc/sys/net/ipv6/conf/default/disable_ipv6")
+		if err == nil && string(content) == "0\n" {
+			config["ipv6.address"] = "auto"
+		}
+	}
+
+	if config["ipv6.address"] == "auto" && config["ipv6.nat"] == "" {
+		config["ipv6.nat"] = "true"
+	}
+
+	// Now replace any "auto" keys with generated values.
+	err := n.populateAutoConfig(config)
+	if err != nil {
+		return errors.Wrapf(err, "Failed generating auto config")
+	}
+
+	return nil
+}
+
+// populateAutoConfig replaces "auto" in config with generated values.
+func (n *overlay) populateAutoConfig(config map[string]string) error {
+	changedConfig := false
+
+	if config["ipv4.address"] == "auto" {
+		subnet, err := randomSubnetV4()
+		if err != nil {
+			return err
+		}
+
+		config["ipv4.address"] = subnet
+		changedConfig = true
+	}
+
+	if config["ipv6.address"] == "auto" {
+		subnet, err := randomSubnetV6()
+		if err != nil {
+			return err
+		}
+
+		config["ipv6.address"] = subnet
+		changedConfig = true
+	}
+
+	// Re-validate config if changed.
+	if changedConfig && n.state != nil {
+		return n.Validate(config)
+	}
+
+	return nil
+}
+
+// ValidateName validates network name.
+func (n *overlay) ValidateName(name string) error {
+	err := n.bridge.ValidateName(name)
+	if err != nil {
+		return err
+	}
+
+	// The tunnel interfaces are named after the network with a suffix (e.g "<name>-t123").
+	if len(name) > 10 {
+		return fmt.Errorf("Network name too long for tunnel interfaces (must be 10 characters or less)")
+	}
+
+	return nil
+}
+
+// Validate network config.
+func (n *overlay) Validate(config map[string]string) error {
+	rules := map[string]func(value string) error{
+		"overlay.protocol":  validate.Optional(validate.IsOneOf("vxlan", "geneve")),
+		"overlay.id":        validate.Optional(validate.IsInRange(1, 16777215)),
+		"overlay.port":      validate.Optional(validate.IsNetworkPort),
+		"overlay.interface": validate.Optional(validate.IsInterfaceName),
+	}
+
+	bridgeConfig := make(map[string]string, len(config))
+	for k, v := range config {
+		// Validate the overlay specific keys separately.
+		_, found := rules[k]
+		if found {
+			continue
+		}
+
+		// Reject the bridge keys that don't apply to an overlay network.
+		if shared.StringInSlice(k, []string{"bridge.driver", "bridge.external_interfaces", "bridge.mode", "ipv4.routes", "ipv6.routes", "ipv4.ovn.ranges", "ipv6.ovn.ranges"}) ||
+			strings.HasPrefix(k, "fan.") ||
+			strings.HasPrefix(k, "tunnel.") ||
+			strings.HasPrefix(k, "security.acls") ||
+			strings.HasPrefix(k, "bgp.") ||
+			strings.HasPrefix(k, "dns.zone.") {
+			return fmt.Errorf("Invalid option for network %q option %q", n.name, k)
+		}
+
+		bridgeConfig[k] = v
+	}
+
+	for k, validator := range rules {
+		err := validator(config[k])
+		if err != nil {
+			return errors.Wrapf(err, "Invalid value for network %q option %q", n.name, k)
+		}
+	}
+
+	// Validate the remaining keys the same way as a bridge network.
+	return n.bridge.Validate(bridgeConfig)
+}
+
+// Delete deletes a network.
+func (n *overlay) Delete(clientType request.ClientType) error {
+	n.logger.Debug("Delete", log.Ctx{"clientType": clientType})
+
+	// Delete all warnings regarding this network
+	err := warnings.DeleteWarningsByLocalNodeAndProjectAndEntity(n.state.Cluster, n.project, dbCluster.TypeNetwork, int(n.id))
+	if err != nil {
+		n.logger.Warn("Failed to delete warnings", log.Ctx{"err": err})
+	}
+
+	if n.isRunning() {
+		err := n.Stop()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Delete apparmor profiles.
+	err = apparmor.NetworkDelete(n.state, n)
+	if err != nil {
+		return err
+	}
+
+	return n.common.delete(clientType)
+}
+
+// Rename renames a network.
+func (n *overlay) Rename(newName string) error {
+	n.logger.Debug("Rename", log.Ctx{"newName": newName})
+
+	if InterfaceExists(newName) {
+		return fmt.Errorf("Network interface %q already exists", newName)
+	}
+
+	// Bring the network down.
+	if n.isRunning() {
+		err := n.Stop()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Rename common steps.
+	err := n.common.rename(newName)
+	if err != nil {
+		return err
+	}
+
+	// Bring the network up.
+	err = n.Start()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Start starts the network.
+func (n *overlay) Start() error {
+	n.logger.Debug("Start")
+
+	err := n.setup(nil)
+	if err != nil {
+		err := n.state.Cluster.UpsertWarningLocalNode(n.project, dbCluster.TypeNetwork, int(n.id), db.WarningNetworkStartupFailure, err.Error())
+		if err != nil {
+			n.logger.Warn("Failed to create warning", log.Ctx{"err": err})
+		}
+	} else {
+		err := warnings.ResolveWarningsByLocalNodeAndProjectAndTypeAndEntity(n.state.Cluster, n.project, db.WarningNetworkStartupFailure, dbCluster.TypeNetwork, int(n.id))
+		if err != nil {
+			n.logger.Warn("Failed to resolve warning", log.Ctx{"err": err})
+		}
+	}
+
+	return err
+}
+
+// setup restarts the network.
+func (n *overlay) setup(oldConfig map[string]string) error {
+	// If we are in mock mode, just no-op.
+	if n.state.OS.MockMode {
+		return nil
+	}
+
+	localAddress, peers, err := n.members()
+	if err != nil {
+		return err
+	}
+
+	// Setup the bridge using the config specific to this member.
+	memberConfig, err := n.memberConfig(localAddress)
+	if err != nil {
+		return err
+	}
+
+	config := n.config
+	n.config = memberConfig
+	err = n.bridge.setup(oldConfig)
+	n.config = config
+	if err != nil {
+		return err
+	}
+
+	err = n.saveDHCPRanges(memberConfig)
+	if err != nil {
+		return err
+	}
+
+	return n.setupTunnels(localAddress, peers)
+}
+
+// Stop stops the network.
+func (n *overlay) Stop() error {
+	n.logger.Debug("Stop")
+
+	if !n.isRunning() {
+		return nil
+	}
+
+	// Stopping the bridge also removes the tunnel interfaces.
+	err := n.bridge.Stop()
+	if err != nil {
+		return err
+	}
+
+	err = n.state.Firewall.NetworkClearTunnelFilter(n.name, n.tunnelPrefix())
+	if err != nil {
+		return errors.Wrapf(err, "Failed clearing tunnel filter")
+	}
+
+	return nil
+}
+
+// Update updates the network. Accepts notification boolean indicating if this update request is coming from a
+// cluster notification, in which case do not update the database, just apply local changes needed.
+func (n *overlay) Update(newNetwork api.NetworkPut, targetNode string, clientType request.ClientType) error {
+	n.logger.Debug("Update", log.Ctx{"clientType": clientType, "newNetwork": newNetwork})
+
+	err := n.populateAutoConfig(newNetwork.Config)
+	if err != nil {
+		return errors.Wrapf(err, "Failed generating auto config")
+	}
+
+	dbUpdateNeeeded, changedKeys, oldNetwork, err := n.common.configChanged(newNetwork)
+	if err != nil {
+		return err
+	}
+
+	if !dbUpdateNeeeded {
+		return nil // Nothing changed.
+	}
+
+	// If the network as a whole has not had any previous creation attempts, or the node itself is still
+	// pending, then don't apply the new settings to the node, just to the database record (ready for the
+	// actual global create request to be initiated).
+	if n.Status() == api.NetworkStatusPending || n.LocalStatus() == api.NetworkStatusPending {
+		return n.common.update(newNetwork, targetNode, clientType)
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	if len(changedKeys) > 0 {
+		// Define a function which reverts everything.
+		revert.Add(func() {
+			// Reset changes to all nodes and database.
+			n.common.update(oldNetwork, targetNode, clientType)
+
+			// Reset any change that was made to local bridge.
+			n.setup(newNetwork.Config)
+		})
+	}
+
+	// Apply changes to all nodes and database.
+	err = n.common.update(newNetwork, targetNode, clientType)
+	if err != nil {
+		return err
+	}
+
+	// Restart the network if needed.
+	if len(changedKeys) > 0 {
+		err = n.setup(oldNetwork.Config)
+		if err != nil {
+			return err
+		}
+	}
+
+	revert.Success()
+	return nil
+}
+
+// HandleHeartbeat refreshes the tunnels to the other cluster members. The heartbeat only contains the members
+// that are online, so tunnels to offline members are removed and added back once they are reachable again.
+func (n *overlay) HandleHeartbeat(heartbeatData *cluster.APIHeartbeat) error {
+	if !n.isRunning() {
+		return nil
+	}
+
+	localID := n.state.Cluster.GetNodeID()
+	localMember, found := heartbeatData.Members[localID]
+	if !found {
+		return nil
+	}
+
+	// Cluster members joining or leaving change the part of the DHCP ranges allocated to this member, in which
+	// case restart the network with the new ranges. This also sets up the tunnels to the new members.
+	dhcpConfig, err := n.memberDHCPConfig()
+	if err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(n.loadDHCPRanges(), dhcpConfig) {
+		n.logger.Info("Reconfiguring overlay DHCP ranges", log.Ctx{"ranges": dhcpConfig})
+
+		return n.reconfigureDHCP()
+	}
+
+	localAddress := overlayMemberAddress(localMember.Address)
+
+	peers := []overlayPeer{}
+	for _, member := range heartbeatData.Members {
+		if member.ID == localID {
+			continue
+		}
+
+		address := overlayMemberAddress(member.Address)
+		if address == "" {
+			continue
+		}
+
+		peers = append(peers, overlayPeer{ID: member.ID, Address: address})
+	}
+
+	sort.Slice(peers, func(i, j int) bool { return peers[i].ID < peers[j].ID })
+
+	// Compare the current tunnels to the heartbeat list and see if we need to update.
+	if reflect.DeepEqual(n.currentPeers(), peers) {
+		return nil
+	}
+
+	n.logger.Info("Refreshing overlay tunnels", log.Ctx{"peers": peers})
+
+	return n.setupTunnels(localAddress, peers)
+}
+
+// DHCPv4Ranges returns the part of the network's DHCPv4 ranges allocated to this cluster member.
+func (n *overlay) DHCPv4Ranges() []shared.IPRange {
+	ranges, err := n.memberDHCPRanges(4)
+	if err != nil {
+		n.logger.Warn("Failed getting member DHCPv4 ranges", log.Ctx{"err": err})
+		return n.common.DHCPv4Ranges()
+	}
+
+	return ranges
+}
+
+// DHCPv6Ranges returns the part of the network's DHCPv6 ranges allocated to this cluster member.
+func (n *overlay) DHCPv6Ranges() []shared.IPRange {
+	ranges, err := n.memberDHCPRanges(6)
+	if err != nil {
+		n.logger.Warn("Failed getting member DHCPv6 ranges", log.Ctx{"err": err})
+		return n.common.DHCPv6Ranges()
+	}
+
+	return ranges
+}
+
+// members returns the tunnel address of this cluster member and the list of peers to connect to.
+func (n *overlay) members() (string, []overlayPeer, error) {
+	var members []db.NodeInfo
+	err := n.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		members, err = tx.GetNodes()
+		return err
+	})
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "Failed getting cluster members")
+	}
+
+	localID := n.state.Cluster.GetNodeID()
+	localAddress := ""
+	peers := []overlayPeer{}
+	for _, member := range members {
+		address := overlayMemberAddress(member.Address)
+
+		if member.ID == localID {
+			localAddress = address
+			continue
+		}
+
+		if address == "" {
+			continue
+		}
+
+		peers = append(peers, overlayPeer{ID: member.ID, Address: address})
+	}
+
+	sort.Slice(peers, func(i, j int) bool { return peers[i].ID < peers[j].ID })
+
+	return localAddress, peers, nil
+}
+
+// memberIndex returns the position of this cluster member amongst all members (ordered by ID) and the number
+// of members. This is used to give each member its own part of the DHCP ranges.
+func (n *overlay) memberIndex() (int, int, error) {
+	var memberIDs []int64
+	err := n.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		members, err := tx.GetNodes()
+		if err != nil {
+			return err
+		}
+
+		for _, member := range members {
+			memberIDs = append(memberIDs, member.ID)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return -1, -1, errors.Wrapf(err, "Failed getting cluster members")
+	}
+
+	sort.Slice(memberIDs, func(i, j int) bool { return memberIDs[i] < memberIDs[j] })
+
+	localID := n.state.Cluster.GetNodeID()
+	for i, memberID := range memberIDs {
+		if memberID == localID {
+			return i, len(memberIDs), nil
+		}
+	}
+
+	return -1, -1, fmt.Errorf("Local cluster member not found")
+}
+
+// sharedDHCPRanges returns the DHCP ranges (or the default range if none are configured) for the specified IP
+// version that are shared between the cluster members. Returns the configured ranges and false if DHCP isn't used
+// for allocating addresses.
+func (n *overlay) sharedDHCPRanges(ipVersion uint) ([]shared.IPRange, bool) {
+	if ipVersion == 4 {
+		subnet := n.DHCPv4Subnet()
+		ranges := n.common.DHCPv4Ranges()
+		if subnet == nil {
+			return ranges, false
+		}
+
+		if len(ranges) == 0 {
+			ranges = append(ranges, shared.IPRange{Start: dhcpalloc.GetIP(subnet, 2).To4(), End: dhcpalloc.GetIP(subnet, -2).To4()})
+		}
+
+		return ranges, true
+	}
+
+	subnet := n.DHCPv6Subnet()
+	ranges := n.common.DHCPv6Ranges()
+	if subnet == nil || !shared.IsTrue(n.config["ipv6.dhcp.stateful"]) {
+		return ranges, false
+	}
+
+	if len(ranges) == 0 {
+		ranges = append(ranges, shared.IPRange{Start: dhcpalloc.GetIP(subnet, 2).To16(), End: dhcpalloc.GetIP(subnet, -1).To16()})
+	}
+
+	return ranges, true
+}
+
+// memberDHCPRanges returns the part of the DHCP ranges (or of the default range if none are configured) for the
+// specified IP version that is allocated to this cluster member. Returns the configured ranges unchanged if
+// DHCP isn't used for allocating addresses.
+func (n *overlay) memberDHCPRanges(ipVersion uint) ([]shared.IPRange, error) {
+	ranges, isShared := n.sharedDHCPRanges(ipVersion)
+	if !isShared {
+		return ranges, nil
+	}
+
+	index, count, err := n.memberIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	memberRanges := make([]shared.IPRange, 0, len(ranges))
+	for i := range ranges {
+		memberRange := splitIPRange(&ranges[i], count, index)
+		if memberRange == nil {
+			return nil, fmt.Errorf("DHCP range %q is too small to be shared between %d cluster members", ranges[i].String(), count)
+		}
+
+		memberRanges = append(memberRanges, *memberRange)
+	}
+
+	return memberRanges, nil
+}
+
+// memberConfig returns the network config to apply to the bridge of this cluster member.
+func (n *overlay) memberConfig(localAddress string) (map[string]string, error) {
+	config := make(map[string]string, len(n.config))
+	for k, v := range n.config {
+		config[k] = v
+	}
+
+	// Leave room for the tunnel headers if no MTU is specified.
+	if config["bridge.mtu"] == "" {
+		mtu, err := n.optimalBridgeMTU(localAddress)
+		if err != nil {
+			return nil, err
+		}
+
+		config["bridge.mtu"] = fmt.Sprintf("%d", mtu)
+	}
+
+	// Use this member's part of the DHCP ranges so that addresses aren't allocated twice.
+	dhcpConfig, err := n.memberDHCPConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range dhcpConfig {
+		config[k] = v
+	}
+
+	return config, nil
+}
+
+// memberDHCPConfig returns the DHCP ranges config keys set to the part of the ranges of this cluster member.
+func (n *overlay) memberDHCPConfig() (map[string]string, error) {
+	config := map[string]string{}
+	for _, ipVersion := range []uint{4, 6} {
+		key := fmt.Sprintf("ipv%d.dhcp.ranges", ipVersion)
+
+		ranges, err := n.memberDHCPRanges(ipVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		rangeStrings := make([]string, 0, len(ranges))
+		for i := range ranges {
+			rangeStrings = append(rangeStrings, ranges[i].String())
+		}
+
+		config[key] = strings.Join(rangeStrings, ",")
+	}
+
+	return config, nil
+}
+
+// reconfigureDHCP restarts the network with this member's current part of the DHCP ranges. The leases of the
+// addresses that are now in the part of another member are dropped so that they aren't handed out twice.
+func (n *overlay) reconfigureDHCP() error {
+	// Stop dnsmasq so that it doesn't rewrite the leases file.
+	err := dnsmasq.Kill(n.name, false)
+	if err != nil {
+		return err
+	}
+
+	sharedRanges := []shared.IPRange{}
+	memberRanges := []shared.IPRange{}
+	for _, ipVersion := range []uint{4, 6} {
+		ranges, isShared := n.sharedDHCPRanges(ipVersion)
+		if !isShared {
+			continue
+		}
+
+		sharedRanges = append(sharedRanges, ranges...)
+
+		ranges, err = n.memberDHCPRanges(ipVersion)
+		if err != nil {
+			return err
+		}
+
+		memberRanges = append(memberRanges, ranges...)
+	}
+
+	leasesPath := shared.VarPath("networks", n.name, "dnsmasq.leases")
+	leases, err := ioutil.ReadFile(leasesPath)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "Failed reading dnsmasq leases file %q", leasesPath)
+	}
+
+	if err == nil {
+		err = ioutil.WriteFile(leasesPath, overlayFilterLeases(leases, sharedRanges, memberRanges), 0644)
+		if err != nil {
+			return errors.Wrapf(err, "Failed writing dnsmasq leases file %q", leasesPath)
+		}
+	}
+
+	return n.setup(nil)
+}
+
+// loadDHCPRanges returns the DHCP ranges config recorded by setup.
+func (n *overlay) loadDHCPRanges() map[string]string {
+	config := map[string]string{}
+
+	content, err := ioutil.ReadFile(shared.VarPath("networks", n.name, overlayDHCPRangesFile))
+	if err != nil {
+		return config
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.SplitN(line, "=", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		config[fields[0]] = fields[1]
+	}
+
+	return config
+}
+
+// saveDHCPRanges records the DHCP ranges config dnsmasq was started with.
+func (n *overlay) saveDHCPRanges(config map[string]string) error {
+	var sb strings.Builder
+	for _, key := range []string{"ipv4.dhcp.ranges", "ipv6.dhcp.ranges"} {
+		sb.WriteString(fmt.Sprintf("%s=%s\n", key, config[key]))
+	}
+
+	err := ioutil.WriteFile(shared.VarPath("networks", n.name, overlayDHCPRangesFile), []byte(sb.String()), 0644)
+	if err != nil {
+		return errors.Wrapf(err, "Failed saving overlay DHCP ranges")
+	}
+
+	return nil
+}
+
+// optimalBridgeMTU returns the MTU to use for the bridge based on the MTU of the underlay interface (either the
+// one specified in overlay.interface or the one that has the member's address) minus the tunnel overhead.
+func (n *overlay) optimalBridgeMTU(localAddress string) (uint32, error) {
+	underlayMTU := uint32(1500)
+
+	devName := n.config["overlay.interface"]
+	if devName == "" && localAddress != "" {
+		devName = overlayInterfaceForIP(net.ParseIP(localAddress))
+	}
+
+	if devName != "" {
+		mtu, err := GetDevMTU(devName)
+		if err != nil {
+			return 0, errors.Wrapf(err, "Failed getting MTU for %q", devName)
+		}
+
+		underlayMTU = mtu
+	}
+
+	// VXLAN and Geneve (without options) use 50 bytes of headers over IPv4 and 70 bytes over IPv6.
+	overhead := uint32(50)
+	localIP := net.ParseIP(localAddress)
+	if localIP != nil && localIP.To4() == nil {
+		overhead = 70
+	}
+
+	return underlayMTU - overhead, nil
+}
+
+// tunnelPrefix returns the prefix used by the tunnel interfaces of the network.
+func (n *overlay) tunnelPrefix() string {
+	return fmt.Sprintf("%s-t", n.name)
+}
+
+// setupTunnels updates the tunnel interfaces of the network so that they connect to the specified peers and
+// records them. Only the tunnels to the peers that were added or removed are changed so that the traffic to the
+// other peers isn't interrupted.
+func (n *overlay) setupTunnels(localAddress string, peers []overlayPeer) error {
+	revert := revert.New()
+	defer revert.Fail()
+
+	if len(peers) > 0 && localAddress == "" {
+		return fmt.Errorf("Cluster member address is required for overlay tunnels")
+	}
+
+	curPeers := n.currentPeers()
+
+	if n.config["overlay.protocol"] == "geneve" {
+		// Geneve has no forwarding database, so there is one tunnel interface per peer. Remove the tunnels to
+		// the peers that have gone away first as a peer whose address changed keeps the same interface name.
+		for _, peer := range curPeers {
+			if overlayPeersContain(peers, peer) {
+				continue
+			}
+
+			peer := peer
+			tunLink := &ip.Link{Name: n.tunnelName(peer)}
+			err := tunLink.Delete()
+			if err != nil {
+				return errors.Wrapf(err, "Failed removing tunnel interface %q", tunLink.Name)
+			}
+
+			revert.Add(func() { n.addGeneveTunnel(peer) })
+		}
+
+		for _, peer := range peers {
+			if overlayPeersContain(curPeers, peer) {
+				continue
+			}
+
+			err := n.addGeneveTunnel(peer)
+			if err != nil {
+				return err
+			}
+
+			tunLink := &ip.Link{Name: n.tunnelName(peer)}
+			revert.Add(func() { tunLink.Delete() })
+		}
+	} else {
+		// A single VXLAN interface floods the broadcast and unknown unicast traffic to every peer that has an
+		// entry in its forwarding database.
+		vxlan := &ip.Link{Name: n.tunnelName(overlayPeer{})}
+
+		if len(peers) > 0 && !InterfaceExists(vxlan.Name) {
+			err := n.addVxlanTunnel(localAddress)
+			if err != nil {
+				return err
+			}
+
+			revert.Add(func() { vxlan.Delete() })
+		}
+
+		for _, peer := range curPeers {
+			if overlayPeersContain(peers, peer) {
+				continue
+			}
+
+			peer := peer
+			err := vxlan.BridgeFDBDelete("00:00:00:00:00:00", peer.Address)
+			if err != nil {
+				return errors.Wrapf(err, "Failed removing tunnel peer %q", peer.Address)
+			}
+
+			revert.Add(func() { vxlan.BridgeFDBAppend("00:00:00:00:00:00", peer.Address) })
+		}
+
+		if len(peers) == 0 && InterfaceExists(vxlan.Name) {
+			err := vxlan.Delete()
+			if err != nil {
+				return errors.Wrapf(err, "Failed removing tunnel interface %q", vxlan.Name)
+			}
+
+			revert.Add(func() { n.addVxlanTunnel(localAddress) })
+		}
+
+		for _, peer := range peers {
+			if overlayPeersContain(curPeers, peer) {
+				continue
+			}
+
+			peer := peer
+			err := vxlan.BridgeFDBAppend("00:00:00:00:00:00", peer.Address)
+			if err != nil {
+				return errors.Wrapf(err, "Failed adding tunnel peer %q", peer.Address)
+			}
+
+			revert.Add(func() { vxlan.BridgeFDBDelete("00:00:00:00:00:00", peer.Address) })
+		}
+	}
+
+	// Stop the gateway, DHCP and DNS of each member from answering the instances of the other members.
+	err := n.state.Firewall.NetworkClearTunnelFilter(n.name, n.tunnelPrefix())
+	if err != nil {
+		return errors.Wrapf(err, "Failed clearing tunnel filter")
+	}
+
+	err = n.state.Firewall.NetworkSetupTunnelFilter(n.name, n.tunnelPrefix())
+	if err != nil {
+		return errors.Wrapf(err, "Failed setting up tunnel filter")
+	}
+
+	err = n.savePeers(peers)
+	if err != nil {
+		return err
+	}
+
+	revert.Success()
+	return nil
+}
+
+// currentPeers returns the recorded tunnel peers that still have a tunnel interface. The tunnel interfaces are
+// removed whenever the bridge is set up again, in which case the recorded peers are stale.
+func (n *overlay) currentPeers() []overlayPeer {
+	peers, err := n.loadPeers()
+	if err != nil && !os.IsNotExist(err) {
+		n.logger.Warn("Failed to load existing overlay peers", log.Ctx{"err": err})
+	}
+
+	curPeers := []overlayPeer{}
+	for _, peer := range peers {
+		if InterfaceExists(n.tunnelName(peer)) {
+			curPeers = append(curPeers, peer)
+		}
+	}
+
+	return curPeers
+}
+
+// tunnelName returns the name of the tunnel interface used to reach a peer.
+func (n *overlay) tunnelName(peer overlayPeer) string {
+	if n.config["overlay.protocol"] == "geneve" {
+		return fmt.Sprintf("%s%d", n.tunnelPrefix(), peer.ID)
+	}
+
+	return fmt.Sprintf("%sun", n.tunnelPrefix())
+}
+
+// tunnelID returns the VXLAN or Geneve network identifier of the tunnels.
+func (n *overlay) tunnelID() string {
+	if n.config["overlay.id"] != "" {
+		return n.config["overlay.id"]
+	}
+
+	return fmt.Sprintf("%d", n.id)
+}
+
+// addGeneveTunnel adds the Geneve tunnel interface to a peer and attaches it to the bridge. The tunnel
+// interfaces are isolated from each other to avoid loops in the full mesh.
+func (n *overlay) addGeneveTunnel(peer overlayPeer) error {
+	tunName := n.tunnelName(peer)
+	if len(tunName) > 15 {
+		return fmt.Errorf("Tunnel interface name %q too long", tunName)
+	}
+
+	mtu, err := GetDevMTU(n.name)
+	if err != nil {
+		return errors.Wrapf(err, "Failed getting MTU for %q", n.name)
+	}
+
+	tunPort := n.config["overlay.port"]
+	if tunPort == "" {
+		tunPort = "6081"
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	geneve := &ip.Geneve{
+		Link:    ip.Link{Name: tunName, MTU: fmt.Sprintf("%d", mtu)},
+		ID:      n.tunnelID(),
+		Remote:  peer.Address,
+		DstPort: tunPort,
+	}
+
+	err = geneve.Add()
+	if err != nil {
+		return errors.Wrapf(err, "Failed adding tunnel interface %q", tunName)
+	}
+
+	revert.Add(func() { geneve.Delete() })
+
+	err = n.attachTunnel(tunName)
+	if err != nil {
+		return err
+	}
+
+	err = geneve.BridgeLinkSetIsolated(true)
+	if err != nil {
+		return err
+	}
+
+	revert.Success()
+	return nil
+}
+
+// addVxlanTunnel adds the VXLAN tunnel interface (without any peer) and attaches it to the bridge.
+func (n *overlay) addVxlanTunnel(localAddress string) error {
+	tunName := n.tunnelName(overlayPeer{})
+
+	mtu, err := GetDevMTU(n.name)
+	if err != nil {
+		return errors.Wrapf(err, "Failed getting MTU for %q", n.name)
+	}
+
+	tunPort := n.config["overlay.port"]
+	if tunPort == "" {
+		tunPort = "4789"
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	vxlan := &ip.Vxlan{
+		Link:    ip.Link{Name: tunName, MTU: fmt.Sprintf("%d", mtu)},
+		VxlanID: n.tunnelID(),
+		Local:   localAddress,
+		DevName: n.config["overlay.interface"],
+		DstPort: tunPort,
+	}
+
+	err = vxlan.Add()
+	if err != nil {
+		return errors.Wrapf(err, "Failed adding tunnel interface %q", tunName)
+	}
+
+	revert.Add(func() { vxlan.Delete() })
+
+	err = n.attachTunnel(tunName)
+	if err != nil {
+		return err
+	}
+
+	revert.Success()
+	return nil
+}
+
+// attachTunnel attaches a tunnel interface to the bridge and brings it up.
+func (n *overlay) attachTunnel(tunName string) error {
+	err := AttachInterface(n.name, tunName)
+	if err != nil {
+		return err
+	}
+
+	tunLink := &ip.Link{Name: tunName}
+	err = tunLink.SetUp()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// loadPeers returns the tunnel peers recorded by setupTunnels.
+func (n *overlay) loadPeers() ([]overlayPeer, error) {
+	peers := []overlayPeer{}
+
+	file, err := os.Open(shared.VarPath("networks", n.name, overlayPeersFile))
+	if err != nil {
+		return peers, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		id, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return peers, err
+		}
+
+		peers = append(peers, overlayPeer{ID: id, Address: fields[1]})
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return peers, err
+	}
+
+	return peers, nil
+}
+
+// savePeers records the tunnel peers.
+func (n *overlay) savePeers(peers []overlayPeer) error {
+	var sb strings.Builder
+	for _, peer := range peers {
+		sb.WriteString(fmt.Sprintf("%d %s\n", peer.ID, peer.Address))
+	}
+
+	err := ioutil.WriteFile(shared.VarPath("networks", n.name, overlayPeersFile), []byte(sb.String()), 0644)
+	if err != nil {
+		return errors.Wrapf(err, "Failed saving overlay peers")
+	}
+
+	return nil
+}
+
+// overlayMemberAddress returns the IP address of a cluster member address in host:port format.
+// Returns empty string if the address isn't a specific IP address.
+func overlayMemberAddress(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsUnspecified() {
+		return ""
+	}
+
+	return ip.String()
+}
+
+// overlayFilterLeases returns the dnsmasq leases without the ones of addresses that are in the shared DHCP ranges
+// but outside of the member's part of them. The other leases (such as the ones of static allocations) are kept.
+func overlayFilterLeases(leases []byte, sharedRanges []shared.IPRange, memberRanges []shared.IPRange) []byte {
+	inRanges := func(ip net.IP, ranges []shared.IPRange) bool {
+		for i := range ranges {
+			if ranges[i].ContainsIP(ip) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(string(leases), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 5 {
+			ip := net.ParseIP(fields[2])
+
+			// The ranges are stored in the 4 bytes form for IPv4.
+			if ip.To4() != nil {
+				ip = ip.To4()
+			}
+
+			if ip != nil && inRanges(ip, sharedRanges) && !inRanges(ip, memberRanges) {
+				continue
+			}
+		}
+
+		sb.WriteString(line)
+	}
+
+	return []byte(sb.String())
+}
+
+// overlayPeersContain returns whether the peer (with the same address) is in the list of peers.
+func overlayPeersContain(peers []overlayPeer, peer overlayPeer) bool {
+	for _, p := range peers {
+		if p == peer {
+			return true
+		}
+	}
+
+	return false
+}
+
+// overlayInterfaceForIP returns the name of the interface that has the specified IP address.
+// Returns empty string if not found.
+func overlayInterfaceForIP(findIP net.IP) string {
+	if findIP == nil {
+		return ""
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			ip, _, err := net.ParseCIDR(addr.String())
+			if err != nil {
+				continue
+			}
+
+			if ip.Equal(findIP) {
+				return iface.Name
+			}
+		}
+	}
+
+	return ""
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/lxc/lxd/lxd/db"
 	dbCluster "github.com/lxc/lxd/lxd/db/cluster"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/dnsmasq/dhcpalloc"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/ip"
 	"github.com/lxc/lxd/lxd/locking"
@@ -102,6 +103,7 @@ func (n *ovn) Info() Info {
 	info.Projects = true
 	info.NodeSpecificConfig = false
 	info.AddressForwards = true
+	info.LoadBalancers = true
 	info.Peering = true
 
 	return info
@@ -198,7 +200,7 @@ func (n *ovn) validateExternalSubnet(uplinkRoutes []*net.IPNet, projectRestricte
 func (n *ovn) getExternalSubnetInUse(uplinkNetworkName string) ([]externalSubnetUsage, error) {
 	var err error
 	var projectNetworks map[string]map[int64]api.Network
-	var projectNetworksForwardsOnUplink map[string]map[int64][]string
+	var projectNetworksForwardsOnUplink, projectNetworksLoadBalancersOnUplink map[string]map[int64][]string
 
 	err = n.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
 		// Get all managed networks across all projects.
@@ -213,6 +215,13 @@ func (n *ovn) getExternalSubnetInUse(uplinkNetworkName string) ([]externalSubnet
 			return errors.Wrapf(err, "Failed loading network forward listen addresses")
 		}
 
+		// Get all network load balancer listen addresses for all networks (of any type) connected to our
+		// uplink.
+		projectNetworksLoadBalancersOnUplink, err = tx.GetProjectNetworkLoadBalancerListenAddressesByUplink(uplinkNetworkName)
+		if err != nil {
+			return errors.Wrapf(err, "Failed loading network load balancer listen addresses")
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -238,24 +247,26 @@ func (n *ovn) getExternalSubnetInUse(uplinkNetworkName string) ([]externalSubnet
 	externalSubnets = append(externalSubnets, ovnNetworkExternalSubnets...)
 	externalSubnets = append(externalSubnets, ovnNICExternalRoutes...)
 
-	// Add forward listen addresses to this list.
-	for projectName, networks := range projectNetworksForwardsOnUplink {
-		for networkID, listenAddresses := range networks {
-			for _, listenAddress := range listenAddresses {
-				// Convert listen address to subnet.
-				listenAddressNet, err := ParseIPToNet(listenAddress)
-				if err != nil {
-					return nil, fmt.Errorf("Invalid existing forward listen address %q", listenAddress)
-				}
+	// Add forward and load balancer listen addresses to this list.
+	for _, projectNetworksListeners := range []map[string]map[int64][]string{projectNetworksForwardsOnUplink, projectNetworksLoadBalancersOnUplink} {
+		for projectName, networks := range projectNetworksListeners {
+			for networkID, listenAddresses := range networks {
+				for _, listenAddress := range listenAddresses {
+					// Convert listen address to subnet.
+					listenAddressNet, err := ParseIPToNet(listenAddress)
+					if err != nil {
+						return nil, fmt.Errorf("Invalid existing listen address %q", listenAddress)
+					}
 
-				// Create an externalSubnetUsage for the listen address by using the network ID
-				// of the listen address to retrieve the already loaded network name from the
-				// projectNetworks map.
-				externalSubnets = append(externalSubnets, externalSubnetUsage{
-					subnet:         *listenAddressNet,
-					networkProject: projectName,
-					networkName:    projectNetworks[projectName][networkID].Name,
-				})
+					// Create an externalSubnetUsage for the listen address by using the network ID
+					// of the listen address to retrieve the already loaded network name from the
+					// projectNetworks map.
+					externalSubnets = append(externalSubnets, externalSubnetUsage{
+						subnet:         *listenAddressNet,
+						networkProject: projectName,
+						networkName:    projectNetworks[projectName][networkID].Name,
+					})
+				}
 			}
 		}
 	}
@@ -512,6 +523,27 @@ func (n *ovn) Validate(config map[string]string) error {
 		}
 	}
 
+	// Check any existing network load balancer backend addresses are suitable for this network's subnet.
+	loadBalancers, err := n.state.Cluster.GetNetworkLoadBalancers(n.ID(), memberSpecific)
+	if err != nil {
+		return fmt.Errorf("Failed loading network load balancers: %w", err)
+	}
+
+	for _, loadBalancer := range loadBalancers {
+		for _, backend := range loadBalancer.Backends {
+			targetIP := net.ParseIP(backend.TargetAddress)
+
+			netSubnet := netSubnets["ipv4.address"]
+			if targetIP.To4() == nil {
+				netSubnet = netSubnets["ipv6.address"]
+			}
+
+			if !SubnetContainsIP(netSubnet, targetIP) {
+				return api.StatusErrorf(http.StatusBadRequest, "Network load balancer for %q has a backend target address %q that is not within the network subnet", loadBalancer.ListenAddress, targetIP.String())
+			}
+		}
+	}
+
 	// Check Security ACLs exist.
 	if config["security.acls"] != "" {
 		err = acl.Exists(n.state, n.project, util.SplitNTrimSpace(config["security.acls"], ",", -1, true)...)
@@ -682,6 +714,28 @@ func (n *ovn) getRouterIntPortIPv4Net() string {
 	return n.config["ipv4.address"]
 }
 
+// OVNLoadBalancerHealthCheckIPv4 returns the address reserved in an OVN network's IPv4 subnet for use as the
+// source address of the load balancer backend health checks.
+func OVNLoadBalancerHealthCheckIPv4(subnet *net.IPNet) net.IP {
+	return dhcpalloc.GetIP(subnet, -2)
+}
+
+// getLoadBalancerHealthCheckIPv4 returns the source address of the load balancer backend health checks.
+// Returns nil if the network doesn't have an IPv4 subnet.
+func (n *ovn) getLoadBalancerHealthCheckIPv4() net.IP {
+	routerIntPortIPv4, routerIntPortIPv4Net, err := net.ParseCIDR(n.getRouterIntPortIPv4Net())
+	if err != nil {
+		return nil
+	}
+
+	healthCheckIPv4 := OVNLoadBalancerHealthCheckIPv4(routerIntPortIPv4Net)
+	if healthCheckIPv4.Equal(routerIntPortIPv4) {
+		return nil
+	}
+
+	return healthCheckIPv4
+}
+
 // getRouterIntPortIPv4Net returns OVN logical router internal port IPv6 address and subnet.
 func (n *ovn) getRouterIntPortIPv6Net() string {
 	return n.config["ipv6.address"]
@@ -1981,6 +2035,12 @@ func (n *ovn) setup(update bool) error {
 		excludeIPV4 = []shared.IPRange{{Start: routerIntPortIPv4}}
 	}
 
+	// Reserve the source address of the load balancer backend health checks.
+	healthCheckIPv4 := n.getLoadBalancerHealthCheckIPv4()
+	if healthCheckIPv4 != nil {
+		excludeIPV4 = append(excludeIPV4, shared.IPRange{Start: healthCheckIPv4})
+	}
+
 	// Setup IP allocation config on logical switch.
 	err = client.LogicalSwitchSetIPAllocation(n.getIntSwitchName(), &openvswitch.OVNIPAllocationOpts{
 		PrefixIPv4:  routerIntPortIPv4Net,
@@ -2453,6 +2513,22 @@ func (n *ovn) Delete(clientType request.ClientType) error {
 		if err != nil {
 			return fmt.Errorf("Failed deleting network forwards: %w", err)
 		}
+
+		// Delete any network load balancers.
+		listenAddresses, err = n.state.Cluster.GetNetworkLoadBalancerListenAddresses(n.ID(), memberSpecific)
+		if err != nil {
+			return fmt.Errorf("Failed loading network load balancers: %w", err)
+		}
+
+		loadBalancers = make([]openvswitch.OVNLoadBalancer, 0, len(listenAddresses))
+		for _, listenAddress := range listenAddresses {
+			loadBalancers = append(loadBalancers, n.getLoadBalancerName(listenAddress))
+		}
+
+		err = client.LoadBalancerDelete(loadBalancers...)
+		if err != nil {
+			return fmt.Errorf("Failed deleting network load balancers: %w", err)
+		}
 	}
 
 	return n.common.delete(clientType)
@@ -3146,6 +3222,14 @@ func (n *ovn) InstanceDevicePortSetup(opts *OVNInstanceNICSetupOpts, securityACL
 
 	revert.Add(func() { client.LogicalSwitchPortDeleteDNS(n.getIntSwitchName(), dnsUUID) })
 
+	// Health check any load balancer backends using the port's IPv4 address through this port.
+	if dnsIPv4 != nil {
+		err = n.loadBalancerHealthCheckPortSetup(client, instancePortName, dnsIPv4)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	// Publish NIC's IPs on uplink network if NAT is disabled and using l2proxy ingress mode on uplink.
 	if shared.StringInSlice(opts.UplinkConfig["ovn.ingress_mode"], []string{"l2proxy", ""}) {
 		for _, k := range []string{"ipv4.nat", "ipv6.nat"} {
@@ -3852,7 +3936,7 @@ func (n *ovn) forwardFlattenVIPs(listenAddress net.IP, defaultTargetAddress net.
 	if defaultTargetAddress != nil {
 		vips = append(vips, openvswitch.OVNLoadBalancerVIP{
 			ListenAddress: listenAddress,
-			TargetAddress: defaultTargetAddress,
+			Targets:       []openvswitch.OVNLoadBalancerTarget{{Address: defaultTargetAddress}},
 		})
 	}
 
@@ -3874,9 +3958,13 @@ func (n *ovn) forwardFlattenVIPs(listenAddress net.IP, defaultTargetAddress net.
 			vips = append(vips, openvswitch.OVNLoadBalancerVIP{
 				ListenAddress: listenAddress,
 				Protocol:      portMap.protocol,
-				TargetAddress: portMap.targetAddress,
 				ListenPort:    lp,
-				TargetPort:    targetPort,
+				Targets: []openvswitch.OVNLoadBalancerTarget{
+					{
+						Address: portMap.targetAddress,
+						Port:    targetPort,
+					},
+				},
 			})
 		}
 	}
@@ -3892,10 +3980,10 @@ func (n *ovn) ForwardCreate(forward api.NetworkForwardsPost, clientType request.
 	if clientType == request.ClientTypeNormal {
 		memberSpecific := false // OVN doesn't support per-member forwards.
 
-		// Check if there is an existing forward using the same listen address.
-		_, _, err := n.state.Cluster.GetNetworkForward(n.ID(), memberSpecific, forward.ListenAddress)
-		if err == nil {
-			return api.StatusErrorf(http.StatusConflict, "A forward for that listen address already exists")
+		// Check if there is an existing forward or load balancer using the same listen address.
+		err := n.listenAddressInUse(memberSpecific, forward.ListenAddress)
+		if err != nil {
+			return err
 		}
 
 		// Convert listen address to subnet so we can check its valid and can be used.
@@ -4140,6 +4228,419 @@ func (n *ovn) ForwardDelete(listenAddress string, clientType request.ClientType)
 	return nil
 }
 
+// loadBalancerFlattenVIPs flattens load balancers into format compatible with OVN load balancers.
+// The targetPorts argument maps the backend target addresses to the switch ports used for health checking them.
+func (n *ovn) loadBalancerFlattenVIPs(listenAddress net.IP, portMaps []*loadBalancerPortMap, targetPorts map[string]openvswitch.OVNSwitchPort) []openvswitch.OVNLoadBalancerVIP {
+	var vips []openvswitch.OVNLoadBalancerVIP
+
+	healthCheckIPv4 := n.getLoadBalancerHealthCheckIPv4()
+
+	for _, portMap := range portMaps {
+		for i, lp := range portMap.listenPorts {
+			targets := make([]openvswitch.OVNLoadBalancerTarget, 0, len(portMap.targets))
+			for _, target := range portMap.targets {
+				targets = append(targets, openvswitch.OVNLoadBalancerTarget{
+					Address:         target.address,
+					Port:            target.targetPort(portMap.listenPorts, i),
+					HealthCheckPort: targetPorts[target.address.String()],
+				})
+			}
+
+			vips = append(vips, openvswitch.OVNLoadBalancerVIP{
+				ListenAddress:       listenAddress,
+				Protocol:            portMap.protocol,
+				ListenPort:          lp,
+				Targets:             targets,
+				HealthCheckSourceIP: healthCheckIPv4,
+			})
+		}
+	}
+
+	return vips
+}
+
+// loadBalancerTargetPorts returns the switch ports of the network keyed on their IP addresses.
+func (n *ovn) loadBalancerTargetPorts(client *openvswitch.OVN) (map[string]openvswitch.OVNSwitchPort, error) {
+	portIPs, err := client.LogicalSwitchPortIPs(n.getIntSwitchName())
+	if err != nil {
+		return nil, fmt.Errorf("Failed getting switch port IPs: %w", err)
+	}
+
+	targetPorts := make(map[string]openvswitch.OVNSwitchPort)
+	for portName, ips := range portIPs {
+		for _, ip := range ips {
+			targetPorts[ip.String()] = portName
+		}
+	}
+
+	return targetPorts, nil
+}
+
+// loadBalancerHealthCheckPortSetup updates the switch port used for health checking the load balancer backends
+// with the target address of the specified instance port.
+func (n *ovn) loadBalancerHealthCheckPortSetup(client *openvswitch.OVN, portName openvswitch.OVNSwitchPort, portIPv4 net.IP) error {
+	healthCheckIPv4 := n.getLoadBalancerHealthCheckIPv4()
+	if healthCheckIPv4 == nil {
+		return nil
+	}
+
+	memberSpecific := false // OVN doesn't support per-member load balancers.
+	loadBalancers, err := n.state.Cluster.GetNetworkLoadBalancers(n.ID(), memberSpecific)
+	if err != nil {
+		return fmt.Errorf("Failed loading network load balancers: %w", err)
+	}
+
+	for _, loadBalancer := range loadBalancers {
+		// Only TCP port based backends are health checked.
+		portMaps, err := n.loadBalancerValidate(net.ParseIP(loadBalancer.ListenAddress), &loadBalancer.NetworkLoadBalancerPut)
+		if err != nil {
+			return err
+		}
+
+		for _, portMap := range portMaps {
+			if portMap.protocol != "tcp" || !loadBalancerPortMapHasTarget(portMap, portIPv4) {
+				continue
+			}
+
+			err = client.LoadBalancerHealthCheckPortSet(n.getLoadBalancerName(loadBalancer.ListenAddress), portIPv4, portName, healthCheckIPv4)
+			if err != nil {
+				return fmt.Errorf("Failed setting load balancer %q health check port: %w", loadBalancer.ListenAddress, err)
+			}
+
+			break
+		}
+	}
+
+	return nil
+}
+
+// loadBalancerPortMapHasTarget returns true if the port map has a target with the specified address.
+func loadBalancerPortMapHasTarget(portMap *loadBalancerPortMap, address net.IP) bool {
+	for _, target := range portMap.targets {
+		if target.address.Equal(address) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadBalancerCreate creates a network load balancer.
+func (n *ovn) LoadBalancerCreate(loadBalancer api.NetworkLoadBalancersPost, clientType request.ClientType) error {
+	revert := revert.New()
+	defer revert.Fail()
+
+	if clientType == request.ClientTypeNormal {
+		memberSpecific := false // OVN doesn't support per-member load balancers.
+
+		// Check if there is an existing forward or load balancer using the same listen address.
+		err := n.listenAddressInUse(memberSpecific, loadBalancer.ListenAddress)
+		if err != nil {
+			return err
+		}
+
+		// Convert listen address to subnet so we can check its valid and can be used.
+		listenAddressNet, err := ParseIPToNet(loadBalancer.ListenAddress)
+		if err != nil {
+			return errors.Wrapf(err, "Failed parsing %q", loadBalancer.ListenAddress)
+		}
+
+		portMaps, err := n.loadBalancerValidate(listenAddressNet.IP, &loadBalancer.NetworkLoadBalancerPut)
+		if err != nil {
+			return err
+		}
+
+		// Load the project to get uplink network restrictions.
+		p, err := n.state.Cluster.GetProject(n.project)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to load network restrictions from project %q", n.project)
+		}
+
+		// Get uplink routes.
+		_, uplink, _, err := n.state.Cluster.GetNetworkInAnyState(project.Default, n.config["network"])
+		if err != nil {
+			return errors.Wrapf(err, "Failed to load uplink network %q", n.config["network"])
+		}
+
+		uplinkRoutes, err := n.uplinkRoutes(uplink)
+		if err != nil {
+			return err
+		}
+
+		// Get project restricted routes.
+		projectRestrictedSubnets, err := n.projectRestrictedSubnets(p, n.config["network"])
+		if err != nil {
+			return err
+		}
+
+		externalSubnetsInUse, err := n.getExternalSubnetInUse(n.config["network"])
+		if err != nil {
+			return err
+		}
+
+		// Check the listen address subnet is allowed within both the uplink's external routes and any
+		// project restricted subnets.
+		err = n.validateExternalSubnet(uplinkRoutes, projectRestrictedSubnets, listenAddressNet)
+		if err != nil {
+			return err
+		}
+
+		// Check the listen address subnet doesn't fall within any existing OVN network external subnets.
+		for _, externalSubnetUser := range externalSubnetsInUse {
+			// Skip our own network's SNAT address (as it can be used for NICs in the network).
+			if externalSubnetUser.networkSNAT && externalSubnetUser.networkProject == n.project && externalSubnetUser.networkName == n.name {
+				continue
+			}
+
+			// Skip our own network (but not NIC devices on our own network).
+			if externalSubnetUser.networkProject == n.project && externalSubnetUser.networkName == n.name && externalSubnetUser.instanceDevice == "" {
+				continue
+			}
+
+			if SubnetContains(&externalSubnetUser.subnet, listenAddressNet) || SubnetContains(listenAddressNet, &externalSubnetUser.subnet) {
+				// This error is purposefully vague so that it doesn't reveal any names of
+				// resources potentially outside of the network's project.
+				return fmt.Errorf("Load balancer listen address %q overlaps with another OVN network or NIC", listenAddressNet.String())
+			}
+		}
+
+		client, err := openvswitch.NewOVN(n.state)
+		if err != nil {
+			return fmt.Errorf("Failed to get OVN client: %w", err)
+		}
+
+		// Create load balancer DB record.
+		loadBalancerID, err := n.state.Cluster.CreateNetworkLoadBalancer(n.ID(), memberSpecific, &loadBalancer)
+		if err != nil {
+			return err
+		}
+
+		revert.Add(func() {
+			n.state.Cluster.DeleteNetworkLoadBalancer(n.ID(), loadBalancerID)
+			client.LoadBalancerDelete(n.getLoadBalancerName(loadBalancer.ListenAddress))
+			n.loadBalancerBGPSetupPrefixes()
+		})
+
+		targetPorts, err := n.loadBalancerTargetPorts(client)
+		if err != nil {
+			return err
+		}
+
+		vips := n.loadBalancerFlattenVIPs(net.ParseIP(loadBalancer.ListenAddress), portMaps, targetPorts)
+
+		err = client.LoadBalancerApply(n.getLoadBalancerName(loadBalancer.ListenAddress), []openvswitch.OVNRouter{n.getRouterName()}, []openvswitch.OVNSwitch{n.getIntSwitchName()}, vips...)
+		if err != nil {
+			return fmt.Errorf("Failed applying OVN load balancer: %w", err)
+		}
+
+		// Notify all other members to refresh their BGP prefixes.
+		notifier, err := cluster.NewNotifier(n.state, n.state.Endpoints.NetworkCert(), n.state.ServerCert(), cluster.NotifyAll)
+		if err != nil {
+			return err
+		}
+
+		err = notifier(func(client lxd.InstanceServer) error {
+			return client.UseProject(n.project).CreateNetworkLoadBalancer(n.name, loadBalancer)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Refresh exported BGP prefixes on local member.
+	err := n.loadBalancerBGPSetupPrefixes()
+	if err != nil {
+		return fmt.Errorf("Failed applying BGP prefixes for load balancers: %w", err)
+	}
+
+	revert.Success()
+	return nil
+}
+
+// LoadBalancerUpdate updates a network load balancer.
+func (n *ovn) LoadBalancerUpdate(listenAddress string, req api.NetworkLoadBalancerPut, clientType request.ClientType) error {
+	revert := revert.New()
+	defer revert.Fail()
+
+	if clientType == request.ClientTypeNormal {
+		memberSpecific := false // OVN doesn't support per-member load balancers.
+		curLoadBalancerID, curLoadBalancer, err := n.state.Cluster.GetNetworkLoadBalancer(n.ID(), memberSpecific, listenAddress)
+		if err != nil {
+			return err
+		}
+
+		portMaps, err := n.loadBalancerValidate(net.ParseIP(curLoadBalancer.ListenAddress), &req)
+		if err != nil {
+			return err
+		}
+
+		curLoadBalancerEtagHash, err := util.EtagHash(curLoadBalancer.Etag())
+		if err != nil {
+			return err
+		}
+
+		newLoadBalancer := api.NetworkLoadBalancer{
+			ListenAddress:          curLoadBalancer.ListenAddress,
+			NetworkLoadBalancerPut: req,
+		}
+
+		newLoadBalancerEtagHash, err := util.EtagHash(newLoadBalancer.Etag())
+		if err != nil {
+			return err
+		}
+
+		if curLoadBalancerEtagHash == newLoadBalancerEtagHash {
+			return nil // Nothing has changed.
+		}
+
+		client, err := openvswitch.NewOVN(n.state)
+		if err != nil {
+			return fmt.Errorf("Failed to get OVN client: %w", err)
+		}
+
+		targetPorts, err := n.loadBalancerTargetPorts(client)
+		if err != nil {
+			return err
+		}
+
+		vips := n.loadBalancerFlattenVIPs(net.ParseIP(newLoadBalancer.ListenAddress), portMaps, targetPorts)
+		err = client.LoadBalancerApply(n.getLoadBalancerName(newLoadBalancer.ListenAddress), []openvswitch.OVNRouter{n.getRouterName()}, []openvswitch.OVNSwitch{n.getIntSwitchName()}, vips...)
+		if err != nil {
+			return fmt.Errorf("Failed applying OVN load balancer: %w", err)
+		}
+
+		revert.Add(func() {
+			// Apply old settings to OVN on failure.
+			curPortMaps, err := n.loadBalancerValidate(net.ParseIP(curLoadBalancer.ListenAddress), &curLoadBalancer.NetworkLoadBalancerPut)
+			if err == nil {
+				vips := n.loadBalancerFlattenVIPs(net.ParseIP(curLoadBalancer.ListenAddress), curPortMaps, targetPorts)
+				client.LoadBalancerApply(n.getLoadBalancerName(curLoadBalancer.ListenAddress), []openvswitch.OVNRouter{n.getRouterName()}, []openvswitch.OVNSwitch{n.getIntSwitchName()}, vips...)
+			}
+		})
+
+		err = n.state.Cluster.UpdateNetworkLoadBalancer(n.ID(), curLoadBalancerID, &newLoadBalancer.NetworkLoadBalancerPut)
+		if err != nil {
+			return err
+		}
+	}
+
+	revert.Success()
+	return nil
+}
+
+// LoadBalancerDelete deletes a network load balancer.
+func (n *ovn) LoadBalancerDelete(listenAddress string, clientType request.ClientType) error {
+	if clientType == request.ClientTypeNormal {
+		memberSpecific := false // OVN doesn't support per-member load balancers.
+		loadBalancerID, loadBalancer, err := n.state.Cluster.GetNetworkLoadBalancer(n.ID(), memberSpecific, listenAddress)
+		if err != nil {
+			return err
+		}
+
+		client, err := openvswitch.NewOVN(n.state)
+		if err != nil {
+			return fmt.Errorf("Failed to get OVN client: %w", err)
+		}
+
+		err = client.LoadBalancerDelete(n.getLoadBalancerName(loadBalancer.ListenAddress))
+		if err != nil {
+			return fmt.Errorf("Failed deleting OVN load balancer: %w", err)
+		}
+
+		err = n.state.Cluster.DeleteNetworkLoadBalancer(n.ID(), loadBalancerID)
+		if err != nil {
+			return err
+		}
+
+		// Notify all other members to refresh their BGP prefixes.
+		notifier, err := cluster.NewNotifier(n.state, n.state.Endpoints.NetworkCert(), n.state.ServerCert(), cluster.NotifyAll)
+		if err != nil {
+			return err
+		}
+
+		err = notifier(func(client lxd.InstanceServer) error {
+			return client.UseProject(n.project).DeleteNetworkLoadBalancer(n.name, loadBalancer.ListenAddress)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Refresh exported BGP prefixes on local member.
+	err := n.loadBalancerBGPSetupPrefixes()
+	if err != nil {
+		return fmt.Errorf("Failed applying BGP prefixes for load balancers: %w", err)
+	}
+
+	return nil
+}
+
+// LoadBalancerBackendHealth returns the health of each of the load balancer's backends.
+// The TCP backends of IPv4 load balancers are health checked by OVN, other backends are reported as "unknown".
+func (n *ovn) LoadBalancerBackendHealth(loadBalancer *api.NetworkLoadBalancer) (map[string]string, error) {
+	health := make(map[string]string, len(loadBalancer.Backends))
+	for _, backend := range loadBalancer.Backends {
+		health[backend.Name] = "unknown"
+	}
+
+	portMaps, err := n.loadBalancerValidate(net.ParseIP(loadBalancer.ListenAddress), &loadBalancer.NetworkLoadBalancerPut)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.getLoadBalancerHealthCheckIPv4() == nil || net.ParseIP(loadBalancer.ListenAddress).To4() == nil {
+		return health, nil
+	}
+
+	client, err := openvswitch.NewOVN(n.state)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get OVN client: %w", err)
+	}
+
+	targetPorts, err := n.loadBalancerTargetPorts(client)
+	if err != nil {
+		return nil, err
+	}
+
+	monitorStatus, err := client.LoadBalancerServiceMonitorStatus()
+	if err != nil {
+		return nil, fmt.Errorf("Failed getting load balancer health check status: %w", err)
+	}
+
+	// Work out the health check status of each backend, using the first TCP target port of the backend.
+	checked := make(map[string]struct{})
+	for _, portMap := range portMaps {
+		if portMap.protocol != "tcp" {
+			continue
+		}
+
+		for _, target := range portMap.targets {
+			_, found := checked[target.backendName]
+			if found {
+				continue
+			}
+
+			checked[target.backendName] = struct{}{}
+
+			// Backends that aren't connected to the network can't be reached.
+			portName, found := targetPorts[target.address.String()]
+			if !found {
+				health[target.backendName] = "offline"
+				continue
+			}
+
+			// Backends that haven't been probed yet stay unknown.
+			dest := net.JoinHostPort(target.address.String(), fmt.Sprintf("%d", target.targetPort(portMap.listenPorts, 0)))
+			status, found := monitorStatus[portName][dest]
+			if found {
+				health[target.backendName] = status
+			}
+		}
+	}
+
+	return health, nil
+}
+
 // Leases returns a list of leases for the OVN network. Those are directly extracted from the OVN database.
 func (n *ovn) Leases(projectName string, clientType request.ClientType) ([]api.NetworkLease, error) {
 	leases := []api.NetworkLease{}
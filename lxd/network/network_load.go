@@ -10,6 +10,7 @@ var drivers = map[string]func() Network{
 	"sriov":    func() Network { return &sriov{} },
 	"ovn":      func() Network { return &ovn{} },
 	"physical": func() Network { return &physical{} },
+	"overlay":  func() Network { return &overlay{} },
 }
 
 // LoadByType loads a network by driver type.
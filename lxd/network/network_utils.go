@@ -1176,6 +1176,42 @@ func IPRangesOverlap(r1, r2 *shared.IPRange) bool {
 	return r1.ContainsIP(r2.Start) || r1.ContainsIP(r2.End)
 }
 
+// splitIPRange splits an IP range into the given number of contiguous parts and returns the part at index.
+// The last part includes any remainder. Returns nil if the range is too small to be split in that many parts.
+func splitIPRange(ipRange *shared.IPRange, parts int, index int) *shared.IPRange {
+	if ipRange.End == nil || parts < 1 || index < 0 || index >= parts {
+		return nil
+	}
+
+	start := big.NewInt(0).SetBytes(ipRange.Start)
+	end := big.NewInt(0).SetBytes(ipRange.End)
+
+	size := big.NewInt(0).Sub(end, start)
+	size.Add(size, big.NewInt(1))
+
+	partSize := big.NewInt(0).Div(size, big.NewInt(int64(parts)))
+	if partSize.Sign() <= 0 {
+		return nil
+	}
+
+	partStart := big.NewInt(0).Mul(partSize, big.NewInt(int64(index)))
+	partStart.Add(partStart, start)
+
+	partEnd := end
+	if index < parts-1 {
+		partEnd = big.NewInt(0).Add(partStart, partSize)
+		partEnd.Sub(partEnd, big.NewInt(1))
+	}
+
+	// Convert back to IPs of the same length as the original range.
+	toIP := func(n *big.Int) net.IP {
+		ip := make(net.IP, len(ipRange.Start))
+		return n.FillBytes(ip)
+	}
+
+	return &shared.IPRange{Start: toIP(partStart), End: toIP(partEnd)}
+}
+
 // InterfaceStatus returns the global unicast IP addresses configured on an interface and whether it is up or not.
 func InterfaceStatus(nicName string) ([]net.IP, bool, error) {
 	iface, err := net.InterfaceByName(nicName)
@@ -137,3 +137,60 @@ func Example_ipRangesOverlap() {
 	// Range1: 10.1.1.8-10.1.1.9, Range2: 10.1.1.4, overlapped: false
 
 }
+
+func Example_splitIPRange() {
+	ipRanges := []*shared.IPRange{
+		{Start: net.ParseIP("10.0.0.2").To4(), End: net.ParseIP("10.0.0.254").To4()},
+		{Start: net.ParseIP("fd42::2"), End: net.ParseIP("fd42::ffff")},
+		{Start: net.ParseIP("10.0.0.2").To4(), End: net.ParseIP("10.0.0.3").To4()},
+	}
+
+	for _, ipRange := range ipRanges {
+		for i := 0; i < 3; i++ {
+			part := splitIPRange(ipRange, 3, i)
+			if part == nil {
+				fmt.Printf("%s part %d: too small\n", ipRange, i)
+				continue
+			}
+
+			fmt.Printf("%s part %d: %s\n", ipRange, i, part)
+		}
+	}
+
+	// Output: 10.0.0.2-10.0.0.254 part 0: 10.0.0.2-10.0.0.85
+	// 10.0.0.2-10.0.0.254 part 1: 10.0.0.86-10.0.0.169
+	// 10.0.0.2-10.0.0.254 part 2: 10.0.0.170-10.0.0.254
+	// fd42::2-fd42::ffff part 0: fd42::2-fd42::5555
+	// fd42::2-fd42::ffff part 1: fd42::5556-fd42::aaa9
+	// fd42::2-fd42::ffff part 2: fd42::aaaa-fd42::ffff
+	// 10.0.0.2-10.0.0.3 part 0: too small
+	// 10.0.0.2-10.0.0.3 part 1: too small
+	// 10.0.0.2-10.0.0.3 part 2: too small
+}
+
+func Example_overlayFilterLeases() {
+	sharedRanges := []shared.IPRange{
+		{Start: net.ParseIP("10.0.0.2").To4(), End: net.ParseIP("10.0.0.254").To4()},
+		{Start: net.ParseIP("fd42::2"), End: net.ParseIP("fd42::ffff")},
+	}
+
+	memberRanges := []shared.IPRange{
+		{Start: net.ParseIP("10.0.0.2").To4(), End: net.ParseIP("10.0.0.127").To4()},
+		{Start: net.ParseIP("fd42::2"), End: net.ParseIP("fd42::7fff")},
+	}
+
+	leases := `1650000000 00:16:3e:00:00:01 10.0.0.10 c1 01:00:16:3e:00:00:01
+1650000000 00:16:3e:00:00:02 10.0.0.200 c2 01:00:16:3e:00:00:02
+1650000000 00:16:3e:00:00:03 10.1.0.10 c3 01:00:16:3e:00:00:03
+duid 00:01:00:01:29:00:00:00:00:16:3e:00:00:00
+1650000000 1234 fd42::10 c1 00:01:00:01:29:00:00:00:00:16:3e:00:00:01
+1650000000 5678 fd42::9000 c2 00:01:00:01:29:00:00:00:00:16:3e:00:00:02
+`
+
+	fmt.Print(string(overlayFilterLeases([]byte(leases), sharedRanges, memberRanges)))
+
+	// Output: 1650000000 00:16:3e:00:00:01 10.0.0.10 c1 01:00:16:3e:00:00:01
+	// 1650000000 00:16:3e:00:00:03 10.1.0.10 c3 01:00:16:3e:00:00:03
+	// duid 00:01:00:01:29:00:00:00:00:16:3e:00:00:00
+	// 1650000000 1234 fd42::10 c1 00:01:00:01:29:00:00:00:00:16:3e:00:00:01
+}
@@ -132,13 +132,23 @@ type OVNACLRule struct {
 	LogName   string // Log label name (requires Log be true).
 }
 
+// OVNLoadBalancerTarget represents an OVN load balancer Virtual IP target.
+type OVNLoadBalancerTarget struct {
+	Address         net.IP
+	Port            uint64
+	HealthCheckPort OVNSwitchPort // Switch port the address belongs to. Only used when health checks are enabled.
+}
+
 // OVNLoadBalancerVIP represents a OVN load balancer Virtual IP entry.
 type OVNLoadBalancerVIP struct {
 	Protocol      string // Either "tcp" or "udp". But only applies to port based VIPs.
 	ListenAddress net.IP
 	ListenPort    uint64
-	TargetAddress net.IP
-	TargetPort    uint64
+	Targets       []OVNLoadBalancerTarget
+
+	// HealthCheckSourceIP enables health checks of the targets of TCP port based IPv4 VIPs.
+	// OVN uses it as the source address of the probes sent to the targets.
+	HealthCheckSourceIP net.IP
 }
 
 // OVNRouterRoute represents a static route added to a logical router.
@@ -181,12 +191,20 @@ func NewOVN(s *state.State) (*OVN, error) {
 	client := &OVN{}
 	client.SetDatabaseAddress(nbConnection)
 
-	return client, err
+	// Use the southbound database configured for the local OVN controller (if any).
+	ovs := NewOVS()
+	sbConnection, err := ovs.OVNSouthboundDBRemoteAddress()
+	if err == nil {
+		client.sbDBAddr = sbConnection
+	}
+
+	return client, nil
 }
 
 // OVN command wrapper.
 type OVN struct {
-	dbAddr string
+	dbAddr   string
+	sbDBAddr string
 }
 
 // SetDatabaseAddress sets the address that runs the OVN northbound and southbound databases.
@@ -203,9 +221,27 @@ func (o *OVN) getNorthboundDB() string {
 	return o.dbAddr
 }
 
+// getSouthboundDB returns connection string to use for southbound database.
+func (o *OVN) getSouthboundDB() string {
+	if o.sbDBAddr == "" {
+		return "unix:/var/run/ovn/ovnsb_db.sock"
+	}
+
+	return o.sbDBAddr
+}
+
 // nbctl executes ovn-nbctl with arguments to connect to wrapper's northbound database.
 func (o *OVN) nbctl(args ...string) (string, error) {
-	dbAddr := o.getNorthboundDB()
+	return o.xbctl("ovn-nbctl", o.getNorthboundDB(), args...)
+}
+
+// sbctl executes ovn-sbctl with arguments to connect to wrapper's southbound database.
+func (o *OVN) sbctl(args ...string) (string, error) {
+	return o.xbctl("ovn-sbctl", o.getSouthboundDB(), args...)
+}
+
+// xbctl executes the specified OVN database command with arguments to connect to the specified database.
+func (o *OVN) xbctl(cmd string, dbAddr string, args ...string) (string, error) {
 	if strings.HasPrefix(dbAddr, "unix:") {
 		dbAddr = fmt.Sprintf("unix:%s", shared.HostPathFollow(strings.TrimPrefix(dbAddr, "unix:")))
 	}
@@ -220,7 +256,7 @@ func (o *OVN) nbctl(args ...string) (string, error) {
 		args = append(sslArgs, args...)
 	}
 
-	return shared.RunCommand("ovn-nbctl", append([]string{"--db", dbAddr}, args...)...)
+	return shared.RunCommand(cmd, append([]string{"--db", dbAddr}, args...)...)
 }
 
 // LogicalRouterAdd adds a named logical router.
@@ -1034,6 +1070,41 @@ func (o *OVN) LogicalSwitchPortDynamicIPs(portName OVNSwitchPort) ([]net.IP, err
 	return dynamicIPs, nil
 }
 
+// LogicalSwitchPortIPs returns the static and dynamic IPs of the logical switch ports of the specified switch.
+func (o *OVN) LogicalSwitchPortIPs(switchName OVNSwitch) (map[OVNSwitchPort][]net.IP, error) {
+	ports, err := o.LogicalSwitchPorts(switchName)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := o.nbctl("--format=csv", "--no-headings", "--data=bare", "--colum=name,addresses,dynamic_addresses", "list", "logical_switch_port")
+	if err != nil {
+		return nil, err
+	}
+
+	portIPs := make(map[OVNSwitchPort][]net.IP, len(ports))
+	for _, line := range util.SplitNTrimSpace(strings.TrimSpace(output), "\n", -1, true) {
+		// E.g. "lxd-net3-instance-fc933d65-0900-46b0-b5f2-4d323342e755-eth0,00:16:3e:5a:2b:7c dynamic,00:16:3e:5a:2b:7c 10.0.0.2"
+		fields := strings.SplitN(line, ",", 2)
+		portName := OVNSwitchPort(strings.TrimSpace(fields[0]))
+
+		_, found := ports[portName]
+		if !found || len(fields) < 2 {
+			continue
+		}
+
+		// Both address columns contain MAC addresses and keywords alongside the IPs, so just skip those.
+		for _, address := range strings.FieldsFunc(fields[1], func(r rune) bool { return r == ' ' || r == ',' || r == '"' }) {
+			ip := net.ParseIP(address)
+			if ip != nil {
+				portIPs[portName] = append(portIPs[portName], ip)
+			}
+		}
+	}
+
+	return portIPs, nil
+}
+
 // LogicalSwitchPortSetDNS sets up the switch DNS records for the DNS name resolving to the IPs of the switch port.
 // Attempts to find at most one IP for each IP protocol, preferring static addresses over dynamic.
 // Returns the DNS record UUID, IPv4 and IPv6 addresses used for DNS records.
@@ -1629,18 +1700,39 @@ func (o *OVN) LoadBalancerApply(loadBalancerName OVNLoadBalancer, routers []OVNR
 	// We have to use a separate load balancer for UDP rules so use this to keep track of whether we need it.
 	lbNames := make(map[string]struct{})
 
+	// Health checked target addresses mapped to the switch port and source address used for probing them.
+	healthCheckPortMappings := make(map[string]string)
+
 	// Build up the commands to add VIPs to the load balancer.
-	for _, r := range vips {
+	for i, r := range vips {
 		if r.ListenAddress == nil {
 			return fmt.Errorf("Missing VIP listen address")
 		}
 
-		if r.TargetAddress == nil {
-			return fmt.Errorf("Missing VIP target address")
+		if len(r.Targets) <= 0 {
+			return fmt.Errorf("Missing VIP target")
+		}
+
+		if r.ListenPort <= 0 && len(r.Targets) > 1 {
+			return fmt.Errorf("Multiple VIP targets require a listen port")
 		}
 
-		if (r.ListenPort > 0 && r.TargetPort <= 0) || (r.TargetPort > 0 && r.ListenPort <= 0) {
-			return fmt.Errorf("The listen and target ports must be specified together")
+		// Build up the comma delimited list of targets.
+		targetArgs := make([]string, 0, len(r.Targets))
+		for _, target := range r.Targets {
+			if target.Address == nil {
+				return fmt.Errorf("Missing VIP target address")
+			}
+
+			if (r.ListenPort > 0 && target.Port <= 0) || (target.Port > 0 && r.ListenPort <= 0) {
+				return fmt.Errorf("The listen and target ports must be specified together")
+			}
+
+			if r.ListenPort > 0 {
+				targetArgs = append(targetArgs, fmt.Sprintf("%s:%d", ipToString(target.Address), target.Port))
+			} else {
+				targetArgs = append(targetArgs, ipToString(target.Address))
+			}
 		}
 
 		if r.Protocol == "udp" {
@@ -1654,17 +1746,38 @@ func (o *OVN) LoadBalancerApply(loadBalancerName OVNLoadBalancer, routers []OVNR
 		if r.ListenPort > 0 {
 			args = append(args,
 				fmt.Sprintf("%s:%d", ipToString(r.ListenAddress), r.ListenPort),
-				fmt.Sprintf("%s:%d", ipToString(r.TargetAddress), r.TargetPort),
+				strings.Join(targetArgs, ","),
 				r.Protocol,
 			)
 		} else {
 			args = append(args,
 				fmt.Sprintf("%s", ipToString(r.ListenAddress)),
-				fmt.Sprintf("%s", ipToString(r.TargetAddress)),
+				strings.Join(targetArgs, ","),
+			)
+		}
+
+		// OVN only supports health checks for IPv4 port based VIPs.
+		if r.HealthCheckSourceIP != nil && r.Protocol == "tcp" && r.ListenPort > 0 && r.ListenAddress.To4() != nil {
+			healthCheckID := fmt.Sprintf("@hc%d", i)
+			args = append(args,
+				"--", fmt.Sprintf("--id=%s", healthCheckID), "create", "load_balancer_health_check",
+				fmt.Sprintf(`vip="%s:%d"`, r.ListenAddress.String(), r.ListenPort),
+				"--", "add", "load_balancer", lbTCPName, "health_check", healthCheckID,
 			)
+
+			for _, target := range r.Targets {
+				if target.HealthCheckPort != "" {
+					healthCheckPortMappings[target.Address.String()] = fmt.Sprintf("%s:%s", target.HealthCheckPort, r.HealthCheckSourceIP.String())
+				}
+			}
 		}
 	}
 
+	// Tell OVN which switch port each health checked target is reachable on.
+	for targetAddress, portMapping := range healthCheckPortMappings {
+		args = append(args, "--", "set", "load_balancer", lbTCPName, fmt.Sprintf(`ip_port_mappings:"%s"="%s"`, targetAddress, portMapping))
+	}
+
 	// If there are some VIP rules then associate the load balancer to the requested routers and switches.
 	if len(vips) > 0 {
 		for _, r := range routers {
@@ -1696,6 +1809,59 @@ func (o *OVN) LoadBalancerApply(loadBalancerName OVNLoadBalancer, routers []OVNR
 	return nil
 }
 
+// LoadBalancerHealthCheckPortSet updates the switch port and source address used for health checking the target
+// address of the specified load balancer.
+func (o *OVN) LoadBalancerHealthCheckPortSet(loadBalancerName OVNLoadBalancer, targetAddress net.IP, portName OVNSwitchPort, sourceIP net.IP) error {
+	lbTCPName := fmt.Sprintf("%s-tcp", loadBalancerName)
+
+	_, err := o.nbctl("set", "load_balancer", lbTCPName, fmt.Sprintf(`ip_port_mappings:"%s"="%s:%s"`, targetAddress.String(), portName, sourceIP.String()))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// LoadBalancerServiceMonitorStatus returns the status of the health checked load balancer targets.
+// The result is keyed on the switch port the targets are reachable on, and then on "<address>:<port>" of the TCP
+// targets, with the value being either "online" or "offline".
+func (o *OVN) LoadBalancerServiceMonitorStatus() (map[OVNSwitchPort]map[string]string, error) {
+	output, err := o.sbctl("--format=csv", "--no-headings", "--data=bare", "--colum=logical_port,ip,port,protocol,status", "list", "service_monitor")
+	if err != nil {
+		return nil, err
+	}
+
+	status := make(map[OVNSwitchPort]map[string]string)
+	for _, line := range util.SplitNTrimSpace(strings.TrimSpace(output), "\n", -1, true) {
+		// E.g. "lxd-net3-instance-fc933d65-0900-46b0-b5f2-4d323342e755-eth0,10.0.0.2,80,tcp,online"
+		fields := util.SplitNTrimSpace(line, ",", 5, false)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("Unrecognised service monitor output %q", line)
+		}
+
+		// An empty protocol means TCP.
+		if fields[3] != "" && fields[3] != "tcp" {
+			continue
+		}
+
+		// Targets that have not been probed yet don't have a status, and failed probes are reported as "error".
+		if fields[4] == "" {
+			continue
+		} else if fields[4] != "online" {
+			fields[4] = "offline"
+		}
+
+		portName := OVNSwitchPort(fields[0])
+		if status[portName] == nil {
+			status[portName] = make(map[string]string)
+		}
+
+		status[portName][net.JoinHostPort(fields[1], fields[2])] = fields[4]
+	}
+
+	return status, nil
+}
+
 // LoadBalancerDelete deletes the specified load balancer(s).
 func (o *OVN) LoadBalancerDelete(loadBalancerNames ...OVNLoadBalancer) error {
 	var args []string
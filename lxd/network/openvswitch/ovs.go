@@ -192,6 +192,22 @@ func (o *OVS) ChassisID() (string, error) {
 	return chassisID, nil
 }
 
+// OVNSouthboundDBRemoteAddress returns the address of the OVN southbound database used by the local OVN controller.
+func (o *OVS) OVNSouthboundDBRemoteAddress() (string, error) {
+	sbConnection, err := shared.RunCommand("ovs-vsctl", "get", "open_vswitch", ".", "external_ids:ovn-remote")
+	if err != nil {
+		return "", err
+	}
+
+	sbConnection = strings.TrimSpace(sbConnection)
+	sbConnection, err = unquote(sbConnection)
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed unquoting")
+	}
+
+	return sbConnection, nil
+}
+
 // OVNEncapIP returns the enscapsulation IP used for OVN underlay tunnels.
 func (o *OVS) OVNEncapIP() (net.IP, error) {
 	// ovs-vsctl's get command doesn't support its --format flag, so we always get the output quoted.
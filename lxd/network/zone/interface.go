@@ -24,8 +24,17 @@ type NetworkZone interface {
 	// Internal validation.
 	validateName(name string) error
 	validateConfig(config *api.NetworkZonePut) error
+	validateRecordName(name string) error
+	validateRecordConfig(info api.NetworkZoneRecordPut) error
 
 	// Modifications.
 	Update(config *api.NetworkZonePut, clientType request.ClientType) error
 	Delete() error
+
+	// Records.
+	AddRecord(req api.NetworkZoneRecordsPost) error
+	GetRecords() ([]api.NetworkZoneRecord, error)
+	GetRecord(name string) (*api.NetworkZoneRecord, error)
+	UpdateRecord(name string, req api.NetworkZoneRecordPut) error
+	DeleteRecord(name string) error
 }
@@ -86,6 +86,12 @@ func Create(s *state.State, projectName string, zoneInfo *api.NetworkZonesPost)
 		return err
 	}
 
+	// Serve the new zone straight away.
+	err = s.DNS.RefreshZone(zoneInfo.Name)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -144,6 +144,7 @@ func (d *zone) validateConfig(info *api.NetworkZonePut) error {
 
 	// Regular config keys.
 	rules["dns.nameservers"] = validate.IsListOf(validate.IsAny)
+	rules["dns.query.allow"] = validate.Optional(validate.IsListOf(validate.IsNetwork))
 	rules["network.nat"] = validate.Optional(validate.IsBool)
 
 	// Validate peer config.
@@ -258,6 +259,12 @@ func (d *zone) Update(config *api.NetworkZonePut, clientType request.ClientType)
 		return err
 	}
 
+	// Notify the DNS peers of any change.
+	err = d.state.DNS.RefreshZone(d.info.Name)
+	if err != nil {
+		return err
+	}
+
 	revert.Success()
 	return nil
 }
@@ -285,6 +292,12 @@ func (d *zone) Delete() error {
 		return err
 	}
 
+	// Notify the DNS peers of any change.
+	err = d.state.DNS.RefreshZone(d.info.Name)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -409,6 +422,28 @@ func (d *zone) Content() (*strings.Builder, error) {
 		}
 	}
 
+	// Add the custom records.
+	customRecords, err := d.GetRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, customRecord := range customRecords {
+		for _, entry := range customRecord.Entries {
+			ttl := entry.TTL
+			if ttl == 0 {
+				ttl = recordDefaultTTL
+			}
+
+			records = append(records, map[string]string{
+				"name":  customRecord.Name,
+				"type":  entry.Type,
+				"ttl":   fmt.Sprintf("%d", ttl),
+				"value": entry.Value,
+			})
+		}
+	}
+
 	// Get the nameservers.
 	nameservers := []string{}
 	for _, entry := range strings.Split(d.info.Config["dns.nameservers"], ",") {
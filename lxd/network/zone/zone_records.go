@@ -0,0 +1,221 @@
+package zone
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/validate"
+)
+
+// recordDefaultTTL is the TTL used for record entries which don't specify one.
+const recordDefaultTTL = 300
+
+// recordTypes lists the DNS record types that can be used in record entries.
+var recordTypes = []string{"A", "AAAA", "CAA", "CNAME", "DNAME", "MX", "NS", "PTR", "SRV", "SSHFP", "TLSA", "TXT"}
+
+// AddRecord adds a new record to the zone.
+func (d *zone) AddRecord(req api.NetworkZoneRecordsPost) error {
+	// Validate.
+	err := d.validateRecordConfig(req.NetworkZoneRecordPut)
+	if err != nil {
+		return err
+	}
+
+	err = d.validateRecordName(req.Name)
+	if err != nil {
+		return err
+	}
+
+	// Check that the record doesn't already exist.
+	_, _, err = d.state.Cluster.GetNetworkZoneRecord(d.id, req.Name)
+	if err == nil {
+		return api.StatusErrorf(409, "A record by that name already exists in the zone")
+	} else if err != db.ErrNoSuchObject {
+		return err
+	}
+
+	// Add the new record.
+	_, err = d.state.Cluster.CreateNetworkZoneRecord(d.id, req)
+	if err != nil {
+		return err
+	}
+
+	// Notify the DNS peers of the change.
+	err = d.state.DNS.RefreshZone(d.info.Name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetRecords returns all the records in the zone.
+func (d *zone) GetRecords() ([]api.NetworkZoneRecord, error) {
+	recordNames, err := d.state.Cluster.GetNetworkZoneRecordNames(d.id)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []api.NetworkZoneRecord{}
+	for _, recordName := range recordNames {
+		_, record, err := d.state.Cluster.GetNetworkZoneRecord(d.id, recordName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed loading record %q", recordName)
+		}
+
+		records = append(records, *record)
+	}
+
+	return records, nil
+}
+
+// GetRecord returns the named record from the zone.
+func (d *zone) GetRecord(name string) (*api.NetworkZoneRecord, error) {
+	_, record, err := d.state.Cluster.GetNetworkZoneRecord(d.id, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// UpdateRecord updates the named record in the zone.
+func (d *zone) UpdateRecord(name string, req api.NetworkZoneRecordPut) error {
+	// Validate.
+	err := d.validateRecordConfig(req)
+	if err != nil {
+		return err
+	}
+
+	// Get the record.
+	id, _, err := d.state.Cluster.GetNetworkZoneRecord(d.id, name)
+	if err != nil {
+		return err
+	}
+
+	// Update the record.
+	err = d.state.Cluster.UpdateNetworkZoneRecord(id, req)
+	if err != nil {
+		return err
+	}
+
+	// Notify the DNS peers of the change.
+	err = d.state.DNS.RefreshZone(d.info.Name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteRecord deletes the named record from the zone.
+func (d *zone) DeleteRecord(name string) error {
+	// Get the record.
+	id, _, err := d.state.Cluster.GetNetworkZoneRecord(d.id, name)
+	if err != nil {
+		return err
+	}
+
+	// Delete the record.
+	err = d.state.Cluster.DeleteNetworkZoneRecord(id)
+	if err != nil {
+		return err
+	}
+
+	// Notify the DNS peers of the change.
+	err = d.state.DNS.RefreshZone(d.info.Name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateRecordName checks the record name is a valid name relative to the zone.
+func (d *zone) validateRecordName(name string) error {
+	if name == "" {
+		return fmt.Errorf("Name is required")
+	}
+
+	if name == "@" {
+		return nil
+	}
+
+	if strings.HasSuffix(name, ".") {
+		return fmt.Errorf("Record names must be relative to the zone")
+	}
+
+	_, ok := dns.IsDomainName(name)
+	if !ok {
+		return fmt.Errorf("Invalid record name %q", name)
+	}
+
+	return nil
+}
+
+// validateRecordConfig checks the record config and entries are valid.
+func (d *zone) validateRecordConfig(info api.NetworkZoneRecordPut) error {
+	// Validate the config (only user keys are allowed).
+	err := d.validateConfigMap(info.Config, map[string]func(value string) error{})
+	if err != nil {
+		return err
+	}
+
+	// Validate the entries.
+	for _, entry := range info.Entries {
+		err := validate.IsOneOf(recordTypes...)(entry.Type)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid record entry type %q", entry.Type)
+		}
+
+		if entry.Value == "" {
+			return fmt.Errorf("Record entries of type %q require a value", entry.Type)
+		}
+
+		err = d.validateRecordValue(entry.Type, entry.Value)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid value %q for record entry of type %q", entry.Value, entry.Type)
+		}
+	}
+
+	return nil
+}
+
+// validateRecordValue checks that the value makes up exactly one record of the given type when rendered in the
+// zone file, so that it can't inject extra records or directives such as $ORIGIN or $TTL.
+func (d *zone) validateRecordValue(recordType string, value string) error {
+	for _, r := range value {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("Control characters aren't allowed")
+		}
+	}
+
+	zp := dns.NewZoneParser(strings.NewReader(fmt.Sprintf("record.%s. %d IN %s %s\n", d.info.Name, recordDefaultTTL, recordType, value)), "", "")
+
+	rr, ok := zp.Next()
+	if !ok {
+		err := zp.Err()
+		if err == nil {
+			err = fmt.Errorf("No record found")
+		}
+
+		return err
+	}
+
+	if rr.Header().Rrtype != dns.StringToType[recordType] {
+		return fmt.Errorf("Value makes up a record of type %q", dns.TypeToString[rr.Header().Rrtype])
+	}
+
+	_, ok = zp.Next()
+	if ok || zp.Err() != nil {
+		return fmt.Errorf("Trailing data after the record")
+	}
+
+	return nil
+}
@@ -4,10 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"sync"
 
 	"github.com/gorilla/mux"
 
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/auth"
+	"github.com/lxc/lxd/lxd/cluster"
 	clusterRequest "github.com/lxc/lxd/lxd/cluster/request"
+	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/lifecycle"
 	"github.com/lxc/lxd/lxd/network/acl"
 	"github.com/lxc/lxd/lxd/project"
@@ -22,18 +28,24 @@ import (
 var networkACLsCmd = APIEndpoint{
 	Path: "network-acls",
 
-	Get:  APIEndpointAction{Handler: networkACLsGet, AccessHandler: allowProjectPermission("networks", "view")},
-	Post: APIEndpointAction{Handler: networkACLsPost, AccessHandler: allowProjectPermission("networks", "manage-networks")},
+	Get:  APIEndpointAction{Handler: networkACLsGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
+	Post: APIEndpointAction{Handler: networkACLsPost, AccessHandler: allowProjectPermission(auth.EntitlementCanManageNetworks)},
 }
 
 var networkACLCmd = APIEndpoint{
 	Path: "network-acls/{name}",
 
-	Delete: APIEndpointAction{Handler: networkACLDelete, AccessHandler: allowProjectPermission("networks", "manage-networks")},
-	Get:    APIEndpointAction{Handler: networkACLGet, AccessHandler: allowProjectPermission("networks", "view")},
-	Put:    APIEndpointAction{Handler: networkACLPut, AccessHandler: allowProjectPermission("networks", "manage-networks")},
-	Patch:  APIEndpointAction{Handler: networkACLPut, AccessHandler: allowProjectPermission("networks", "manage-networks")},
-	Post:   APIEndpointAction{Handler: networkACLPost, AccessHandler: allowProjectPermission("networks", "manage-networks")},
+	Delete: APIEndpointAction{Handler: networkACLDelete, AccessHandler: allowProjectPermission(auth.EntitlementCanManageNetworks)},
+	Get:    APIEndpointAction{Handler: networkACLGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
+	Put:    APIEndpointAction{Handler: networkACLPut, AccessHandler: allowProjectPermission(auth.EntitlementCanManageNetworks)},
+	Patch:  APIEndpointAction{Handler: networkACLPut, AccessHandler: allowProjectPermission(auth.EntitlementCanManageNetworks)},
+	Post:   APIEndpointAction{Handler: networkACLPost, AccessHandler: allowProjectPermission(auth.EntitlementCanManageNetworks)},
+}
+
+var networkACLLogCmd = APIEndpoint{
+	Path: "network-acls/{name}/log",
+
+	Get: APIEndpointAction{Handler: networkACLLogGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
 }
 
 // API endpoints.
@@ -523,3 +535,109 @@ func networkACLPost(d *Daemon, r *http.Request) response.Response {
 	url := fmt.Sprintf("/%s/network-acls/%s", version.APIVersion, req.Name)
 	return response.SyncResponseLocation(true, nil, url)
 }
+
+// swagger:operation GET /1.0/network-acls/{name}/log network-acls network_acl_log_get
+//
+// Get the network ACL log
+//
+// Gets the traffic log entries matched by the logged rules of a specific network ACL, across all cluster members.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+// responses:
+//   "200":
+//     description: ACL log entries
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           type: array
+//           description: List of log entries
+//           items:
+//             $ref: "#/definitions/NetworkACLLogEntry"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func networkACLLogGet(d *Daemon, r *http.Request) response.Response {
+	projectName, _, err := project.NetworkProject(d.State().Cluster, projectParam(r))
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	netACL, err := acl.LoadByName(d.State(), projectName, mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Get the local entries.
+	entries, err := netACL.GetLog()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	var localMember string
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		localMember, err = tx.GetLocalNodeName()
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	for i := range entries {
+		entries[i].Location = localMember
+	}
+
+	// Collect the entries from the other cluster members.
+	clientType := clusterRequest.UserAgentClientType(r.Header.Get("User-Agent"))
+	if clientType == clusterRequest.ClientTypeNormal {
+		notifier, err := cluster.NewNotifier(d.State(), d.endpoints.NetworkCert(), d.serverCert(), cluster.NotifyAlive)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		var entriesLock sync.Mutex
+		err = notifier(func(client lxd.InstanceServer) error {
+			memberEntries, err := client.UseProject(projectName).GetNetworkACLLog(netACL.Info().Name)
+			if err != nil {
+				return err
+			}
+
+			entriesLock.Lock()
+			entries = append(entries, memberEntries...)
+			entriesLock.Unlock()
+
+			return nil
+		})
+		if err != nil {
+			return response.SmartError(err)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+
+	return response.SyncResponse(true, entries)
+}
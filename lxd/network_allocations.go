@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+
+	"github.com/lxc/lxd/lxd/auth"
+	clusterRequest "github.com/lxc/lxd/lxd/cluster/request"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/network"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+var networkAllocationsCmd = APIEndpoint{
+	Path: "network-allocations",
+
+	Get: APIEndpointAction{Handler: networkAllocationsGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
+}
+
+// swagger:operation GET /1.0/network-allocations network-allocations network_allocations_get
+//
+// Get the network allocations
+//
+// Returns a list of the IP addresses and subnets allocated on the networks of a project
+// (or of all projects), along with the entity using each of them.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: query
+//     name: all-projects
+//     description: Retrieve the allocations of all projects
+//     type: boolean
+// responses:
+//   "200":
+//     description: API endpoints
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           type: array
+//           description: List of network allocations
+//           items:
+//             $ref: "#/definitions/NetworkAllocation"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func networkAllocationsGet(d *Daemon, r *http.Request) response.Response {
+	projectName := projectParam(r)
+	allProjects := shared.IsTrue(queryParam(r, "all-projects"))
+
+	// Get the projects to report on along with the project their networks live in.
+	networkProjects := map[string]string{}
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		filter := db.ProjectFilter{}
+		if !allProjects {
+			filter.Name = &projectName
+		}
+
+		projects, err := tx.GetProjects(filter)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range projects {
+			if allProjects && !d.authorizer.UserHasPermission(r, auth.ObjectProject(p.Name), auth.EntitlementCanView) {
+				continue
+			}
+
+			networkProjects[p.Name] = project.NetworkProjectFromRecord(&p)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if !allProjects && networkProjects[projectName] == "" {
+		return response.NotFound(fmt.Errorf("Project %q not found", projectName))
+	}
+
+	projectNames := make([]string, 0, len(networkProjects))
+	for name := range networkProjects {
+		projectNames = append(projectNames, name)
+	}
+
+	sort.Strings(projectNames)
+
+	result := []api.NetworkAllocation{}
+	seenNetworks := map[string]bool{}
+	for _, projectName := range projectNames {
+		networkProjectName := networkProjects[projectName]
+
+		networkNames, err := d.cluster.GetNetworks(networkProjectName)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		for _, networkName := range networkNames {
+			n, err := network.LoadByName(d.State(), networkProjectName, networkName)
+			if err != nil {
+				return response.SmartError(fmt.Errorf("Failed loading network %q in project %q: %w", networkName, networkProjectName, err))
+			}
+
+			// Networks from the default project are shared by projects without their own networks, so
+			// only report their own allocations once.
+			networkKey := fmt.Sprintf("%s/%s", networkProjectName, networkName)
+			if !seenNetworks[networkKey] {
+				seenNetworks[networkKey] = true
+
+				allocations, err := networkAllocations(d, n)
+				if err != nil {
+					return response.SmartError(err)
+				}
+
+				result = append(result, allocations...)
+			}
+
+			// Instance addresses are reported for each project using the network.
+			leases, err := n.Leases(projectName, clusterRequest.ClientTypeNormal)
+			if err != nil && err != network.ErrNotImplemented {
+				return response.SmartError(fmt.Errorf("Failed getting leases for network %q in project %q: %w", networkName, projectName, err))
+			}
+
+			for _, lease := range leases {
+				// Uplink addresses are reported along with the network using them.
+				if lease.Type == "uplink" {
+					continue
+				}
+
+				address := allocationAddress(lease.Address)
+				if address == "" {
+					continue
+				}
+
+				result = append(result, api.NetworkAllocation{
+					Address: address,
+					Network: networkName,
+					UsedBy:  api.NewURL().Path(version.APIVersion, "instances", lease.Hostname).Project(projectName).String(),
+					Type:    "instance",
+					Hwaddr:  lease.Hwaddr,
+				})
+			}
+		}
+	}
+
+	return response.SyncResponse(true, result)
+}
+
+// networkAllocations returns the subnets, uplink addresses, forward and load balancer listen addresses
+// allocated by a network.
+func networkAllocations(d *Daemon, n network.Network) ([]api.NetworkAllocation, error) {
+	allocations := []api.NetworkAllocation{}
+	networkURL := api.NewURL().Path(version.APIVersion, "networks", n.Name()).Project(n.Project())
+	netConfig := n.Config()
+
+	// Network subnets.
+	for _, ipVersion := range []string{"ipv4", "ipv6"} {
+		_, subnet, err := net.ParseCIDR(netConfig[fmt.Sprintf("%s.address", ipVersion)])
+		if err != nil {
+			continue
+		}
+
+		allocations = append(allocations, api.NetworkAllocation{
+			Address: subnet.String(),
+			Network: n.Name(),
+			UsedBy:  networkURL.String(),
+			Type:    "network",
+			NAT:     shared.IsTrue(netConfig[fmt.Sprintf("%s.nat", ipVersion)]),
+		})
+	}
+
+	// Addresses allocated on the uplink network (OVN).
+	for _, ipVersion := range []string{"ipv4", "ipv6"} {
+		address := allocationAddress(netConfig[fmt.Sprintf("volatile.network.%s.address", ipVersion)])
+		if address == "" {
+			continue
+		}
+
+		allocations = append(allocations, api.NetworkAllocation{
+			Address: address,
+			Network: netConfig["network"],
+			UsedBy:  networkURL.String(),
+			Type:    "uplink",
+			NAT:     shared.IsTrue(netConfig[fmt.Sprintf("%s.nat", ipVersion)]),
+		})
+	}
+
+	// Forward listen addresses.
+	forwards, err := d.cluster.GetNetworkForwards(n.ID(), false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed loading forwards for network %q: %w", n.Name(), err)
+	}
+
+	for _, forward := range forwards {
+		address := allocationAddress(forward.ListenAddress)
+		if address == "" {
+			continue
+		}
+
+		allocations = append(allocations, api.NetworkAllocation{
+			Address: address,
+			Network: n.Name(),
+			UsedBy:  api.NewURL().Path(version.APIVersion, "networks", n.Name(), "forwards", forward.ListenAddress).Project(n.Project()).String(),
+			Type:    "network-forward",
+			NAT:     true,
+		})
+	}
+
+	// Load balancer listen addresses.
+	loadBalancers, err := d.cluster.GetNetworkLoadBalancers(n.ID(), false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed loading load balancers for network %q: %w", n.Name(), err)
+	}
+
+	for _, loadBalancer := range loadBalancers {
+		address := allocationAddress(loadBalancer.ListenAddress)
+		if address == "" {
+			continue
+		}
+
+		allocations = append(allocations, api.NetworkAllocation{
+			Address: address,
+			Network: n.Name(),
+			UsedBy:  api.NewURL().Path(version.APIVersion, "networks", n.Name(), "load-balancers", loadBalancer.ListenAddress).Project(n.Project()).String(),
+			Type:    "network-load-balancer",
+			NAT:     true,
+		})
+	}
+
+	return allocations, nil
+}
+
+// allocationAddress returns the single host CIDR for an IP address (or the address itself if already a CIDR).
+// Returns empty string if the address isn't valid.
+func allocationAddress(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		ip, _, _ = net.ParseCIDR(address)
+		if ip == nil {
+			return ""
+		}
+	}
+
+	if ip.To4() != nil {
+		return fmt.Sprintf("%s/32", ip.String())
+	}
+
+	return fmt.Sprintf("%s/128", ip.String())
+}
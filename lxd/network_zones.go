@@ -7,6 +7,7 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/lxc/lxd/lxd/auth"
 	clusterRequest "github.com/lxc/lxd/lxd/cluster/request"
 	"github.com/lxc/lxd/lxd/lifecycle"
 	"github.com/lxc/lxd/lxd/network/zone"
@@ -21,17 +22,17 @@ import (
 var networkZonesCmd = APIEndpoint{
 	Path: "network-zones",
 
-	Get:  APIEndpointAction{Handler: networkZonesGet, AccessHandler: allowProjectPermission("networks", "view")},
-	Post: APIEndpointAction{Handler: networkZonesPost, AccessHandler: allowProjectPermission("networks", "manage-networks")},
+	Get:  APIEndpointAction{Handler: networkZonesGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
+	Post: APIEndpointAction{Handler: networkZonesPost, AccessHandler: allowProjectPermission(auth.EntitlementCanManageNetworks)},
 }
 
 var networkZoneCmd = APIEndpoint{
 	Path: "network-zones/{name}",
 
-	Delete: APIEndpointAction{Handler: networkZoneDelete, AccessHandler: allowProjectPermission("networks", "manage-networks")},
-	Get:    APIEndpointAction{Handler: networkZoneGet, AccessHandler: allowProjectPermission("networks", "view")},
-	Put:    APIEndpointAction{Handler: networkZonePut, AccessHandler: allowProjectPermission("networks", "manage-networks")},
-	Patch:  APIEndpointAction{Handler: networkZonePut, AccessHandler: allowProjectPermission("networks", "manage-networks")},
+	Delete: APIEndpointAction{Handler: networkZoneDelete, AccessHandler: allowProjectPermission(auth.EntitlementCanManageNetworks)},
+	Get:    APIEndpointAction{Handler: networkZoneGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
+	Put:    APIEndpointAction{Handler: networkZonePut, AccessHandler: allowProjectPermission(auth.EntitlementCanManageNetworks)},
+	Patch:  APIEndpointAction{Handler: networkZonePut, AccessHandler: allowProjectPermission(auth.EntitlementCanManageNetworks)},
 }
 
 // API endpoints.
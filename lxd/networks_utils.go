@@ -19,11 +19,12 @@ func networkAutoAttach(cluster *db.Cluster, devName string) error {
 	return network.AttachInterface(dbInfo.Name, devName)
 }
 
-// networkUpdateForkdnsServersTask runs every 30s and refreshes the forkdns servers list.
+// networkUpdateForkdnsServersTask runs when the cluster member list changes and refreshes the forkdns servers
+// list of fan bridges and the tunnels of overlay networks.
 func networkUpdateForkdnsServersTask(s *state.State, heartbeatData *cluster.APIHeartbeat) error {
 	logger.Debug("Refreshing forkdns servers")
 
-	// Use project.Default here as forkdns (fan bridge) networks don't support projects.
+	// Use project.Default here as forkdns (fan bridge) and overlay networks don't support projects.
 	projectName := project.Default
 
 	// Get a list of managed networks
@@ -39,10 +40,12 @@ func networkUpdateForkdnsServersTask(s *state.State, heartbeatData *cluster.APIH
 			continue
 		}
 
-		if n.Type() == "bridge" && n.Config()["bridge.mode"] == "fan" {
+		if (n.Type() == "bridge" && n.Config()["bridge.mode"] == "fan") || n.Type() == "overlay" {
+			// Don't let a failure on one network stop the others from being refreshed.
 			err := n.HandleHeartbeat(heartbeatData)
 			if err != nil {
-				return err
+				logger.Errorf("Failed handling heartbeat for network %q from project %q: %v", name, projectName, err)
+				continue
 			}
 		}
 	}
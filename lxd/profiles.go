@@ -12,6 +12,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/auth"
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
@@ -31,18 +32,18 @@ import (
 var profilesCmd = APIEndpoint{
 	Path: "profiles",
 
-	Get:  APIEndpointAction{Handler: profilesGet, AccessHandler: allowProjectPermission("profiles", "view")},
-	Post: APIEndpointAction{Handler: profilesPost, AccessHandler: allowProjectPermission("profiles", "manage-profiles")},
+	Get:  APIEndpointAction{Handler: profilesGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
+	Post: APIEndpointAction{Handler: profilesPost, AccessHandler: allowProjectPermission(auth.EntitlementCanManageProfiles)},
 }
 
 var profileCmd = APIEndpoint{
 	Path: "profiles/{name}",
 
-	Delete: APIEndpointAction{Handler: profileDelete, AccessHandler: allowProjectPermission("profiles", "manage-profiles")},
-	Get:    APIEndpointAction{Handler: profileGet, AccessHandler: allowProjectPermission("profiles", "view")},
-	Patch:  APIEndpointAction{Handler: profilePatch, AccessHandler: allowProjectPermission("profiles", "manage-profiles")},
-	Post:   APIEndpointAction{Handler: profilePost, AccessHandler: allowProjectPermission("profiles", "manage-profiles")},
-	Put:    APIEndpointAction{Handler: profilePut, AccessHandler: allowProjectPermission("profiles", "manage-profiles")},
+	Delete: APIEndpointAction{Handler: profileDelete, AccessHandler: allowProjectPermission(auth.EntitlementCanManageProfiles)},
+	Get:    APIEndpointAction{Handler: profileGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
+	Patch:  APIEndpointAction{Handler: profilePatch, AccessHandler: allowProjectPermission(auth.EntitlementCanManageProfiles)},
+	Post:   APIEndpointAction{Handler: profilePost, AccessHandler: allowProjectPermission(auth.EntitlementCanManageProfiles)},
+	Put:    APIEndpointAction{Handler: profilePut, AccessHandler: allowProjectPermission(auth.EntitlementCanManageProfiles)},
 }
 
 // swagger:operation GET /1.0/profiles profiles profiles_get
@@ -158,7 +159,7 @@ func profilesGet(d *Daemon, r *http.Request) response.Response {
 			apiProfiles := make([]*api.Profile, len(profiles))
 			for i, profile := range profiles {
 				apiProfiles[i] = db.ProfileToAPI(&profile)
-				apiProfiles[i].UsedBy = project.FilterUsedBy(r, apiProfiles[i].UsedBy)
+				apiProfiles[i].UsedBy = project.FilterUsedBy(d.authorizer, r, apiProfiles[i].UsedBy)
 			}
 
 			result = apiProfiles
@@ -330,7 +331,7 @@ func profileGet(d *Daemon, r *http.Request) response.Response {
 		}
 
 		resp = db.ProfileToAPI(profile)
-		resp.UsedBy = project.FilterUsedBy(r, resp.UsedBy)
+		resp.UsedBy = project.FilterUsedBy(d.authorizer, r, resp.UsedBy)
 
 		return nil
 	})
@@ -10,10 +10,10 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/lxc/lxd/lxd/auth"
 	"github.com/lxc/lxd/lxd/db"
 	deviceconfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
-	"github.com/lxc/lxd/lxd/rbac"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -771,6 +771,7 @@ func isContainerLowLevelOptionForbidden(key string) bool {
 	}
 
 	if shared.StringInSlice(key, []string{
+		"boot.host_shutdown_action",
 		"boot.host_shutdown_timeout",
 		"linux.kernel_modules",
 		"raw.apparmor",
@@ -790,6 +791,7 @@ func isContainerLowLevelOptionForbidden(key string) bool {
 // Return true if a low-level VM option is forbidden.
 func isVMLowLevelOptionForbidden(key string) bool {
 	if shared.StringInSlice(key, []string{
+		"boot.host_shutdown_action",
 		"boot.host_shutdown_timeout",
 		"limits.memory.hugepages",
 		"raw.idmap",
@@ -878,6 +880,70 @@ func AllowVolumeUpdate(tx *db.ClusterTx, projectName, volumeName string, req api
 	return nil
 }
 
+// AllowBucketCreation returns an error if any project-specific limit or
+// restriction is violated when creating a new storage bucket in a project.
+func AllowBucketCreation(tx *db.ClusterTx, projectName string, req api.StorageBucketsPost) error {
+	info, err := fetchProject(tx, projectName, true)
+	if err != nil {
+		return err
+	}
+
+	if info == nil {
+		return nil
+	}
+
+	// If "limits.disk" is not set, there's nothing to do.
+	if info.Project.Config["limits.disk"] == "" {
+		return nil
+	}
+
+	// Add the bucket being created.
+	bucket := &db.StorageBucket{}
+	bucket.Name = req.Name
+	bucket.Config = req.Config
+	info.Buckets = append(info.Buckets, bucket)
+
+	err = checkRestrictionsAndAggregateLimits(tx, info)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AllowBucketUpdate checks that project limits and restrictions are not
+// violated when changing an existing storage bucket in a project.
+func AllowBucketUpdate(tx *db.ClusterTx, projectName string, bucketID int64, req api.StorageBucketPut) error {
+	info, err := fetchProject(tx, projectName, true)
+	if err != nil {
+		return err
+	}
+
+	if info == nil {
+		return nil
+	}
+
+	// If "limits.disk" is not set, there's nothing to do.
+	if info.Project.Config["limits.disk"] == "" {
+		return nil
+	}
+
+	// Change the bucket being updated.
+	for i, bucket := range info.Buckets {
+		if bucket.ID != bucketID {
+			continue
+		}
+		info.Buckets[i].Config = req.Config
+	}
+
+	err = checkRestrictionsAndAggregateLimits(tx, info)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // AllowProfileUpdate checks that project limits and restrictions are not
 // violated when changing a profile.
 func AllowProfileUpdate(tx *db.ClusterTx, projectName, profileName string, req api.ProfilePut) error {
@@ -1088,13 +1154,14 @@ type projectInfo struct {
 	Profiles  []db.Profile
 	Instances []db.Instance
 	Volumes   []db.StorageVolumeArgs
+	Buckets   []*db.StorageBucket
 }
 
 // Fetch the given project from the database along with its profiles, instances
-// and possibly custom volumes.
+// and possibly custom volumes and storage buckets.
 //
-// If the skipIfNoLimits flag is true, then profiles, instances and volumes
-// won't be loaded if the profile has no limits set on it, and nil will be
+// If the skipIfNoLimits flag is true, then profiles, instances, volumes and
+// buckets won't be loaded if the profile has no limits set on it, and nil will be
 // returned.
 func fetchProject(tx *db.ClusterTx, projectName string, skipIfNoLimits bool) (*projectInfo, error) {
 	project, err := tx.GetProject(projectName)
@@ -1135,11 +1202,19 @@ func fetchProject(tx *db.ClusterTx, projectName string, skipIfNoLimits bool) (*p
 		return nil, errors.Wrap(err, "Fetch project custom volumes from database")
 	}
 
+	buckets, err := tx.GetStoragePoolBuckets(false, db.StorageBucketFilter{
+		Project: &projectName,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Fetch project storage buckets from database")
+	}
+
 	info := &projectInfo{
 		Project:   project,
 		Profiles:  profiles,
 		Instances: instances,
 		Volumes:   volumes,
+		Buckets:   buckets,
 	}
 
 	return info, nil
@@ -1182,7 +1257,7 @@ func expandInstancesConfigAndDevices(instances []db.Instance, profiles []db.Prof
 }
 
 // Sum of the effective values for the given limits across all project
-// enties (instances and custom volumes).
+// enties (instances, custom volumes and storage buckets).
 func getTotalsAcrossProjectEntities(info *projectInfo, keys []string, skipUnset bool) (map[string]int64, error) {
 	totals := map[string]int64{}
 
@@ -1209,6 +1284,27 @@ func getTotalsAcrossProjectEntities(info *projectInfo, keys []string, skipUnset
 				}
 				totals[key] += limit
 			}
+
+			for _, bucket := range info.Buckets {
+				value, ok := bucket.Config["size"]
+				if !ok {
+					if skipUnset {
+						continue
+					}
+
+					return nil, fmt.Errorf(
+						"Storage bucket %s in project %s has no 'size' config set",
+						bucket.Name, info.Project.Name)
+				}
+
+				limit, err := units.ParseByteSizeString(value)
+				if err != nil {
+					return nil, errors.Wrapf(
+						err, "Parse 'size' for storage bucket %s in project %s",
+						bucket.Name, info.Project.Name)
+				}
+				totals[key] += limit
+			}
 		}
 	}
 
@@ -1350,9 +1446,9 @@ var aggregateLimitConfigValuePrinters = map[string]func(int64) string{
 }
 
 // FilterUsedBy filters a UsedBy list based on project access
-func FilterUsedBy(r *http.Request, entries []string) []string {
+func FilterUsedBy(authorizer auth.Authorizer, r *http.Request, entries []string) []string {
 	// Shortcut for admins and non-RBAC environments.
-	if rbac.UserIsAdmin(r) {
+	if authorizer.UserIsAdmin(r) {
 		return entries
 	}
 
@@ -1374,7 +1470,7 @@ func FilterUsedBy(r *http.Request, entries []string) []string {
 			projectName = val
 		}
 
-		if !rbac.UserHasPermission(r, projectName, "view") {
+		if !authorizer.UserHasPermission(r, auth.ObjectProject(projectName), auth.EntitlementCanView) {
 			continue
 		}
 
@@ -1404,9 +1500,9 @@ func projectHasRestriction(project *db.Project, restrictionKey string, blockValu
 }
 
 // CheckClusterTargetRestriction check if user is allowed to use cluster member targeting
-func CheckClusterTargetRestriction(tx *db.ClusterTx, r *http.Request, projectName string, targetFlag string) error {
+func CheckClusterTargetRestriction(authorizer auth.Authorizer, tx *db.ClusterTx, r *http.Request, projectName string, targetFlag string) error {
 	// Allow server administrators to move instances around even when restricted (node evacuation, ...)
-	if rbac.UserIsAdmin(r) {
+	if authorizer.UserIsAdmin(r) {
 		return nil
 	}
 
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/lxc/lxd/lxd/auth"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/project"
@@ -170,7 +171,7 @@ func TestCheckClusterTargetRestriction_RestrictedTrue(t *testing.T) {
 
 	req := &http.Request{}
 
-	err = project.CheckClusterTargetRestriction(tx, req, "p1", "n1")
+	err = project.CheckClusterTargetRestriction(auth.NewAuthorizer(), tx, req, "p1", "n1")
 	assert.EqualError(t, err, "This project doesn't allow cluster member targeting")
 }
 
@@ -190,6 +191,6 @@ func TestCheckClusterTargetRestriction_RestrictedFalse(t *testing.T) {
 
 	req := &http.Request{}
 
-	err = project.CheckClusterTargetRestriction(tx, req, "p1", "n1")
+	err = project.CheckClusterTargetRestriction(auth.NewAuthorizer(), tx, req, "p1", "n1")
 	assert.NoError(t, err)
 }
@@ -19,6 +19,7 @@ import (
 	"gopkg.in/macaroon-bakery.v2/httpbakery"
 	"gopkg.in/macaroon-bakery.v2/httpbakery/agent"
 
+	"github.com/lxc/lxd/lxd/auth"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
 )
@@ -45,12 +46,6 @@ type rbacStatus struct {
 // Errors
 var errUnknownUser = fmt.Errorf("Unknown RBAC user")
 
-// UserAccess struct for permission checks.
-type UserAccess struct {
-	Admin    bool
-	Projects map[string][]string
-}
-
 // Server represents an RBAC server.
 type Server struct {
 	apiURL string
@@ -283,7 +278,7 @@ func (r *Server) RenameProject(id int64, name string) error {
 }
 
 // UserAccess returns a UserAccess struct for the user.
-func (r *Server) UserAccess(username string) (*UserAccess, error) {
+func (r *Server) UserAccess(username string) (*auth.UserAccess, error) {
 	r.permissionsLock.Lock()
 	defer r.permissionsLock.Unlock()
 
@@ -301,7 +296,7 @@ func (r *Server) UserAccess(username string) (*UserAccess, error) {
 	}
 
 	// Prepare the response.
-	access := UserAccess{
+	access := auth.UserAccess{
 		Admin:    shared.StringInSlice("admin", permissions[""]),
 		Projects: map[string][]string{},
 	}
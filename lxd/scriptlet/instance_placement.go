@@ -0,0 +1,176 @@
+package scriptlet
+
+import (
+	"context"
+	"fmt"
+
+	"go.starlark.net/starlark"
+
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// instancePlacementFunction is the function the instance placement scriptlet must define.
+const instancePlacementFunction = "instance_placement"
+
+// instancePlacementBuiltins are the functions made available to the instance placement scriptlet.
+// They are only used for validation here, the functions themselves are bound to each run.
+var instancePlacementBuiltins = starlark.StringDict{
+	"log_info":                     starlark.None,
+	"log_warn":                     starlark.None,
+	"log_error":                    starlark.None,
+	"set_target":                   starlark.None,
+	"get_cluster_member_resources": starlark.None,
+}
+
+// InstancePlacementValidate validates the instance placement scriptlet.
+func InstancePlacementValidate(src string) error {
+	return compile(instancePlacementFunction, src, instancePlacementBuiltins, instancePlacementFunction)
+}
+
+// InstancePlacementRun runs the instance placement scriptlet for an instance creation request in the given
+// project and returns the name of the chosen cluster member among the candidates.
+// An empty name is returned when the scriptlet doesn't choose a member, leaving the choice to the default
+// placement logic. The scriptlet rejects the request by calling fail(), in which case an error is returned.
+func InstancePlacementRun(ctx context.Context, src string, projectName string, req *api.InstancesPost, candidates []api.ClusterMember, memberResources func(memberName string) (*api.Resources, error)) (string, error) {
+	logCtx := log.Ctx{"scriptlet": instancePlacementFunction, "project": projectName, "instance": req.Name}
+
+	isCandidate := func(memberName string) bool {
+		for _, candidate := range candidates {
+			if candidate.ServerName == memberName {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	var target string
+
+	logFunc := func(level string) *starlark.Builtin {
+		return starlark.NewBuiltin("log_"+level, func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			msg := logMessage(args)
+
+			switch level {
+			case "warn":
+				logger.Warn(msg, logCtx)
+			case "error":
+				logger.Error(msg, logCtx)
+			default:
+				logger.Info(msg, logCtx)
+			}
+
+			return starlark.None, nil
+		})
+	}
+
+	setTarget := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var memberName string
+
+		err := starlark.UnpackArgs(b.Name(), args, kwargs, "member_name", &memberName)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isCandidate(memberName) {
+			return nil, fmt.Errorf("Cluster member %q isn't a candidate for the instance", memberName)
+		}
+
+		target = memberName
+
+		return starlark.None, nil
+	}
+
+	getClusterMemberResources := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var memberName string
+
+		err := starlark.UnpackArgs(b.Name(), args, kwargs, "member_name", &memberName)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isCandidate(memberName) {
+			return nil, fmt.Errorf("Cluster member %q isn't a candidate for the instance", memberName)
+		}
+
+		res, err := memberResources(memberName)
+		if err != nil {
+			return nil, fmt.Errorf("Failed getting resources of cluster member %q: %w", memberName, err)
+		}
+
+		return toStarlark(res)
+	}
+
+	predeclared := starlark.StringDict{
+		"log_info":                     logFunc("info"),
+		"log_warn":                     logFunc("warn"),
+		"log_error":                    logFunc("error"),
+		"set_target":                   starlark.NewBuiltin("set_target", setTarget),
+		"get_cluster_member_resources": starlark.NewBuiltin("get_cluster_member_resources", getClusterMemberResources),
+	}
+
+	thread := &starlark.Thread{
+		Name:  instancePlacementFunction,
+		Print: func(thread *starlark.Thread, msg string) { logger.Info(msg, logCtx) },
+	}
+
+	// Stop the scriptlet when the request is cancelled.
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel(ctx.Err().Error())
+		case <-done:
+		}
+	}()
+
+	globals, err := starlark.ExecFile(thread, instancePlacementFunction, src, predeclared)
+	if err != nil {
+		return "", err
+	}
+
+	fn, ok := globals[instancePlacementFunction]
+	if !ok {
+		return "", fmt.Errorf("Scriptlet doesn't define a %q function", instancePlacementFunction)
+	}
+
+	// Build the arguments, making sure the scriptlet always gets dicts for the config and devices.
+	reqCopy := *req
+	if reqCopy.Config == nil {
+		reqCopy.Config = map[string]string{}
+	}
+
+	if reqCopy.Devices == nil {
+		reqCopy.Devices = map[string]map[string]string{}
+	}
+
+	request, err := toStarlark(reqCopy)
+	if err != nil {
+		return "", fmt.Errorf("Failed converting request: %w", err)
+	}
+
+	err = request.(*starlark.Dict).SetKey(starlark.String("project"), starlark.String(projectName))
+	if err != nil {
+		return "", err
+	}
+
+	members, err := toStarlark(candidates)
+	if err != nil {
+		return "", fmt.Errorf("Failed converting candidate members: %w", err)
+	}
+
+	_, err = starlark.Call(thread, fn, starlark.Tuple{request, members}, nil)
+	if err != nil {
+		evalErr, ok := err.(*starlark.EvalError)
+		if ok {
+			logger.Debug("Instance placement scriptlet failed", log.Ctx{"project": projectName, "instance": req.Name, "backtrace": evalErr.Backtrace()})
+		}
+
+		return "", err
+	}
+
+	return target, nil
+}
@@ -0,0 +1,89 @@
+package scriptlet_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/scriptlet"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// The scriptlet must be valid Starlark defining the instance_placement function.
+func TestInstancePlacementValidate(t *testing.T) {
+	assert.NoError(t, scriptlet.InstancePlacementValidate(`
+def instance_placement(request, candidate_members):
+    set_target(candidate_members[0]["server_name"])
+`))
+
+	assert.Error(t, scriptlet.InstancePlacementValidate(`def instance_placement(request, candidate_members)`))
+	assert.Error(t, scriptlet.InstancePlacementValidate(`def other(request, candidate_members): pass`))
+	assert.Error(t, scriptlet.InstancePlacementValidate(`
+def instance_placement(request, candidate_members):
+    unknown_function()
+`))
+}
+
+func TestInstancePlacementRun(t *testing.T) {
+	src := `
+def instance_placement(request, candidate_members):
+    if request["config"].get("user.reject") == "true":
+        fail("Rejected for %s" % request["project"])
+
+    if request["config"].get("user.default") == "true":
+        return
+
+    for member in candidate_members:
+        resources = get_cluster_member_resources(member["server_name"])
+        if resources["gpu"]["total"] > 0:
+            continue
+
+        set_target(member["server_name"])
+        return
+`
+
+	candidates := []api.ClusterMember{{ServerName: "gpu01"}, {ServerName: "node01"}}
+	memberResources := func(memberName string) (*api.Resources, error) {
+		res := &api.Resources{}
+		if memberName == "gpu01" {
+			res.GPU.Total = 1
+		}
+
+		return res, nil
+	}
+
+	run := func(config map[string]string) (string, error) {
+		req := &api.InstancesPost{Name: "c1", InstancePut: api.InstancePut{Config: config}}
+		return scriptlet.InstancePlacementRun(context.Background(), src, "default", req, candidates, memberResources)
+	}
+
+	target, err := run(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "node01", target)
+
+	target, err = run(map[string]string{"user.default": "true"})
+	require.NoError(t, err)
+	assert.Equal(t, "", target)
+
+	_, err = run(map[string]string{"user.reject": "true"})
+	assert.EqualError(t, err, "fail: Rejected for default")
+}
+
+// Only candidate members can be chosen.
+func TestInstancePlacementRun_NotCandidate(t *testing.T) {
+	src := `
+def instance_placement(request, candidate_members):
+    set_target("node02")
+`
+
+	memberResources := func(memberName string) (*api.Resources, error) {
+		return nil, fmt.Errorf("Unexpected call")
+	}
+
+	req := &api.InstancesPost{Name: "c1"}
+	_, err := scriptlet.InstancePlacementRun(context.Background(), src, "default", req, []api.ClusterMember{{ServerName: "node01"}}, memberResources)
+	assert.Error(t, err)
+}
@@ -0,0 +1,130 @@
+package scriptlet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// compile parses and resolves a scriptlet, checking that it defines the given top-level function.
+func compile(name string, src string, predeclared starlark.StringDict, function string) error {
+	f, err := syntax.Parse(name, src, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = starlark.FileProgram(f, predeclared.Has)
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range f.Stmts {
+		def, ok := stmt.(*syntax.DefStmt)
+		if ok && def.Name.Name == function {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Scriptlet doesn't define a %q function", function)
+}
+
+// toStarlark converts a value which can be marshalled to JSON into its Starlark equivalent.
+// Structs are converted to dicts keyed by their JSON field names.
+func toStarlark(value interface{}) (starlark.Value, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	err = decoder.Decode(&decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonToStarlark(decoded)
+}
+
+// jsonToStarlark converts a value decoded from JSON into its Starlark equivalent.
+func jsonToStarlark(value interface{}) (starlark.Value, error) {
+	switch v := value.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case string:
+		return starlark.String(v), nil
+	case json.Number:
+		i, err := v.Int64()
+		if err == nil {
+			return starlark.MakeInt64(i), nil
+		}
+
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+
+		return starlark.Float(f), nil
+	case []interface{}:
+		list := make([]starlark.Value, 0, len(v))
+		for _, entry := range v {
+			item, err := jsonToStarlark(entry)
+			if err != nil {
+				return nil, err
+			}
+
+			list = append(list, item)
+		}
+
+		return starlark.NewList(list), nil
+	case map[string]interface{}:
+		// Insert keys in a stable order as Starlark dicts preserve insertion order.
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		dict := starlark.NewDict(len(v))
+		for _, key := range keys {
+			item, err := jsonToStarlark(v[key])
+			if err != nil {
+				return nil, err
+			}
+
+			err = dict.SetKey(starlark.String(key), item)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return dict, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported value type %T", value)
+}
+
+// logMessage joins the arguments of a logging builtin into a single message.
+func logMessage(args starlark.Tuple) string {
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		s, ok := starlark.AsString(arg)
+		if !ok {
+			s = arg.String()
+		}
+
+		parts = append(parts, s)
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -1044,6 +1045,8 @@ func (b *lxdBackend) RefreshCustomVolume(projectName string, srcProjectName stri
 
 	if contentDBType == db.StoragePoolVolumeContentTypeBlock {
 		contentType = drivers.ContentTypeBlock
+	} else if contentDBType == db.StoragePoolVolumeContentTypeISO {
+		contentType = drivers.ContentTypeISO
 	}
 
 	storagePoolSupported := false
@@ -1134,7 +1137,7 @@ func (b *lxdBackend) RefreshCustomVolume(projectName string, srcProjectName stri
 
 		var volSize int64
 
-		if contentType == drivers.ContentTypeBlock {
+		if contentType == drivers.ContentTypeBlock || contentType == drivers.ContentTypeISO {
 			// Get the src volume name on storage.
 			srcVolStorageName := project.StorageVolume(srcProjectName, srcVolName)
 			srcVol := srcPool.newVolume(drivers.VolumeTypeCustom, contentType, srcVolStorageName, srcVolRow.Config)
@@ -1565,9 +1568,30 @@ func (b *lxdBackend) CreateInstanceFromMigration(inst instance.Instance, conn io
 			if !revert {
 				return
 			}
-			b.DeleteInstance(inst, op)
+
+			if args.ClusterMove {
+				b.CleanupInstanceMove(inst, op)
+			} else {
+				b.DeleteInstance(inst, op)
+			}
 		}()
 
+		// When moving an instance between cluster members the instance and snapshot records already exist,
+		// so only the volume records for this member need creating.
+		if args.ClusterMove {
+			err = VolumeDBCreate(b.state, b, projectName, args.Name, "", volType, false, args.Config, time.Time{}, contentType)
+			if err != nil {
+				return err
+			}
+
+			for _, snapName := range args.Snapshots {
+				err = VolumeDBCreate(b.state, b, projectName, drivers.GetSnapshotVolumeName(args.Name, snapName), "", volType, true, args.Config, time.Time{}, contentType)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
 		// If the negotiated migration method is rsync and the instance's base image is
 		// already on the host then setup a pre-filler that will unpack the local image
 		// to try and speed up the rsync of the incoming volume by avoiding the need to
@@ -1818,6 +1842,84 @@ func (b *lxdBackend) DeleteInstance(inst instance.Instance, op *operations.Opera
 	return nil
 }
 
+// CleanupInstanceMove removes the instance's root volume and snapshot volumes from this member after the instance
+// has been moved to another cluster member. Unlike DeleteInstance, the instance and snapshot records are left intact.
+func (b *lxdBackend) CleanupInstanceMove(inst instance.Instance, op *operations.Operation) error {
+	logger := logging.AddContext(b.logger, log.Ctx{"project": inst.Project(), "instance": inst.Name()})
+	logger.Debug("CleanupInstanceMove started")
+	defer logger.Debug("CleanupInstanceMove finished")
+
+	if inst.IsSnapshot() {
+		return fmt.Errorf("Instance must not be a snapshot")
+	}
+
+	// Volumes on remote storage are shared between members, so there is nothing to clean up.
+	if b.driver.Info().Remote {
+		return nil
+	}
+
+	volType, err := InstanceTypeToVolumeType(inst.Type())
+	if err != nil {
+		return err
+	}
+
+	volDBType, err := VolumeTypeToDBType(volType)
+	if err != nil {
+		return err
+	}
+
+	contentType := InstanceContentType(inst)
+	volStorageName := project.Instance(inst.Project(), inst.Name())
+
+	snapshots, err := b.state.Cluster.GetInstanceSnapshotsNames(inst.Project(), inst.Name())
+	if err != nil {
+		return err
+	}
+
+	// Remove the snapshot volumes first, in reverse order so that the newest snapshots are removed first.
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		_, snapName, _ := shared.InstanceGetParentAndSnapshotName(snapshots[i])
+		snapVol := b.newVolume(volType, contentType, drivers.GetSnapshotVolumeName(volStorageName, snapName), nil)
+
+		if b.driver.HasVolume(snapVol) {
+			err = b.driver.DeleteVolumeSnapshot(snapVol, op)
+			if err != nil {
+				return errors.Wrapf(err, "Error deleting storage volume snapshot %q", snapName)
+			}
+		}
+
+		err = b.state.Cluster.RemoveStoragePoolVolume(inst.Project(), drivers.GetSnapshotVolumeName(inst.Name(), snapName), volDBType, b.ID())
+		if err != nil && errors.Cause(err) != db.ErrNoSuchObject {
+			return errors.Wrapf(err, "Error deleting storage volume snapshot %q from database", snapName)
+		}
+	}
+
+	vol := b.newVolume(volType, contentType, volStorageName, nil)
+	if b.driver.HasVolume(vol) {
+		err = b.driver.DeleteVolume(vol, op)
+		if err != nil {
+			return errors.Wrapf(err, "Error deleting storage volume")
+		}
+	}
+
+	err = b.removeInstanceSymlink(inst.Type(), inst.Project(), inst.Name())
+	if err != nil {
+		return err
+	}
+
+	err = b.removeInstanceSnapshotSymlinkIfUnused(inst.Type(), inst.Project(), inst.Name())
+	if err != nil {
+		return err
+	}
+
+	err = b.state.Cluster.RemoveStoragePoolVolume(inst.Project(), inst.Name(), volDBType, b.ID())
+	if err != nil && errors.Cause(err) != db.ErrNoSuchObject {
+		return errors.Wrapf(err, "Error deleting storage volume from database")
+	}
+
+	return nil
+}
+
 // UpdateInstance updates an instance volume's config.
 func (b *lxdBackend) UpdateInstance(inst instance.Instance, newDesc string, newConfig map[string]string, op *operations.Operation) error {
 	logger := logging.AddContext(b.logger, log.Ctx{"project": inst.Project(), "instance": inst.Name(), "newDesc": newDesc, "newConfig": newConfig})
@@ -1969,8 +2071,10 @@ func (b *lxdBackend) MigrateInstance(inst instance.Instance, conn io.ReadWriteCl
 }
 
 // BackupInstance creates an instance backup.
-func (b *lxdBackend) BackupInstance(inst instance.Instance, tarWriter *instancewriter.InstanceTarWriter, optimized bool, snapshots bool, op *operations.Operation) error {
-	logger := logging.AddContext(b.logger, log.Ctx{"project": inst.Project(), "instance": inst.Name(), "optimized": optimized, "snapshots": snapshots})
+// If fromSnapshot is specified, the instance volume is exported from that snapshot of the instance rather than
+// from the live volume.
+func (b *lxdBackend) BackupInstance(inst instance.Instance, tarWriter *instancewriter.InstanceTarWriter, optimized bool, snapshots bool, fromSnapshot string, parent *backup.Parent, op *operations.Operation) error {
+	logger := logging.AddContext(b.logger, log.Ctx{"project": inst.Project(), "instance": inst.Name(), "optimized": optimized, "snapshots": snapshots, "fromSnapshot": fromSnapshot, "incremental": parent != nil})
 	logger.Debug("BackupInstance started")
 	defer logger.Debug("BackupInstance finished")
 
@@ -2007,12 +2111,18 @@ func (b *lxdBackend) BackupInstance(inst instance.Instance, tarWriter *instancew
 		snapNames = make([]string, 0, len(instSnapshots))
 		for _, instSnapshot := range instSnapshots {
 			_, snapName, _ := shared.InstanceGetParentAndSnapshotName(instSnapshot.Name())
+
+			// The snapshot the instance is exported from isn't part of the backup.
+			if snapName == fromSnapshot {
+				continue
+			}
+
 			snapNames = append(snapNames, snapName)
 		}
 	}
 
 	vol := b.newVolume(volType, contentType, volStorageName, rootDiskConf)
-	err = b.driver.BackupVolume(vol, tarWriter, optimized, snapNames, op)
+	err = b.driver.BackupVolume(vol, tarWriter, optimized, snapNames, fromSnapshot, parent, op)
 	if err != nil {
 		return err
 	}
@@ -2874,6 +2984,83 @@ func (b *lxdBackend) CreateCustomVolume(projectName string, volName string, desc
 	return nil
 }
 
+// CreateCustomVolumeFromISO creates a custom ISO volume from the supplied ISO image.
+func (b *lxdBackend) CreateCustomVolumeFromISO(projectName string, volName string, srcData io.ReadSeeker, size int64, op *operations.Operation) error {
+	logger := logging.AddContext(b.logger, log.Ctx{"project": projectName, "volName": volName, "size": size})
+	logger.Debug("CreateCustomVolumeFromISO started")
+	defer logger.Debug("CreateCustomVolumeFromISO finished")
+
+	if b.Status() == api.StoragePoolStatusPending {
+		return fmt.Errorf("Specified pool is not fully created")
+	}
+
+	// Get the volume name on storage.
+	volStorageName := project.StorageVolume(projectName, volName)
+
+	// The volume is sized to fit the ISO image.
+	config := map[string]string{
+		"size": fmt.Sprintf("%d", size),
+	}
+
+	// Validate config.
+	vol := b.newVolume(drivers.VolumeTypeCustom, drivers.ContentTypeISO, volStorageName, config)
+	err := b.driver.ValidateVolume(vol, false)
+	if err != nil {
+		return err
+	}
+
+	if b.driver.HasVolume(vol) {
+		return fmt.Errorf("Cannot create volume, already exists on target storage")
+	}
+
+	// Create database entry for new storage volume.
+	err = VolumeDBCreate(b.state, b, projectName, volName, "", vol.Type(), false, vol.Config(), time.Time{}, vol.ContentType())
+	if err != nil {
+		return err
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	revert.Add(func() {
+		b.state.Cluster.RemoveStoragePoolVolume(projectName, volName, db.StoragePoolVolumeTypeCustom, b.ID())
+	})
+
+	volFiller := drivers.VolumeFiller{
+		Fill: func(vol drivers.Volume, rootBlockPath string, allowUnsafeResize bool) (int64, error) {
+			// Copy the ISO image into the volume's block device or file.
+			to, err := os.OpenFile(rootBlockPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+			if err != nil {
+				return -1, errors.Wrapf(err, "Failed opening %q", rootBlockPath)
+			}
+			defer to.Close()
+
+			_, err = srcData.Seek(0, io.SeekStart)
+			if err != nil {
+				return -1, err
+			}
+
+			_, err = io.Copy(to, srcData)
+			if err != nil {
+				return -1, errors.Wrapf(err, "Failed writing ISO image to %q", rootBlockPath)
+			}
+
+			return size, to.Close()
+		},
+	}
+
+	// Create the custom volume on the storage device and fill it with the ISO image.
+	err = b.driver.CreateVolume(vol, &volFiller, op)
+	if err != nil {
+		return err
+	}
+
+	b.state.Events.SendLifecycle(projectName, lifecycle.StorageVolumeCreated.Event(vol, string(vol.Type()), projectName, op, log.Ctx{"type": vol.Type()}))
+
+	revert.Success()
+	return nil
+}
+
 // CreateCustomVolumeFromCopy creates a custom volume from an existing custom volume.
 // It copies the snapshots from the source volume by default, but can be disabled if requested.
 func (b *lxdBackend) CreateCustomVolumeFromCopy(projectName string, srcProjectName string, volName string, desc string, config map[string]string, srcPoolName, srcVolName string, srcVolOnly bool, op *operations.Operation) error {
@@ -2939,6 +3126,8 @@ func (b *lxdBackend) CreateCustomVolumeFromCopy(projectName string, srcProjectNa
 
 	if contentDBType == db.StoragePoolVolumeContentTypeBlock {
 		contentType = drivers.ContentTypeBlock
+	} else if contentDBType == db.StoragePoolVolumeContentTypeISO {
+		contentType = drivers.ContentTypeISO
 	}
 
 	storagePoolSupported := false
@@ -3045,7 +3234,7 @@ func (b *lxdBackend) CreateCustomVolumeFromCopy(projectName string, srcProjectNa
 	// "no space left on device".
 	var volSize int64
 
-	if contentType == drivers.ContentTypeBlock {
+	if contentType == drivers.ContentTypeBlock || contentType == drivers.ContentTypeISO {
 		// Get the src volume name on storage.
 		srcVolStorageName := project.StorageVolume(srcProjectName, srcVolName)
 		srcVol := srcPool.newVolume(drivers.VolumeTypeCustom, contentType, srcVolStorageName, srcVolRow.Config)
@@ -3412,6 +3601,11 @@ func (b *lxdBackend) UpdateCustomVolume(projectName string, volName string, newD
 			return fmt.Errorf("Custom volume 'block.filesystem' property cannot be changed")
 		}
 
+		// Check that the ISO volume's size isn't being changed.
+		if contentType == drivers.ContentTypeISO && changedConfig["size"] != "" {
+			return fmt.Errorf("Custom ISO volume 'size' property cannot be changed")
+		}
+
 		// Check that security.unmapped and security.shifted aren't set together.
 		if shared.IsTrue(newConfig["security.unmapped"]) && shared.IsTrue(newConfig["security.shifted"]) {
 			return fmt.Errorf("security.unmapped and security.shifted are mutually exclusive")
@@ -3995,6 +4189,214 @@ func (b *lxdBackend) RestoreCustomVolume(projectName, volName string, snapshotNa
 	return nil
 }
 
+// bucketsSupported returns an error if the storage pool doesn't support storage buckets.
+func (b *lxdBackend) bucketsSupported() error {
+	if b.Status() == api.StoragePoolStatusPending {
+		return fmt.Errorf("Specified pool is not fully created")
+	}
+
+	for _, supportedType := range b.driver.Info().VolumeTypes {
+		if supportedType == drivers.VolumeTypeBucket {
+			return nil
+		}
+	}
+
+	return api.StatusErrorf(http.StatusBadRequest, "Storage pool does not support buckets")
+}
+
+// validateBucketConfig checks the supplied storage bucket config.
+func (b *lxdBackend) validateBucketConfig(vol drivers.Volume) error {
+	for k := range vol.Config() {
+		if k != "size" {
+			return fmt.Errorf("Invalid storage bucket configuration key %q", k)
+		}
+	}
+
+	return b.driver.ValidateVolume(vol, false)
+}
+
+// CreateBucket creates a storage bucket and the volume that stores its objects.
+func (b *lxdBackend) CreateBucket(projectName string, bucket api.StorageBucketsPost, op *operations.Operation) error {
+	logger := logging.AddContext(b.logger, log.Ctx{"project": projectName, "bucketName": bucket.Name, "desc": bucket.Description, "config": bucket.Config})
+	logger.Debug("CreateBucket started")
+	defer logger.Debug("CreateBucket finished")
+
+	err := b.bucketsSupported()
+	if err != nil {
+		return err
+	}
+
+	err = ValidBucketName(bucket.Name)
+	if err != nil {
+		return api.StatusErrorf(http.StatusBadRequest, "%v", err)
+	}
+
+	// Validate config.
+	volStorageName := project.StorageVolume(projectName, bucket.Name)
+	vol := b.newVolume(drivers.VolumeTypeBucket, drivers.ContentTypeFS, volStorageName, bucket.Config)
+	err = b.validateBucketConfig(vol)
+	if err != nil {
+		return err
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	// Create database entry for the new storage bucket.
+	var bucketID int64
+	err = b.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		bucketID, err = tx.CreateStoragePoolBucket(b.ID(), projectName, !b.Driver().Info().Remote, bucket)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	revert.Add(func() {
+		b.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+			return tx.DeleteStoragePoolBucket(b.ID(), bucketID)
+		})
+	})
+
+	// Create the bucket volume on the storage device.
+	err = b.driver.CreateVolume(vol, nil, op)
+	if err != nil {
+		return err
+	}
+
+	revert.Success()
+	return nil
+}
+
+// UpdateBucket updates the description and config of a storage bucket.
+func (b *lxdBackend) UpdateBucket(projectName string, bucketName string, bucket api.StorageBucketPut, op *operations.Operation) error {
+	logger := logging.AddContext(b.logger, log.Ctx{"project": projectName, "bucketName": bucketName, "desc": bucket.Description, "config": bucket.Config})
+	logger.Debug("UpdateBucket started")
+	defer logger.Debug("UpdateBucket finished")
+
+	err := b.bucketsSupported()
+	if err != nil {
+		return err
+	}
+
+	// Get current config to compare what has changed.
+	var curBucket *db.StorageBucket
+	err = b.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		curBucket, err = tx.GetStoragePoolBucket(b.ID(), projectName, !b.Driver().Info().Remote, bucketName)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Validate config.
+	volStorageName := project.StorageVolume(projectName, bucketName)
+	newVol := b.newVolume(drivers.VolumeTypeBucket, drivers.ContentTypeFS, volStorageName, bucket.Config)
+	err = b.validateBucketConfig(newVol)
+	if err != nil {
+		return err
+	}
+
+	// Apply config changes if there are any.
+	changedConfig, _ := b.detectChangedConfig(curBucket.Config, bucket.Config)
+	if len(changedConfig) != 0 {
+		curVol := b.newVolume(drivers.VolumeTypeBucket, drivers.ContentTypeFS, volStorageName, curBucket.Config)
+		err = b.driver.UpdateVolume(curVol, changedConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Update the database record.
+	err = b.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.UpdateStoragePoolBucket(b.ID(), curBucket.ID, &bucket)
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteBucket deletes a storage bucket along with its keys and objects.
+func (b *lxdBackend) DeleteBucket(projectName string, bucketName string, op *operations.Operation) error {
+	logger := logging.AddContext(b.logger, log.Ctx{"project": projectName, "bucketName": bucketName})
+	logger.Debug("DeleteBucket started")
+	defer logger.Debug("DeleteBucket finished")
+
+	var bucket *db.StorageBucket
+	err := b.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		bucket, err = tx.GetStoragePoolBucket(b.ID(), projectName, !b.Driver().Info().Remote, bucketName)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// There's no need to pass config as it's not needed when deleting a volume.
+	volStorageName := project.StorageVolume(projectName, bucketName)
+	vol := b.newVolume(drivers.VolumeTypeBucket, drivers.ContentTypeFS, volStorageName, nil)
+
+	// Delete the volume from the storage device.
+	if b.driver.HasVolume(vol) {
+		err = b.driver.DeleteVolume(vol, op)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Finally, remove the bucket record (and its keys) from the database.
+	err = b.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.DeleteStoragePoolBucket(b.ID(), bucket.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// MountBucket mounts a storage bucket's volume and returns the path objects are stored in.
+// The caller is expected to call UnmountBucket when done.
+func (b *lxdBackend) MountBucket(projectName string, bucketName string, op *operations.Operation) (string, error) {
+	logger := logging.AddContext(b.logger, log.Ctx{"project": projectName, "bucketName": bucketName})
+	logger.Debug("MountBucket started")
+	defer logger.Debug("MountBucket finished")
+
+	var bucket *db.StorageBucket
+	err := b.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		bucket, err = tx.GetStoragePoolBucket(b.ID(), projectName, !b.Driver().Info().Remote, bucketName)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	volStorageName := project.StorageVolume(projectName, bucketName)
+	vol := b.newVolume(drivers.VolumeTypeBucket, drivers.ContentTypeFS, volStorageName, bucket.Config)
+
+	err = b.driver.MountVolume(vol, op)
+	if err != nil {
+		return "", err
+	}
+
+	return vol.MountPath(), nil
+}
+
+// UnmountBucket unmounts a storage bucket's volume.
+func (b *lxdBackend) UnmountBucket(projectName string, bucketName string, op *operations.Operation) (bool, error) {
+	logger := logging.AddContext(b.logger, log.Ctx{"project": projectName, "bucketName": bucketName})
+	logger.Debug("UnmountBucket started")
+	defer logger.Debug("UnmountBucket finished")
+
+	volStorageName := project.StorageVolume(projectName, bucketName)
+	vol := b.newVolume(drivers.VolumeTypeBucket, drivers.ContentTypeFS, volStorageName, nil)
+
+	return b.driver.UnmountVolume(vol, false, op)
+}
+
 func (b *lxdBackend) createStorageStructure(path string) error {
 	for _, volType := range b.driver.Info().VolumeTypes {
 		for _, name := range drivers.BaseDirectories[volType] {
@@ -4437,6 +4839,8 @@ func (b *lxdBackend) detectUnknownCustomVolume(vol *drivers.Volume, projectVols
 
 	if contentType == drivers.ContentTypeBlock {
 		apiContentType = db.StoragePoolVolumeContentTypeNameBlock
+	} else if contentType == drivers.ContentTypeISO {
+		apiContentType = db.StoragePoolVolumeContentTypeNameISO
 	} else if contentType == drivers.ContentTypeFS {
 		apiContentType = db.StoragePoolVolumeContentTypeNameFS
 
@@ -4647,7 +5051,7 @@ func (b *lxdBackend) BackupCustomVolume(projectName string, volName string, tarW
 
 	vol := b.newVolume(drivers.VolumeTypeCustom, drivers.ContentType(volume.ContentType), volStorageName, volume.Config)
 
-	err = b.driver.BackupVolume(vol, tarWriter, optimized, snapNames, op)
+	err = b.driver.BackupVolume(vol, tarWriter, optimized, snapNames, "", nil, op)
 	if err != nil {
 		return err
 	}
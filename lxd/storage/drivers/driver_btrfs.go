@@ -38,6 +38,7 @@ func (d *btrfs) load() error {
 		"storage_zfs_volmode":                      nil,
 		"storage_rename_custom_volume_add_project": nil,
 		"storage_lvm_skipactivation":               nil,
+		"storage_create_buckets":                   nil,
 	}
 
 	// Done if previously loaded.
@@ -96,7 +97,7 @@ func (d *btrfs) Info() Info {
 		OptimizedBackupHeader: true,
 		PreservesInodes:       !d.state.OS.RunningInUserNS,
 		Remote:                d.isRemote(),
-		VolumeTypes:           []VolumeType{VolumeTypeCustom, VolumeTypeImage, VolumeTypeContainer, VolumeTypeVM},
+		VolumeTypes:           []VolumeType{VolumeTypeCustom, VolumeTypeImage, VolumeTypeContainer, VolumeTypeVM, VolumeTypeBucket},
 		BlockBacking:          false,
 		RunningCopyFreeze:     false,
 		DirectIO:              true,
@@ -420,7 +421,7 @@ func (d *btrfs) MigrationTypes(contentType ContentType, refresh bool) []migratio
 	if refresh || d.state.OS.RunningInUserNS {
 		var transportType migration.MigrationFSType
 
-		if contentType == ContentTypeBlock {
+		if contentType == ContentTypeBlock || contentType == ContentTypeISO {
 			transportType = migration.MigrationFSType_BLOCK_AND_RSYNC
 		} else {
 			transportType = migration.MigrationFSType_RSYNC
@@ -434,7 +435,7 @@ func (d *btrfs) MigrationTypes(contentType ContentType, refresh bool) []migratio
 		}
 	}
 
-	if contentType == ContentTypeBlock {
+	if contentType == ContentTypeBlock || contentType == ContentTypeISO {
 		return []migration.Type{
 			{
 				FSType:   migration.MigrationFSType_BTRFS,
@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -16,8 +17,11 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"github.com/lxc/lxd/lxd/backup"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/revert"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/ioprogress"
+	log "github.com/lxc/lxd/shared/log15"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -447,6 +451,23 @@ func (d *btrfs) loadOptimizedBackupHeader(r io.ReadSeeker) (*BTRFSMetaDataHeader
 // receiveSubVolume receives a subvolume from an io.Reader into the receivePath, then sets it writable and returns
 // the path to the received subvolume.
 func (d *btrfs) receiveSubVolume(r io.Reader, receivePath string) (string, error) {
+	subVolPath, err := d.receiveReadonlySubVolume(r, receivePath)
+	if err != nil {
+		return "", err
+	}
+
+	// Set writable to allow subvolume to be moved (or deleted if needed) later.
+	err = d.setSubvolumeReadonlyProperty(subVolPath, false)
+	if err != nil {
+		return "", err
+	}
+
+	return subVolPath, nil
+}
+
+// receiveReadonlySubVolume receives a subvolume from an io.Reader into the receivePath and returns the path to the
+// received subvolume. The subvolume is left readonly so that it can be used as the parent of differential streams.
+func (d *btrfs) receiveReadonlySubVolume(r io.Reader, receivePath string) (string, error) {
 	// Check target path is empty before receive.
 	files, err := ioutil.ReadDir(receivePath)
 	if err != nil {
@@ -471,13 +492,206 @@ func (d *btrfs) receiveSubVolume(r io.Reader, receivePath string) (string, error
 		return "", fmt.Errorf("Unpack target path contains %d files, expected 1 file after unpack", len(files))
 	}
 
-	subVolPath := filepath.Join(receivePath, files[0].Name())
+	return filepath.Join(receivePath, files[0].Name()), nil
+}
 
-	// Set writable to allow subvolume to be moved (or deleted if needed) later.
-	err = d.setSubvolumeReadonlyProperty(subVolPath, false)
+// createVolumeFromBackupChain restores an incremental optimized backup. The subvolumes of every backup in the
+// chain are received readonly in turn (so that each differential stream finds its parent), and the final volume
+// and its snapshots are then created as snapshots of the received subvolumes.
+func (d *btrfs) createVolumeFromBackupChain(vol Volume, srcBackup backup.Info, srcData io.ReadSeeker, op *operations.Operation) (revert.Hook, error) {
+	chain, chainData, err := srcBackup.Chain(srcData)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return subVolPath, nil
+	revert := revert.New()
+	defer revert.Fail()
+
+	revertHook := func() {
+		for _, snapName := range srcBackup.Snapshots {
+			snapVol, _ := vol.NewSnapshot(snapName)
+			d.DeleteVolumeSnapshot(snapVol, op)
+		}
+
+		d.DeleteVolume(vol, op)
+	}
+
+	revert.Add(revertHook)
+
+	// Create a temporary directory to receive the backups into.
+	tmpUnpackDir, err := ioutil.TempDir(GetVolumeMountPath(d.name, vol.volType, ""), "backup.")
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create temporary directory under %q", GetVolumeMountPath(d.name, vol.volType, ""))
+	}
+	defer os.RemoveAll(tmpUnpackDir)
+
+	err = os.Chmod(tmpUnpackDir, 0100)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to chmod temporary directory %q", tmpUnpackDir)
+	}
+
+	// Keep track of the received subvolumes so they can be removed once the volume has been restored.
+	var received []string
+	defer func() {
+		for i := len(received) - 1; i >= 0; i-- {
+			d.deleteSubvolume(received[i], false)
+		}
+	}()
+
+	// receive receives a subvolume file from a backup tarball file into its own temporary directory.
+	receive := func(r io.ReadSeeker, unpacker []string, srcFile string) (string, error) {
+		tr, cancelFunc, err := shared.CompressedTarReader(context.Background(), r, unpacker)
+		if err != nil {
+			return "", err
+		}
+		defer cancelFunc()
+
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break // End of archive.
+			}
+			if err != nil {
+				return "", err
+			}
+
+			if hdr.Name == srcFile {
+				receivePath, err := ioutil.TempDir(tmpUnpackDir, "")
+				if err != nil {
+					return "", err
+				}
+
+				subVolPath, err := d.receiveReadonlySubVolume(tr, receivePath)
+				if err != nil {
+					return "", err
+				}
+
+				received = append(received, subVolPath)
+
+				return subVolPath, nil
+			}
+		}
+
+		return "", fmt.Errorf("Could not find %q", srcFile)
+	}
+
+	type receivedSubVolume struct {
+		BTRFSSubVolume
+		path string
+	}
+
+	// Receive the subvolumes of each backup, from oldest to newest. Only the last backup's main volume is needed.
+	subVols := map[string][]receivedSubVolume{}
+	for i, info := range chain {
+		r := chainData[i]
+		r.Seek(0, 0)
+		_, _, unpacker, err := shared.DetectCompressionFile(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if info.OptimizedHeader == nil || !*info.OptimizedHeader {
+			return nil, fmt.Errorf("Incremental backups require all backups of the chain to have an optimized header")
+		}
+
+		optimizedHeader, err := d.loadOptimizedBackupHeader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, subVol := range optimizedHeader.Subvolumes {
+			if subVol.Snapshot == "" && i < len(chain)-1 {
+				continue
+			}
+
+			// Figure out what file we are looking for in the backup file.
+			srcFilePrefix := subVol.Snapshot
+			if subVol.Snapshot == "" {
+				srcFilePrefix = "container"
+				if vol.volType == VolumeTypeVM {
+					srcFilePrefix = "virtual-machine"
+					if vol.contentType == ContentTypeFS {
+						srcFilePrefix = "virtual-machine-config"
+					}
+				} else if vol.volType == VolumeTypeCustom {
+					srcFilePrefix = "volume"
+				}
+			} else {
+				snapDir := "snapshots"
+				if vol.volType == VolumeTypeVM {
+					snapDir = "virtual-machine-snapshots"
+					if vol.contentType == ContentTypeFS {
+						srcFilePrefix = fmt.Sprintf("%s-config", subVol.Snapshot)
+					}
+				} else if vol.volType == VolumeTypeCustom {
+					snapDir = "volume-snapshots"
+				}
+
+				srcFilePrefix = filepath.Join(snapDir, srcFilePrefix)
+			}
+
+			srcFilePath := filepath.Join("backup", fmt.Sprintf("%s.bin", srcFilePrefix))
+			if subVol.Path != string(filepath.Separator) {
+				srcFilePath = filepath.Join("backup", fmt.Sprintf("%s_%s.bin", srcFilePrefix, PathNameEncode(strings.TrimPrefix(subVol.Path, string(filepath.Separator)))))
+			}
+
+			d.Logger().Debug("Receiving optimized volume", log.Ctx{"name": vol.name, "snapshot": subVol.Snapshot, "source": srcFilePath})
+			path, err := receive(r, unpacker, srcFilePath)
+			if err != nil {
+				return nil, err
+			}
+
+			subVols[subVol.Snapshot] = append(subVols[subVol.Snapshot], receivedSubVolume{BTRFSSubVolume: subVol, path: path})
+		}
+	}
+
+	if len(srcBackup.Snapshots) > 0 {
+		// Create new snapshots directory.
+		err := createParentSnapshotDirIfMissing(d.name, vol.volType, vol.name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Create the volume and its snapshots from the received subvolumes.
+	for _, snapName := range append(append([]string{}, srcBackup.Snapshots...), "") {
+		v := vol
+		if snapName != "" {
+			v, _ = vol.NewSnapshot(snapName)
+		}
+
+		if len(subVols[snapName]) < 1 {
+			return nil, fmt.Errorf("No matching subvolume(s) for %q found in backups", v.name)
+		}
+
+		for _, subVol := range subVols[snapName] {
+			subVolTargetPath := filepath.Join(v.MountPath(), subVol.Path)
+
+			// Clear the target for the subvol to use.
+			os.Remove(subVolTargetPath)
+
+			err = d.snapshotSubvolume(subVol.path, subVolTargetPath, false)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Restore readonly property on subvolumes that need it (in reverse order so the root is last).
+		for i := len(subVols[snapName]) - 1; i >= 0; i-- {
+			subVol := subVols[snapName][i]
+			if !subVol.Readonly {
+				continue
+			}
+
+			err = d.setSubvolumeReadonlyProperty(filepath.Join(v.MountPath(), subVol.Path), true)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	revertExternal := revert.Clone() // Clone before calling revert.Success() so we can return the Fail func.
+	revert.Success()
+
+	return revertExternal.Fail, nil
 }
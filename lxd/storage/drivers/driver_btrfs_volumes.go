@@ -46,7 +46,7 @@ func (d *btrfs) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Op
 
 	// Create sparse loopback file if volume is block.
 	rootBlockPath := ""
-	if vol.contentType == ContentTypeBlock {
+	if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 		// We expect the filler to copy the VM image into this path.
 		rootBlockPath, err = d.GetVolumeDiskPath(vol)
 		if err != nil {
@@ -75,7 +75,7 @@ func (d *btrfs) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Op
 
 	// If we are creating a block volume, resize it to the requested size or the default.
 	// We expect the filler function to have converted the qcow2 image to raw into the rootBlockPath.
-	if vol.contentType == ContentTypeBlock {
+	if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 		// Convert to bytes.
 		sizeBytes, err := units.ParseByteSizeString(vol.ConfigSize())
 		if err != nil {
@@ -134,13 +134,23 @@ func (d *btrfs) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Op
 func (d *btrfs) CreateVolumeFromBackup(vol Volume, srcBackup backup.Info, srcData io.ReadSeeker, op *operations.Operation) (VolumePostHook, revert.Hook, error) {
 	// Handle the non-optimized tarballs through the generic unpacker.
 	if !*srcBackup.OptimizedStorage {
-		return genericVFSBackupUnpack(d, vol, srcBackup.Snapshots, srcData, op)
+		return genericVFSBackupUnpack(d, vol, srcBackup, srcData, op)
 	}
 
 	if d.HasVolume(vol) {
 		return nil, nil, fmt.Errorf("Cannot restore volume, already exists on target")
 	}
 
+	// Incremental backups are restored from the chain of backups they are based on.
+	if len(srcBackup.Parents) > 0 {
+		revertHook, err := d.createVolumeFromBackupChain(vol, srcBackup, srcData, op)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return nil, revertHook, nil
+	}
+
 	revert := revert.New()
 	defer revert.Fail()
 
@@ -669,7 +679,7 @@ func (d *btrfs) SetVolumeQuota(vol Volume, size string, allowUnsafeResize bool,
 	}
 
 	// For VM block files, resize the file if needed.
-	if vol.contentType == ContentTypeBlock {
+	if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 		// Do nothing if size isn't specified.
 		if sizeBytes <= 0 {
 			return nil
@@ -1015,13 +1025,14 @@ func (d *btrfs) MigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs *m
 
 // BackupVolume copies a volume (and optionally its snapshots) to a specified target path.
 // This driver does not support optimized backups.
-func (d *btrfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWriter, optimized bool, snapshots []string, op *operations.Operation) error {
+func (d *btrfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWriter, optimized bool, snapshots []string, fromSnapshot string, parent *backup.Parent, op *operations.Operation) error {
 	// Handle the non-optimized tarballs through the generic packer.
 	if !optimized {
 		// Because the generic backup method will not take a consistent backup if files are being modified
 		// as they are copied to the tarball, as BTRFS allows us to take a quick snapshot without impacting
 		// the parent volume we do so here to ensure the backup taken is consistent.
-		if vol.contentType == ContentTypeFS {
+		// This isn't needed when exporting from an existing snapshot.
+		if vol.contentType == ContentTypeFS && fromSnapshot == "" {
 			sourcePath := vol.MountPath()
 			poolPath := GetPoolMountPath(d.name)
 			tmpDir, err := ioutil.TempDir(poolPath, "backup.")
@@ -1055,7 +1066,7 @@ func (d *btrfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWr
 			defer d.deleteSubvolume(mountPath, true)
 		}
 
-		return genericVFSBackupVolume(d, vol, tarWriter, snapshots, op)
+		return genericVFSBackupVolume(d, vol, tarWriter, snapshots, fromSnapshot, parent, op)
 	}
 
 	// Optimized backup.
@@ -1068,6 +1079,32 @@ func (d *btrfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWr
 		}
 	}
 
+	// Incremental backups only include the snapshots taken since the parent backup and are sent relative to
+	// the last snapshot of the parent backup.
+	lastVolPath := "" // Used as parent for differential exports.
+	if parent != nil {
+		if len(parent.Info.Snapshots) < 1 {
+			return fmt.Errorf("Optimized incremental backups require the parent backup to include snapshots")
+		}
+
+		baseSnapshot := parent.Info.Snapshots[len(parent.Info.Snapshots)-1]
+		if !shared.StringInSlice(baseSnapshot, snapshots) {
+			return fmt.Errorf("Snapshot %q of the parent backup no longer exists", baseSnapshot)
+		}
+
+		baseVol, _ := vol.NewSnapshot(baseSnapshot)
+		lastVolPath = baseVol.MountPath()
+
+		newSnapshots := []string{}
+		for _, snapName := range snapshots {
+			if !shared.StringInSlice(snapName, parent.Info.Snapshots) {
+				newSnapshots = append(newSnapshots, snapName)
+			}
+		}
+
+		snapshots = newSnapshots
+	}
+
 	// Generate driver restoration header.
 	optimizedHeader, err := d.restorationHeader(vol, snapshots)
 	if err != nil {
@@ -1204,7 +1241,6 @@ func (d *btrfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWr
 	}
 
 	// Backup snapshots if populated.
-	lastVolPath := "" // Used as parent for differential exports.
 	for _, snapName := range snapshots {
 		snapVol, _ := vol.NewSnapshot(snapName)
 
@@ -1229,8 +1265,16 @@ func (d *btrfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWr
 		lastVolPath = snapVol.MountPath()
 	}
 
-	// Make a temporary copy of the instance.
+	// Make a temporary copy of the instance (or of the snapshot to export it from).
 	sourceVolume := vol.MountPath()
+	if fromSnapshot != "" {
+		snapVol, err := vol.NewSnapshot(fromSnapshot)
+		if err != nil {
+			return err
+		}
+
+		sourceVolume = snapVol.MountPath()
+	}
 	instancesPath := GetVolumeMountPath(d.name, vol.volType, "")
 
 	tmpInstanceMntPoint, err := ioutil.TempDir(instancesPath, "backup.")
@@ -35,6 +35,7 @@ func (d *ceph) load() error {
 		"storage_zfs_volmode":                      nil,
 		"storage_rename_custom_volume_add_project": nil,
 		"storage_lvm_skipactivation":               nil,
+		"storage_create_buckets":                   nil,
 	}
 
 	// Done if previously loaded.
@@ -363,7 +364,7 @@ func (d *ceph) MigrationTypes(contentType ContentType, refresh bool) []migration
 	if refresh {
 		var transportType migration.MigrationFSType
 
-		if contentType == ContentTypeBlock {
+		if contentType == ContentTypeBlock || contentType == ContentTypeISO {
 			transportType = migration.MigrationFSType_BLOCK_AND_RSYNC
 		} else {
 			transportType = migration.MigrationFSType_RSYNC
@@ -377,7 +378,7 @@ func (d *ceph) MigrationTypes(contentType ContentType, refresh bool) []migration
 		}
 	}
 
-	if contentType == ContentTypeBlock {
+	if contentType == ContentTypeBlock || contentType == ContentTypeISO {
 		return []migration.Type{
 			{
 				FSType: migration.MigrationFSType_RBD,
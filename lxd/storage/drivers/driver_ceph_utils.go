@@ -1067,7 +1067,7 @@ func (d *ceph) getRBDVolumeName(vol Volume, snapName string, zombie bool, withPo
 		parentName = fmt.Sprintf("%s_%s", parentName, vol.ConfigBlockFilesystem())
 	}
 
-	if vol.contentType == ContentTypeBlock {
+	if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 		parentName = fmt.Sprintf("%s%s", parentName, cephBlockVolSuffix)
 	}
 
@@ -172,7 +172,7 @@ func (d *ceph) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Ope
 			var err error
 			var devPath string
 
-			if vol.contentType == ContentTypeBlock {
+			if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 				// Get the device path.
 				devPath, err = d.GetVolumeDiskPath(vol)
 				if err != nil {
@@ -230,7 +230,7 @@ func (d *ceph) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Ope
 			return err
 		}
 
-		if vol.contentType == ContentTypeBlock {
+		if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 			// Re-create the FS config volume's readonly snapshot now that the filler function has run
 			// and unpacked into both config and block volumes.
 			fsVol := NewVolume(d, d.name, vol.volType, ContentTypeFS, vol.name, vol.config, vol.poolConfig)
@@ -292,7 +292,7 @@ func (d *ceph) getVolumeSize(volumeName string) (int64, error) {
 
 // CreateVolumeFromBackup re-creates a volume from its exported state.
 func (d *ceph) CreateVolumeFromBackup(vol Volume, srcBackup backup.Info, srcData io.ReadSeeker, op *operations.Operation) (VolumePostHook, revert.Hook, error) {
-	return genericVFSBackupUnpack(d, vol, srcBackup.Snapshots, srcData, op)
+	return genericVFSBackupUnpack(d, vol, srcBackup, srcData, op)
 }
 
 // CreateVolumeFromCopy provides same-pool volume copying functionality.
@@ -966,7 +966,7 @@ func (d *ceph) SetVolumeQuota(vol Volume, size string, allowUnsafeResize bool, o
 
 // GetVolumeDiskPath returns the location of a root disk block device.
 func (d *ceph) GetVolumeDiskPath(vol Volume) (string, error) {
-	if vol.IsVMBlock() || vol.volType == VolumeTypeCustom && vol.contentType == ContentTypeBlock {
+	if vol.IsVMBlock() || vol.IsCustomBlock() {
 		_, devPath, err := d.getRBDMappedDevPath(vol, false)
 		return devPath, err
 	}
@@ -1110,7 +1110,7 @@ func (d *ceph) MountVolume(vol Volume, op *operations.Operation) error {
 
 			d.logger.Debug("Mounted RBD volume", log.Ctx{"dev": volDevPath, "path": mountPath, "options": mountOptions})
 		}
-	} else if vol.contentType == ContentTypeBlock {
+	} else if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 		// For VMs, mount the filesystem volume.
 		if vol.IsVMBlock() {
 			fsVol := vol.NewVMBlockFilesystemVolume()
@@ -1160,7 +1160,7 @@ func (d *ceph) UnmountVolume(vol Volume, keepBlockDev bool, op *operations.Opera
 
 			ourUnmount = true
 		}
-	} else if vol.contentType == ContentTypeBlock {
+	} else if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 		// For VMs, unmount the filesystem volume.
 		if vol.IsVMBlock() {
 			fsVol := vol.NewVMBlockFilesystemVolume()
@@ -1367,8 +1367,8 @@ func (d *ceph) MigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs *mi
 }
 
 // BackupVolume creates an exported version of a volume.
-func (d *ceph) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWriter, optimized bool, snapshots []string, op *operations.Operation) error {
-	return genericVFSBackupVolume(d, vol, tarWriter, snapshots, op)
+func (d *ceph) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWriter, optimized bool, snapshots []string, fromSnapshot string, parent *backup.Parent, op *operations.Operation) error {
+	return genericVFSBackupVolume(d, vol, tarWriter, snapshots, fromSnapshot, parent, op)
 }
 
 // CreateVolumeSnapshot creates a snapshot of a volume.
@@ -1566,7 +1566,7 @@ func (d *ceph) MountVolumeSnapshot(snapVol Volume, op *operations.Operation) (bo
 
 	var err error
 	ourMount := false
-	if snapVol.contentType == ContentTypeBlock {
+	if snapVol.contentType == ContentTypeBlock || snapVol.contentType == ContentTypeISO {
 		// Activate RBD volume if needed.
 		ourMount, _, err = d.getRBDMappedDevPath(snapVol, true)
 		if err != nil {
@@ -1617,7 +1617,7 @@ func (d *ceph) UnmountVolumeSnapshot(snapVol Volume, op *operations.Operation) (
 		}
 	}
 
-	if snapVol.contentType == ContentTypeBlock {
+	if snapVol.contentType == ContentTypeBlock || snapVol.contentType == ContentTypeISO {
 		err := d.rbdUnmapVolume(snapVol, true)
 		if err != nil {
 			return false, err
@@ -147,7 +147,7 @@ func (d *common) MigrationTypes(contentType ContentType, refresh bool) []migrati
 		rsyncFeatures = []string{"xattrs", "delete", "compress", "bidirectional"}
 	}
 
-	if contentType == ContentTypeBlock {
+	if contentType == ContentTypeBlock || contentType == ContentTypeISO {
 		transportType = migration.MigrationFSType_BLOCK_AND_RSYNC
 	} else {
 		transportType = migration.MigrationFSType_RSYNC
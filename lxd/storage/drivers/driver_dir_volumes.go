@@ -37,7 +37,7 @@ func (d *dir) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Oper
 
 	// Create sparse loopback file if volume is block.
 	rootBlockPath := ""
-	if vol.contentType == ContentTypeBlock {
+	if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 		// We expect the filler to copy the VM image into this path.
 		rootBlockPath, err = d.GetVolumeDiskPath(vol)
 		if err != nil {
@@ -63,7 +63,7 @@ func (d *dir) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Oper
 
 	// If we are creating a block volume, resize it to the requested size or the default.
 	// We expect the filler function to have converted the qcow2 image to raw into the rootBlockPath.
-	if vol.contentType == ContentTypeBlock {
+	if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 		// Convert to bytes.
 		sizeBytes, err := units.ParseByteSizeString(vol.ConfigSize())
 		if err != nil {
@@ -93,7 +93,7 @@ func (d *dir) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Oper
 // CreateVolumeFromBackup restores a backup tarball onto the storage device.
 func (d *dir) CreateVolumeFromBackup(vol Volume, srcBackup backup.Info, srcData io.ReadSeeker, op *operations.Operation) (VolumePostHook, revert.Hook, error) {
 	// Run the generic backup unpacker
-	postHook, revertHook, err := genericVFSBackupUnpack(d.withoutGetVolID(), vol, srcBackup.Snapshots, srcData, op)
+	postHook, revertHook, err := genericVFSBackupUnpack(d.withoutGetVolID(), vol, srcBackup, srcData, op)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -265,7 +265,7 @@ func (d *dir) SetVolumeQuota(vol Volume, size string, allowUnsafeResize bool, op
 	}
 
 	// For VM block files, resize the file if needed.
-	if vol.contentType == ContentTypeBlock {
+	if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 		// Do nothing if size isn't specified.
 		if sizeBytes <= 0 {
 			return nil
@@ -372,8 +372,8 @@ func (d *dir) MigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs *mig
 
 // BackupVolume copies a volume (and optionally its snapshots) to a specified target path.
 // This driver does not support optimized backups.
-func (d *dir) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWriter, optimized bool, snapshots []string, op *operations.Operation) error {
-	return genericVFSBackupVolume(d, vol, tarWriter, snapshots, op)
+func (d *dir) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWriter, optimized bool, snapshots []string, fromSnapshot string, parent *backup.Parent, op *operations.Operation) error {
+	return genericVFSBackupVolume(d, vol, tarWriter, snapshots, fromSnapshot, parent, op)
 }
 
 // CreateVolumeSnapshot creates a snapshot of a volume.
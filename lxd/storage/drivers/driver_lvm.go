@@ -40,6 +40,7 @@ func (d *lvm) load() error {
 		"storage_zfs_volmode":                      nil,
 		"storage_rename_custom_volume_add_project": nil,
 		"storage_lvm_skipactivation":               d.patchStorageSkipActivation,
+		"storage_create_buckets":                   nil,
 	}
 
 	// Done if previously loaded.
@@ -457,14 +457,15 @@ func (d *lvm) renameLogicalVolume(volDevPath string, newVolDevPath string) error
 // lvmFullVolumeName returns the logical volume's full name with volume type prefix. It also converts the supplied
 // volName to a name suitable for use as a logical volume using volNameToLVName(). If an empty volType is passed
 // then just the volName is returned. If an invalid volType is passed then an empty string is returned.
-// If a content type of ContentTypeBlock is supplied then the volume name is suffixed with lvmBlockVolSuffix.
+// If a content type of ContentTypeBlock or ContentTypeISO is supplied then the volume name is suffixed with
+// lvmBlockVolSuffix.
 func (d *lvm) lvmFullVolumeName(volType VolumeType, contentType ContentType, volName string) string {
 	if volType == "" {
 		return volName
 	}
 
 	contentTypeSuffix := ""
-	if contentType == ContentTypeBlock {
+	if contentType == ContentTypeBlock || contentType == ContentTypeISO {
 		contentTypeSuffix = lvmBlockVolSuffix
 	}
 
@@ -702,7 +703,7 @@ func (d *lvm) parseLogicalVolumeSnapshot(parent Volume, lvmVolName string) strin
 	fullVolName := d.lvmFullVolumeName(parent.volType, parent.contentType, parent.name)
 
 	// If block volume, remove the block suffix ready for comparison with LV list.
-	if parent.IsVMBlock() || parent.volType == VolumeTypeCustom && parent.contentType == ContentTypeBlock {
+	if parent.IsVMBlock() || parent.IsCustomBlock() {
 		if !strings.HasSuffix(lvmVolName, lvmBlockVolSuffix) {
 			return ""
 		}
@@ -60,7 +60,7 @@ func (d *lvm) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Oper
 			var err error
 			var devPath string
 
-			if vol.contentType == ContentTypeBlock {
+			if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 				// Get the device path.
 				devPath, err = d.GetVolumeDiskPath(vol)
 				if err != nil {
@@ -104,7 +104,7 @@ func (d *lvm) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Oper
 
 // CreateVolumeFromBackup restores a backup tarball onto the storage device.
 func (d *lvm) CreateVolumeFromBackup(vol Volume, srcBackup backup.Info, srcData io.ReadSeeker, op *operations.Operation) (VolumePostHook, revert.Hook, error) {
-	return genericVFSBackupUnpack(d, vol, srcBackup.Snapshots, srcData, op)
+	return genericVFSBackupUnpack(d, vol, srcBackup, srcData, op)
 }
 
 // CreateVolumeFromCopy provides same-pool volume copying functionality.
@@ -335,7 +335,7 @@ func (d *lvm) GetVolumeUsage(vol Volume) (int64, error) {
 		}
 
 		return int64(stat.Blocks-stat.Bfree) * int64(stat.Bsize), nil
-	} else if vol.contentType == ContentTypeBlock && d.usesThinpool() {
+	} else if (vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO) && d.usesThinpool() {
 		// For non-snapshot thin pool block volumes we can calculate an approximate usage using the space
 		// allocated to the volume from the thin pool.
 		volDevPath := d.lvmDevPath(d.config["lvm.vg_name"], vol.volType, vol.contentType, vol.name)
@@ -477,7 +477,7 @@ func (d *lvm) SetVolumeQuota(vol Volume, size string, allowUnsafeResize bool, op
 
 // GetVolumeDiskPath returns the location of a disk volume.
 func (d *lvm) GetVolumeDiskPath(vol Volume) (string, error) {
-	if vol.IsVMBlock() || vol.volType == VolumeTypeCustom && vol.contentType == ContentTypeBlock {
+	if vol.IsVMBlock() || vol.IsCustomBlock() {
 		volDevPath := d.lvmDevPath(d.config["lvm.vg_name"], vol.volType, vol.contentType, vol.name)
 		return volDevPath, nil
 	}
@@ -622,7 +622,7 @@ func (d *lvm) MountVolume(vol Volume, op *operations.Operation) error {
 			}
 			d.logger.Debug("Mounted logical volume", log.Ctx{"dev": volDevPath, "path": mountPath, "options": mountOptions})
 		}
-	} else if vol.contentType == ContentTypeBlock {
+	} else if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 		// For VMs, mount the filesystem volume.
 		if vol.IsVMBlock() {
 			fsVol := vol.NewVMBlockFilesystemVolume()
@@ -675,7 +675,7 @@ func (d *lvm) UnmountVolume(vol Volume, keepBlockDev bool, op *operations.Operat
 
 			ourUnmount = true
 		}
-	} else if vol.contentType == ContentTypeBlock {
+	} else if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 		// For VMs, unmount the filesystem volume.
 		if vol.IsVMBlock() {
 			fsVol := vol.NewVMBlockFilesystemVolume()
@@ -794,8 +794,8 @@ func (d *lvm) MigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs *mig
 
 // BackupVolume copies a volume (and optionally its snapshots) to a specified target path.
 // This driver does not support optimized backups.
-func (d *lvm) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWriter, _ bool, snapshots []string, op *operations.Operation) error {
-	return genericVFSBackupVolume(d, vol, tarWriter, snapshots, op)
+func (d *lvm) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWriter, _ bool, snapshots []string, fromSnapshot string, parent *backup.Parent, op *operations.Operation) error {
+	return genericVFSBackupVolume(d, vol, tarWriter, snapshots, fromSnapshot, parent, op)
 }
 
 // CreateVolumeSnapshot creates a snapshot of a volume.
@@ -983,7 +983,7 @@ func (d *lvm) MountVolumeSnapshot(snapVol Volume, op *operations.Operation) (boo
 	}
 
 	activated := false
-	if snapVol.contentType == ContentTypeBlock {
+	if snapVol.contentType == ContentTypeBlock || snapVol.contentType == ContentTypeISO {
 		volDevPath := d.lvmDevPath(d.config["lvm.vg_name"], snapVol.volType, snapVol.contentType, snapVol.name)
 
 		// Activate volume if needed.
@@ -1046,7 +1046,7 @@ func (d *lvm) UnmountVolumeSnapshot(snapVol Volume, op *operations.Operation) (b
 	}
 
 	deactivated := false
-	if snapVol.contentType == ContentTypeBlock {
+	if snapVol.contentType == ContentTypeBlock || snapVol.contentType == ContentTypeISO {
 		deactivated, err = d.deactivateVolume(volDevPath)
 		if err != nil {
 			return false, err
@@ -48,6 +48,7 @@ func (d *zfs) load() error {
 		"storage_zfs_volmode":                      d.patchStorageZFSVolMode,
 		"storage_rename_custom_volume_add_project": nil,
 		"storage_lvm_skipactivation":               nil,
+		"storage_create_buckets":                   d.patchStorageCreateVM,
 	}
 
 	// Done if previously loaded.
@@ -109,7 +110,7 @@ func (d *zfs) Info() Info {
 		OptimizedBackups:  true,
 		PreservesInodes:   true,
 		Remote:            d.isRemote(),
-		VolumeTypes:       []VolumeType{VolumeTypeCustom, VolumeTypeImage, VolumeTypeContainer, VolumeTypeVM},
+		VolumeTypes:       []VolumeType{VolumeTypeCustom, VolumeTypeImage, VolumeTypeContainer, VolumeTypeVM, VolumeTypeBucket},
 		BlockBacking:      false,
 		RunningCopyFreeze: false,
 		DirectIO:          zfsDirectIO,
@@ -477,7 +478,7 @@ func (d *zfs) MigrationTypes(contentType ContentType, refresh bool) []migration.
 	if refresh {
 		var transportType migration.MigrationFSType
 
-		if contentType == ContentTypeBlock {
+		if contentType == ContentTypeBlock || contentType == ContentTypeISO {
 			transportType = migration.MigrationFSType_BLOCK_AND_RSYNC
 		} else {
 			transportType = migration.MigrationFSType_RSYNC
@@ -497,7 +498,7 @@ func (d *zfs) MigrationTypes(contentType ContentType, refresh bool) []migration.
 		features = append(features, "compress")
 	}
 
-	if contentType == ContentTypeBlock {
+	if contentType == ContentTypeBlock || contentType == ContentTypeISO {
 		return []migration.Type{
 			{
 				FSType:   migration.MigrationFSType_ZFS,
@@ -316,7 +316,7 @@ func (d *zfs) sendDataset(dataset string, parent string, volSrcArgs *migration.V
 func (d *zfs) receiveDataset(vol Volume, conn io.ReadWriteCloser, writeWrapper func(io.WriteCloser) io.WriteCloser) error {
 	// Assemble zfs receive command.
 	cmd := exec.Command("zfs", "receive", "-x", "mountpoint", "-F", "-u", d.dataset(vol, false))
-	if vol.ContentType() == ContentTypeBlock {
+	if vol.ContentType() == ContentTypeBlock || vol.ContentType() == ContentTypeISO {
 		cmd = exec.Command("zfs", "receive", "-F", "-u", d.dataset(vol, false))
 	}
 
@@ -54,7 +54,7 @@ func (d *zfs) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Oper
 
 		// For block volumes check if the cached image volume is larger than the current pool volume.size
 		// setting (if so we won't be able to resize the snapshot to that the smaller size later).
-		if vol.contentType == ContentTypeBlock {
+		if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 			volSize, err := d.getDatasetProperty(d.dataset(vol, true), "volsize")
 			if err != nil {
 				return err
@@ -182,7 +182,7 @@ func (d *zfs) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Oper
 			var err error
 			var devPath string
 
-			if vol.contentType == ContentTypeBlock {
+			if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 				// Get the device path.
 				devPath, err = d.GetVolumeDiskPath(vol)
 				if err != nil {
@@ -228,7 +228,7 @@ func (d *zfs) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Oper
 			return err
 		}
 
-		if vol.contentType == ContentTypeBlock {
+		if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 			// Re-create the FS config volume's readonly snapshot now that the filler function has run and unpacked into both config and block volumes.
 			fsVol := NewVolume(d, d.name, vol.volType, ContentTypeFS, vol.name, vol.config, vol.poolConfig)
 
@@ -254,7 +254,13 @@ func (d *zfs) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Oper
 func (d *zfs) CreateVolumeFromBackup(vol Volume, srcBackup backup.Info, srcData io.ReadSeeker, op *operations.Operation) (VolumePostHook, revert.Hook, error) {
 	// Handle the non-optimized tarballs through the generic unpacker.
 	if !*srcBackup.OptimizedStorage {
-		return genericVFSBackupUnpack(d, vol, srcBackup.Snapshots, srcData, op)
+		return genericVFSBackupUnpack(d, vol, srcBackup, srcData, op)
+	}
+
+	// Get the backups needed to restore this one (only this one unless it is an incremental backup).
+	chain, chainData, err := srcBackup.Chain(srcData)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	if d.HasVolume(vol) {
@@ -300,7 +306,7 @@ func (d *zfs) CreateVolumeFromBackup(vol Volume, srcBackup backup.Info, srcData
 
 			if hdr.Name == srcFile {
 				// Extract the backup.
-				if v.ContentType() == ContentTypeBlock {
+				if v.ContentType() == ContentTypeBlock || v.ContentType() == ContentTypeISO {
 					err = shared.RunCommandWithFds(tr, nil, "zfs", "receive", "-F", target)
 				} else {
 					err = shared.RunCommandWithFds(tr, nil, "zfs", "receive", "-x", "mountpoint", "-F", target)
@@ -329,13 +335,6 @@ func (d *zfs) CreateVolumeFromBackup(vol Volume, srcBackup backup.Info, srcData
 	vols = append(vols, vol)
 
 	for _, v := range vols {
-		// Find the compression algorithm used for backup source data.
-		srcData.Seek(0, 0)
-		_, _, unpacker, err := shared.DetectCompressionFile(srcData)
-		if err != nil {
-			return nil, nil, err
-		}
-
 		if len(srcBackup.Snapshots) > 0 {
 			// Create new snapshots directory.
 			err := createParentSnapshotDirIfMissing(d.name, v.volType, v.name)
@@ -344,22 +343,56 @@ func (d *zfs) CreateVolumeFromBackup(vol Volume, srcBackup backup.Info, srcData
 			}
 		}
 
-		// Restore backups from oldest to newest.
-		for _, snapName := range srcBackup.Snapshots {
-			prefix := "snapshots"
-			fileName := fmt.Sprintf("%s.bin", snapName)
-			if v.volType == VolumeTypeVM {
-				prefix = "virtual-machine-snapshots"
-				if v.contentType == ContentTypeFS {
-					fileName = fmt.Sprintf("%s-config.bin", snapName)
+		// Restore backups from oldest to newest. Each backup of the chain only contains the snapshots which
+		// were taken since the previous one, sent relative to the previous snapshot.
+		var unpacker []string
+		var prevSnapshots []string
+		var receivedSnapshots []string
+		for i, info := range chain {
+			// Find the compression algorithm used for backup source data.
+			srcData = chainData[i]
+			srcData.Seek(0, 0)
+			_, _, unpacker, err = shared.DetectCompressionFile(srcData)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			for _, snapName := range info.Snapshots {
+				if shared.StringInSlice(snapName, prevSnapshots) {
+					continue
 				}
-			} else if v.volType == VolumeTypeCustom {
-				prefix = "volume-snapshots"
+
+				prefix := "snapshots"
+				fileName := fmt.Sprintf("%s.bin", snapName)
+				if v.volType == VolumeTypeVM {
+					prefix = "virtual-machine-snapshots"
+					if v.contentType == ContentTypeFS {
+						fileName = fmt.Sprintf("%s-config.bin", snapName)
+					}
+				} else if v.volType == VolumeTypeCustom {
+					prefix = "volume-snapshots"
+				}
+
+				srcFile := fmt.Sprintf("backup/%s/%s", prefix, fileName)
+				dstSnapshot := fmt.Sprintf("%s@snapshot-%s", d.dataset(v, false), snapName)
+				err = unpackVolume(v, srcData, unpacker, srcFile, dstSnapshot)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				receivedSnapshots = append(receivedSnapshots, snapName)
+			}
+
+			prevSnapshots = info.Snapshots
+		}
+
+		// Remove the snapshots which were only needed to apply the incremental backups.
+		for _, snapName := range receivedSnapshots {
+			if shared.StringInSlice(snapName, srcBackup.Snapshots) {
+				continue
 			}
 
-			srcFile := fmt.Sprintf("backup/%s/%s", prefix, fileName)
-			dstSnapshot := fmt.Sprintf("%s@snapshot-%s", d.dataset(v, false), snapName)
-			err = unpackVolume(v, srcData, unpacker, srcFile, dstSnapshot)
+			_, err := shared.RunCommand("zfs", "destroy", fmt.Sprintf("%s@snapshot-%s", d.dataset(v, false), snapName))
 			if err != nil {
 				return nil, nil, err
 			}
@@ -506,7 +539,7 @@ func (d *zfs) CreateVolumeFromCopy(vol Volume, srcVol Volume, copySnapshots bool
 		// Send/receive the snapshot.
 		var sender *exec.Cmd
 		var receiver *exec.Cmd
-		if vol.ContentType() == ContentTypeBlock {
+		if vol.ContentType() == ContentTypeBlock || vol.ContentType() == ContentTypeISO {
 			receiver = exec.Command("zfs", "receive", d.dataset(vol, false))
 		} else {
 			receiver = exec.Command("zfs", "receive", "-x", "mountpoint", d.dataset(vol, false))
@@ -602,7 +635,7 @@ func (d *zfs) CreateVolumeFromCopy(vol Volume, srcVol Volume, copySnapshots bool
 		// Perform volume clone.
 		args := []string{"clone"}
 
-		if vol.contentType == ContentTypeBlock {
+		if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 			// Use volmode=none so volume is invisible until mounted.
 			args = append(args, "-o", "volmode=none")
 		}
@@ -920,7 +953,7 @@ func (d *zfs) SetVolumeQuota(vol Volume, size string, allowUnsafeResize bool, op
 	}
 
 	// Handle volume datasets.
-	if vol.contentType == ContentTypeBlock {
+	if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 		// Do nothing if size isn't specified.
 		if sizeBytes <= 0 {
 			return nil
@@ -1187,7 +1220,7 @@ func (d *zfs) MountVolume(vol Volume, op *operations.Operation) error {
 
 			d.logger.Debug("Mounted ZFS dataset", log.Ctx{"dev": dataset, "path": mountPath})
 		}
-	} else if vol.contentType == ContentTypeBlock {
+	} else if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 		// For block devices, we make them appear.
 		// Check if already active.
 		current, err := d.getDatasetProperty(dataset, "volmode")
@@ -1252,7 +1285,7 @@ func (d *zfs) UnmountVolume(vol Volume, keepBlockDev bool, op *operations.Operat
 			d.logger.Debug("Unmounted ZFS dataset", log.Ctx{"volName": vol.name, "dev": dataset, "path": mountPath})
 			ourUnmount = true
 		}
-	} else if vol.contentType == ContentTypeBlock {
+	} else if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 		// For VMs, also mount the filesystem dataset.
 		if vol.IsVMBlock() {
 			fsVol := vol.NewVMBlockFilesystemVolume()
@@ -1469,13 +1502,14 @@ func (d *zfs) MigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs *mig
 }
 
 // BackupVolume creates an exported version of a volume.
-func (d *zfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWriter, optimized bool, snapshots []string, op *operations.Operation) error {
+func (d *zfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWriter, optimized bool, snapshots []string, fromSnapshot string, parent *backup.Parent, op *operations.Operation) error {
 	// Handle the non-optimized tarballs through the generic packer.
 	if !optimized {
 		// Because the generic backup method will not take a consistent backup if files are being modified
 		// as they are copied to the tarball, as ZFS allows us to take a quick snapshot without impacting
 		// the parent volume we do so here to ensure the backup taken is consistent.
-		if vol.contentType == ContentTypeFS {
+		// This isn't needed when exporting from an existing snapshot.
+		if vol.contentType == ContentTypeFS && fromSnapshot == "" {
 			poolPath := GetPoolMountPath(d.name)
 			tmpDir, err := ioutil.TempDir(poolPath, "backup.")
 			if err != nil {
@@ -1521,7 +1555,7 @@ func (d *zfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWrit
 			}(srcSnapshot, vol.MountPath())
 		}
 
-		return genericVFSBackupVolume(d, vol, tarWriter, snapshots, op)
+		return genericVFSBackupVolume(d, vol, tarWriter, snapshots, fromSnapshot, parent, op)
 	}
 
 	// Optimized backup.
@@ -1534,10 +1568,23 @@ func (d *zfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWrit
 		}
 	}
 
+	// Incremental backups are sent relative to the last snapshot of the parent backup.
+	baseSnapshot := ""
+	if parent != nil {
+		if len(parent.Info.Snapshots) < 1 {
+			return fmt.Errorf("Optimized incremental backups require the parent backup to include snapshots")
+		}
+
+		baseSnapshot = parent.Info.Snapshots[len(parent.Info.Snapshots)-1]
+		if !shared.StringInSlice(baseSnapshot, snapshots) {
+			return fmt.Errorf("Snapshot %q of the parent backup no longer exists", baseSnapshot)
+		}
+	}
+
 	// Backup VM config volumes first.
 	if vol.IsVMBlock() {
 		fsVol := vol.NewVMBlockFilesystemVolume()
-		err := d.BackupVolume(fsVol, tarWriter, optimized, snapshots, op)
+		err := d.BackupVolume(fsVol, tarWriter, optimized, snapshots, fromSnapshot, parent, op)
 		if err != nil {
 			return err
 		}
@@ -1586,12 +1633,22 @@ func (d *zfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWrit
 
 	// Handle snapshots.
 	finalParent := ""
+	if baseSnapshot != "" {
+		snapshot, _ := vol.NewSnapshot(baseSnapshot)
+		finalParent = d.dataset(snapshot, false)
+	}
+
 	if len(snapshots) > 0 {
 		for i, snapName := range snapshots {
+			// Snapshots included in the parent backup are skipped.
+			if parent != nil && shared.StringInSlice(snapName, parent.Info.Snapshots) {
+				continue
+			}
+
 			snapshot, _ := vol.NewSnapshot(snapName)
 
 			// Figure out parent and current subvolumes.
-			parent := ""
+			parent := finalParent
 			if i > 0 {
 				oldSnapshot, _ := vol.NewSnapshot(snapshots[i-1])
 				parent = d.dataset(oldSnapshot, false)
@@ -1619,13 +1676,23 @@ func (d *zfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWrit
 		}
 	}
 
-	// Create a temporary read-only snapshot.
-	srcSnapshot := fmt.Sprintf("%s@backup-%s", d.dataset(vol, false), uuid.New())
-	_, err := shared.RunCommand("zfs", "snapshot", srcSnapshot)
-	if err != nil {
-		return err
+	// Export from the requested snapshot, or else from a temporary read-only snapshot.
+	var srcSnapshot string
+	if fromSnapshot != "" {
+		snapshot, err := vol.NewSnapshot(fromSnapshot)
+		if err != nil {
+			return err
+		}
+
+		srcSnapshot = d.dataset(snapshot, false)
+	} else {
+		srcSnapshot = fmt.Sprintf("%s@backup-%s", d.dataset(vol, false), uuid.New())
+		_, err := shared.RunCommand("zfs", "snapshot", srcSnapshot)
+		if err != nil {
+			return err
+		}
+		defer shared.RunCommand("zfs", "destroy", srcSnapshot)
 	}
-	defer shared.RunCommand("zfs", "destroy", srcSnapshot)
 
 	// Dump the container to a file.
 	fileName := "container.bin"
@@ -1639,7 +1706,7 @@ func (d *zfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWrit
 		fileName = "volume.bin"
 	}
 
-	err = sendToFile(srcSnapshot, finalParent, fmt.Sprintf("backup/%s", fileName))
+	err := sendToFile(srcSnapshot, finalParent, fmt.Sprintf("backup/%s", fileName))
 	if err != nil {
 		return err
 	}
@@ -1770,7 +1837,7 @@ func (d *zfs) MountVolumeSnapshot(snapVol Volume, op *operations.Operation) (boo
 			d.logger.Debug("Mounted ZFS snapshot dataset", log.Ctx{"dev": snapshotDataset, "path": mountPath})
 			ourMounts++
 		}
-	} else if snapVol.contentType == ContentTypeBlock {
+	} else if snapVol.contentType == ContentTypeBlock || snapVol.contentType == ContentTypeISO {
 		// For block devices, we make them appear by enabling volmode=dev and snapdev=visible on the parent volume.
 		// Ensure snap volume parent is activated to avoid issues activating the snapshot volume device.
 		parent, _, _ := shared.InstanceGetParentAndSnapshotName(snapVol.Name())
@@ -1849,7 +1916,7 @@ func (d *zfs) UnmountVolumeSnapshot(snapVol Volume, op *operations.Operation) (b
 	}
 
 	// For block devices, we make them disappear.
-	if snapVol.contentType == ContentTypeBlock {
+	if snapVol.contentType == ContentTypeBlock || snapVol.contentType == ContentTypeISO {
 		parent, _, _ := shared.InstanceGetParentAndSnapshotName(snapVol.Name())
 		parentVol := NewVolume(d, d.Name(), snapVol.volType, snapVol.contentType, parent, snapVol.config, snapVol.poolConfig)
 		parentDataset := d.dataset(parentVol, false)
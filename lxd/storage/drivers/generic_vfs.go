@@ -1,7 +1,9 @@
 package drivers
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,6 +14,8 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/lxc/lxd/lxd/backup"
+	"github.com/lxc/lxd/lxd/backup/incremental"
 	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/revert"
@@ -29,6 +33,9 @@ import (
 // genericVolumeBlockExtension extension used for generic block volume disk files.
 const genericVolumeBlockExtension = "img"
 
+// genericVolumeBlockDeltaExtension extension used for the changed blocks of block volumes in incremental backups.
+const genericVolumeBlockDeltaExtension = "delta"
+
 // genericVolumeDiskFile used to indicate the file name used for block volume disk files.
 const genericVolumeDiskFile = "root.img"
 
@@ -156,7 +163,7 @@ func genericVFSMigrateVolume(d Driver, s *state.State, vol Volume, conn io.ReadW
 		}
 
 		rsyncArgs = []string{"--exclude", genericVolumeDiskFile}
-	} else if vol.contentType == ContentTypeBlock && volSrcArgs.MigrationType.FSType != migration.MigrationFSType_BLOCK_AND_RSYNC || vol.contentType == ContentTypeFS && volSrcArgs.MigrationType.FSType != migration.MigrationFSType_RSYNC {
+	} else if vol.contentType != ContentTypeFS && volSrcArgs.MigrationType.FSType != migration.MigrationFSType_BLOCK_AND_RSYNC || vol.contentType == ContentTypeFS && volSrcArgs.MigrationType.FSType != migration.MigrationFSType_RSYNC {
 		return ErrNotSupported
 	}
 
@@ -221,14 +228,14 @@ func genericVFSMigrateVolume(d Driver, s *state.State, vol Volume, conn io.ReadW
 
 		// Send snapshot to target (ensure local snapshot volume is mounted if needed).
 		err = snapshot.MountTask(func(mountPath string, op *operations.Operation) error {
-			if vol.contentType != ContentTypeBlock || vol.volType != VolumeTypeCustom {
+			if !vol.IsCustomBlock() {
 				err := sendFSVol(snapshot, conn, mountPath)
 				if err != nil {
 					return err
 				}
 			}
 
-			if vol.IsVMBlock() || vol.contentType == ContentTypeBlock && vol.volType == VolumeTypeCustom {
+			if vol.IsVMBlock() || vol.IsCustomBlock() {
 				err = sendBlockVol(snapshot, conn)
 				if err != nil {
 					return err
@@ -244,14 +251,14 @@ func genericVFSMigrateVolume(d Driver, s *state.State, vol Volume, conn io.ReadW
 
 	// Send volume to target (ensure local volume is mounted if needed).
 	return vol.MountTask(func(mountPath string, op *operations.Operation) error {
-		if vol.contentType != ContentTypeBlock || vol.volType != VolumeTypeCustom {
+		if !vol.IsCustomBlock() {
 			err := sendFSVol(vol, conn, mountPath)
 			if err != nil {
 				return err
 			}
 		}
 
-		if vol.IsVMBlock() || vol.contentType == ContentTypeBlock && vol.volType == VolumeTypeCustom {
+		if vol.IsVMBlock() || vol.IsCustomBlock() {
 			err := sendBlockVol(vol, conn)
 			if err != nil {
 				return err
@@ -266,7 +273,7 @@ func genericVFSMigrateVolume(d Driver, s *state.State, vol Volume, conn io.ReadW
 // initVolume is run against the main volume (not the snapshots) and is often used for quota initialization.
 func genericVFSCreateVolumeFromMigration(d Driver, initVolume func(vol Volume) (func(), error), vol Volume, conn io.ReadWriteCloser, volTargetArgs migration.VolumeTargetArgs, preFiller *VolumeFiller, op *operations.Operation) error {
 	// Check migration transport type matches volume type.
-	if vol.contentType == ContentTypeBlock {
+	if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 		if volTargetArgs.MigrationType.FSType != migration.MigrationFSType_BLOCK_AND_RSYNC {
 			return ErrNotSupported
 		}
@@ -336,7 +343,7 @@ func genericVFSCreateVolumeFromMigration(d Driver, initVolume func(vol Volume) (
 		path := shared.AddSlash(mountPath)
 		pathBlock := ""
 
-		if vol.IsVMBlock() || vol.contentType == ContentTypeBlock && vol.volType == VolumeTypeCustom {
+		if vol.IsVMBlock() || vol.IsCustomBlock() {
 			pathBlock, err = d.GetVolumeDiskPath(vol)
 			if err != nil {
 				return errors.Wrapf(err, "Error getting VM block volume disk path")
@@ -348,7 +355,7 @@ func genericVFSCreateVolumeFromMigration(d Driver, initVolume func(vol Volume) (
 			fullSnapshotName := GetSnapshotVolumeName(vol.name, snapName)
 			snapVol := NewVolume(d, d.Name(), vol.volType, vol.contentType, fullSnapshotName, vol.config, vol.poolConfig)
 
-			if snapVol.contentType != ContentTypeBlock || snapVol.volType != VolumeTypeCustom { // Receive the filesystem snapshot first (as it is sent first).
+			if !snapVol.IsCustomBlock() { // Receive the filesystem snapshot first (as it is sent first).
 				err = recvFSVol(snapVol.name, conn, path)
 				if err != nil {
 					return err
@@ -356,7 +363,7 @@ func genericVFSCreateVolumeFromMigration(d Driver, initVolume func(vol Volume) (
 			}
 
 			// Receive the block snapshot next (if needed).
-			if vol.IsVMBlock() || vol.contentType == ContentTypeBlock && vol.volType == VolumeTypeCustom {
+			if vol.IsVMBlock() || vol.IsCustomBlock() {
 				err = recvBlockVol(snapVol.name, conn, pathBlock)
 				if err != nil {
 					return err
@@ -384,7 +391,7 @@ func genericVFSCreateVolumeFromMigration(d Driver, initVolume func(vol Volume) (
 			}
 		}
 
-		if vol.contentType != ContentTypeBlock || vol.volType != VolumeTypeCustom {
+		if !vol.IsCustomBlock() {
 			// Receive main volume.
 			err = recvFSVol(vol.name, conn, path)
 			if err != nil {
@@ -393,7 +400,7 @@ func genericVFSCreateVolumeFromMigration(d Driver, initVolume func(vol Volume) (
 		}
 
 		// Receive the final main volume sync if needed.
-		if volTargetArgs.Live && (vol.contentType != ContentTypeBlock || vol.volType != VolumeTypeCustom) {
+		if volTargetArgs.Live && !vol.IsCustomBlock() {
 			d.Logger().Debug("Starting main volume final sync", log.Ctx{"volName": vol.name, "path": path})
 			err = recvFSVol(vol.name, conn, path)
 			if err != nil {
@@ -409,7 +416,7 @@ func genericVFSCreateVolumeFromMigration(d Driver, initVolume func(vol Volume) (
 		}
 
 		// Receive the block volume next (if needed).
-		if vol.IsVMBlock() || vol.contentType == ContentTypeBlock && vol.volType == VolumeTypeCustom {
+		if vol.IsVMBlock() || vol.IsCustomBlock() {
 			err = recvBlockVol(vol.name, conn, pathBlock)
 			if err != nil {
 				return err
@@ -437,7 +444,7 @@ func genericVFSHasVolume(vol Volume) bool {
 
 // genericVFSGetVolumeDiskPath is a generic GetVolumeDiskPath implementation for VFS-only drivers.
 func genericVFSGetVolumeDiskPath(vol Volume) (string, error) {
-	if vol.contentType != ContentTypeBlock {
+	if vol.contentType != ContentTypeBlock && vol.contentType != ContentTypeISO {
 		return "", ErrNotSupported
 	}
 
@@ -445,7 +452,11 @@ func genericVFSGetVolumeDiskPath(vol Volume) (string, error) {
 }
 
 // genericVFSBackupVolume is a generic BackupVolume implementation for VFS-only drivers.
-func genericVFSBackupVolume(d Driver, vol Volume, tarWriter *instancewriter.InstanceTarWriter, snapshots []string, op *operations.Operation) error {
+// If parent is specified, only the snapshots which aren't part of the parent backup are included in full and the
+// main volume only contains the files (or blocks) that changed since the parent backup alongside a manifest of
+// its complete content.
+// If fromSnapshot is specified, the main volume is exported from that snapshot rather than from the volume itself.
+func genericVFSBackupVolume(d Driver, vol Volume, tarWriter *instancewriter.InstanceTarWriter, snapshots []string, fromSnapshot string, parent *backup.Parent, op *operations.Operation) error {
 	if len(snapshots) > 0 {
 		// Check requested snapshot match those in storage.
 		err := vol.SnapshotsMatch(snapshots, op)
@@ -455,12 +466,38 @@ func genericVFSBackupVolume(d Driver, vol Volume, tarWriter *instancewriter.Inst
 	}
 
 	// Define a function that can copy a volume into the backup target location.
-	backupVolume := func(v Volume, prefix string) error {
+	// If parentManifest is specified, only the differences from it are copied followed by the volume manifest.
+	backupVolume := func(v Volume, prefix string, parentManifest *incremental.Manifest) error {
 		return v.MountTask(func(mountPath string, op *operations.Operation) error {
 			// Reset hard link cache as we are copying a new volume (instance or snapshot).
 			tarWriter.ResetHardLinkMap()
 
-			if v.contentType == ContentTypeBlock {
+			var manifest *incremental.Manifest
+			if parentManifest != nil {
+				manifest = incremental.NewManifest()
+			}
+
+			// writeFile adds a file to the tarball, skipping unchanged files for incremental backups.
+			writeFile := func(name string, srcPath string, fi os.FileInfo, ignoreGrowth bool) error {
+				if manifest != nil {
+					f, err := incremental.NewFile(srcPath, fi)
+					if err != nil {
+						return err
+					}
+
+					relName := strings.TrimPrefix(name, prefix)
+					manifest.Files[relName] = f
+
+					// Directories are always included so that their metadata is restored.
+					if !fi.IsDir() && !parentManifest.Changed(relName, f) {
+						return nil
+					}
+				}
+
+				return tarWriter.WriteFile(name, srcPath, fi, ignoreGrowth)
+			}
+
+			if v.contentType == ContentTypeBlock || v.contentType == ContentTypeISO {
 				blockPath, err := d.GetVolumeDiskPath(v)
 				if err != nil {
 					errMsg := "Error getting VM block volume disk path"
@@ -499,7 +536,7 @@ func genericVFSBackupVolume(d Driver, vol Volume, tarWriter *instancewriter.Inst
 						}
 
 						name := filepath.Join(prefix, strings.TrimPrefix(srcPath, mountPath))
-						err = tarWriter.WriteFile(name, srcPath, fi, false)
+						err = writeFile(name, srcPath, fi, false)
 						if err != nil {
 							return errors.Wrapf(err, "Error adding %q as %q to tarball", srcPath, name)
 						}
@@ -512,6 +549,9 @@ func genericVFSBackupVolume(d Driver, vol Volume, tarWriter *instancewriter.Inst
 				}
 
 				name := fmt.Sprintf("%s.%s", prefix, genericVolumeBlockExtension)
+				if manifest != nil {
+					name = fmt.Sprintf("%s.%s", prefix, genericVolumeBlockDeltaExtension)
+				}
 
 				logMsg := "Copying virtual machine block volume"
 				if vol.volType == VolumeTypeCustom {
@@ -525,6 +565,7 @@ func genericVFSBackupVolume(d Driver, vol Volume, tarWriter *instancewriter.Inst
 				}
 				defer from.Close()
 
+				var src io.Reader = from
 				fi := instancewriter.FileInfo{
 					FileName:    name,
 					FileSize:    blockDiskSize,
@@ -532,7 +573,44 @@ func genericVFSBackupVolume(d Driver, vol Volume, tarWriter *instancewriter.Inst
 					FileModTime: time.Now(),
 				}
 
-				err = tarWriter.WriteFileFromReader(from, &fi)
+				if manifest != nil {
+					// Write the changed blocks to a temporary file first as the tarball header needs
+					// the size of the delta.
+					backupsPath := shared.VarPath("backups")
+					tmpFile, err := ioutil.TempFile(backupsPath, fmt.Sprintf("%s_delta", backup.WorkingDirPrefix))
+					if err != nil {
+						return errors.Wrapf(err, "Failed to open temporary file for block delta")
+					}
+					defer tmpFile.Close()
+					defer os.Remove(tmpFile.Name())
+
+					// Blocks can only be compared if they were hashed using the same block size.
+					var parentBlocks []string
+					if parentManifest.BlockSize == incremental.DefaultBlockSize {
+						parentBlocks = parentManifest.Blocks
+					}
+
+					manifest.DiskSize = blockDiskSize
+					manifest.BlockSize = incremental.DefaultBlockSize
+					manifest.Blocks, err = incremental.WriteBlockDelta(tmpFile, from, blockDiskSize, manifest.BlockSize, parentBlocks)
+					if err != nil {
+						return errors.Wrapf(err, "Error generating block delta of %q", blockPath)
+					}
+
+					fi.FileSize, err = tmpFile.Seek(0, io.SeekCurrent)
+					if err != nil {
+						return err
+					}
+
+					_, err = tmpFile.Seek(0, 0)
+					if err != nil {
+						return err
+					}
+
+					src = tmpFile
+				}
+
+				err = tarWriter.WriteFileFromReader(src, &fi)
 				if err != nil {
 					return errors.Wrapf(err, "Error copying %q as %q to tarball", blockPath, name)
 				}
@@ -555,7 +633,7 @@ func genericVFSBackupVolume(d Driver, vol Volume, tarWriter *instancewriter.Inst
 					}
 				}
 
-				return filepath.Walk(mountPath, func(srcPath string, fi os.FileInfo, err error) error {
+				err = filepath.Walk(mountPath, func(srcPath string, fi os.FileInfo, err error) error {
 					if err != nil {
 						if os.IsNotExist(err) {
 							logger.Warnf("File vanished during export: %q, skipping", srcPath)
@@ -570,13 +648,37 @@ func genericVFSBackupVolume(d Driver, vol Volume, tarWriter *instancewriter.Inst
 					// Write the file to the tarball with ignoreGrowth enabled so that if the
 					// source file grows during copy we only copy up to the original size.
 					// This means that the file in the tarball may be inconsistent.
-					err = tarWriter.WriteFile(name, srcPath, fi, true)
+					err = writeFile(name, srcPath, fi, true)
 					if err != nil {
 						return errors.Wrapf(err, "Error adding %q as %q to tarball", srcPath, name)
 					}
 
 					return nil
 				})
+				if err != nil {
+					return err
+				}
+			}
+
+			if manifest != nil {
+				// Record the full content of the volume so the next incremental backup and the restore
+				// of this one know which files exist.
+				manifestJSON, err := json.Marshal(manifest)
+				if err != nil {
+					return err
+				}
+
+				fi := instancewriter.FileInfo{
+					FileName:    fmt.Sprintf("%s.%s", prefix, incremental.ManifestExtension),
+					FileSize:    int64(len(manifestJSON)),
+					FileMode:    0644,
+					FileModTime: time.Now(),
+				}
+
+				err = tarWriter.WriteFileFromReader(bytes.NewReader(manifestJSON), &fi)
+				if err != nil {
+					return errors.Wrapf(err, "Error adding %q to tarball", fi.FileName)
+				}
 			}
 
 			return nil
@@ -593,13 +695,18 @@ func genericVFSBackupVolume(d Driver, vol Volume, tarWriter *instancewriter.Inst
 		}
 
 		for _, snapName := range snapshots {
+			// Snapshots don't change, so the ones included in the parent backup can be skipped.
+			if parent != nil && shared.StringInSlice(snapName, parent.Info.Snapshots) {
+				continue
+			}
+
 			prefix := filepath.Join(snapshotsPrefix, snapName)
 			snapVol, err := vol.NewSnapshot(snapName)
 			if err != nil {
 				return err
 			}
 
-			err = backupVolume(snapVol, prefix)
+			err = backupVolume(snapVol, prefix, nil)
 			if err != nil {
 				return err
 			}
@@ -614,7 +721,25 @@ func genericVFSBackupVolume(d Driver, vol Volume, tarWriter *instancewriter.Inst
 		prefix = "backup/volume"
 	}
 
-	err := backupVolume(vol, prefix)
+	var parentManifest *incremental.Manifest
+	if parent != nil {
+		var err error
+		parentManifest, err = incremental.ReadManifest(parent.Data, prefix)
+		if err != nil {
+			return errors.Wrapf(err, "Failed reading content of parent backup %q", parent.Name)
+		}
+	}
+
+	srcVol := vol
+	if fromSnapshot != "" {
+		var err error
+		srcVol, err = vol.NewSnapshot(fromSnapshot)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := backupVolume(srcVol, prefix, parentManifest)
 	if err != nil {
 		return err
 	}
@@ -623,13 +748,16 @@ func genericVFSBackupVolume(d Driver, vol Volume, tarWriter *instancewriter.Inst
 }
 
 // genericVFSBackupUnpack unpacks a non-optimized backup tarball through a storage driver.
+// Incremental backups are restored by unpacking the oldest backup of their chain and then applying the changes
+// recorded in each of the following backups in turn.
 // Returns a post hook function that should be called once the database entries for the restored backup have been
 // created and a revert function that can be used to undo the actions this function performs should something
 // subsequently fail. For VolumeTypeCustom volumes, a nil post hook is returned as it is expected that the DB
 // record be created before the volume is unpacked due to differences in the archive format that allows this.
-func genericVFSBackupUnpack(d Driver, vol Volume, snapshots []string, srcData io.ReadSeeker, op *operations.Operation) (VolumePostHook, revert.Hook, error) {
+func genericVFSBackupUnpack(d Driver, vol Volume, srcBackup backup.Info, srcData io.ReadSeeker, op *operations.Operation) (VolumePostHook, revert.Hook, error) {
 	// Define function to unpack a volume from a backup tarball file.
-	unpackVolume := func(r io.ReadSeeker, tarArgs []string, unpacker []string, srcPrefix string, mountPath string) error {
+	// If applyDelta is true, the volume content isn't cleared and the block volume is updated from the delta.
+	unpackVolume := func(r io.ReadSeeker, tarArgs []string, unpacker []string, srcPrefix string, mountPath string, applyDelta bool) error {
 		volTypeName := "container"
 		if vol.IsVMBlock() {
 			volTypeName = "virtual machine"
@@ -638,9 +766,11 @@ func genericVFSBackupUnpack(d Driver, vol Volume, snapshots []string, srcData io
 		}
 
 		// Clear the volume ready for unpack.
-		err := wipeDirectory(mountPath)
-		if err != nil {
-			return errors.Wrapf(err, "Error clearing volume before unpack")
+		if !applyDelta {
+			err := wipeDirectory(mountPath)
+			if err != nil {
+				return errors.Wrapf(err, "Error clearing volume before unpack")
+			}
 		}
 
 		// Unpack the filesystem parts of the volume (for containers and custom filesystem volumes that is
@@ -672,21 +802,24 @@ func genericVFSBackupUnpack(d Driver, vol Volume, snapshots []string, srcData io
 
 			// Extract filesystem volume.
 			d.Logger().Debug(fmt.Sprintf("Unpacking %s filesystem volume", volTypeName), log.Ctx{"source": srcPrefix, "target": mountPath, "args": args})
-			srcData.Seek(0, 0)
-			err = shared.RunCommandWithFds(r, nil, "tar", args...)
+			r.Seek(0, 0)
+			err := shared.RunCommandWithFds(r, nil, "tar", args...)
 			if err != nil {
 				return errors.Wrapf(err, "Error starting unpack")
 			}
 		}
 
 		// Extract block file to block volume.
-		if vol.contentType == ContentTypeBlock {
+		if vol.contentType == ContentTypeBlock || vol.contentType == ContentTypeISO {
 			targetPath, err := d.GetVolumeDiskPath(vol)
 			if err != nil {
 				return err
 			}
 
 			srcFile := fmt.Sprintf("%s.%s", srcPrefix, genericVolumeBlockExtension)
+			if applyDelta {
+				srcFile = fmt.Sprintf("%s.%s", srcPrefix, genericVolumeBlockDeltaExtension)
+			}
 
 			tr, cancelFunc, err := shared.CompressedTarReader(context.Background(), r, unpacker)
 			if err != nil {
@@ -706,20 +839,34 @@ func genericVFSBackupUnpack(d Driver, vol Volume, snapshots []string, srcData io
 				if hdr.Name == srcFile {
 					var allowUnsafeResize bool
 
+					size := hdr.Size
+					blockSize := int64(0)
+					if applyDelta {
+						size, blockSize, err = incremental.ReadBlockDeltaHeader(tr)
+						if err != nil {
+							return err
+						}
+					}
+
 					// Open block file (use O_CREATE to support drivers that use image files).
-					to, err := os.OpenFile(targetPath, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+					flags := os.O_WRONLY | os.O_TRUNC | os.O_CREATE
+					if applyDelta {
+						flags = os.O_WRONLY | os.O_CREATE
+					}
+
+					to, err := os.OpenFile(targetPath, flags, 0644)
 					if err != nil {
 						return errors.Wrapf(err, "Error opening file for writing %q", targetPath)
 					}
 					defer to.Close()
 
 					// Restore original size of volume from raw block backup file size.
-					d.Logger().Debug("Setting volume size from source", log.Ctx{"source": srcFile, "target": targetPath, "size": hdr.Size})
+					d.Logger().Debug("Setting volume size from source", log.Ctx{"source": srcFile, "target": targetPath, "size": size})
 
 					// Allow potentially destructive resize of volume as we are going to be
 					// overwriting it entirely anyway. This allows shrinking of block volumes.
 					allowUnsafeResize = true
-					err = d.SetVolumeQuota(vol, fmt.Sprintf("%d", hdr.Size), allowUnsafeResize, op)
+					err = d.SetVolumeQuota(vol, fmt.Sprintf("%d", size), allowUnsafeResize, op)
 					if err != nil {
 						return err
 					}
@@ -730,7 +877,12 @@ func genericVFSBackupUnpack(d Driver, vol Volume, snapshots []string, srcData io
 					}
 
 					d.Logger().Debug(logMsg, log.Ctx{"source": srcFile, "target": targetPath})
-					_, err = io.Copy(to, tr)
+					if applyDelta {
+						err = incremental.ApplyBlockDelta(to, tr, size, blockSize)
+					} else {
+						_, err = io.Copy(to, tr)
+					}
+
 					if err != nil {
 						return err
 					}
@@ -749,9 +901,8 @@ func genericVFSBackupUnpack(d Driver, vol Volume, snapshots []string, srcData io
 	revert := revert.New()
 	defer revert.Fail()
 
-	// Find the compression algorithm used for backup source data.
-	srcData.Seek(0, 0)
-	tarArgs, _, unpacker, err := shared.DetectCompressionFile(srcData)
+	// Get the backups needed to restore this one (only this one unless it is an incremental backup).
+	chain, chainData, err := srcBackup.Chain(srcData)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -767,7 +918,7 @@ func genericVFSBackupUnpack(d Driver, vol Volume, snapshots []string, srcData io
 	}
 	revert.Add(func() { d.DeleteVolume(vol, op) })
 
-	if len(snapshots) > 0 {
+	if len(srcBackup.Snapshots) > 0 {
 		// Create new snapshots directory.
 		err := createParentSnapshotDirIfMissing(d.Name(), vol.volType, vol.name)
 		if err != nil {
@@ -782,26 +933,45 @@ func genericVFSBackupUnpack(d Driver, vol Volume, snapshots []string, srcData io
 		backupSnapshotsPrefix = "backup/volume-snapshots"
 	}
 
-	for _, snapName := range snapshots {
-		err = vol.MountTask(func(mountPath string, op *operations.Operation) error {
-			backupSnapshotPrefix := fmt.Sprintf("%s/%s", backupSnapshotsPrefix, snapName)
-			return unpackVolume(srcData, tarArgs, unpacker, backupSnapshotPrefix, mountPath)
-		}, op)
+	// Restore the snapshots from oldest to newest. Each backup of the chain only contains the snapshots
+	// which were taken since the previous one.
+	var prevSnapshots []string
+	for i, info := range chain {
+		// Find the compression algorithm used for backup source data.
+		r := chainData[i]
+		r.Seek(0, 0)
+		tarArgs, _, unpacker, err := shared.DetectCompressionFile(r)
 		if err != nil {
 			return nil, nil, err
 		}
 
-		snapVol, err := vol.NewSnapshot(snapName)
-		if err != nil {
-			return nil, nil, err
-		}
+		for _, snapName := range info.Snapshots {
+			if shared.StringInSlice(snapName, prevSnapshots) || !shared.StringInSlice(snapName, srcBackup.Snapshots) {
+				continue
+			}
 
-		d.Logger().Debug("Creating volume snapshot", log.Ctx{"snapshotName": snapVol.Name()})
-		err = d.CreateVolumeSnapshot(snapVol, op)
-		if err != nil {
-			return nil, nil, err
+			err = vol.MountTask(func(mountPath string, op *operations.Operation) error {
+				backupSnapshotPrefix := fmt.Sprintf("%s/%s", backupSnapshotsPrefix, snapName)
+				return unpackVolume(r, tarArgs, unpacker, backupSnapshotPrefix, mountPath, false)
+			}, op)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			snapVol, err := vol.NewSnapshot(snapName)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			d.Logger().Debug("Creating volume snapshot", log.Ctx{"snapshotName": snapVol.Name()})
+			err = d.CreateVolumeSnapshot(snapVol, op)
+			if err != nil {
+				return nil, nil, err
+			}
+			revert.Add(func() { d.DeleteVolumeSnapshot(snapVol, op) })
 		}
-		revert.Add(func() { d.DeleteVolumeSnapshot(snapVol, op) })
+
+		prevSnapshots = info.Snapshots
 	}
 
 	err = d.MountVolume(vol, op)
@@ -817,10 +987,45 @@ func genericVFSBackupUnpack(d Driver, vol Volume, snapshots []string, srcData io
 		backupPrefix = "backup/volume"
 	}
 
+	// Unpack the main volume from the oldest backup and then apply the changes of each incremental backup.
 	mountPath := vol.MountPath()
-	err = unpackVolume(srcData, tarArgs, unpacker, backupPrefix, mountPath)
-	if err != nil {
-		return nil, nil, err
+	for i := range chain {
+		r := chainData[i]
+		r.Seek(0, 0)
+		tarArgs, _, unpacker, err := shared.DetectCompressionFile(r)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		err = unpackVolume(r, tarArgs, unpacker, backupPrefix, mountPath, i > 0)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if i == 0 || vol.IsCustomBlock() {
+			continue
+		}
+
+		// Remove the files which were deleted since the previous backup.
+		manifest, err := incremental.ReadManifest(r, backupPrefix)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var exclude []string
+		if vol.IsVMBlock() {
+			diskPath, err := d.GetVolumeDiskPath(vol)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			exclude = append(exclude, diskPath)
+		}
+
+		err = manifest.Prune(mountPath, exclude...)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	// Run EnsureMountPath after mounting and unpacking to ensure the mounted directory has the
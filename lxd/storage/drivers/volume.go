@@ -45,6 +45,9 @@ const VolumeTypeContainer = VolumeType("containers")
 // VolumeTypeVM represents a virtual-machine storage volume.
 const VolumeTypeVM = VolumeType("virtual-machines")
 
+// VolumeTypeBucket represents a storage bucket volume.
+const VolumeTypeBucket = VolumeType("buckets")
+
 // ContentType indicates the format of the volume.
 type ContentType string
 
@@ -55,11 +58,16 @@ const ContentTypeFS = ContentType("filesystem")
 // know which filesystem(s) (if any) are in use.
 const ContentTypeBlock = ContentType("block")
 
+// ContentTypeISO indicates the volume will be a block device containing a read-only ISO image.
+// ISO volumes are stored the same way as block volumes.
+const ContentTypeISO = ContentType("iso")
+
 // VolumePostHook function returned from a storage action that should be run later to complete the action.
 type VolumePostHook func(vol Volume) error
 
 // BaseDirectories maps volume types to the expected directories.
 var BaseDirectories = map[VolumeType][]string{
+	VolumeTypeBucket:    {"buckets"},
 	VolumeTypeContainer: {"containers", "containers-snapshots"},
 	VolumeTypeCustom:    {"custom", "custom-snapshots"},
 	VolumeTypeImage:     {"images"},
@@ -352,9 +360,9 @@ func (v Volume) IsVMBlock() bool {
 	return (v.volType == VolumeTypeVM || v.volType == VolumeTypeImage) && v.contentType == ContentTypeBlock
 }
 
-// IsCustomBlock returns true if volume is a custom block volume.
+// IsCustomBlock returns true if volume is a custom block volume (including ISO volumes).
 func (v Volume) IsCustomBlock() bool {
-	return (v.volType == VolumeTypeCustom && v.contentType == ContentTypeBlock)
+	return v.volType == VolumeTypeCustom && (v.contentType == ContentTypeBlock || v.contentType == ContentTypeISO)
 }
 
 // NewVMBlockFilesystemVolume returns a copy of the volume with the content type set to ContentTypeFS and the
@@ -431,7 +439,7 @@ func (v Volume) ConfigSize() string {
 
 	// If volume size isn't defined in either volume or pool config, then for block volumes or block-backed
 	// volumes return the defaultBlockSize.
-	if (size == "" || size == "0") && (v.contentType == ContentTypeBlock || v.driver.Info().BlockBacking) {
+	if (size == "" || size == "0") && (v.contentType == ContentTypeBlock || v.contentType == ContentTypeISO || v.driver.Info().BlockBacking) {
 		return defaultBlockSize
 	}
 
@@ -18,6 +18,9 @@ import (
 // PoolIDTemporary is used to indicate a temporary pool instance that is not in the database.
 const PoolIDTemporary = -1
 
+// bucketVolIDOffset is added to bucket IDs to generate volume IDs for bucket volumes.
+const bucketVolIDOffset = int64(1 << 30)
+
 // volIDFuncMake returns a function that can be supplied to the underlying storage drivers allowing
 // them to lookup the volume ID for a specific volume type and volume name. This function is tied
 // to the Pool ID that it is generated for, meaning the storage drivers do not need to know the ID
@@ -25,6 +28,28 @@ const PoolIDTemporary = -1
 func volIDFuncMake(state *state.State, poolID int64) func(volType drivers.VolumeType, volName string) (int64, error) {
 	// Return a function to retrieve a volume ID for a volume Name for use in driver.
 	return func(volType drivers.VolumeType, volName string) (int64, error) {
+		// Bucket volumes don't have a storage volume record, so use the bucket ID instead, offset so that
+		// it cannot clash with storage volume IDs (used by the dir driver to derive quota project IDs).
+		if volType == drivers.VolumeTypeBucket {
+			projectName, bucketName := project.StorageVolumeParts(volName)
+
+			var bucket *db.StorageBucket
+			err := state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+				var err error
+				bucket, err = tx.GetStoragePoolBucket(poolID, projectName, true, bucketName)
+				return err
+			})
+			if err != nil {
+				if err == db.ErrNoSuchObject {
+					return -1, fmt.Errorf("Failed to get bucket ID for project %q, bucket %q: Bucket doesn't exist", projectName, bucketName)
+				}
+
+				return -1, err
+			}
+
+			return bucket.ID + bucketVolIDOffset, nil
+		}
+
 		volTypeID, err := VolumeTypeToDBType(volType)
 		if err != nil {
 			return -1, err
@@ -0,0 +1,484 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	authAlgorithm      = "AWS4-HMAC-SHA256"
+	authTimeFormat     = "20060102T150405Z"
+	authDateFormat     = "20060102"
+	authTerminator     = "aws4_request"
+	authMaxClockSkew   = 15 * time.Minute
+	authMaxPresignTime = 7 * 24 * time.Hour
+
+	payloadUnsigned          = "UNSIGNED-PAYLOAD"
+	payloadStreaming         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	payloadStreamingUnsigned = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	// maxUnhashedBody is the largest request body accepted when the client doesn't supply the payload hash
+	// in the X-Amz-Content-Sha256 header (requiring the body to be buffered to compute it).
+	maxUnhashedBody = 16 * 1024 * 1024
+)
+
+// emptySHA256 is the hex encoded SHA256 hash of an empty payload.
+var emptySHA256 = hex.EncodeToString(sha256.New().Sum(nil))
+
+// signature represents the AWS signature version 4 details of a request.
+type signature struct {
+	accessKey     string
+	date          string
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+	timestamp     time.Time
+	presigned     bool
+}
+
+// scope returns the credential scope of the signature.
+func (s *signature) scope() string {
+	return strings.Join([]string{s.date, s.region, s.service, authTerminator}, "/")
+}
+
+// parseCredential parses a "<access key>/<date>/<region>/<service>/aws4_request" credential string.
+func (s *signature) parseCredential(credential string) error {
+	fields := strings.Split(credential, "/")
+	if len(fields) != 5 || fields[4] != authTerminator {
+		return errInvalidArgument("Invalid credential %q", credential)
+	}
+
+	s.accessKey = fields[0]
+	s.date = fields[1]
+	s.region = fields[2]
+	s.service = fields[3]
+
+	return nil
+}
+
+// parseSignature extracts the signature details from either the Authorization header or the query string of
+// a presigned request.
+func parseSignature(r *http.Request) (*signature, error) {
+	s := &signature{}
+	query := r.URL.Query()
+
+	if query.Get("X-Amz-Algorithm") != "" {
+		if query.Get("X-Amz-Algorithm") != authAlgorithm {
+			return nil, errInvalidArgument("Unsupported signing algorithm %q", query.Get("X-Amz-Algorithm"))
+		}
+
+		err := s.parseCredential(query.Get("X-Amz-Credential"))
+		if err != nil {
+			return nil, err
+		}
+
+		s.presigned = true
+		s.signedHeaders = strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+		s.signature = query.Get("X-Amz-Signature")
+
+		s.timestamp, err = time.Parse(authTimeFormat, query.Get("X-Amz-Date"))
+		if err != nil {
+			return nil, errInvalidArgument("Invalid X-Amz-Date %q", query.Get("X-Amz-Date"))
+		}
+
+		expires, err := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
+		if err != nil || expires < 0 || time.Duration(expires)*time.Second > authMaxPresignTime {
+			return nil, errInvalidArgument("Invalid X-Amz-Expires %q", query.Get("X-Amz-Expires"))
+		}
+
+		now := time.Now().UTC()
+		if s.timestamp.After(now.Add(authMaxClockSkew)) {
+			return nil, errRequestTimeTooSkewed()
+		}
+
+		if now.After(s.timestamp.Add(time.Duration(expires) * time.Second)) {
+			return nil, errExpiredToken()
+		}
+
+		return s, nil
+	}
+
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return nil, errAccessDenied()
+	}
+
+	if !strings.HasPrefix(auth, authAlgorithm+" ") {
+		return nil, errInvalidArgument("Unsupported authorization type")
+	}
+
+	for _, field := range strings.Split(strings.TrimPrefix(auth, authAlgorithm+" "), ",") {
+		field = strings.TrimSpace(field)
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, errInvalidArgument("Invalid authorization header field %q", field)
+		}
+
+		switch parts[0] {
+		case "Credential":
+			err := s.parseCredential(parts[1])
+			if err != nil {
+				return nil, err
+			}
+
+		case "SignedHeaders":
+			s.signedHeaders = strings.Split(parts[1], ";")
+		case "Signature":
+			s.signature = parts[1]
+		}
+	}
+
+	if s.accessKey == "" || s.signature == "" || len(s.signedHeaders) == 0 {
+		return nil, errInvalidArgument("Incomplete authorization header")
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if amzDate == "" {
+		amzDate = r.Header.Get("Date")
+	}
+
+	var err error
+	s.timestamp, err = time.Parse(authTimeFormat, amzDate)
+	if err != nil {
+		s.timestamp, err = http.ParseTime(amzDate)
+		if err != nil {
+			return nil, errAccessDenied()
+		}
+	}
+
+	skew := time.Since(s.timestamp)
+	if skew < 0 {
+		skew = -skew
+	}
+
+	if skew > authMaxClockSkew {
+		return nil, errRequestTimeTooSkewed()
+	}
+
+	return s, nil
+}
+
+// verify checks the request signature using the given secret key and payload hash.
+func (s *signature) verify(r *http.Request, secretKey string, payloadHash string) error {
+	if s.timestamp.UTC().Format(authDateFormat) != s.date {
+		return errSignatureDoesNotMatch()
+	}
+
+	expected := s.compute(r, secretKey, payloadHash)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(s.signature)) != 1 {
+		return errSignatureDoesNotMatch()
+	}
+
+	return nil
+}
+
+// compute calculates the signature of the request.
+func (s *signature) compute(r *http.Request, secretKey string, payloadHash string) string {
+	stringToSign := strings.Join([]string{
+		authAlgorithm,
+		s.timestamp.UTC().Format(authTimeFormat),
+		s.scope(),
+		hashHex([]byte(canonicalRequest(r, s.signedHeaders, payloadHash, s.presigned))),
+	}, "\n")
+
+	return hex.EncodeToString(hmacSHA256(signingKey(secretKey, s.date, s.region, s.service), []byte(stringToSign)))
+}
+
+// canonicalRequest builds the AWS signature version 4 canonical request.
+func canonicalRequest(r *http.Request, signedHeaders []string, payloadHash string, presigned bool) string {
+	// Canonical query string.
+	query := r.URL.Query()
+	if presigned {
+		query.Del("X-Amz-Signature")
+	}
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var queryParts []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+
+		for _, v := range values {
+			queryParts = append(queryParts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+
+	// Canonical headers.
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		var values []string
+
+		switch name {
+		case "host":
+			values = []string{r.Host}
+		case "content-length":
+			values = append([]string(nil), r.Header.Values("Content-Length")...)
+			if len(values) == 0 && r.ContentLength >= 0 {
+				values = []string{strconv.FormatInt(r.ContentLength, 10)}
+			}
+
+		default:
+			values = append([]string(nil), r.Header.Values(name)...)
+		}
+
+		for i := range values {
+			values[i] = strings.Join(strings.Fields(values[i]), " ")
+		}
+
+		headers.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+	}
+
+	path := r.URL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(path, false),
+		strings.Join(queryParts, "&"),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// signingKey derives the signing key for the given secret key and credential scope.
+func signingKey(secretKey string, date string, region string, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), []byte(date))
+	key = hmacSHA256(key, []byte(region))
+	key = hmacSHA256(key, []byte(service))
+	return hmacSHA256(key, []byte(authTerminator))
+}
+
+func hmacSHA256(key []byte, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// uriEncode encodes a string as specified by the AWS signature version 4 rules.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+
+	for _, c := range []byte(s) {
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// authenticate verifies the signature of the request using the given secret key and replaces the request
+// body with one that verifies the payload as it is read.
+func authenticate(r *http.Request, s *signature, secretKey string) error {
+	if s.presigned {
+		return s.verify(r, secretKey, payloadUnsigned)
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+
+	switch payloadHash {
+	case "":
+		// The payload hash must be computed from the body.
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxUnhashedBody+1))
+		if err != nil {
+			return errInternalError(err)
+		}
+
+		if len(body) > maxUnhashedBody {
+			return errEntityTooLarge()
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		return s.verify(r, secretKey, hashHex(body))
+	case payloadUnsigned:
+		return s.verify(r, secretKey, payloadHash)
+	case payloadStreaming, payloadStreamingUnsigned:
+		err := s.verify(r, secretKey, payloadHash)
+		if err != nil {
+			return err
+		}
+
+		decodedLength, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil {
+			return errInvalidArgument("Invalid X-Amz-Decoded-Content-Length")
+		}
+
+		reader := &chunkedReader{
+			reader: bufio.NewReader(r.Body),
+			closer: r.Body,
+		}
+
+		if payloadHash == payloadStreaming {
+			reader.key = signingKey(secretKey, s.date, s.region, s.service)
+			reader.prefix = strings.Join([]string{authAlgorithm + "-PAYLOAD", s.timestamp.UTC().Format(authTimeFormat), s.scope()}, "\n")
+			reader.prevSignature = s.signature
+		}
+
+		r.Body = reader
+		r.ContentLength = decodedLength
+
+		return nil
+	default:
+		if len(payloadHash) != sha256.Size*2 {
+			return errInvalidArgument("Invalid X-Amz-Content-Sha256 %q", payloadHash)
+		}
+
+		err := s.verify(r, secretKey, payloadHash)
+		if err != nil {
+			return err
+		}
+
+		r.Body = &hashReader{
+			ReadCloser: r.Body,
+			hash:       sha256.New(),
+			expected:   payloadHash,
+		}
+
+		return nil
+	}
+}
+
+// hashReader verifies the SHA256 hash of the payload once fully read.
+type hashReader struct {
+	io.ReadCloser
+
+	hash     hash.Hash
+	expected string
+}
+
+// Read reads from the payload, returning an error at the end of it if the hash doesn't match.
+func (r *hashReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+
+	if err == io.EOF && hex.EncodeToString(r.hash.Sum(nil)) != r.expected {
+		return n, errContentSHA256Mismatch()
+	}
+
+	return n, err
+}
+
+// chunkedReader decodes an aws-chunked payload, verifying the chunk signatures if a signing key is set.
+type chunkedReader struct {
+	reader *bufio.Reader
+	closer io.Closer
+
+	key           []byte
+	prefix        string
+	prevSignature string
+
+	chunk []byte
+	done  bool
+}
+
+// Read reads the decoded payload.
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		err := r.nextChunk()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+
+	return n, nil
+}
+
+// Close closes the underlying body.
+func (r *chunkedReader) Close() error {
+	return r.closer.Close()
+}
+
+// nextChunk reads and verifies the next chunk.
+func (r *chunkedReader) nextChunk() error {
+	line, err := r.reader.ReadString('\n')
+	if err != nil {
+		return errInvalidRequest("Invalid chunked payload")
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	fields := strings.Split(line, ";")
+
+	size, err := strconv.ParseInt(fields[0], 16, 64)
+	if err != nil || size < 0 || size > 64*1024*1024 {
+		return errInvalidRequest("Invalid chunk size")
+	}
+
+	chunkSignature := ""
+	for _, field := range fields[1:] {
+		if strings.HasPrefix(field, "chunk-signature=") {
+			chunkSignature = strings.TrimPrefix(field, "chunk-signature=")
+		}
+	}
+
+	chunk := make([]byte, size)
+	_, err = io.ReadFull(r.reader, chunk)
+	if err != nil {
+		return errInvalidRequest("Invalid chunked payload")
+	}
+
+	if r.key != nil {
+		stringToSign := strings.Join([]string{r.prefix, r.prevSignature, emptySHA256, hashHex(chunk)}, "\n")
+		expected := hex.EncodeToString(hmacSHA256(r.key, []byte(stringToSign)))
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(chunkSignature)) != 1 {
+			return errSignatureDoesNotMatch()
+		}
+
+		r.prevSignature = chunkSignature
+	}
+
+	if size == 0 {
+		// Discard any trailing headers.
+		r.done = true
+		_, _ = io.Copy(ioutil.Discard, r.reader)
+
+		return nil
+	}
+
+	// Consume the CRLF that follows the chunk data.
+	_, err = r.reader.Discard(2)
+	if err != nil {
+		return errInvalidRequest("Invalid chunked payload")
+	}
+
+	r.chunk = chunk
+
+	return nil
+}
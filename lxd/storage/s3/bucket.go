@@ -0,0 +1,754 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Directories inside the bucket volume.
+	objectsDir = "objects"
+	uploadsDir = "uploads"
+	tmpDir     = "tmp"
+
+	// Extended attributes used to store object metadata.
+	xattrETag        = "user.lxd.s3.etag"
+	xattrContentType = "user.lxd.s3.content_type"
+	xattrMetadata    = "user.lxd.s3.metadata"
+
+	// uploadInfoFile is the name of the file describing a multipart upload.
+	uploadInfoFile = "upload.json"
+
+	maxKeyLength  = 1024
+	maxPartNumber = 10000
+)
+
+// emptyMD5 is the hex encoded MD5 hash of an empty object.
+var emptyMD5 = hex.EncodeToString(md5.New().Sum(nil))
+
+// objectInfo represents the properties of a stored object.
+type objectInfo struct {
+	key         string
+	path        string
+	size        int64
+	modTime     time.Time
+	etag        string
+	contentType string
+	metadata    map[string]string
+}
+
+// uploadInfo represents the properties of an in-progress multipart upload.
+type uploadInfo struct {
+	Key         string            `json:"key"`
+	ContentType string            `json:"content_type"`
+	Metadata    map[string]string `json:"metadata"`
+	Initiated   time.Time         `json:"initiated"`
+}
+
+// bucket stores objects as files in a directory tree.
+//
+// Object keys are mapped to paths below the objects directory, so keys that are both an object and a prefix of
+// another object's key (e.g. "a" and "a/b") cannot coexist. Keys ending in "/" are stored as directories.
+type bucket struct {
+	name string
+	path string
+}
+
+// objectPath validates the object key and returns the path of the object.
+func (b *bucket) objectPath(key string) (string, error) {
+	if len(key) > maxKeyLength {
+		return "", errKeyTooLong()
+	}
+
+	trimmed := strings.TrimSuffix(key, "/")
+	if trimmed == "" || strings.ContainsRune(key, 0) {
+		return "", errInvalidArgument("Invalid object key %q", key)
+	}
+
+	for _, part := range strings.Split(trimmed, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", errInvalidArgument("Invalid object key %q", key)
+		}
+	}
+
+	return filepath.Join(b.path, objectsDir, trimmed), nil
+}
+
+// created returns the creation time of the bucket.
+func (b *bucket) created() time.Time {
+	fi, err := os.Stat(b.path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return fi.ModTime()
+}
+
+// getXattr returns the value of an extended attribute, or an empty string if not set.
+func getXattr(path string, name string) string {
+	buf := make([]byte, 4096)
+
+	n, err := unix.Getxattr(path, name, buf)
+	if err != nil || n <= 0 {
+		return ""
+	}
+
+	return string(buf[:n])
+}
+
+// setXattr sets an extended attribute, ignoring filesystems that don't support them.
+func setXattr(path string, name string, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	err := unix.Setxattr(path, name, []byte(value), 0)
+	if err != nil && err != unix.ENOTSUP && err != unix.EOPNOTSUPP {
+		return fmt.Errorf("Failed setting extended attribute %q on %q: %w", name, path, err)
+	}
+
+	return nil
+}
+
+// setObjectXattrs stores the object metadata on the file at the given path.
+func setObjectXattrs(path string, etag string, contentType string, metadata map[string]string) error {
+	err := setXattr(path, xattrETag, etag)
+	if err != nil {
+		return err
+	}
+
+	err = setXattr(path, xattrContentType, contentType)
+	if err != nil {
+		return err
+	}
+
+	if len(metadata) > 0 {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+
+		err = setXattr(path, xattrMetadata, string(data))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newObjectInfo returns the object info for the given key and path.
+func newObjectInfo(key string, path string, fi os.FileInfo) *objectInfo {
+	info := &objectInfo{
+		key:     key,
+		path:    path,
+		modTime: fi.ModTime(),
+	}
+
+	if fi.IsDir() {
+		info.etag = emptyMD5
+		return info
+	}
+
+	info.size = fi.Size()
+	info.etag = getXattr(path, xattrETag)
+	info.contentType = getXattr(path, xattrContentType)
+
+	data := getXattr(path, xattrMetadata)
+	if data != "" {
+		_ = json.Unmarshal([]byte(data), &info.metadata)
+	}
+
+	return info
+}
+
+// statObject returns the object info for the given key.
+func (b *bucket) statObject(key string) (*objectInfo, error) {
+	path, err := b.objectPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) || isNotDir(err) {
+			return nil, errNoSuchKey()
+		}
+
+		return nil, errInternalError(err)
+	}
+
+	// Directories are only objects when requested as a folder (with a trailing slash) and vice versa.
+	if fi.IsDir() != strings.HasSuffix(key, "/") {
+		return nil, errNoSuchKey()
+	}
+
+	return newObjectInfo(key, path, fi), nil
+}
+
+// isNotDir returns whether the error was caused by a path component not being a directory.
+func isNotDir(err error) bool {
+	pathErr, ok := err.(*os.PathError)
+	return ok && pathErr.Err == unix.ENOTDIR
+}
+
+// tempFile creates a temporary file inside the bucket volume so that it can be atomically moved into place.
+func (b *bucket) tempFile() (*os.File, error) {
+	dir := filepath.Join(b.path, tmpDir)
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.TempFile(dir, "object-")
+}
+
+// commit moves a temporary file into place as the object with the given key.
+func (b *bucket) commit(tmpPath string, key string) error {
+	path, err := b.objectPath(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		if isNotDir(err) || os.IsExist(err) {
+			return errInvalidRequest("Object key %q conflicts with an existing object", key)
+		}
+
+		return errInternalError(err)
+	}
+
+	fi, err := os.Stat(path)
+	if err == nil && fi.IsDir() {
+		return errInvalidRequest("Object key %q conflicts with an existing prefix", key)
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return errInternalError(err)
+	}
+
+	return nil
+}
+
+// writeObject stores the object read from r and returns its ETag.
+func (b *bucket) writeObject(key string, r io.Reader, contentType string, metadata map[string]string) (string, error) {
+	path, err := b.objectPath(key)
+	if err != nil {
+		return "", err
+	}
+
+	// Folder objects are stored as directories.
+	if strings.HasSuffix(key, "/") {
+		_, err = io.Copy(ioutil.Discard, r)
+		if err != nil {
+			return "", err
+		}
+
+		err = os.MkdirAll(path, 0700)
+		if err != nil {
+			if isNotDir(err) || os.IsExist(err) {
+				return "", errInvalidRequest("Object key %q conflicts with an existing object", key)
+			}
+
+			return "", errInternalError(err)
+		}
+
+		return emptyMD5, nil
+	}
+
+	f, err := b.tempFile()
+	if err != nil {
+		return "", errInternalError(err)
+	}
+
+	defer func() { _ = os.Remove(f.Name()) }()
+	defer func() { _ = f.Close() }()
+
+	hash := md5.New()
+	_, err = io.Copy(io.MultiWriter(f, hash), r)
+	if err != nil {
+		return "", err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return "", errInternalError(err)
+	}
+
+	etag := hex.EncodeToString(hash.Sum(nil))
+
+	err = setObjectXattrs(f.Name(), etag, contentType, metadata)
+	if err != nil {
+		return "", errInternalError(err)
+	}
+
+	err = b.commit(f.Name(), key)
+	if err != nil {
+		return "", err
+	}
+
+	return etag, nil
+}
+
+// copyObject copies the source object to the destination key, optionally replacing its metadata.
+func (b *bucket) copyObject(srcKey string, dstKey string, replace bool, contentType string, metadata map[string]string) (*objectInfo, error) {
+	src, err := b.statObject(srcKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !replace {
+		contentType = src.contentType
+		metadata = src.metadata
+	}
+
+	if strings.HasSuffix(srcKey, "/") {
+		_, err = b.writeObject(dstKey, strings.NewReader(""), contentType, metadata)
+	} else {
+		var srcFile *os.File
+
+		srcFile, err = os.Open(src.path)
+		if err != nil {
+			return nil, errInternalError(err)
+		}
+
+		defer func() { _ = srcFile.Close() }()
+
+		_, err = b.writeObject(dstKey, srcFile, contentType, metadata)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return b.statObject(dstKey)
+}
+
+// deleteObject removes the object with the given key and any parent directories left empty by it.
+// Deleting a missing object isn't an error.
+func (b *bucket) deleteObject(key string) error {
+	path, err := b.objectPath(key)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) || isNotDir(err) {
+			return nil
+		}
+
+		return errInternalError(err)
+	}
+
+	if fi.IsDir() != strings.HasSuffix(key, "/") {
+		return nil
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		// Folders that still contain objects are left in place.
+		if fi.IsDir() {
+			return nil
+		}
+
+		return errInternalError(err)
+	}
+
+	// Remove empty parent directories.
+	root := filepath.Join(b.path, objectsDir)
+	for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// listObjects returns all objects whose key starts with prefix, sorted by key.
+func (b *bucket) listObjects(prefix string) ([]*objectInfo, error) {
+	root := filepath.Join(b.path, objectsDir)
+
+	// Start walking from the deepest directory covered by the prefix.
+	start := root
+	idx := strings.LastIndex(prefix, "/")
+	if idx > 0 {
+		dir, err := b.objectPath(prefix[:idx])
+		if err != nil {
+			return []*objectInfo{}, nil
+		}
+
+		start = dir
+	}
+
+	objects := []*objectInfo{}
+
+	err := filepath.Walk(start, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) || isNotDir(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		if path == root {
+			return nil
+		}
+
+		key, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if fi.IsDir() {
+			key += "/"
+
+			// Prune directories that can't contain matching keys.
+			if !strings.HasPrefix(key, prefix) && !strings.HasPrefix(prefix, key) {
+				return filepath.SkipDir
+			}
+
+			// Only empty directories are reported as folder objects.
+			entries, err := ioutil.ReadDir(path)
+			if err != nil || len(entries) > 0 {
+				return nil
+			}
+		}
+
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		objects = append(objects, newObjectInfo(key, path, fi))
+
+		return nil
+	})
+	if err != nil {
+		return nil, errInternalError(err)
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].key < objects[j].key })
+
+	return objects, nil
+}
+
+// uploadPath validates the upload ID and returns the path of the upload directory.
+func (b *bucket) uploadPath(uploadID string) (string, error) {
+	if len(uploadID) != 32 {
+		return "", errNoSuchUpload()
+	}
+
+	_, err := hex.DecodeString(uploadID)
+	if err != nil {
+		return "", errNoSuchUpload()
+	}
+
+	return filepath.Join(b.path, uploadsDir, uploadID), nil
+}
+
+// partPath returns the path of the given part of an upload.
+func partPath(uploadPath string, partNumber int) string {
+	return filepath.Join(uploadPath, fmt.Sprintf("part-%05d", partNumber))
+}
+
+// createUpload starts a new multipart upload and returns its ID.
+func (b *bucket) createUpload(key string, contentType string, metadata map[string]string) (string, error) {
+	_, err := b.objectPath(key)
+	if err != nil {
+		return "", err
+	}
+
+	uploadID := randomHex(16)
+
+	path, err := b.uploadPath(uploadID)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(path, 0700)
+	if err != nil {
+		return "", errInternalError(err)
+	}
+
+	data, err := json.Marshal(uploadInfo{Key: key, ContentType: contentType, Metadata: metadata, Initiated: time.Now().UTC()})
+	if err != nil {
+		return "", errInternalError(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(path, uploadInfoFile), data, 0600)
+	if err != nil {
+		return "", errInternalError(err)
+	}
+
+	return uploadID, nil
+}
+
+// getUpload returns the upload info for the given upload ID, checking that it is for the given key.
+func (b *bucket) getUpload(key string, uploadID string) (string, *uploadInfo, error) {
+	path, err := b.uploadPath(uploadID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(path, uploadInfoFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil, errNoSuchUpload()
+		}
+
+		return "", nil, errInternalError(err)
+	}
+
+	info := &uploadInfo{}
+	err = json.Unmarshal(data, info)
+	if err != nil {
+		return "", nil, errInternalError(err)
+	}
+
+	if key != "" && info.Key != key {
+		return "", nil, errNoSuchUpload()
+	}
+
+	return path, info, nil
+}
+
+// listUploads returns the in-progress multipart uploads.
+func (b *bucket) listUploads() ([]multipartUpload, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(b.path, uploadsDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errInternalError(err)
+	}
+
+	uploads := []multipartUpload{}
+	for _, entry := range entries {
+		_, info, err := b.getUpload("", entry.Name())
+		if err != nil {
+			continue
+		}
+
+		uploads = append(uploads, multipartUpload{
+			Key:       info.Key,
+			UploadID:  entry.Name(),
+			Initiated: info.Initiated.Format(timeFormat),
+		})
+	}
+
+	sort.Slice(uploads, func(i, j int) bool { return uploads[i].Key < uploads[j].Key })
+
+	return uploads, nil
+}
+
+// writePart stores a part of a multipart upload and returns its ETag.
+func (b *bucket) writePart(key string, uploadID string, partNumber int, r io.Reader) (string, error) {
+	if partNumber < 1 || partNumber > maxPartNumber {
+		return "", errInvalidArgument("Part number must be an integer between 1 and %d", maxPartNumber)
+	}
+
+	path, _, err := b.getUpload(key, uploadID)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := ioutil.TempFile(path, "tmp-")
+	if err != nil {
+		return "", errInternalError(err)
+	}
+
+	defer func() { _ = os.Remove(f.Name()) }()
+	defer func() { _ = f.Close() }()
+
+	hash := md5.New()
+	_, err = io.Copy(io.MultiWriter(f, hash), r)
+	if err != nil {
+		return "", err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return "", errInternalError(err)
+	}
+
+	err = os.Rename(f.Name(), partPath(path, partNumber))
+	if err != nil {
+		return "", errInternalError(err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// listParts returns the uploaded parts of a multipart upload.
+func (b *bucket) listParts(key string, uploadID string) ([]part, error) {
+	path, _, err := b.getUpload(key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, errInternalError(err)
+	}
+
+	parts := []part{}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "part-") {
+			continue
+		}
+
+		partNumber, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), "part-"))
+		if err != nil {
+			continue
+		}
+
+		etag, err := fileMD5(filepath.Join(path, entry.Name()))
+		if err != nil {
+			return nil, errInternalError(err)
+		}
+
+		parts = append(parts, part{
+			PartNumber:   partNumber,
+			LastModified: entry.ModTime().UTC().Format(timeFormat),
+			ETag:         quoteETag(etag),
+			Size:         entry.Size(),
+		})
+	}
+
+	return parts, nil
+}
+
+// completeUpload assembles the listed parts into the final object and returns its ETag.
+func (b *bucket) completeUpload(key string, uploadID string, req *completeMultipartUpload) (string, error) {
+	path, info, err := b.getUpload(key, uploadID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(req.Parts) == 0 {
+		return "", errMalformedXML()
+	}
+
+	f, err := b.tempFile()
+	if err != nil {
+		return "", errInternalError(err)
+	}
+
+	defer func() { _ = os.Remove(f.Name()) }()
+	defer func() { _ = f.Close() }()
+
+	// The ETag of a multipart object is the MD5 of the concatenated binary MD5s of its parts.
+	etagHash := md5.New()
+	lastPart := 0
+
+	for _, p := range req.Parts {
+		if p.PartNumber <= lastPart {
+			return "", errInvalidPartOrder()
+		}
+
+		lastPart = p.PartNumber
+
+		partFile, err := os.Open(partPath(path, p.PartNumber))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return "", errInvalidPart()
+			}
+
+			return "", errInternalError(err)
+		}
+
+		partHash := md5.New()
+		_, err = io.Copy(io.MultiWriter(f, partHash), partFile)
+		_ = partFile.Close()
+		if err != nil {
+			return "", errInternalError(err)
+		}
+
+		sum := partHash.Sum(nil)
+		if hex.EncodeToString(sum) != strings.Trim(p.ETag, `"`) {
+			return "", errInvalidPart()
+		}
+
+		etagHash.Write(sum)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return "", errInternalError(err)
+	}
+
+	etag := fmt.Sprintf("%s-%d", hex.EncodeToString(etagHash.Sum(nil)), len(req.Parts))
+
+	err = setObjectXattrs(f.Name(), etag, info.ContentType, info.Metadata)
+	if err != nil {
+		return "", errInternalError(err)
+	}
+
+	err = b.commit(f.Name(), key)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.RemoveAll(path)
+	if err != nil {
+		return "", errInternalError(err)
+	}
+
+	return etag, nil
+}
+
+// abortUpload removes a multipart upload and its parts.
+func (b *bucket) abortUpload(key string, uploadID string) error {
+	path, _, err := b.getUpload(key, uploadID)
+	if err != nil {
+		return err
+	}
+
+	err = os.RemoveAll(path)
+	if err != nil {
+		return errInternalError(err)
+	}
+
+	return nil
+}
+
+// fileMD5 returns the hex encoded MD5 hash of a file.
+func fileMD5(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	hash := md5.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// quoteETag returns the ETag in its quoted form, or an empty string if not set.
+func quoteETag(etag string) string {
+	if etag == "" {
+		return ""
+	}
+
+	return `"` + etag + `"`
+}
@@ -0,0 +1,111 @@
+package s3
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+)
+
+// Error represents an S3 error response.
+type Error struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource,omitempty"`
+
+	status int
+}
+
+// Error returns the error message.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// newError returns a new S3 error with the given HTTP status, code and message.
+func newError(status int, code string, format string, args ...interface{}) *Error {
+	return &Error{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+		status:  status,
+	}
+}
+
+func errAccessDenied() *Error {
+	return newError(http.StatusForbidden, "AccessDenied", "Access Denied")
+}
+
+func errInvalidAccessKeyID() *Error {
+	return newError(http.StatusForbidden, "InvalidAccessKeyId", "The access key ID you provided does not exist in our records")
+}
+
+func errSignatureDoesNotMatch() *Error {
+	return newError(http.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided")
+}
+
+func errRequestTimeTooSkewed() *Error {
+	return newError(http.StatusForbidden, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large")
+}
+
+func errExpiredToken() *Error {
+	return newError(http.StatusForbidden, "AccessDenied", "Request has expired")
+}
+
+func errContentSHA256Mismatch() *Error {
+	return newError(http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed")
+}
+
+func errInvalidArgument(format string, args ...interface{}) *Error {
+	return newError(http.StatusBadRequest, "InvalidArgument", format, args...)
+}
+
+func errInvalidRequest(format string, args ...interface{}) *Error {
+	return newError(http.StatusBadRequest, "InvalidRequest", format, args...)
+}
+
+func errMalformedXML() *Error {
+	return newError(http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+}
+
+func errEntityTooLarge() *Error {
+	return newError(http.StatusBadRequest, "EntityTooLarge", "Your proposed upload exceeds the maximum allowed object size")
+}
+
+func errKeyTooLong() *Error {
+	return newError(http.StatusBadRequest, "KeyTooLongError", "Your key is too long")
+}
+
+func errInvalidPart() *Error {
+	return newError(http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found")
+}
+
+func errInvalidPartOrder() *Error {
+	return newError(http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order")
+}
+
+func errNoSuchBucket() *Error {
+	return newError(http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+}
+
+func errNoSuchKey() *Error {
+	return newError(http.StatusNotFound, "NoSuchKey", "The specified key does not exist")
+}
+
+func errNoSuchUpload() *Error {
+	return newError(http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist")
+}
+
+func errBucketAlreadyOwnedByYou() *Error {
+	return newError(http.StatusConflict, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it")
+}
+
+func errMethodNotAllowed() *Error {
+	return newError(http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource")
+}
+
+func errNotImplemented() *Error {
+	return newError(http.StatusNotImplemented, "NotImplemented", "A header or query you provided implies functionality that is not implemented")
+}
+
+func errInternalError(err error) *Error {
+	return newError(http.StatusInternalServerError, "InternalError", "%v", err)
+}
@@ -0,0 +1,631 @@
+package s3
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Access represents the access to a bucket granted by a bucket key.
+type Access struct {
+	// SecretKey is the secret key matching the access key.
+	SecretKey string
+
+	// ReadOnly indicates whether the key is limited to read operations.
+	ReadOnly bool
+
+	// Bucket is the S3 name of the bucket the key grants access to.
+	Bucket string
+
+	// Mount makes the bucket's storage available, returning the path to use and a function to call once
+	// the request is complete.
+	Mount func() (string, func(), error)
+}
+
+// AccessFunc returns the access granted by the given access key, or nil if the key isn't known.
+type AccessFunc func(accessKey string) (*Access, error)
+
+// server implements an S3 compatible API on top of directory trees.
+type server struct {
+	access AccessFunc
+}
+
+// NewServer returns an http.Handler serving the S3 API for the buckets returned by the access function.
+// Only path-style requests authenticated using AWS signature version 4 are supported.
+func NewServer(access AccessFunc) http.Handler {
+	return &server{access: access}
+}
+
+// ServeHTTP authenticates and handles an S3 request.
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "LXD")
+	w.Header().Set("X-Amz-Request-Id", randomHex(8))
+
+	bucketName, key := splitPath(r.URL.Path)
+
+	err := s.handle(w, r, bucketName, key)
+	if err != nil {
+		s3Err, ok := err.(*Error)
+		if !ok {
+			logger.Warn("Failed handling S3 request", log.Ctx{"method": r.Method, "path": r.URL.Path, "err": err})
+			s3Err = errInternalError(err)
+		}
+
+		s3Err.Resource = r.URL.Path
+		writeError(w, r, s3Err)
+	}
+}
+
+// handle authenticates the request and dispatches it to the relevant handler.
+func (s *server) handle(w http.ResponseWriter, r *http.Request, bucketName string, key string) error {
+	sig, err := parseSignature(r)
+	if err != nil {
+		return err
+	}
+
+	access, err := s.access(sig.accessKey)
+	if err != nil {
+		return err
+	}
+
+	if access == nil {
+		return errInvalidAccessKeyID()
+	}
+
+	err = authenticate(r, sig, access.SecretKey)
+	if err != nil {
+		return err
+	}
+
+	if bucketName != "" && bucketName != access.Bucket {
+		return errAccessDenied()
+	}
+
+	if access.ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return errAccessDenied()
+	}
+
+	path, release, err := access.Mount()
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	b := &bucket{name: access.Bucket, path: path}
+	query := r.URL.Query()
+
+	// Service level requests.
+	if bucketName == "" {
+		if r.Method != http.MethodGet {
+			return errMethodNotAllowed()
+		}
+
+		return s.listBuckets(w, r, b)
+	}
+
+	// Bucket level requests.
+	if key == "" {
+		switch r.Method {
+		case http.MethodGet:
+			if hasQuery(query, "location") {
+				return writeXML(w, http.StatusOK, &locationConstraint{Xmlns: xmlNamespace})
+			} else if hasQuery(query, "uploads") {
+				return s.listMultipartUploads(w, r, b)
+			} else if hasQuery(query, "versioning") || hasQuery(query, "acl") || hasQuery(query, "policy") || hasQuery(query, "lifecycle") {
+				return errNotImplemented()
+			}
+
+			return s.listObjects(w, r, b)
+		case http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+			return nil
+		case http.MethodPut:
+			return errBucketAlreadyOwnedByYou()
+		case http.MethodPost:
+			if hasQuery(query, "delete") {
+				return s.deleteObjects(w, r, b)
+			}
+		}
+
+		return errMethodNotAllowed()
+	}
+
+	// Object level requests.
+	switch r.Method {
+	case http.MethodGet:
+		if query.Get("uploadId") != "" {
+			return s.listParts(w, r, b, key)
+		}
+
+		return s.getObject(w, r, b, key)
+	case http.MethodHead:
+		return s.getObject(w, r, b, key)
+	case http.MethodPut:
+		if query.Get("uploadId") != "" {
+			return s.uploadPart(w, r, b, key)
+		} else if r.Header.Get("X-Amz-Copy-Source") != "" {
+			return s.copyObject(w, r, b, key)
+		}
+
+		return s.putObject(w, r, b, key)
+	case http.MethodPost:
+		if hasQuery(query, "uploads") {
+			return s.createMultipartUpload(w, r, b, key)
+		} else if query.Get("uploadId") != "" {
+			return s.completeMultipartUpload(w, r, b, key)
+		}
+	case http.MethodDelete:
+		if query.Get("uploadId") != "" {
+			err = b.abortUpload(key, query.Get("uploadId"))
+			if err != nil {
+				return err
+			}
+		} else {
+			err = b.deleteObject(key)
+			if err != nil {
+				return err
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	return errMethodNotAllowed()
+}
+
+func (s *server) listBuckets(w http.ResponseWriter, r *http.Request, b *bucket) error {
+	resp := &listAllMyBucketsResult{
+		Xmlns: xmlNamespace,
+		Owner: owner{ID: "lxd", DisplayName: "lxd"},
+	}
+
+	resp.Buckets = append(resp.Buckets, struct {
+		Name         string `xml:"Name"`
+		CreationDate string `xml:"CreationDate"`
+	}{Name: b.name, CreationDate: b.created().UTC().Format(timeFormat)})
+
+	return writeXML(w, http.StatusOK, resp)
+}
+
+func (s *server) listObjects(w http.ResponseWriter, r *http.Request, b *bucket) error {
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	encodeKeys := query.Get("encoding-type") == "url"
+	isV2 := query.Get("list-type") == "2"
+
+	maxKeys := 1000
+	if query.Get("max-keys") != "" {
+		var err error
+		maxKeys, err = strconv.Atoi(query.Get("max-keys"))
+		if err != nil || maxKeys < 0 {
+			return errInvalidArgument("Invalid max-keys %q", query.Get("max-keys"))
+		}
+
+		if maxKeys > 1000 {
+			maxKeys = 1000
+		}
+	}
+
+	resp := &listBucketResult{
+		Xmlns:     xmlNamespace,
+		Name:      b.name,
+		MaxKeys:   maxKeys,
+		Delimiter: encodeKey(delimiter, encodeKeys),
+		Prefix:    encodeKey(prefix, encodeKeys),
+	}
+
+	if encodeKeys {
+		resp.EncodingType = "url"
+	}
+
+	// Determine the key to start listing after.
+	var after string
+	if isV2 {
+		resp.StartAfter = encodeKey(query.Get("start-after"), encodeKeys)
+		after = query.Get("start-after")
+
+		token := query.Get("continuation-token")
+		if token != "" {
+			resp.ContinuationToken = token
+
+			decoded, err := base64.StdEncoding.DecodeString(token)
+			if err != nil {
+				return errInvalidArgument("Invalid continuation token")
+			}
+
+			after = string(decoded)
+		}
+	} else {
+		marker := encodeKey(query.Get("marker"), encodeKeys)
+		resp.Marker = &marker
+		after = query.Get("marker")
+	}
+
+	objects, err := b.listObjects(prefix)
+	if err != nil {
+		return err
+	}
+
+	var lastKey string
+	seenPrefixes := map[string]bool{}
+	count := 0
+
+	for _, obj := range objects {
+		if obj.key <= after {
+			continue
+		}
+
+		// Group keys containing the delimiter after the prefix.
+		groupPrefix := ""
+		if delimiter != "" {
+			idx := strings.Index(obj.key[len(prefix):], delimiter)
+			if idx >= 0 {
+				groupPrefix = obj.key[:len(prefix)+idx+len(delimiter)]
+			}
+		}
+
+		if groupPrefix != "" && seenPrefixes[groupPrefix] {
+			continue
+		}
+
+		// Skip any keys covered by a common prefix returned in a previous page.
+		if groupPrefix != "" && groupPrefix <= after {
+			continue
+		}
+
+		if count >= maxKeys {
+			resp.IsTruncated = true
+			break
+		}
+
+		count++
+
+		if groupPrefix != "" {
+			seenPrefixes[groupPrefix] = true
+			resp.CommonPrefixes = append(resp.CommonPrefixes, commonPrefix{Prefix: encodeKey(groupPrefix, encodeKeys)})
+			lastKey = groupPrefix
+
+			continue
+		}
+
+		resp.Contents = append(resp.Contents, object{
+			Key:          encodeKey(obj.key, encodeKeys),
+			LastModified: obj.modTime.UTC().Format(timeFormat),
+			ETag:         quoteETag(obj.etag),
+			Size:         obj.size,
+			StorageClass: "STANDARD",
+		})
+
+		lastKey = obj.key
+	}
+
+	if resp.IsTruncated {
+		if isV2 {
+			resp.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(lastKey))
+		} else if delimiter != "" {
+			resp.NextMarker = encodeKey(lastKey, encodeKeys)
+		}
+	}
+
+	if isV2 {
+		resp.KeyCount = &count
+	}
+
+	return writeXML(w, http.StatusOK, resp)
+}
+
+func (s *server) getObject(w http.ResponseWriter, r *http.Request, b *bucket, key string) error {
+	info, err := b.statObject(key)
+	if err != nil {
+		return err
+	}
+
+	contentType := info.contentType
+	if contentType == "" {
+		contentType = "binary/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	if info.etag != "" {
+		w.Header().Set("ETag", quoteETag(info.etag))
+	}
+
+	for k, v := range info.metadata {
+		w.Header().Set("X-Amz-Meta-"+k, v)
+	}
+
+	if strings.HasSuffix(key, "/") {
+		w.Header().Set("Last-Modified", lastModified(info.modTime))
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	f, err := os.Open(info.path)
+	if err != nil {
+		return errInternalError(err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	http.ServeContent(w, r, "", info.modTime, f)
+
+	return nil
+}
+
+func (s *server) putObject(w http.ResponseWriter, r *http.Request, b *bucket, key string) error {
+	etag, err := b.writeObject(key, r.Body, r.Header.Get("Content-Type"), requestMetadata(r))
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", quoteETag(etag))
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (s *server) copyObject(w http.ResponseWriter, r *http.Request, b *bucket, key string) error {
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		return errInvalidArgument("Invalid copy source")
+	}
+
+	// Drop any version ID and split into bucket and key.
+	source = strings.SplitN(source, "?", 2)[0]
+	srcBucket, srcKey := splitPath("/" + strings.TrimPrefix(source, "/"))
+	if srcBucket != b.name {
+		return errAccessDenied()
+	}
+
+	if srcKey == "" {
+		return errInvalidArgument("Invalid copy source")
+	}
+
+	replace := r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE"
+	if srcKey == key && !replace {
+		return errInvalidRequest("This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata")
+	}
+
+	info, err := b.copyObject(srcKey, key, replace, r.Header.Get("Content-Type"), requestMetadata(r))
+	if err != nil {
+		return err
+	}
+
+	return writeXML(w, http.StatusOK, &copyObjectResult{
+		Xmlns:        xmlNamespace,
+		LastModified: info.modTime.UTC().Format(timeFormat),
+		ETag:         quoteETag(info.etag),
+	})
+}
+
+func (s *server) deleteObjects(w http.ResponseWriter, r *http.Request, b *bucket) error {
+	req := &deleteRequest{}
+
+	err := decodeXML(r.Body, req)
+	if err != nil {
+		return err
+	}
+
+	resp := &deleteResult{Xmlns: xmlNamespace}
+
+	for _, obj := range req.Objects {
+		err := b.deleteObject(obj.Key)
+		if err != nil {
+			s3Err, ok := err.(*Error)
+			if !ok {
+				s3Err = errInternalError(err)
+			}
+
+			resp.Errors = append(resp.Errors, deleteError{Key: obj.Key, Code: s3Err.Code, Message: s3Err.Message})
+			continue
+		}
+
+		if !req.Quiet {
+			resp.Deleted = append(resp.Deleted, deletedObject{Key: obj.Key})
+		}
+	}
+
+	return writeXML(w, http.StatusOK, resp)
+}
+
+func (s *server) createMultipartUpload(w http.ResponseWriter, r *http.Request, b *bucket, key string) error {
+	uploadID, err := b.createUpload(key, r.Header.Get("Content-Type"), requestMetadata(r))
+	if err != nil {
+		return err
+	}
+
+	return writeXML(w, http.StatusOK, &initiateMultipartUploadResult{
+		Xmlns:    xmlNamespace,
+		Bucket:   b.name,
+		Key:      key,
+		UploadID: uploadID,
+	})
+}
+
+func (s *server) uploadPart(w http.ResponseWriter, r *http.Request, b *bucket, key string) error {
+	query := r.URL.Query()
+
+	partNumber, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil {
+		return errInvalidArgument("Invalid part number %q", query.Get("partNumber"))
+	}
+
+	etag, err := b.writePart(key, query.Get("uploadId"), partNumber, r.Body)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", quoteETag(etag))
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (s *server) completeMultipartUpload(w http.ResponseWriter, r *http.Request, b *bucket, key string) error {
+	req := &completeMultipartUpload{}
+
+	err := decodeXML(r.Body, req)
+	if err != nil {
+		return err
+	}
+
+	etag, err := b.completeUpload(key, r.URL.Query().Get("uploadId"), req)
+	if err != nil {
+		return err
+	}
+
+	return writeXML(w, http.StatusOK, &completeMultipartUploadResult{
+		Xmlns:    xmlNamespace,
+		Location: fmt.Sprintf("/%s/%s", b.name, key),
+		Bucket:   b.name,
+		Key:      key,
+		ETag:     quoteETag(etag),
+	})
+}
+
+func (s *server) listMultipartUploads(w http.ResponseWriter, r *http.Request, b *bucket) error {
+	uploads, err := b.listUploads()
+	if err != nil {
+		return err
+	}
+
+	return writeXML(w, http.StatusOK, &listMultipartUploadsResult{
+		Xmlns:   xmlNamespace,
+		Bucket:  b.name,
+		Uploads: uploads,
+	})
+}
+
+func (s *server) listParts(w http.ResponseWriter, r *http.Request, b *bucket, key string) error {
+	uploadID := r.URL.Query().Get("uploadId")
+
+	parts, err := b.listParts(key, uploadID)
+	if err != nil {
+		return err
+	}
+
+	return writeXML(w, http.StatusOK, &listPartsResult{
+		Xmlns:    xmlNamespace,
+		Bucket:   b.name,
+		Key:      key,
+		UploadID: uploadID,
+		Parts:    parts,
+	})
+}
+
+// splitPath splits a path-style request path into bucket name and object key.
+func splitPath(path string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+
+	return parts[0], parts[1]
+}
+
+// hasQuery returns whether the query string contains the given parameter, even without a value.
+func hasQuery(query url.Values, name string) bool {
+	_, ok := query[name]
+	return ok
+}
+
+// requestMetadata returns the user metadata headers of the request.
+func requestMetadata(r *http.Request) map[string]string {
+	metadata := map[string]string{}
+
+	for k := range r.Header {
+		if strings.HasPrefix(strings.ToLower(k), "x-amz-meta-") {
+			metadata[strings.ToLower(k[len("x-amz-meta-"):])] = r.Header.Get(k)
+		}
+	}
+
+	return metadata
+}
+
+// encodeKey URL encodes the key if requested by the client.
+func encodeKey(key string, encode bool) string {
+	if !encode {
+		return key
+	}
+
+	return uriEncode(key, false)
+}
+
+// randomHex returns a random hex string generated from n bytes.
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	_, _ = rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
+
+// decodeXML decodes an XML request body.
+func decodeXML(r io.Reader, v interface{}) error {
+	data, err := ioutil.ReadAll(io.LimitReader(r, 1024*1024))
+	if err != nil {
+		s3Err, ok := err.(*Error)
+		if ok {
+			return s3Err
+		}
+
+		return errInternalError(err)
+	}
+
+	err = xml.Unmarshal(data, v)
+	if err != nil {
+		return errMalformedXML()
+	}
+
+	return nil
+}
+
+// writeXML writes an XML response.
+func writeXML(w http.ResponseWriter, status int, v interface{}) error {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return errInternalError(err)
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Length", strconv.Itoa(len(xml.Header)+len(data)))
+	w.WriteHeader(status)
+
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(data)
+
+	return nil
+}
+
+// writeError writes an S3 error response.
+func writeError(w http.ResponseWriter, r *http.Request, err *Error) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(err.status)
+		return
+	}
+
+	_ = writeXML(w, err.status, err)
+}
+
+// lastModified formats a modification time for use in headers.
+func lastModified(t time.Time) string {
+	return t.UTC().Format(http.TimeFormat)
+}
@@ -41,6 +41,26 @@ func ValidName(value string) error {
 	return nil
 }
 
+// ValidBucketName validates the provided name, and returns an error if it's not a valid storage bucket name.
+// Bucket names follow the S3 naming rules, restricted to lowercase letters, numbers and hyphens.
+func ValidBucketName(value string) error {
+	if len(value) < 3 || len(value) > 63 {
+		return fmt.Errorf("Bucket name must be between 3 and 63 characters long")
+	}
+
+	for _, r := range value {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return fmt.Errorf("Bucket name can only contain lowercase letters, numbers and hyphens")
+		}
+	}
+
+	if strings.HasPrefix(value, "-") || strings.HasSuffix(value, "-") {
+		return fmt.Errorf("Bucket name must begin and end with a letter or number")
+	}
+
+	return nil
+}
+
 // ConfigDiff returns a diff of the provided configs. Additionally, it returns whether or not
 // only user properties have been changed.
 func ConfigDiff(oldConfig map[string]string, newConfig map[string]string) ([]string, bool) {
@@ -157,6 +177,8 @@ func VolumeContentTypeToDBContentType(contentType drivers.ContentType) (int, err
 		return db.StoragePoolVolumeContentTypeBlock, nil
 	case drivers.ContentTypeFS:
 		return db.StoragePoolVolumeContentTypeFS, nil
+	case drivers.ContentTypeISO:
+		return db.StoragePoolVolumeContentTypeISO, nil
 	}
 
 	return -1, fmt.Errorf("Invalid volume content type")
@@ -169,6 +191,8 @@ func VolumeDBContentTypeToContentType(volDBType int) (drivers.ContentType, error
 		return drivers.ContentTypeBlock, nil
 	case db.StoragePoolVolumeContentTypeFS:
 		return drivers.ContentTypeFS, nil
+	case db.StoragePoolVolumeContentTypeISO:
+		return drivers.ContentTypeISO, nil
 	}
 
 	return "", fmt.Errorf("Invalid volume content type")
@@ -181,6 +205,8 @@ func VolumeContentTypeNameToContentType(contentTypeName string) (int, error) {
 		return db.StoragePoolVolumeContentTypeFS, nil
 	case db.StoragePoolVolumeContentTypeNameBlock:
 		return db.StoragePoolVolumeContentTypeBlock, nil
+	case db.StoragePoolVolumeContentTypeNameISO:
+		return db.StoragePoolVolumeContentTypeISO, nil
 	}
 
 	return -1, fmt.Errorf("Invalid volume content type name")
@@ -374,6 +400,14 @@ func validateVolumeCommonRules(vol drivers.Volume) map[string]func(string) error
 		},
 		"snapshots.schedule": validate.Optional(validate.IsCron([]string{"@hourly", "@daily", "@midnight", "@weekly", "@monthly", "@annually", "@yearly"})),
 		"snapshots.pattern":  validate.IsAny,
+		"backups.expiry": func(value string) error {
+			// Validate expression
+			_, err := shared.GetSnapshotExpiry(time.Time{}, value)
+			return err
+		},
+		"backups.schedule": validate.Optional(validate.IsCron([]string{"@hourly", "@daily", "@midnight", "@weekly", "@monthly", "@annually", "@yearly"})),
+		"backups.pattern":  validate.IsAny,
+		"backups.keep":     validate.Optional(validate.IsUint32),
 	}
 
 	// volatile.idmap settings only make sense for filesystem volumes.
@@ -822,7 +856,7 @@ func VolumeUsedByDaemon(s *state.State, poolName string, volumeName string) (boo
 
 // FallbackMigrationType returns the fallback migration transport to use based on volume content type.
 func FallbackMigrationType(contentType drivers.ContentType) migration.MigrationFSType {
-	if contentType == drivers.ContentTypeBlock {
+	if contentType == drivers.ContentTypeBlock || contentType == drivers.ContentTypeISO {
 		return migration.MigrationFSType_BLOCK_AND_RSYNC
 	}
 
@@ -0,0 +1,1101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/auth"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/lifecycle"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/request"
+	"github.com/lxc/lxd/lxd/response"
+	storagePools "github.com/lxc/lxd/lxd/storage"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+var storagePoolBucketsCmd = APIEndpoint{
+	Path: "storage-pools/{poolName}/buckets",
+
+	Get:  APIEndpointAction{Handler: storagePoolBucketsGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
+	Post: APIEndpointAction{Handler: storagePoolBucketsPost, AccessHandler: allowProjectPermission(auth.EntitlementCanManageStorageVolumes)},
+}
+
+var storagePoolBucketCmd = APIEndpoint{
+	Path: "storage-pools/{poolName}/buckets/{bucketName}",
+
+	Delete: APIEndpointAction{Handler: storagePoolBucketDelete, AccessHandler: allowProjectPermission(auth.EntitlementCanManageStorageVolumes)},
+	Get:    APIEndpointAction{Handler: storagePoolBucketGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
+	Patch:  APIEndpointAction{Handler: storagePoolBucketPut, AccessHandler: allowProjectPermission(auth.EntitlementCanManageStorageVolumes)},
+	Put:    APIEndpointAction{Handler: storagePoolBucketPut, AccessHandler: allowProjectPermission(auth.EntitlementCanManageStorageVolumes)},
+}
+
+var storagePoolBucketKeysCmd = APIEndpoint{
+	Path: "storage-pools/{poolName}/buckets/{bucketName}/keys",
+
+	Get:  APIEndpointAction{Handler: storagePoolBucketKeysGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
+	Post: APIEndpointAction{Handler: storagePoolBucketKeysPost, AccessHandler: allowProjectPermission(auth.EntitlementCanManageStorageVolumes)},
+}
+
+var storagePoolBucketKeyCmd = APIEndpoint{
+	Path: "storage-pools/{poolName}/buckets/{bucketName}/keys/{keyName}",
+
+	Delete: APIEndpointAction{Handler: storagePoolBucketKeyDelete, AccessHandler: allowProjectPermission(auth.EntitlementCanManageStorageVolumes)},
+	Get:    APIEndpointAction{Handler: storagePoolBucketKeyGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
+	Put:    APIEndpointAction{Handler: storagePoolBucketKeyPut, AccessHandler: allowProjectPermission(auth.EntitlementCanManageStorageVolumes)},
+}
+
+// Bucket key roles.
+const (
+	bucketKeyRoleReadOnly = "read-only"
+	bucketKeyRoleAdmin    = "admin"
+)
+
+// API endpoints.
+
+// swagger:operation GET /1.0/storage-pools/{poolName}/buckets storage storage_pool_buckets_get
+//
+// Get the storage pool buckets
+//
+// Returns a list of storage pool buckets (URLs).
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+// responses:
+//   "200":
+//     description: API endpoints
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           type: array
+//           description: List of endpoints
+//           items:
+//             type: string
+//           example: |-
+//             [
+//               "/1.0/storage-pools/local/buckets/foo",
+//               "/1.0/storage-pools/local/buckets/bar"
+//             ]
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+
+// swagger:operation GET /1.0/storage-pools/{poolName}/buckets?recursion=1 storage storage_pool_buckets_get_recursion1
+//
+// Get the storage pool buckets
+//
+// Returns a list of storage pool buckets (structs).
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+// responses:
+//   "200":
+//     description: API endpoints
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           type: array
+//           description: List of storage pool buckets
+//           items:
+//             $ref: "#/definitions/StorageBucket"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func storagePoolBucketsGet(d *Daemon, r *http.Request) response.Response {
+	resp := forwardedResponseIfTargetIsRemote(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	projectName, err := project.StorageVolumeProject(d.State().Cluster, projectParam(r), db.StoragePoolVolumeTypeCustom)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	poolName, err := url.PathUnescape(mux.Vars(r)["poolName"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	pool, err := storagePools.GetPoolByName(d.State(), poolName)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Only restrict the list to this member's buckets if a target was requested.
+	memberSpecific := queryParam(r, "target") != ""
+
+	var buckets []*db.StorageBucket
+	var localMember string
+	poolID := pool.ID()
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		localMember, err = tx.GetLocalNodeName()
+		if err != nil {
+			return err
+		}
+
+		buckets, err = tx.GetStoragePoolBuckets(memberSpecific, db.StorageBucketFilter{
+			PoolID:  &poolID,
+			Project: &projectName,
+		})
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	recursion := util.IsRecursionRequest(r)
+
+	resultString := []string{}
+	resultMap := []*api.StorageBucket{}
+	for _, bucket := range buckets {
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/storage-pools/%s/buckets/%s", version.APIVersion, url.PathEscape(pool.Name()), url.PathEscape(bucket.Name)))
+		} else {
+			// The S3 URL is only known for buckets served by this member.
+			if bucket.Location == "" || bucket.Location == localMember {
+				bucket.S3URL = storagePoolBucketURL(d, bucket)
+			}
+
+			resultMap = append(resultMap, &bucket.StorageBucket)
+		}
+	}
+
+	if !recursion {
+		return response.SyncResponse(true, resultString)
+	}
+
+	return response.SyncResponse(true, resultMap)
+}
+
+// swagger:operation GET /1.0/storage-pools/{poolName}/buckets/{bucketName} storage storage_pool_bucket_get
+//
+// Get the storage pool bucket
+//
+// Gets a specific storage pool bucket.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+// responses:
+//   "200":
+//     description: Storage pool bucket
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           $ref: "#/definitions/StorageBucket"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "404":
+//     $ref: "#/responses/NotFound"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func storagePoolBucketGet(d *Daemon, r *http.Request) response.Response {
+	_, _, bucket, resp := storagePoolBucketLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	bucket.S3URL = storagePoolBucketURL(d, bucket)
+
+	return response.SyncResponseETag(true, &bucket.StorageBucket, bucket.Etag())
+}
+
+// swagger:operation POST /1.0/storage-pools/{poolName}/buckets storage storage_pool_buckets_post
+//
+// Add a storage pool bucket
+//
+// Creates a new storage pool bucket.
+//
+// ---
+// consumes:
+//   - application/json
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+//   - in: body
+//     name: bucket
+//     description: Bucket
+//     required: true
+//     schema:
+//       $ref: "#/definitions/StorageBucketsPost"
+// responses:
+//   "200":
+//     $ref: "#/responses/EmptySyncResponse"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func storagePoolBucketsPost(d *Daemon, r *http.Request) response.Response {
+	resp := forwardedResponseIfTargetIsRemote(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	projectName, err := project.StorageVolumeProject(d.State().Cluster, projectParam(r), db.StoragePoolVolumeTypeCustom)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	poolName, err := url.PathUnescape(mux.Vars(r)["poolName"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	pool, err := storagePools.GetPoolByName(d.State(), poolName)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	req := api.StorageBucketsPost{}
+
+	// Parse the request into a record.
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return project.AllowBucketCreation(tx, projectName, req)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = pool.CreateBucket(projectName, req, nil)
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed creating storage bucket: %w", err))
+	}
+
+	d.State().Events.SendLifecycle(projectName, lifecycle.StorageBucketCreated.Event(pool.Name(), projectName, req.Name, request.CreateRequestor(r), nil))
+
+	u := fmt.Sprintf("/%s/storage-pools/%s/buckets/%s", version.APIVersion, url.PathEscape(pool.Name()), url.PathEscape(req.Name))
+	return response.SyncResponseLocation(true, nil, u)
+}
+
+// swagger:operation PATCH /1.0/storage-pools/{poolName}/buckets/{bucketName} storage storage_pool_bucket_patch
+//
+// Partially update the storage bucket
+//
+// Updates a subset of the storage bucket configuration.
+//
+// ---
+// consumes:
+//   - application/json
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+//   - in: body
+//     name: storage bucket
+//     description: Storage bucket configuration
+//     required: true
+//     schema:
+//       $ref: "#/definitions/StorageBucketPut"
+// responses:
+//   "200":
+//     $ref: "#/responses/EmptySyncResponse"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "412":
+//     $ref: "#/responses/PreconditionFailed"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+
+// swagger:operation PUT /1.0/storage-pools/{poolName}/buckets/{bucketName} storage storage_pool_bucket_put
+//
+// Update the storage bucket
+//
+// Updates the entire storage bucket configuration.
+//
+// ---
+// consumes:
+//   - application/json
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+//   - in: body
+//     name: storage bucket
+//     description: Storage bucket configuration
+//     required: true
+//     schema:
+//       $ref: "#/definitions/StorageBucketPut"
+// responses:
+//   "200":
+//     $ref: "#/responses/EmptySyncResponse"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "412":
+//     $ref: "#/responses/PreconditionFailed"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func storagePoolBucketPut(d *Daemon, r *http.Request) response.Response {
+	pool, projectName, bucket, resp := storagePoolBucketLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	// Validate the ETag.
+	err := util.EtagCheck(r, bucket.Etag())
+	if err != nil {
+		return response.PreconditionFailed(err)
+	}
+
+	req := api.StorageBucketPut{}
+
+	// Decode the request.
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	if r.Method == http.MethodPatch {
+		// If config being updated via "patch" method, then merge all existing config with the keys that
+		// are present in the request config.
+		for k, v := range bucket.Config {
+			_, ok := req.Config[k]
+			if !ok {
+				req.Config[k] = v
+			}
+		}
+
+		if req.Description == "" {
+			req.Description = bucket.Description
+		}
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return project.AllowBucketUpdate(tx, projectName, bucket.ID, req)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = pool.UpdateBucket(projectName, bucket.Name, req, nil)
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed updating storage bucket: %w", err))
+	}
+
+	d.State().Events.SendLifecycle(projectName, lifecycle.StorageBucketUpdated.Event(pool.Name(), projectName, bucket.Name, request.CreateRequestor(r), nil))
+
+	return response.EmptySyncResponse
+}
+
+// swagger:operation DELETE /1.0/storage-pools/{poolName}/buckets/{bucketName} storage storage_pool_bucket_delete
+//
+// Delete the storage bucket
+//
+// Removes the storage bucket and all of its contents.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+// responses:
+//   "200":
+//     $ref: "#/responses/EmptySyncResponse"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func storagePoolBucketDelete(d *Daemon, r *http.Request) response.Response {
+	pool, projectName, bucket, resp := storagePoolBucketLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	err := pool.DeleteBucket(projectName, bucket.Name, nil)
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed deleting storage bucket: %w", err))
+	}
+
+	d.State().Events.SendLifecycle(projectName, lifecycle.StorageBucketDeleted.Event(pool.Name(), projectName, bucket.Name, request.CreateRequestor(r), nil))
+
+	return response.EmptySyncResponse
+}
+
+// swagger:operation GET /1.0/storage-pools/{poolName}/buckets/{bucketName}/keys storage storage_pool_bucket_keys_get
+//
+// Get the storage pool bucket keys
+//
+// Returns a list of storage pool bucket keys (URLs).
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+// responses:
+//   "200":
+//     description: API endpoints
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           type: array
+//           description: List of endpoints
+//           items:
+//             type: string
+//           example: |-
+//             [
+//               "/1.0/storage-pools/local/buckets/foo/keys/my-read-only-key",
+//               "/1.0/storage-pools/local/buckets/foo/keys/my-admin-key"
+//             ]
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+
+// swagger:operation GET /1.0/storage-pools/{poolName}/buckets/{bucketName}/keys?recursion=1 storage storage_pool_bucket_keys_get_recursion1
+//
+// Get the storage pool bucket keys
+//
+// Returns a list of storage pool bucket keys (structs).
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+// responses:
+//   "200":
+//     description: API endpoints
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           type: array
+//           description: List of storage pool bucket keys
+//           items:
+//             $ref: "#/definitions/StorageBucketKey"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func storagePoolBucketKeysGet(d *Daemon, r *http.Request) response.Response {
+	pool, _, bucket, resp := storagePoolBucketLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	var keys []*db.StorageBucketKey
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		keys, err = tx.GetStoragePoolBucketKeys(bucket.ID)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	recursion := util.IsRecursionRequest(r)
+
+	resultString := []string{}
+	resultMap := []*api.StorageBucketKey{}
+	for _, key := range keys {
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/storage-pools/%s/buckets/%s/keys/%s", version.APIVersion, url.PathEscape(pool.Name()), url.PathEscape(bucket.Name), url.PathEscape(key.Name)))
+		} else {
+			resultMap = append(resultMap, &key.StorageBucketKey)
+		}
+	}
+
+	if !recursion {
+		return response.SyncResponse(true, resultString)
+	}
+
+	return response.SyncResponse(true, resultMap)
+}
+
+// swagger:operation GET /1.0/storage-pools/{poolName}/buckets/{bucketName}/keys/{keyName} storage storage_pool_bucket_key_get
+//
+// Get the storage pool bucket key
+//
+// Gets a specific storage pool bucket key.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+// responses:
+//   "200":
+//     description: Storage pool bucket key
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           $ref: "#/definitions/StorageBucketKey"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "404":
+//     $ref: "#/responses/NotFound"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func storagePoolBucketKeyGet(d *Daemon, r *http.Request) response.Response {
+	_, _, _, key, resp := storagePoolBucketKeyLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	return response.SyncResponseETag(true, &key.StorageBucketKey, key.Etag())
+}
+
+// swagger:operation POST /1.0/storage-pools/{poolName}/buckets/{bucketName}/keys storage storage_pool_bucket_keys_post
+//
+// Add a storage pool bucket key
+//
+// Creates a new storage pool bucket key.
+// If the access or secret keys aren't provided, they are generated.
+//
+// ---
+// consumes:
+//   - application/json
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+//   - in: body
+//     name: bucket
+//     description: Bucket key
+//     required: true
+//     schema:
+//       $ref: "#/definitions/StorageBucketKeysPost"
+// responses:
+//   "200":
+//     description: Storage pool bucket key
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           $ref: "#/definitions/StorageBucketKey"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func storagePoolBucketKeysPost(d *Daemon, r *http.Request) response.Response {
+	pool, projectName, bucket, resp := storagePoolBucketLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	req := api.StorageBucketKeysPost{}
+
+	// Parse the request into a record.
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if req.Name == "" {
+		return response.BadRequest(fmt.Errorf("No name provided"))
+	}
+
+	if strings.Contains(req.Name, "/") {
+		return response.BadRequest(fmt.Errorf("Storage bucket key names may not contain slashes"))
+	}
+
+	err = storagePoolBucketKeyFill(&req.StorageBucketKeyPut)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.CreateStoragePoolBucketKey(bucket.ID, req)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed creating storage bucket key: %w", err))
+	}
+
+	d.State().Events.SendLifecycle(projectName, lifecycle.StorageBucketKeyCreated.Event(pool.Name(), projectName, bucket.Name, req.Name, request.CreateRequestor(r), nil))
+
+	key := api.StorageBucketKey{
+		Name:                req.Name,
+		StorageBucketKeyPut: req.StorageBucketKeyPut,
+	}
+
+	u := fmt.Sprintf("/%s/storage-pools/%s/buckets/%s/keys/%s", version.APIVersion, url.PathEscape(pool.Name()), url.PathEscape(bucket.Name), url.PathEscape(req.Name))
+	return response.SyncResponseLocation(true, key, u)
+}
+
+// swagger:operation PUT /1.0/storage-pools/{poolName}/buckets/{bucketName}/keys/{keyName} storage storage_pool_bucket_key_put
+//
+// Update the storage bucket key
+//
+// Updates the entire storage bucket key configuration.
+// If the access or secret keys are empty, new ones are generated.
+//
+// ---
+// consumes:
+//   - application/json
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+//   - in: body
+//     name: storage bucket
+//     description: Storage bucket key configuration
+//     required: true
+//     schema:
+//       $ref: "#/definitions/StorageBucketKeyPut"
+// responses:
+//   "200":
+//     $ref: "#/responses/EmptySyncResponse"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "412":
+//     $ref: "#/responses/PreconditionFailed"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func storagePoolBucketKeyPut(d *Daemon, r *http.Request) response.Response {
+	pool, projectName, bucket, key, resp := storagePoolBucketKeyLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	// Validate the ETag.
+	err := util.EtagCheck(r, key.Etag())
+	if err != nil {
+		return response.PreconditionFailed(err)
+	}
+
+	req := api.StorageBucketKeyPut{}
+
+	// Decode the request.
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = storagePoolBucketKeyFill(&req)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.UpdateStoragePoolBucketKey(bucket.ID, key.ID, &req)
+	})
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed updating storage bucket key: %w", err))
+	}
+
+	d.State().Events.SendLifecycle(projectName, lifecycle.StorageBucketKeyUpdated.Event(pool.Name(), projectName, bucket.Name, key.Name, request.CreateRequestor(r), nil))
+
+	return response.EmptySyncResponse
+}
+
+// swagger:operation DELETE /1.0/storage-pools/{poolName}/buckets/{bucketName}/keys/{keyName} storage storage_pool_bucket_key_delete
+//
+// Delete the storage bucket key
+//
+// Removes the storage bucket key.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+// responses:
+//   "200":
+//     $ref: "#/responses/EmptySyncResponse"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func storagePoolBucketKeyDelete(d *Daemon, r *http.Request) response.Response {
+	pool, projectName, bucket, key, resp := storagePoolBucketKeyLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.DeleteStoragePoolBucketKey(bucket.ID, key.ID)
+	})
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed deleting storage bucket key: %w", err))
+	}
+
+	d.State().Events.SendLifecycle(projectName, lifecycle.StorageBucketKeyDeleted.Event(pool.Name(), projectName, bucket.Name, key.Name, request.CreateRequestor(r), nil))
+
+	return response.EmptySyncResponse
+}
+
+// storagePoolBucketLoad loads the storage pool and bucket referenced by the request.
+// If the bucket lives on another cluster member, a response forwarding the request to it is returned instead.
+func storagePoolBucketLoad(d *Daemon, r *http.Request) (storagePools.Pool, string, *db.StorageBucket, response.Response) {
+	resp := forwardedResponseIfTargetIsRemote(d, r)
+	if resp != nil {
+		return nil, "", nil, resp
+	}
+
+	projectName, err := project.StorageVolumeProject(d.State().Cluster, projectParam(r), db.StoragePoolVolumeTypeCustom)
+	if err != nil {
+		return nil, "", nil, response.SmartError(err)
+	}
+
+	poolName, err := url.PathUnescape(mux.Vars(r)["poolName"])
+	if err != nil {
+		return nil, "", nil, response.SmartError(err)
+	}
+
+	bucketName, err := url.PathUnescape(mux.Vars(r)["bucketName"])
+	if err != nil {
+		return nil, "", nil, response.SmartError(err)
+	}
+
+	pool, err := storagePools.GetPoolByName(d.State(), poolName)
+	if err != nil {
+		return nil, "", nil, response.SmartError(err)
+	}
+
+	// Buckets on local pools are specific to a member, so when no target is given forward the request
+	// to the member which has the bucket.
+	memberSpecific := !pool.Driver().Info().Remote
+
+	var bucket *db.StorageBucket
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		bucket, err = tx.GetStoragePoolBucket(pool.ID(), projectName, memberSpecific && queryParam(r, "target") != "", bucketName)
+		return err
+	})
+	if err != nil {
+		if err == db.ErrNoSuchObject {
+			return nil, "", nil, response.NotFound(fmt.Errorf("Storage bucket not found"))
+		}
+
+		return nil, "", nil, response.SmartError(err)
+	}
+
+	if memberSpecific && bucket.Location != "" {
+		resp = forwardedResponseToNode(d, r, bucket.Location)
+		if resp != nil {
+			return nil, "", nil, resp
+		}
+	}
+
+	return pool, projectName, bucket, nil
+}
+
+// storagePoolBucketKeyLoad loads the storage pool, bucket and bucket key referenced by the request.
+func storagePoolBucketKeyLoad(d *Daemon, r *http.Request) (storagePools.Pool, string, *db.StorageBucket, *db.StorageBucketKey, response.Response) {
+	pool, projectName, bucket, resp := storagePoolBucketLoad(d, r)
+	if resp != nil {
+		return nil, "", nil, nil, resp
+	}
+
+	keyName, err := url.PathUnescape(mux.Vars(r)["keyName"])
+	if err != nil {
+		return nil, "", nil, nil, response.SmartError(err)
+	}
+
+	var key *db.StorageBucketKey
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		key, err = tx.GetStoragePoolBucketKey(bucket.ID, keyName)
+		return err
+	})
+	if err != nil {
+		if err == db.ErrNoSuchObject {
+			return nil, "", nil, nil, response.NotFound(fmt.Errorf("Storage bucket key not found"))
+		}
+
+		return nil, "", nil, nil, response.SmartError(err)
+	}
+
+	return pool, projectName, bucket, key, nil
+}
+
+// storagePoolBucketKeyFill validates the role of a bucket key and generates any missing credentials.
+func storagePoolBucketKeyFill(key *api.StorageBucketKeyPut) error {
+	if key.Role == "" {
+		key.Role = bucketKeyRoleReadOnly
+	}
+
+	if !shared.StringInSlice(key.Role, []string{bucketKeyRoleReadOnly, bucketKeyRoleAdmin}) {
+		return api.StatusErrorf(http.StatusBadRequest, "Invalid key role %q", key.Role)
+	}
+
+	if key.AccessKey == "" {
+		accessKey, err := shared.RandomCryptoString()
+		if err != nil {
+			return err
+		}
+
+		key.AccessKey = strings.ToUpper(accessKey[:20])
+	}
+
+	if key.SecretKey == "" {
+		secretKey, err := shared.RandomCryptoString()
+		if err != nil {
+			return err
+		}
+
+		key.SecretKey = secretKey[:40]
+	}
+
+	return nil
+}
+
+// storagePoolBucketURL returns the S3 URL of the bucket if it is served by this member, or an empty string
+// if the storage buckets listener isn't enabled.
+func storagePoolBucketURL(d *Daemon, bucket *db.StorageBucket) string {
+	address := d.endpoints.StorageBucketsAddress()
+	if address == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("https://%s/%s", address, project.StorageVolume(bucket.Project, bucket.Name))
+}
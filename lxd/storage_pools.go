@@ -166,7 +166,7 @@ func storagePoolsGet(d *Daemon, r *http.Request) response.Response {
 			if err != nil {
 				return response.SmartError(err)
 			}
-			pl.UsedBy = project.FilterUsedBy(r, poolUsedBy)
+			pl.UsedBy = project.FilterUsedBy(d.authorizer, r, poolUsedBy)
 
 			resultMap = append(resultMap, *pl)
 		}
@@ -574,7 +574,7 @@ func storagePoolGet(d *Daemon, r *http.Request) response.Response {
 	if err != nil {
 		return response.SmartError(err)
 	}
-	pool.UsedBy = project.FilterUsedBy(r, poolUsedBy)
+	pool.UsedBy = project.FilterUsedBy(d.authorizer, r, poolUsedBy)
 
 	targetNode := queryParam(r, "target")
 
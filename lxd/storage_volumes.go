@@ -14,12 +14,12 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"github.com/lxc/lxd/lxd/auth"
 	"github.com/lxc/lxd/lxd/backup"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
-	"github.com/lxc/lxd/lxd/rbac"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/revert"
 	"github.com/lxc/lxd/lxd/state"
@@ -36,25 +36,25 @@ import (
 var storagePoolVolumesCmd = APIEndpoint{
 	Path: "storage-pools/{name}/volumes",
 
-	Get:  APIEndpointAction{Handler: storagePoolVolumesGet, AccessHandler: allowProjectPermission("storage-volumes", "view")},
-	Post: APIEndpointAction{Handler: storagePoolVolumesPost, AccessHandler: allowProjectPermission("storage-volumes", "manage-storage-volumes")},
+	Get:  APIEndpointAction{Handler: storagePoolVolumesGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
+	Post: APIEndpointAction{Handler: storagePoolVolumesPost, AccessHandler: allowProjectPermission(auth.EntitlementCanManageStorageVolumes)},
 }
 
 var storagePoolVolumesTypeCmd = APIEndpoint{
 	Path: "storage-pools/{name}/volumes/{type}",
 
-	Get:  APIEndpointAction{Handler: storagePoolVolumesTypeGet, AccessHandler: allowProjectPermission("storage-volumes", "view")},
-	Post: APIEndpointAction{Handler: storagePoolVolumesTypePost, AccessHandler: allowProjectPermission("storage-volumes", "manage-storage-volumes")},
+	Get:  APIEndpointAction{Handler: storagePoolVolumesTypeGet, AccessHandler: allowProjectPermission(auth.EntitlementCanView)},
+	Post: APIEndpointAction{Handler: storagePoolVolumesTypePost, AccessHandler: allowProjectPermission(auth.EntitlementCanManageStorageVolumes)},
 }
 
 var storagePoolVolumeTypeCmd = APIEndpoint{
 	Path: "storage-pools/{pool}/volumes/{type}/{name}",
 
-	Delete: APIEndpointAction{Handler: storagePoolVolumeDelete, AccessHandler: allowProjectPermission("storage-volumes", "manage-storage-volumes")},
-	Get:    APIEndpointAction{Handler: storagePoolVolumeGet, AccessHandler: allowProjectPermission("storage-volumes", "view")},
-	Patch:  APIEndpointAction{Handler: storagePoolVolumePatch, AccessHandler: allowProjectPermission("storage-volumes", "manage-storage-volumes")},
-	Post:   APIEndpointAction{Handler: storagePoolVolumePost, AccessHandler: allowProjectPermission("storage-volumes", "manage-storage-volumes")},
-	Put:    APIEndpointAction{Handler: storagePoolVolumePut, AccessHandler: allowProjectPermission("storage-volumes", "manage-storage-volumes")},
+	Delete: APIEndpointAction{Handler: storagePoolVolumeDelete, AccessHandler: allowPermission(auth.ObjectTypeStorageVolume, auth.EntitlementCanEdit, "pool", "type", "name")},
+	Get:    APIEndpointAction{Handler: storagePoolVolumeGet, AccessHandler: allowPermission(auth.ObjectTypeStorageVolume, auth.EntitlementCanView, "pool", "type", "name")},
+	Patch:  APIEndpointAction{Handler: storagePoolVolumePatch, AccessHandler: allowPermission(auth.ObjectTypeStorageVolume, auth.EntitlementCanEdit, "pool", "type", "name")},
+	Post:   APIEndpointAction{Handler: storagePoolVolumePost, AccessHandler: allowPermission(auth.ObjectTypeStorageVolume, auth.EntitlementCanEdit, "pool", "type", "name")},
+	Put:    APIEndpointAction{Handler: storagePoolVolumePut, AccessHandler: allowPermission(auth.ObjectTypeStorageVolume, auth.EntitlementCanEdit, "pool", "type", "name")},
 }
 
 // swagger:operation GET /1.0/storage-pools/{name}/volumes storage storage_pool_volumes_get
@@ -246,7 +246,7 @@ func storagePoolVolumesGet(d *Daemon, r *http.Request) response.Response {
 			if err != nil {
 				return response.InternalError(err)
 			}
-			volume.UsedBy = project.FilterUsedBy(r, volumeUsedBy)
+			volume.UsedBy = project.FilterUsedBy(d.authorizer, r, volumeUsedBy)
 		}
 	}
 
@@ -411,7 +411,7 @@ func storagePoolVolumesTypeGet(d *Daemon, r *http.Request) response.Response {
 			if err != nil {
 				return response.SmartError(err)
 			}
-			vol.UsedBy = project.FilterUsedBy(r, volumeUsedBy)
+			vol.UsedBy = project.FilterUsedBy(d.authorizer, r, volumeUsedBy)
 
 			resultMap = append(resultMap, vol)
 		}
@@ -476,6 +476,10 @@ func storagePoolVolumesTypePost(d *Daemon, r *http.Request) response.Response {
 
 	// If we're getting binary content, process separately.
 	if r.Header.Get("Content-Type") == "application/octet-stream" {
+		if r.Header.Get("X-LXD-type") == db.StoragePoolVolumeContentTypeNameISO {
+			return createStoragePoolVolumeFromISO(d, r, projectParam(r), projectName, r.Body, poolName, r.Header.Get("X-LXD-name"))
+		}
+
 		return createStoragePoolVolumeFromBackup(d, r, projectParam(r), projectName, r.Body, poolName, r.Header.Get("X-LXD-name"))
 	}
 
@@ -501,11 +505,16 @@ func storagePoolVolumesTypePost(d *Daemon, r *http.Request) response.Response {
 		req.ContentType = db.StoragePoolVolumeContentTypeNameFS
 	}
 
-	_, err = storagePools.VolumeContentTypeNameToContentType(req.ContentType)
+	contentType, err := storagePools.VolumeContentTypeNameToContentType(req.ContentType)
 	if err != nil {
 		return response.BadRequest(err)
 	}
 
+	// ISO volumes can only be created by importing an ISO image.
+	if contentType == db.StoragePoolVolumeContentTypeISO && req.Source.Type == "" {
+		return response.BadRequest(fmt.Errorf("ISO volumes can only be created by importing an ISO image"))
+	}
+
 	req.Type = mux.Vars(r)["type"]
 
 	// We currently only allow to create storage volumes of type storagePoolVolumeTypeCustom.
@@ -712,6 +721,11 @@ func storagePoolVolumesPost(d *Daemon, r *http.Request) response.Response {
 		req.ContentType = db.StoragePoolVolumeContentTypeNameFS
 	}
 
+	// ISO volumes can only be created by importing an ISO image.
+	if req.ContentType == db.StoragePoolVolumeContentTypeNameISO && req.Source.Type == "" {
+		return response.BadRequest(fmt.Errorf("ISO volumes can only be created by importing an ISO image"))
+	}
+
 	projectName, err := project.StorageVolumeProject(d.State().Cluster, projectParam(r), db.StoragePoolVolumeTypeCustom)
 	if err != nil {
 		return response.SmartError(err)
@@ -913,7 +927,7 @@ func storagePoolVolumePost(d *Daemon, r *http.Request) response.Response {
 		targetProjectName = req.Project
 
 		// Check is user has access to target project
-		if !rbac.UserHasPermission(r, targetProjectName, "manage-storage-volumes") {
+		if !d.authorizer.UserHasPermission(r, auth.ObjectProject(targetProjectName), auth.EntitlementCanManageStorageVolumes) {
 			return response.Forbidden(nil)
 		}
 	}
@@ -1252,7 +1266,7 @@ func storagePoolVolumeGet(d *Daemon, r *http.Request) response.Response {
 	if err != nil {
 		return response.SmartError(err)
 	}
-	volume.UsedBy = project.FilterUsedBy(r, volumeUsedBy)
+	volume.UsedBy = project.FilterUsedBy(d.authorizer, r, volumeUsedBy)
 
 	etag := []interface{}{volumeName, volume.Type, volume.Config}
 
@@ -1655,6 +1669,85 @@ func storagePoolVolumeDelete(d *Daemon, r *http.Request) response.Response {
 	return response.EmptySyncResponse
 }
 
+func createStoragePoolVolumeFromISO(d *Daemon, r *http.Request, requestProjectName string, projectName string, data io.Reader, pool string, volName string) response.Response {
+	revert := revert.New()
+	defer revert.Fail()
+
+	if volName == "" {
+		return response.BadRequest(fmt.Errorf("Missing volume name"))
+	}
+
+	if strings.Contains(volName, "/") {
+		return response.BadRequest(fmt.Errorf("Storage volume names may not contain slashes"))
+	}
+
+	// Create temporary file to store uploaded ISO data.
+	isoFile, err := ioutil.TempFile(shared.VarPath("backups"), "lxd_iso_")
+	if err != nil {
+		return response.InternalError(err)
+	}
+	defer os.Remove(isoFile.Name())
+	revert.Add(func() { isoFile.Close() })
+
+	// Stream uploaded ISO data into temporary file.
+	size, err := io.Copy(isoFile, data)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	// Check whether we are allowed to create the volume.
+	req := api.StorageVolumesPost{
+		Name:        volName,
+		Type:        db.StoragePoolVolumeTypeNameCustom,
+		ContentType: db.StoragePoolVolumeContentTypeNameISO,
+		StorageVolumePut: api.StorageVolumePut{
+			Config: map[string]string{
+				"size": fmt.Sprintf("%d", size),
+			},
+		},
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return project.AllowVolumeCreation(tx, projectName, req)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Copy reverter so far so we can use it inside run after this function has finished.
+	runRevert := revert.Clone()
+
+	run := func(op *operations.Operation) error {
+		defer isoFile.Close()
+		defer runRevert.Fail()
+
+		pool, err := storagePools.GetPoolByName(d.State(), pool)
+		if err != nil {
+			return err
+		}
+
+		// Dump ISO to storage.
+		err = pool.CreateCustomVolumeFromISO(projectName, volName, isoFile, size, op)
+		if err != nil {
+			return errors.Wrap(err, "Create custom volume from ISO")
+		}
+
+		runRevert.Success()
+		return nil
+	}
+
+	resources := map[string][]string{}
+	resources["storage_volumes"] = []string{volName}
+
+	op, err := operations.OperationCreate(d.State(), requestProjectName, operations.OperationClassTask, db.OperationVolumeCreate, resources, nil, run, nil, nil, r)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	revert.Success()
+	return operations.OperationResponse(op)
+}
+
 func createStoragePoolVolumeFromBackup(d *Daemon, r *http.Request, requestProjectName string, projectName string, data io.Reader, pool string, volName string) response.Response {
 	revert := revert.New()
 	defer revert.Fail()
@@ -13,6 +13,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 
+	"github.com/lxc/lxd/lxd/auth"
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/instance"
@@ -32,18 +33,18 @@ import (
 var storagePoolVolumeSnapshotsTypeCmd = APIEndpoint{
 	Path: "storage-pools/{pool}/volumes/{type}/{name}/snapshots",
 
-	Get:  APIEndpointAction{Handler: storagePoolVolumeSnapshotsTypeGet, AccessHandler: allowProjectPermission("storage-volumes", "view")},
-	Post: APIEndpointAction{Handler: storagePoolVolumeSnapshotsTypePost, AccessHandler: allowProjectPermission("storage-volumes", "manage-storage-volumes")},
+	Get:  APIEndpointAction{Handler: storagePoolVolumeSnapshotsTypeGet, AccessHandler: allowPermission(auth.ObjectTypeStorageVolume, auth.EntitlementCanView, "pool", "type", "name")},
+	Post: APIEndpointAction{Handler: storagePoolVolumeSnapshotsTypePost, AccessHandler: allowPermission(auth.ObjectTypeStorageVolume, auth.EntitlementCanManageSnapshots, "pool", "type", "name")},
 }
 
 var storagePoolVolumeSnapshotTypeCmd = APIEndpoint{
 	Path: "storage-pools/{pool}/volumes/{type}/{name}/snapshots/{snapshotName}",
 
-	Delete: APIEndpointAction{Handler: storagePoolVolumeSnapshotTypeDelete, AccessHandler: allowProjectPermission("storage-volumes", "manage-storage-volumes")},
-	Get:    APIEndpointAction{Handler: storagePoolVolumeSnapshotTypeGet, AccessHandler: allowProjectPermission("storage-volumes", "view")},
-	Post:   APIEndpointAction{Handler: storagePoolVolumeSnapshotTypePost, AccessHandler: allowProjectPermission("storage-volumes", "manage-storage-volumes")},
-	Patch:  APIEndpointAction{Handler: storagePoolVolumeSnapshotTypePatch, AccessHandler: allowProjectPermission("storage-volumes", "manage-storage-volumes")},
-	Put:    APIEndpointAction{Handler: storagePoolVolumeSnapshotTypePut, AccessHandler: allowProjectPermission("storage-volumes", "manage-storage-volumes")},
+	Delete: APIEndpointAction{Handler: storagePoolVolumeSnapshotTypeDelete, AccessHandler: allowPermission(auth.ObjectTypeStorageVolume, auth.EntitlementCanManageSnapshots, "pool", "type", "name")},
+	Get:    APIEndpointAction{Handler: storagePoolVolumeSnapshotTypeGet, AccessHandler: allowPermission(auth.ObjectTypeStorageVolume, auth.EntitlementCanView, "pool", "type", "name")},
+	Post:   APIEndpointAction{Handler: storagePoolVolumeSnapshotTypePost, AccessHandler: allowPermission(auth.ObjectTypeStorageVolume, auth.EntitlementCanManageSnapshots, "pool", "type", "name")},
+	Patch:  APIEndpointAction{Handler: storagePoolVolumeSnapshotTypePatch, AccessHandler: allowPermission(auth.ObjectTypeStorageVolume, auth.EntitlementCanManageSnapshots, "pool", "type", "name")},
+	Put:    APIEndpointAction{Handler: storagePoolVolumeSnapshotTypePut, AccessHandler: allowPermission(auth.ObjectTypeStorageVolume, auth.EntitlementCanManageSnapshots, "pool", "type", "name")},
 }
 
 // swagger:operation POST /1.0/storage-pools/{name}/volumes/{type}/{volume}/snapshots storage storage_pool_volumes_type_snapshots_post
@@ -382,7 +383,7 @@ func storagePoolVolumeSnapshotsTypeGet(d *Daemon, r *http.Request) response.Resp
 			if err != nil {
 				return response.SmartError(err)
 			}
-			vol.UsedBy = project.FilterUsedBy(r, volumeUsedBy)
+			vol.UsedBy = project.FilterUsedBy(d.authorizer, r, volumeUsedBy)
 
 			tmp := &api.StorageVolumeSnapshot{}
 			tmp.Config = vol.Config
@@ -1101,67 +1102,14 @@ func autoCreateCustomVolumeSnapshotsTask(d *Daemon) (task.Func, task.Schedule) {
 		}
 
 		if len(remoteVolumes) > 0 {
-			// Get list of cluster members.
-			var nodeCount int
-			var onlineNodeIDs []int64
-			err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
-				// Get the offline threshold.
-				config, err := cluster.ConfigLoad(tx)
-				if err != nil {
-					return errors.Wrap(err, "Failed to load LXD config")
-				}
-
-				// Get all the members.
-				nodes, err := tx.GetNodes()
-				if err != nil {
-					return err
-				}
-
-				nodeCount = len(nodes)
-
-				// Filter to online members.
-				for _, node := range nodes {
-					if node.IsOffline(config.OfflineThreshold()) {
-						continue
-					}
-
-					onlineNodeIDs = append(onlineNodeIDs, node.ID)
-				}
-
-				return nil
-			})
+			remoteVolumes, err = customVolumesForLocalMember(d, remoteVolumes)
 			if err != nil {
-				logger.Error("Failed getting online cluster members for auto custom volume snapshot task", log.Ctx{"err": err})
-				return
+				logger.Error("Skipping remote volumes for auto custom volume snapshot task", log.Ctx{"err": err})
 			}
 
-			// Skip snapshotting remote custom volumes if there are no online members, as we can't be
-			// sure that the cluster isn't partitioned and we may end up attempting the snapshot on
-			// multiple members.
-			if nodeCount > 1 && len(onlineNodeIDs) <= 0 {
-				logger.Error("Skipping remote volumes for auto custom volume snapshot task due to no online members")
-			} else {
-				for _, v := range remoteVolumes {
-					// If there are multiple cluster members, a stable random member is chosen
-					// to perform the snapshot from. This avoids taking the snapshot on every
-					// member and spreads the load taking the snapshots across the online
-					// cluster members.
-					if nodeCount > 1 {
-						selectedNodeID, err := util.GetStableRandomInt64FromList(int64(v.ID), onlineNodeIDs)
-						if err != nil {
-							logger.Error("Failed scheduling remote auto custom volume snapshot task", log.Ctx{"vol": v.Name, "project": v.ProjectName, "pool": v.PoolName, "err": err})
-							continue
-						}
-
-						// Don't snapshot, if we're not the chosen one.
-						if d.cluster.GetNodeID() != selectedNodeID {
-							continue
-						}
-					}
-
-					logger.Debug("Scheduling remote auto custom volume snapshot", log.Ctx{"vol": v.Name, "project": v.ProjectName, "pool": v.PoolName})
-					volumes = append(volumes, v)
-				}
+			for _, v := range remoteVolumes {
+				logger.Debug("Scheduling remote auto custom volume snapshot", log.Ctx{"vol": v.Name, "project": v.ProjectName, "pool": v.PoolName})
+				volumes = append(volumes, v)
 			}
 		}
 
@@ -1205,6 +1153,74 @@ func autoCreateCustomVolumeSnapshotsTask(d *Daemon) (task.Func, task.Schedule) {
 	return f, schedule
 }
 
+// customVolumesForLocalMember returns the subset of the supplied remote custom volumes that should be handled by
+// the local member when running scheduled tasks.
+// If there are multiple cluster members, a stable random member is chosen for each volume. This avoids running
+// the task for the same volume on every member and spreads the load across the online cluster members.
+func customVolumesForLocalMember(d *Daemon, remoteVolumes []db.StorageVolumeArgs) ([]db.StorageVolumeArgs, error) {
+	// Get list of cluster members.
+	var nodeCount int
+	var onlineNodeIDs []int64
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		// Get the offline threshold.
+		config, err := cluster.ConfigLoad(tx)
+		if err != nil {
+			return errors.Wrap(err, "Failed to load LXD config")
+		}
+
+		// Get all the members.
+		nodes, err := tx.GetNodes()
+		if err != nil {
+			return err
+		}
+
+		nodeCount = len(nodes)
+
+		// Filter to online members.
+		for _, node := range nodes {
+			if node.IsOffline(config.OfflineThreshold()) {
+				continue
+			}
+
+			onlineNodeIDs = append(onlineNodeIDs, node.ID)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed getting online cluster members")
+	}
+
+	// Skip remote custom volumes if there are no online members, as we can't be sure that the cluster isn't
+	// partitioned and we may end up running the task on multiple members.
+	if nodeCount > 1 && len(onlineNodeIDs) <= 0 {
+		return nil, fmt.Errorf("No online cluster members")
+	}
+
+	if nodeCount <= 1 {
+		return remoteVolumes, nil
+	}
+
+	localNodeID := d.cluster.GetNodeID()
+	volumes := make([]db.StorageVolumeArgs, 0, len(remoteVolumes))
+	for _, v := range remoteVolumes {
+		selectedNodeID, err := util.GetStableRandomInt64FromList(int64(v.ID), onlineNodeIDs)
+		if err != nil {
+			logger.Error("Failed selecting cluster member for remote custom volume", log.Ctx{"vol": v.Name, "project": v.ProjectName, "pool": v.PoolName, "err": err})
+			continue
+		}
+
+		// Skip the volume, if we're not the chosen one.
+		if localNodeID != selectedNodeID {
+			continue
+		}
+
+		volumes = append(volumes, v)
+	}
+
+	return volumes, nil
+}
+
 func autoCreateCustomVolumeSnapshots(ctx context.Context, d *Daemon, volumes []db.StorageVolumeArgs) {
 	// Make the snapshots sequentially.
 	for _, v := range volumes {
@@ -0,0 +1,84 @@
+package api
+
+// AuthGroupsPost represents the fields of a new LXD authorization group
+//
+// swagger:model
+//
+// API extension: auth_groups
+type AuthGroupsPost struct {
+	AuthGroupPut `yaml:",inline"`
+
+	// The name of the group
+	// Example: operators
+	Name string `json:"name" yaml:"name"`
+}
+
+// AuthGroupPost represents the fields required to rename a LXD authorization group
+//
+// swagger:model
+//
+// API extension: auth_groups
+type AuthGroupPost struct {
+	// The new name of the group
+	// Example: admins
+	Name string `json:"name" yaml:"name"`
+}
+
+// AuthGroupPut represents the modifiable fields of a LXD authorization group
+//
+// swagger:model
+//
+// API extension: auth_groups
+type AuthGroupPut struct {
+	// Description of the group
+	// Example: Instance operators
+	Description string `json:"description" yaml:"description"`
+
+	// Permissions granted to the members of the group
+	Permissions []AuthPermission `json:"permissions" yaml:"permissions"`
+
+	// Fingerprints of the identities (trusted certificates) in the group
+	// Example: ["fd200419b271f1dc2a5591b693cc5774b7f234e1ff8c6b78ad703b6888fe2b69"]
+	Identities []string `json:"identities" yaml:"identities"`
+}
+
+// AuthGroup represents a LXD authorization group
+//
+// swagger:model
+//
+// API extension: auth_groups
+type AuthGroup struct {
+	AuthGroupPut `yaml:",inline"`
+
+	// The name of the group
+	// Example: operators
+	Name string `json:"name" yaml:"name"`
+}
+
+// Writable converts a full AuthGroup struct into a AuthGroupPut struct (filters read-only fields).
+func (g *AuthGroup) Writable() AuthGroupPut {
+	return g.AuthGroupPut
+}
+
+// AuthPermission represents an entitlement granted on an entity
+//
+// swagger:model
+//
+// API extension: auth_groups
+type AuthPermission struct {
+	// The entitlement granted on the entity
+	// Example: can_exec
+	Entitlement string `json:"entitlement" yaml:"entitlement"`
+
+	// The type of entity (server, project, instance, network or storage_volume)
+	// Example: instance
+	EntityType string `json:"entity_type" yaml:"entity_type"`
+
+	// The project of the entity (the project itself for project entities)
+	// Example: default
+	Project string `json:"project,omitempty" yaml:"project,omitempty"`
+
+	// The name of the entity (storage volumes use <pool>/<type>/<volume>)
+	// Example: c1
+	EntityName string `json:"entity_name,omitempty" yaml:"entity_name,omitempty"`
+}
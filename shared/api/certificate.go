@@ -9,6 +9,16 @@ const CertificateTypeServer = "server"
 // CertificateTypeMetrics indicates a metrics certificate type.
 const CertificateTypeMetrics = "metrics"
 
+// CertificateTypeOIDC indicates an OpenID Connect identity.
+//
+// API extension: oidc
+const CertificateTypeOIDC = "oidc"
+
+// CertificateTypeOIDCRevoked indicates a removed OpenID Connect identity.
+//
+// API extension: oidc
+const CertificateTypeOIDCRevoked = "oidc-revoked"
+
 // CertificateTypeUnknown indicates an unknown certificate type.
 const CertificateTypeUnknown = "unknown"
 
@@ -0,0 +1,38 @@
+package api
+
+// DevLXDPut represents the modifiable data of the devlxd API.
+//
+// API extension: devlxd_writable
+type DevLXDPut struct {
+	// Instance state as reported by the workload (Ready or Started)
+	// Example: Ready
+	State string `json:"state" yaml:"state"`
+}
+
+// DevLXDGet represents the server data exposed over the devlxd API.
+//
+// API extension: devlxd_writable
+type DevLXDGet struct {
+	DevLXDPut `yaml:",inline"`
+
+	// API version number
+	// Example: 1.0
+	APIVersion string `json:"api_version" yaml:"api_version"`
+
+	// What cluster member this instance is located on
+	// Example: lxd01
+	Location string `json:"location" yaml:"location"`
+
+	// Type of instance (container or virtual-machine)
+	// Example: container
+	InstanceType string `json:"instance_type,omitempty" yaml:"instance_type,omitempty"`
+}
+
+// DevLXDSnapshotsPost represents the fields available for a new snapshot requested over the devlxd API.
+//
+// API extension: devlxd_writable
+type DevLXDSnapshotsPost struct {
+	// Snapshot name (defaults to the instance's snapshots.pattern)
+	// Example: snap0
+	Name string `json:"name" yaml:"name"`
+}
@@ -33,6 +33,12 @@ type InstanceBackupsPost struct {
 	//
 	// API extension: backup_compression_algorithm
 	CompressionAlgorithm string `json:"compression_algorithm" yaml:"compression_algorithm"`
+
+	// Name of the parent backup to only include the changes made since (incremental backup)
+	// Example: backup0
+	//
+	// API extension: backup_incremental
+	Incremental string `json:"incremental" yaml:"incremental"`
 }
 
 // InstanceBackup represents a LXD instance backup.
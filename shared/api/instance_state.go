@@ -199,3 +199,16 @@ type InstanceStateNetworkCounters struct {
 	// Example: 179
 	PacketsDroppedInbound int64 `json:"packets_dropped_inbound" yaml:"packets_dropped_inbound"`
 }
+
+// InstanceFilesystemsFreezePut represents a request to the lxd-agent to freeze or thaw the guest filesystems.
+//
+// API extension: instance_snapshots_consistency
+type InstanceFilesystemsFreezePut struct {
+	// Whether the filesystems should be frozen (true) or thawed (false)
+	// Example: true
+	Frozen bool `json:"frozen" yaml:"frozen"`
+
+	// How long (in s) before the filesystems are automatically thawed again (0 to disable)
+	// Example: 300
+	Timeout int `json:"timeout" yaml:"timeout"`
+}
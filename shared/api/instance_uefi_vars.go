@@ -0,0 +1,35 @@
+package api
+
+// InstanceUEFIVars represents the UEFI variables of a LXD virtual machine.
+//
+// swagger:model
+//
+// API extension: instance_uefi_vars
+type InstanceUEFIVars struct {
+	// UEFI variables map, keyed by variable name and GUID (`<name>-<GUID>`)
+	// Example: {"SecureBootEnable-f0a30bc7-af08-4556-99c4-001009c93a44": {"data": "01", "attr": 3}}
+	Variables map[string]InstanceUEFIVariable `json:"variables" yaml:"variables"`
+}
+
+// InstanceUEFIVariable represents a single UEFI variable.
+//
+// swagger:model
+//
+// API extension: instance_uefi_vars
+type InstanceUEFIVariable struct {
+	// Hex-encoded variable data
+	// Example: 01
+	Data string `json:"data" yaml:"data"`
+
+	// Variable attributes
+	// Example: 3
+	Attr uint32 `json:"attr" yaml:"attr"`
+
+	// Hex-encoded timestamp (time based authenticated variables only)
+	// Example: 0000000000000000000000000000000000000000000000000000000000000000
+	Timestamp string `json:"timestamp,omitempty" yaml:"timestamp,omitempty"`
+
+	// Hex-encoded digest (authenticated variables only)
+	// Example: 0000000000000000000000000000000000000000000000000000000000000000
+	Digest string `json:"digest,omitempty" yaml:"digest,omitempty"`
+}
@@ -1,6 +1,9 @@
 package api
 
-import "strings"
+import (
+	"strings"
+	"time"
+)
 
 // NetworkACLRule represents a single rule in an ACL ruleset.
 // Refer to doc/network-acls.md for details.
@@ -149,3 +152,50 @@ type NetworkACLsPost struct {
 	NetworkACLPost `yaml:",inline"`
 	NetworkACLPut  `yaml:",inline"`
 }
+
+// NetworkACLLogEntry represents a traffic log entry matched by a logged network ACL rule
+//
+// swagger:model
+//
+// API extension: network_acl_log
+type NetworkACLLogEntry struct {
+	// Time at which the traffic was logged
+	// Example: 2021-11-22T21:59:48.373Z
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+
+	// Cluster member on which the traffic was logged
+	// Example: lxd01
+	Location string `json:"location" yaml:"location"`
+
+	// Direction of the rule that matched (ingress or egress)
+	// Example: ingress
+	Direction string `json:"direction" yaml:"direction"`
+
+	// Index of the rule that matched in the ACL rules for that direction
+	// Example: 0
+	Rule int `json:"rule" yaml:"rule"`
+
+	// Action of the rule that matched
+	// Example: drop
+	Action string `json:"action" yaml:"action"`
+
+	// Protocol of the traffic
+	// Example: tcp
+	Protocol string `json:"protocol" yaml:"protocol"`
+
+	// Source address of the traffic
+	// Example: 10.0.0.2
+	Source string `json:"source" yaml:"source"`
+
+	// Source port of the traffic
+	// Example: 41234
+	SourcePort string `json:"source_port,omitempty" yaml:"source_port,omitempty"`
+
+	// Destination address of the traffic
+	// Example: 10.0.0.3
+	Destination string `json:"destination" yaml:"destination"`
+
+	// Destination port of the traffic
+	// Example: 80
+	DestinationPort string `json:"destination_port,omitempty" yaml:"destination_port,omitempty"`
+}
@@ -0,0 +1,32 @@
+package api
+
+// NetworkAllocation represents an IP address or subnet allocated on a network
+//
+// swagger:model
+//
+// API extension: network_allocations
+type NetworkAllocation struct {
+	// The allocated address or subnet (CIDR)
+	// Example: 10.0.0.98/32
+	Address string `json:"address" yaml:"address"`
+
+	// Name of the network the address is allocated on
+	// Example: lxdbr0
+	Network string `json:"network" yaml:"network"`
+
+	// URL of the entity using the address
+	// Example: /1.0/instances/c1
+	UsedBy string `json:"used_by" yaml:"used_by"`
+
+	// Type of the entity using the address (instance, network, network-forward, network-load-balancer or uplink)
+	// Example: instance
+	Type string `json:"type" yaml:"type"`
+
+	// Whether the address is NATed
+	// Example: false
+	NAT bool `json:"nat" yaml:"nat"`
+
+	// The MAC address of the instance NIC using the address
+	// Example: 00:16:3e:2c:89:d9
+	Hwaddr string `json:"hwaddr,omitempty" yaml:"hwaddr,omitempty"`
+}
@@ -50,3 +50,76 @@ type NetworkZone struct {
 func (f *NetworkZone) Writable() NetworkZonePut {
 	return f.NetworkZonePut
 }
+
+// NetworkZoneRecordsPost represents the fields of a new LXD network zone record
+//
+// swagger:model
+//
+// API extension: network_zones_records
+type NetworkZoneRecordsPost struct {
+	NetworkZoneRecordPut `yaml:",inline"`
+
+	// The record name in the zone
+	// Example: @
+	Name string `json:"name" yaml:"name"`
+}
+
+// NetworkZoneRecordPut represents the modifiable fields of a LXD network zone record
+//
+// swagger:model
+//
+// API extension: network_zones_records
+type NetworkZoneRecordPut struct {
+	// Description of the record
+	// Example: SPF record
+	Description string `json:"description" yaml:"description"`
+
+	// Entries in the record
+	Entries []NetworkZoneRecordEntry `json:"entries" yaml:"entries"`
+
+	// Advanced configuration for the record
+	// Example: {"user.mykey": "foo"}
+	Config map[string]string `json:"config" yaml:"config"`
+}
+
+// NetworkZoneRecordEntry represents the fields in a record entry
+//
+// swagger:model
+//
+// API extension: network_zones_records
+type NetworkZoneRecordEntry struct {
+	// Type of DNS entry
+	// Example: TXT
+	Type string `json:"type" yaml:"type"`
+
+	// TTL for the entry
+	// Example: 3600
+	TTL uint64 `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+
+	// Value for the record
+	// Example: v=spf1 mx ~all
+	Value string `json:"value" yaml:"value"`
+}
+
+// NetworkZoneRecord represents a network zone (DNS) record.
+//
+// swagger:model
+//
+// API extension: network_zones_records
+type NetworkZoneRecord struct {
+	NetworkZoneRecordPut `yaml:",inline"`
+
+	// The name of the record
+	// Example: @
+	Name string `json:"name" yaml:"name"`
+}
+
+// Etag returns the values used for etag generation.
+func (f *NetworkZoneRecord) Etag() []interface{} {
+	return []interface{}{f.Name, f.Description, f.Entries, f.Config}
+}
+
+// Writable converts a full NetworkZoneRecord struct into a NetworkZoneRecordPut struct (filters read-only fields).
+func (f *NetworkZoneRecord) Writable() NetworkZoneRecordPut {
+	return f.NetworkZoneRecordPut
+}
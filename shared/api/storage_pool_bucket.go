@@ -0,0 +1,109 @@
+package api
+
+// StorageBucketsPost represents the fields of a new LXD storage pool bucket
+//
+// swagger:model
+//
+// API extension: storage_buckets
+type StorageBucketsPost struct {
+	StorageBucketPut `yaml:",inline"`
+
+	// Bucket name
+	// Example: foo
+	Name string `json:"name" yaml:"name"`
+}
+
+// StorageBucketPut represents the modifiable fields of a LXD storage pool bucket
+//
+// swagger:model
+//
+// API extension: storage_buckets
+type StorageBucketPut struct {
+	// Storage bucket configuration map
+	// Example: {"size": "50GiB"}
+	Config map[string]string `json:"config" yaml:"config"`
+
+	// Description of the storage bucket
+	// Example: My custom bucket
+	Description string `json:"description" yaml:"description"`
+}
+
+// StorageBucket represents the fields of a LXD storage pool bucket
+//
+// swagger:model
+//
+// API extension: storage_buckets
+type StorageBucket struct {
+	StorageBucketPut `yaml:",inline"`
+
+	// Bucket name
+	// Example: foo
+	Name string `json:"name" yaml:"name"`
+
+	// Bucket S3 URL
+	// Example: https://127.0.0.1:9000/default_foo
+	S3URL string `json:"s3_url" yaml:"s3_url"`
+
+	// What cluster member this record was found on
+	// Example: lxd01
+	Location string `json:"location" yaml:"location"`
+}
+
+// Writable converts a full StorageBucket struct into a StorageBucketPut struct (filters read-only fields).
+func (b *StorageBucket) Writable() StorageBucketPut {
+	return b.StorageBucketPut
+}
+
+// StorageBucketKeysPost represents the fields of a new LXD storage pool bucket key
+//
+// swagger:model
+//
+// API extension: storage_buckets
+type StorageBucketKeysPost struct {
+	StorageBucketKeyPut `yaml:",inline"`
+
+	// Key name
+	// Example: my-read-only-key
+	Name string `json:"name" yaml:"name"`
+}
+
+// StorageBucketKeyPut represents the modifiable fields of a LXD storage pool bucket key
+//
+// swagger:model
+//
+// API extension: storage_buckets
+type StorageBucketKeyPut struct {
+	// Description of the storage bucket key
+	// Example: My read-only bucket key
+	Description string `json:"description" yaml:"description"`
+
+	// Whether the key can perform write actions or not (either "read-only" or "admin")
+	// Example: read-only
+	Role string `json:"role" yaml:"role"`
+
+	// Access key (generated if not specified)
+	// Example: 33UgkaIBLBIxb7O1
+	AccessKey string `json:"access-key" yaml:"access-key"`
+
+	// Secret key (generated if not specified)
+	// Example: kDQD6AOgwHgaQI1UIJBJpPaiLgZuJbq0
+	SecretKey string `json:"secret-key" yaml:"secret-key"`
+}
+
+// StorageBucketKey represents the fields of a LXD storage pool bucket key
+//
+// swagger:model
+//
+// API extension: storage_buckets
+type StorageBucketKey struct {
+	StorageBucketKeyPut `yaml:",inline"`
+
+	// Key name
+	// Example: my-read-only-key
+	Name string `json:"name" yaml:"name"`
+}
+
+// Writable converts a full StorageBucketKey struct into a StorageBucketKeyPut struct (filters read-only fields).
+func (b *StorageBucketKey) Writable() StorageBucketKeyPut {
+	return b.StorageBucketKeyPut
+}
@@ -77,17 +77,27 @@ var HugePageSizeSuffix = [...]string{"64KB", "1MB", "2MB", "1GB"}
 
 // InstanceConfigKeysAny is a map of config key to validator. (keys applying to containers AND virtual machines)
 var InstanceConfigKeysAny = map[string]func(value string) error{
+	"backups.schedule": validate.Optional(validate.IsCron([]string{"@hourly", "@daily", "@midnight", "@weekly", "@monthly", "@annually", "@yearly"})),
+	"backups.pattern":  validate.IsAny,
+	"backups.expiry": func(value string) error {
+		// Validate expression
+		_, err := GetSnapshotExpiry(time.Time{}, value)
+		return err
+	},
+	"backups.keep": validate.Optional(validate.IsUint32),
+
 	"boot.autostart":             validate.Optional(validate.IsBool),
 	"boot.autostart.delay":       validate.Optional(validate.IsInt64),
 	"boot.autostart.priority":    validate.Optional(validate.IsInt64),
 	"boot.stop.priority":         validate.Optional(validate.IsInt64),
+	"boot.host_shutdown_action":  validate.Optional(validate.IsOneOf("stop", "stateful-stop")),
 	"boot.host_shutdown_timeout": validate.Optional(validate.IsInt64),
 
 	"cloud-init.network-config": validate.Optional(validate.IsAny),
 	"cloud-init.user-data":      validate.Optional(validate.IsAny),
 	"cloud-init.vendor-data":    validate.Optional(validate.IsAny),
 
-	"cluster.evacuate": validate.Optional(validate.IsOneOf("auto", "migrate", "stop")),
+	"cluster.evacuate": validate.Optional(validate.IsOneOf("auto", "migrate", "live-migrate", "stop")),
 
 	"limits.cpu": func(value string) error {
 		if value == "" {
@@ -148,6 +158,8 @@ var InstanceConfigKeysAny = map[string]func(value string) error{
 	"raw.apparmor": validate.IsAny,
 
 	"security.devlxd":            validate.Optional(validate.IsBool),
+	"security.devlxd.config":     validate.Optional(validate.IsBool),
+	"security.devlxd.snapshots":  validate.Optional(validate.IsBool),
 	"security.protection.delete": validate.Optional(validate.IsBool),
 
 	"snapshots.schedule":         validate.Optional(validate.IsCron([]string{"@hourly", "@daily", "@midnight", "@weekly", "@monthly", "@annually", "@yearly", "@startup"})),
@@ -160,17 +172,21 @@ var InstanceConfigKeysAny = map[string]func(value string) error{
 	},
 
 	// Volatile keys.
-	"volatile.apply_template":   validate.IsAny,
-	"volatile.base_image":       validate.IsAny,
-	"volatile.evacuate.origin":  validate.IsAny,
-	"volatile.last_state.idmap": validate.IsAny,
-	"volatile.last_state.power": validate.IsAny,
-	"volatile.idmap.base":       validate.IsAny,
-	"volatile.idmap.current":    validate.IsAny,
-	"volatile.idmap.next":       validate.IsAny,
-	"volatile.apply_quota":      validate.IsAny,
-	"volatile.uuid":             validate.Optional(validate.IsUUID),
-	"volatile.vsock_id":         validate.Optional(validate.IsInt64),
+	"volatile.apply_template":       validate.IsAny,
+	"volatile.base_image":           validate.IsAny,
+	"volatile.evacuate.origin":      validate.IsAny,
+	"volatile.last_state.idmap":     validate.IsAny,
+	"volatile.last_state.power":     validate.IsAny,
+	"volatile.last_state.ready":     validate.Optional(validate.IsBool),
+	"volatile.memory.base":          validate.Optional(validate.IsInt64),
+	"volatile.memory.hotplug":       validate.IsAny,
+	"volatile.idmap.base":           validate.IsAny,
+	"volatile.idmap.current":        validate.IsAny,
+	"volatile.idmap.next":           validate.IsAny,
+	"volatile.snapshot.consistency": validate.IsAny,
+	"volatile.apply_quota":          validate.IsAny,
+	"volatile.uuid":                 validate.Optional(validate.IsUUID),
+	"volatile.vsock_id":             validate.Optional(validate.IsInt64),
 
 	// Caller is responsible for full validation of any raw.* value.
 	"raw.idmap": validate.IsAny,
@@ -267,6 +283,7 @@ var InstanceConfigKeysContainer = map[string]func(value string) error{
 
 // InstanceConfigKeysVM is a map of config key to validator. (keys applying to VM only)
 var InstanceConfigKeysVM = map[string]func(value string) error{
+	"limits.cpu.hotplug":      validate.Optional(validate.IsBool),
 	"limits.memory.hugepages": validate.Optional(validate.IsBool),
 
 	"migration.stateful": validate.Optional(validate.IsBool),
@@ -275,6 +292,8 @@ var InstanceConfigKeysVM = map[string]func(value string) error{
 	"raw.qemu": validate.IsAny,
 
 	"security.secureboot": validate.Optional(validate.IsBool),
+
+	"snapshots.consistency": validate.Optional(validate.IsOneOf("crash", "filesystem")),
 }
 
 // ConfigKeyChecker returns a function that will check whether or not
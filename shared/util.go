@@ -36,6 +36,7 @@ const SnapshotDelimiter = "/"
 const HTTPSDefaultPort = 8443
 const HTTPDefaultPort = 8080
 const HTTPSMetricsDefaultPort = 9100
+const HTTPSStorageBucketsDefaultPort = 9000
 
 // URLEncode encodes a path and query parameters to a URL.
 func URLEncode(path string, query map[string]string) (string, error) {
@@ -299,6 +299,30 @@ var APIExtensions = []string{
 	"database_leader",
 	"instance_all_projects",
 	"clustering_groups",
+	"backup_schedule",
+	"backup_target",
+	"backup_incremental",
+	"storage_buckets",
+	"network_load_balancer",
+	"migration_vm_live",
+	"oidc",
+	"auth_groups",
+	"instances_placement_scriptlet",
+	"cluster_healing",
+	"vm_disk_hotplug",
+	"vm_cpu_memory_hotplug",
+	"vm_usb_pci_hotplug",
+	"disk_io_bus_cache_threads",
+	"custom_volume_iso",
+	"instance_host_shutdown_action",
+	"instance_uefi_vars",
+	"instance_snapshots_consistency",
+	"devlxd_writable",
+	"network_zones_records",
+	"network_dns_queries",
+	"network_acl_log",
+	"network_allocations",
+	"network_overlay",
 }
 
 // APIExtensionsCount returns the number of available API extensions.
@@ -6,12 +6,15 @@ package main
  */
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/gorilla/websocket"
 	"gopkg.in/yaml.v2"
@@ -71,6 +74,33 @@ func devlxdMonitor(c http.Client) {
 	}
 }
 
+func devlxdRequest(c http.Client, method string, path string, body io.Reader) {
+	req, err := http.NewRequest(method, fmt.Sprintf("http://meshuggah-rocks%s", path), body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	raw, err := c.Do(req)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	value, err := ioutil.ReadAll(raw.Body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if raw.StatusCode != http.StatusOK {
+		fmt.Println("http error", raw.StatusCode, strings.TrimSpace(string(value)))
+		os.Exit(1)
+	}
+
+	fmt.Println(string(value))
+}
+
 func main() {
 	c := http.Client{Transport: devLxdTransport}
 	raw, err := c.Get("http://meshuggah-rocks/")
@@ -101,9 +131,27 @@ func main() {
 	}
 
 	if len(os.Args) > 1 {
-		if os.Args[1] == "monitor" {
+		switch os.Args[1] {
+		case "monitor":
 			devlxdMonitor(c)
 			os.Exit(0)
+		case "state":
+			devlxdRequest(c, "PATCH", "/1.0", bytes.NewBufferString(fmt.Sprintf(`{"state": %q}`, os.Args[2])))
+			os.Exit(0)
+		case "set":
+			devlxdRequest(c, "PUT", fmt.Sprintf("/1.0/config/%s", os.Args[2]), bytes.NewBufferString(os.Args[3]))
+			os.Exit(0)
+		case "unset":
+			devlxdRequest(c, "DELETE", fmt.Sprintf("/1.0/config/%s", os.Args[2]), nil)
+			os.Exit(0)
+		case "snapshot":
+			name := ""
+			if len(os.Args) > 2 {
+				name = os.Args[2]
+			}
+
+			devlxdRequest(c, "POST", "/1.0/snapshots", bytes.NewBufferString(fmt.Sprintf(`{"name": %q}`, name)))
+			os.Exit(0)
 		}
 
 		raw, err := c.Get(fmt.Sprintf("http://meshuggah-rocks/1.0/config/%s", os.Args[1]))
@@ -162,6 +162,8 @@ if [ "${1:-"all"}" != "cluster" ]; then
     run_test test_sql "lxd sql"
     run_test test_tls_restrictions "TLS restrictions"
     run_test test_certificate_edit "Certificate edit"
+    run_test test_oidc "OpenID Connect"
+    run_test test_authorization "authorization groups"
     run_test test_basic_usage "basic usage"
     run_test test_remote_url "remote url handling"
     run_test test_remote_admin "remote administration"
@@ -196,6 +198,10 @@ if [ "${1:-"all"}" != "standalone" ]; then
     run_test test_clustering_autotarget "clustering autotarget member"
     # run_test test_clustering_upgrade "clustering upgrade"
     run_test test_clustering_groups "clustering groups"
+    run_test test_clustering_placement_scriptlet "clustering instance placement scriptlet"
+    run_test test_clustering_healing "clustering healing"
+    run_test test_clustering_vm_live_migration "clustering VM live migration"
+    run_test test_clustering_overlay "clustering overlay network"
 fi
 
 if [ "${1:-"all"}" != "cluster" ]; then
@@ -256,6 +262,7 @@ if [ "${1:-"all"}" != "cluster" ]; then
     run_test test_network "network management"
     run_test test_network_acl "network ACL management"
     run_test test_network_forward "network address forwards"
+    run_test test_network_load_balancer "network load balancers"
     run_test test_network_zone "network DNS zones"
     run_test test_idmap "id mapping"
     run_test test_template "file templating"
@@ -266,12 +273,14 @@ if [ "${1:-"all"}" != "cluster" ]; then
     run_test test_fdleak "fd leak"
     run_test test_storage "storage"
     run_test test_storage_volume_snapshots "storage volume snapshots"
+    run_test test_storage_buckets "storage buckets"
     run_test test_init_auto "lxd init auto"
     run_test test_init_interactive "lxd init interactive"
     run_test test_init_preseed "lxd init preseed"
     run_test test_storage_profiles "storage profiles"
     run_test test_container_recover "container recover"
     run_test test_storage_volume_attach "attaching storage volumes"
+    run_test test_storage_volume_iso "ISO storage volumes"
     run_test test_storage_driver_btrfs "btrfs storage driver"
     run_test test_storage_driver_ceph "ceph storage driver"
     run_test test_storage_driver_cephfs "cephfs storage driver"
@@ -279,6 +288,7 @@ if [ "${1:-"all"}" != "cluster" ]; then
     run_test test_kernel_limits "kernel limits"
     run_test test_macaroon_auth "macaroon authentication"
     run_test test_console "console"
+    run_test test_uefi_vars "UEFI variables"
     run_test test_query "query"
     run_test test_storage_local_volume_handling "storage local volume handling"
     run_test test_backup_import "backup import"
@@ -286,6 +296,9 @@ if [ "${1:-"all"}" != "cluster" ]; then
     run_test test_backup_rename "backup rename"
     run_test test_backup_volume_export "backup volume export"
     run_test test_backup_volume_rename_delete "backup volume rename and delete"
+    run_test test_backup_schedule "backup scheduling"
+    run_test test_backup_target "backup target"
+    run_test test_backup_incremental "incremental backups"
     run_test test_container_local_cross_pool_handling "container local cross pool handling"
     run_test test_incremental_copy "incremental container copy"
     run_test test_profiles_project_default "profiles in default project"
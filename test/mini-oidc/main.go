@@ -0,0 +1,204 @@
+// Package main implements a minimal OpenID Connect issuer for testing.
+//
+// It supports the device authorization and refresh token grants and
+// automatically approves all device logins for the user named in the
+// user file.
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type issuer struct {
+	url      string
+	userFile string
+	expiry   time.Duration
+	key      *rsa.PrivateKey
+
+	mu            sync.Mutex
+	deviceCodes   map[string]string // device code -> audience
+	refreshTokens map[string][2]string
+}
+
+func main() {
+	endpoint := flag.String("endpoint", "127.0.0.1:8082", "address to listen on")
+	userFile := flag.String("user-file", "user.data", "file containing the name of the user to log in as")
+	expiry := flag.Duration("expiry", 5*time.Minute, "access token lifetime")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	i := &issuer{
+		url:           "http://" + *endpoint,
+		userFile:      *userFile,
+		expiry:        *expiry,
+		key:           key,
+		deviceCodes:   map[string]string{},
+		refreshTokens: map[string][2]string{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", i.discovery)
+	mux.HandleFunc("/jwks", i.jwks)
+	mux.HandleFunc("/device", i.device)
+	mux.HandleFunc("/token", i.token)
+
+	log.Fatal(http.ListenAndServe(*endpoint, mux))
+}
+
+func (i *issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                        i.url,
+		"jwks_uri":                      i.url + "/jwks",
+		"device_authorization_endpoint": i.url + "/device",
+		"token_endpoint":                i.url + "/token",
+		"grant_types_supported":         []string{"urn:ietf:params:oauth:grant-type:device_code", "refresh_token"},
+	})
+}
+
+func (i *issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": "test",
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (i *issuer) device(w http.ResponseWriter, r *http.Request) {
+	audience := r.FormValue("audience")
+	if audience == "" {
+		audience = r.FormValue("client_id")
+	}
+
+	code := randomString()
+
+	i.mu.Lock()
+	i.deviceCodes[code] = audience
+	i.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"device_code":               code,
+		"user_code":                 strings.ToUpper(code[:8]),
+		"verification_uri":          i.url + "/device/verify",
+		"verification_uri_complete": i.url + "/device/verify?code=" + code[:8],
+		"expires_in":                300,
+		"interval":                  1,
+	})
+}
+
+func (i *issuer) token(w http.ResponseWriter, r *http.Request) {
+	var user, audience string
+
+	i.mu.Lock()
+	switch r.FormValue("grant_type") {
+	case "urn:ietf:params:oauth:grant-type:device_code":
+		var ok bool
+		audience, ok = i.deviceCodes[r.FormValue("device_code")]
+		if ok {
+			delete(i.deviceCodes, r.FormValue("device_code"))
+
+			// Auto-approve the login for the configured user.
+			data, err := ioutil.ReadFile(i.userFile)
+			if err == nil {
+				user = strings.TrimSpace(string(data))
+			}
+		}
+	case "refresh_token":
+		entry, ok := i.refreshTokens[r.FormValue("refresh_token")]
+		if ok {
+			delete(i.refreshTokens, r.FormValue("refresh_token"))
+			user, audience = entry[0], entry[1]
+		}
+	}
+	i.mu.Unlock()
+
+	if user == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "access_denied"})
+		return
+	}
+
+	now := time.Now()
+	accessToken, err := i.sign(map[string]interface{}{
+		"iss":   i.url,
+		"sub":   user,
+		"aud":   audience,
+		"email": user + "@example.com",
+		"iat":   now.Unix(),
+		"exp":   now.Add(i.expiry).Unix(),
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	refreshToken := randomString()
+
+	i.mu.Lock()
+	i.refreshTokens[refreshToken] = [2]string{user, audience}
+	i.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    int64(i.expiry.Seconds()),
+	})
+}
+
+// sign returns an RS256 signed JWT for the given claims.
+func (i *issuer) sign(claims map[string]interface{}) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, i.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func randomString() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
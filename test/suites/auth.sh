@@ -0,0 +1,81 @@
+test_authorization() {
+  ensure_import_testimage
+  ensure_has_localhost_remote "${LXD_ADDR}"
+
+  FINGERPRINT=$(lxc config trust list --format csv | cut -d, -f4)
+
+  lxc init testimage c1
+  lxc init testimage c2
+
+  # Group management
+  lxc auth group create operators --description "Instance operators"
+  lxc auth group list | grep -q operators
+  lxc auth group show operators | grep -q "description: Instance operators"
+  ! lxc auth group create operators || false
+  ! lxc auth group permission add operators instance c1 not_an_entitlement || false
+  ! lxc auth group permission add operators project can_exec || false
+  ! lxc auth group permission add operators storage_volume foo can_view || false
+  ! lxc auth group identity add operators 0000000000000000 || false
+
+  lxc auth group permission add operators instance c1 can_view
+  lxc auth group permission add operators instance c1 can_update_state
+  ! lxc auth group permission add operators instance c1 can_view || false
+  lxc auth group identity add operators "${FINGERPRINT}"
+  lxc auth group show operators | grep -q "${FINGERPRINT}"
+
+  # Apply restrictions with no project access
+  lxc config trust show "${FINGERPRINT}" | sed -e "s/restricted: false/restricted: true/" | lxc config trust edit "${FINGERPRINT}"
+
+  # Only the instances granted through the group are accessible
+  lxc_remote info localhost:c1 | grep -q "Name: c1"
+  ! lxc_remote info localhost:c2 || false
+  ! lxc_remote config set localhost:c1 user.foo bar || false
+  ! lxc_remote auth group list localhost: || false
+  lxc_remote start localhost:c1
+  lxc_remote stop localhost:c1 --force
+
+  # Permission changes apply to the next request
+  lxc auth group permission remove operators instance c1 can_update_state
+  ! lxc_remote start localhost:c1 || false
+  lxc auth group permission add operators instance c1 can_edit
+  lxc_remote config set localhost:c1 user.foo bar
+
+  # Server administrators have full access
+  lxc auth group permission add operators server admin
+  lxc_remote info localhost:c2 | grep -q "Name: c2"
+  lxc_remote auth group list localhost: | grep -q operators
+
+  # Removing the identity revokes the permissions
+  lxc auth group identity remove operators "${FINGERPRINT}"
+  ! lxc_remote info localhost:c1 || false
+
+  # Permissions follow renamed entities and are removed along with them
+  pool=$(lxc profile device get default root pool)
+  lxc network create lxdt$$ ipv4.address=none ipv6.address=none
+  lxc storage volume create "${pool}" vol1
+  lxc auth group permission add operators network "lxdt$$" can_view
+  lxc auth group permission add operators storage_volume "${pool}/custom/vol1" can_view
+  lxc move c1 c3
+  lxc network rename "lxdt$$" "lxdu$$"
+  lxc storage volume rename "${pool}" vol1 vol2
+  lxc auth group show operators | grep -q "entity_name: c3"
+  lxc auth group show operators | grep -q "entity_name: lxdu$$"
+  lxc auth group show operators | grep -q "entity_name: ${pool}/custom/vol2"
+  ! lxc auth group show operators | grep -q "entity_name: c1" || false
+  lxc delete c3
+  lxc network delete "lxdu$$"
+  lxc storage volume delete "${pool}" vol2
+  ! lxc auth group show operators | grep -q "entity_name:" || false
+  lxc auth group show operators | grep -q "entitlement: admin"
+
+  # Rename and delete
+  lxc auth group rename operators ops
+  lxc auth group show ops
+  ! lxc auth group show operators || false
+  lxc auth group delete ops
+  ! lxc auth group list | grep -q ops || false
+
+  # Cleanup
+  lxc config trust show "${FINGERPRINT}" | sed -e "s/restricted: true/restricted: false/" | lxc config trust edit "${FINGERPRINT}"
+  lxc delete c2
+}
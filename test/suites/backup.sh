@@ -752,3 +752,187 @@ test_backup_volume_rename_delete() {
   lxc storage volume rm "${pool}" vol2
   ! stat "${LXD_DIR}"/backups/custom/"${pool}"/default_vol2 || false
 }
+
+test_backup_schedule() {
+  ensure_import_testimage
+  ensure_has_localhost_remote "${LXD_ADDR}"
+
+  pool="lxdtest-$(basename "${LXD_DIR}")"
+
+  # Check key validation.
+  lxc init testimage c1
+  ! lxc config set c1 backups.schedule="foo" || false
+  ! lxc config set c1 backups.expiry="foo" || false
+  ! lxc config set c1 backups.keep="-1" || false
+  lxc profile set default backups.keep=2
+  lxc profile unset default backups.keep
+
+  lxc storage volume create "${pool}" vol1
+  ! lxc storage volume set "${pool}" vol1 backups.schedule="foo" || false
+  ! lxc storage volume set "${pool}" vol1 backups.keep="foo" || false
+
+  # Create some manual backups which should be subject to the retention limit.
+  lxc query -X POST --wait -d '{\"name\":\"foo\"}' /1.0/instances/c1/backups
+  lxc query -X POST --wait -d '{\"name\":\"bar\"}' /1.0/instances/c1/backups
+  lxc query -X POST --wait -d '{\"name\":\"foo\"}' /1.0/storage-pools/"${pool}"/volumes/custom/vol1/backups
+  lxc query -X POST --wait -d '{\"name\":\"bar\"}' /1.0/storage-pools/"${pool}"/volumes/custom/vol1/backups
+
+  # Schedule backups every minute, keeping only the latest.
+  lxc config set c1 backups.schedule="* * * * *" backups.pattern="auto%d" backups.keep=1
+  lxc storage volume set "${pool}" vol1 backups.schedule="* * * * *" backups.pattern="auto%d" backups.keep=1
+
+  # Wait for the scheduled backups to be taken.
+  for _ in $(seq 90); do
+    if lxc query /1.0/instances/c1/backups | grep -q "backups/auto0" && lxc query /1.0/storage-pools/"${pool}"/volumes/custom/vol1/backups | grep -q "backups/auto0"; then
+      break
+    fi
+
+    sleep 1
+  done
+
+  lxc config unset c1 backups.schedule
+  lxc storage volume unset "${pool}" vol1 backups.schedule
+
+  # Only the scheduled backup should remain.
+  lxc query /1.0/instances/c1/backups | jq -r '.[]' | grep -c backups/ | grep -qx 1
+  lxc query /1.0/instances/c1/backups | grep -q "backups/auto0"
+  stat "${LXD_DIR}"/backups/instances/c1/auto0
+  ! stat "${LXD_DIR}"/backups/instances/c1/foo || false
+  lxc query /1.0/storage-pools/"${pool}"/volumes/custom/vol1/backups | jq -r '.[]' | grep -c backups/ | grep -qx 1
+  lxc query /1.0/storage-pools/"${pool}"/volumes/custom/vol1/backups | grep -q "backups/auto0"
+  stat "${LXD_DIR}"/backups/custom/"${pool}"/default_vol1/auto0
+
+  lxc delete c1
+  lxc storage volume delete "${pool}" vol1
+}
+
+test_backup_target() {
+  ensure_import_testimage
+  ensure_has_localhost_remote "${LXD_ADDR}"
+
+  pool="lxdtest-$(basename "${LXD_DIR}")"
+  target_dir="${TEST_DIR}/backups-target"
+  mkdir -p "${target_dir}"
+
+  # Check key validation.
+  ! lxc config set backups.target="relative/path" || false
+  ! lxc config set backups.target="ftp://example.com/bucket" || false
+  ! lxc project set default backups.target="${target_dir}" || false
+  lxc config set backups.target="${target_dir}"
+
+  # The target credentials of projects aren't returned by the API.
+  lxc project set default backups.target.access_key=foo backups.target.secret_key=bar
+  lxc project get default backups.target.secret_key | grep -qx true
+  ! lxc query /1.0/projects?recursion=1 | grep -q '"bar"' || false
+  lxc project show default | sed 's/description: .*/description: test/' | lxc project edit default
+  lxc project get default backups.target.secret_key | grep -qx true
+  lxd sql global "SELECT value FROM projects_config WHERE key='backups.target.secret_key'" | grep -q "| bar "
+  lxc project unset default backups.target.access_key
+  lxc project unset default backups.target.secret_key
+
+  # Backups get stored on the target.
+  lxc init testimage c1
+  lxc query -X POST --wait -d '{\"name\":\"foo\"}' /1.0/instances/c1/backups
+  stat "${target_dir}"/default/instances/c1/foo
+
+  lxc storage volume create "${pool}" vol1
+  lxc query -X POST --wait -d '{\"name\":\"foo\"}' /1.0/storage-pools/"${pool}"/volumes/custom/vol1/backups
+  stat "${target_dir}"/default/custom/"${pool}"/vol1/foo
+
+  # Renames and deletes are applied to the target.
+  lxc query -X POST -d '{\"name\":\"bar\"}' /1.0/instances/c1/backups/foo
+  ! stat "${target_dir}"/default/instances/c1/foo || false
+  stat "${target_dir}"/default/instances/c1/bar
+
+  lxc query -X DELETE --wait /1.0/storage-pools/"${pool}"/volumes/custom/vol1/backups/foo
+  ! stat "${target_dir}"/default/custom/"${pool}"/vol1/foo || false
+
+  # Backups only held on the target are still listed and exported.
+  rm "${LXD_DIR}"/backups/instances/c1/bar
+  my_curl -f -X GET "https://${LXD_ADDR}/1.0/instances/c1/backups/bar/export" > "${LXD_DIR}/c1-bar.tar.gz"
+  tar -tzf "${LXD_DIR}/c1-bar.tar.gz" | grep -q "backup/index.yaml"
+  rm "${LXD_DIR}/c1-bar.tar.gz"
+
+  lxc delete c1
+  lxc query /1.0/instances/c1/backups | grep -q "backups/bar"
+  stat "${target_dir}"/default/instances/c1/bar
+
+  # Restore directly from the target.
+  lxc import c1/bar c2 --from-target
+  lxc info c2 | grep -q "Name: c2"
+  ! lxc import c1/missing c3 --from-target || false
+
+  lxc delete c2
+  rm -f "${target_dir}"/default/instances/c1/bar
+  lxc config unset backups.target
+  lxc storage volume delete "${pool}" vol1
+  rm -rf "${target_dir}"
+}
+
+test_backup_incremental() {
+  ensure_import_testimage
+  ensure_has_localhost_remote "${LXD_ADDR}"
+
+  lxd_backend=$(storage_backend "$LXD_DIR")
+
+  lxc init testimage c1
+  echo foo | lxc file push - c1/root/deleted
+  lxc snapshot c1 snap0
+  lxc query -X POST --wait -d '{\"name\":\"base\"}' /1.0/instances/c1/backups
+
+  # Check parent validation.
+  ! lxc query -X POST --wait -d '{\"name\":\"inc0\",\"incremental\":\"missing\"}' /1.0/instances/c1/backups || false
+  ! lxc query -X POST --wait -d '{\"name\":\"inc0\",\"incremental\":\"inc0\"}' /1.0/instances/c1/backups || false
+
+  # Make some changes and take incremental backups on top of each other.
+  echo bar | lxc file push - c1/root/added
+  lxc file delete c1/root/deleted
+  lxc snapshot c1 snap1
+  lxc query -X POST --wait -d '{\"name\":\"inc1\",\"incremental\":\"base\"}' /1.0/instances/c1/backups
+  echo baz | lxc file push - c1/root/added
+  lxc query -X POST --wait -d '{\"name\":\"inc2\",\"incremental\":\"inc1\"}' /1.0/instances/c1/backups
+
+  # Incremental backups record their parents and skip the snapshots they already include.
+  tar -xzOf "${LXD_DIR}"/backups/instances/c1/inc2 backup/index.yaml | grep -A2 "^parents:" | grep -q "inc1"
+  tar -xzOf "${LXD_DIR}"/backups/instances/c1/inc2 backup/index.yaml | grep -A2 "^parents:" | grep -q "base"
+  ! tar -tzf "${LXD_DIR}"/backups/instances/c1/inc1 | grep -q "backup/snapshots/snap0" || false
+  tar -tzf "${LXD_DIR}"/backups/instances/c1/inc1 | grep -q "backup/snapshots/snap1"
+  ! tar -tzf "${LXD_DIR}"/backups/instances/c1/inc2 | grep -q "backup/snapshots/" || false
+
+  # Restoring replays the whole chain.
+  my_curl -f -X GET "https://${LXD_ADDR}/1.0/instances/c1/backups/inc2/export" > "${LXD_DIR}/c1-inc2.tar.gz"
+  lxc import "${LXD_DIR}/c1-inc2.tar.gz" c2
+  lxc info c2 | grep -q snap0
+  lxc info c2 | grep -q snap1
+  [ "$(lxc file pull c2/root/added -)" = "baz" ]
+  ! lxc file pull c2/root/deleted - || false
+  lxc delete c2
+  rm "${LXD_DIR}/c1-inc2.tar.gz"
+
+  # Parent backups can't be deleted or renamed while incremental backups are based on them.
+  ! lxc query -X DELETE --wait /1.0/instances/c1/backups/inc1 || false
+  ! lxc query -X DELETE --wait /1.0/instances/c1/backups/base || false
+  ! lxc query -X POST --wait -d '{\"name\":\"inc1-renamed\"}' /1.0/instances/c1/backups/inc1 || false
+  [ -f "${LXD_DIR}"/backups/instances/c1/inc1 ]
+  lxc query -X POST --wait -d '{\"name\":\"inc2-renamed\"}' /1.0/instances/c1/backups/inc2
+  lxc query -X DELETE --wait /1.0/instances/c1/backups/inc2-renamed
+  lxc query -X DELETE --wait /1.0/instances/c1/backups/inc1
+
+  if [ "$lxd_backend" = "btrfs" ] || [ "$lxd_backend" = "zfs" ]; then
+    lxc query -X POST --wait -d '{\"name\":\"obase\",\"optimized_storage\":true}' /1.0/instances/c1/backups
+    echo qux | lxc file push - c1/root/added
+    lxc snapshot c1 snap2
+    lxc query -X POST --wait -d '{\"name\":\"oinc1\",\"incremental\":\"obase\"}' /1.0/instances/c1/backups
+    tar -tzf "${LXD_DIR}"/backups/instances/c1/oinc1 | grep -q "backup/snapshots/snap2.bin"
+    ! tar -tzf "${LXD_DIR}"/backups/instances/c1/oinc1 | grep -q "backup/snapshots/snap1.bin" || false
+
+    my_curl -f -X GET "https://${LXD_ADDR}/1.0/instances/c1/backups/oinc1/export" > "${LXD_DIR}/c1-oinc1.tar.gz"
+    lxc import "${LXD_DIR}/c1-oinc1.tar.gz" c2
+    lxc info c2 | grep -q snap2
+    [ "$(lxc file pull c2/root/added -)" = "qux" ]
+    lxc delete c2
+    rm "${LXD_DIR}/c1-oinc1.tar.gz"
+  fi
+
+  lxc delete c1
+}
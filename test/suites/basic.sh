@@ -477,6 +477,11 @@ test_basic_usage() {
   [ "$(lxc config get configtest boot.host_shutdown_timeout)" -eq 45 ]
   lxc config set configtest boot.host_shutdown_timeout 15
   [ "$(lxc config get configtest boot.host_shutdown_timeout)" -eq 15 ]
+
+  # Test boot.host_shutdown_action config setting
+  lxc config set configtest boot.host_shutdown_action stateful-stop
+  [ "$(lxc config get configtest boot.host_shutdown_action)" = "stateful-stop" ]
+  ! lxc config set configtest boot.host_shutdown_action suspend || false
   lxc delete configtest
 
   # Test deleting multiple images
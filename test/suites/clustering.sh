@@ -3256,3 +3256,343 @@ test_clustering_groups() {
 
   lxc remote rm cluster
 }
+
+test_clustering_placement_scriptlet() {
+  # shellcheck disable=2039
+  local LXD_DIR
+
+  setup_clustering_bridge
+  prefix="lxd$$"
+  bridge="${prefix}"
+
+  setup_clustering_netns 1
+  LXD_ONE_DIR=$(mktemp -d -p "${TEST_DIR}" XXX)
+  chmod +x "${LXD_ONE_DIR}"
+  ns1="${prefix}1"
+  spawn_lxd_and_bootstrap_cluster "${ns1}" "${bridge}" "${LXD_ONE_DIR}"
+
+  # Add a newline at the end of each line. YAML as weird rules..
+  cert=$(sed ':a;N;$!ba;s/\n/\n\n/g' "${LXD_ONE_DIR}/cluster.crt")
+
+  # Spawn a second node
+  setup_clustering_netns 2
+  LXD_TWO_DIR=$(mktemp -d -p "${TEST_DIR}" XXX)
+  chmod +x "${LXD_TWO_DIR}"
+  ns2="${prefix}2"
+  spawn_lxd_and_join_cluster "${ns2}" "${bridge}" "${cert}" 2 1 "${LXD_TWO_DIR}"
+
+  # Use node1 for all cluster actions.
+  LXD_DIR="${LXD_ONE_DIR}"
+
+  ensure_import_testimage
+
+  # Invalid scriptlets are rejected.
+  ! lxc config set instances.placement.scriptlet "def instance_placement(" || false
+  ! lxc config set instances.placement.scriptlet "def other(request, candidate_members): pass" || false
+
+  # Place everything on node2 unless the instance asks to be rejected.
+  lxc config set instances.placement.scriptlet "$(cat << EOF
+def instance_placement(request, candidate_members):
+    log_info("Placing", request["name"], "in project", request["project"])
+
+    if request["config"].get("user.reject") == "true":
+        fail("Instance rejected")
+
+    for member in candidate_members:
+        resources = get_cluster_member_resources(member["server_name"])
+        if member["server_name"] == "node2" and resources["cpu"]["total"] > 0:
+            set_target(member["server_name"])
+EOF
+)"
+
+  lxc init testimage c1
+  lxc init testimage c2
+  lxc ls | grep c1 | grep -q node2
+  lxc ls | grep c2 | grep -q node2
+  ! lxc init testimage c3 -c user.reject=true || false
+
+  # An explicit target bypasses the scriptlet.
+  lxc init testimage c3 --target node1
+  lxc ls | grep c3 | grep -q node1
+
+  # Without a scriptlet, the least busy member is used.
+  lxc config unset instances.placement.scriptlet
+  lxc init testimage c4
+  lxc ls | grep c4 | grep -q node1
+
+  lxc delete c1 c2 c3 c4
+  lxc image delete testimage
+
+  shutdown_lxd "${LXD_ONE_DIR}"
+  shutdown_lxd "${LXD_TWO_DIR}"
+  sleep 0.5
+  rm -f "${LXD_TWO_DIR}/unix.socket"
+  rm -f "${LXD_ONE_DIR}/unix.socket"
+
+  teardown_clustering_netns
+  teardown_clustering_bridge
+
+  kill_lxd "${LXD_ONE_DIR}"
+  kill_lxd "${LXD_TWO_DIR}"
+}
+
+test_clustering_healing() {
+  # shellcheck disable=2039
+  local LXD_DIR
+
+  setup_clustering_bridge
+  prefix="lxd$$"
+  bridge="${prefix}"
+
+  # The random storage backend is not supported in clustering tests,
+  # since we need to have the same storage driver on all nodes.
+  driver="${LXD_BACKEND}"
+  if [ "${driver}" = "random" ] || [ "${driver}" = "lvm" ]; then
+    driver="dir"
+  fi
+
+  # Spawn first node
+  setup_clustering_netns 1
+  LXD_ONE_DIR=$(mktemp -d -p "${TEST_DIR}" XXX)
+  chmod +x "${LXD_ONE_DIR}"
+  ns1="${prefix}1"
+  spawn_lxd_and_bootstrap_cluster "${ns1}" "${bridge}" "${LXD_ONE_DIR}" "${driver}"
+
+  # Add a newline at the end of each line. YAML as weird rules..
+  cert=$(sed ':a;N;$!ba;s/\n/\n\n/g' "${LXD_ONE_DIR}/cluster.crt")
+
+  # Spawn a second node
+  setup_clustering_netns 2
+  LXD_TWO_DIR=$(mktemp -d -p "${TEST_DIR}" XXX)
+  chmod +x "${LXD_TWO_DIR}"
+  ns2="${prefix}2"
+  spawn_lxd_and_join_cluster "${ns2}" "${bridge}" "${cert}" 2 1 "${LXD_TWO_DIR}" "${driver}"
+
+  # Spawn a third node
+  setup_clustering_netns 3
+  LXD_THREE_DIR=$(mktemp -d -p "${TEST_DIR}" XXX)
+  chmod +x "${LXD_THREE_DIR}"
+  ns3="${prefix}3"
+  spawn_lxd_and_join_cluster "${ns3}" "${bridge}" "${cert}" 3 1 "${LXD_THREE_DIR}" "${driver}"
+
+  LXD_DIR="${LXD_ONE_DIR}" ensure_import_testimage
+  LXD_DIR="${LXD_ONE_DIR}" lxc launch testimage c1 --target=node3
+  LXD_DIR="${LXD_ONE_DIR}" lxc init testimage c2 --target=node3
+  LXD_DIR="${LXD_ONE_DIR}" lxc launch testimage c3 --target=node3 -c cluster.evacuate=stop
+
+  # Invalid thresholds are rejected.
+  ! LXD_DIR="${LXD_ONE_DIR}" lxc config set cluster.healing_threshold -1 || false
+  ! LXD_DIR="${LXD_ONE_DIR}" lxc config set cluster.healing_threshold foo || false
+
+  # Kill the third node and wait for it to be healed.
+  LXD_DIR="${LXD_ONE_DIR}" lxc config set cluster.offline_threshold 11
+  LXD_DIR="${LXD_ONE_DIR}" lxc config set cluster.healing_threshold 11
+  kill -9 "$(cat "${LXD_THREE_DIR}/lxd.pid")"
+  sleep 30
+
+  LXD_DIR="${LXD_ONE_DIR}" lxc cluster list
+  LXD_DIR="${LXD_ONE_DIR}" lxc cluster show node3 | grep -q "status: Evacuated"
+  LXD_DIR="${LXD_ONE_DIR}" lxc warning list | grep -q "Offline cluster member evacuated"
+
+  if [ "${driver}" = "ceph" ]; then
+    # Ceph-backed instances are moved and restarted if they were running.
+    ! LXD_DIR="${LXD_ONE_DIR}" lxc info c1 | grep -q "Location: node3" || false
+    LXD_DIR="${LXD_ONE_DIR}" lxc info c1 | grep -q "Status: RUNNING"
+    ! LXD_DIR="${LXD_ONE_DIR}" lxc info c2 | grep -q "Location: node3" || false
+    LXD_DIR="${LXD_ONE_DIR}" lxc info c2 | grep -q "Status: STOPPED"
+
+    # Instances which can't be migrated (like with cluster.evacuate=stop) are left on their member.
+    LXD_DIR="${LXD_ONE_DIR}" lxc list -c nL --format csv | grep -q "c3,node3"
+  else
+    # Other instances stay on their member.
+    LXD_DIR="${LXD_ONE_DIR}" lxc list -c nL --format csv | grep -q "c1,node3"
+    LXD_DIR="${LXD_ONE_DIR}" lxc list -c nL --format csv | grep -q "c2,node3"
+    LXD_DIR="${LXD_ONE_DIR}" lxc list -c nL --format csv | grep -q "c3,node3"
+  fi
+
+  # Bring the third node back and restore it.
+  LXD_DIR="${LXD_ONE_DIR}" lxc config unset cluster.healing_threshold
+  respawn_lxd_cluster_member "${ns3}" "${LXD_THREE_DIR}"
+  sleep 12
+
+  LXD_DIR="${LXD_ONE_DIR}" lxc cluster restore node3 --force
+  LXD_DIR="${LXD_ONE_DIR}" lxc cluster show node3 | grep -q "status: Online"
+  LXD_DIR="${LXD_ONE_DIR}" lxc list -c nL --format csv | grep -q "c1,node3"
+  LXD_DIR="${LXD_ONE_DIR}" lxc list -c nL --format csv | grep -q "c2,node3"
+
+  # The instances left behind are running again after the restore.
+  LXD_DIR="${LXD_ONE_DIR}" lxc list -c nL --format csv | grep -q "c3,node3"
+  LXD_DIR="${LXD_ONE_DIR}" lxc info c3 | grep -q "Status: RUNNING"
+
+  LXD_DIR="${LXD_ONE_DIR}" lxc delete -f c1 c2 c3
+  LXD_DIR="${LXD_ONE_DIR}" lxc image delete testimage
+
+  LXD_DIR="${LXD_ONE_DIR}" lxd shutdown
+  LXD_DIR="${LXD_TWO_DIR}" lxd shutdown
+  LXD_DIR="${LXD_THREE_DIR}" lxd shutdown
+  sleep 0.5
+  rm -f "${LXD_ONE_DIR}/unix.socket"
+  rm -f "${LXD_TWO_DIR}/unix.socket"
+  rm -f "${LXD_THREE_DIR}/unix.socket"
+
+  teardown_clustering_netns
+  teardown_clustering_bridge
+
+  kill_lxd "${LXD_ONE_DIR}"
+  kill_lxd "${LXD_TWO_DIR}"
+  kill_lxd "${LXD_THREE_DIR}"
+}
+
+test_clustering_vm_live_migration() {
+  if [ ! -e /dev/kvm ] || ! command -v qemu-system-x86_64 >/dev/null 2>&1; then
+    echo "==> SKIP: VM live migration (missing KVM or QEMU)"
+    return
+  fi
+
+  # shellcheck disable=2039
+  local LXD_DIR
+
+  setup_clustering_bridge
+  prefix="lxd$$"
+  bridge="${prefix}"
+
+  setup_clustering_netns 1
+  LXD_ONE_DIR=$(mktemp -d -p "${TEST_DIR}" XXX)
+  chmod +x "${LXD_ONE_DIR}"
+  ns1="${prefix}1"
+  spawn_lxd_and_bootstrap_cluster "${ns1}" "${bridge}" "${LXD_ONE_DIR}"
+
+  # Add a newline at the end of each line. YAML as weird rules..
+  cert=$(sed ':a;N;$!ba;s/\n/\n\n/g' "${LXD_ONE_DIR}/cluster.crt")
+
+  # Spawn a second node
+  setup_clustering_netns 2
+  LXD_TWO_DIR=$(mktemp -d -p "${TEST_DIR}" XXX)
+  chmod +x "${LXD_TWO_DIR}"
+  ns2="${prefix}2"
+  spawn_lxd_and_join_cluster "${ns2}" "${bridge}" "${cert}" 2 1 "${LXD_TWO_DIR}"
+
+  LXD_DIR="${LXD_ONE_DIR}"
+
+  # An empty VM only runs its firmware, which is enough to transfer its running state.
+  lxc init --empty --vm v1 --target node1 -c limits.memory=256MiB -c security.secureboot=false
+  lxc start v1
+
+  # Live migration requires migration.stateful.
+  ! lxc move v1 --target node2 || false
+  lxc info v1 | grep -q "Location: node1"
+
+  lxc stop -f v1
+  lxc config set v1 migration.stateful=true
+  lxc start v1
+
+  # Renaming isn't possible as part of a live migration.
+  ! lxc move v1 v2 --target node2 || false
+
+  # The VM keeps running whilst it is moved to the other member and back.
+  lxc move v1 --target node2
+  lxc info v1 | grep -q "Location: node2"
+  lxc info v1 | grep -q "Status: RUNNING"
+  LXD_DIR="${LXD_TWO_DIR}" lxc info v1 | grep -q "Status: RUNNING"
+  ! pgrep -f "${LXD_ONE_DIR}/logs/v1/qemu.conf" || false
+
+  lxc move v1 --target node1
+  lxc info v1 | grep -q "Location: node1"
+  lxc info v1 | grep -q "Status: RUNNING"
+  ! pgrep -f "${LXD_TWO_DIR}/logs/v1/qemu.conf" || false
+
+  lxc delete -f v1
+
+  shutdown_lxd "${LXD_ONE_DIR}"
+  shutdown_lxd "${LXD_TWO_DIR}"
+  sleep 0.5
+  rm -f "${LXD_TWO_DIR}/unix.socket"
+  rm -f "${LXD_ONE_DIR}/unix.socket"
+
+  teardown_clustering_netns
+  teardown_clustering_bridge
+
+  kill_lxd "${LXD_ONE_DIR}"
+  kill_lxd "${LXD_TWO_DIR}"
+}
+
+test_clustering_overlay() {
+  # shellcheck disable=2039
+  local LXD_DIR
+
+  setup_clustering_bridge
+  prefix="lxd$$"
+  bridge="${prefix}"
+
+  # The random storage backend is not supported in clustering tests,
+  # since we need to have the same storage driver on all nodes.
+  driver="${LXD_BACKEND}"
+  if [ "${driver}" = "random" ] || [ "${driver}" = "lvm" ]; then
+    driver="dir"
+  fi
+
+  setup_clustering_netns 1
+  LXD_ONE_DIR=$(mktemp -d -p "${TEST_DIR}" XXX)
+  chmod +x "${LXD_ONE_DIR}"
+  ns1="${prefix}1"
+  spawn_lxd_and_bootstrap_cluster "${ns1}" "${bridge}" "${LXD_ONE_DIR}" "${driver}"
+
+  # Add a newline at the end of each line. YAML as weird rules..
+  cert=$(sed ':a;N;$!ba;s/\n/\n\n/g' "${LXD_ONE_DIR}/cluster.crt")
+
+  # Spawn a second node
+  setup_clustering_netns 2
+  LXD_TWO_DIR=$(mktemp -d -p "${TEST_DIR}" XXX)
+  chmod +x "${LXD_TWO_DIR}"
+  ns2="${prefix}2"
+  spawn_lxd_and_join_cluster "${ns2}" "${bridge}" "${cert}" 2 1 "${LXD_TWO_DIR}" "${driver}"
+
+  LXD_DIR="${LXD_ONE_DIR}"
+  ensure_import_testimage
+
+  for protocol in vxlan geneve; do
+    lxc network create "ovl$$" --type=overlay --target=node1
+    lxc network create "ovl$$" --type=overlay --target=node2
+    lxc network create "ovl$$" --type=overlay overlay.protocol="${protocol}" ipv4.address=192.0.2.1/24 ipv6.address=none
+
+    # Each member allocates addresses from its own part of the DHCP range.
+    grep -qx "ipv4.dhcp.ranges=192.0.2.2-192.0.2.127" "${LXD_ONE_DIR}/networks/ovl$$/overlay.dhcp"
+    grep -qx "ipv4.dhcp.ranges=192.0.2.128-192.0.2.254" "${LXD_TWO_DIR}/networks/ovl$$/overlay.dhcp"
+
+    lxc launch testimage c1 --target=node1 -n "ovl$$"
+    lxc launch testimage c2 --target=node2 -n "ovl$$"
+    lxc exec c1 -- udhcpc -i eth0 -n -q
+    lxc exec c2 -- udhcpc -i eth0 -n -q
+
+    c1_ip="$(lxc exec c1 -- ip -4 addr show eth0 | awk '/inet / {print $2}' | cut -d/ -f1)"
+    c2_ip="$(lxc exec c2 -- ip -4 addr show eth0 | awk '/inet / {print $2}' | cut -d/ -f1)"
+    [ "${c1_ip}" != "${c2_ip}" ]
+
+    # The instances on the two members reach each other over the tunnels.
+    lxc exec c1 -- ping -c2 -W5 "${c2_ip}"
+    lxc exec c2 -- ping -c2 -W5 "${c1_ip}"
+
+    # The tunnels are set up again when the daemon of a member restarts, without stopping its instances.
+    kill -9 "$(cat "${LXD_TWO_DIR}/lxd.pid")"
+    respawn_lxd_cluster_member "${ns2}" "${LXD_TWO_DIR}"
+    LXD_DIR="${LXD_ONE_DIR}"
+    lxc exec c1 -- ping -c2 -W5 "${c2_ip}"
+
+    lxc delete -f c1 c2
+    lxc network delete "ovl$$"
+  done
+
+  lxc image delete testimage
+
+  shutdown_lxd "${LXD_ONE_DIR}"
+  shutdown_lxd "${LXD_TWO_DIR}"
+  sleep 0.5
+  rm -f "${LXD_TWO_DIR}/unix.socket"
+  rm -f "${LXD_ONE_DIR}/unix.socket"
+
+  teardown_clustering_netns
+  teardown_clustering_bridge
+
+  kill_lxd "${LXD_ONE_DIR}"
+  kill_lxd "${LXD_TWO_DIR}"
+}
@@ -8,10 +8,20 @@ test_container_devices_disk() {
   test_container_devices_raw_mount_options
   test_container_devices_disk_ceph
   test_container_devices_disk_cephfs
+  test_container_devices_disk_io
 
   lxc delete -f foo
 }
 
+test_container_devices_disk_io() {
+  # The disk I/O options are only supported for virtual machines.
+  mkdir -p "${TEST_DIR}/io-source"
+  ! lxc config device add foo io disk source="${TEST_DIR}/io-source" path=/mnt io.bus=nvme || false
+  ! lxc config device add foo io disk source="${TEST_DIR}/io-source" path=/mnt io.cache=none || false
+  ! lxc config device add foo io disk source="${TEST_DIR}/io-source" path=/mnt io.threads=true || false
+  rmdir "${TEST_DIR}/io-source"
+}
+
 test_container_devices_disk_shift() {
   if ! grep -q shiftfs /proc/filesystems; then
     return
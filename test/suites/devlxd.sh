@@ -22,6 +22,31 @@ test_devlxd() {
   lxc config set devlxd security.nesting true
   ! lxc exec devlxd devlxd-client security.nesting | grep true || false
 
+  # Writable user config keys.
+  ! lxc exec devlxd devlxd-client set user.written hello || false
+  lxc config set devlxd security.devlxd.config true
+  lxc exec devlxd devlxd-client set user.written hello
+  [ "$(lxc config get devlxd user.written)" = "hello" ]
+  lxc exec devlxd devlxd-client unset user.written
+  [ "$(lxc config get devlxd user.written)" = "" ]
+  ! lxc exec devlxd devlxd-client set security.nesting false || false
+  [ "$(lxc config get devlxd security.nesting)" = "true" ]
+  lxc config unset devlxd security.devlxd.config
+
+  # Ready state.
+  lxc exec devlxd devlxd-client state Ready
+  lxc list devlxd -c s --format csv | grep -q READY
+  lxc exec devlxd devlxd-client state Started
+  lxc list devlxd -c s --format csv | grep -q RUNNING
+  ! lxc exec devlxd devlxd-client state Bogus || false
+
+  # Guest initiated snapshots.
+  ! lxc exec devlxd devlxd-client snapshot || false
+  lxc config set devlxd security.devlxd.snapshots true
+  lxc exec devlxd devlxd-client snapshot from-guest
+  lxc info devlxd | grep -q from-guest
+  lxc config unset devlxd security.devlxd.snapshots
+
   lxc exec devlxd devlxd-client monitor > "${TEST_DIR}/devlxd.log" &
   client=$!
 
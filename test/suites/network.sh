@@ -66,6 +66,15 @@ test_network() {
   lxc network list-leases lxdt$$ | grep STATIC | grep -q "${v4_addr}"
   lxc network list-leases lxdt$$ | grep STATIC | grep -q "${v6_addr}"
 
+  # Check the network allocations.
+  v4_subnet="$(lxc network get lxdt$$ ipv4.address)"
+  lxc network list-allocations --format=csv | grep "/1.0/networks/lxdt$$," | grep -q ",network,"
+  lxc network list-allocations --format=csv | grep "/1.0/instances/nettest," | grep -q "${v4_addr}/32,instance,lxdt$$"
+  lxc network list-allocations --address "${v4_addr}" --format=csv | grep -q "/1.0/instances/nettest,"
+  lxc network list-allocations --address "${v4_subnet}" --format=csv | grep -q "/1.0/networks/lxdt$$,"
+  ! lxc network list-allocations --address 192.0.2.1 --format=csv | grep -q "lxdt$$" || false
+  lxc query "/1.0/network-allocations?all-projects=true" | grep -q "/1.0/instances/nettest"
+
   # Request DHCPv6 lease (if udhcpc6 is in busybox image).
   busyboxUdhcpc6=1
   if ! lxc exec nettest -- busybox --list | grep udhcpc6 ; then
@@ -78,4 +87,17 @@ test_network() {
 
   lxc delete nettest -f
   lxc network delete lxdt$$
+
+  # Overlay networks.
+  lxc network create ovl$$ --type=overlay ipv4.address=192.0.2.1/24 ipv6.address=none
+  lxc network show ovl$$ | grep -q "type: overlay"
+  lxc network get ovl$$ ipv4.address | grep -q "192.0.2.1/24"
+  lxc network set ovl$$ overlay.protocol=geneve
+  lxc network set ovl$$ overlay.id=1000
+  ! lxc network set ovl$$ overlay.protocol=gre || false
+  ! lxc network set ovl$$ overlay.id=16777216 || false
+  ! lxc network set ovl$$ bridge.mode=fan || false
+  ! lxc network set ovl$$ tunnel.foo.protocol=vxlan || false
+  ! lxc network create ovltoolongnm --type=overlay || false
+  lxc network delete ovl$$
 }
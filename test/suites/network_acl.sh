@@ -43,6 +43,10 @@ EOF
  lxc network acl show testacl | grep 'destination_port: "22"'
  lxc network acl show testacl | grep "user.mykey: foo"
 
+ # ACL log (no logged rules).
+ [ -z "$(lxc network acl show-log testacl --format=csv)" ]
+ ! lxc network acl show-log missingacl || false
+
  # ACL Patch. Check for merged config and replaced description, ingress and egress fields.
  lxc query -X PATCH -d "{\\\"config\\\": {\\\"user.myotherkey\\\": \\\"bah\\\"}}" /1.0/network-acls/testacl
  lxc network acl show testacl | grep "user.mykey: foo"
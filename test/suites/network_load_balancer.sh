@@ -0,0 +1,98 @@
+test_network_load_balancer() {
+  ensure_import_testimage
+  ensure_has_localhost_remote "${LXD_ADDR}"
+
+  firewallDriver=$(lxc info | awk -F ":" '/firewall:/{gsub(/ /, "", $0); print $2}')
+  netName=lxdt$$
+
+  lxc network create "${netName}" \
+        ipv4.address=192.0.2.1/24 \
+        ipv6.address=fd42:4242:4242:1010::1/64
+
+  # Check creating empty load balancer doesn't create any firewall rules.
+  lxc network load-balancer create "${netName}" 198.51.100.1
+  if [ "$firewallDriver" = "xtables" ]; then
+    ! iptables -w -t nat -S | grep -c "generated for LXD network-load-balancer ${netName}" || false
+  else
+    ! nft -nn list chain inet lxd "lbprert.${netName}" || false
+    ! nft -nn list chain inet lxd "lbout.${netName}" || false
+    ! nft -nn list chain inet lxd "lbpstrt.${netName}" || false
+  fi
+
+  # Check load balancer is exported via BGP prefixes.
+  lxc query /internal/testing/bgp | grep "198.51.100.1/32"
+
+  # Check a listen address can't be used by both a forward and a load balancer.
+  ! lxc network forward create "${netName}" 198.51.100.1 || false
+
+  # Check can't use unsupported config keys.
+  ! lxc network load-balancer set "${netName}" 198.51.100.1 target_address=192.0.2.2 || false
+  lxc network load-balancer set "${netName}" 198.51.100.1 user.foo=bar
+  [ "$(lxc network load-balancer get "${netName}" 198.51.100.1 user.foo)" = "bar" ]
+
+  # Check backend validation.
+  ! lxc network load-balancer backend add "${netName}" 198.51.100.1 bad 192.0.3.2 80 || false
+  ! lxc network load-balancer backend add "${netName}" 198.51.100.1 bad fd42:4242:4242:1010::2 80 || false
+  lxc network load-balancer backend add "${netName}" 198.51.100.1 c1 192.0.2.2 80
+  ! lxc network load-balancer backend add "${netName}" 198.51.100.1 c1 192.0.2.3 80 || false
+  lxc network load-balancer backend add "${netName}" 198.51.100.1 c2 192.0.2.3 80
+
+  # Check port validation.
+  ! lxc network load-balancer port add "${netName}" 198.51.100.1 tcp 80 c3 || false
+  ! lxc network load-balancer port add "${netName}" 198.51.100.1 icmp 80 c1 || false
+  ! lxc network load-balancer port add "${netName}" 198.51.100.1 tcp 80-81 c1 || false
+
+  # Check adding a port creates the expected firewall rules.
+  lxc network load-balancer port add "${netName}" 198.51.100.1 tcp 80 c1,c2
+  if [ "$firewallDriver" = "xtables" ]; then
+    iptables -w -t nat -S | grep -- "-A PREROUTING -d 198.51.100.1/32 -p tcp -m tcp --dport 80 -m statistic --mode nth --every 2 --packet 0 -m comment --comment \"generated for LXD network-load-balancer ${netName}\" -j DNAT --to-destination 192.0.2.2:80"
+    iptables -w -t nat -S | grep -- "-A PREROUTING -d 198.51.100.1/32 -p tcp -m tcp --dport 80 -m comment --comment \"generated for LXD network-load-balancer ${netName}\" -j DNAT --to-destination 192.0.2.3:80"
+    iptables -w -t nat -S | grep -- "-A POSTROUTING -s 192.0.2.2/32 -d 192.0.2.2/32 -p tcp -m tcp --dport 80 -m comment --comment \"generated for LXD network-load-balancer ${netName}\" -j MASQUERADE"
+    iptables -w -t nat -S | grep -- "-A POSTROUTING -s 192.0.2.3/32 -d 192.0.2.3/32 -p tcp -m tcp --dport 80 -m comment --comment \"generated for LXD network-load-balancer ${netName}\" -j MASQUERADE"
+  else
+    nft -nn list chain inet lxd "lbprert.${netName}" | grep "ip daddr 198.51.100.1 tcp dport 80 dnat ip to numgen random mod 2 map { 0 : 192.0.2.2 . 80, 1 : 192.0.2.3 . 80 }"
+    nft -nn list chain inet lxd "lbout.${netName}" | grep "ip daddr 198.51.100.1 tcp dport 80 dnat ip to numgen random mod 2 map { 0 : 192.0.2.2 . 80, 1 : 192.0.2.3 . 80 }"
+    nft -nn list chain inet lxd "lbpstrt.${netName}" | grep "ip saddr 192.0.2.2 ip daddr 192.0.2.2 tcp dport 80 masquerade"
+    nft -nn list chain inet lxd "lbpstrt.${netName}" | grep "ip saddr 192.0.2.3 ip daddr 192.0.2.3 tcp dport 80 masquerade"
+  fi
+
+  # Check can't add port with duplicate listen port.
+  ! lxc network load-balancer port add "${netName}" 198.51.100.1 tcp 80 c2 || false
+
+  # Check can't remove a backend that is still used by a port.
+  ! lxc network load-balancer backend remove "${netName}" 198.51.100.1 c2 || false
+
+  # Check backend health is reported (nothing listens on the targets).
+  lxc network load-balancer show "${netName}" 198.51.100.1 | grep -A2 "backend_health:" | grep "c1: offline"
+
+  # Check list output.
+  lxc network load-balancer list "${netName}" --format=csv | grep "198.51.100.1,,2,1"
+
+  # Check removing ports and backends clears firewall rules.
+  lxc network load-balancer port remove "${netName}" 198.51.100.1 tcp 80
+  lxc network load-balancer backend remove "${netName}" 198.51.100.1 c2
+  if [ "$firewallDriver" = "xtables" ]; then
+    ! iptables -w -t nat -S | grep -c "generated for LXD network-load-balancer ${netName}" || false
+  else
+    ! nft -nn list chain inet lxd "lbprert.${netName}" || false
+    ! nft -nn list chain inet lxd "lbout.${netName}" || false
+    ! nft -nn list chain inet lxd "lbpstrt.${netName}" || false
+  fi
+
+  # Check deleting the load balancer removes its BGP prefix.
+  lxc network load-balancer delete "${netName}" 198.51.100.1
+  ! lxc query /internal/testing/bgp | grep "198.51.100.1/32" || false
+
+  # Check deleting network with load balancers removes firewall rules.
+  lxc network load-balancer create "${netName}" 198.51.100.1
+  lxc network load-balancer backend add "${netName}" 198.51.100.1 c1 192.0.2.2 80
+  lxc network load-balancer port add "${netName}" 198.51.100.1 tcp 80 c1
+  lxc network delete "${netName}"
+  if [ "$firewallDriver" = "xtables" ]; then
+    ! iptables -w -t nat -S | grep -c "generated for LXD network-load-balancer ${netName}" || false
+  else
+    ! nft -nn list chain inet lxd "lbprert.${netName}" || false
+    ! nft -nn list chain inet lxd "lbout.${netName}" || false
+    ! nft -nn list chain inet lxd "lbpstrt.${netName}" || false
+  fi
+}
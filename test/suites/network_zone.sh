@@ -48,6 +48,51 @@ test_network_zone() {
   dig "@${DNS_ADDR}" -p "${DNS_PORT}" axfr 2.0.192.in-addr.arpa | grep "PTR"
   dig "@${DNS_ADDR}" -p "${DNS_PORT}" axfr 0.1.0.1.2.4.2.4.2.4.2.4.2.4.d.f.ip6.arpa | grep "PTR"
 
+  # Direct queries
+  lxc network zone unset lxd.example.net peers.test.address
+  ! dig "@${DNS_ADDR}" -p "${DNS_PORT}" +short c1.lxd.example.net A | grep -q "192.0.2.42" || false
+  lxc network zone set lxd.example.net dns.query.allow=127.0.0.0/8
+  dig "@${DNS_ADDR}" -p "${DNS_PORT}" +short c1.lxd.example.net A | grep -q "192.0.2.42"
+  dig "@${DNS_ADDR}" -p "${DNS_PORT}" lxd.example.net SOA | grep -q "status: NOERROR"
+  dig "@${DNS_ADDR}" -p "${DNS_PORT}" missing.lxd.example.net A | grep -q "status: NXDOMAIN"
+  dig "@${DNS_ADDR}" -p "${DNS_PORT}" c1.example.org A | grep -q "status: REFUSED"
+  ! dig "@${DNS_ADDR}" -p "${DNS_PORT}" axfr lxd.example.net | grep -q "192.0.2.42" || false
+  lxc network zone unset lxd.example.net dns.query.allow
+  lxc network zone set lxd.example.net peers.test.address=127.0.0.1
+
+  # Incremental transfers
+  serial="$(dig "@${DNS_ADDR}" -p "${DNS_PORT}" +short lxd.example.net SOA | cut -d' ' -f3)"
+  [ "$(dig "@${DNS_ADDR}" -p "${DNS_PORT}" +short lxd.example.net SOA | cut -d' ' -f3)" = "${serial}" ]
+  [ "$(dig "@${DNS_ADDR}" -p "${DNS_PORT}" ixfr="${serial}" lxd.example.net | grep -c "SOA")" = "1" ]
+
+  # Custom records
+  lxc network zone record create lxd.example.net demo user.foo=bar
+  ! lxc network zone record create lxd.example.net demo || false
+  ! lxc network zone record entry add lxd.example.net demo A 2001:db8::1 || false
+  lxc network zone record entry add lxd.example.net demo A 1.1.1.1 --ttl 900
+  lxc network zone record entry add lxd.example.net demo AAAA 1111::1111
+
+  # Record changes are served right away, with a new serial and as an incremental transfer.
+  dig "@${DNS_ADDR}" -p "${DNS_PORT}" +short demo.lxd.example.net A | grep -qxF "1.1.1.1"
+  [ "$(dig "@${DNS_ADDR}" -p "${DNS_PORT}" +short lxd.example.net SOA | cut -d' ' -f3)" -gt "${serial}" ]
+  dig "@${DNS_ADDR}" -p "${DNS_PORT}" ixfr="${serial}" lxd.example.net | grep "^demo.lxd.example.net" | grep -qF "1111::1111"
+  ! lxc network zone record entry add lxd.example.net demo A "1.1.1.2 2.2.2.2" || false
+  ! lxc network zone record entry add lxd.example.net demo A "$(printf '1.1.1.2\n$ORIGIN evil.example.net.')" || false
+  ! lxc network zone record entry add lxd.example.net demo TXT "$(printf '"foo" )\nbar IN A 1.1.1.2')" || false
+  lxc network zone record create lxd.example.net @
+  lxc network zone record entry add lxd.example.net @ TXT '"v=spf1 mx ~all"'
+  [ "$(lxc network zone record get lxd.example.net demo user.foo)" = "bar" ]
+  lxc network zone record list lxd.example.net | grep -q demo
+  lxc network zone record show lxd.example.net demo | grep -q "value: 1.1.1.1"
+  dig "@${DNS_ADDR}" -p "${DNS_PORT}" axfr lxd.example.net | grep "^demo.lxd.example.net.\s\+900\s\+IN\s\+A\s\+1.1.1.1$"
+  dig "@${DNS_ADDR}" -p "${DNS_PORT}" axfr lxd.example.net | grep "^demo.lxd.example.net.\s\+300\s\+IN\s\+AAAA\s\+1111::1111$"
+  dig "@${DNS_ADDR}" -p "${DNS_PORT}" axfr lxd.example.net | grep "^lxd.example.net.\s\+300\s\+IN\s\+TXT\s\+\"v=spf1 mx ~all\"$"
+  lxc network zone record entry remove lxd.example.net demo A 1.1.1.1
+  ! dig "@${DNS_ADDR}" -p "${DNS_PORT}" axfr lxd.example.net | grep "1.1.1.1" || false
+  lxc network zone record delete lxd.example.net demo
+  lxc network zone record delete lxd.example.net @
+  ! dig "@${DNS_ADDR}" -p "${DNS_PORT}" axfr lxd.example.net | grep "demo" || false
+
   # Cleanup
   lxc delete -f c1
   lxc network delete "${netName}"
@@ -0,0 +1,91 @@
+test_oidc() {
+  # shellcheck disable=SC2039
+  local tcp_port oidc_pid
+
+  # Setup the stand-in OIDC issuer.
+  (
+    cd mini-oidc || return
+    go build ./...
+  )
+
+  tcp_port="$(local_tcp_port)"
+  echo "user1" > "${TEST_DIR}/oidc.user"
+  mini-oidc/mini-oidc -endpoint "127.0.0.1:${tcp_port}" -user-file "${TEST_DIR}/oidc.user" &
+  oidc_pid=$!
+  sleep 1
+
+  # OIDC isn't advertised until both the issuer and client ID are set.
+  lxc config set oidc.issuer "http://127.0.0.1:${tcp_port}"
+  ! curl --unix-socket "$LXD_DIR/unix.socket" "lxd/1.0" | jq .metadata.auth_methods | grep oidc || false
+  lxc config set oidc.client.id "device"
+  curl --unix-socket "$LXD_DIR/unix.socket" "lxd/1.0" | jq .metadata.auth_methods | grep oidc
+
+  # Requests with an invalid token are rejected and point to the issuer.
+  [ "$(curl -k -s -o /dev/null -w "%{http_code}" -H "Authorization: Bearer foo" "https://${LXD_ADDR}/1.0")" = "401" ]
+  curl -k -s -D - -o /dev/null -H "Authorization: Bearer foo" "https://${LXD_ADDR}/1.0" | grep -qi "^X-LXD-OIDC-issuer: http://127.0.0.1:${tcp_port}"
+
+  # Log in through the device flow.
+  lxc remote add oidc "https://${LXD_ADDR}" --accept-certificate --auth-type oidc
+  [ -s "${LXD_CONF}/oidctokens/oidc.json" ]
+  lxc_remote info oidc: | grep -q "auth: trusted"
+
+  # The stored tokens are used without logging in again.
+  ! lxc_remote project list oidc: 2>&1 | grep -q "^URL:" || false
+
+  # The identity is listed in the trust store, without access to any project.
+  lxc config trust list --format csv | grep -q "^oidc,user1@example.com,"
+  FINGERPRINT=$(lxc config trust list --format csv | grep "^oidc," | cut -d, -f4)
+  lxc config trust show "${FINGERPRINT}" | grep -q "restricted: true"
+  ! lxc_remote project list oidc: --format csv | grep -q "^default" || false
+
+  # Grant the identity access to a project.
+  lxc project create foo
+  lxc config trust show "${FINGERPRINT}" | sed -e "s/projects: \[\]/projects: ['foo']/" | lxc config trust edit "${FINGERPRINT}"
+  lxc_remote project list oidc: --format csv | grep -q "^foo"
+  ! lxc_remote project list oidc: --format csv | grep -q "^default" || false
+  ! lxc_remote project create oidc:bar || false
+
+  # A second user gets its own entry, which is restricted too.
+  echo "user2" > "${TEST_DIR}/oidc.user"
+  lxc remote remove oidc
+  [ ! -e "${LXD_CONF}/oidctokens/oidc.json" ]
+  lxc remote add oidc "https://${LXD_ADDR}" --accept-certificate --auth-type oidc
+  lxc config trust list --format csv | grep -q "^oidc,user2@example.com,"
+  FINGERPRINT=$(lxc config trust list --format csv | grep "^oidc,user2@example.com," | cut -d, -f4)
+  ! lxc_remote project list oidc: --format csv | grep -q "^default" || false
+  lxc config trust show "${FINGERPRINT}" | sed -e "s/restricted: true/restricted: false/" | lxc config trust edit "${FINGERPRINT}"
+  lxc_remote project list oidc: --format csv | grep -q "^default"
+
+  # Removing the identity revokes it rather than letting it be added again on its next request.
+  lxc config trust remove "${FINGERPRINT}"
+  lxc config trust list --format csv | grep -q "^oidc-revoked,user2@example.com,"
+  lxc_remote info oidc: | grep -q "auth: untrusted"
+  ! lxc_remote project list oidc: || false
+  ! lxc config trust list --format csv | grep -q "^oidc,user2@example.com," || false
+
+  # Changing the type back allows it again, restricted to no projects.
+  lxc config trust show "${FINGERPRINT}" | sed -e "s/type: oidc-revoked/type: oidc/" | lxc config trust edit "${FINGERPRINT}"
+  lxc_remote info oidc: | grep -q "auth: trusted"
+  ! lxc_remote project list oidc: --format csv | grep -q "^default" || false
+
+  # Unsetting the issuer disables OIDC.
+  lxc config unset oidc.issuer
+  ! lxc_remote project list oidc: || false
+
+  # Cleanup.
+  lxc remote remove oidc
+  for fingerprint in $(lxc config trust list --format csv | grep "^oidc," | cut -d, -f4); do
+    lxc config trust remove "${fingerprint}"
+  done
+
+  for fingerprint in $(lxc config trust list --format csv | grep "^oidc-revoked," | cut -d, -f4); do
+    lxc config trust remove "${fingerprint}"
+  done
+
+  [ "$(lxc config trust list --format csv | grep -c "^oidc")" = "0" ]
+
+  lxc project delete foo
+  lxc config unset oidc.client.id
+  kill "${oidc_pid}"
+  rm -f mini-oidc/mini-oidc "${TEST_DIR}/oidc.user"
+}
@@ -0,0 +1,76 @@
+s3cmd_bucket() {
+  # shellcheck disable=2039
+  local access_key secret_key
+  access_key="${1}"
+  secret_key="${2}"
+  shift 2
+
+  curl -sk --fail --user "${access_key}:${secret_key}" --aws-sigv4 "aws:amz:us-east-1:s3" "$@"
+}
+
+test_storage_buckets() {
+  # shellcheck disable=2039
+  local lxd_backend
+  lxd_backend=$(storage_backend "$LXD_DIR")
+
+  if [ "$lxd_backend" != "dir" ] && [ "$lxd_backend" != "btrfs" ] && [ "$lxd_backend" != "zfs" ]; then
+    echo "==> SKIP: storage buckets not supported on ${lxd_backend}"
+    return
+  fi
+
+  # shellcheck disable=2039
+  local pool bucket_addr bucket_url admin_access admin_secret ro_access ro_secret
+  pool="lxdtest-$(basename "${LXD_DIR}")"
+  bucket_addr="127.0.0.1:$(local_tcp_port)"
+  lxc config set core.storage_buckets_address "${bucket_addr}"
+
+  # Bucket management.
+  ! lxc storage bucket create "${pool}" "Invalid_Name" || false
+  lxc storage bucket create "${pool}" foo size=1GiB
+  lxc storage bucket list "${pool}" | grep -q foo
+  lxc storage bucket get "${pool}" foo size | grep -qx 1GiB
+  lxc storage bucket set "${pool}" foo size=2GiB
+  lxc storage bucket get "${pool}" foo size | grep -qx 2GiB
+  lxc storage bucket show "${pool}" foo | sed 's/^description:.*/description: bar/' | lxc storage bucket edit "${pool}" foo
+  lxc storage bucket show "${pool}" foo | grep -q 'description: bar'
+  bucket_url="$(lxc storage bucket show "${pool}" foo | awk '/^s3_url:/ {print $2}')"
+  [ "${bucket_url}" = "https://${bucket_addr}/default_foo" ]
+
+  # Key management.
+  lxc storage bucket key create "${pool}" foo admin-key --role=admin --access-key=ADMINACCESSKEY --secret-key=adminsecretkey
+  admin_access="ADMINACCESSKEY"
+  admin_secret="adminsecretkey"
+  ro_access="$(lxc storage bucket key create "${pool}" foo ro-key | awk '/^Access key:/ {print $3}')"
+  ro_secret="$(lxc storage bucket key show "${pool}" foo ro-key | awk '/^secret-key:/ {print $2}')"
+  lxc storage bucket key show "${pool}" foo ro-key | grep -q 'role: read-only'
+  lxc storage bucket key list "${pool}" foo | grep -q admin-key
+  lxc storage bucket key list "${pool}" foo | grep -q ro-key
+
+  # S3 access.
+  echo foobar > "${TEST_DIR}/bucket-object"
+  ! s3cmd_bucket "${admin_access}" wrong "${bucket_url}/" || false
+  s3cmd_bucket "${admin_access}" "${admin_secret}" -T "${TEST_DIR}/bucket-object" "${bucket_url}/dir/object"
+  s3cmd_bucket "${ro_access}" "${ro_secret}" "${bucket_url}/" | grep -q "<Key>dir/object</Key>"
+  [ "$(s3cmd_bucket "${ro_access}" "${ro_secret}" "${bucket_url}/dir/object")" = "foobar" ]
+  ! s3cmd_bucket "${ro_access}" "${ro_secret}" -T "${TEST_DIR}/bucket-object" "${bucket_url}/other" || false
+  ! s3cmd_bucket "${ro_access}" "${ro_secret}" -X DELETE "${bucket_url}/dir/object" || false
+  s3cmd_bucket "${admin_access}" "${admin_secret}" -X DELETE "${bucket_url}/dir/object"
+  ! s3cmd_bucket "${ro_access}" "${ro_secret}" "${bucket_url}/dir/object" || false
+
+  # Deleting a key revokes access.
+  lxc storage bucket key delete "${pool}" foo ro-key
+  ! s3cmd_bucket "${ro_access}" "${ro_secret}" "${bucket_url}/" || false
+
+  # Project disk limits account for buckets.
+  lxc project create p1 -c features.storage.volumes=true -c limits.disk=1GiB
+  ! lxc storage bucket create "${pool}" foo --project p1 || false
+  ! lxc storage bucket create "${pool}" foo size=2GiB --project p1 || false
+  lxc storage bucket create "${pool}" foo size=1GiB --project p1
+  lxc storage bucket delete "${pool}" foo --project p1
+  lxc project delete p1
+
+  lxc storage bucket delete "${pool}" foo
+  ! lxc storage bucket show "${pool}" foo || false
+  lxc config unset core.storage_buckets_address
+  rm -f "${TEST_DIR}/bucket-object"
+}
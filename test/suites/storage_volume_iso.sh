@@ -0,0 +1,39 @@
+test_storage_volume_iso() {
+  ensure_import_testimage
+
+  # shellcheck disable=2039
+  local pool
+  pool="lxdtest-$(basename "${LXD_DIR}")"
+
+  # Create a fake ISO image.
+  truncate -s 8MiB "${TEST_DIR}/foo.iso"
+
+  # ISO volumes can only be created by importing an image.
+  ! lxc storage volume create "${pool}" foo --type=iso || false
+
+  # Import the ISO image, using the file name as the volume name.
+  lxc storage volume import "${pool}" "${TEST_DIR}/foo.iso"
+  lxc storage volume show "${pool}" foo | grep -q "content_type: iso"
+
+  # Import it again using an explicit name and type.
+  cp "${TEST_DIR}/foo.iso" "${TEST_DIR}/bar.img"
+  lxc storage volume import "${pool}" "${TEST_DIR}/bar.img" bar --type=iso
+  lxc storage volume show "${pool}" bar | grep -q "content_type: iso"
+
+  # The size of ISO volumes cannot be changed.
+  ! lxc storage volume set "${pool}" foo size=16MiB || false
+
+  # ISO volumes cannot be attached to containers.
+  lxc init testimage c1
+  ! lxc config device add c1 foo disk pool="${pool}" source=foo || false
+  lxc delete c1
+
+  # ISO volumes can be copied.
+  lxc storage volume copy "${pool}/foo" "${pool}/baz"
+  lxc storage volume show "${pool}" baz | grep -q "content_type: iso"
+
+  lxc storage volume delete "${pool}" foo
+  lxc storage volume delete "${pool}" bar
+  lxc storage volume delete "${pool}" baz
+  rm -f "${TEST_DIR}/foo.iso" "${TEST_DIR}/bar.img"
+}
@@ -0,0 +1,39 @@
+test_uefi_vars() {
+  if [ ! -e /dev/kvm ] || ! command -v qemu-system-x86_64 >/dev/null 2>&1 || ! command -v uefivars >/dev/null 2>&1; then
+    echo "==> SKIP: UEFI variables (missing KVM, QEMU or uefivars)"
+    return
+  fi
+
+  lxd_backend=$(storage_backend "$LXD_DIR")
+  key="LXDTest-6d1e0d07-6d3f-4d1b-9c3e-3f3c2f3c4a5b"
+
+  lxc init --empty --vm v1 -c security.secureboot=false
+
+  # Reading the variables of a VM that never started doesn't create its NVRAM file.
+  [ "$(lxc query /1.0/instances/v1/uefi-vars | jq '.variables | length')" -gt 0 ]
+  if [ "${lxd_backend}" = "dir" ]; then
+    [ ! -e "${LXD_DIR}/virtual-machines/v1/qemu.nvram" ]
+  fi
+
+  # Set, get, show and unset a variable.
+  lxc config uefi set v1 "${key}=0102"
+  [ "$(lxc config uefi get v1 "${key}")" = "0102" ]
+  lxc config uefi show v1 | grep -F "${key}"
+  [ "$(lxc query /1.0/instances/v1/uefi-vars | jq -r ".variables[\"${key}\"].attr")" = "7" ]
+
+  # Invalid keys and values are rejected.
+  ! lxc config uefi set v1 "LXDTest=01" || false
+  ! lxc config uefi set v1 "LXDTest-6d1e0d07-6d3f-4d1b-9c3e-3f3c2f3c4a5z=01" || false
+  ! lxc config uefi set v1 "${key}=0x" || false
+  [ "$(lxc config uefi get v1 "${key}")" = "0102" ]
+
+  lxc config uefi unset v1 "${key}"
+  ! lxc config uefi get v1 "${key}" || false
+  ! lxc config uefi unset v1 "${key}" || false
+
+  # UEFI variables are only available for virtual machines.
+  lxc init --empty c1
+  ! lxc query /1.0/instances/c1/uefi-vars || false
+
+  lxc delete c1 v1
+}